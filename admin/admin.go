@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Roles are ordered, a role is allowed to do everything the roles before it are allowed to.
+const (
+	RoleViewer       = "viewer"
+	RoleAbuseHandler = "abuse-handler"
+	RoleOperator     = "operator"
+)
+
+var (
+	ranks = map[string]int{
+		RoleViewer:       1,
+		RoleAbuseHandler: 2,
+		RoleOperator:     3,
+	}
+
+	mu      sync.RWMutex
+	current = &Config{}
+)
+
+// Identity is an authenticated admin credential.
+type Identity struct {
+	Name string
+	Role string
+}
+
+// Allows returns whether the identity holds the role or a role after it.
+func (i *Identity) Allows(role string) bool {
+	return ranks[i.Role] >= ranks[role]
+}
+
+// Token is a static admin credential of ADMIN_ROLES_FILE.
+type Token struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  string `json:"role"`
+}
+
+// Config is the content of ADMIN_ROLES_FILE, OIDC groups are mapped to roles
+// and the highest role of the groups of an id token is used.
+type Config struct {
+	Tokens []Token      `json:"tokens"`
+	OIDC   *OIDCOptions `json:"oidc,omitempty"`
+
+	verifier *verifier
+}
+
+type OIDCOptions struct {
+	Issuer      string            `json:"issuer"`
+	ClientID    string            `json:"clientID"`
+	GroupsClaim string            `json:"groupsClaim"`
+	Groups      map[string]string `json:"groups"`
+}
+
+// Load reads ADMIN_ROLES_FILE, ADMIN_TOKEN is always an operator credential.
+func Load() error {
+	c := &Config{}
+
+	if path := os.Getenv("ADMIN_ROLES_FILE"); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, errLoadConfig, path)
+		}
+		if err := json.Unmarshal(data, c); err != nil {
+			return errors.Wrapf(err, errLoadConfig, path)
+		}
+		if err := c.validate(); err != nil {
+			return errors.Wrapf(err, errLoadConfig, path)
+		}
+		if c.OIDC != nil {
+			if c.OIDC.GroupsClaim == "" {
+				c.OIDC.GroupsClaim = "groups"
+			}
+			c.verifier = newVerifier(c.OIDC.Issuer, c.OIDC.ClientID)
+		}
+		logrus.Infof("loaded %d admin tokens from %s, oidc: %t", len(c.Tokens), path, c.OIDC != nil)
+	}
+
+	mu.Lock()
+	current = c
+	mu.Unlock()
+
+	return nil
+}
+
+// Enabled returns whether any admin credential is configured.
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return os.Getenv("ADMIN_TOKEN") != "" || len(current.Tokens) > 0 || current.OIDC != nil
+}
+
+// Authenticate returns the identity of a bearer token, tokens which look like a JWT
+// are verified as OIDC id tokens when OIDC is configured.
+func Authenticate(token string) (*Identity, bool) {
+	if token == "" {
+		return nil, false
+	}
+
+	if admin := os.Getenv("ADMIN_TOKEN"); admin != "" && subtle.ConstantTimeCompare([]byte(admin), []byte(token)) == 1 {
+		return &Identity{Name: "admin", Role: RoleOperator}, true
+	}
+
+	mu.RLock()
+	c := current
+	mu.RUnlock()
+
+	for _, t := range c.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return &Identity{Name: t.Name, Role: t.Role}, true
+		}
+	}
+
+	if c.verifier == nil || strings.Count(token, ".") != 2 {
+		return nil, false
+	}
+
+	claims, err := c.verifier.verify(token)
+	if err != nil {
+		logrus.Debugf("failed to verify admin id token: %v", err)
+		return nil, false
+	}
+
+	id := &Identity{Name: claims.subject()}
+	for _, g := range claims.groups(c.OIDC.GroupsClaim) {
+		if r, ok := c.OIDC.Groups[g]; ok && ranks[r] > ranks[id.Role] {
+			id.Role = r
+		}
+	}
+	if id.Role == "" {
+		return nil, false
+	}
+
+	return id, true
+}
+
+func (c *Config) validate() error {
+	for _, t := range c.Tokens {
+		if _, ok := ranks[t.Role]; !ok || t.Token == "" {
+			return errors.Errorf(errInvalidRole, t.Role, t.Name)
+		}
+	}
+	if c.OIDC != nil {
+		for g, r := range c.OIDC.Groups {
+			if _, ok := ranks[r]; !ok {
+				return errors.Errorf(errInvalidRole, r, g)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package admin
+
+const (
+	errDecodeToken    = "failed to decode id token"
+	errDiscover       = "failed to discover oidc provider %s"
+	errExpiredToken   = "id token is expired"
+	errFetchKeys      = "failed to fetch oidc keys from %s"
+	errInvalidAud     = "id token is not issued for %s"
+	errInvalidIssuer  = "id token is issued by %s"
+	errInvalidRole    = "invalid admin role %s of %s"
+	errInvalidSign    = "invalid id token signature"
+	errLoadConfig     = "failed to load admin roles from %s"
+	errUnknownKey     = "unknown oidc key %s"
+	errUnsupportedAlg = "unsupported id token algorithm %s"
+)
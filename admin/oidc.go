@@ -0,0 +1,266 @@
+package admin
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	discoveryPath  = "/.well-known/openid-configuration"
+	fetchTimeout   = 10 * time.Second
+	refreshPeriod  = time.Minute
+	allowedSkew    = time.Minute
+	algRS256       = "RS256"
+	algES256       = "ES256"
+	es256KeyLength = 32
+)
+
+// verifier checks OIDC id tokens of one issuer, the signing keys are fetched from the
+// jwks_uri of the issuer and fetched again when a token is signed with an unknown key.
+type verifier struct {
+	issuer   string
+	clientID string
+	client   *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+type claims map[string]interface{}
+
+func newVerifier(issuer, clientID string) *verifier {
+	return &verifier{
+		issuer:   strings.TrimSuffix(issuer, "/"),
+		clientID: clientID,
+		client:   &http.Client{Timeout: fetchTimeout},
+		keys:     make(map[string]crypto.PublicKey),
+	}
+}
+
+func (v *verifier) verify(token string) (claims, error) {
+	parts := strings.Split(token, ".")
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errors.Wrap(err, errDecodeToken)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, errDecodeToken)
+	}
+
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != algRS256 {
+			return nil, errors.Errorf(errUnsupportedAlg, header.Alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+			return nil, errors.New(errInvalidSign)
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != algES256 || len(sig) != 2*es256KeyLength {
+			return nil, errors.Errorf(errUnsupportedAlg, header.Alg)
+		}
+		r := new(big.Int).SetBytes(sig[:es256KeyLength])
+		s := new(big.Int).SetBytes(sig[es256KeyLength:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return nil, errors.New(errInvalidSign)
+		}
+	default:
+		return nil, errors.Errorf(errUnsupportedAlg, header.Alg)
+	}
+
+	c := claims{}
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, errors.Wrap(err, errDecodeToken)
+	}
+
+	if iss, _ := c["iss"].(string); strings.TrimSuffix(iss, "/") != v.issuer {
+		return nil, errors.Errorf(errInvalidIssuer, iss)
+	}
+	if !c.audience(v.clientID) {
+		return nil, errors.Errorf(errInvalidAud, v.clientID)
+	}
+
+	now := time.Now()
+	if exp, ok := c["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0).Add(allowedSkew)) {
+		return nil, errors.New(errExpiredToken)
+	}
+	if nbf, ok := c["nbf"].(float64); ok && now.Add(allowedSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New(errExpiredToken)
+	}
+
+	return c, nil
+}
+
+// Used to get the signing key of a token, the keys are fetched at most once a refresh period
+func (v *verifier) key(kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+
+	if time.Since(v.fetched) < refreshPeriod {
+		return nil, errors.Errorf(errUnknownKey, kid)
+	}
+	v.fetched = time.Now()
+
+	keys, err := v.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+
+	if k, ok := v.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, errors.Errorf(errUnknownKey, kid)
+}
+
+func (v *verifier) fetchKeys() (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JwksURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(v.issuer+discoveryPath, &discovery); err != nil {
+		return nil, errors.Wrapf(err, errDiscover, v.issuer)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != v.issuer {
+		return nil, errors.Errorf(errInvalidIssuer, discovery.Issuer)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(discovery.JwksURI, &jwks); err != nil {
+		return nil, errors.Wrapf(err, errFetchKeys, discovery.JwksURI)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch {
+		case k.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+func (v *verifier) getJSON(url string, out interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected reply status: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Used to check the aud claim, it is either a string or a list of strings
+func (c claims) audience(clientID string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if s, ok := a.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c claims) subject() string {
+	for _, k := range []string{"email", "preferred_username", "sub"} {
+		if s, ok := c[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// Used to get the groups of the token, a single group may be given as a string
+func (c claims) groups(claim string) []string {
+	switch gs := c[claim].(type) {
+	case string:
+		return []string{gs}
+	case []interface{}:
+		result := make([]string, 0, len(gs))
+		for _, g := range gs {
+			if s, ok := g.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func decodeSegment(seg string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
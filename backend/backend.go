@@ -1,19 +1,39 @@
 package backend
 
 import (
+	"time"
+
 	"github.com/rancher/rdns-server/model"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 var currentBackend Backend
 
+// ErrNameTaken is returned when the requested name of a new domain is used or frozen.
+var ErrNameTaken = errors.New("name is taken")
+
+// ErrForeignOwner is returned when the records of a name are written which an external-dns
+// ownership TXT record gives to another owner.
+var ErrForeignOwner = errors.New("name is owned by another external-dns owner")
+
+// ErrUnknownHost is returned when a host is drained which is not a host of the domain.
+var ErrUnknownHost = errors.New("host is not a host of the domain")
+
+// ErrStateConflict is returned when an operational state is written with a version which is not
+// its current version, another replica has changed it since it was read.
+var ErrStateConflict = errors.New("operational state is changed by another writer")
+
 type Backend interface {
 	Get(opts *model.DomainOptions) (model.Domain, error)
 	Set(opts *model.DomainOptions) (model.Domain, error)
 	Update(opts *model.DomainOptions) (model.Domain, error)
+	PatchHosts(fqdn string, add, remove []string) (model.Domain, error)
 	Delete(opts *model.DomainOptions) error
 	Renew(opts *model.DomainOptions) (model.Domain, error)
+	// Keepalive renews a domain like Renew without reading its records, it returns the new expiration
+	Keepalive(fqdn string) (time.Time, error)
 	SetText(opts *model.DomainOptions) (model.Domain, error)
 	GetText(opts *model.DomainOptions) (model.Domain, error)
 	UpdateText(opts *model.DomainOptions) (model.Domain, error)
@@ -22,10 +42,41 @@ type Backend interface {
 	GetCNAME(opts *model.DomainOptions) (model.Domain, error)
 	UpdateCNAME(opts *model.DomainOptions) (model.Domain, error)
 	DeleteCNAME(opts *model.DomainOptions) error
+	SetCAA(fqdn string, records []model.CAARecord) error
+	GetCAA(fqdn string) ([]model.CAARecord, error)
+	DeleteCAA(fqdn string) error
+	SetQueryTypes(fqdn string, types []string) error
+	GetQueryTypes(fqdn string) ([]string, error)
 	GetToken(fqdn string) (string, error)
 	GetTokenCount() (int64, error)
+	RotateToken(fqdn, token string) error
+	SetScopedToken(fqdn, scope, origin string) error
+	GetScopedToken(fqdn, scope string) (string, error)
+	DeleteScopedToken(fqdn, scope string) error
+	SetAPIKey(k *model.APIKey) error
+	GetAPIKey(id string) (model.APIKey, error)
+	ListAPIKeys() ([]model.APIKey, error)
+	DeleteAPIKey(id string) error
+	List(opts *model.ListOptions) (model.DomainList, error)
+	Search(opts *model.SearchOptions) (model.DomainList, error)
+	HostDomains(host string) ([]string, error)
+	SetTTL(fqdn string, ttl uint32) (model.Domain, error)
+	DrainHost(fqdn, host string, until time.Time) (model.Domain, error)
+	Suspend(s *model.Suspension) error
+	Unsuspend(fqdn string) error
+	ListSuspensions() ([]model.Suspension, error)
+	SetWebhook(w *model.Webhook) error
+	ListWebhooks(fqdn string) ([]model.Webhook, error)
+	DeleteWebhook(fqdn, id string) error
+	EnqueueJob(j *model.Job) error
+	ClaimJobs(kind string, limit int, visibility time.Duration) ([]model.Job, error)
+	DeleteJob(kind, id string) error
+	GetOperationalState(key string) (model.OperationalState, error)
+	SetOperationalState(s *model.OperationalState) error
+	ListOperationalStates() ([]model.OperationalState, error)
 	GetZone() string
 	GetName() string
+	Ping() error
 	MigrateFrozen(opts *model.MigrateFrozen) error
 	MigrateToken(opts *model.MigrateToken) error
 	MigrateRecord(opts *model.MigrateRecord) error
@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"strings"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// MaxCNAMEDepth is the number of rdns CNAME records a chain may pass through
+// before it leaves the zone.
+const MaxCNAMEDepth = 3
+
+// CheckCNAME validates a CNAME target of fqdn before it is written. Targets outside the zone
+// are accepted as is, targets inside the zone are followed and must neither lead back to fqdn
+// nor pass through more than MaxCNAMEDepth rdns CNAME records.
+func CheckCNAME(b Backend, fqdn, target string) error {
+	target = strings.ToLower(strings.TrimSuffix(target, "."))
+	if target == "" {
+		return errors.New(errCNAMEEmpty)
+	}
+	if _, ok := dns.IsDomainName(target); !ok {
+		return errors.Errorf(errCNAMENotAllowed, target)
+	}
+
+	seen := map[string]bool{}
+	if fqdn != "" {
+		seen[baseDomain(strings.ToLower(fqdn), ZoneOf(b, fqdn))] = true
+	}
+
+	next := target
+	for depth := 0; ; depth++ {
+		base := baseDomain(next, ZoneOf(b, next))
+		if base == "" {
+			// the chain leaves the zone
+			return nil
+		}
+		if seen[base] {
+			return errors.Errorf(errCNAMELoop, target, base)
+		}
+		seen[base] = true
+
+		d, err := b.GetCNAME(&model.DomainOptions{Fqdn: base})
+		if err != nil || d.CNAME == "" {
+			// the chain ends at a rdns record which is not a CNAME
+			return nil
+		}
+		if depth >= MaxCNAMEDepth {
+			return errors.Errorf(errCNAMEDepth, target, MaxCNAMEDepth)
+		}
+
+		next = strings.ToLower(strings.TrimSuffix(d.CNAME, "."))
+	}
+}
+
+// Used to get the rdns domain which serves a name, sub names are served by the wildcard record
+// e.g. x1.qrn7oq.lb.rancher.cloud => qrn7oq.lb.rancher.cloud
+// e.g. www.example.com => ""
+func baseDomain(name, zone string) string {
+	zone = strings.ToLower(strings.Trim(zone, "."))
+	slug := util.SlugWithZone(name, zone)
+	if slug == "" {
+		return ""
+	}
+	return slug + "." + zone
+}
@@ -0,0 +1,742 @@
+package dual
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/etcdv3"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Name           = "dual"
+	typeA          = "A"
+	typeTXT        = "TXT"
+	typeToken      = "TOKEN"
+	typeFrozen     = "FROZEN"
+	typeSuspension = "SUSPENSION"
+	typeWebhook    = "WEBHOOK"
+	typeTTL        = "TTL"
+	typeCAA        = "CAA"
+	typeQueryTypes = "QUERYTYPES"
+	typeDrain      = "DRAIN"
+	typeJob        = "JOB"
+	typeAPIKey     = "APIKEY"
+	typeState      = "STATE"
+
+	// StateOld only uses the old backend
+	StateOld = "old"
+	// StateReadOld writes to both backends and reads from the old backend
+	StateReadOld = "read-old"
+	// StateReadNew writes to both backends and reads from the new backend
+	StateReadNew = "read-new"
+	// StateNew only uses the new backend
+	StateNew = "new"
+)
+
+var (
+	// states are switched one step at a time, so every switch can be rolled back
+	transitions = map[string][]string{
+		StateOld:     {StateReadOld},
+		StateReadOld: {StateOld, StateReadNew},
+		StateReadNew: {StateReadOld, StateNew},
+		StateNew:     {StateReadNew},
+	}
+
+	mirrorErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_double_write_errors",
+		Help: "The number of writes which failed to be mirrored to the secondary backend",
+	}, []string{"backend"})
+)
+
+// Backend writes to an old and a new backend while one of them serves the reads.
+// The backend which serves the reads is the primary, its result is returned; writes to the
+// other backend are mirrored with the same fqdn and token and only logged when they fail.
+type Backend struct {
+	Old backend.Backend
+	New backend.Backend
+
+	mu    sync.RWMutex
+	state string
+}
+
+func NewBackend(old, new backend.Backend, state string) (*Backend, error) {
+	if _, ok := transitions[state]; !ok {
+		return nil, errors.Errorf(errInvalidState, state)
+	}
+
+	return &Backend{
+		Old:   old,
+		New:   new,
+		state: state,
+	}, nil
+}
+
+func (b *Backend) State() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.state
+}
+
+// SetState switches to the next or previous state of the migration.
+func (b *Backend) SetState(state string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := transitions[state]; !ok {
+		return errors.Errorf(errInvalidState, state)
+	}
+
+	if state == b.state {
+		return nil
+	}
+
+	for _, s := range transitions[b.state] {
+		if s == state {
+			logrus.Infof("switch double-write state from %s to %s", b.state, state)
+			b.state = state
+			return nil
+		}
+	}
+
+	return errors.Errorf(errInvalidTransition, b.state, state)
+}
+
+// Restore switches to a state which is persisted, it was reached one step at a time by the
+// replica which switched it so the steps between are not checked.
+func (b *Backend) Restore(state string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := transitions[state]; !ok {
+		return errors.Errorf(errInvalidState, state)
+	}
+
+	if state != b.state {
+		logrus.Infof("restore double-write state %s over %s", state, b.state)
+		b.state = state
+	}
+	return nil
+}
+
+// Used to get the primary and the secondary backend of the current state,
+// the secondary is nil when writes are not mirrored.
+func (b *Backend) backends() (backend.Backend, backend.Backend) {
+	switch b.State() {
+	case StateReadOld:
+		return b.Old, b.New
+	case StateReadNew:
+		return b.New, b.Old
+	case StateNew:
+		return b.New, nil
+	}
+	return b.Old, nil
+}
+
+func (b *Backend) primary() backend.Backend {
+	p, _ := b.backends()
+	return p
+}
+
+// Reader returns the reader of the backend which serves the reads of the current state, the
+// reads are never mirrored.
+func (b *Backend) Reader() backend.Backend {
+	return backend.Reader(b.primary())
+}
+
+func (b *Backend) GetName() string {
+	return Name
+}
+
+func (b *Backend) GetZone() string {
+	return b.primary().GetZone()
+}
+
+func (b *Backend) GetZones() []string {
+	return backend.Zones(b.primary())
+}
+
+// Ping checks both backends while the double-write lasts, the writes fail without either of them.
+func (b *Backend) Ping() error {
+	p, s := b.backends()
+	if err := p.Ping(); err != nil {
+		return err
+	}
+	if s != nil {
+		return s.Ping()
+	}
+	return nil
+}
+
+func (b *Backend) Get(opts *model.DomainOptions) (model.Domain, error) {
+	return b.primary().Get(opts)
+}
+
+func (b *Backend) Set(opts *model.DomainOptions) (model.Domain, error) {
+	p, s := b.backends()
+
+	d, err := p.Set(opts)
+	if err != nil || s == nil {
+		return d, err
+	}
+
+	b.mirror(p, s, d)
+
+	return d, nil
+}
+
+func (b *Backend) Update(opts *model.DomainOptions) (model.Domain, error) {
+	p, s := b.backends()
+
+	d, err := p.Update(opts)
+	if err != nil || s == nil {
+		return d, err
+	}
+
+	if _, err := s.Update(opts); err != nil {
+		// the domain may be created before the double-write begins
+		b.mirror(p, s, d)
+	}
+
+	return d, nil
+}
+
+func (b *Backend) Delete(opts *model.DomainOptions) error {
+	p, s := b.backends()
+
+	if err := p.Delete(opts); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeA, opts.Fqdn, s.Delete(opts))
+	}
+
+	return nil
+}
+
+func (b *Backend) Renew(opts *model.DomainOptions) (model.Domain, error) {
+	p, s := b.backends()
+
+	d, err := p.Renew(opts)
+	if err != nil || s == nil {
+		return d, err
+	}
+
+	_, err = s.Renew(opts)
+	b.check(s, typeA, opts.Fqdn, err)
+
+	return d, nil
+}
+
+func (b *Backend) Keepalive(fqdn string) (time.Time, error) {
+	p, s := b.backends()
+
+	t, err := p.Keepalive(fqdn)
+	if err != nil || s == nil {
+		return t, err
+	}
+
+	_, err = s.Keepalive(fqdn)
+	b.check(s, typeA, fqdn, err)
+
+	return t, nil
+}
+
+func (b *Backend) SetText(opts *model.DomainOptions) (model.Domain, error) {
+	p, s := b.backends()
+
+	d, err := p.SetText(opts)
+	if err != nil || s == nil {
+		return d, err
+	}
+
+	_, err = s.SetText(opts)
+	b.check(s, typeTXT, opts.Fqdn, err)
+
+	return d, nil
+}
+
+func (b *Backend) GetText(opts *model.DomainOptions) (model.Domain, error) {
+	return b.primary().GetText(opts)
+}
+
+func (b *Backend) UpdateText(opts *model.DomainOptions) (model.Domain, error) {
+	p, s := b.backends()
+
+	d, err := p.UpdateText(opts)
+	if err != nil || s == nil {
+		return d, err
+	}
+
+	if _, err := s.UpdateText(opts); err != nil {
+		_, err = s.SetText(opts)
+		b.check(s, typeTXT, opts.Fqdn, err)
+	}
+
+	return d, nil
+}
+
+func (b *Backend) DeleteText(opts *model.DomainOptions) error {
+	p, s := b.backends()
+
+	if err := p.DeleteText(opts); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeTXT, opts.Fqdn, s.DeleteText(opts))
+	}
+
+	return nil
+}
+
+// CNAME records are generated with a random name and have no migrate api,
+// so they are only written to the primary backend.
+func (b *Backend) SetCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	return b.primary().SetCNAME(opts)
+}
+
+func (b *Backend) GetCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	return b.primary().GetCNAME(opts)
+}
+
+func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	return b.primary().UpdateCNAME(opts)
+}
+
+func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
+	return b.primary().DeleteCNAME(opts)
+}
+
+func (b *Backend) GetToken(fqdn string) (string, error) {
+	return b.primary().GetToken(fqdn)
+}
+
+func (b *Backend) GetTokenCount() (int64, error) {
+	return b.primary().GetTokenCount()
+}
+
+// RotateToken writes the same token origin to both backends, so the new token is accepted
+// whichever backend serves the reads.
+func (b *Backend) RotateToken(fqdn, token string) error {
+	p, s := b.backends()
+
+	if err := p.RotateToken(fqdn, token); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeToken, fqdn, s.RotateToken(fqdn, token))
+	}
+
+	return nil
+}
+
+func (b *Backend) List(opts *model.ListOptions) (model.DomainList, error) {
+	return b.primary().List(opts)
+}
+
+func (b *Backend) Search(opts *model.SearchOptions) (model.DomainList, error) {
+	return b.primary().Search(opts)
+}
+
+func (b *Backend) HostDomains(host string) ([]string, error) {
+	return b.primary().HostDomains(host)
+}
+
+func (b *Backend) SetScopedToken(fqdn, scope, origin string) error {
+	p, s := b.backends()
+
+	if err := p.SetScopedToken(fqdn, scope, origin); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeToken, fqdn, s.SetScopedToken(fqdn, scope, origin))
+	}
+
+	return nil
+}
+
+func (b *Backend) GetScopedToken(fqdn, scope string) (string, error) {
+	return b.primary().GetScopedToken(fqdn, scope)
+}
+
+func (b *Backend) DeleteScopedToken(fqdn, scope string) error {
+	p, s := b.backends()
+
+	if err := p.DeleteScopedToken(fqdn, scope); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeToken, fqdn, s.DeleteScopedToken(fqdn, scope))
+	}
+
+	return nil
+}
+
+func (b *Backend) SetAPIKey(k *model.APIKey) error {
+	p, s := b.backends()
+
+	if err := p.SetAPIKey(k); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeAPIKey, k.ID, s.SetAPIKey(k))
+	}
+
+	return nil
+}
+
+func (b *Backend) GetAPIKey(id string) (model.APIKey, error) {
+	return b.primary().GetAPIKey(id)
+}
+
+func (b *Backend) ListAPIKeys() ([]model.APIKey, error) {
+	return b.primary().ListAPIKeys()
+}
+
+func (b *Backend) DeleteAPIKey(id string) error {
+	p, s := b.backends()
+
+	if err := p.DeleteAPIKey(id); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeAPIKey, id, s.DeleteAPIKey(id))
+	}
+
+	return nil
+}
+
+func (b *Backend) PatchHosts(fqdn string, add, remove []string) (model.Domain, error) {
+	p, s := b.backends()
+
+	d, err := p.PatchHosts(fqdn, add, remove)
+	if err != nil {
+		return d, err
+	}
+
+	if s != nil {
+		_, err := s.PatchHosts(fqdn, add, remove)
+		b.check(s, typeA, fqdn, err)
+	}
+
+	return d, nil
+}
+
+func (b *Backend) SetTTL(fqdn string, ttl uint32) (model.Domain, error) {
+	p, s := b.backends()
+
+	d, err := p.SetTTL(fqdn, ttl)
+	if err != nil || s == nil {
+		return d, err
+	}
+
+	_, err = s.SetTTL(fqdn, ttl)
+	b.check(s, typeTTL, fqdn, err)
+
+	return d, nil
+}
+
+func (b *Backend) SetCAA(fqdn string, records []model.CAARecord) error {
+	p, s := b.backends()
+
+	if err := p.SetCAA(fqdn, records); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeCAA, fqdn, s.SetCAA(fqdn, records))
+	}
+
+	return nil
+}
+
+func (b *Backend) GetCAA(fqdn string) ([]model.CAARecord, error) {
+	return b.primary().GetCAA(fqdn)
+}
+
+func (b *Backend) DeleteCAA(fqdn string) error {
+	p, s := b.backends()
+
+	if err := p.DeleteCAA(fqdn); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeCAA, fqdn, s.DeleteCAA(fqdn))
+	}
+
+	return nil
+}
+
+func (b *Backend) SetQueryTypes(fqdn string, types []string) error {
+	p, s := b.backends()
+
+	if err := p.SetQueryTypes(fqdn, types); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeQueryTypes, fqdn, s.SetQueryTypes(fqdn, types))
+	}
+
+	return nil
+}
+
+func (b *Backend) GetQueryTypes(fqdn string) ([]string, error) {
+	return b.primary().GetQueryTypes(fqdn)
+}
+
+func (b *Backend) DrainHost(fqdn, host string, until time.Time) (model.Domain, error) {
+	p, s := b.backends()
+
+	d, err := p.DrainHost(fqdn, host, until)
+	if err != nil || s == nil {
+		return d, err
+	}
+
+	_, err = s.DrainHost(fqdn, host, until)
+	b.check(s, typeDrain, fqdn, err)
+
+	return d, nil
+}
+
+func (b *Backend) Suspend(s *model.Suspension) error {
+	p, sb := b.backends()
+
+	if err := p.Suspend(s); err != nil {
+		return err
+	}
+
+	if sb != nil {
+		b.check(sb, typeSuspension, s.Fqdn, sb.Suspend(s))
+	}
+
+	return nil
+}
+
+func (b *Backend) Unsuspend(fqdn string) error {
+	p, s := b.backends()
+
+	if err := p.Unsuspend(fqdn); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeSuspension, fqdn, s.Unsuspend(fqdn))
+	}
+
+	return nil
+}
+
+func (b *Backend) ListSuspensions() ([]model.Suspension, error) {
+	return b.primary().ListSuspensions()
+}
+
+func (b *Backend) SetWebhook(w *model.Webhook) error {
+	p, s := b.backends()
+
+	if err := p.SetWebhook(w); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeWebhook, w.Fqdn, s.SetWebhook(w))
+	}
+
+	return nil
+}
+
+func (b *Backend) ListWebhooks(fqdn string) ([]model.Webhook, error) {
+	return b.primary().ListWebhooks(fqdn)
+}
+
+func (b *Backend) DeleteWebhook(fqdn, id string) error {
+	p, s := b.backends()
+
+	if err := p.DeleteWebhook(fqdn, id); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeWebhook, fqdn, s.DeleteWebhook(fqdn, id))
+	}
+
+	return nil
+}
+
+// Jobs are side effects of this instance, they are only queued in the primary backend. Jobs which
+// were queued before the primary changed are still claimed from the secondary, so they are not lost.
+func (b *Backend) EnqueueJob(j *model.Job) error {
+	return b.primary().EnqueueJob(j)
+}
+
+func (b *Backend) ClaimJobs(kind string, limit int, visibility time.Duration) ([]model.Job, error) {
+	p, s := b.backends()
+
+	js, err := p.ClaimJobs(kind, limit, visibility)
+	if err != nil || s == nil || len(js) >= limit {
+		return js, err
+	}
+
+	rest, err := s.ClaimJobs(kind, limit-len(js), visibility)
+	if err != nil {
+		logrus.Warn(errors.Wrapf(err, errClaimJobs, kind, s.GetName()))
+	}
+	return append(js, rest...), nil
+}
+
+func (b *Backend) DeleteJob(kind, id string) error {
+	p, s := b.backends()
+
+	if err := p.DeleteJob(kind, id); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeJob, id, s.DeleteJob(kind, id))
+	}
+
+	return nil
+}
+
+func (b *Backend) GetOperationalState(key string) (model.OperationalState, error) {
+	return b.primary().GetOperationalState(key)
+}
+
+// SetOperationalState writes the state to the primary with its version, the versions of the
+// backends differ so the state is mirrored over the current version of the secondary.
+func (b *Backend) SetOperationalState(st *model.OperationalState) error {
+	p, s := b.backends()
+
+	if err := p.SetOperationalState(st); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeState, st.Key, mirrorState(s, *st))
+	}
+
+	return nil
+}
+
+func (b *Backend) ListOperationalStates() ([]model.OperationalState, error) {
+	return b.primary().ListOperationalStates()
+}
+
+func (b *Backend) MigrateFrozen(opts *model.MigrateFrozen) error {
+	p, s := b.backends()
+
+	if err := p.MigrateFrozen(opts); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeFrozen, opts.Path, s.MigrateFrozen(opts))
+	}
+
+	return nil
+}
+
+func (b *Backend) MigrateToken(opts *model.MigrateToken) error {
+	p, s := b.backends()
+
+	if err := p.MigrateToken(opts); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeToken, opts.Path, s.MigrateToken(opts))
+	}
+
+	return nil
+}
+
+func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
+	p, s := b.backends()
+
+	if err := p.MigrateRecord(opts); err != nil {
+		return err
+	}
+
+	if s != nil {
+		b.check(s, typeA, opts.Fqdn, s.MigrateRecord(opts))
+	}
+
+	return nil
+}
+
+// Used to copy a domain written to the primary backend to the secondary backend,
+// the token is copied first so the domain keeps its token in both backends.
+func (b *Backend) mirror(p, s backend.Backend, d model.Domain) {
+	token, err := p.GetToken(d.Fqdn)
+	if err != nil {
+		b.check(s, typeToken, d.Fqdn, err)
+		return
+	}
+
+	expiration := d.Expiration
+	if expiration == nil {
+		e := time.Now().Add(time.Hour)
+		expiration = &e
+	}
+
+	err = s.MigrateToken(&model.MigrateToken{
+		Path:       TokenPath(s, d.Fqdn),
+		Token:      token,
+		Expiration: expiration,
+	})
+	if err != nil {
+		b.check(s, typeToken, d.Fqdn, err)
+		return
+	}
+
+	b.check(s, typeA, d.Fqdn, s.MigrateRecord(&model.MigrateRecord{
+		Fqdn:       d.Fqdn,
+		Hosts:      d.Hosts,
+		SubDomain:  d.SubDomain,
+		Token:      token,
+		Expiration: expiration,
+	}))
+}
+
+func (b *Backend) check(s backend.Backend, rType, fqdn string, err error) {
+	if err == nil {
+		return
+	}
+	mirrorErrors.WithLabelValues(s.GetName()).Inc()
+	logrus.Error(errors.Wrapf(err, errMirrorRecord, rType, fqdn, s.GetName()))
+}
+
+// TokenPath returns the token path which the migrate api of a backend expects
+// e.g. etcdv3: sample.lb.rancher.cloud => /token/sample.lb.rancher.cloud
+// e.g. route53: sample.lb.rancher.cloud => sample.lb.rancher.cloud
+func TokenPath(b backend.Backend, fqdn string) string {
+	if b.GetName() == etcdv3.Name {
+		return "/token/" + fqdn
+	}
+	return fqdn
+}
+
+// Used to write a state written to the primary backend to the secondary backend
+func mirrorState(s backend.Backend, st model.OperationalState) error {
+	current, err := s.GetOperationalState(st.Key)
+	if err != nil {
+		return err
+	}
+	st.Version = current.Version
+	return s.SetOperationalState(&st)
+}
@@ -0,0 +1,8 @@
+package dual
+
+const (
+	errClaimJobs         = "failed to claim %s jobs from %s backend"
+	errInvalidState      = "invalid double-write state: %s"
+	errInvalidTransition = "can not switch double-write state from %s to %s"
+	errMirrorRecord      = "failed to mirror %s record %s to %s backend"
+)
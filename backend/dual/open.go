@@ -0,0 +1,29 @@
+package dual
+
+import (
+	"os"
+
+	"github.com/rancher/rdns-server/backend"
+)
+
+// OpenBackend opens the new backend of the double-write mode, it is configured
+// with the same environments as its own command.
+func OpenBackend(name string) (backend.Backend, error) {
+	return backend.Open(&backend.Config{Name: name, DSN: os.Getenv("DSN")})
+}
+
+// Wrap returns the double-write backend of old and the backend of DOUBLE_WRITE_BACKEND,
+// old is returned as is when the double-write mode is disabled.
+func Wrap(old backend.Backend) (backend.Backend, error) {
+	name := os.Getenv("DOUBLE_WRITE_BACKEND")
+	if name == "" {
+		return old, nil
+	}
+
+	new, err := OpenBackend(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBackend(old, new, os.Getenv("DOUBLE_WRITE_STATE"))
+}
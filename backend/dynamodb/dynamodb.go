@@ -0,0 +1,72 @@
+package dynamodb
+
+import (
+	"os"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/route53"
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/database/dynamodb"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	Name = "dynamodb"
+)
+
+// Backend is the route53 backend with its tokens and records kept in a DynamoDB table, the table
+// expires the items of a domain by their time to live so no database has to be managed.
+type Backend struct {
+	*route53.Backend
+}
+
+func init() {
+	backend.Register(Name, func(cfg *backend.Config) (backend.Backend, error) {
+		d, err := NewDatabase()
+		if err != nil {
+			return nil, err
+		}
+		database.SetDatabase(d)
+		return NewBackend()
+	})
+}
+
+func NewBackend() (*Backend, error) {
+	b, err := route53.NewBackend()
+	if err != nil {
+		return &Backend{}, err
+	}
+	return &Backend{Backend: b}, nil
+}
+
+// NewDatabase opens the table of DYNAMODB_TABLE, the table is created when it does not exist.
+func NewDatabase() (*dynamodb.Database, error) {
+	lease, err := time.ParseDuration(os.Getenv("DATABASE_LEASE_TIME"))
+	if err != nil {
+		return nil, errors.Wrapf(err, errParseFlag, "database_lease_time")
+	}
+
+	frozen, err := time.ParseDuration(os.Getenv("FROZEN"))
+	if err != nil {
+		return nil, errors.Wrapf(err, errParseFlag, "frozen")
+	}
+
+	grace, err := time.ParseDuration(os.Getenv("DYNAMODB_TTL_GRACE"))
+	if err != nil {
+		return nil, errors.Wrapf(err, errParseFlag, "dynamodb_ttl_grace")
+	}
+
+	return dynamodb.NewDatabase(&dynamodb.Options{
+		Table:    os.Getenv("DYNAMODB_TABLE"),
+		Endpoint: os.Getenv("DYNAMODB_ENDPOINT"),
+		Lease:    lease,
+		Frozen:   frozen,
+		Grace:    grace,
+	})
+}
+
+func (b *Backend) GetName() string {
+	return Name
+}
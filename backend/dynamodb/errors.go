@@ -0,0 +1,5 @@
+package dynamodb
+
+const (
+	errParseFlag = "failed to parse flag %s"
+)
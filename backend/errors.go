@@ -0,0 +1,13 @@
+package backend
+
+const (
+	errCNAMEDepth      = "CNAME target %s exceeds the chain depth limit %d"
+	errCNAMEEmpty      = "CNAME target can not be empty"
+	errCNAMELoop       = "CNAME target %s makes a loop through %s"
+	errCNAMENotAllowed = "CNAME target %s is not a valid domain name"
+	errOpenBackend     = "failed to open %s backend"
+	errRemoveHost      = "failed to remove host %s of domain %s"
+	errRemoveLastHost  = "host %s is the last host of domain %s"
+	errReplaceHost     = "failed to replace host %s of domain %s"
+	errUnknownBackend  = "unknown backend %s, available backends: %s"
+)
@@ -0,0 +1,102 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const apiKeyPath = "/apikeyv3"
+
+// SetAPIKey keeps the api key without a lease, it is kept until it is revoked.
+func (b *Backend) SetAPIKey(k *model.APIKey) error {
+	logrus.Debugf("set api key: %s", k.ID)
+
+	if k.CreatedOn == nil {
+		t := time.Now()
+		k.CreatedOn = &t
+	}
+
+	value, err := json.Marshal(k)
+	if err != nil {
+		return errors.Wrapf(err, errSetRecord, typeAPIKey, k.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := b.C.Put(ctx, b.apiKeyKey(k.ID), string(value)); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeAPIKey, k.ID)
+	}
+
+	return nil
+}
+
+func (b *Backend) GetAPIKey(id string) (model.APIKey, error) {
+	var k model.APIKey
+	key := b.apiKeyKey(id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, key)
+	if err != nil {
+		return k, errors.Wrapf(err, errLookupRecords, typeAPIKey, key)
+	}
+	if len(resp.Kvs) == 0 {
+		return k, errors.Errorf(errEmptyRecord, typeAPIKey, key)
+	}
+
+	err = json.Unmarshal(resp.Kvs[0].Value, &k)
+	return k, errors.Wrapf(err, errLookupRecords, typeAPIKey, key)
+}
+
+func (b *Backend) ListAPIKeys() ([]model.APIKey, error) {
+	path := fmt.Sprintf("%s%s/", b.Prefix, apiKeyPath)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeAPIKey, path)
+	}
+
+	result := make([]model.APIKey, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var k model.APIKey
+		if err := json.Unmarshal(kv.Value, &k); err != nil {
+			logrus.Warnf("skip invalid %s record %s: %v", typeAPIKey, kv.Key, err)
+			continue
+		}
+		result = append(result, k)
+	}
+
+	return result, nil
+}
+
+func (b *Backend) DeleteAPIKey(id string) error {
+	logrus.Debugf("delete api key: %s", id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := b.C.Delete(ctx, b.apiKeyKey(id)); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeAPIKey, id)
+	}
+
+	return nil
+}
+
+// Used to get the key of an api key
+// e.g. k3x9a2 => /rdnsv3/apikeyv3/k3x9a2
+func (b *Backend) apiKeyKey(id string) string {
+	return fmt.Sprintf("%s%s/%s", b.Prefix, apiKeyPath, id)
+}
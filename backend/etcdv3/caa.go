@@ -0,0 +1,73 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetCAA replaces the CAA records of a domain, they are kept in one key with the lease of the
+// domain token so they are renewed and expire together with their domain.
+func (b *Backend) SetCAA(fqdn string, records []model.CAARecord) error {
+	logrus.Debugf("set %s records for domain: %s", typeCAA, fqdn)
+
+	leaseID, _, err := b.setToken(&model.DomainOptions{Fqdn: fqdn}, true)
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(records)
+	if err != nil {
+		return errors.Wrapf(err, errSetRecord, typeCAA, fqdn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := util.CAAKey(b.Prefix, fqdn)
+	if _, err := b.C.Put(ctx, key, string(value), clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
+		return errors.Wrapf(err, errSetRecordWithLease, typeCAA, key, leaseID)
+	}
+
+	return nil
+}
+
+func (b *Backend) GetCAA(fqdn string) ([]model.CAARecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := util.CAAKey(b.Prefix, fqdn)
+	resp, err := b.C.Get(ctx, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeCAA, key)
+	}
+	if resp.Count == 0 {
+		return nil, errors.Errorf(errEmptyRecord, typeCAA, key)
+	}
+
+	records := make([]model.CAARecord, 0)
+	if err := json.Unmarshal(resp.Kvs[0].Value, &records); err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeCAA, key)
+	}
+	return records, nil
+}
+
+func (b *Backend) DeleteCAA(fqdn string) error {
+	logrus.Debugf("delete %s records for domain: %s", typeCAA, fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := util.CAAKey(b.Prefix, fqdn)
+	if _, err := b.C.Delete(ctx, key); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeCAA, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package etcdv3
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/rancher/rdns-server/codec"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The CNAME target is kept in the wildcard record of the domain, which answers the domain
+// and its sub names, the dns plugin answers every record whose host is not an IP as a CNAME
+// e.g. sample.lb.rancher.cloud => /rdnsv3/cloud/rancher/lb/sample/* => {"host": "www.example.com"}
+
+func (b *Backend) SetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("set %s record for domain options: %s", typeCNAME, opts.String())
+
+	if err := checkCNAME(opts.CNAME); err != nil {
+		return d, err
+	}
+
+	var path, slug string
+	for i := 0; i < maxSlugHashTimes; i++ {
+		slug = generateSlug(b.Domain)
+
+		if b.checkSlugName(slug) {
+			logrus.Debugf(errExistSlug, slug)
+			continue
+		}
+
+		fqdn := fmt.Sprintf("%s.%s", slug, b.Domain)
+		path = b.getPath(fqdn)
+
+		if !b.checkPathExist(path) && b.checkHeritage(fqdn) == nil {
+			opts.Fqdn = fqdn
+			break
+		}
+	}
+
+	if opts.Fqdn == "" {
+		return d, errors.Errorf(errSetRecord, typeCNAME, opts.String())
+	}
+
+	leaseID, _, err := b.setToken(opts, false)
+	if err != nil {
+		return d, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	// the domain record is kept like the one of A records, so the slug is not generated again
+	lease := clientv3.WithLease(clientv3.LeaseID(leaseID))
+	ops := []clientv3.Op{
+		clientv3.OpPut(path, b.formatValue(""), lease),
+		clientv3.OpPut(b.cnameKey(opts.Fqdn), b.encode(&codec.Record{Host: opts.CNAME}), lease),
+	}
+	_, err = b.C.Txn(ctx).Then(append(ops, b.heritageOps(opts.Fqdn, leaseID)...)...).Commit()
+	if err != nil {
+		return d, errors.Wrapf(err, errSetRecordWithLease, typeCNAME, path, leaseID)
+	}
+
+	if err := b.lockSlugName(opts.Fqdn, slug, false); err != nil {
+		return d, err
+	}
+
+	d, err = b.GetCNAME(opts)
+	if err != nil {
+		return d, err
+	}
+
+	return d, b.setIndexes(&d, opts.Labels, opts.CreatorIP)
+}
+
+func (b *Backend) GetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("get %s record for domain options: %s", typeCNAME, opts.String())
+
+	key := b.cnameKey(opts.Fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, key)
+	if err != nil {
+		return d, errors.Wrapf(err, errEmptyRecord, typeCNAME, key)
+	}
+
+	if resp.Count <= 0 {
+		return d, errors.Errorf(errEmptyRecord, typeCNAME, key)
+	}
+
+	rec, err := codec.Decode(resp.Kvs[0].Value)
+	if err != nil {
+		return d, err
+	}
+
+	if rec.Host == "" || net.ParseIP(rec.Host) != nil {
+		return d, errors.Errorf(errEmptyRecord, typeCNAME, key)
+	}
+
+	lease, err := b.getLease(resp.Kvs[0].Lease)
+	if err != nil {
+		return d, err
+	}
+
+	d.Fqdn = opts.Fqdn
+	d.CNAME = rec.Host
+	d.Expiration = getExpiration(lease.TTL)
+
+	m, err := b.getMeta(opts.Fqdn)
+	if err != nil {
+		return d, err
+	}
+	d.Labels = m.Labels
+
+	if d.Annotations, err = b.getAnnotations(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	return d, nil
+}
+
+func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("update %s record for domain options: %s", typeCNAME, opts.String())
+
+	if err := checkCNAME(opts.CNAME); err != nil {
+		return d, err
+	}
+
+	if _, err := b.GetCNAME(opts); err != nil {
+		return d, err
+	}
+
+	if err := b.checkHeritage(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	leaseID, _, err := b.setToken(opts, true)
+	if err != nil {
+		return d, err
+	}
+
+	ttl, err := b.getTTL(opts.Fqdn)
+	if err != nil {
+		return d, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := b.cnameKey(opts.Fqdn)
+	if _, err := b.C.Put(ctx, key, b.encode(&codec.Record{Host: opts.CNAME, TTL: ttl}), clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
+		return d, errors.Wrapf(err, errSetRecordWithLease, typeCNAME, key, leaseID)
+	}
+
+	return b.GetCNAME(opts)
+}
+
+func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
+	logrus.Debugf("delete %s record for domain options: %s", typeCNAME, opts.String())
+
+	if _, err := b.GetCNAME(opts); err != nil {
+		return err
+	}
+
+	path := b.getPath(opts.Fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	_, err := b.C.Txn(ctx).Then(
+		clientv3.OpDelete(b.cnameKey(opts.Fqdn)),
+		clientv3.OpDelete(path),
+		clientv3.OpDelete(b.ttlKey(opts.Fqdn)),
+		clientv3.OpDelete(util.QueryTypesKey(b.Prefix, opts.Fqdn)),
+		clientv3.OpDelete(b.heritageKey(opts.Fqdn)),
+	).Commit()
+	if err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeCNAME, path)
+	}
+
+	return b.deleteIndexes(opts.Fqdn)
+}
+
+// Used to get the key of a CNAME record
+// e.g. sample.lb.rancher.cloud => /rdnsv3/cloud/rancher/lb/sample/*
+func (b *Backend) cnameKey(fqdn string) string {
+	return b.getPath(fqdn) + "/*"
+}
+
+// an IP target would be answered as an A record instead of a CNAME
+func checkCNAME(target string) error {
+	if target == "" || net.ParseIP(target) != nil {
+		return errors.Errorf(errNotValidCNAME, target)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package etcdv3
+
+import (
+	"context"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/codec"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// DrainHost rewrites the records of the host in the domain and its sub domains with the time it is
+// drained until, the dns plugin leaves the host out of its answers until then. A zero time undrains it.
+func (b *Backend) DrainHost(fqdn, host string, until time.Time) (d model.Domain, err error) {
+	logrus.Debugf("set %s of host %s of domain %s to %s", typeDrain, host, fqdn, until.Format(time.RFC3339))
+
+	if _, err := b.Get(&model.DomainOptions{Fqdn: fqdn}); err != nil {
+		return d, err
+	}
+
+	var drained int64
+	if !until.IsZero() {
+		drained = until.Unix()
+	}
+
+	path := b.getPath(fqdn)
+	ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path+"/", clientv3.WithPrefix())
+	if err != nil {
+		return d, errors.Wrapf(err, errLookupRecords, typeA, path)
+	}
+
+	found := false
+	for _, kv := range resp.Kvs {
+		rec, err := codec.Decode(kv.Value)
+		if err != nil || rec.Host != host {
+			continue
+		}
+		found = true
+		if rec.Drained == drained {
+			continue
+		}
+		rec.Drained = drained
+		if _, err := b.C.Put(ctx, string(kv.Key), b.encode(rec), clientv3.WithLease(clientv3.LeaseID(kv.Lease))); err != nil {
+			return d, errors.Wrapf(err, errSetRecordWithLease, typeA, kv.Key, kv.Lease)
+		}
+	}
+	if !found {
+		return d, errors.Wrapf(backend.ErrUnknownHost, errNoDrainHost, host, fqdn)
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
+
+// Used to keep the time a host is drained until, drains which are over are left out
+func setDrained(d *model.Domain, rec *codec.Record) {
+	if rec.Drained <= time.Now().Unix() {
+		return
+	}
+	if d.Drained == nil {
+		d.Drained = make(map[string]*time.Time)
+	}
+	until := time.Unix(rec.Drained, 0).UTC()
+	d.Drained[rec.Host] = &until
+}
@@ -5,6 +5,7 @@ const (
 	errEmptyRecord            = "failed to found %s record: %s"
 	errExistSlug              = "slug name %s can not be used, try another"
 	errGrantLease             = "failed to grant lease"
+	errExtendLease            = "failed to move the keys of %s to lease %d"
 	errSetRecordWithLease     = "failed to set %s record %s with lease %d"
 	errSyncRecords            = "failed to sync %s records: %s"
 	errSyncSubRecords         = "failed to sync sub %s records: %s"
@@ -13,5 +14,20 @@ const (
 	errLookupRecords          = "failed to lookup %s record: %s"
 	errMultiRecords           = "multiple %s records: %s"
 	errNoLookupResults        = "no lookup results for %s record: %s"
+	errNoDrainHost            = "host %s is not a host of domain %s"
 	errNotValidDomainName     = "not valid domain name: %s"
+	errNotValidCNAME          = "not valid CNAME target: %s"
+	errInvalidShards          = "invalid etcd shards: %s"
+	errInvalidRootDomains     = "invalid root domains: %s"
+	errInvalidExpiration      = "expiration %s is not in the future"
+	errReshardRecord          = "failed to move record %s to %s"
+	errMoveToken              = "failed to move token %s to %s"
+	errInvalidContinue        = "invalid continue token: %s"
+	errContinueExpired        = "continue token of revision %d is expired, please restart the list"
+	errSetIndexes             = "failed to set search indexes of %s"
+	errSetRecord              = "failed to set %s record: %s"
+	errRequestName            = "failed to request name %s"
+	errPing                   = "failed to get %s from etcd"
+	errReadEndpoints          = "failed to connect the etcd read endpoints %s"
+	errForeignOwner           = "the records of %s are owned by external-dns owner %s"
 )
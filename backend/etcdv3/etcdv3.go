@@ -2,12 +2,15 @@ package etcdv3
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/codec"
+	"github.com/rancher/rdns-server/config"
 	"github.com/rancher/rdns-server/model"
 	"github.com/rancher/rdns-server/util"
 
@@ -22,23 +25,55 @@ const (
 	Name             = "etcdv3"
 	typeA            = "A"
 	typeTXT          = "TXT"
+	typeCNAME        = "CNAME"
+	typeCAA          = "CAA"
+	typeQueryTypes   = "QUERYTYPES"
 	typeToken        = "TOKEN"
 	typeFrozen       = "FROZEN"
+	typeIndex        = "INDEX"
+	typeSuspension   = "SUSPENSION"
+	typeWebhook      = "WEBHOOK"
+	typeTTL          = "TTL"
+	typeDrain        = "DRAIN"
+	typeJob          = "JOB"
+	typeAPIKey       = "APIKEY"
+	typeState        = "STATE"
 	tokenPath        = "/tokenv3"
 	frozenPath       = "/frozenv3"
+	pingKey          = "/health"
 	maxSlugHashTimes = 100
 	tokenLength      = 32
-	slugLength       = 6
 	operationTimeout = 100 * time.Millisecond
+	rangeTimeout     = 5 * time.Second
+	rangePageSize    = 500
 )
 
+// host keys are told apart from sub domain keys by the underscore, so colons of IPv6 hosts are replaced too
+var keyReplacer = strings.NewReplacer(".", "_", ":", "_")
+
 type Backend struct {
 	Domain    string
 	Prefix    string
 	FrozenTTL time.Duration
 	LeaseTime time.Duration
+	Codec     codec.Codec
+	Shards    int
+	// ChallengeTTL is the lease time of ACME challenge TXT records, 0 means the domain lease
+	ChallengeTTL time.Duration
+	// HeritageOwner is the external-dns owner id of the ownership TXT records of the domains, empty writes none
+	HeritageOwner string
 
 	C *clientv3.Client
+	// R is the client of the read endpoints, nil when the reads go to the endpoints of C
+	R *clientv3.Client
+
+	readHealth *backend.ReadHealth
+}
+
+func init() {
+	backend.Register(Name, func(cfg *backend.Config) (backend.Backend, error) {
+		return NewBackend()
+	})
 }
 
 func NewBackend() (*Backend, error) {
@@ -58,14 +93,95 @@ func NewBackend() (*Backend, error) {
 	if err != nil {
 		return nil, err
 	}
+	vc, err := codec.Get(os.Getenv("ETCD_VALUE_ENCODING"))
+	if err != nil {
+		return nil, err
+	}
+	shards, err := strconv.Atoi(os.Getenv("ETCD_SHARDS"))
+	if err != nil {
+		return nil, errors.Wrapf(err, errInvalidShards, os.Getenv("ETCD_SHARDS"))
+	}
+	if shards < 0 {
+		return nil, errors.Errorf(errInvalidShards, os.Getenv("ETCD_SHARDS"))
+	}
+	challenge, err := time.ParseDuration(os.Getenv("ACME_TXT_TTL"))
+	if err != nil {
+		return nil, err
+	}
+
+	roots := util.RootDomains(os.Getenv("DOMAIN"))
+	if len(roots) == 0 {
+		return nil, errors.Errorf(errInvalidRootDomains, os.Getenv("DOMAIN"))
+	}
 
-	return &Backend{
-		Domain:    os.Getenv("DOMAIN"),
+	b := &Backend{
+		Domain:    roots[0],
 		Prefix:    os.Getenv("ETCD_PREFIX_PATH"),
 		FrozenTTL: frozen,
 		LeaseTime: leaseTime,
+		Codec:     vc,
+		Shards:    shards,
 		C:         c,
-	}, nil
+
+		ChallengeTTL:  challenge,
+		HeritageOwner: os.Getenv("ETCD_HERITAGE_OWNER"),
+	}
+
+	if endpoints := os.Getenv("ETCD_READ_ENDPOINTS"); endpoints != "" {
+		// no dial timeout, so read endpoints which are down do not stop the start and
+		// are connected once they are up
+		r, err := clientv3.New(clientv3.Config{
+			Endpoints: strings.Split(endpoints, ","),
+		})
+		if err != nil {
+			c.Close()
+			return nil, errors.Wrapf(err, errReadEndpoints, endpoints)
+		}
+		// the read endpoints answer from their own copy without asking the leader
+		r.KV = serializableKV{r.KV}
+		b.R = r
+		b.readHealth = backend.NewReadHealth(Name, b.pingReader)
+	}
+
+	return b, nil
+}
+
+// Reader returns a backend of the same root domain which reads from the read endpoints, the
+// reads go to the write endpoints while the read endpoints are not set or not healthy.
+func (b *Backend) Reader() backend.Backend {
+	if b.R == nil || !b.readHealth.Healthy() {
+		return b
+	}
+	r := *b
+	r.C = b.R
+	return &r
+}
+
+func (b *Backend) pingReader() error {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := b.R.Get(ctx, b.Prefix+pingKey); err != nil {
+		return errors.Wrapf(err, errPing, b.Prefix+pingKey)
+	}
+	return nil
+}
+
+// serializableKV serves every get from the member it is sent to, which may lag behind the leader
+type serializableKV struct {
+	clientv3.KV
+}
+
+func (kv serializableKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	return kv.KV.Get(ctx, key, append(opts, clientv3.WithSerializable())...)
+}
+
+// ForRoot returns a backend of another root domain which shares the client and the prefix,
+// the records of every root domain are kept below its own reversed path.
+func (b *Backend) ForRoot(root string) *Backend {
+	r := *b
+	r.Domain = root
+	return &r
 }
 
 func (b *Backend) GetName() string {
@@ -76,10 +192,22 @@ func (b *Backend) GetZone() string {
 	return b.Domain
 }
 
+// Ping gets a single key which needs not exist, like the health check of etcdctl, so the
+// cluster only answers once it has a leader.
+func (b *Backend) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := b.C.Get(ctx, b.Prefix+pingKey); err != nil {
+		return errors.Wrapf(err, errPing, b.Prefix+pingKey)
+	}
+	return nil
+}
+
 func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 	logrus.Debugf("get %s record for domain options: %s", typeA, opts.String())
 
-	path := getPath(b.Prefix, opts.Fqdn)
+	path := b.getPath(opts.Fqdn)
 
 	kvs, err := b.lookupKeys(path)
 	if err != nil {
@@ -97,26 +225,22 @@ func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 		k := string(v.Key)
 		prefix := findSubPrefix(k, path)
 
-		m, err := unmarshalToMap(v.Value)
+		rec, err := codec.Decode(v.Value)
 		if err != nil {
 			return d, err
 		}
 
-		isText := false
-		if _, ok := m["text"]; ok {
-			isText = true
-		}
-
-		if prefix != "" && !strings.Contains(prefix, "_") && !isText {
+		if prefix != "" && !strings.Contains(prefix, "_") && !rec.TXT {
 			subs[prefix] = make([]string, 0)
 			continue
 		}
 
-		if m["host"] == "" {
+		if rec.Host == "" {
 			continue
 		}
 
-		hosts = append(hosts, m["host"])
+		hosts = append(hosts, rec.Host)
+		setDrained(&d, rec)
 	}
 
 	lease, err := b.getLease(kvs[0].Lease)
@@ -126,7 +250,7 @@ func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 
 	for k := range subs {
 		n := fmt.Sprintf("%s.%s", k, opts.Fqdn)
-		p := getPath(b.Prefix, n)
+		p := b.getPath(n)
 
 		kvs, err := b.lookupKeys(p)
 		if err != nil {
@@ -135,21 +259,39 @@ func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 
 		ss := make([]string, 0)
 		for _, v := range kvs {
-			m, err := unmarshalToMap(v.Value)
+			rec, err := codec.Decode(v.Value)
 			if err != nil {
 				return d, err
 			}
-			ss = append(ss, m["host"])
+			ss = append(ss, rec.Host)
+			setDrained(&d, rec)
 		}
 
 		subs[k] = ss
 	}
 
+	ttl, err := b.getTTL(opts.Fqdn)
+	if err != nil {
+		return d, err
+	}
+
 	d.Fqdn = opts.Fqdn
 	d.Hosts = hosts
 	d.SubDomain = subs
+	d.TTL = ttl
 	d.Expiration = getExpiration(lease.TTL)
 
+	m, err := b.getMeta(opts.Fqdn)
+	if err != nil {
+		return d, err
+	}
+	d.Deadline = m.Deadline
+	d.Labels = m.Labels
+
+	if d.Annotations, err = b.getAnnotations(opts.Fqdn); err != nil {
+		return d, err
+	}
+
 	return d, nil
 }
 
@@ -157,8 +299,20 @@ func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
 	logrus.Debugf("set %s record for domain options: %s", typeA, opts.String())
 
 	var path, slug string
-	for i := 0; i < maxSlugHashTimes; i++ {
-		slug = generateSlug()
+	if opts.Name != "" {
+		slug = opts.Name
+		opts.Fqdn = fmt.Sprintf("%s.%s", slug, b.Domain)
+		path = b.getPath(opts.Fqdn)
+
+		if err := b.checkHeritage(opts.Fqdn); err != nil {
+			return d, err
+		}
+		if err := b.reserveSlugName(slug, path); err != nil {
+			return d, err
+		}
+	}
+	for i := 0; i < maxSlugHashTimes && opts.Fqdn == ""; i++ {
+		slug = generateSlug(b.Domain)
 
 		if b.checkSlugName(slug) {
 			logrus.Debugf(errExistSlug, slug)
@@ -166,9 +320,9 @@ func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
 		}
 
 		fqdn := fmt.Sprintf("%s.%s", slug, b.Domain)
-		path = getPath(b.Prefix, fqdn)
+		path = b.getPath(fqdn)
 
-		if !b.checkPathExist(path) {
+		if !b.checkPathExist(path) && b.checkHeritage(fqdn) == nil {
 			opts.Fqdn = fqdn
 			break
 		}
@@ -183,13 +337,22 @@ func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
 		return d, err
 	}
 
-	return b.Get(opts)
+	d, err = b.Get(opts)
+	if err != nil {
+		return d, err
+	}
+
+	return d, b.setIndexes(&d, opts.Labels, opts.CreatorIP)
 }
 
 func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error) {
 	logrus.Debugf("update %s record for domain options: %s", typeA, opts.String())
 
-	path := getPath(b.Prefix, opts.Fqdn)
+	if err := b.checkHeritage(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	path := b.getPath(opts.Fqdn)
 
 	kvs, err := b.lookupKeys(path)
 	if err != nil {
@@ -209,6 +372,10 @@ func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error)
 		return d, err
 	}
 
+	if err := b.setIndexes(&d, opts.Labels, ""); err != nil {
+		return d, err
+	}
+
 	return d, b.lockSlugName(opts.Fqdn, findSlugWithZone(opts.Fqdn, b.Domain), true)
 }
 
@@ -220,140 +387,30 @@ func (b *Backend) Delete(opts *model.DomainOptions) error {
 		return err
 	}
 
-	path := getPath(b.Prefix, opts.Fqdn)
+	path := b.getPath(opts.Fqdn)
 
-	kvs, err := b.lookupKeys(path)
-	if err != nil {
-		return err
+	// records and their host index keys are deleted in one transaction
+	ops := make([]clientv3.Op, 0)
+	for _, h := range d.Hosts {
+		ops = append(ops, clientv3.OpDelete(fmt.Sprintf("%s/%s", path, formatKey(h))), clientv3.OpDelete(b.indexKey(indexHost, h, opts.Fqdn)))
 	}
-
-	for _, v := range kvs {
-		k := string(v.Key)
-		prefix := findSubPrefix(k, path)
-		path := getPath(b.Prefix, fmt.Sprintf("%s.%s", prefix, opts.Fqdn))
-
-		if prefix != "" && strings.Contains(prefix, "_") {
-			ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
-			_, err := b.C.Delete(ctx, path)
-			cancel()
-			if err != nil {
-				return errors.Wrapf(err, errDeleteRecord, typeA, path)
-			}
+	ops = append(ops, clientv3.OpDelete(path), clientv3.OpDelete(b.ttlKey(opts.Fqdn)), clientv3.OpDelete(util.CAAKey(b.Prefix, opts.Fqdn)), clientv3.OpDelete(util.QueryTypesKey(b.Prefix, opts.Fqdn)), clientv3.OpDelete(b.heritageKey(opts.Fqdn)))
+	for prefix, hosts := range d.SubDomain {
+		fqdn := fmt.Sprintf("%s.%s", prefix, opts.Fqdn)
+		ops = append(ops, clientv3.OpDelete(b.getPath(fqdn), clientv3.WithPrefix()))
+		for _, h := range hosts {
+			ops = append(ops, clientv3.OpDelete(b.indexKey(indexHost, h, fqdn)))
 		}
-
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
 	defer cancel()
 
-	if _, err := b.C.Delete(ctx, path); err != nil {
+	if _, err := b.C.Txn(ctx).Then(ops...).Commit(); err != nil {
 		return errors.Wrapf(err, errDeleteRecord, typeA, path)
 	}
-	for prefix := range d.SubDomain {
-		path := getPath(b.Prefix, fmt.Sprintf("%s.%s", prefix, opts.Fqdn))
-
-		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
-		_, err := b.C.Delete(ctx, path, clientv3.WithPrefix())
-		cancel()
-		if err != nil {
-			return errors.Wrapf(err, errDeleteRecord, typeA, path)
-		}
-	}
-
-	return nil
-}
-
-func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("renew %s record for domain options: %s", typeA, opts.String())
-
-	path := getPath(b.Prefix, opts.Fqdn)
-
-	leaseID, leaseTTL, err := b.setToken(opts, true)
-	if err != nil {
-		return d, err
-	}
-
-	_, leaseTTL, err = b.keepaliveOnce(leaseID)
-	if err != nil {
-		return d, err
-	}
-
-	kvs, err := b.lookupKeys(path)
-	if err != nil {
-		return d, err
-	}
-
-	if len(kvs) <= 0 {
-		return d, errors.Errorf(errNoLookupResults, typeA, path)
-	}
-
-	subs := make(map[string][]string, 0)
-	hosts := make([]string, 0)
-
-	for _, v := range kvs {
-		k := string(v.Key)
-		prefix := findSubPrefix(k, path)
-
-		m, err := unmarshalToMap(v.Value)
-		if err != nil {
-			return d, err
-		}
-
-		isText := false
-		if _, ok := m["text"]; ok {
-			isText = true
-		}
-
-		if prefix != "" && !strings.Contains(prefix, "_") && !isText {
-			subs[prefix] = make([]string, 0)
-			continue
-		}
-
-		hosts = append(hosts, m["host"])
-	}
-
-	for k := range subs {
-		n := fmt.Sprintf("%s.%s", k, opts.Fqdn)
-		p := getPath(b.Prefix, n)
-
-		kvs, err := b.lookupKeys(p)
-		if err != nil {
-			return d, err
-		}
-
-		ss := make([]string, 0)
-		for _, v := range kvs {
-			m, err := unmarshalToMap(v.Value)
-			if err != nil {
-				return d, err
-			}
-			ss = append(ss, m["host"])
-		}
-
-		subs[k] = ss
-	}
-
-	d.Fqdn = opts.Fqdn
-	d.Hosts = hosts
-	d.SubDomain = subs
-	d.Expiration = getExpiration(leaseTTL)
-
-	return d, nil
-}
-
-func (b *Backend) SetCNAME(opts *model.DomainOptions) (model.Domain, error) {
-	return model.Domain{}, nil
-}
-
-func (b *Backend) GetCNAME(opts *model.DomainOptions) (model.Domain, error) {
-	return model.Domain{}, nil
-}
 
-func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (model.Domain, error) {
-	return model.Domain{}, nil
-}
-
-func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
-	return nil
+	return b.deleteIndexes(opts.Fqdn)
 }
 
 func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error) {
@@ -363,7 +420,11 @@ func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error)
 		return d, errors.Errorf(errNotValidDomainName, opts.Fqdn)
 	}
 
-	path := getPath(b.Prefix, opts.Fqdn)
+	if err := b.checkHeritage(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	path := b.textPath(opts)
 	slug := findSlugWithZone(opts.Fqdn, b.Domain)
 	base := fmt.Sprintf("%s.%s", slug, b.Domain)
 
@@ -372,10 +433,14 @@ func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error)
 		return d, err
 	}
 
+	if leaseID, err = b.textLease(opts.Fqdn, leaseID); err != nil {
+		return d, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
 	defer cancel()
 
-	if _, err := b.C.Put(ctx, path, formatTextValue(opts.Text), clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
+	if _, err := b.C.Put(ctx, path, b.formatTextValue(opts.Text), clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
 		return d, errors.Wrapf(err, errSetRecordWithLease, typeTXT, path, leaseID)
 	}
 
@@ -389,36 +454,65 @@ func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error)
 		return d, errors.Errorf(errNotValidDomainName, opts.Fqdn)
 	}
 
-	path := getPath(b.Prefix, opts.Fqdn)
+	path := b.textPath(opts)
 
 	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
 	defer cancel()
 
-	resp, err := b.C.Get(ctx, path)
+	ops := []clientv3.Op{clientv3.OpGet(path)}
+	if opts.Order == "" {
+		// the values of the orders and the ones kept by their content are the children of the record
+		ops = append(ops, clientv3.OpGet(path+"/", clientv3.WithPrefix()))
+	}
+	resp, err := b.C.Txn(ctx).Then(ops...).Commit()
 	if err != nil {
 		return d, errors.Wrapf(err, errEmptyRecord, typeTXT, path)
 	}
 
-	if resp.Count <= 0 {
-		return d, errors.Errorf(errEmptyRecord, typeTXT, path)
-	}
-
-	lease, err := b.getLease(resp.Kvs[0].Lease)
-	if err != nil {
-		return d, err
-	}
+	texts := make([]string, 0)
+	var ttl int64 = -1
+	leases := make(map[int64]bool)
+	for _, r := range resp.Responses {
+		for _, kv := range r.GetResponseRange().Kvs {
+			// the records of the names below the record are not its values, the record itself is
+			if string(kv.Key) != path && strings.Contains(strings.TrimPrefix(string(kv.Key), path+"/"), "/") {
+				continue
+			}
+			rec, err := codec.Decode(kv.Value)
+			if err != nil {
+				return d, err
+			}
+			if !rec.TXT {
+				continue
+			}
+			texts = append(texts, rec.Text)
 
-	m, err := unmarshalToMap(resp.Kvs[0].Value)
-	if err != nil {
-		return d, err
+			// the record answers until the value which lives longest expires
+			if leases[kv.Lease] {
+				continue
+			}
+			leases[kv.Lease] = true
+			lease, err := b.getLease(kv.Lease)
+			if err != nil {
+				return d, err
+			}
+			if lease.TTL > ttl {
+				ttl = lease.TTL
+			}
+		}
 	}
 
-	if _, ok := m["text"]; ok {
-		d.Text = m["text"]
+	if len(texts) == 0 {
+		return d, errors.Errorf(errEmptyRecord, typeTXT, path)
 	}
 
 	d.Fqdn = opts.Fqdn
-	d.Expiration = getExpiration(lease.TTL)
+	d.Text = texts[0]
+	if opts.Order == "" {
+		d.Texts = texts
+	}
+	d.Order = opts.Order
+	d.Expiration = getExpiration(ttl)
 
 	return d, nil
 }
@@ -430,11 +524,16 @@ func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err err
 		return d, errors.Errorf(errNotValidDomainName, opts.Fqdn)
 	}
 
-	if _, err := b.GetText(opts); err != nil {
+	// a value which replaces the others needs any value of the fqdn, not one of its order
+	if _, err := b.GetText(opts.ReplacedText()); err != nil {
+		return d, err
+	}
+
+	if err := b.checkHeritage(opts.Fqdn); err != nil {
 		return d, err
 	}
 
-	path := getPath(b.Prefix, opts.Fqdn)
+	path := b.textPath(opts)
 	slug := findSlugWithZone(opts.Fqdn, b.Domain)
 	base := fmt.Sprintf("%s.%s", slug, b.Domain)
 
@@ -443,10 +542,27 @@ func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err err
 		return d, err
 	}
 
+	if leaseID, err = b.textLease(opts.Fqdn, leaseID); err != nil {
+		return d, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
 	defer cancel()
 
-	if _, err := b.C.Put(ctx, path, formatTextValue(opts.Text), clientv3.WithLease(clientv3.LeaseID(leaseID)), clientv3.WithPrevKV()); err != nil {
+	ops := []clientv3.Op{clientv3.OpPut(path, b.formatTextValue(opts.Text), clientv3.WithLease(clientv3.LeaseID(leaseID)))}
+	if opts.Order == "" || opts.Replace {
+		// the value replaces all values of the record, the value of the record itself too
+		record := b.textPath(opts.ReplacedText())
+		deletes, err := b.textValuesDelete(record, path)
+		if err != nil {
+			return d, err
+		}
+		if record != path {
+			deletes = append(deletes, clientv3.OpDelete(record))
+		}
+		ops = append(ops, deletes...)
+	}
+	if _, err := b.C.Txn(ctx).Then(ops...).Commit(); err != nil {
 		return d, errors.Wrapf(err, errSetRecordWithLease, typeTXT, path, leaseID)
 	}
 
@@ -456,25 +572,53 @@ func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err err
 func (b *Backend) DeleteText(opts *model.DomainOptions) error {
 	logrus.Debugf("delete %s record for domain options: %s", typeTXT, opts.String())
 
-	path := getPath(b.Prefix, opts.Fqdn)
+	path := b.textPath(opts)
 
 	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
 	defer cancel()
 
-	if _, err := b.C.Delete(ctx, path); err != nil {
+	ops := []clientv3.Op{clientv3.OpDelete(path)}
+	if opts.Order == "" {
+		deletes, err := b.textValuesDelete(path, "")
+		if err != nil {
+			return err
+		}
+		ops = append(ops, deletes...)
+	}
+	if _, err := b.C.Txn(ctx).Then(ops...).Commit(); err != nil {
 		return errors.Wrapf(err, errDeleteRecord, typeTXT, path)
 	}
 
 	return nil
 }
 
+// Used to delete the values of the orders of a TXT record and the ones kept by their content except
+// the key keep, the records of the names below it are kept
+// e.g. /rdnsv3/cloud/rancher/lb/sample/_acme-challenge => [.../_acme-challenge/4f1b, .../_acme-challenge/v-ba7816bf8f01cfea]
+func (b *Backend) textValuesDelete(path, keep string) ([]clientv3.Op, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path+"/", clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeTXT, path)
+	}
+	ops := make([]clientv3.Op, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if string(kv.Key) != keep && !strings.Contains(strings.TrimPrefix(string(kv.Key), path+"/"), "/") {
+			ops = append(ops, clientv3.OpDelete(string(kv.Key)))
+		}
+	}
+	return ops, nil
+}
+
 func (b *Backend) GetToken(fqdn string) (string, error) {
 	logrus.Debugf("get %s record for fqdn: %s", typeToken, fqdn)
 
 	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
 	defer cancel()
 
-	path := getTokenPath(fqdn)
+	path := b.getTokenPath(fqdn)
 
 	resp, err := b.C.Get(ctx, path)
 	if err != nil {
@@ -498,7 +642,7 @@ func (b *Backend) GetTokenCount() (int64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
 	defer cancel()
 
-	resp, err := b.C.Get(ctx, tokenPath, clientv3.WithPrefix())
+	resp, err := b.C.Get(ctx, b.tokenRoot()+"/", clientv3.WithPrefix())
 	if err != nil {
 		if err == rpctypes.ErrKeyNotFound {
 
@@ -529,7 +673,7 @@ func (b *Backend) MigrateFrozen(opts *model.MigrateFrozen) error {
 }
 
 func (b *Backend) MigrateToken(opts *model.MigrateToken) error {
-	path := getTokenPath(strings.Split(opts.Path, "/")[2])
+	path := b.getTokenPath(strings.Split(opts.Path, "/")[2])
 
 	id, _, err := b.grantLease(opts.Expiration.Unix() - time.Now().Unix())
 	if err != nil {
@@ -563,7 +707,7 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 			SubDomain: opts.SubDomain,
 		}
 
-		path := getPath(b.Prefix, dopts.Fqdn)
+		path := b.getPath(dopts.Fqdn)
 
 		leaseID, _, err := b.setToken(dopts, true)
 		if err != nil {
@@ -574,7 +718,7 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
 		defer cancel()
 
-		_, err = b.C.Put(ctx, path, formatValue(""), clientv3.WithLease(clientv3.LeaseID(leaseID)))
+		_, err = b.C.Put(ctx, path, b.formatValue(""), clientv3.WithLease(clientv3.LeaseID(leaseID)))
 		if err != nil {
 			return err
 		}
@@ -591,27 +735,22 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 			k := string(v.Key)
 			prefix := findSubPrefix(k, path)
 
-			m, err := unmarshalToMap(v.Value)
+			rec, err := codec.Decode(v.Value)
 			if err != nil {
 				return err
 			}
 
-			isText := false
-			if _, ok := m["text"]; ok {
-				isText = true
-			}
-
-			if prefix != "" && !strings.Contains(prefix, "_") && !isText {
+			if prefix != "" && !strings.Contains(prefix, "_") && !rec.TXT {
 				subs[prefix] = make([]string, 0)
 				continue
 			}
 
-			hosts = append(hosts, m["host"])
+			hosts = append(hosts, rec.Host)
 		}
 
 		for k := range subs {
 			n := fmt.Sprintf("%s.%s", k, dopts.Fqdn)
-			p := getPath(b.Prefix, n)
+			p := b.getPath(n)
 
 			kvs, err := b.lookupKeys(p)
 			if err != nil {
@@ -620,28 +759,94 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 
 			ss := make([]string, 0)
 			for _, v := range kvs {
-				m, err := unmarshalToMap(v.Value)
+				rec, err := codec.Decode(v.Value)
 				if err != nil {
 					return err
 				}
-				ss = append(ss, m["host"])
+				ss = append(ss, rec.Host)
 			}
 
 			subs[k] = ss
 		}
 
-		if err := b.syncRecords(dopts.Hosts, hosts, path, clientv3.LeaseID(leaseID)); err != nil {
+		if err := b.syncRecords(dopts.Hosts, hosts, path, dopts.Fqdn, clientv3.LeaseID(leaseID)); err != nil {
 			return errors.Wrapf(err, errSyncRecords, typeA, path)
 		}
 
 		if err := b.setSubRecords(dopts, subs, leaseID); err != nil {
 			return errors.Wrapf(err, errSetSubRecordsWithLease, typeA, dopts.Fqdn, leaseID)
 		}
+
+		d, err := b.Get(dopts)
+		if err != nil {
+			return err
+		}
+		return b.setIndexes(&d, nil, "")
 	}
 
 	return nil
 }
 
+// Reshard moves all records of the zone to the key layout of the configured shard count,
+// keys keep their values and leases. It returns the number of moved keys.
+func (b *Backend) Reshard() (int, error) {
+	root := getPath(b.Prefix, b.Domain) + "/"
+	end := clientv3.GetPrefixRangeEnd(root)
+	key := root
+	moved := 0
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+		resp, err := b.C.Get(ctx, key, clientv3.WithRange(end), clientv3.WithLimit(rangePageSize))
+		cancel()
+		if err != nil {
+			return moved, errors.Wrapf(err, errLookupRecords, typeA, root)
+		}
+
+		for _, v := range resp.Kvs {
+			old := string(v.Key)
+			path := b.reshardKey(old, root)
+			if path == old {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+			_, err := b.C.Txn(ctx).
+				If(clientv3.Compare(clientv3.ModRevision(old), "=", v.ModRevision)).
+				Then(clientv3.OpPut(path, string(v.Value), clientv3.WithLease(clientv3.LeaseID(v.Lease))), clientv3.OpDelete(old)).
+				Commit()
+			cancel()
+			if err != nil {
+				return moved, errors.Wrapf(err, errReshardRecord, old, path)
+			}
+			moved++
+		}
+
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		key = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+
+	return moved, nil
+}
+
+// Used to get the key of the configured shard layout, keys may come from any layout
+// e.g. /rdnsv3/cloud/rancher/lb/qrn7oq/x1 => /rdnsv3/cloud/rancher/lb/_21/qrn7oq/x1
+func (b *Backend) reshardKey(key, root string) string {
+	ss := strings.Split(strings.TrimPrefix(key, root), "/")
+	if util.IsShardLabel(ss[0]) {
+		ss = ss[1:]
+	}
+	if len(ss) == 0 || ss[0] == "" {
+		return key
+	}
+	if label := util.ShardLabel(ss[0], b.Shards); label != "" {
+		ss = append([]string{label}, ss...)
+	}
+	return root + strings.Join(ss, "/")
+}
+
 func (b *Backend) setRecord(path string, opts *model.DomainOptions, exist bool) (d model.Domain, err error) {
 	leaseID, leaseTTL, err := b.setToken(opts, exist)
 	if err != nil {
@@ -653,7 +858,7 @@ func (b *Backend) setRecord(path string, opts *model.DomainOptions, exist bool)
 		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
 		defer cancel()
 
-		_, err := b.C.Put(ctx, path, formatValue(""), clientv3.WithLease(clientv3.LeaseID(leaseID)))
+		_, err := b.C.Put(ctx, path, b.formatValue(""), clientv3.WithLease(clientv3.LeaseID(leaseID)))
 		if err != nil {
 			return d, err
 		}
@@ -671,27 +876,22 @@ func (b *Backend) setRecord(path string, opts *model.DomainOptions, exist bool)
 		k := string(v.Key)
 		prefix := findSubPrefix(k, path)
 
-		m, err := unmarshalToMap(v.Value)
+		rec, err := codec.Decode(v.Value)
 		if err != nil {
 			return d, err
 		}
 
-		isText := false
-		if _, ok := m["text"]; ok {
-			isText = true
-		}
-
-		if prefix != "" && !strings.Contains(prefix, "_") && !isText {
+		if prefix != "" && !strings.Contains(prefix, "_") && !rec.TXT {
 			subs[prefix] = make([]string, 0)
 			continue
 		}
 
-		hosts = append(hosts, m["host"])
+		hosts = append(hosts, rec.Host)
 	}
 
 	for k := range subs {
 		n := fmt.Sprintf("%s.%s", k, opts.Fqdn)
-		p := getPath(b.Prefix, n)
+		p := b.getPath(n)
 
 		kvs, err := b.lookupKeys(p)
 		if err != nil {
@@ -700,17 +900,17 @@ func (b *Backend) setRecord(path string, opts *model.DomainOptions, exist bool)
 
 		ss := make([]string, 0)
 		for _, v := range kvs {
-			m, err := unmarshalToMap(v.Value)
+			rec, err := codec.Decode(v.Value)
 			if err != nil {
 				return d, err
 			}
-			ss = append(ss, m["host"])
+			ss = append(ss, rec.Host)
 		}
 
 		subs[k] = ss
 	}
 
-	if err := b.syncRecords(opts.Hosts, hosts, path, clientv3.LeaseID(leaseID)); err != nil {
+	if err := b.syncRecords(opts.Hosts, hosts, path, opts.Fqdn, clientv3.LeaseID(leaseID)); err != nil {
 		return d, errors.Wrapf(err, errSyncRecords, typeA, path)
 	}
 
@@ -718,6 +918,10 @@ func (b *Backend) setRecord(path string, opts *model.DomainOptions, exist bool)
 		return d, errors.Wrapf(err, errSetSubRecordsWithLease, typeA, opts.Fqdn, leaseID)
 	}
 
+	if err := b.putHeritage(opts.Fqdn, leaseID); err != nil {
+		return d, err
+	}
+
 	d.Fqdn = opts.Fqdn
 	d.Hosts = opts.Hosts
 	d.SubDomain = opts.SubDomain
@@ -727,11 +931,15 @@ func (b *Backend) setRecord(path string, opts *model.DomainOptions, exist bool)
 }
 
 func (b *Backend) setSubRecords(opts *model.DomainOptions, origins map[string][]string, leaseID int64) error {
-	for prefix := range origins {
+	for prefix, values := range origins {
 		if _, ok := opts.SubDomain[prefix]; !ok {
-			path := getPath(b.Prefix, fmt.Sprintf("%s.%s", prefix, opts.Fqdn))
+			fqdn := fmt.Sprintf("%s.%s", prefix, opts.Fqdn)
+			ops := []clientv3.Op{clientv3.OpDelete(b.getPath(fqdn), clientv3.WithPrefix())}
+			for _, v := range values {
+				ops = append(ops, clientv3.OpDelete(b.indexKey(indexHost, v, fqdn)))
+			}
 			ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
-			_, err := b.C.Delete(ctx, path, clientv3.WithPrefix())
+			_, err := b.C.Txn(ctx).Then(ops...).Commit()
 			cancel()
 			if err != nil {
 				return err
@@ -740,7 +948,8 @@ func (b *Backend) setSubRecords(opts *model.DomainOptions, origins map[string][]
 	}
 
 	for prefix, values := range opts.SubDomain {
-		path := getPath(b.Prefix, fmt.Sprintf("%s.%s", prefix, opts.Fqdn))
+		fqdn := fmt.Sprintf("%s.%s", prefix, opts.Fqdn)
+		path := b.getPath(fqdn)
 
 		kvs, err := b.lookupKeys(path)
 		if err != nil {
@@ -749,14 +958,14 @@ func (b *Backend) setSubRecords(opts *model.DomainOptions, origins map[string][]
 
 		hosts := make([]string, 0)
 		for _, v := range kvs {
-			m, err := unmarshalToMap(v.Value)
+			rec, err := codec.Decode(v.Value)
 			if err != nil {
 				return err
 			}
-			hosts = append(hosts, m["host"])
+			hosts = append(hosts, rec.Host)
 		}
 
-		if err := b.syncRecords(values, hosts, path, clientv3.LeaseID(leaseID)); err != nil {
+		if err := b.syncRecords(values, hosts, path, fqdn, clientv3.LeaseID(leaseID)); err != nil {
 			return errors.Wrapf(err, errSyncSubRecords, typeA, path)
 		}
 	}
@@ -764,15 +973,24 @@ func (b *Backend) setSubRecords(opts *model.DomainOptions, origins map[string][]
 	return nil
 }
 
-func (b *Backend) syncRecords(new, old []string, path string, leaseID clientv3.LeaseID) error {
+// Used to sync the host records of a domain, every host record is written in one
+// transaction with its host index key. Index keys of unchanged hosts are rewritten so
+// records which are written before the index exists get indexed on the next update.
+func (b *Backend) syncRecords(new, old []string, path, fqdn string, leaseID clientv3.LeaseID) error {
 	left := sliceToMap(new)
 	right := sliceToMap(old)
+	base := fmt.Sprintf("%s.%s", findSlugWithZone(fqdn, b.Domain), b.Domain)
+
+	ttl, err := b.getTTL(base)
+	if err != nil {
+		return err
+	}
 
 	for r := range right {
 		if _, ok := left[r]; !ok {
 			key := fmt.Sprintf("%s/%s", path, formatKey(r))
 			ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
-			_, err := b.C.Delete(ctx, key)
+			_, err := b.C.Txn(ctx).Then(clientv3.OpDelete(key), clientv3.OpDelete(b.indexKey(indexHost, r, fqdn))).Commit()
 			cancel()
 			if err != nil {
 				return err
@@ -781,14 +999,19 @@ func (b *Backend) syncRecords(new, old []string, path string, leaseID clientv3.L
 	}
 
 	for l := range left {
+		if l == "" {
+			continue
+		}
+		ops := []clientv3.Op{clientv3.OpPut(b.indexKey(indexHost, l, fqdn), base, clientv3.WithLease(leaseID))}
 		if _, ok := right[l]; !ok {
 			key := fmt.Sprintf("%s/%s", path, formatKey(l))
-			ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
-			_, err := b.C.Put(ctx, key, formatValue(l), clientv3.WithLease(leaseID))
-			cancel()
-			if err != nil {
-				return err
-			}
+			ops = append(ops, clientv3.OpPut(key, b.encode(&codec.Record{Host: l, TTL: ttl}), clientv3.WithLease(leaseID)))
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+		_, err := b.C.Txn(ctx).Then(ops...).Commit()
+		cancel()
+		if err != nil {
+			return err
 		}
 	}
 
@@ -801,7 +1024,7 @@ func (b *Backend) setToken(opts *model.DomainOptions, exist bool) (int64, int64,
 	var token string
 	var leaseID, leaseTTL int64
 
-	path := getTokenPath(opts.Fqdn)
+	path := b.getTokenPath(opts.Fqdn)
 
 	if exist {
 		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
@@ -828,7 +1051,7 @@ func (b *Backend) setToken(opts *model.DomainOptions, exist bool) (int64, int64,
 	} else {
 		token = util.RandStringWithAll(tokenLength)
 
-		id, ttl, err := b.grantLease(int64(b.LeaseTime.Seconds()))
+		id, ttl, err := b.grantLease(b.leaseSeconds(opts))
 		if err != nil {
 			return 0, -1, err
 		}
@@ -847,6 +1070,27 @@ func (b *Backend) setToken(opts *model.DomainOptions, exist bool) (int64, int64,
 	return leaseID, leaseTTL, nil
 }
 
+// Used to get the path of a TXT record, values of ACME orders are kept as children of the
+// record so concurrent orders of one fqdn are all answered
+// e.g. _acme-challenge.sample.lb.rancher.cloud => /rdnsv3/cloud/rancher/lb/sample/_acme-challenge
+// e.g. _acme-challenge.sample.lb.rancher.cloud, 4f1b => /rdnsv3/cloud/rancher/lb/sample/_acme-challenge/4f1b
+func (b *Backend) textPath(opts *model.DomainOptions) string {
+	if opts.Order == "" {
+		return b.getPath(opts.Fqdn)
+	}
+	return fmt.Sprintf("%s/%s", b.getPath(opts.Fqdn), opts.Order)
+}
+
+// Used to get the lease of a TXT record, ACME challenge records get a short lease of their
+// own so that stale challenge values are cleaned up long before the domain expires.
+func (b *Backend) textLease(fqdn string, leaseID int64) (int64, error) {
+	if b.ChallengeTTL <= 0 || !util.IsACMEChallenge(fqdn) {
+		return leaseID, nil
+	}
+	id, _, err := b.grantLease(int64(b.ChallengeTTL.Seconds()))
+	return id, err
+}
+
 func (b *Backend) lockSlugName(fqdn, slug string, exist bool) error {
 	logrus.Debugf("lock slug name: %s", fqdn)
 
@@ -883,6 +1127,35 @@ func (b *Backend) lockSlugName(fqdn, slug string, exist bool) error {
 	return nil
 }
 
+// Used to freeze a requested slug name before its domain is set, the name is only frozen when it is
+// neither frozen nor used, so two requests of the same name can not both get it.
+func (b *Backend) reserveSlugName(slug, path string) error {
+	frozen := fmt.Sprintf("%s%s/%s", b.Prefix, frozenPath, slug)
+
+	leaseID, _, err := b.grantLease(int64(b.FrozenTTL.Seconds()))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Txn(ctx).If(
+		clientv3.Compare(clientv3.CreateRevision(frozen), "=", 0),
+		clientv3.Compare(clientv3.CreateRevision(path), "=", 0),
+	).Then(
+		clientv3.OpPut(frozen, "", clientv3.WithLease(clientv3.LeaseID(leaseID))),
+	).Commit()
+	if err != nil {
+		return errors.Wrapf(err, errSetRecordWithLease, typeFrozen, frozen, leaseID)
+	}
+	if !resp.Succeeded {
+		return errors.Wrapf(backend.ErrNameTaken, errRequestName, slug)
+	}
+
+	return nil
+}
+
 func (b *Backend) lookupKeys(path string) ([]*mvccpb.KeyValue, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
 	defer cancel()
@@ -895,11 +1168,11 @@ func (b *Backend) lookupKeys(path string) ([]*mvccpb.KeyValue, error) {
 	kvs := make([]*mvccpb.KeyValue, 0)
 	for _, v := range resp.Kvs {
 		if len(v.Value) > 0 {
-			m, err := unmarshalToMap(v.Value)
+			rec, err := codec.Decode(v.Value)
 			if err != nil {
 				continue
 			}
-			if _, ok := m["text"]; ok {
+			if rec.TXT {
 				continue
 			}
 		} else {
@@ -977,6 +1250,23 @@ func (b *Backend) checkPathExist(path string) bool {
 	return true
 }
 
+// Used to get the seconds a new domain lives, the api clamps the requested lease
+// e.g. {Lease: 0} => 864000
+// e.g. {Lease: 3600} => 3600
+func (b *Backend) leaseSeconds(opts *model.DomainOptions) int64 {
+	if opts.Lease > 0 {
+		return opts.Lease
+	}
+	return int64(b.LeaseTime.Seconds())
+}
+
+// Used to get a path of the configured shard layout
+// e.g. sample.lb.rancher.cloud => /rdnsv3/cloud/rancher/lb/sample
+// e.g. sample.lb.rancher.cloud => /rdnsv3/cloud/rancher/lb/_27/sample
+func (b *Backend) getPath(fqdn string) string {
+	return getPath(b.Prefix, util.ShardFqdn(fqdn, b.Domain, b.Shards))
+}
+
 // Used to get a path as etcd preferred
 // e.g. sample.lb.rancher.cloud => /rdnsv3/cloud/rancher/lb/sample
 func getPath(path, fqdn string) string {
@@ -994,34 +1284,49 @@ func convertToPath(domain string) string {
 	return "/" + strings.Join(ss, "/")
 }
 
-// Used to get a token path as etcd preferred
-// e.g. sample.lb.rancher.cloud => /tokenv3/sample_lb_rancher_cloud
-func getTokenPath(fqdn string) string {
-	return fmt.Sprintf("%s/%s", tokenPath, formatKey(fqdn))
+// Used to get a token path as etcd preferred, tokens are kept under the prefix and the root domain
+// so the instances of other root domains can share the etcd cluster
+// e.g. sample.lb.rancher.cloud => /rdnsv3/tokenv3/lb_rancher_cloud/sample_lb_rancher_cloud
+func (b *Backend) getTokenPath(fqdn string) string {
+	return fmt.Sprintf("%s/%s", b.tokenRoot(), formatKey(fqdn))
+}
+
+// Used to get the path which holds the tokens of the root domain
+// e.g. lb.rancher.cloud => /rdnsv3/tokenv3/lb_rancher_cloud
+func (b *Backend) tokenRoot() string {
+	return fmt.Sprintf("%s%s/%s", b.Prefix, tokenPath, formatKey(strings.TrimSuffix(b.Domain, ".")))
 }
 
 // Used to format a key as etcd preferred
 // e.g. 1.1.1.1 => 1_1_1_1
+// e.g. 2001:db8::1 => 2001_db8__1
 // e.g. sample.lb.rancher.cloud => sample_lb_rancher_cloud
 func formatKey(key string) string {
-	return strings.Replace(key, ".", "_", -1)
+	return keyReplacer.Replace(key)
 }
 
 // Used to format a A value as dns preferred
 // e.g. 1.1.1.1 => {"host": "1.1.1.1"}
-func formatValue(value string) string {
-	return fmt.Sprintf("{\"host\":\"%s\"}", value)
+func (b *Backend) formatValue(value string) string {
+	return b.encode(&codec.Record{Host: value})
 }
 
 // Used to format a txt value as dns preferred
 // e.g. abc => {"text": "abc"}
-func formatTextValue(value string) string {
-	return fmt.Sprintf("{\"text\":\"%s\"}", value)
+func (b *Backend) formatTextValue(value string) string {
+	return b.encode(&codec.Record{Text: value, TXT: true})
 }
 
-// Used to generate a random slug
-func generateSlug() string {
-	return util.RandStringWithSmall(slugLength)
+// Used to encode a record with the configured value encoding,
+// records only hold strings so encoding never fails
+func (b *Backend) encode(r *codec.Record) string {
+	v, _ := b.Codec.Encode(r)
+	return string(v)
+}
+
+// Used to generate a random slug with the slug length of the zone
+func generateSlug(zone string) string {
+	return util.RandStringWithSmall(config.Resolve(zone).SlugLength)
 }
 
 // Used to find slug name
@@ -1050,12 +1355,6 @@ func getExpiration(ttl int64) *time.Time {
 	return &e
 }
 
-func unmarshalToMap(b []byte) (map[string]string, error) {
-	var v map[string]string
-	err := json.Unmarshal(b, &v)
-	return v, err
-}
-
 func sliceToMap(ss []string) map[string]bool {
 	m := make(map[string]bool)
 	for _, s := range ss {
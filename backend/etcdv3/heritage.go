@@ -0,0 +1,104 @@
+package etcdv3
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/codec"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+)
+
+// The external-dns coredns provider writes the records of a name to keys with a random last label
+// below the path of the name, e.g. sample.lb.rancher.cloud => /skydns/cloud/rancher/lb/sample/1a2b3c4d,
+// so the ownership TXT record of a domain is kept below its path like them.
+const heritageLabel = "_heritage"
+
+// Used to get the key of the ownership TXT record of a domain
+// e.g. sample.lb.rancher.cloud => /rdnsv3/cloud/rancher/lb/sample/_heritage
+func (b *Backend) heritageKey(fqdn string) string {
+	return b.getPath(fqdn) + "/" + heritageLabel
+}
+
+// Used to get the put of the ownership TXT record of a domain on the lease of its token, there is
+// none without HeritageOwner
+func (b *Backend) heritageOps(fqdn string, leaseID int64) []clientv3.Op {
+	if b.HeritageOwner == "" {
+		return nil
+	}
+	text := model.HeritageText(b.HeritageOwner, "rdns/"+fqdn)
+	return []clientv3.Op{clientv3.OpPut(b.heritageKey(fqdn), b.formatTextValue(text), clientv3.WithLease(clientv3.LeaseID(leaseID)))}
+}
+
+func (b *Backend) putHeritage(fqdn string, leaseID int64) error {
+	ops := b.heritageOps(fqdn, leaseID)
+	if len(ops) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := b.C.Txn(ctx).Then(ops...).Commit(); err != nil {
+		return errors.Wrapf(err, errSetRecordWithLease, typeTXT, b.heritageKey(fqdn), leaseID)
+	}
+	return nil
+}
+
+// Used to get the labels of the ownership TXT record of a domain, nil when it has none
+func (b *Backend) getAnnotations(fqdn string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := b.heritageKey(fqdn)
+	resp, err := b.C.Get(ctx, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeTXT, key)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+
+	rec, err := codec.Decode(resp.Kvs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	labels, _ := model.ParseHeritage(rec.Text)
+	return labels, nil
+}
+
+// checkHeritage refuses to write the records of a name whose ownership TXT record is of another
+// external-dns owner, a name without one is never refused so the names of earlier versions keep
+// working. The records of external-dns are the keys right below the path of the name, the record
+// of heritageKey is written by the backend so it is not refused when HeritageOwner is changed.
+func (b *Backend) checkHeritage(fqdn string) error {
+	path := b.getPath(fqdn) + "/"
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix())
+	if err != nil {
+		return errors.Wrapf(err, errLookupRecords, typeTXT, path)
+	}
+
+	for _, kv := range resp.Kvs {
+		label := strings.TrimPrefix(string(kv.Key), path)
+		if label == heritageLabel || strings.Contains(label, "/") {
+			continue
+		}
+		rec, err := codec.Decode(kv.Value)
+		if err != nil || rec.Text == "" {
+			continue
+		}
+		labels, ok := model.ParseHeritage(rec.Text)
+		if !ok || labels[model.LabelOwner] == b.HeritageOwner {
+			continue
+		}
+		return errors.Wrapf(backend.ErrForeignOwner, errForeignOwner, fqdn, labels[model.LabelOwner])
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The jobs are kept without a lease by kind and sorted by their id, which starts with the time
+// they are queued at
+// e.g. /jobv3/webhook/1570924800000000000-abcdefgh => {"kind": "webhook", "visible": ...}
+const jobPath = "/jobv3"
+
+func (b *Backend) EnqueueJob(j *model.Job) error {
+	logrus.Debugf("enqueue %s job: %s", j.Kind, j.ID)
+
+	value, err := json.Marshal(j)
+	if err != nil {
+		return errors.Wrapf(err, errSetRecord, typeJob, j.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := b.C.Put(ctx, b.jobKey(j.Kind, j.ID), string(value)); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeJob, j.ID)
+	}
+
+	return nil
+}
+
+// ClaimJobs hides the visible jobs until the visibility timeout, a job is only claimed when it is
+// not changed since it was read, so a job which another worker claims at once is left to that worker.
+func (b *Backend) ClaimJobs(kind string, limit int, visibility time.Duration) ([]model.Job, error) {
+	path := b.jobKey(kind, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeJob, path)
+	}
+
+	now := time.Now()
+	result := make([]model.Job, 0)
+	for _, kv := range resp.Kvs {
+		if len(result) >= limit {
+			break
+		}
+
+		var j model.Job
+		if err := json.Unmarshal(kv.Value, &j); err != nil {
+			logrus.Warnf("skip invalid %s record %s: %v", typeJob, kv.Key, err)
+			continue
+		}
+		if j.Visible.After(now) {
+			continue
+		}
+
+		j.Attempts++
+		j.Visible = now.Add(visibility)
+		value, err := json.Marshal(j)
+		if err != nil {
+			return result, errors.Wrapf(err, errSetRecord, typeJob, j.ID)
+		}
+
+		key := string(kv.Key)
+		txn, err := b.C.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+			Then(clientv3.OpPut(key, string(value))).
+			Commit()
+		if err != nil {
+			return result, errors.Wrapf(err, errSetRecord, typeJob, j.ID)
+		}
+		if txn.Succeeded {
+			result = append(result, j)
+		}
+	}
+
+	return result, nil
+}
+
+func (b *Backend) DeleteJob(kind, id string) error {
+	logrus.Debugf("delete %s job: %s", kind, id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := b.C.Delete(ctx, b.jobKey(kind, id)); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeJob, id)
+	}
+
+	return nil
+}
+
+// Used to get the key of a job, an empty id is the prefix of the jobs of the kind
+// e.g. webhook, 1570924800000000000-abcdefgh => /rdnsv3/jobv3/webhook/1570924800000000000-abcdefgh
+func (b *Backend) jobKey(kind, id string) string {
+	return fmt.Sprintf("%s%s/%s/%s", b.Prefix, jobPath, kind, id)
+}
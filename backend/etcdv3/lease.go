@@ -0,0 +1,247 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// keys are moved in transactions below the default limit of 128 operations
+const maxTxnOps = 100
+
+// Extend moves the keys of a domain to a lease which is granted the longer lease time, renewals
+// keep the longer lease once it is moved. It returns false when the domain already has a lease
+// which is granted as long, or is a temporary domain.
+func (b *Backend) Extend(fqdn string, leaseTime time.Duration) (bool, error) {
+	logrus.Debugf("extend lease of fqdn %s to %s", fqdn, leaseTime)
+
+	m, err := b.getMeta(fqdn)
+	if err != nil {
+		return false, err
+	}
+	if m.Deadline != nil {
+		return false, nil
+	}
+
+	lease, err := b.domainLease(fqdn)
+	if err != nil {
+		return false, err
+	}
+
+	seconds := int64(leaseTime.Seconds())
+	if lease.GrantedTTL >= seconds {
+		return false, nil
+	}
+
+	return true, b.moveLease(fqdn, lease.Keys, seconds)
+}
+
+// SetExpiration moves the keys of a domain to a lease which expires at until, sooner or later
+// than the lease it has. Renewals keep the lease time of the new lease.
+func (b *Backend) SetExpiration(fqdn string, until time.Time) (d model.Domain, err error) {
+	logrus.Debugf("set expiration of fqdn %s to %s", fqdn, until.Format(time.RFC3339))
+
+	seconds := int64(time.Until(until).Seconds())
+	if seconds <= 0 {
+		return d, errors.Errorf(errInvalidExpiration, until.Format(time.RFC3339))
+	}
+
+	lease, err := b.domainLease(fqdn)
+	if err != nil {
+		return d, err
+	}
+	if err := b.moveLease(fqdn, lease.Keys, seconds); err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
+
+// SetDeadline keeps the absolute expiry of a temporary domain in its meta, the keys of the domain
+// are moved to a lease which expires at the deadline when their lease expires later.
+func (b *Backend) SetDeadline(fqdn string, until time.Time) (d model.Domain, err error) {
+	logrus.Debugf("set deadline of fqdn %s to %s", fqdn, until.Format(time.RFC3339))
+
+	seconds := int64(time.Until(until).Seconds())
+	if seconds <= 0 {
+		return d, errors.Errorf(errInvalidExpiration, until.Format(time.RFC3339))
+	}
+
+	lease, err := b.domainLease(fqdn)
+	if err != nil {
+		return d, err
+	}
+
+	m, err := b.getMeta(fqdn)
+	if err != nil {
+		return d, err
+	}
+	m.Deadline = &until
+	value, err := json.Marshal(m)
+	if err != nil {
+		return d, errors.Wrapf(err, errSetIndexes, fqdn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	_, err = b.C.Put(ctx, b.metaKey(fqdn), string(value), clientv3.WithLease(lease.ID))
+	cancel()
+	if err != nil {
+		return d, errors.Wrapf(err, errSetIndexes, fqdn)
+	}
+
+	if lease.TTL > seconds {
+		// read again, so the meta key is attached to the lease which is moved
+		if lease, err = b.domainLease(fqdn); err != nil {
+			return d, err
+		}
+		if err := b.moveLease(fqdn, lease.Keys, seconds); err != nil {
+			return d, err
+		}
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
+
+// Keepalive renews the lease of a domain without reading its records. The token and meta keys are
+// read in one transaction and the lease is kept alive once, only the lease of a temporary domain
+// takes the steps of Renew to stay within its deadline.
+func (b *Backend) Keepalive(fqdn string) (time.Time, error) {
+	logrus.Debugf("keepalive lease of fqdn %s", fqdn)
+
+	path := b.getTokenPath(fqdn)
+	key := b.metaKey(fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	resp, err := b.C.Txn(ctx).Then(clientv3.OpGet(path), clientv3.OpGet(key)).Commit()
+	cancel()
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, errEmptyRecord, typeToken, path)
+	}
+
+	tokens, metas := resp.Responses[0].GetResponseRange(), resp.Responses[1].GetResponseRange()
+	if tokens.Count <= 0 {
+		return time.Time{}, errors.Errorf(errEmptyRecord, typeToken, path)
+	}
+	id := tokens.Kvs[0].Lease
+
+	m := &meta{}
+	if metas.Count > 0 {
+		if err := json.Unmarshal(metas.Kvs[0].Value, m); err != nil {
+			return time.Time{}, errors.Wrapf(err, errLookupRecords, typeIndex, key)
+		}
+	}
+
+	var ttl int64
+	if m.Deadline == nil {
+		_, ttl, err = b.keepaliveOnce(id)
+	} else {
+		ttl, err = b.renewLease(fqdn, id)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Now().Add(time.Duration(ttl) * time.Second), nil
+}
+
+// Used to renew the lease of a domain, the lease of a temporary domain is renewed up to its
+// deadline at most. It returns the seconds the lease lives from now.
+func (b *Backend) renewLease(fqdn string, id int64) (int64, error) {
+	m, err := b.getMeta(fqdn)
+	if err != nil {
+		return 0, err
+	}
+	if m.Deadline == nil {
+		_, ttl, err := b.keepaliveOnce(id)
+		return ttl, err
+	}
+
+	remaining := int64(time.Until(*m.Deadline).Seconds())
+	lease, err := b.domainLease(fqdn)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case lease.GrantedTTL <= remaining:
+		_, ttl, err := b.keepaliveOnce(id)
+		return ttl, err
+	case lease.TTL < remaining:
+		return remaining, b.moveLease(fqdn, lease.Keys, remaining)
+	default:
+		return lease.TTL, nil
+	}
+}
+
+// Used to get the lease of the token of a domain with the keys which are attached to it
+func (b *Backend) domainLease(fqdn string) (*clientv3.LeaseTimeToLiveResponse, error) {
+	path := b.getTokenPath(fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	resp, err := b.C.Get(ctx, path)
+	cancel()
+	if err != nil {
+		return nil, errors.Wrapf(err, errEmptyRecord, typeToken, path)
+	}
+	if resp.Count <= 0 {
+		return nil, errors.Errorf(errEmptyRecord, typeToken, path)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+	return b.C.TimeToLive(ctx, clientv3.LeaseID(resp.Kvs[0].Lease), clientv3.WithAttachedKeys())
+}
+
+// Used to move the keys of a domain to a new lease of the seconds
+func (b *Backend) moveLease(fqdn string, keys [][]byte, seconds int64) error {
+	id, _, err := b.grantLease(seconds)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(keys); start += maxTxnOps {
+		end := start + maxTxnOps
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := b.moveKeys(keys[start:end], id); err != nil {
+			return errors.Wrapf(err, errExtendLease, fqdn, id)
+		}
+	}
+	return nil
+}
+
+// Used to put the keys again with the lease, keys which are deleted in the meantime are skipped
+func (b *Backend) moveKeys(keys [][]byte, id int64) error {
+	gets := make([]clientv3.Op, 0, len(keys))
+	for _, k := range keys {
+		gets = append(gets, clientv3.OpGet(string(k)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Txn(ctx).Then(gets...).Commit()
+	if err != nil {
+		return err
+	}
+
+	puts := make([]clientv3.Op, 0, len(keys))
+	for _, r := range resp.Responses {
+		for _, kv := range r.GetResponseRange().Kvs {
+			puts = append(puts, clientv3.OpPut(string(kv.Key), string(kv.Value), clientv3.WithLease(clientv3.LeaseID(id))))
+		}
+	}
+	if len(puts) == 0 {
+		return nil
+	}
+
+	_, err = b.C.Txn(ctx).Then(puts...).Commit()
+	return err
+}
@@ -0,0 +1,186 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/rancher/rdns-server/codec"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type continueToken struct {
+	Revision int64  `json:"rev"`
+	Key      string `json:"key"`
+}
+
+// List returns a page of domains of the zone. All pages of one listing are read at the
+// etcd revision of the first page, so the pages form a consistent snapshot.
+func (b *Backend) List(opts *model.ListOptions) (l model.DomainList, err error) {
+	logrus.Debugf("list %s records with limit %d", typeA, opts.Limit)
+
+	root := getPath(b.Prefix, b.Domain) + "/"
+	end := clientv3.GetPrefixRangeEnd(root)
+	key := root
+	var rev int64
+
+	if opts.Continue != "" {
+		rev, key, err = decodeContinue(opts.Continue)
+		if err != nil || !strings.HasPrefix(key, root) {
+			return l, errors.Errorf(errInvalidContinue, opts.Continue)
+		}
+	}
+
+	items := make([]model.Domain, 0)
+	leases := make([]int64, 0)
+	index := make(map[string]int)
+
+Pages:
+	for {
+		ops := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithLimit(rangePageSize)}
+		if rev > 0 {
+			ops = append(ops, clientv3.WithRev(rev))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+		resp, err := b.C.Get(ctx, key, ops...)
+		cancel()
+		if err != nil {
+			if err == rpctypes.ErrCompacted {
+				return l, errors.Errorf(errContinueExpired, rev)
+			}
+			return l, errors.Wrapf(err, errLookupRecords, typeA, root)
+		}
+
+		if rev == 0 {
+			rev = resp.Header.Revision
+		}
+
+		for _, v := range resp.Kvs {
+			slug, rest := splitListKey(string(v.Key), root)
+			if slug == "" {
+				continue
+			}
+
+			i, ok := index[slug]
+			if !ok {
+				if int64(len(items)) >= opts.Limit {
+					l.Continue = encodeContinue(rev, string(v.Key))
+					break Pages
+				}
+				items = append(items, model.Domain{Fqdn: slug + "." + b.Domain})
+				leases = append(leases, 0)
+				i = len(items) - 1
+				index[slug] = i
+			}
+
+			if len(rest) == 0 {
+				leases[i] = v.Lease
+			}
+
+			if err := appendListValue(&items[i], rest, v); err != nil {
+				return l, err
+			}
+		}
+
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		key = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+
+	for i := range items {
+		if leases[i] == 0 {
+			continue
+		}
+		lease, err := b.getLease(leases[i])
+		if err != nil || lease.TTL <= 0 {
+			continue
+		}
+		items[i].Expiration = getExpiration(lease.TTL)
+	}
+
+	l.Items = items
+	l.Revision = rev
+
+	return l, nil
+}
+
+// Used to append a listed key to its domain, TXT values are not part of the list
+// e.g. [] => base record
+// e.g. [1_1_1_1] => host, [x1] => sub domain, [x1 1_1_1_1] => sub domain host
+func appendListValue(d *model.Domain, rest []string, v *mvccpb.KeyValue) error {
+	if len(rest) == 0 {
+		return nil
+	}
+
+	rec, err := codec.Decode(v.Value)
+	if err != nil {
+		return err
+	}
+	if rec.TXT {
+		return nil
+	}
+
+	switch len(rest) {
+	case 1:
+		if rec.Host != "" {
+			d.Hosts = append(d.Hosts, rec.Host)
+			return nil
+		}
+		if d.SubDomain == nil {
+			d.SubDomain = make(map[string][]string)
+		}
+		if _, ok := d.SubDomain[rest[0]]; !ok {
+			d.SubDomain[rest[0]] = make([]string, 0)
+		}
+	case 2:
+		if rec.Host == "" {
+			return nil
+		}
+		if d.SubDomain == nil {
+			d.SubDomain = make(map[string][]string)
+		}
+		d.SubDomain[rest[0]] = append(d.SubDomain[rest[0]], rec.Host)
+	}
+
+	return nil
+}
+
+// Used to split a listed key to the slug and the rest segments
+// e.g. /rdnsv3/cloud/rancher/lb/_21/qrn7oq/x1 => qrn7oq, [x1]
+func splitListKey(key, root string) (string, []string) {
+	ss := strings.Split(strings.TrimPrefix(key, root), "/")
+	if util.IsShardLabel(ss[0]) {
+		ss = ss[1:]
+	}
+	if len(ss) == 0 || ss[0] == "" {
+		return "", nil
+	}
+	return ss[0], ss[1:]
+}
+
+func encodeContinue(rev int64, key string) string {
+	b, _ := json.Marshal(&continueToken{Revision: rev, Key: key})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeContinue(s string) (int64, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, "", err
+	}
+	t := &continueToken{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return 0, "", err
+	}
+	return t.Revision, t.Key, nil
+}
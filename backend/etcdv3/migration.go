@@ -0,0 +1,167 @@
+package etcdv3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	migrationPath = "/migrationv3"
+	typeMigration = "MIGRATION"
+)
+
+// AppliedMigrations returns the data migrations which are recorded as applied with their apply time.
+func (b *Backend) AppliedMigrations() (map[string]time.Time, error) {
+	path := fmt.Sprintf("%s%s/", b.Prefix, migrationPath)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeMigration, path)
+	}
+
+	applied := make(map[string]time.Time, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		t, _ := time.Parse(time.RFC3339, string(kv.Value))
+		applied[strings.TrimPrefix(string(kv.Key), path)] = t
+	}
+
+	return applied, nil
+}
+
+// RecordMigration records a data migration as applied, the key has no lease.
+func (b *Backend) RecordMigration(id string) error {
+	key := fmt.Sprintf("%s%s/%s", b.Prefix, migrationPath, id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := b.C.Put(ctx, key, time.Now().Format(time.RFC3339)); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeMigration, key)
+	}
+
+	return nil
+}
+
+// Reindex writes the search and host index keys of every domain, domains which are
+// written before the indexes exist are otherwise only indexed on their next update.
+// It returns the number of indexed domains.
+func (b *Backend) Reindex() (int, error) {
+	opts := &model.ListOptions{Limit: model.MaxListLimit}
+	indexed := 0
+
+	for {
+		l, err := b.List(opts)
+		if err != nil {
+			return indexed, err
+		}
+
+		for i := range l.Items {
+			d := &l.Items[i]
+			if err := b.reindexDomain(d); err != nil {
+				logrus.Warnf("skip indexing domain %s: %v", d.Fqdn, err)
+				continue
+			}
+			indexed++
+		}
+
+		if l.Continue == "" {
+			break
+		}
+		opts.Continue = l.Continue
+	}
+
+	return indexed, nil
+}
+
+func (b *Backend) reindexDomain(d *model.Domain) error {
+	if err := b.setIndexes(d, nil, ""); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	resp, err := b.C.Get(ctx, b.getTokenPath(d.Fqdn))
+	cancel()
+	if err != nil || resp.Count <= 0 {
+		return errors.Errorf(errEmptyRecord, typeToken, b.getTokenPath(d.Fqdn))
+	}
+	lease := clientv3.WithLease(clientv3.LeaseID(resp.Kvs[0].Lease))
+
+	ops := make([]clientv3.Op, 0)
+	for _, h := range d.Hosts {
+		ops = append(ops, clientv3.OpPut(b.indexKey(indexHost, h, d.Fqdn), d.Fqdn, lease))
+	}
+	for prefix, hosts := range d.SubDomain {
+		fqdn := fmt.Sprintf("%s.%s", prefix, d.Fqdn)
+		for _, h := range hosts {
+			ops = append(ops, clientv3.OpPut(b.indexKey(indexHost, h, fqdn), d.Fqdn, lease))
+		}
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), rangeTimeout)
+	defer cancel()
+
+	if _, err := b.C.Txn(ctx).Then(ops...).Commit(); err != nil {
+		return errors.Wrapf(err, errSetIndexes, d.Fqdn)
+	}
+
+	return nil
+}
+
+// MoveTokens moves the tokens of the root domain from the global token path to the path under
+// the prefix, the tokens keep their leases. Tokens of other root domains are left to their own
+// instances. It returns the number of moved tokens.
+func (b *Backend) MoveTokens() (int, error) {
+	path := tokenPath + "/"
+	suffix := "_" + formatKey(b.Domain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix())
+	cancel()
+	if err != nil {
+		return 0, errors.Wrapf(err, errLookupRecords, typeToken, path)
+	}
+
+	moved := 0
+	for _, kv := range resp.Kvs {
+		key := strings.TrimPrefix(string(kv.Key), path)
+		// only the tokens of sample.<root> belong to the root, sample.lb.<root> belongs to lb.<root>
+		slug := strings.TrimSuffix(key, suffix)
+		if slug == key || slug == "" || strings.Contains(slug, "_") {
+			continue
+		}
+
+		to := b.getTokenPath(slug + "." + b.Domain)
+
+		// the token which is already under the prefix wins, it may be written by a new instance
+		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+		_, err := b.C.Txn(ctx).If(
+			clientv3.Compare(clientv3.CreateRevision(to), "=", 0),
+		).Then(
+			clientv3.OpPut(to, string(kv.Value), clientv3.WithLease(clientv3.LeaseID(kv.Lease))),
+			clientv3.OpDelete(string(kv.Key)),
+		).Else(
+			clientv3.OpDelete(string(kv.Key)),
+		).Commit()
+		cancel()
+		if err != nil {
+			return moved, errors.Wrapf(err, errMoveToken, string(kv.Key), to)
+		}
+		moved++
+	}
+
+	return moved, nil
+}
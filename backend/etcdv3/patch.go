@@ -0,0 +1,59 @@
+package etcdv3
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/rdns-server/codec"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// PatchHosts writes the added and removed hosts of a domain in one transaction with their host
+// index keys, concurrent patches of other hosts are not lost. The transaction is refused when the
+// domain has expired meanwhile.
+func (b *Backend) PatchHosts(fqdn string, add, remove []string) (d model.Domain, err error) {
+	logrus.Debugf("patch %s record for domain %s: add %v, remove %v", typeA, fqdn, add, remove)
+
+	path := b.getPath(fqdn)
+
+	leaseID, _, err := b.setToken(&model.DomainOptions{Fqdn: fqdn}, true)
+	if err != nil {
+		return d, err
+	}
+
+	base := fmt.Sprintf("%s.%s", findSlugWithZone(fqdn, b.Domain), b.Domain)
+	ttl, err := b.getTTL(base)
+	if err != nil {
+		return d, err
+	}
+
+	lease := clientv3.WithLease(clientv3.LeaseID(leaseID))
+	ops := make([]clientv3.Op, 0, 2*(len(add)+len(remove)))
+	for _, h := range remove {
+		ops = append(ops,
+			clientv3.OpDelete(fmt.Sprintf("%s/%s", path, formatKey(h))),
+			clientv3.OpDelete(b.indexKey(indexHost, h, fqdn)))
+	}
+	for _, h := range add {
+		ops = append(ops,
+			clientv3.OpPut(fmt.Sprintf("%s/%s", path, formatKey(h)), b.encode(&codec.Record{Host: h, TTL: ttl}), lease),
+			clientv3.OpPut(b.indexKey(indexHost, h, fqdn), base, lease))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Txn(ctx).If(clientv3.Compare(clientv3.CreateRevision(path), ">", 0)).Then(ops...).Commit()
+	if err != nil {
+		return d, errors.Wrapf(err, errSyncRecords, typeA, path)
+	}
+	if !resp.Succeeded {
+		return d, errors.Errorf(errNoLookupResults, typeA, path)
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
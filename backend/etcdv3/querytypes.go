@@ -0,0 +1,66 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetQueryTypes keeps the query types of a domain in one key with the lease of the domain token,
+// the dns plugin reads it for the domain and the names below it. No types lift the restriction.
+func (b *Backend) SetQueryTypes(fqdn string, types []string) error {
+	logrus.Debugf("set %s of domain %s to %v", typeQueryTypes, fqdn, types)
+
+	leaseID, _, err := b.setToken(&model.DomainOptions{Fqdn: fqdn}, true)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := util.QueryTypesKey(b.Prefix, fqdn)
+	if len(types) == 0 {
+		if _, err := b.C.Delete(ctx, key); err != nil {
+			return errors.Wrapf(err, errDeleteRecord, typeQueryTypes, key)
+		}
+		return nil
+	}
+
+	value, err := json.Marshal(types)
+	if err != nil {
+		return errors.Wrapf(err, errSetRecord, typeQueryTypes, fqdn)
+	}
+	if _, err := b.C.Put(ctx, key, string(value), clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
+		return errors.Wrapf(err, errSetRecordWithLease, typeQueryTypes, key, leaseID)
+	}
+
+	return nil
+}
+
+// GetQueryTypes gets the query types of a domain, nil means the domain is not restricted.
+func (b *Backend) GetQueryTypes(fqdn string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := util.QueryTypesKey(b.Prefix, fqdn)
+	resp, err := b.C.Get(ctx, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeQueryTypes, key)
+	}
+	if resp.Count == 0 {
+		return nil, nil
+	}
+
+	var types []string
+	if err := json.Unmarshal(resp.Kvs[0].Value, &types); err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeQueryTypes, key)
+	}
+	return types, nil
+}
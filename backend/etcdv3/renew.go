@@ -0,0 +1,211 @@
+package etcdv3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/rdns-server/codec"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// renewal holds the keys of a domain which a renewal refreshes, they are read in one transaction
+// so the records and the lease of the token are of one revision.
+type renewal struct {
+	token   *mvccpb.KeyValue
+	keys    []*mvccpb.KeyValue
+	records []*mvccpb.KeyValue
+}
+
+// Renew refreshes the lease of the token of a domain, the records, the host index keys, the token
+// origin and the TXT records of the domain share the lease so they are all refreshed by the one
+// keepalive. Keys which are still on another lease, e.g. after a lease move which failed halfway,
+// are moved to the lease of the token in one transaction before it is refreshed.
+func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("renew %s record for domain options: %s", typeA, opts.String())
+
+	path := b.getPath(opts.Fqdn)
+
+	r, err := b.readRenewal(opts.Fqdn)
+	if err != nil {
+		return d, err
+	}
+
+	if len(r.records) <= 0 {
+		return d, errors.Errorf(errNoLookupResults, typeA, path)
+	}
+
+	hosts, subs := renewalHosts(r.records, path)
+	if err := b.fenceLease(opts.Fqdn, r, hosts, subs); err != nil {
+		return d, err
+	}
+
+	leaseTTL, err := b.renewLease(opts.Fqdn, r.token.Lease)
+	if err != nil {
+		return d, err
+	}
+
+	d.Fqdn = opts.Fqdn
+	d.Hosts = hosts
+	d.SubDomain = subs
+	d.Expiration = getExpiration(leaseTTL)
+
+	return d, b.setIndexes(&d, nil, "")
+}
+
+// Used to read the token, the scoped tokens, the meta key and the records of a domain with the
+// records of its sub domains and its TXT records in one transaction
+func (b *Backend) readRenewal(fqdn string) (*renewal, error) {
+	path := b.getPath(fqdn)
+	token := b.getTokenPath(fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+	defer cancel()
+
+	resp, err := b.C.Txn(ctx).Then(
+		clientv3.OpGet(token),
+		clientv3.OpGet(b.scopedTokenKey(fqdn, ""), clientv3.WithPrefix()),
+		clientv3.OpGet(b.metaKey(fqdn)),
+		clientv3.OpGet(path),
+		clientv3.OpGet(path+"/", clientv3.WithPrefix()),
+	).Commit()
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeA, path)
+	}
+
+	tokens := resp.Responses[0].GetResponseRange().Kvs
+	if len(tokens) <= 0 {
+		return nil, errors.Errorf(errEmptyRecord, typeToken, token)
+	}
+
+	r := &renewal{token: tokens[0]}
+	for i, rr := range resp.Responses[1:] {
+		for _, kv := range rr.GetResponseRange().Kvs {
+			// the first two are the scoped tokens and the meta key, the others are the records
+			if i < 2 {
+				r.keys = append(r.keys, kv)
+				continue
+			}
+			rec, err := codec.Decode(kv.Value)
+			if err != nil {
+				continue
+			}
+			if rec.TXT {
+				if !b.ownsTextLease(string(kv.Key), path) {
+					r.keys = append(r.keys, kv)
+				}
+				continue
+			}
+			r.keys = append(r.keys, kv)
+			r.records = append(r.records, kv)
+		}
+	}
+
+	return r, nil
+}
+
+// Used to move the keys of a renewal which are not on the lease of the token to it, a key which is
+// written in the meantime is skipped as its writer puts it with the lease of the token. The host
+// index keys are put again with the moved keys as they are not read by the renewal.
+func (b *Backend) fenceLease(fqdn string, r *renewal, hosts []string, subs map[string][]string) error {
+	lease := clientv3.WithLease(clientv3.LeaseID(r.token.Lease))
+
+	ops := make([]clientv3.Op, 0)
+	for _, kv := range r.keys {
+		if kv.Lease == r.token.Lease {
+			continue
+		}
+		k := string(kv.Key)
+		ops = append(ops, clientv3.OpTxn(
+			[]clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(k), "=", kv.ModRevision)},
+			[]clientv3.Op{clientv3.OpPut(k, string(kv.Value), lease)},
+			nil,
+		))
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	logrus.Infof("move %d keys of fqdn %s to lease %d before it is renewed", len(ops), fqdn, r.token.Lease)
+
+	for _, h := range hosts {
+		ops = append(ops, clientv3.OpPut(b.indexKey(indexHost, h, fqdn), fqdn, lease))
+	}
+	for prefix, values := range subs {
+		for _, h := range values {
+			ops = append(ops, clientv3.OpPut(b.indexKey(indexHost, h, fmt.Sprintf("%s.%s", prefix, fqdn)), fqdn, lease))
+		}
+	}
+
+	for start := 0; start < len(ops); start += maxTxnOps {
+		end := start + maxTxnOps
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+		_, err := b.C.Txn(ctx).Then(ops[start:end]...).Commit()
+		cancel()
+		if err != nil {
+			return errors.Wrapf(err, errExtendLease, fqdn, r.token.Lease)
+		}
+	}
+
+	return nil
+}
+
+// Used to get the hosts of a domain and its sub domains from the records of a renewal, the
+// records below path are the hosts of the domain and the directories of its sub domains
+// e.g. /rdnsv3/cloud/rancher/lb/sample/1_1_1_1, /rdnsv3/cloud/rancher/lb/sample/x1/2_2_2_2 => [1.1.1.1], {x1: [2.2.2.2]}
+func renewalHosts(records []*mvccpb.KeyValue, path string) ([]string, map[string][]string) {
+	hosts := make([]string, 0)
+	subs := make(map[string][]string, 0)
+
+	for _, kv := range records {
+		k := string(kv.Key)
+		if k == path {
+			continue
+		}
+		prefix := strings.Split(strings.TrimPrefix(k, path+"/"), "/")[0]
+
+		rec, err := codec.Decode(kv.Value)
+		if err != nil {
+			continue
+		}
+
+		if !strings.Contains(prefix, "_") {
+			if _, ok := subs[prefix]; !ok {
+				subs[prefix] = make([]string, 0)
+			}
+			if rec.Host != "" {
+				subs[prefix] = append(subs[prefix], rec.Host)
+			}
+			continue
+		}
+
+		if rec.Host != "" {
+			hosts = append(hosts, rec.Host)
+		}
+	}
+
+	return hosts, subs
+}
+
+// Used to check whether a TXT record is an ACME challenge record which has a short lease of its
+// own, see textLease, so a renewal does not move it to the lease of the domain
+func (b *Backend) ownsTextLease(key, path string) bool {
+	if b.ChallengeTTL <= 0 {
+		return false
+	}
+	for _, l := range strings.Split(strings.TrimPrefix(key, path+"/"), "/") {
+		if util.IsACMEChallenge(l + ".") {
+			return true
+		}
+	}
+	return false
+}
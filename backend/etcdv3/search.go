@@ -0,0 +1,398 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/codec"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	indexPath     = "/indexv3"
+	metaPath      = "/metav3"
+	indexHost     = "host"
+	indexLabel    = "label"
+	indexCreator  = "creator"
+	indexExpiry   = "expiry"
+	expiryPadding = 20
+)
+
+// meta keeps the attributes of a domain which are not part of its records,
+// and the index keys which were written for it.
+type meta struct {
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatorIP string            `json:"creatorIP,omitempty"`
+	Deadline  *time.Time        `json:"deadline,omitempty"`
+	Keys      []string          `json:"keys"`
+}
+
+// Search returns a page of domains which match all filters. Candidates are read from the
+// secondary indexes and verified against the records, the text filter is not indexed and
+// falls back to a scan of the zone when it is the only filter.
+func (b *Backend) Search(opts *model.SearchOptions) (l model.DomainList, err error) {
+	logrus.Debugf("search %s records with filters: %+v", typeA, opts)
+
+	if opts.Host == "" && opts.Label == "" && opts.CreatorIP == "" && opts.ExpiringBefore == nil && opts.ExpiringAfter == nil {
+		return b.scan(opts)
+	}
+
+	var after string
+	if opts.Continue != "" {
+		v, err := base64.RawURLEncoding.DecodeString(opts.Continue)
+		if err != nil {
+			return l, errors.Errorf(errInvalidContinue, opts.Continue)
+		}
+		after = string(v)
+	}
+
+	candidates, err := b.searchIndexes(opts)
+	if err != nil {
+		return l, err
+	}
+
+	items := make([]model.Domain, 0)
+	for _, fqdn := range candidates {
+		if fqdn <= after {
+			continue
+		}
+		if int64(len(items)) >= opts.Limit {
+			l.Continue = base64.RawURLEncoding.EncodeToString([]byte(items[len(items)-1].Fqdn))
+			break
+		}
+
+		d, ok := b.searchDomain(fqdn, opts)
+		if ok {
+			items = append(items, d)
+		}
+	}
+	l.Items = items
+
+	return l, nil
+}
+
+// Used to scan the zone page by page when no indexed filter is set, a page never
+// asks for more domains than still fit so the list continue token can be reused.
+func (b *Backend) scan(opts *model.SearchOptions) (l model.DomainList, err error) {
+	items := make([]model.Domain, 0)
+	cont := opts.Continue
+
+	for int64(len(items)) < opts.Limit {
+		page, err := b.List(&model.ListOptions{Limit: opts.Limit - int64(len(items)), Continue: cont})
+		if err != nil {
+			return l, err
+		}
+
+		for _, d := range page.Items {
+			if d, ok := b.searchDomain(d.Fqdn, opts); ok {
+				items = append(items, d)
+			}
+		}
+
+		cont = page.Continue
+		if cont == "" {
+			break
+		}
+	}
+
+	l.Items = items
+	l.Continue = cont
+
+	return l, nil
+}
+
+// Used to get the sorted fqdns which are found in every index of the filters
+func (b *Backend) searchIndexes(opts *model.SearchOptions) ([]string, error) {
+	var result map[string]bool
+
+	intersect := func(fqdns map[string]bool) {
+		if result == nil {
+			result = fqdns
+			return
+		}
+		for k := range result {
+			if !fqdns[k] {
+				delete(result, k)
+			}
+		}
+	}
+
+	filters := map[string]string{
+		indexHost:    opts.Host,
+		indexLabel:   opts.Label,
+		indexCreator: opts.CreatorIP,
+	}
+	for name, value := range filters {
+		if value == "" {
+			continue
+		}
+		prefix := b.indexKey(name, value, "")
+		fqdns, err := b.rangeIndex(prefix, clientv3.GetPrefixRangeEnd(prefix))
+		if err != nil {
+			return nil, err
+		}
+		intersect(fqdns)
+	}
+
+	if opts.ExpiringBefore != nil || opts.ExpiringAfter != nil {
+		start := b.indexKey(indexExpiry, "", "")
+		end := clientv3.GetPrefixRangeEnd(start)
+		if opts.ExpiringAfter != nil {
+			start = b.indexKey(indexExpiry, expiryValue(opts.ExpiringAfter.Unix()+1), "")
+		}
+		if opts.ExpiringBefore != nil {
+			end = b.indexKey(indexExpiry, expiryValue(opts.ExpiringBefore.Unix()), "")
+		}
+		fqdns, err := b.rangeIndex(start, end)
+		if err != nil {
+			return nil, err
+		}
+		intersect(fqdns)
+	}
+
+	ss := make([]string, 0, len(result))
+	for k := range result {
+		ss = append(ss, k)
+	}
+	sort.Strings(ss)
+
+	return ss, nil
+}
+
+// HostDomains returns the domains which have a record pointing at the host.
+func (b *Backend) HostDomains(host string) ([]string, error) {
+	prefix := b.indexKey(indexHost, host, "")
+	fqdns, err := b.rangeIndex(prefix, clientv3.GetPrefixRangeEnd(prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	ss := make([]string, 0, len(fqdns))
+	for k := range fqdns {
+		ss = append(ss, k)
+	}
+	sort.Strings(ss)
+
+	return ss, nil
+}
+
+func (b *Backend) rangeIndex(start, end string) (map[string]bool, error) {
+	fqdns := make(map[string]bool)
+	key := start
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+		resp, err := b.C.Get(ctx, key, clientv3.WithRange(end), clientv3.WithLimit(rangePageSize))
+		cancel()
+		if err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeIndex, start)
+		}
+
+		for _, v := range resp.Kvs {
+			// the indexes are shared by the root domains which share the prefix
+			if util.SlugWithZone(string(v.Value), b.Domain) == "" {
+				continue
+			}
+			fqdns[string(v.Value)] = true
+		}
+
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		key = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+
+	return fqdns, nil
+}
+
+// Used to read a candidate domain with its attributes and check it against the filters,
+// candidates which are deleted or changed after they were indexed are dropped.
+func (b *Backend) searchDomain(fqdn string, opts *model.SearchOptions) (model.Domain, bool) {
+	d, err := b.Get(&model.DomainOptions{Fqdn: fqdn})
+	if err != nil {
+		logrus.Debugf("skip search candidate %s: %v", fqdn, err)
+		return d, false
+	}
+
+	m, err := b.getMeta(fqdn)
+	if err != nil {
+		logrus.Debugf("skip search candidate %s: %v", fqdn, err)
+		return d, false
+	}
+	d.Labels = m.Labels
+	d.CreatorIP = m.CreatorIP
+
+	var texts []string
+	if opts.Text != "" {
+		if texts, err = b.lookupTexts(fqdn); err != nil {
+			logrus.Debugf("skip search candidate %s: %v", fqdn, err)
+			return d, false
+		}
+	}
+
+	return d, opts.Match(&d, texts)
+}
+
+// Used to lookup all TXT values of a domain and its sub domains
+func (b *Backend) lookupTexts(fqdn string) ([]string, error) {
+	path := b.getPath(fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeTXT, path)
+	}
+
+	texts := make([]string, 0)
+	for _, v := range resp.Kvs {
+		if rec, err := codec.Decode(v.Value); err == nil && rec.TXT {
+			texts = append(texts, rec.Text)
+		}
+	}
+
+	return texts, nil
+}
+
+// Used to rewrite the index keys of a domain, nil labels and an empty creator keep the
+// stored values. Index keys share the lease of the domain token so they expire together.
+func (b *Backend) setIndexes(d *model.Domain, labels map[string]string, creatorIP string) error {
+	logrus.Debugf("set %s for fqdn: %s", typeIndex, d.Fqdn)
+
+	m, err := b.getMeta(d.Fqdn)
+	if err != nil {
+		return err
+	}
+	if labels != nil {
+		m.Labels = labels
+	}
+	if creatorIP != "" {
+		m.CreatorIP = creatorIP
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	resp, err := b.C.Get(ctx, b.getTokenPath(d.Fqdn))
+	cancel()
+	if err != nil || resp.Count <= 0 {
+		return errors.Errorf(errEmptyRecord, typeToken, b.getTokenPath(d.Fqdn))
+	}
+	lease := clientv3.WithLease(clientv3.LeaseID(resp.Kvs[0].Lease))
+
+	keys := make(map[string]bool)
+	for _, v := range model.LabelValues(m.Labels) {
+		keys[b.indexKey(indexLabel, v, d.Fqdn)] = true
+	}
+	if m.CreatorIP != "" {
+		keys[b.indexKey(indexCreator, m.CreatorIP, d.Fqdn)] = true
+	}
+	if d.Expiration != nil {
+		keys[b.indexKey(indexExpiry, expiryValue(d.Expiration.Unix()), d.Fqdn)] = true
+	}
+
+	// host index keys are written with the host records, see syncRecords
+	hostPrefix := b.indexKey(indexHost, "", "")
+	ops := make([]clientv3.Op, 0)
+	for _, k := range m.Keys {
+		if !keys[k] && !strings.HasPrefix(k, hostPrefix) {
+			ops = append(ops, clientv3.OpDelete(k))
+		}
+	}
+	m.Keys = make([]string, 0, len(keys))
+	for k := range keys {
+		m.Keys = append(m.Keys, k)
+		ops = append(ops, clientv3.OpPut(k, d.Fqdn, lease))
+	}
+	sort.Strings(m.Keys)
+
+	v, err := json.Marshal(m)
+	if err != nil {
+		return errors.Wrapf(err, errSetIndexes, d.Fqdn)
+	}
+	ops = append(ops, clientv3.OpPut(b.metaKey(d.Fqdn), string(v), lease))
+
+	ctx, cancel = context.WithTimeout(context.Background(), rangeTimeout)
+	defer cancel()
+
+	if _, err := b.C.Txn(ctx).Then(ops...).Commit(); err != nil {
+		return errors.Wrapf(err, errSetIndexes, d.Fqdn)
+	}
+
+	return nil
+}
+
+func (b *Backend) deleteIndexes(fqdn string) error {
+	m, err := b.getMeta(fqdn)
+	if err != nil {
+		return err
+	}
+
+	ops := make([]clientv3.Op, 0, len(m.Keys)+1)
+	for _, k := range m.Keys {
+		ops = append(ops, clientv3.OpDelete(k))
+	}
+	ops = append(ops, clientv3.OpDelete(b.metaKey(fqdn)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+	defer cancel()
+
+	if _, err := b.C.Txn(ctx).Then(ops...).Commit(); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeIndex, fqdn)
+	}
+
+	return nil
+}
+
+func (b *Backend) getMeta(fqdn string) (*meta, error) {
+	key := b.metaKey(fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeIndex, key)
+	}
+
+	m := &meta{}
+	if resp.Count > 0 {
+		if err := json.Unmarshal(resp.Kvs[0].Value, m); err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeIndex, key)
+		}
+	}
+
+	return m, nil
+}
+
+// Used to get an index key, values are escaped as they may hold slashes
+// e.g. host, 1.1.1.1, sample.lb.rancher.cloud => /rdnsv3/indexv3/host/1.1.1.1/sample_lb_rancher_cloud
+// e.g. label, team=foo, "" => /rdnsv3/indexv3/label/team=foo/
+func (b *Backend) indexKey(name, value, fqdn string) string {
+	if value == "" {
+		return fmt.Sprintf("%s%s/%s/", b.Prefix, indexPath, name)
+	}
+	return fmt.Sprintf("%s%s/%s/%s/%s", b.Prefix, indexPath, name, url.PathEscape(value), formatKey(fqdn))
+}
+
+// Used to get the meta key of a domain
+// e.g. sample.lb.rancher.cloud => /rdnsv3/metav3/sample_lb_rancher_cloud
+func (b *Backend) metaKey(fqdn string) string {
+	return fmt.Sprintf("%s%s/%s", b.Prefix, metaPath, formatKey(fqdn))
+}
+
+// Used to get an expiry index value which sorts in time order
+// e.g. 1565077200 => 00000000001565077200
+func expiryValue(unix int64) string {
+	return fmt.Sprintf("%0*d", expiryPadding, unix)
+}
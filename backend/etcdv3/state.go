@@ -0,0 +1,106 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The operational state is kept without a lease by key, the etcd version of a key is the version
+// of its value, so writes compare it and a lost update is refused
+// e.g. /statev3/maintenance => {"key": "maintenance", "value": {"enabled": true}}
+const statePath = "/statev3"
+
+// GetOperationalState returns the state of the key, a key which is never written has version 0.
+func (b *Backend) GetOperationalState(key string) (model.OperationalState, error) {
+	s := model.OperationalState{Key: key}
+	path := b.stateKey(key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path)
+	if err != nil {
+		return s, errors.Wrapf(err, errLookupRecords, typeState, path)
+	}
+	if len(resp.Kvs) == 0 {
+		return s, nil
+	}
+
+	if err := json.Unmarshal(resp.Kvs[0].Value, &s); err != nil {
+		return s, errors.Wrapf(err, errLookupRecords, typeState, path)
+	}
+	s.Version = resp.Kvs[0].Version
+	return s, nil
+}
+
+// SetOperationalState writes the state when its version is still the current one, the version of
+// the written state is set to s.
+func (b *Backend) SetOperationalState(s *model.OperationalState) error {
+	logrus.Debugf("set operational state: %s", s.Key)
+
+	t := time.Now()
+	s.Updated = &t
+	value, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrapf(err, errSetRecord, typeState, s.Key)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	path := b.stateKey(s.Key)
+	txn, err := b.C.Txn(ctx).
+		If(clientv3.Compare(clientv3.Version(path), "=", s.Version)).
+		Then(clientv3.OpPut(path, string(value))).
+		Commit()
+	if err != nil {
+		return errors.Wrapf(err, errSetRecord, typeState, s.Key)
+	}
+	if !txn.Succeeded {
+		return errors.Wrapf(backend.ErrStateConflict, errSetRecord, typeState, s.Key)
+	}
+
+	s.Version++
+	return nil
+}
+
+func (b *Backend) ListOperationalStates() ([]model.OperationalState, error) {
+	path := fmt.Sprintf("%s%s/", b.Prefix, statePath)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeState, path)
+	}
+
+	result := make([]model.OperationalState, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		s := model.OperationalState{Key: strings.TrimPrefix(string(kv.Key), path)}
+		if err := json.Unmarshal(kv.Value, &s); err != nil {
+			logrus.Warnf("skip invalid %s record %s: %v", typeState, kv.Key, err)
+			continue
+		}
+		s.Version = kv.Version
+		result = append(result, s)
+	}
+
+	return result, nil
+}
+
+// Used to get the key of an operational state
+// e.g. maintenance => /rdnsv3/statev3/maintenance
+func (b *Backend) stateKey(key string) string {
+	return fmt.Sprintf("%s%s/%s", b.Prefix, statePath, key)
+}
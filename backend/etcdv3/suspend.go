@@ -0,0 +1,84 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const suspendPath = "/suspendv3"
+
+// Suspend keeps the suspension without a lease, it outlives the records of the domain
+// until it is removed by Unsuspend.
+func (b *Backend) Suspend(s *model.Suspension) error {
+	logrus.Debugf("suspend domain: %s", s.Fqdn)
+
+	if s.Time == nil {
+		t := time.Now()
+		s.Time = &t
+	}
+
+	value, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrapf(err, errSetRecord, typeSuspension, s.Fqdn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := b.C.Put(ctx, b.suspendKey(s.Fqdn), string(value)); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeSuspension, s.Fqdn)
+	}
+
+	return nil
+}
+
+func (b *Backend) Unsuspend(fqdn string) error {
+	logrus.Debugf("unsuspend domain: %s", fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := b.C.Delete(ctx, b.suspendKey(fqdn)); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeSuspension, fqdn)
+	}
+
+	return nil
+}
+
+func (b *Backend) ListSuspensions() ([]model.Suspension, error) {
+	path := fmt.Sprintf("%s%s/", b.Prefix, suspendPath)
+
+	ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeSuspension, path)
+	}
+
+	result := make([]model.Suspension, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var s model.Suspension
+		if err := json.Unmarshal(kv.Value, &s); err != nil {
+			logrus.Warnf("skip invalid %s record %s: %v", typeSuspension, kv.Key, err)
+			continue
+		}
+		result = append(result, s)
+	}
+
+	return result, nil
+}
+
+// Used to get the key of a suspension
+// e.g. sample.lb.rancher.cloud => /rdnsv3/suspendv3/sample_lb_rancher_cloud
+func (b *Backend) suspendKey(fqdn string) string {
+	return fmt.Sprintf("%s%s/%s", b.Prefix, suspendPath, formatKey(fqdn))
+}
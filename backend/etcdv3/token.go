@@ -0,0 +1,98 @@
+package etcdv3
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const scopedTokenPath = "/scopedtokenv3"
+
+// RotateToken replaces the token origin of a domain, the token keeps its lease so the
+// domain expires as before and the tokens derived from the old origin are refused.
+func (b *Backend) RotateToken(fqdn, token string) error {
+	logrus.Debugf("rotate %s record for fqdn: %s", typeToken, fqdn)
+
+	path := b.getTokenPath(fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path)
+	if err != nil {
+		return errors.Wrapf(err, errEmptyRecord, typeToken, path)
+	}
+
+	if resp.Count <= 0 {
+		return errors.Errorf(errEmptyRecord, typeToken, path)
+	}
+
+	leaseID := resp.Kvs[0].Lease
+	if _, err := b.C.Put(ctx, path, token, clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
+		return errors.Wrapf(err, errSetRecordWithLease, typeToken, path, leaseID)
+	}
+
+	return nil
+}
+
+// SetScopedToken keeps the origin of a secondary token of a domain with the lease of the domain token,
+// so the secondary token expires with the domain. An origin of the same scope is replaced.
+func (b *Backend) SetScopedToken(fqdn, scope, origin string) error {
+	logrus.Debugf("set %s %s record for fqdn: %s", scope, typeToken, fqdn)
+
+	leaseID, _, err := b.setToken(&model.DomainOptions{Fqdn: fqdn}, true)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := b.scopedTokenKey(fqdn, scope)
+	if _, err := b.C.Put(ctx, key, origin, clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
+		return errors.Wrapf(err, errSetRecordWithLease, typeToken, key, leaseID)
+	}
+
+	return nil
+}
+
+func (b *Backend) GetScopedToken(fqdn, scope string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := b.scopedTokenKey(fqdn, scope)
+	resp, err := b.C.Get(ctx, key)
+	if err != nil {
+		return "", errors.Wrapf(err, errLookupRecords, typeToken, key)
+	}
+	if resp.Count == 0 {
+		return "", errors.Errorf(errEmptyRecord, typeToken, key)
+	}
+
+	return string(resp.Kvs[0].Value), nil
+}
+
+func (b *Backend) DeleteScopedToken(fqdn, scope string) error {
+	logrus.Debugf("delete %s %s record for fqdn: %s", scope, typeToken, fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := b.scopedTokenKey(fqdn, scope)
+	if _, err := b.C.Delete(ctx, key); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeToken, key)
+	}
+
+	return nil
+}
+
+// Used to get the key of a secondary token of a domain, they are kept apart from the tokens so they are not counted
+// e.g. sample.lb.rancher.cloud, read-only => /rdnsv3/scopedtokenv3/sample_lb_rancher_cloud/read-only
+func (b *Backend) scopedTokenKey(fqdn, scope string) string {
+	return fmt.Sprintf("%s%s/%s/%s", b.Prefix, scopedTokenPath, formatKey(fqdn), scope)
+}
@@ -0,0 +1,94 @@
+package etcdv3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/rancher/rdns-server/codec"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const ttlPath = "/ttlv3"
+
+// SetTTL keeps the ttl of a domain with the lease of the domain token and rewrites the host
+// records of the domain and its sub domains with it, 0 restores the default ttl.
+func (b *Backend) SetTTL(fqdn string, ttl uint32) (d model.Domain, err error) {
+	logrus.Debugf("set %s of domain %s to %d", typeTTL, fqdn, ttl)
+
+	if _, err := b.Get(&model.DomainOptions{Fqdn: fqdn}); err != nil {
+		return d, err
+	}
+
+	leaseID, _, err := b.setToken(&model.DomainOptions{Fqdn: fqdn}, true)
+	if err != nil {
+		return d, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := b.ttlKey(fqdn)
+	if ttl == 0 {
+		_, err = b.C.Delete(ctx, key)
+	} else {
+		_, err = b.C.Put(ctx, key, strconv.FormatUint(uint64(ttl), 10), clientv3.WithLease(clientv3.LeaseID(leaseID)))
+	}
+	if err != nil {
+		return d, errors.Wrapf(err, errSetRecordWithLease, typeTTL, key, leaseID)
+	}
+
+	path := b.getPath(fqdn)
+	rctx, rcancel := context.WithTimeout(context.Background(), rangeTimeout)
+	defer rcancel()
+
+	resp, err := b.C.Get(rctx, path+"/", clientv3.WithPrefix())
+	if err != nil {
+		return d, errors.Wrapf(err, errLookupRecords, typeA, path)
+	}
+
+	// records keep their own lease, TXT records keep the default ttl
+	for _, kv := range resp.Kvs {
+		rec, err := codec.Decode(kv.Value)
+		if err != nil || rec.Host == "" || rec.TTL == ttl {
+			continue
+		}
+		rec.TTL = ttl
+		if _, err := b.C.Put(rctx, string(kv.Key), b.encode(rec), clientv3.WithLease(clientv3.LeaseID(kv.Lease))); err != nil {
+			return d, errors.Wrapf(err, errSetRecordWithLease, typeA, kv.Key, kv.Lease)
+		}
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
+
+// Used to get the ttl of a domain, 0 means the default ttl
+func (b *Backend) getTTL(fqdn string) (uint32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	key := b.ttlKey(fqdn)
+	resp, err := b.C.Get(ctx, key)
+	if err != nil {
+		return 0, errors.Wrapf(err, errLookupRecords, typeTTL, key)
+	}
+	if resp.Count == 0 {
+		return 0, nil
+	}
+
+	ttl, err := strconv.ParseUint(string(resp.Kvs[0].Value), 10, 32)
+	if err != nil {
+		return 0, errors.Wrapf(err, errLookupRecords, typeTTL, key)
+	}
+	return uint32(ttl), nil
+}
+
+// Used to get the key of the ttl of a domain
+// e.g. sample.lb.rancher.cloud => /rdnsv3/ttlv3/sample_lb_rancher_cloud
+func (b *Backend) ttlKey(fqdn string) string {
+	return fmt.Sprintf("%s%s/%s", b.Prefix, ttlPath, formatKey(fqdn))
+}
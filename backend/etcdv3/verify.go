@@ -0,0 +1,81 @@
+package etcdv3
+
+import (
+	"context"
+
+	"github.com/rancher/rdns-server/codec"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Verification is what Verify finds among the records of a root domain.
+type Verification struct {
+	Checked int `json:"checked"`
+	// Missing are the records which are written without a checksum, by an earlier version or by hand
+	Missing int `json:"missing"`
+	// Sealed are the missing ones whose checksum is written
+	Sealed int `json:"sealed"`
+	// Corrupt are the keys of the records which do not decode or do not match their checksum
+	Corrupt []string `json:"corrupt"`
+}
+
+// Verify decodes every record of the root domain page by page and checks it against its checksum.
+// The corrupt records are only reported, seal writes the checksums of the records which have none
+// unless they are changed meanwhile.
+func (b *Backend) Verify(seal bool) (Verification, error) {
+	root := getPath(b.Prefix, b.Domain) + "/"
+	end := clientv3.GetPrefixRangeEnd(root)
+	key := root
+	v := Verification{Corrupt: make([]string, 0)}
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+		resp, err := b.C.Get(ctx, key, clientv3.WithRange(end), clientv3.WithLimit(rangePageSize))
+		cancel()
+		if err != nil {
+			return v, errors.Wrapf(err, errLookupRecords, typeA, root)
+		}
+
+		for _, kv := range resp.Kvs {
+			v.Checked++
+			rec, err := codec.Decode(kv.Value)
+			if err == nil {
+				err = rec.Verify()
+			}
+			if err == nil {
+				continue
+			}
+			if err != codec.ErrNoChecksum {
+				logrus.Errorf("record %s is corrupt: %v", kv.Key, err)
+				v.Corrupt = append(v.Corrupt, string(kv.Key))
+				continue
+			}
+
+			v.Missing++
+			if !seal {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), rangeTimeout)
+			txn, err := b.C.Txn(ctx).
+				If(clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision)).
+				Then(clientv3.OpPut(string(kv.Key), b.encode(rec), clientv3.WithLease(clientv3.LeaseID(kv.Lease)))).
+				Commit()
+			cancel()
+			if err != nil {
+				return v, errors.Wrapf(err, errSetRecord, typeA, kv.Key)
+			}
+			if txn.Succeeded {
+				v.Sealed++
+			}
+		}
+
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		key = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+
+	return v, nil
+}
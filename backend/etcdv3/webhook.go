@@ -0,0 +1,91 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const webhookPath = "/webhookv3"
+
+// SetWebhook keeps the webhook with the lease of the domain token, so webhooks
+// are renewed and expire together with their domain.
+func (b *Backend) SetWebhook(w *model.Webhook) error {
+	logrus.Debugf("set webhook %s for domain: %s", w.ID, w.Fqdn)
+
+	leaseID, _, err := b.setToken(&model.DomainOptions{Fqdn: w.Fqdn}, true)
+	if err != nil {
+		return err
+	}
+
+	if w.Time == nil {
+		t := time.Now()
+		w.Time = &t
+	}
+
+	value, err := json.Marshal(w)
+	if err != nil {
+		return errors.Wrapf(err, errSetRecord, typeWebhook, w.Fqdn)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	path := b.webhookKey(w.Fqdn, w.ID)
+	if _, err := b.C.Put(ctx, path, string(value), clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
+		return errors.Wrapf(err, errSetRecordWithLease, typeWebhook, path, leaseID)
+	}
+
+	return nil
+}
+
+func (b *Backend) ListWebhooks(fqdn string) ([]model.Webhook, error) {
+	path := b.webhookKey(fqdn, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeWebhook, path)
+	}
+
+	result := make([]model.Webhook, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var w model.Webhook
+		if err := json.Unmarshal(kv.Value, &w); err != nil {
+			logrus.Warnf("skip invalid %s record %s: %v", typeWebhook, kv.Key, err)
+			continue
+		}
+		result = append(result, w)
+	}
+
+	return result, nil
+}
+
+func (b *Backend) DeleteWebhook(fqdn, id string) error {
+	logrus.Debugf("delete webhook %s for domain: %s", id, fqdn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	path := b.webhookKey(fqdn, id)
+	if _, err := b.C.Delete(ctx, path); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeWebhook, path)
+	}
+
+	return nil
+}
+
+// Used to get the key of a webhook, an empty id returns the prefix of all webhooks of the domain
+// e.g. sample.lb.rancher.cloud, 3kq8d1zc => /rdnsv3/webhookv3/sample_lb_rancher_cloud/3kq8d1zc
+func (b *Backend) webhookKey(fqdn, id string) string {
+	return fmt.Sprintf("%s%s/%s/%s", b.Prefix, webhookPath, formatKey(fqdn), id)
+}
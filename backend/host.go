@@ -0,0 +1,123 @@
+package backend
+
+import (
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ReplaceHost replaces the host from with the host to in every domain which points at it,
+// the domains are found with the host index. A domain which fails to update is reported and
+// does not stop the others, so the replacement can be repeated until nothing is left.
+func ReplaceHost(b Backend, from, to string) (model.HostReplace, error) {
+	r := model.HostReplace{
+		From:     from,
+		To:       to,
+		Replaced: make([]string, 0),
+		Failed:   make(map[string]string),
+	}
+
+	fqdns, err := b.HostDomains(from)
+	if err != nil {
+		return r, err
+	}
+
+	for _, fqdn := range fqdns {
+		if err := replaceDomainHost(b, fqdn, from, to); err != nil {
+			logrus.Error(err)
+			r.Failed[fqdn] = err.Error()
+			continue
+		}
+		r.Replaced = append(r.Replaced, fqdn)
+	}
+
+	logrus.Infof("replaced host %s with %s in %d domains, %d failed", from, to, len(r.Replaced), len(r.Failed))
+
+	return r, nil
+}
+
+// RemoveHost removes the host from every domain which points at it, as ReplaceHost does. The last
+// host of a domain is kept and the domain is reported as failed, it would not resolve at all.
+func RemoveHost(b Backend, host string) (model.HostReplace, error) {
+	r := model.HostReplace{
+		From:     host,
+		Replaced: make([]string, 0),
+		Failed:   make(map[string]string),
+	}
+
+	fqdns, err := b.HostDomains(host)
+	if err != nil {
+		return r, err
+	}
+
+	for _, fqdn := range fqdns {
+		if err := removeDomainHost(b, fqdn, host); err != nil {
+			logrus.Error(err)
+			r.Failed[fqdn] = err.Error()
+			continue
+		}
+		r.Replaced = append(r.Replaced, fqdn)
+	}
+
+	logrus.Infof("removed host %s from %d domains, %d failed", host, len(r.Replaced), len(r.Failed))
+
+	return r, nil
+}
+
+func removeDomainHost(b Backend, fqdn, host string) error {
+	d, err := b.Get(&model.DomainOptions{Fqdn: fqdn})
+	if err != nil {
+		return errors.Wrapf(err, errRemoveHost, host, fqdn)
+	}
+	if len(d.Hosts) == 1 && d.Hosts[0] == host {
+		return errors.Errorf(errRemoveLastHost, host, fqdn)
+	}
+
+	// the patch only removes the host, the hosts added by other writers meanwhile are kept
+	if _, err := b.PatchHosts(fqdn, nil, []string{host}); err != nil {
+		return errors.Wrapf(err, errRemoveHost, host, fqdn)
+	}
+
+	return nil
+}
+
+func replaceDomainHost(b Backend, fqdn, from, to string) error {
+	d, err := b.Get(&model.DomainOptions{Fqdn: fqdn})
+	if err != nil {
+		return errors.Wrapf(err, errReplaceHost, from, fqdn)
+	}
+
+	opts := &model.DomainOptions{
+		Fqdn:      fqdn,
+		Hosts:     replaceHosts(d.Hosts, from, to),
+		SubDomain: make(map[string][]string, len(d.SubDomain)),
+	}
+	for k, v := range d.SubDomain {
+		opts.SubDomain[k] = replaceHosts(v, from, to)
+	}
+
+	if _, err := b.Update(opts); err != nil {
+		return errors.Wrapf(err, errReplaceHost, from, fqdn)
+	}
+
+	return nil
+}
+
+// Used to replace a host in a host list, the result keeps the order and has no duplicates
+// e.g. [1.1.1.1 2.2.2.2], 1.1.1.1 => 2.2.2.2: [2.2.2.2]
+func replaceHosts(hosts []string, from, to string) []string {
+	seen := make(map[string]bool, len(hosts))
+	result := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if h == from {
+			h = to
+		}
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		result = append(result, h)
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetAPIKey keeps the api key without an expiration, it is kept until it is revoked.
+func (b *Backend) SetAPIKey(k *model.APIKey) error {
+	logrus.Debugf("set api key: %s", k.ID)
+
+	if k.CreatedOn == nil {
+		t := time.Now()
+		k.CreatedOn = &t
+	}
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	b.s.apiKeys[k.ID] = *k
+
+	return nil
+}
+
+func (b *Backend) GetAPIKey(id string) (model.APIKey, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	k, ok := b.s.apiKeys[id]
+	if !ok {
+		return k, errors.Errorf(errEmptyRecord, typeAPIKey, id)
+	}
+
+	return k, nil
+}
+
+func (b *Backend) ListAPIKeys() ([]model.APIKey, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	result := make([]model.APIKey, 0, len(b.s.apiKeys))
+	for _, k := range b.s.apiKeys {
+		result = append(result, k)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
+
+func (b *Backend) DeleteAPIKey(id string) error {
+	logrus.Debugf("delete api key: %s", id)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	delete(b.s.apiKeys, id)
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetCAA replaces the CAA records of a domain, the records are kept in their order and are
+// deleted together with their domain.
+func (b *Backend) SetCAA(fqdn string, records []model.CAARecord) error {
+	logrus.Debugf("set %s records for domain: %s", typeCAA, fqdn)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		return errors.Errorf(errEmptyRecord, typeToken, fqdn)
+	}
+	e.caa = append([]model.CAARecord{}, records...)
+
+	return nil
+}
+
+func (b *Backend) GetCAA(fqdn string) ([]model.CAARecord, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	e, ok := b.s.live(fqdn)
+	if !ok || len(e.caa) == 0 {
+		return nil, errors.Errorf(errEmptyRecord, typeCAA, fqdn)
+	}
+
+	return append([]model.CAARecord{}, e.caa...), nil
+}
+
+func (b *Backend) DeleteCAA(fqdn string) error {
+	logrus.Debugf("delete %s records for domain: %s", typeCAA, fqdn)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	if e, ok := b.s.domains[fqdn]; ok {
+		e.caa = nil
+	}
+
+	return nil
+}
+
+// SetQueryTypes keeps the query types a domain is restricted to, no types lift the restriction.
+func (b *Backend) SetQueryTypes(fqdn string, types []string) error {
+	logrus.Debugf("set %s of domain %s to %v", typeQueryTypes, fqdn, types)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		return errors.Errorf(errEmptyRecord, typeToken, fqdn)
+	}
+	if len(types) == 0 {
+		e.queryTypes = nil
+	} else {
+		e.queryTypes = append([]string{}, types...)
+	}
+
+	return nil
+}
+
+func (b *Backend) GetQueryTypes(fqdn string) ([]string, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	e, ok := b.s.live(fqdn)
+	if !ok || len(e.queryTypes) == 0 {
+		return nil, nil
+	}
+
+	return append([]string{}, e.queryTypes...), nil
+}
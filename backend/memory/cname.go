@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"net"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The CNAME target is kept with the domain, a domain with a target has no hosts.
+
+func (b *Backend) SetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("set %s record for domain options: %s", typeCNAME, opts.String())
+
+	if err := checkCNAME(opts.CNAME); err != nil {
+		return d, err
+	}
+
+	// the target is all a CNAME domain answers, the hosts of the options are not written
+	c := &model.DomainOptions{Fqdn: opts.Fqdn, Name: opts.Name, Lease: opts.Lease, Labels: opts.Labels, CreatorIP: opts.CreatorIP}
+	if err := b.create(c, opts.CNAME); err != nil {
+		return d, err
+	}
+	opts.Fqdn = c.Fqdn
+
+	return b.GetCNAME(opts)
+}
+
+func (b *Backend) GetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("get %s record for domain options: %s", typeCNAME, opts.String())
+
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	e, ok := b.s.live(opts.Fqdn)
+	if !ok || e.cname == "" {
+		return d, errors.Errorf(errEmptyRecord, typeCNAME, opts.Fqdn)
+	}
+
+	exp := e.expiration
+	d.Fqdn = opts.Fqdn
+	d.CNAME = e.cname
+	d.Expiration = &exp
+	d.Labels = copyLabels(e.labels)
+
+	return d, nil
+}
+
+func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("update %s record for domain options: %s", typeCNAME, opts.String())
+
+	if err := checkCNAME(opts.CNAME); err != nil {
+		return d, err
+	}
+
+	b.s.mu.Lock()
+	e, ok := b.s.live(opts.Fqdn)
+	if !ok || e.cname == "" {
+		b.s.mu.Unlock()
+		return d, errors.Errorf(errEmptyRecord, typeCNAME, opts.Fqdn)
+	}
+	e.cname = opts.CNAME
+	b.s.mu.Unlock()
+
+	return b.GetCNAME(opts)
+}
+
+func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
+	logrus.Debugf("delete %s record for domain options: %s", typeCNAME, opts.String())
+
+	if _, err := b.GetCNAME(opts); err != nil {
+		return err
+	}
+
+	b.s.mu.Lock()
+	b.s.remove(opts.Fqdn)
+	b.s.mu.Unlock()
+
+	return nil
+}
+
+// an IP target would be answered as an A record instead of a CNAME
+func checkCNAME(target string) error {
+	if target == "" || net.ParseIP(target) != nil {
+		return errors.Errorf(errNotValidCNAME, target)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package memory
+
+const (
+	errEmptyRecord        = "failed to found %s record: %s"
+	errExistSlug          = "slug name %s can not be used, try another"
+	errNoLookupResults    = "no lookup results for %s record: %s"
+	errNoDrainHost        = "host %s is not a host of domain %s"
+	errNotValidDomainName = "not valid domain name: %s"
+	errNotValidCNAME      = "not valid CNAME target: %s"
+	errInvalidRootDomains = "invalid root domains: %s"
+	errInvalidExpiration  = "expiration %s is not in the future"
+	errInvalidContinue    = "invalid continue token: %s"
+	errSetRecord          = "failed to set %s record: %s"
+	errRequestName        = "failed to request name %s"
+)
@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The jobs are kept by kind and claimed in the order of their id, which starts with the time they
+// are queued at.
+
+func (b *Backend) EnqueueJob(j *model.Job) error {
+	logrus.Debugf("enqueue %s job: %s", j.Kind, j.ID)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	jobs, ok := b.s.jobs[j.Kind]
+	if !ok {
+		jobs = make(map[string]model.Job)
+		b.s.jobs[j.Kind] = jobs
+	}
+	c := *j
+	c.Payload = append([]byte{}, j.Payload...)
+	jobs[j.ID] = c
+
+	return nil
+}
+
+// ClaimJobs hides the visible jobs until the visibility timeout, the jobs are read and claimed
+// under one lock so a job is never claimed by two workers at once.
+func (b *Backend) ClaimJobs(kind string, limit int, visibility time.Duration) ([]model.Job, error) {
+	now := time.Now()
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	ids := make([]string, 0)
+	for id, j := range b.s.jobs[kind] {
+		if !j.Visible.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	result := make([]model.Job, 0, len(ids))
+	for _, id := range ids {
+		j := b.s.jobs[kind][id]
+		j.Attempts++
+		j.Visible = now.Add(visibility)
+		b.s.jobs[kind][id] = j
+		result = append(result, j)
+	}
+
+	return result, nil
+}
+
+func (b *Backend) DeleteJob(kind, id string) error {
+	logrus.Debugf("delete %s job: %s", kind, id)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	delete(b.s.jobs[kind], id)
+
+	return nil
+}
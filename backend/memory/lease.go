@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Extend gives a domain the longer lease time, which renewals keep from then on, and moves its
+// expiration to the lease time from now. It returns false when the domain already has a lease
+// time which is as long, or is a temporary domain.
+func (b *Backend) Extend(fqdn string, leaseTime time.Duration) (bool, error) {
+	logrus.Debugf("extend lease of fqdn %s to %s", fqdn, leaseTime)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		return false, errors.Errorf(errEmptyRecord, typeToken, fqdn)
+	}
+	if e.lease >= leaseTime || !e.deadline.IsZero() {
+		return false, nil
+	}
+
+	e.lease = leaseTime
+	e.expiration = time.Now().Add(leaseTime)
+	b.s.schedule(e)
+
+	return true, nil
+}
+
+// Keepalive moves the expiration of a domain like Renew without reading the domain back.
+func (b *Backend) Keepalive(fqdn string) (time.Time, error) {
+	logrus.Debugf("keepalive lease of fqdn %s", fqdn)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		return time.Time{}, errors.Errorf(errNoLookupResults, typeA, fqdn)
+	}
+	e.expiration = time.Now().Add(e.lease)
+	if !e.deadline.IsZero() && e.expiration.After(e.deadline) {
+		e.expiration = e.deadline
+	}
+	b.s.schedule(e)
+
+	return e.expiration, nil
+}
+
+// SetExpiration moves the expiration of a domain to until, sooner or later than the one it has.
+// Renewals keep the lease time up to until from then on.
+func (b *Backend) SetExpiration(fqdn string, until time.Time) (d model.Domain, err error) {
+	logrus.Debugf("set expiration of fqdn %s to %s", fqdn, until.Format(time.RFC3339))
+
+	lease := time.Until(until)
+	if lease <= 0 {
+		return d, errors.Errorf(errInvalidExpiration, until.Format(time.RFC3339))
+	}
+
+	b.s.mu.Lock()
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		b.s.mu.Unlock()
+		return d, errors.Errorf(errNoLookupResults, typeA, fqdn)
+	}
+	e.lease = lease
+	e.expiration = until
+	b.s.schedule(e)
+	b.s.mu.Unlock()
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
+
+// SetDeadline keeps the absolute expiry of a temporary domain, its expiration is moved to the
+// deadline when it is later and renewals never move it past the deadline.
+func (b *Backend) SetDeadline(fqdn string, until time.Time) (d model.Domain, err error) {
+	logrus.Debugf("set deadline of fqdn %s to %s", fqdn, until.Format(time.RFC3339))
+
+	if !until.After(time.Now()) {
+		return d, errors.Errorf(errInvalidExpiration, until.Format(time.RFC3339))
+	}
+
+	b.s.mu.Lock()
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		b.s.mu.Unlock()
+		return d, errors.Errorf(errNoLookupResults, typeA, fqdn)
+	}
+	e.deadline = until
+	if e.expiration.After(until) {
+		e.expiration = until
+		b.s.schedule(e)
+	}
+	b.s.mu.Unlock()
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"encoding/base64"
+	"sort"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// List returns a page of domains of the zone in the order of their fqdn, the continue token is the
+// last fqdn of the page so the pages see the domains which are written in between.
+func (b *Backend) List(opts *model.ListOptions) (l model.DomainList, err error) {
+	logrus.Debugf("list %s records with limit %d", typeA, opts.Limit)
+
+	return b.page(opts, func(e *domain) bool { return true }, false)
+}
+
+// HostDomains returns the domains which have a record pointing at the host.
+func (b *Backend) HostDomains(host string) ([]string, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	fqdns := make([]string, 0)
+	for _, e := range b.domains("") {
+		if e.hasHost(host) {
+			fqdns = append(fqdns, e.fqdn)
+		}
+	}
+
+	return fqdns, nil
+}
+
+// Used to read a page of the domains which match, with their labels when labels is true. A limit
+// of 0 reads all of them.
+func (b *Backend) page(opts *model.ListOptions, match func(e *domain) bool, labels bool) (l model.DomainList, err error) {
+	after, err := decodeContinue(opts.Continue)
+	if err != nil {
+		return l, err
+	}
+
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	items := make([]model.Domain, 0)
+	for _, e := range b.domains(after) {
+		if !match(e) {
+			continue
+		}
+		if opts.Limit > 0 && int64(len(items)) == opts.Limit {
+			l.Continue = encodeContinue(items[len(items)-1].Fqdn)
+			break
+		}
+		d := e.toDomain()
+		if labels && len(e.labels) > 0 {
+			d.Labels = copyLabels(e.labels)
+		}
+		items = append(items, d)
+	}
+	l.Items = items
+
+	return l, nil
+}
+
+// Used to get the domains of the zone after a fqdn in the order of their fqdn, domains whose
+// expiration is over are left out. The lock is held.
+func (b *Backend) domains(after string) []*domain {
+	result := make([]*domain, 0)
+	for fqdn, e := range b.s.domains {
+		if _, ok := b.s.live(fqdn); !ok || e.zone != b.Domain || fqdn <= after {
+			continue
+		}
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].fqdn < result[j].fqdn })
+
+	return result
+}
+
+// Used to check whether the domain or one of its sub domains has a host, the lock is held
+func (e *domain) hasHost(host string) bool {
+	for _, hosts := range e.hosts {
+		for _, h := range hosts {
+			if h == host {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func encodeContinue(fqdn string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fqdn))
+}
+
+// Used to decode the fqdn the page continues after, an empty token starts at the first domain
+func decodeContinue(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	v, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return "", errors.Errorf(errInvalidContinue, s)
+	}
+	return string(v), nil
+}
@@ -0,0 +1,555 @@
+package memory
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/config"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Name             = "memory"
+	typeA            = "A"
+	typeTXT          = "TXT"
+	typeCNAME        = "CNAME"
+	typeCAA          = "CAA"
+	typeQueryTypes   = "QUERYTYPES"
+	typeToken        = "TOKEN"
+	typeFrozen       = "FROZEN"
+	typeTTL          = "TTL"
+	typeDrain        = "DRAIN"
+	typeAPIKey       = "APIKEY"
+	typeState        = "STATE"
+	maxSlugHashTimes = 100
+	tokenLength      = 32
+)
+
+// Backend keeps the domains with their hosts, txt records and tokens in the memory of the process,
+// everything is lost when the process stops. A domain is deleted by a timer once its expiration is
+// over, reads leave it out before the timer fires.
+type Backend struct {
+	Domain    string
+	FrozenTTL time.Duration
+	LeaseTime time.Duration
+	// ChallengeTTL is the lease time of ACME challenge TXT records, 0 means the domain lease
+	ChallengeTTL time.Duration
+
+	s *store
+}
+
+// store keeps the records of every root domain, the backends of the root domains share it.
+type store struct {
+	mu          sync.RWMutex
+	domains     map[string]*domain
+	frozen      map[string]time.Time
+	suspensions map[string]model.Suspension
+	apiKeys     map[string]model.APIKey
+	jobs        map[string]map[string]model.Job
+	states      map[string]model.OperationalState
+	migrations  map[string]time.Time
+}
+
+// domain is a domain with every record which is deleted with it
+type domain struct {
+	fqdn       string
+	zone       string
+	token      string
+	cname      string
+	creatorIP  string
+	ttl        uint32
+	lease      time.Duration
+	expiration time.Time
+	// deadline is zero unless the domain is a temporary domain
+	deadline time.Time
+	// hosts are kept by the fqdn of the domain or of a sub domain
+	hosts map[string][]string
+	// drained are the times the hosts of the domain and its sub domains are drained until
+	drained map[string]time.Time
+	// texts are kept by fqdn and order, see textKey
+	texts  map[string]*text
+	labels map[string]string
+	caa    []model.CAARecord
+	// queryTypes are the record types the domain is restricted to, nil means every type
+	queryTypes []string
+	scoped     map[string]string
+	webhooks   map[string]model.Webhook
+	timer      *time.Timer
+}
+
+type text struct {
+	content string
+	// expiration is zero when the record expires with its domain
+	expiration time.Time
+}
+
+func init() {
+	backend.Register(Name, func(cfg *backend.Config) (backend.Backend, error) {
+		return NewBackend()
+	})
+}
+
+func NewBackend() (*Backend, error) {
+	leaseTime, err := time.ParseDuration(os.Getenv("MEMORY_LEASE_TIME"))
+	if err != nil {
+		return nil, err
+	}
+	frozen, err := time.ParseDuration(os.Getenv("FROZEN"))
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := time.ParseDuration(os.Getenv("ACME_TXT_TTL"))
+	if err != nil {
+		return nil, err
+	}
+
+	roots := util.RootDomains(os.Getenv("DOMAIN"))
+	if len(roots) == 0 {
+		return nil, errors.Errorf(errInvalidRootDomains, os.Getenv("DOMAIN"))
+	}
+
+	return &Backend{
+		Domain:    roots[0],
+		FrozenTTL: frozen,
+		LeaseTime: leaseTime,
+
+		ChallengeTTL: challenge,
+
+		s: &store{
+			domains:     make(map[string]*domain),
+			frozen:      make(map[string]time.Time),
+			suspensions: make(map[string]model.Suspension),
+			apiKeys:     make(map[string]model.APIKey),
+			jobs:        make(map[string]map[string]model.Job),
+			states:      make(map[string]model.OperationalState),
+			migrations:  make(map[string]time.Time),
+		},
+	}, nil
+}
+
+// ForRoot returns a backend of another root domain which shares the records, the domains of
+// every root domain are told apart by their zone.
+func (b *Backend) ForRoot(root string) *Backend {
+	r := *b
+	r.Domain = root
+	return &r
+}
+
+func (b *Backend) GetName() string {
+	return Name
+}
+
+func (b *Backend) GetZone() string {
+	return b.Domain
+}
+
+func (b *Backend) Ping() error {
+	return nil
+}
+
+func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("get %s record for domain options: %s", typeA, opts.String())
+
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	base, ok := b.s.live(b.baseOf(opts.Fqdn))
+	if !ok {
+		return d, errors.Errorf(errNoLookupResults, typeA, opts.Fqdn)
+	}
+	d = base.toDomain()
+
+	if opts.Fqdn == base.fqdn {
+		return d, nil
+	}
+
+	// a sub domain is answered with its own hosts, like its path of the etcdv3 backend
+	hosts, ok := d.SubDomain[strings.TrimSuffix(opts.Fqdn, "."+base.fqdn)]
+	if !ok {
+		return model.Domain{}, errors.Errorf(errNoLookupResults, typeA, opts.Fqdn)
+	}
+	sub := model.Domain{Fqdn: opts.Fqdn, Hosts: hosts, SubDomain: map[string][]string{}, TTL: d.TTL, Expiration: d.Expiration, Deadline: d.Deadline}
+	for _, h := range hosts {
+		if until, ok := d.Drained[h]; ok {
+			if sub.Drained == nil {
+				sub.Drained = make(map[string]*time.Time)
+			}
+			sub.Drained[h] = until
+		}
+	}
+	return sub, nil
+}
+
+func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("set %s record for domain options: %s", typeA, opts.String())
+
+	if err := b.create(opts, ""); err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: opts.Fqdn})
+}
+
+func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("update %s record for domain options: %s", typeA, opts.String())
+
+	b.s.mu.Lock()
+	e, ok := b.s.live(opts.Fqdn)
+	if !ok {
+		b.s.mu.Unlock()
+		return d, errors.Errorf(errNoLookupResults, typeA, opts.Fqdn)
+	}
+	e.setHosts(opts)
+	if opts.Labels != nil {
+		e.labels = copyLabels(opts.Labels)
+	}
+	b.s.mu.Unlock()
+
+	return b.Get(&model.DomainOptions{Fqdn: opts.Fqdn})
+}
+
+// PatchHosts adds and removes the hosts of a domain or a sub domain at once, concurrent patches of
+// other hosts are not lost.
+func (b *Backend) PatchHosts(fqdn string, add, remove []string) (d model.Domain, err error) {
+	logrus.Debugf("patch %s record for domain %s: add %v, remove %v", typeA, fqdn, add, remove)
+
+	b.s.mu.Lock()
+	e, ok := b.s.live(b.baseOf(fqdn))
+	if !ok {
+		b.s.mu.Unlock()
+		return d, errors.Errorf(errNoLookupResults, typeA, fqdn)
+	}
+	removed := sliceToMap(remove)
+	hosts := make([]string, 0)
+	for _, h := range e.hosts[fqdn] {
+		if !removed[h] {
+			hosts = append(hosts, h)
+		}
+	}
+	e.putHosts(fqdn, append(hosts, add...))
+	b.s.mu.Unlock()
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
+
+// Delete deletes the domain with all of its records, the frozen slug is kept until it expires. A
+// sub domain only loses its hosts.
+func (b *Backend) Delete(opts *model.DomainOptions) error {
+	logrus.Debugf("delete %s record for domain options: %s", typeA, opts.String())
+
+	if _, err := b.Get(opts); err != nil {
+		return err
+	}
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	base := b.baseOf(opts.Fqdn)
+	if opts.Fqdn != base {
+		if e, ok := b.s.domains[base]; ok {
+			delete(e.hosts, opts.Fqdn)
+		}
+		return nil
+	}
+	b.s.remove(base)
+
+	return nil
+}
+
+// Renew moves the expiration of a domain to the lease time of the domain from now, the lease
+// time is the one the domain is created or last extended with. A temporary domain is renewed up
+// to its deadline at most.
+func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("renew %s record for domain options: %s", typeA, opts.String())
+
+	if _, err := b.Keepalive(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: opts.Fqdn})
+}
+
+func (b *Backend) GetToken(fqdn string) (string, error) {
+	logrus.Debugf("get %s record for fqdn: %s", typeToken, fqdn)
+
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		return "", errors.Errorf(errEmptyRecord, typeToken, fqdn)
+	}
+
+	return e.token, nil
+}
+
+func (b *Backend) GetTokenCount() (int64, error) {
+	logrus.Debugf("get %s record count", typeToken)
+
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	var count int64
+	for fqdn, e := range b.s.domains {
+		if _, ok := b.s.live(fqdn); ok && e.zone == b.Domain {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Used to create the domain of a requested name, an imported fqdn or a random slug with its hosts,
+// the slug is frozen under the same lock so two requests of the same name can not both get it.
+func (b *Backend) create(opts *model.DomainOptions, cname string) error {
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	var fqdn string
+	switch {
+	case opts.Name != "":
+		fqdn = fmt.Sprintf("%s.%s", opts.Name, b.Domain)
+		if err := b.reserveSlugName(opts.Name, fqdn); err != nil {
+			return err
+		}
+	case opts.Fqdn != "":
+		fqdn = opts.Fqdn
+		if err := b.reserveSlugName(util.SlugWithZone(fqdn, b.Domain), fqdn); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < maxSlugHashTimes && fqdn == ""; i++ {
+		slug := generateSlug(b.Domain)
+		err := b.reserveSlugName(slug, fmt.Sprintf("%s.%s", slug, b.Domain))
+		if errors.Cause(err) == backend.ErrNameTaken {
+			logrus.Debugf(errExistSlug, slug)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fqdn = fmt.Sprintf("%s.%s", slug, b.Domain)
+	}
+	if fqdn == "" {
+		return errors.Errorf(errSetRecord, typeA, opts.String())
+	}
+	opts.Fqdn = fqdn
+
+	lease := b.leaseTime(opts)
+	e := &domain{
+		fqdn:       fqdn,
+		zone:       b.Domain,
+		token:      util.RandStringWithAll(tokenLength),
+		cname:      cname,
+		creatorIP:  opts.CreatorIP,
+		lease:      lease,
+		expiration: time.Now().Add(lease),
+		hosts:      make(map[string][]string),
+		drained:    make(map[string]time.Time),
+		texts:      make(map[string]*text),
+		labels:     copyLabels(opts.Labels),
+		scoped:     make(map[string]string),
+		webhooks:   make(map[string]model.Webhook),
+	}
+	e.setHosts(opts)
+	b.s.domains[fqdn] = e
+	b.s.schedule(e)
+
+	return nil
+}
+
+// Used to freeze a slug before its domain is set, the slug is only frozen when it is neither frozen
+// nor used. The expired domain of the fqdn is deleted, its timer may not have fired yet.
+func (b *Backend) reserveSlugName(slug, fqdn string) error {
+	if slug == "" {
+		return errors.Errorf(errNotValidDomainName, fqdn)
+	}
+
+	if _, ok := b.s.domains[fqdn]; ok {
+		if _, ok := b.s.live(fqdn); ok {
+			return errors.Wrapf(backend.ErrNameTaken, errRequestName, slug)
+		}
+		b.s.remove(fqdn)
+	}
+	if until, ok := b.s.frozen[slug]; ok && time.Now().Before(until) {
+		return errors.Wrapf(backend.ErrNameTaken, errRequestName, slug)
+	}
+
+	b.s.freeze(slug, time.Now().Add(b.FrozenTTL))
+	return nil
+}
+
+// Used to get the domain of a fqdn whose expiration is not over, the lock is held
+func (s *store) live(fqdn string) (*domain, bool) {
+	e, ok := s.domains[fqdn]
+	if !ok || !time.Now().Before(e.expiration) {
+		return nil, false
+	}
+	return e, true
+}
+
+// Used to restart the timer which deletes a domain once its expiration is over, the lock is held
+func (s *store) schedule(e *domain) {
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+	e.timer = time.AfterFunc(time.Until(e.expiration), func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// the domain may be renewed or created again since the timer fired
+		if s.domains[e.fqdn] != e || time.Now().Before(e.expiration) {
+			return
+		}
+		logrus.Debugf("expire %s record: %s", typeA, e.fqdn)
+		s.remove(e.fqdn)
+	})
+}
+
+// Used to delete a domain with its records, the lock is held
+func (s *store) remove(fqdn string) {
+	if e, ok := s.domains[fqdn]; ok {
+		e.timer.Stop()
+		delete(s.domains, fqdn)
+	}
+}
+
+// Used to keep a frozen slug until a time, a timer deletes it then. The lock is held.
+func (s *store) freeze(slug string, until time.Time) {
+	s.frozen[slug] = until
+	time.AfterFunc(time.Until(until), func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// the slug may be frozen again since the timer was started
+		if s.frozen[slug].Equal(until) {
+			delete(s.frozen, slug)
+		}
+	})
+}
+
+// Used to convert a domain with its hosts to the domain of the api, the lock is held
+func (e *domain) toDomain() model.Domain {
+	exp := e.expiration
+	d := model.Domain{
+		Fqdn:       e.fqdn,
+		Hosts:      make([]string, 0),
+		SubDomain:  make(map[string][]string),
+		CNAME:      e.cname,
+		CreatorIP:  e.creatorIP,
+		Labels:     copyLabels(e.labels),
+		TTL:        e.ttl,
+		Expiration: &exp,
+	}
+	if !e.deadline.IsZero() {
+		t := e.deadline
+		d.Deadline = &t
+	}
+
+	now := time.Now()
+	for fqdn, hosts := range e.hosts {
+		if fqdn == e.fqdn {
+			d.Hosts = append(d.Hosts, hosts...)
+		} else {
+			d.SubDomain[strings.TrimSuffix(fqdn, "."+e.fqdn)] = append([]string{}, hosts...)
+		}
+		for _, h := range hosts {
+			if until, ok := e.drained[h]; ok && until.After(now) {
+				if d.Drained == nil {
+					d.Drained = make(map[string]*time.Time)
+				}
+				t := until.UTC()
+				d.Drained[h] = &t
+			}
+		}
+	}
+
+	return d
+}
+
+// Used to sync the hosts of a domain and its sub domains with the options, the hosts which are
+// kept keep the time they are drained until. The lock is held.
+func (e *domain) setHosts(opts *model.DomainOptions) {
+	e.hosts = make(map[string][]string)
+	e.putHosts(e.fqdn, opts.Hosts)
+	for prefix, hosts := range opts.SubDomain {
+		e.putHosts(fmt.Sprintf("%s.%s", prefix, e.fqdn), hosts)
+	}
+
+	kept := make(map[string]bool)
+	for _, hosts := range e.hosts {
+		for _, h := range hosts {
+			kept[h] = true
+		}
+	}
+	for h := range e.drained {
+		if !kept[h] {
+			delete(e.drained, h)
+		}
+	}
+}
+
+// Used to replace the hosts of a fqdn in the order of the hosts, a fqdn without hosts is removed.
+// The lock is held.
+func (e *domain) putHosts(fqdn string, hosts []string) {
+	m := sliceToMap(hosts)
+	delete(m, "")
+	if len(m) == 0 {
+		delete(e.hosts, fqdn)
+		return
+	}
+
+	sorted := make([]string, 0, len(m))
+	for h := range m {
+		sorted = append(sorted, h)
+	}
+	sort.Strings(sorted)
+	e.hosts[fqdn] = sorted
+}
+
+// Used to get the fqdn of the domain which a fqdn is under
+// e.g. x1.qrn7oq.lb.rancher.cloud => qrn7oq.lb.rancher.cloud
+func (b *Backend) baseOf(fqdn string) string {
+	return fmt.Sprintf("%s.%s", util.SlugWithZone(fqdn, b.Domain), b.Domain)
+}
+
+// Used to get the time a new domain lives, the api clamps the requested lease
+// e.g. {Lease: 0} => 240h
+// e.g. {Lease: 3600} => 1h
+func (b *Backend) leaseTime(opts *model.DomainOptions) time.Duration {
+	if opts.Lease > 0 {
+		return time.Duration(opts.Lease) * time.Second
+	}
+	return b.LeaseTime
+}
+
+// Used to generate a random slug with the slug length of the zone
+func generateSlug(zone string) string {
+	return util.RandStringWithSmall(config.Resolve(zone).SlugLength)
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	m := make(map[string]string, len(labels))
+	for k, v := range labels {
+		m[k] = v
+	}
+	return m
+}
+
+func sliceToMap(ss []string) map[string]bool {
+	m := make(map[string]bool)
+	for _, s := range ss {
+		m[s] = true
+	}
+	return m
+}
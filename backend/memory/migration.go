@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AppliedMigrations returns the data migrations which are recorded as applied with their apply time.
+func (b *Backend) AppliedMigrations() (map[string]time.Time, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	applied := make(map[string]time.Time, len(b.s.migrations))
+	for id, on := range b.s.migrations {
+		applied[id] = on
+	}
+
+	return applied, nil
+}
+
+// RecordMigration records a data migration as applied, a migration which is recorded already keeps
+// its apply time.
+func (b *Backend) RecordMigration(id string) error {
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	if _, ok := b.s.migrations[id]; !ok {
+		b.s.migrations[id] = time.Now()
+	}
+
+	return nil
+}
+
+// MigrateFrozen keeps the frozen slug of the path until its expiration
+func (b *Backend) MigrateFrozen(opts *model.MigrateFrozen) error {
+	logrus.Debugf("migrate %s record: %s", typeFrozen, opts.Path)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	b.s.freeze(opts.Path, *opts.Expiration)
+
+	return nil
+}
+
+// MigrateToken creates the domain of the path with the token and the expiration, the records of
+// the domain are migrated after it. A domain of the path keeps its records.
+func (b *Backend) MigrateToken(opts *model.MigrateToken) error {
+	logrus.Debugf("migrate %s record: %s", typeToken, opts.Path)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	e, ok := b.s.domains[opts.Path]
+	if !ok {
+		e = &domain{
+			fqdn:     opts.Path,
+			hosts:    make(map[string][]string),
+			drained:  make(map[string]time.Time),
+			texts:    make(map[string]*text),
+			labels:   make(map[string]string),
+			scoped:   make(map[string]string),
+			webhooks: make(map[string]model.Webhook),
+		}
+		b.s.domains[opts.Path] = e
+	}
+	e.zone = b.Domain
+	e.token = opts.Token
+	e.lease = b.LeaseTime
+	e.expiration = *opts.Expiration
+	b.s.schedule(e)
+
+	return nil
+}
+
+func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
+	logrus.Debugf("migrate record: %s", opts.Fqdn)
+
+	if opts.Text != "" {
+		_, err := b.SetText(&model.DomainOptions{Fqdn: opts.Fqdn, Text: opts.Text})
+		return err
+	}
+
+	_, err := b.Update(&model.DomainOptions{Fqdn: opts.Fqdn, Hosts: opts.Hosts, SubDomain: opts.SubDomain})
+	return err
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Search returns a page of domains which match all filters, the pages are read as the list pages are.
+func (b *Backend) Search(opts *model.SearchOptions) (l model.DomainList, err error) {
+	logrus.Debugf("search %s records with filters: %+v", typeA, opts)
+
+	return b.page(&opts.ListOptions, func(e *domain) bool { return e.matches(opts) }, true)
+}
+
+// Used to check a domain against the filters of a search, the lock is held
+func (e *domain) matches(opts *model.SearchOptions) bool {
+	if opts.Host != "" && !e.hasHost(opts.Host) {
+		return false
+	}
+	if opts.Label != "" {
+		kv := strings.SplitN(opts.Label, "=", 2)
+		if v, ok := e.labels[kv[0]]; len(kv) == 2 && (!ok || v != kv[1]) {
+			return false
+		}
+	}
+	if opts.CreatorIP != "" && e.creatorIP != opts.CreatorIP {
+		return false
+	}
+	if opts.ExpiringBefore != nil && !e.expiration.Before(*opts.ExpiringBefore) {
+		return false
+	}
+	if opts.ExpiringAfter != nil && !e.expiration.After(*opts.ExpiringAfter) {
+		return false
+	}
+	if opts.Text != "" && !e.hasText(opts.Text) {
+		return false
+	}
+	return true
+}
+
+// Used to check whether a TXT record of the domain contains a text, the lock is held
+func (e *domain) hasText(s string) bool {
+	now := time.Now()
+	for _, t := range e.texts {
+		if (t.expiration.IsZero() || now.Before(t.expiration)) && strings.Contains(t.content, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The operational state is kept by key with the version of its value, a write names the version it
+// was read at and is refused when the state is at another one, so a lost update is refused.
+
+// GetOperationalState returns the state of the key, a key which is never written has version 0.
+func (b *Backend) GetOperationalState(key string) (model.OperationalState, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	s, ok := b.s.states[key]
+	if !ok {
+		return model.OperationalState{Key: key}, nil
+	}
+
+	return s, nil
+}
+
+// SetOperationalState writes the state when its version is still the current one, the version of
+// the written state is set to s.
+func (b *Backend) SetOperationalState(s *model.OperationalState) error {
+	logrus.Debugf("set operational state: %s", s.Key)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	if b.s.states[s.Key].Version != s.Version {
+		return errors.Wrapf(backend.ErrStateConflict, errSetRecord, typeState, s.Key)
+	}
+
+	t := time.Now()
+	s.Version++
+	s.Updated = &t
+
+	c := *s
+	if len(s.Value) > 0 {
+		c.Value = append([]byte{}, s.Value...)
+	}
+	b.s.states[s.Key] = c
+
+	return nil
+}
+
+func (b *Backend) ListOperationalStates() ([]model.OperationalState, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	result := make([]model.OperationalState, 0, len(b.s.states))
+	for _, s := range b.s.states {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+
+	return result, nil
+}
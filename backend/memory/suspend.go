@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Suspend keeps the suspension apart from the domain, it outlives the domain until it is removed
+// by Unsuspend.
+func (b *Backend) Suspend(s *model.Suspension) error {
+	logrus.Debugf("suspend domain: %s", s.Fqdn)
+
+	if s.Time == nil {
+		t := time.Now()
+		s.Time = &t
+	}
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	b.s.suspensions[s.Fqdn] = *s
+
+	return nil
+}
+
+func (b *Backend) Unsuspend(fqdn string) error {
+	logrus.Debugf("unsuspend domain: %s", fqdn)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	delete(b.s.suspensions, fqdn)
+
+	return nil
+}
+
+func (b *Backend) ListSuspensions() ([]model.Suspension, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	result := make([]model.Suspension, 0, len(b.s.suspensions))
+	for _, s := range b.s.suspensions {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Fqdn < result[j].Fqdn })
+
+	return result, nil
+}
@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The TXT records are kept with their domain by fqdn and order, the values of ACME orders are kept
+// apart so concurrent orders of one fqdn are all answered. A record expires with its domain, unless
+// it is an ACME challenge record, which gets an expiration and a timer of its own
+// e.g. _acme-challenge.sample.lb.rancher.cloud, 4f1b => texts {_acme-challenge.sample.lb.rancher.cloud/4f1b}
+
+func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("set %s record for domain options: %s", typeTXT, opts.String())
+
+	if err := b.checkText(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	if err := b.setText(opts); err != nil {
+		return d, err
+	}
+
+	return b.GetText(opts)
+}
+
+func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("get %s record for domain options: %s", typeTXT, opts.String())
+
+	if err := b.checkText(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	e, ok := b.s.live(b.baseOf(opts.Fqdn))
+	if !ok {
+		return d, errors.Errorf(errEmptyRecord, typeTXT, opts.Fqdn)
+	}
+
+	// without an order all values of the fqdn are read, the record answers until the last one expires
+	keys := []string{textKey(opts.Fqdn, opts.Order)}
+	if opts.Order == "" {
+		keys = textKeys(e, opts.Fqdn)
+	}
+	texts := make([]string, 0, len(keys))
+	var exp time.Time
+	for _, key := range keys {
+		t, ok := e.texts[key]
+		if !ok || (!t.expiration.IsZero() && !time.Now().Before(t.expiration)) {
+			continue
+		}
+		texts = append(texts, t.content)
+		te := t.expiration
+		if te.IsZero() {
+			te = e.expiration
+		}
+		if te.After(exp) {
+			exp = te
+		}
+	}
+	if len(texts) == 0 {
+		return d, errors.Errorf(errEmptyRecord, typeTXT, opts.Fqdn)
+	}
+
+	d.Fqdn = opts.Fqdn
+	d.Text = texts[0]
+	if opts.Order == "" {
+		d.Texts = texts
+	}
+	d.Order = opts.Order
+	d.Expiration = &exp
+
+	return d, nil
+}
+
+func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("update %s record for domain options: %s", typeTXT, opts.String())
+
+	if err := b.checkText(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	if _, err := b.GetText(opts.ReplacedText()); err != nil {
+		return d, err
+	}
+
+	if opts.Order == "" || opts.Replace {
+		// the value replaces all values of the fqdn
+		if err := b.DeleteText(&model.DomainOptions{Fqdn: opts.Fqdn}); err != nil {
+			return d, err
+		}
+	}
+	if err := b.setText(opts); err != nil {
+		return d, err
+	}
+
+	return b.GetText(opts)
+}
+
+// Without an order all values of the fqdn are deleted.
+func (b *Backend) DeleteText(opts *model.DomainOptions) error {
+	logrus.Debugf("delete %s record for domain options: %s", typeTXT, opts.String())
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	if e, ok := b.s.domains[b.baseOf(opts.Fqdn)]; ok {
+		keys := []string{textKey(opts.Fqdn, opts.Order)}
+		if opts.Order == "" {
+			keys = textKeys(e, opts.Fqdn)
+		}
+		for _, key := range keys {
+			delete(e.texts, key)
+		}
+	}
+
+	return nil
+}
+
+// Used to write a TXT record below the domain of its fqdn, which has to exist
+func (b *Backend) setText(opts *model.DomainOptions) error {
+	base := b.baseOf(opts.Fqdn)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	e, ok := b.s.live(base)
+	if !ok {
+		return errors.Errorf(errEmptyRecord, typeToken, base)
+	}
+
+	// an updated challenge record gets a new expiration, like a new lease of the etcdv3 backend
+	key := textKey(opts.Fqdn, opts.Order)
+	t := &text{content: opts.Text, expiration: b.textExpiration(opts.Fqdn)}
+	e.texts[key] = t
+	if !t.expiration.IsZero() {
+		time.AfterFunc(time.Until(t.expiration), func() {
+			b.s.mu.Lock()
+			defer b.s.mu.Unlock()
+
+			// the record may be updated since the timer was started
+			if e.texts[key] == t {
+				delete(e.texts, key)
+			}
+		})
+	}
+
+	return nil
+}
+
+// Used to get the expiration of a TXT record, ACME challenge records expire long before their
+// domain so stale challenge values are cleaned up. Zero means the record expires with its domain.
+func (b *Backend) textExpiration(fqdn string) time.Time {
+	if b.ChallengeTTL <= 0 || !util.IsACMEChallenge(fqdn) {
+		return time.Time{}
+	}
+	return time.Now().Add(b.ChallengeTTL)
+}
+
+// Used to check that a TXT record is below a domain
+// e.g. _acme-challenge.sample.lb.rancher.cloud => ok
+// e.g. sample.lb.rancher.cloud => not valid
+func (b *Backend) checkText(fqdn string) error {
+	if len(strings.Split(fqdn, "."))-len(strings.Split(b.Domain, ".")) <= 1 {
+		return errors.Errorf(errNotValidDomainName, fqdn)
+	}
+	return nil
+}
+
+// Used to get the key of a TXT record of a domain
+// e.g. _acme-challenge.sample.lb.rancher.cloud, 4f1b => _acme-challenge.sample.lb.rancher.cloud/4f1b
+func textKey(fqdn, order string) string {
+	return fqdn + "/" + order
+}
+
+// Used to get the keys of all TXT values of an fqdn in a domain, sorted
+// e.g. _acme-challenge.sample.lb.rancher.cloud => [_acme-challenge.sample.lb.rancher.cloud/, _acme-challenge.sample.lb.rancher.cloud/4f1b]
+func textKeys(e *domain, fqdn string) []string {
+	keys := make([]string, 0)
+	for key := range e.texts {
+		if strings.HasPrefix(key, fqdn+"/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
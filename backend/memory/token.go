@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// RotateToken replaces the token origin of a domain, the domain keeps its expiration and the
+// tokens derived from the old origin are refused.
+func (b *Backend) RotateToken(fqdn, token string) error {
+	logrus.Debugf("rotate %s record for fqdn: %s", typeToken, fqdn)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		return errors.Errorf(errEmptyRecord, typeToken, fqdn)
+	}
+	e.token = token
+
+	return nil
+}
+
+// SetScopedToken keeps the origin of a secondary token of a domain, the secondary token is deleted
+// with the domain. An origin of the same scope is replaced.
+func (b *Backend) SetScopedToken(fqdn, scope, origin string) error {
+	logrus.Debugf("set %s %s record for fqdn: %s", scope, typeToken, fqdn)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		return errors.Errorf(errEmptyRecord, typeToken, fqdn)
+	}
+	e.scoped[scope] = origin
+
+	return nil
+}
+
+func (b *Backend) GetScopedToken(fqdn, scope string) (string, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		return "", errors.Errorf(errEmptyRecord, typeToken, fqdn+"/"+scope)
+	}
+	origin, ok := e.scoped[scope]
+	if !ok {
+		return "", errors.Errorf(errEmptyRecord, typeToken, fqdn+"/"+scope)
+	}
+
+	return origin, nil
+}
+
+func (b *Backend) DeleteScopedToken(fqdn, scope string) error {
+	logrus.Debugf("delete %s %s record for fqdn: %s", scope, typeToken, fqdn)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	if e, ok := b.s.domains[fqdn]; ok {
+		delete(e.scoped, scope)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetTTL keeps the ttl of a domain, the hosts of the domain and its sub domains are answered with
+// it. 0 restores the default ttl.
+func (b *Backend) SetTTL(fqdn string, ttl uint32) (d model.Domain, err error) {
+	logrus.Debugf("set %s of domain %s to %d", typeTTL, fqdn, ttl)
+
+	b.s.mu.Lock()
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		b.s.mu.Unlock()
+		return d, errors.Errorf(errNoLookupResults, typeA, fqdn)
+	}
+	e.ttl = ttl
+	b.s.mu.Unlock()
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
+
+// DrainHost keeps the time the host of the domain and its sub domains is drained until. A zero
+// time undrains it.
+func (b *Backend) DrainHost(fqdn, host string, until time.Time) (d model.Domain, err error) {
+	logrus.Debugf("set %s of host %s of domain %s to %s", typeDrain, host, fqdn, until.Format(time.RFC3339))
+
+	b.s.mu.Lock()
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		b.s.mu.Unlock()
+		return d, errors.Errorf(errNoLookupResults, typeA, fqdn)
+	}
+	if !e.hasHost(host) {
+		b.s.mu.Unlock()
+		return d, errors.Wrapf(backend.ErrUnknownHost, errNoDrainHost, host, fqdn)
+	}
+	if until.IsZero() {
+		delete(e.drained, host)
+	} else {
+		e.drained[host] = until
+	}
+	b.s.mu.Unlock()
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetWebhook keeps the webhook with its domain, webhooks are deleted together with
+// their domain.
+func (b *Backend) SetWebhook(w *model.Webhook) error {
+	logrus.Debugf("set webhook %s for domain: %s", w.ID, w.Fqdn)
+
+	if w.Time == nil {
+		t := time.Now()
+		w.Time = &t
+	}
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	e, ok := b.s.live(w.Fqdn)
+	if !ok {
+		return errors.Errorf(errEmptyRecord, typeToken, w.Fqdn)
+	}
+	c := *w
+	c.Events = append([]string{}, w.Events...)
+	e.webhooks[w.ID] = c
+
+	return nil
+}
+
+func (b *Backend) ListWebhooks(fqdn string) ([]model.Webhook, error) {
+	b.s.mu.RLock()
+	defer b.s.mu.RUnlock()
+
+	result := make([]model.Webhook, 0)
+	e, ok := b.s.live(fqdn)
+	if !ok {
+		return result, nil
+	}
+	for _, w := range e.webhooks {
+		result = append(result, w)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
+
+func (b *Backend) DeleteWebhook(fqdn, id string) error {
+	logrus.Debugf("delete webhook %s for domain: %s", id, fqdn)
+
+	b.s.mu.Lock()
+	defer b.s.mu.Unlock()
+
+	if e, ok := b.s.domains[fqdn]; ok {
+		delete(e.webhooks, id)
+	}
+
+	return nil
+}
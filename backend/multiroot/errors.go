@@ -0,0 +1,8 @@
+package multiroot
+
+const (
+	errNestedRoot    = "root domain %s can not be served together with %s"
+	errNoRootDomains = "no root domains to serve"
+	errUnknownRoot   = "root domain %s is not served, expected one of %s"
+	errUnsupported   = "%s is not supported by the %s backend"
+)
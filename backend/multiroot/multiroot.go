@@ -0,0 +1,391 @@
+package multiroot
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+)
+
+// Backend serves several root domains with a backend per root domain, e.g. lb.example1.com and
+// lb.example2.com. The records and tokens of a domain are read from and written to the backend
+// of its root domain, the keyspaces which are shared by all root domains (suspensions, jobs,
+// api keys, operational state and frozen slugs) go to the backend of the first root domain,
+// which is the default.
+type Backend struct {
+	roots    []string
+	backends map[string]backend.Backend
+}
+
+// NewBackend returns the backend of the roots, open is called once per root domain in order.
+func NewBackend(roots []string, open func(root string) (backend.Backend, error)) (*Backend, error) {
+	if len(roots) == 0 {
+		return nil, errors.New(errNoRootDomains)
+	}
+
+	b := &Backend{
+		roots:    roots,
+		backends: make(map[string]backend.Backend, len(roots)),
+	}
+	for i, r := range roots {
+		// the records of a root domain are kept below its reversed path, which would hold
+		// the records of the root domains under it too
+		if n := util.RootOf(r, append(append([]string{}, roots[:i]...), roots[i+1:]...)); n != "" {
+			return nil, errors.Errorf(errNestedRoot, r, n)
+		}
+		o, err := open(r)
+		if err != nil {
+			return nil, err
+		}
+		b.backends[r] = o
+	}
+	return b, nil
+}
+
+// Backends returns the backends of the root domains in order.
+func (b *Backend) Backends() []backend.Backend {
+	bs := make([]backend.Backend, 0, len(b.roots))
+	for _, r := range b.roots {
+		bs = append(bs, b.backends[r])
+	}
+	return bs
+}
+
+// Reader returns the backend of the readers of the root domains.
+func (b *Backend) Reader() backend.Backend {
+	r := &Backend{
+		roots:    b.roots,
+		backends: make(map[string]backend.Backend, len(b.roots)),
+	}
+	for root, o := range b.backends {
+		r.backends[root] = backend.Reader(o)
+	}
+	return r
+}
+
+// Used to get the backend of the default root domain
+func (b *Backend) first() backend.Backend {
+	return b.backends[b.roots[0]]
+}
+
+// Used to get the backend of the root domain which a fqdn is under, the default one when it is under none
+func (b *Backend) of(fqdn string) backend.Backend {
+	if r := util.RootOf(fqdn, b.roots); r != "" {
+		return b.backends[r]
+	}
+	return b.first()
+}
+
+// Used to get the backend of a requested root domain, empty means the default one
+func (b *Backend) root(root string) (backend.Backend, error) {
+	if root == "" {
+		return b.first(), nil
+	}
+	o, ok := b.backends[strings.Trim(strings.ToLower(root), ".")]
+	if !ok {
+		return nil, errors.Errorf(errUnknownRoot, root, strings.Join(b.roots, ", "))
+	}
+	return o, nil
+}
+
+// Used to get the backend which creates a domain, the fqdn is known when a domain is imported
+func (b *Backend) create(opts *model.DomainOptions) (backend.Backend, error) {
+	if opts.Fqdn != "" {
+		return b.of(opts.Fqdn), nil
+	}
+	return b.root(opts.Root)
+}
+
+// GetName returns the name of the backends, the root domains are only a layout of them.
+func (b *Backend) GetName() string {
+	return b.first().GetName()
+}
+
+func (b *Backend) GetZone() string {
+	return b.roots[0]
+}
+
+func (b *Backend) GetZones() []string {
+	return b.roots
+}
+
+func (b *Backend) Ping() error {
+	return b.first().Ping()
+}
+
+func (b *Backend) Get(opts *model.DomainOptions) (model.Domain, error) {
+	return b.of(opts.Fqdn).Get(opts)
+}
+
+func (b *Backend) Set(opts *model.DomainOptions) (model.Domain, error) {
+	o, err := b.create(opts)
+	if err != nil {
+		return model.Domain{}, err
+	}
+	return o.Set(opts)
+}
+
+func (b *Backend) Update(opts *model.DomainOptions) (model.Domain, error) {
+	return b.of(opts.Fqdn).Update(opts)
+}
+
+func (b *Backend) PatchHosts(fqdn string, add, remove []string) (model.Domain, error) {
+	return b.of(fqdn).PatchHosts(fqdn, add, remove)
+}
+
+func (b *Backend) Delete(opts *model.DomainOptions) error {
+	return b.of(opts.Fqdn).Delete(opts)
+}
+
+func (b *Backend) Renew(opts *model.DomainOptions) (model.Domain, error) {
+	return b.of(opts.Fqdn).Renew(opts)
+}
+
+func (b *Backend) Keepalive(fqdn string) (time.Time, error) {
+	return b.of(fqdn).Keepalive(fqdn)
+}
+
+func (b *Backend) SetText(opts *model.DomainOptions) (model.Domain, error) {
+	return b.of(opts.Fqdn).SetText(opts)
+}
+
+func (b *Backend) GetText(opts *model.DomainOptions) (model.Domain, error) {
+	return b.of(opts.Fqdn).GetText(opts)
+}
+
+func (b *Backend) UpdateText(opts *model.DomainOptions) (model.Domain, error) {
+	return b.of(opts.Fqdn).UpdateText(opts)
+}
+
+func (b *Backend) DeleteText(opts *model.DomainOptions) error {
+	return b.of(opts.Fqdn).DeleteText(opts)
+}
+
+func (b *Backend) SetCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	o, err := b.create(opts)
+	if err != nil {
+		return model.Domain{}, err
+	}
+	return o.SetCNAME(opts)
+}
+
+func (b *Backend) GetCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	return b.of(opts.Fqdn).GetCNAME(opts)
+}
+
+func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	return b.of(opts.Fqdn).UpdateCNAME(opts)
+}
+
+func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
+	return b.of(opts.Fqdn).DeleteCNAME(opts)
+}
+
+func (b *Backend) SetCAA(fqdn string, records []model.CAARecord) error {
+	return b.of(fqdn).SetCAA(fqdn, records)
+}
+
+func (b *Backend) GetCAA(fqdn string) ([]model.CAARecord, error) {
+	return b.of(fqdn).GetCAA(fqdn)
+}
+
+func (b *Backend) DeleteCAA(fqdn string) error {
+	return b.of(fqdn).DeleteCAA(fqdn)
+}
+
+func (b *Backend) SetQueryTypes(fqdn string, types []string) error {
+	return b.of(fqdn).SetQueryTypes(fqdn, types)
+}
+
+func (b *Backend) GetQueryTypes(fqdn string) ([]string, error) {
+	return b.of(fqdn).GetQueryTypes(fqdn)
+}
+
+func (b *Backend) GetToken(fqdn string) (string, error) {
+	return b.of(fqdn).GetToken(fqdn)
+}
+
+// GetTokenCount counts the tokens of all root domains.
+func (b *Backend) GetTokenCount() (int64, error) {
+	var count int64
+	for _, r := range b.roots {
+		n, err := b.backends[r].GetTokenCount()
+		if err != nil {
+			return 0, err
+		}
+		count += n
+	}
+	return count, nil
+}
+
+func (b *Backend) RotateToken(fqdn, token string) error {
+	return b.of(fqdn).RotateToken(fqdn, token)
+}
+
+func (b *Backend) SetScopedToken(fqdn, scope, origin string) error {
+	return b.of(fqdn).SetScopedToken(fqdn, scope, origin)
+}
+
+func (b *Backend) GetScopedToken(fqdn, scope string) (string, error) {
+	return b.of(fqdn).GetScopedToken(fqdn, scope)
+}
+
+func (b *Backend) DeleteScopedToken(fqdn, scope string) error {
+	return b.of(fqdn).DeleteScopedToken(fqdn, scope)
+}
+
+func (b *Backend) SetAPIKey(k *model.APIKey) error {
+	return b.first().SetAPIKey(k)
+}
+
+func (b *Backend) GetAPIKey(id string) (model.APIKey, error) {
+	return b.first().GetAPIKey(id)
+}
+
+func (b *Backend) ListAPIKeys() ([]model.APIKey, error) {
+	return b.first().ListAPIKeys()
+}
+
+func (b *Backend) DeleteAPIKey(id string) error {
+	return b.first().DeleteAPIKey(id)
+}
+
+// List lists the domains of the root domain of opts.Root, the continue token only
+// continues the list of the same root domain.
+func (b *Backend) List(opts *model.ListOptions) (model.DomainList, error) {
+	o, err := b.root(opts.Root)
+	if err != nil {
+		return model.DomainList{}, err
+	}
+	return o.List(opts)
+}
+
+func (b *Backend) Search(opts *model.SearchOptions) (model.DomainList, error) {
+	o, err := b.root(opts.Root)
+	if err != nil {
+		return model.DomainList{}, err
+	}
+	return o.Search(opts)
+}
+
+// HostDomains returns the domains of all root domains which point at the host.
+func (b *Backend) HostDomains(host string) ([]string, error) {
+	fqdns := make([]string, 0)
+	for _, r := range b.roots {
+		ss, err := b.backends[r].HostDomains(host)
+		if err != nil {
+			return nil, err
+		}
+		fqdns = append(fqdns, ss...)
+	}
+	sort.Strings(fqdns)
+	return fqdns, nil
+}
+
+// Extend extends the lease of a domain when the backend of its root domain can.
+func (b *Backend) Extend(fqdn string, lease time.Duration) (bool, error) {
+	e, ok := b.of(fqdn).(interface {
+		Extend(fqdn string, lease time.Duration) (bool, error)
+	})
+	if !ok {
+		return false, errors.Errorf(errUnsupported, "extending leases", b.GetName())
+	}
+	return e.Extend(fqdn, lease)
+}
+
+// SetExpiration moves the expiration of a domain when the backend of its root domain can.
+func (b *Backend) SetExpiration(fqdn string, until time.Time) (model.Domain, error) {
+	e, ok := b.of(fqdn).(interface {
+		SetExpiration(fqdn string, until time.Time) (model.Domain, error)
+	})
+	if !ok {
+		return model.Domain{}, errors.Errorf(errUnsupported, "setting expirations", b.GetName())
+	}
+	return e.SetExpiration(fqdn, until)
+}
+
+// SetDeadline keeps the deadline of a temporary domain when the backend of its root domain can.
+func (b *Backend) SetDeadline(fqdn string, until time.Time) (model.Domain, error) {
+	e, ok := b.of(fqdn).(interface {
+		SetDeadline(fqdn string, until time.Time) (model.Domain, error)
+	})
+	if !ok {
+		return model.Domain{}, errors.Errorf(errUnsupported, "temporary domains", b.GetName())
+	}
+	return e.SetDeadline(fqdn, until)
+}
+
+func (b *Backend) SetTTL(fqdn string, ttl uint32) (model.Domain, error) {
+	return b.of(fqdn).SetTTL(fqdn, ttl)
+}
+
+func (b *Backend) DrainHost(fqdn, host string, until time.Time) (model.Domain, error) {
+	return b.of(fqdn).DrainHost(fqdn, host, until)
+}
+
+func (b *Backend) Suspend(s *model.Suspension) error {
+	return b.first().Suspend(s)
+}
+
+func (b *Backend) Unsuspend(fqdn string) error {
+	return b.first().Unsuspend(fqdn)
+}
+
+func (b *Backend) ListSuspensions() ([]model.Suspension, error) {
+	return b.first().ListSuspensions()
+}
+
+func (b *Backend) SetWebhook(w *model.Webhook) error {
+	return b.of(w.Fqdn).SetWebhook(w)
+}
+
+func (b *Backend) ListWebhooks(fqdn string) ([]model.Webhook, error) {
+	return b.of(fqdn).ListWebhooks(fqdn)
+}
+
+func (b *Backend) DeleteWebhook(fqdn, id string) error {
+	return b.of(fqdn).DeleteWebhook(fqdn, id)
+}
+
+func (b *Backend) EnqueueJob(j *model.Job) error {
+	return b.first().EnqueueJob(j)
+}
+
+func (b *Backend) ClaimJobs(kind string, limit int, visibility time.Duration) ([]model.Job, error) {
+	return b.first().ClaimJobs(kind, limit, visibility)
+}
+
+func (b *Backend) DeleteJob(kind, id string) error {
+	return b.first().DeleteJob(kind, id)
+}
+
+func (b *Backend) GetOperationalState(key string) (model.OperationalState, error) {
+	return b.first().GetOperationalState(key)
+}
+
+func (b *Backend) SetOperationalState(s *model.OperationalState) error {
+	return b.first().SetOperationalState(s)
+}
+
+func (b *Backend) ListOperationalStates() ([]model.OperationalState, error) {
+	return b.first().ListOperationalStates()
+}
+
+func (b *Backend) MigrateFrozen(opts *model.MigrateFrozen) error {
+	return b.first().MigrateFrozen(opts)
+}
+
+// MigrateToken migrates the token to the backend of the fqdn which ends its path
+// e.g. /token/sample.lb.example2.com => lb.example2.com
+func (b *Backend) MigrateToken(opts *model.MigrateToken) error {
+	return b.of(opts.Path[strings.LastIndex(opts.Path, "/")+1:]).MigrateToken(opts)
+}
+
+func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
+	return b.of(opts.Fqdn).MigrateRecord(opts)
+}
@@ -0,0 +1,74 @@
+package backend
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReadCheckInterval is how often the read endpoints of a backend are pinged, the reads fall back
+// to the write endpoints from the first failed ping until the next one which succeeds.
+const ReadCheckInterval = 10 * time.Second
+
+// ReadSplitter is implemented by the backends which can serve the reads from other endpoints than
+// the writes, e.g. the etcd followers or the read replicas of a database.
+type ReadSplitter interface {
+	// Reader returns the backend which reads from the read endpoints, it is the backend itself
+	// when no read endpoints are set or they are not healthy.
+	Reader() Backend
+}
+
+// Reader returns the backend which serves the reads of b. It may lag behind the writes of b, so
+// the reads which a write depends on, like the token checks, are left to b.
+func Reader(b Backend) Backend {
+	if s, ok := b.(ReadSplitter); ok {
+		return s.Reader()
+	}
+	return b
+}
+
+// GetReader returns the backend which serves the reads of the current backend.
+func GetReader() Backend {
+	return Reader(GetBackend())
+}
+
+// ReadHealth tracks whether the read endpoints of a backend answer, they are pinged every
+// ReadCheckInterval for as long as the process runs.
+type ReadHealth struct {
+	name    string
+	ping    func() error
+	healthy int32
+}
+
+// NewReadHealth pings the read endpoints once before it returns, so the first reads do not go to
+// endpoints which are down.
+func NewReadHealth(name string, ping func() error) *ReadHealth {
+	// unknown until the first ping, so its result is logged either way
+	h := &ReadHealth{name: name, ping: ping, healthy: -1}
+	h.check()
+	go func() {
+		for range time.Tick(ReadCheckInterval) {
+			h.check()
+		}
+	}()
+	return h
+}
+
+// Healthy reports whether the last ping of the read endpoints succeeded, a nil ReadHealth has no
+// read endpoints and is never healthy.
+func (h *ReadHealth) Healthy() bool {
+	return h != nil && atomic.LoadInt32(&h.healthy) == 1
+}
+
+func (h *ReadHealth) check() {
+	if err := h.ping(); err != nil {
+		if atomic.SwapInt32(&h.healthy, 0) != 0 {
+			logrus.Warnf("read endpoints of %s backend are unhealthy, reads fall back to the write endpoints: %v", h.name, err)
+		}
+		return
+	}
+	if atomic.SwapInt32(&h.healthy, 1) != 1 {
+		logrus.Infof("reads of %s backend are served by the read endpoints", h.name)
+	}
+}
@@ -0,0 +1,55 @@
+package backend
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Config is what a registered backend is opened with, the other options of a backend are read
+// from the environments of its command.
+type Config struct {
+	// Name is the name the backend is registered with
+	Name string
+	// DSN is the data source of the database which keeps the tokens, empty means the command
+	// has set the database already
+	DSN string
+}
+
+// Factory opens a backend of a Config.
+type Factory func(cfg *Config) (Backend, error)
+
+var factories = make(map[string]Factory)
+
+// Register registers the factory of a backend by name, backends register themselves in their init.
+func Register(name string, f Factory) {
+	if _, ok := factories[name]; ok {
+		panic("backend is registered twice: " + name)
+	}
+	factories[name] = f
+}
+
+// Names returns the names of the registered backends in order.
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens the registered backend of cfg.Name.
+func Open(cfg *Config) (Backend, error) {
+	f, ok := factories[cfg.Name]
+	if !ok {
+		return nil, errors.Errorf(errUnknownBackend, cfg.Name, strings.Join(Names(), ", "))
+	}
+
+	b, err := f(cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, errOpenBackend, cfg.Name)
+	}
+	return b, nil
+}
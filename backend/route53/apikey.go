@@ -0,0 +1,72 @@
+package route53
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetAPIKey keeps the api key in the database, it is not bound to the token of any domain.
+func (b *Backend) SetAPIKey(k *model.APIKey) error {
+	logrus.Debugf("set api key: %s", k.ID)
+
+	createdOn := time.Now()
+	if k.CreatedOn != nil {
+		createdOn = *k.CreatedOn
+	}
+
+	err := database.GetDatabase().InsertAPIKey(&model.DatabaseAPIKey{
+		ID:         k.ID,
+		Name:       k.Name,
+		Tenant:     k.Tenant,
+		RootDomain: k.RootDomain,
+		Hash:       k.Hash,
+		CreatedOn:  createdOn.Unix(),
+	})
+	return errors.Wrapf(err, errInsertAPIKeyToDatabase, k.ID)
+}
+
+func (b *Backend) GetAPIKey(id string) (model.APIKey, error) {
+	r, err := database.GetDatabase().QueryAPIKey(id)
+	if err != nil {
+		return model.APIKey{}, errors.Wrapf(err, errQueryAPIKeyFromDatabase, id)
+	}
+	return toAPIKey(r), nil
+}
+
+func (b *Backend) ListAPIKeys() ([]model.APIKey, error) {
+	rs, err := database.GetDatabase().ListAPIKeys()
+	if err != nil {
+		return nil, errors.Wrap(err, errListAPIKeysFromDatabase)
+	}
+
+	result := make([]model.APIKey, 0, len(rs))
+	for _, r := range rs {
+		result = append(result, toAPIKey(r))
+	}
+
+	return result, nil
+}
+
+func (b *Backend) DeleteAPIKey(id string) error {
+	logrus.Debugf("delete api key: %s", id)
+
+	err := database.GetDatabase().DeleteAPIKey(id)
+	return errors.Wrapf(err, errDeleteAPIKeyFromDatabase, id)
+}
+
+func toAPIKey(r *model.DatabaseAPIKey) model.APIKey {
+	t := time.Unix(r.CreatedOn, 0)
+	return model.APIKey{
+		ID:         r.ID,
+		Name:       r.Name,
+		Tenant:     r.Tenant,
+		RootDomain: r.RootDomain,
+		Hash:       r.Hash,
+		CreatedOn:  &t,
+	}
+}
@@ -0,0 +1,31 @@
+package route53
+
+import (
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+// CAA records are not supported, the records of route53 are tracked in the database by type
+// so that expired domains are purged, and CAA has no type there.
+func (b *Backend) SetCAA(fqdn string, records []model.CAARecord) error {
+	return errors.Errorf(errNotSupportedCAA, fqdn)
+}
+
+func (b *Backend) GetCAA(fqdn string) ([]model.CAARecord, error) {
+	return nil, errors.Errorf(errNotSupportedCAA, fqdn)
+}
+
+func (b *Backend) DeleteCAA(fqdn string) error {
+	return errors.Errorf(errNotSupportedCAA, fqdn)
+}
+
+// SetQueryTypes is not supported, route53 answers every record of a name which it keeps.
+func (b *Backend) SetQueryTypes(fqdn string, types []string) error {
+	return errors.Errorf(errNotSupportedQueryTypes, fqdn)
+}
+
+// GetQueryTypes always reports no restriction, as none can be set.
+func (b *Backend) GetQueryTypes(fqdn string) ([]string, error) {
+	return nil, nil
+}
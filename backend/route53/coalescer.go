@@ -0,0 +1,148 @@
+package route53
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/sirupsen/logrus"
+)
+
+// route53 accepts up to 1000 changes per batch, keep batches small so a
+// single request stays well under the payload limits.
+const maxCoalesceChanges = 100
+
+// coalescer batches the changes submitted within one interval into a single
+// ChangeResourceRecordSets call. Successive changes to the same record set are
+// merged, the last change wins and every submitter gets the result of the call.
+type coalescer struct {
+	svc      *route53.Route53
+	zoneID   string
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*pendingChange
+	order   []string
+}
+
+type pendingChange struct {
+	change *route53.Change
+	done   []chan result
+}
+
+// result is the id of the change batch which held a change, or the error of sending it
+type result struct {
+	changeID string
+	err      error
+}
+
+func newCoalescer(svc *route53.Route53, zoneID string, interval time.Duration) *coalescer {
+	c := &coalescer{
+		svc:      svc,
+		zoneID:   zoneID,
+		interval: interval,
+		pending:  make(map[string]*pendingChange),
+		order:    make([]string, 0),
+	}
+
+	if interval > 0 {
+		go c.run()
+	}
+
+	return c
+}
+
+// Change submits a change and blocks until the batch which holds it is sent, the id of the batch is returned.
+func (c *coalescer) Change(change *route53.Change) (string, error) {
+	if c.interval <= 0 {
+		r := c.send([]*route53.Change{change})
+		return r.changeID, r.err
+	}
+
+	key := aws.StringValue(change.ResourceRecordSet.Name) + "/" + aws.StringValue(change.ResourceRecordSet.Type)
+	done := make(chan result, 1)
+
+	c.mu.Lock()
+	if p, ok := c.pending[key]; ok {
+		logrus.Debugf("coalesce route53 change of record set: %s", key)
+		p.change = change
+		p.done = append(p.done, done)
+	} else {
+		c.pending[key] = &pendingChange{change: change, done: []chan result{done}}
+		c.order = append(c.order, key)
+	}
+	c.mu.Unlock()
+
+	r := <-done
+	return r.changeID, r.err
+}
+
+func (c *coalescer) run() {
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+
+	for range t.C {
+		c.flush()
+	}
+}
+
+func (c *coalescer) flush() {
+	c.mu.Lock()
+	n := len(c.order)
+	if n > maxCoalesceChanges {
+		n = maxCoalesceChanges
+	}
+	batch := make([]*pendingChange, 0, n)
+	for _, key := range c.order[:n] {
+		batch = append(batch, c.pending[key])
+		delete(c.pending, key)
+	}
+	c.order = c.order[n:]
+	c.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	changes := make([]*route53.Change, 0, len(batch))
+	for _, p := range batch {
+		changes = append(changes, p.change)
+	}
+
+	r := c.send(changes)
+	if r.err != nil && len(batch) > 1 {
+		// a batch is applied atomically, retry one by one so that an invalid
+		// change only fails its own submitters.
+		logrus.Debugf("failed to send route53 batch of %d changes, retry one by one: %v", len(batch), r.err)
+		for _, p := range batch {
+			p.reply(c.send([]*route53.Change{p.change}))
+		}
+		return
+	}
+
+	for _, p := range batch {
+		p.reply(r)
+	}
+}
+
+func (c *coalescer) send(changes []*route53.Change) result {
+	input := route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(c.zoneID),
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: changes,
+		},
+	}
+
+	out, err := c.svc.ChangeResourceRecordSets(&input)
+	if err != nil {
+		return result{err: err}
+	}
+	return result{changeID: aws.StringValue(out.ChangeInfo.Id)}
+}
+
+func (p *pendingChange) reply(r result) {
+	for _, done := range p.done {
+		done <- r
+	}
+}
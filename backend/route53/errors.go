@@ -1,23 +1,61 @@
 package route53
 
 const (
-	errDeleteAFromDatabase       = "failed to delete A record %s from database"
-	errDeleteRecordsFromDatabase = "failed to delete %s record %s from database"
-	errDeleteRoute53Record       = "failed to delete route53 %s record: %s"
-	errExistRecord               = "%s record: %s already exist"
-	errFilterRecords             = "failed to filter %s records: %s"
-	errGenerateName              = "failed to generate valid record: %s"
-	errInsertFrozenToDatabase    = "failed to insert %s's frozen to database"
-	errInsertRecordToDatabase    = "failed to insert %s record: %s to database"
-	errInsertTokenToDatabase     = "failed to insert %s's token to database"
-	errNoRoute53Record           = "failed to found route53 %s record: %s"
-	errNotValidGenerateName      = "generate name %s is already exist, will try another"
-	errParseFlag                 = "failed to parse flag: %s"
-	errQueryAFromDatabase        = "failed to query %s's A record from database"
-	errQueryTokenFromDatabase    = "failed to query %s's token record from database"
-	errQueryTXTFromDatabase      = "failed to query %s's TXT record from database"
-	errQueryCNAMEFromDatabase    = "failed to query %s's CNAME record from database"
-	errRenewFrozenFromDatabase   = "failed to renew %s's frozen record from database"
-	errRenewTokenFromDatabase    = "failed to renew %s's token record from database"
-	errUpsertRoute53Record       = "failed to upsert route53 %s record: %s"
+	errClaimJobsFromDatabase         = "failed to claim %s jobs from database"
+	errDeleteAFromDatabase           = "failed to delete A record %s from database"
+	errDeleteAPIKeyFromDatabase = "failed to delete api key %s from database"
+	errDeleteJobFromDatabase         = "failed to delete %s job %s from database"
+	errDeleteRecordsFromDatabase     = "failed to delete %s record %s from database"
+	errDeleteRoute53Record           = "failed to delete route53 %s record: %s"
+	errDeleteScopedTokenFromDatabase = "failed to delete %s's %s token from database"
+	errDeleteSuspensionFromDatabase  = "failed to delete %s's suspension from database"
+	errDeleteWebhookFromDatabase     = "failed to delete %s's webhook %s from database"
+	errExistRecord                   = "%s record: %s already exist"
+	errFilterRecords                 = "failed to filter %s records: %s"
+	errGenerateName                  = "failed to generate valid record: %s"
+	errGetChange                     = "failed to get route53 change %s"
+	errInsertAPIKeyToDatabase = "failed to insert api key %s to database"
+	errInsertFrozenToDatabase        = "failed to insert %s's frozen to database"
+	errInsertJobToDatabase           = "failed to insert %s job %s to database"
+	errInsertMigrationToDatabase     = "failed to insert data migration %s to database"
+	errInsertRecordToDatabase        = "failed to insert %s record: %s to database"
+	errInsertTokenToDatabase         = "failed to insert %s's token to database"
+	errInsertWebhookToDatabase       = "failed to insert %s's webhook to database"
+	errInvalidContinue               = "invalid continue token: %s"
+	errListAPIKeysFromDatabase = "failed to list api keys from database"
+	errListMigrationsFromDatabase    = "failed to list data migrations from database"
+	errListStatesFromDatabase        = "failed to list operational states from database"
+	errListSuspensionsFromDatabase   = "failed to list suspensions from database"
+	errListWebhooksFromDatabase      = "failed to list %s's webhooks from database"
+	errListTokensFromDatabase        = "failed to list token records from database"
+	errNoRoute53Record               = "failed to found route53 %s record: %s"
+	errNotSupportedIPv6              = "IPv6 hosts of domain %s are not supported by the route53 backend"
+	errNotSupportedLease             = "lease of domain %s is not supported by the route53 backend"
+	errNotSupportedTTL               = "ttl of domain %s can not be changed, route53 records use the TTL option"
+	errNotSupportedDrain             = "hosts of domain %s can not be drained, route53 answers every host of a record"
+	errNotSupportedCAA               = "CAA records of domain %s are not supported by the route53 backend"
+	errNotSupportedQueryTypes        = "query types of domain %s can not be restricted, route53 answers every type of a record"
+	errNotValidGenerateName          = "generate name %s is already exist, will try another"
+	errParseFlag                     = "failed to parse flag: %s"
+	errQueryAFromDatabase            = "failed to query %s's A record from database"
+	errQueryScopedTokenFromDatabase  = "failed to query %s's %s token from database"
+	errQueryStateFromDatabase        = "failed to query operational state %s from database"
+	errQueryTokenFromDatabase        = "failed to query %s's token record from database"
+	errQueryTXTFromDatabase          = "failed to query %s's TXT record from database"
+	errQueryTXTOrderFromDatabase     = "failed to query %s's TXT record of order %s from database"
+	errSetTXTOrderToDatabase         = "failed to set %s's TXT record of order %s to database"
+	errQueryAPIKeyFromDatabase = "failed to query api key %s from database"
+	errQueryCNAMEFromDatabase        = "failed to query %s's CNAME record from database"
+	errQueryHostFromDatabase         = "failed to query domains of host %s from database"
+	errQueryIndexesFromDatabase      = "failed to query %s's search indexes from database"
+	errRenewFrozenFromDatabase       = "failed to renew %s's frozen record from database"
+	errRenewTokenFromDatabase        = "failed to renew %s's token record from database"
+	errSetIndexesToDatabase          = "failed to set %s's search indexes to database"
+	errSetScopedTokenToDatabase      = "failed to set %s's %s token to database"
+	errSetStateToDatabase            = "failed to set operational state %s to database"
+	errSetSuspensionToDatabase       = "failed to set %s's suspension to database"
+	errUpdateTokenToDatabase         = "failed to update %s's token to database"
+	errUpsertRoute53Record           = "failed to upsert route53 %s record: %s"
+	errRequestName                   = "failed to request name %s"
+	errPing                          = "failed to ping the database"
 )
@@ -0,0 +1,70 @@
+package route53
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// EnqueueJob keeps the job in the database, it is not referenced by a token so it outlives the
+// domain it is about.
+func (b *Backend) EnqueueJob(j *model.Job) error {
+	logrus.Debugf("enqueue %s job: %s", j.Kind, j.ID)
+
+	err := database.GetDatabase().InsertJob(&model.DatabaseJob{
+		ID:        j.ID,
+		Kind:      j.Kind,
+		Payload:   string(j.Payload),
+		Attempts:  j.Attempts,
+		CreatedOn: j.Created.Unix(),
+		VisibleOn: toMillis(j.Visible),
+	})
+	return errors.Wrapf(err, errInsertJobToDatabase, j.Kind, j.ID)
+}
+
+// ClaimJobs hides the visible jobs until the visibility timeout, a job which another worker claims
+// at once is left to that worker.
+func (b *Backend) ClaimJobs(kind string, limit int, visibility time.Duration) ([]model.Job, error) {
+	now := time.Now()
+	js, err := database.GetDatabase().QueryVisibleJobs(kind, toMillis(now), limit)
+	if err != nil {
+		return nil, errors.Wrapf(err, errClaimJobsFromDatabase, kind)
+	}
+
+	until := now.Add(visibility)
+	result := make([]model.Job, 0, len(js))
+	for _, j := range js {
+		ok, err := database.GetDatabase().ClaimJob(j.ID, j.VisibleOn, toMillis(until))
+		if err != nil {
+			return result, errors.Wrapf(err, errClaimJobsFromDatabase, kind)
+		}
+		if !ok {
+			continue
+		}
+		result = append(result, model.Job{
+			ID:       j.ID,
+			Kind:     j.Kind,
+			Payload:  []byte(j.Payload),
+			Attempts: j.Attempts + 1,
+			Created:  time.Unix(j.CreatedOn, 0),
+			Visible:  until,
+		})
+	}
+
+	return result, nil
+}
+
+func (b *Backend) DeleteJob(kind, id string) error {
+	logrus.Debugf("delete %s job: %s", kind, id)
+
+	err := database.GetDatabase().DeleteJob(id)
+	return errors.Wrapf(err, errDeleteJobFromDatabase, kind, id)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,87 @@
+package route53
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// List returns a page of domains from the database bookkeeping instead of the route53 api.
+// Pages are keyed by token id, so domains created during a listing are appended to the last pages.
+func (b *Backend) List(opts *model.ListOptions) (l model.DomainList, err error) {
+	logrus.Debugf("list %s records with limit %d", typeA, opts.Limit)
+
+	var lastID int64
+	if opts.Continue != "" {
+		v, err := base64.RawURLEncoding.DecodeString(opts.Continue)
+		if err != nil {
+			return l, errors.Errorf(errInvalidContinue, opts.Continue)
+		}
+		lastID, err = strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return l, errors.Errorf(errInvalidContinue, opts.Continue)
+		}
+	}
+
+	tokens, err := database.GetDatabase().ListTokens(lastID, opts.Limit)
+	if err != nil {
+		return l, errors.Wrap(err, errListTokensFromDatabase)
+	}
+
+	items := make([]model.Domain, 0)
+	for _, t := range tokens {
+		items = append(items, b.listDomain(t))
+	}
+
+	if int64(len(tokens)) == opts.Limit {
+		last := strconv.FormatInt(tokens[len(tokens)-1].ID, 10)
+		l.Continue = base64.RawURLEncoding.EncodeToString([]byte(last))
+	}
+	l.Items = items
+
+	return l, nil
+}
+
+// Used to build a listed domain from the database records of its token
+func (b *Backend) listDomain(t *model.Token) model.Domain {
+	d := model.Domain{
+		Fqdn:       t.Fqdn,
+		Expiration: convertExpiration(time.Unix(0, t.CreatedOn), int(b.LeaseTime.Nanoseconds())),
+	}
+
+	if c, err := database.GetDatabase().QueryCNAME(t.Fqdn); err == nil && c.Fqdn != "" {
+		d.CNAME = c.Content
+		return d
+	}
+
+	for _, name := range []string{t.Fqdn, fmt.Sprintf("%s.%s", "empty", t.Fqdn)} {
+		a, err := database.GetDatabase().QueryA(name)
+		if err != nil || a.Fqdn == "" {
+			continue
+		}
+
+		if a.Content != "" {
+			d.Hosts = strings.Split(a.Content, ",")
+		}
+
+		subs, _ := database.GetDatabase().ListSubA(a.ID)
+		if len(subs) > 0 {
+			ss := make(map[string][]string, 0)
+			for _, sub := range subs {
+				ss[strings.Split(sub.Fqdn, ".")[0]] = strings.Split(sub.Content, ",")
+			}
+			d.SubDomain = ss
+		}
+		break
+	}
+
+	return d
+}
@@ -0,0 +1,34 @@
+package route53
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+// AppliedMigrations returns the data migrations which are recorded as applied with their apply time.
+func (b *Backend) AppliedMigrations() (map[string]time.Time, error) {
+	ms, err := database.GetDatabase().ListDataMigrations()
+	if err != nil {
+		return nil, errors.Wrap(err, errListMigrationsFromDatabase)
+	}
+
+	applied := make(map[string]time.Time, len(ms))
+	for _, m := range ms {
+		applied[m.ID] = time.Unix(m.AppliedOn, 0)
+	}
+
+	return applied, nil
+}
+
+// RecordMigration records a data migration as applied.
+func (b *Backend) RecordMigration(id string) error {
+	err := database.GetDatabase().InsertDataMigration(&model.DataMigration{
+		ID:        id,
+		AppliedOn: time.Now().Unix(),
+	})
+	return errors.Wrapf(err, errInsertMigrationToDatabase, id)
+}
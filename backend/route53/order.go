@@ -0,0 +1,121 @@
+package route53
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/pkg/errors"
+)
+
+// Used to set the TXT value of an ACME order, the TXT record set is rewritten with the
+// values of all orders of the fqdn so concurrent orders don't overwrite each other.
+func (b *Backend) setOrderText(opts *model.DomainOptions) (d model.Domain, err error) {
+	token, err := database.GetDatabase().QueryToken(b.findSlugWithZone(opts.Fqdn))
+	if err != nil {
+		return d, errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
+	}
+
+	err = database.GetDatabase().SetTXTOrder(&model.TXTOrder{
+		Fqdn:      opts.Fqdn,
+		OrderID:   opts.Order,
+		Content:   opts.Text,
+		CreatedOn: time.Now().Unix(),
+		TID:       token.ID,
+	})
+	if err != nil {
+		return d, errors.Wrapf(err, errSetTXTOrderToDatabase, opts.Fqdn, opts.Order)
+	}
+
+	if err := b.syncOrderText(opts, token.ID); err != nil {
+		return d, err
+	}
+
+	return b.getOrderText(opts)
+}
+
+func (b *Backend) getOrderText(opts *model.DomainOptions) (d model.Domain, err error) {
+	t, err := database.GetDatabase().QueryTXTOrder(opts.Fqdn, opts.Order)
+	if err != nil {
+		return d, errors.Wrapf(err, errQueryTXTOrderFromDatabase, opts.Fqdn, opts.Order)
+	}
+
+	token, err := database.GetDatabase().QueryToken(b.findSlugWithZone(opts.Fqdn))
+	if err != nil {
+		return d, errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
+	}
+
+	d.Fqdn = opts.Fqdn
+	d.Text = t.Content
+	d.Order = t.OrderID
+	d.Expiration = convertExpiration(time.Unix(0, token.CreatedOn), int(b.LeaseTime.Nanoseconds()))
+	if b.ChallengeTTL > 0 && util.IsACMEChallenge(opts.Fqdn) {
+		d.Expiration = convertExpiration(time.Unix(t.CreatedOn, 0), int(b.ChallengeTTL.Nanoseconds()))
+	}
+	d.Propagation = b.propagations.status(opts.Fqdn)
+
+	return d, nil
+}
+
+// Used to remove the TXT value of a completed ACME order, the values of other orders are kept
+func (b *Backend) deleteOrderText(opts *model.DomainOptions) error {
+	if err := database.GetDatabase().DeleteTXTOrder(opts.Fqdn, opts.Order); err != nil {
+		return errors.Wrapf(err, errDeleteRecordsFromDatabase, typeTXT, opts.Fqdn)
+	}
+
+	token, err := database.GetDatabase().QueryToken(b.findSlugWithZone(opts.Fqdn))
+	if err != nil {
+		return errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
+	}
+
+	return b.syncOrderText(opts, token.ID)
+}
+
+// Used to write the TXT record set of an fqdn with the values of all its orders,
+// the record set is deleted when no order is left.
+func (b *Backend) syncOrderText(opts *model.DomainOptions, tID int64) error {
+	orders, err := database.GetDatabase().ListTXTOrders(opts.Fqdn)
+	if err != nil {
+		return errors.Wrapf(err, errQueryTXTFromDatabase, opts.Fqdn)
+	}
+
+	if len(orders) == 0 {
+		return b.DeleteText(&model.DomainOptions{Fqdn: opts.Fqdn})
+	}
+
+	rr := make([]*route53.ResourceRecord, 0, len(orders))
+	for _, o := range orders {
+		rr = append(rr, &route53.ResourceRecord{
+			Value: aws.String(fmt.Sprintf("\"%s\"", o.Content)),
+		})
+	}
+
+	rrs := &route53.ResourceRecordSet{
+		Name:            aws.String(opts.Fqdn),
+		Type:            aws.String(typeTXT),
+		ResourceRecords: rr,
+		TTL:             aws.Int64(int64(b.TTL)),
+	}
+
+	_, err = b.setRecord(rrs, opts, typeTXT, tID, 0, false)
+	return err
+}
+
+// Used to forget the values of all orders of an fqdn, the record set is left as it is
+func (b *Backend) deleteOrders(fqdn string) error {
+	orders, err := database.GetDatabase().ListTXTOrders(fqdn)
+	if err != nil {
+		return errors.Wrapf(err, errQueryTXTFromDatabase, fqdn)
+	}
+	for _, o := range orders {
+		if err := database.GetDatabase().DeleteTXTOrder(fqdn, o.OrderID); err != nil {
+			return errors.Wrapf(err, errDeleteRecordsFromDatabase, typeTXT, fqdn)
+		}
+	}
+	return nil
+}
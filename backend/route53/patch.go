@@ -0,0 +1,25 @@
+package route53
+
+import (
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PatchHosts updates the A records with the patched hosts, route53 applies the records of a
+// change batch at once but a concurrent patch of the domain may be overwritten.
+func (b *Backend) PatchHosts(fqdn string, add, remove []string) (d model.Domain, err error) {
+	logrus.Debugf("patch A record for domain %s: add %v, remove %v", fqdn, add, remove)
+
+	d, err = b.Get(&model.DomainOptions{Fqdn: fqdn})
+	if err != nil {
+		return d, err
+	}
+
+	p := &model.HostsPatch{Add: add, Remove: remove}
+	return b.Update(&model.DomainOptions{
+		Fqdn:      fqdn,
+		Hosts:     p.Apply(d.Hosts),
+		SubDomain: d.SubDomain,
+	})
+}
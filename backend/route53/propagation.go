@@ -0,0 +1,115 @@
+package route53
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// route53 propagates changes to all of its name servers within 60 seconds
+	propagationDeadline = 60 * time.Second
+	// the status of a pending change is asked for at most once per propagationPoll
+	propagationPoll = 5 * time.Second
+	// the changes past their deadline are forgotten once maxPropagations changes are pending
+	maxPropagations = 10000
+)
+
+// propagations keeps the last pending change of every domain until route53 reports it in sync,
+// the name servers of route53 answer either the old or the new records until then.
+type propagations struct {
+	svc *route53.Route53
+
+	mu      sync.Mutex
+	changes map[string]*propagation
+}
+
+type propagation struct {
+	model.Propagation
+	checked time.Time
+}
+
+func newPropagations(svc *route53.Route53) *propagations {
+	return &propagations{
+		svc:     svc,
+		changes: make(map[string]*propagation),
+	}
+}
+
+func (p *propagations) track(fqdn, changeID string) {
+	if changeID == "" {
+		return
+	}
+
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.changes) >= maxPropagations {
+		p.forget(now)
+	}
+	p.changes[fqdn] = &propagation{
+		Propagation: model.Propagation{
+			ChangeID: changeID,
+			Status:   model.PropagationPending,
+			Deadline: now.Add(propagationDeadline).UTC(),
+		},
+		checked: now,
+	}
+}
+
+// Used to get the propagation of the last change of a domain, nil means no change is pending.
+// A change is reported in sync once, it is forgotten afterwards.
+func (p *propagations) status(fqdn string) *model.Propagation {
+	now := time.Now()
+
+	p.mu.Lock()
+	c, ok := p.changes[fqdn]
+	if !ok {
+		p.mu.Unlock()
+		return nil
+	}
+	result := c.Propagation
+	poll := now.Sub(c.checked) >= propagationPoll
+	if poll {
+		c.checked = now
+	}
+	p.mu.Unlock()
+
+	if !poll {
+		return &result
+	}
+
+	out, err := p.svc.GetChange(&route53.GetChangeInput{Id: aws.String(result.ChangeID)})
+	if err != nil {
+		logrus.Warn(errors.Wrapf(err, errGetChange, result.ChangeID))
+		return &result
+	}
+	if aws.StringValue(out.ChangeInfo.Status) != route53.ChangeStatusInsync {
+		return &result
+	}
+
+	p.mu.Lock()
+	if p.changes[fqdn] == c {
+		delete(p.changes, fqdn)
+	}
+	p.mu.Unlock()
+
+	result.Status = model.PropagationInSync
+	return &result
+}
+
+// Used to forget the changes which are past their deadline, they are in sync by then
+func (p *propagations) forget(now time.Time) {
+	for fqdn, c := range p.changes {
+		if now.After(c.Deadline) {
+			delete(p.changes, fqdn)
+		}
+	}
+}
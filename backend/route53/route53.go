@@ -8,7 +8,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/config"
 	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/database/mysql"
 	"github.com/rancher/rdns-server/model"
 	"github.com/rancher/rdns-server/util"
 
@@ -26,7 +29,6 @@ const (
 	typeTXT          = "TXT"
 	typeCNAME        = "CNAME"
 	maxSlugHashTimes = 100
-	slugLength       = 6
 	tokenLength      = 32
 )
 
@@ -35,8 +37,26 @@ type Backend struct {
 	Zone      string
 	ZoneID    string
 	TTL       int64
+	// ChallengeTTL is the lifetime of ACME challenge TXT records, 0 means the domain lease
+	ChallengeTTL time.Duration
 
 	Svc *route53.Route53
+
+	changes      *coalescer
+	propagations *propagations
+}
+
+func init() {
+	backend.Register(Name, func(cfg *backend.Config) (backend.Backend, error) {
+		if cfg.DSN != "" {
+			d, err := mysql.NewDatabase(cfg.DSN)
+			if err != nil {
+				return nil, err
+			}
+			database.SetDatabase(d)
+		}
+		return NewBackend()
+	})
 }
 
 func NewBackend() (*Backend, error) {
@@ -69,12 +89,27 @@ func NewBackend() (*Backend, error) {
 		return &Backend{}, errors.Wrapf(err, errParseFlag, "ttl")
 	}
 
+	interval, err := time.ParseDuration(os.Getenv("ROUTE53_COALESCE_INTERVAL"))
+	if err != nil {
+		return &Backend{}, errors.Wrapf(err, errParseFlag, "route53_coalesce_interval")
+	}
+
+	challenge, err := time.ParseDuration(os.Getenv("ACME_TXT_TTL"))
+	if err != nil {
+		return &Backend{}, errors.Wrapf(err, errParseFlag, "acme_txt_ttl")
+	}
+
 	return &Backend{
 		LeaseTime: d,
 		Zone:      strings.TrimRight(aws.StringValue(z.HostedZone.Name), "."),
 		ZoneID:    aws.StringValue(z.HostedZone.Id),
 		Svc:       svc,
 		TTL:       ttl,
+		changes:   newCoalescer(svc, aws.StringValue(z.HostedZone.Id), interval),
+
+		propagations: newPropagations(svc),
+
+		ChallengeTTL: challenge,
 	}, nil
 }
 
@@ -86,6 +121,14 @@ func (b *Backend) GetZone() string {
 	return b.Zone
 }
 
+// Ping only checks the database, route53 calls are rate limited per account.
+func (b *Backend) Ping() error {
+	if err := database.GetDatabase().Ping(); err != nil {
+		return errors.Wrap(err, errPing)
+	}
+	return nil
+}
+
 func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 	logrus.Debugf("get A record for domain options: %s", opts.String())
 
@@ -126,8 +169,10 @@ func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 		d.Fqdn = opts.Fqdn
 		d.Hosts = strings.Split(e.Content, ",")
 		d.Expiration = convertExpiration(time.Unix(0, token.CreatedOn), int(b.LeaseTime.Nanoseconds()))
+		d.Propagation = b.propagations.status(opts.Fqdn)
+		d.Labels, err = b.tokenLabels(token)
 
-		return d, nil
+		return d, err
 	}
 
 	// convert A & sub domain records to map
@@ -137,15 +182,32 @@ func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 	d.Hosts = ca[opts.Fqdn]
 	d.SubDomain = cs
 	d.Expiration = convertExpiration(time.Unix(0, token.CreatedOn), int(b.LeaseTime.Nanoseconds()))
+	d.Propagation = b.propagations.status(opts.Fqdn)
+	d.Labels, err = b.tokenLabels(token)
 
-	return d, nil
+	return d, err
 }
 
 func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
 	logrus.Debugf("set A record for domain options: %s", opts.String())
 
-	for i := 0; i < maxSlugHashTimes; i++ {
-		fqdn := fmt.Sprintf("%s.%s", generateSlug(), b.Zone)
+	if model.HasIPv6(opts.Hosts, opts.SubDomain) {
+		return d, errors.Errorf(errNotSupportedIPv6, opts.Fqdn)
+	}
+
+	if opts.Lease > 0 {
+		return d, errors.Errorf(errNotSupportedLease, opts.Fqdn)
+	}
+
+	if opts.Name != "" {
+		opts.Fqdn = fmt.Sprintf("%s.%s", opts.Name, b.Zone)
+		if d, err := b.Get(&model.DomainOptions{Fqdn: opts.Fqdn}); err == nil && d.Fqdn != "" {
+			return d, errors.Wrapf(backend.ErrNameTaken, errRequestName, opts.Name)
+		}
+	}
+
+	for i := 0; i < maxSlugHashTimes && opts.Fqdn == ""; i++ {
+		fqdn := fmt.Sprintf("%s.%s", generateSlug(b.Zone), b.Zone)
 
 		// check whether this slug name can be used or not, if not found the slug name is valid, others not valid
 		r, err := database.GetDatabase().QueryFrozen(strings.Split(fqdn, ".")[0])
@@ -172,8 +234,12 @@ func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
 		return d, errors.Errorf(errGenerateName, opts.String())
 	}
 
-	// save the slug name to the database in case of the name will be re-generate
+	// save the slug name to the database in case of the name will be re-generate,
+	// the prefix is unique so a requested name which is frozen already is refused
 	if err := database.GetDatabase().InsertFrozen(strings.Split(opts.Fqdn, ".")[0]); err != nil {
+		if r, _ := database.GetDatabase().QueryFrozen(strings.Split(opts.Fqdn, ".")[0]); opts.Name != "" && r != "" {
+			return d, errors.Wrapf(backend.ErrNameTaken, errRequestName, opts.Name)
+		}
 		return d, errors.Wrapf(err, errInsertFrozenToDatabase, strings.Split(opts.Fqdn, ".")[0])
 	}
 
@@ -238,12 +304,21 @@ func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
 		}
 	}
 
-	return b.Get(opts)
+	d, err = b.Get(opts)
+	if err != nil {
+		return d, err
+	}
+
+	return d, b.setIndexes(&d, opts.Labels, opts.CreatorIP)
 }
 
 func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error) {
 	logrus.Debugf("update A record for domain options: %s", opts.String())
 
+	if model.HasIPv6(opts.Hosts, opts.SubDomain) {
+		return d, errors.Errorf(errNotSupportedIPv6, opts.Fqdn)
+	}
+
 	records, err := b.getRecords(opts, typeA)
 	if err != nil {
 		return d, err
@@ -355,7 +430,12 @@ func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error)
 		}
 	}
 
-	return b.Get(opts)
+	d, err = b.Get(opts)
+	if err != nil {
+		return d, err
+	}
+
+	return d, b.setIndexes(&d, opts.Labels, "")
 }
 
 func (b *Backend) Delete(opts *model.DomainOptions) error {
@@ -390,7 +470,7 @@ func (b *Backend) Delete(opts *model.DomainOptions) error {
 		return errors.Wrapf(err, errDeleteAFromDatabase, emptyName)
 	}
 
-	return nil
+	return b.deleteIndexes(opts.Fqdn)
 }
 
 func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
@@ -417,11 +497,24 @@ func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
 	}, nil
 }
 
+// Keepalive renews the token and frozen records like Renew, the records of route53 have no lease.
+func (b *Backend) Keepalive(fqdn string) (time.Time, error) {
+	d, err := b.Renew(&model.DomainOptions{Fqdn: fqdn})
+	if err != nil || d.Expiration == nil {
+		return time.Time{}, err
+	}
+	return *d.Expiration, nil
+}
+
 func (b *Backend) SetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
 	logrus.Debugf("set CNAME record for domain options: %s", opts.String())
 
+	if opts.Lease > 0 {
+		return d, errors.Errorf(errNotSupportedLease, opts.Fqdn)
+	}
+
 	for i := 0; i < maxSlugHashTimes; i++ {
-		fqdn := fmt.Sprintf("%s.%s", generateSlug(), b.Zone)
+		fqdn := fmt.Sprintf("%s.%s", generateSlug(b.Zone), b.Zone)
 
 		// check whether this slug name can be used or not, if not found the slug name is valid, others not valid
 		r, err := database.GetDatabase().QueryFrozen(strings.Split(fqdn, ".")[0])
@@ -506,8 +599,10 @@ func (b *Backend) GetCNAME(opts *model.DomainOptions) (d model.Domain, err error
 	d.Fqdn = opts.Fqdn
 	d.CNAME = aws.StringValue(c[0].ResourceRecords[0].Value)
 	d.Expiration = convertExpiration(time.Unix(0, token.CreatedOn), int(b.LeaseTime.Nanoseconds()))
+	d.Propagation = b.propagations.status(opts.Fqdn)
+	d.Labels, err = b.tokenLabels(token)
 
-	return d, nil
+	return d, err
 }
 
 func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
@@ -586,6 +681,10 @@ func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
 func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error) {
 	logrus.Debugf("get TXT record for domain options: %s", opts.String())
 
+	if opts.Order != "" {
+		return b.getOrderText(opts)
+	}
+
 	records, err := b.getRecords(opts, typeTXT)
 	if err != nil {
 		return d, err
@@ -602,9 +701,15 @@ func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error)
 		return d, errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
 	}
 
+	// the record set holds the values of all orders of the fqdn
+	for _, rr := range t[0].ResourceRecords {
+		d.Texts = append(d.Texts, strings.Trim(aws.StringValue(rr.Value), "\""))
+	}
+
 	d.Fqdn = opts.Fqdn
-	d.Text = strings.Trim(aws.StringValue(t[0].ResourceRecords[0].Value), "\"")
-	d.Expiration = convertExpiration(time.Unix(0, token.CreatedOn), int(b.LeaseTime.Nanoseconds()))
+	d.Text = d.Texts[0]
+	d.Expiration = b.textExpiration(opts.Fqdn, token)
+	d.Propagation = b.propagations.status(opts.Fqdn)
 
 	return d, nil
 }
@@ -612,6 +717,10 @@ func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error)
 func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error) {
 	logrus.Debugf("set TXT record for domain options: %s", opts.String())
 
+	if opts.Order != "" {
+		return b.setOrderText(opts)
+	}
+
 	records, err := b.getRecords(opts, typeTXT)
 	if err != nil {
 		return d, err
@@ -647,6 +756,20 @@ func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error)
 func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err error) {
 	logrus.Debugf("update TXT record for domain options: %s", opts.String())
 
+	if opts.Replace {
+		if _, err := b.GetText(opts.ReplacedText()); err != nil {
+			return d, err
+		}
+		// the record set is written with the value of its order alone
+		if err := b.deleteOrders(opts.Fqdn); err != nil {
+			return d, err
+		}
+		return b.setOrderText(opts)
+	}
+	if opts.Order != "" {
+		return b.setOrderText(opts)
+	}
+
 	records, err := b.getRecords(opts, typeTXT)
 	if err != nil {
 		return d, err
@@ -656,6 +779,11 @@ func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err err
 		return d, errors.Errorf(errFilterRecords, typeTXT, opts.Fqdn)
 	}
 
+	// the value replaces the values of all orders in the record set
+	if err := b.deleteOrders(opts.Fqdn); err != nil {
+		return d, err
+	}
+
 	r, err := database.GetDatabase().QueryTXT(opts.Fqdn)
 	if err != nil {
 		return d, errors.Wrapf(err, errQueryTXTFromDatabase, opts.Fqdn)
@@ -685,7 +813,8 @@ func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err err
 	d.Fqdn = opts.Fqdn
 	d.Hosts = opts.Hosts
 	d.Text = opts.Text
-	d.Expiration = convertExpiration(time.Unix(0, token.CreatedOn), int(b.LeaseTime.Nanoseconds()))
+	d.Expiration = b.textExpiration(opts.Fqdn, token)
+	d.Propagation = b.propagations.status(opts.Fqdn)
 
 	return d, nil
 }
@@ -693,6 +822,13 @@ func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err err
 func (b *Backend) DeleteText(opts *model.DomainOptions) error {
 	logrus.Debugf("delete TXT record for domain options: %s", opts.String())
 
+	if opts.Order != "" {
+		return b.deleteOrderText(opts)
+	}
+	if err := b.deleteOrders(opts.Fqdn); err != nil {
+		return err
+	}
+
 	records, err := b.getRecords(opts, typeTXT)
 	if err != nil {
 		return err
@@ -752,6 +888,9 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 			return err
 		}
 	} else {
+		if model.HasIPv6(opts.Hosts, opts.SubDomain) {
+			return errors.Errorf(errNotSupportedIPv6, opts.Fqdn)
+		}
 		dopts := &model.DomainOptions{
 			Fqdn:      opts.Fqdn,
 			Hosts:     opts.Hosts,
@@ -822,10 +961,27 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 				return err
 			}
 		}
+
+		d, err := b.Get(dopts)
+		if err != nil {
+			return err
+		}
+		return b.setIndexes(&d, nil, "")
 	}
 	return nil
 }
 
+// Used to get the expiration of a TXT record, ACME challenge records expire a challenge ttl
+// after they are written and are deleted by the purger.
+func (b *Backend) textExpiration(fqdn string, token *model.Token) *time.Time {
+	if b.ChallengeTTL > 0 && util.IsACMEChallenge(fqdn) {
+		if t, err := database.GetDatabase().QueryTXT(fqdn); err == nil && t.Fqdn != "" {
+			return convertExpiration(time.Unix(t.CreatedOn, 0), int(b.ChallengeTTL.Nanoseconds()))
+		}
+	}
+	return convertExpiration(time.Unix(0, token.CreatedOn), int(b.LeaseTime.Nanoseconds()))
+}
+
 // Used to set record to database
 func (b *Backend) setRecordToDatabase(rrs *route53.ResourceRecordSet, rType string, tID, pID int64, sub bool) (int64, error) {
 	content := make([]string, 0)
@@ -946,21 +1102,16 @@ func (b *Backend) getRecords(opts *model.DomainOptions, rType string) (*route53.
 //     sub: whether is sub domain or not
 func (b *Backend) setRecord(rrs *route53.ResourceRecordSet, opts *model.DomainOptions, rType string, tID, pID int64, sub bool) (int64, error) {
 	if len(rrs.ResourceRecords) >= 1 {
-		input := route53.ChangeResourceRecordSetsInput{
-			HostedZoneId: aws.String(b.ZoneID),
-			ChangeBatch: &route53.ChangeBatch{
-				Changes: []*route53.Change{
-					{
-						Action:            aws.String("UPSERT"),
-						ResourceRecordSet: rrs,
-					},
-				},
-			},
+		change := &route53.Change{
+			Action:            aws.String("UPSERT"),
+			ResourceRecordSet: rrs,
 		}
 
-		if _, err := b.Svc.ChangeResourceRecordSets(&input); err != nil {
+		id, err := b.changes.Change(change)
+		if err != nil {
 			return 0, errors.Wrapf(err, errUpsertRoute53Record, rType, opts.Fqdn)
 		}
+		b.propagations.track(opts.Fqdn, id)
 	}
 
 	// set record to database
@@ -977,25 +1128,20 @@ func (b *Backend) setRecord(rrs *route53.ResourceRecordSet, opts *model.DomainOp
 //     rType: record's type(0: TXT, 1: A, 2: SUB)
 //     sub: whether is sub domain or not
 func (b *Backend) deleteRecord(rrs *route53.ResourceRecordSet, opts *model.DomainOptions, rType string, sub bool) error {
-	input := route53.ChangeResourceRecordSetsInput{
-		HostedZoneId: aws.String(b.ZoneID),
-		ChangeBatch: &route53.ChangeBatch{
-			Changes: []*route53.Change{
-				{
-					Action: aws.String("DELETE"),
-					ResourceRecordSet: &route53.ResourceRecordSet{
-						Name:            rrs.Name,
-						Type:            aws.String(rType),
-						ResourceRecords: rrs.ResourceRecords,
-						TTL:             aws.Int64(int64(b.TTL)),
-					},
-				},
-			},
+	change := &route53.Change{
+		Action: aws.String("DELETE"),
+		ResourceRecordSet: &route53.ResourceRecordSet{
+			Name:            rrs.Name,
+			Type:            aws.String(rType),
+			ResourceRecords: rrs.ResourceRecords,
+			TTL:             aws.Int64(int64(b.TTL)),
 		},
 	}
-	if _, err := b.Svc.ChangeResourceRecordSets(&input); err != nil {
+	id, err := b.changes.Change(change)
+	if err != nil {
 		return errors.Wrapf(err, errDeleteRoute53Record, rType, opts.Fqdn)
 	}
+	b.propagations.track(opts.Fqdn, id)
 
 	// delete record from database
 	if err := b.deleteRecordFromDatabase(rrs, rType, sub); err != nil {
@@ -1100,9 +1246,9 @@ func (b *Backend) findSlugWithZone(fqdn string) string {
 	return ss[1]
 }
 
-// Used to generate a random slug
-func generateSlug() string {
-	return util.RandStringWithSmall(slugLength)
+// Used to generate a random slug with the slug length of the zone
+func generateSlug(zone string) string {
+	return util.RandStringWithSmall(config.Resolve(zone).SlugLength)
 }
 
 // Used to generate a random token
@@ -0,0 +1,160 @@
+package route53
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	indexLabel   = "label"
+	indexCreator = "creator"
+)
+
+// Search returns a page of domains which match all filters, the filters are resolved by
+// the database with the record_host and domain_index tables, the token creation time and the TXT records.
+func (b *Backend) Search(opts *model.SearchOptions) (l model.DomainList, err error) {
+	logrus.Debugf("search %s records with filters: %+v", typeA, opts)
+
+	f := &model.TokenFilter{
+		Host: opts.Host,
+		Indexes: map[string]string{
+			indexLabel:   opts.Label,
+			indexCreator: opts.CreatorIP,
+		},
+		Text:  opts.Text,
+		Limit: opts.Limit,
+	}
+
+	// a token expires a lease time after it is created or renewed
+	if opts.ExpiringAfter != nil {
+		f.CreatedAfter = opts.ExpiringAfter.Add(-b.LeaseTime).UnixNano()
+	}
+	if opts.ExpiringBefore != nil {
+		f.CreatedBefore = opts.ExpiringBefore.Add(-b.LeaseTime).UnixNano()
+	}
+
+	if opts.Continue != "" {
+		v, err := base64.RawURLEncoding.DecodeString(opts.Continue)
+		if err != nil {
+			return l, errors.Errorf(errInvalidContinue, opts.Continue)
+		}
+		f.LastID, err = strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return l, errors.Errorf(errInvalidContinue, opts.Continue)
+		}
+	}
+
+	tokens, err := database.GetDatabase().SearchTokens(f)
+	if err != nil {
+		return l, errors.Wrap(err, errListTokensFromDatabase)
+	}
+
+	items := make([]model.Domain, 0)
+	for _, t := range tokens {
+		d := b.listDomain(t)
+
+		indexes, err := database.GetDatabase().ListIndexes(t.ID)
+		if err != nil {
+			return l, errors.Wrapf(err, errQueryIndexesFromDatabase, t.Fqdn)
+		}
+		d.Labels = indexLabels(indexes)
+		if len(indexes[indexCreator]) > 0 {
+			d.CreatorIP = indexes[indexCreator][0]
+		}
+
+		items = append(items, d)
+	}
+
+	if int64(len(tokens)) == opts.Limit {
+		last := strconv.FormatInt(tokens[len(tokens)-1].ID, 10)
+		l.Continue = base64.RawURLEncoding.EncodeToString([]byte(last))
+	}
+	l.Items = items
+
+	return l, nil
+}
+
+// HostDomains returns the domains which have a record pointing at the host, the record_host
+// rows are written in the same transaction as the A records.
+func (b *Backend) HostDomains(host string) ([]string, error) {
+	tokens, err := database.GetDatabase().QueryHostTokens(host)
+	if err != nil {
+		return nil, errors.Wrapf(err, errQueryHostFromDatabase, host)
+	}
+
+	fqdns := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		fqdns = append(fqdns, t.Fqdn)
+	}
+
+	return fqdns, nil
+}
+
+// Used to rewrite the domain_index rows of a domain, nil labels and an empty creator keep
+// the stored rows. Hosts are kept in the record_host table with the A records.
+func (b *Backend) setIndexes(d *model.Domain, labels map[string]string, creatorIP string) error {
+	t, err := database.GetDatabase().QueryToken(d.Fqdn)
+	if err != nil {
+		return errors.Wrapf(err, errQueryTokenFromDatabase, d.Fqdn)
+	}
+
+	indexes := make(map[string][]string)
+	if labels != nil {
+		indexes[indexLabel] = model.LabelValues(labels)
+	}
+	if creatorIP != "" {
+		indexes[indexCreator] = []string{creatorIP}
+	}
+
+	for name, values := range indexes {
+		if err := database.GetDatabase().SetIndexes(t.ID, name, values); err != nil {
+			return errors.Wrapf(err, errSetIndexesToDatabase, d.Fqdn)
+		}
+	}
+
+	return nil
+}
+
+func (b *Backend) deleteIndexes(fqdn string) error {
+	t, err := database.GetDatabase().QueryToken(fqdn)
+	if err != nil {
+		return errors.Wrapf(err, errQueryTokenFromDatabase, fqdn)
+	}
+
+	for _, name := range []string{indexLabel, indexCreator} {
+		if err := database.GetDatabase().SetIndexes(t.ID, name, nil); err != nil {
+			return errors.Wrapf(err, errSetIndexesToDatabase, fqdn)
+		}
+	}
+
+	return nil
+}
+
+// Used to get the labels of the domain of a token, nil when it has none
+func (b *Backend) tokenLabels(t *model.Token) (map[string]string, error) {
+	indexes, err := database.GetDatabase().ListIndexes(t.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, errQueryIndexesFromDatabase, t.Fqdn)
+	}
+	return indexLabels(indexes), nil
+}
+
+// e.g. {label: [tenant=acme]} => {tenant: acme}
+func indexLabels(indexes map[string][]string) map[string]string {
+	if len(indexes[indexLabel]) == 0 {
+		return nil
+	}
+	labels := make(map[string]string)
+	for _, v := range indexes[indexLabel] {
+		kv := strings.SplitN(v, "=", 2)
+		labels[kv[0]] = kv[1]
+	}
+	return labels
+}
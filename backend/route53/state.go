@@ -0,0 +1,80 @@
+package route53
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// GetOperationalState returns the state of the key, a key which is never written has version 0.
+func (b *Backend) GetOperationalState(key string) (model.OperationalState, error) {
+	r, err := database.GetDatabase().QueryOperationalState(key)
+	if err == sql.ErrNoRows {
+		return model.OperationalState{Key: key}, nil
+	}
+	if err != nil {
+		return model.OperationalState{Key: key}, errors.Wrapf(err, errQueryStateFromDatabase, key)
+	}
+	return toOperationalState(r), nil
+}
+
+// SetOperationalState writes the state when its version is still the one in the database, the
+// version of the written state is set to s.
+func (b *Backend) SetOperationalState(s *model.OperationalState) error {
+	logrus.Debugf("set operational state: %s", s.Key)
+
+	t := time.Now()
+	r := &model.DatabaseOperationalState{
+		Key:       s.Key,
+		Value:     string(s.Value),
+		Version:   s.Version + 1,
+		UpdatedOn: t.Unix(),
+	}
+
+	var ok bool
+	var err error
+	if s.Version == 0 {
+		ok, err = database.GetDatabase().InsertOperationalState(r)
+	} else {
+		ok, err = database.GetDatabase().UpdateOperationalState(r, s.Version)
+	}
+	if err != nil {
+		return errors.Wrapf(err, errSetStateToDatabase, s.Key)
+	}
+	if !ok {
+		return errors.Wrapf(backend.ErrStateConflict, errSetStateToDatabase, s.Key)
+	}
+
+	s.Version, s.Updated = r.Version, &t
+	return nil
+}
+
+func (b *Backend) ListOperationalStates() ([]model.OperationalState, error) {
+	rs, err := database.GetDatabase().ListOperationalStates()
+	if err != nil {
+		return nil, errors.Wrap(err, errListStatesFromDatabase)
+	}
+
+	result := make([]model.OperationalState, 0, len(rs))
+	for _, r := range rs {
+		result = append(result, toOperationalState(r))
+	}
+	return result, nil
+}
+
+func toOperationalState(r *model.DatabaseOperationalState) model.OperationalState {
+	t := time.Unix(r.UpdatedOn, 0)
+	return model.OperationalState{
+		Key:     r.Key,
+		Value:   json.RawMessage(r.Value),
+		Version: r.Version,
+		Updated: &t,
+	}
+}
@@ -0,0 +1,55 @@
+package route53
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Suspend keeps the suspension in the database, suspending a domain again only
+// updates its reason.
+func (b *Backend) Suspend(s *model.Suspension) error {
+	logrus.Debugf("suspend domain: %s", s.Fqdn)
+
+	createdOn := time.Now()
+	if s.Time != nil {
+		createdOn = *s.Time
+	}
+
+	err := database.GetDatabase().SetSuspension(&model.SuspendedDomain{
+		Fqdn:      s.Fqdn,
+		Reason:    s.Reason,
+		CreatedOn: createdOn.Unix(),
+	})
+	return errors.Wrapf(err, errSetSuspensionToDatabase, s.Fqdn)
+}
+
+func (b *Backend) Unsuspend(fqdn string) error {
+	logrus.Debugf("unsuspend domain: %s", fqdn)
+
+	err := database.GetDatabase().DeleteSuspension(fqdn)
+	return errors.Wrapf(err, errDeleteSuspensionFromDatabase, fqdn)
+}
+
+func (b *Backend) ListSuspensions() ([]model.Suspension, error) {
+	rs, err := database.GetDatabase().ListSuspensions()
+	if err != nil {
+		return nil, errors.Wrap(err, errListSuspensionsFromDatabase)
+	}
+
+	result := make([]model.Suspension, 0, len(rs))
+	for _, r := range rs {
+		t := time.Unix(r.CreatedOn, 0)
+		result = append(result, model.Suspension{
+			Fqdn:   r.Fqdn,
+			Reason: r.Reason,
+			Time:   &t,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package route53
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+// RotateToken replaces the token origin of a domain, the records keep referring to the token row.
+func (b *Backend) RotateToken(fqdn, token string) error {
+	if err := database.GetDatabase().UpdateToken(token, fqdn); err != nil {
+		return errors.Wrapf(err, errUpdateTokenToDatabase, fqdn)
+	}
+	return nil
+}
+
+// SetScopedToken keeps the origin of a secondary token in the database, it references the token of the
+// domain and is deleted together with it.
+func (b *Backend) SetScopedToken(fqdn, scope, origin string) error {
+	t, err := database.GetDatabase().QueryToken(fqdn)
+	if err != nil {
+		return errors.Wrapf(err, errQueryTokenFromDatabase, fqdn)
+	}
+
+	err = database.GetDatabase().SetScopedToken(&model.DomainScopedToken{
+		Fqdn:      fqdn,
+		Scope:     scope,
+		Token:     origin,
+		CreatedOn: time.Now().Unix(),
+		TID:       t.ID,
+	})
+	return errors.Wrapf(err, errSetScopedTokenToDatabase, fqdn, scope)
+}
+
+func (b *Backend) GetScopedToken(fqdn, scope string) (string, error) {
+	t, err := database.GetDatabase().QueryScopedToken(fqdn, scope)
+	if err != nil {
+		return "", errors.Wrapf(err, errQueryScopedTokenFromDatabase, fqdn, scope)
+	}
+	return t.Token, nil
+}
+
+func (b *Backend) DeleteScopedToken(fqdn, scope string) error {
+	err := database.GetDatabase().DeleteScopedToken(fqdn, scope)
+	return errors.Wrapf(err, errDeleteScopedTokenFromDatabase, fqdn, scope)
+}
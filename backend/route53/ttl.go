@@ -0,0 +1,19 @@
+package route53
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+// SetTTL is not supported, every route53 record is written with the TTL option.
+func (b *Backend) SetTTL(fqdn string, ttl uint32) (model.Domain, error) {
+	return model.Domain{}, errors.Errorf(errNotSupportedTTL, fqdn)
+}
+
+// DrainHost is not supported, route53 has no way to leave a host of a record out of its answers.
+func (b *Backend) DrainHost(fqdn, host string, until time.Time) (model.Domain, error) {
+	return model.Domain{}, errors.Errorf(errNotSupportedDrain, fqdn)
+}
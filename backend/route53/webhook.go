@@ -0,0 +1,72 @@
+package route53
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetWebhook keeps the webhook in the database, it references the token of the domain
+// and is deleted together with it.
+func (b *Backend) SetWebhook(w *model.Webhook) error {
+	logrus.Debugf("set webhook %s for domain: %s", w.ID, w.Fqdn)
+
+	t, err := database.GetDatabase().QueryToken(w.Fqdn)
+	if err != nil {
+		return errors.Wrapf(err, errQueryTokenFromDatabase, w.Fqdn)
+	}
+
+	createdOn := time.Now()
+	if w.Time != nil {
+		createdOn = *w.Time
+	}
+	w.Time = &createdOn
+
+	err = database.GetDatabase().InsertWebhook(&model.DomainWebhook{
+		ID:        w.ID,
+		Fqdn:      w.Fqdn,
+		URL:       w.URL,
+		Secret:    w.Secret,
+		Events:    strings.Join(w.Events, ","),
+		CreatedOn: createdOn.Unix(),
+		TID:       t.ID,
+	})
+	return errors.Wrapf(err, errInsertWebhookToDatabase, w.Fqdn)
+}
+
+func (b *Backend) ListWebhooks(fqdn string) ([]model.Webhook, error) {
+	ws, err := database.GetDatabase().ListWebhooks(fqdn)
+	if err != nil {
+		return nil, errors.Wrapf(err, errListWebhooksFromDatabase, fqdn)
+	}
+
+	result := make([]model.Webhook, 0, len(ws))
+	for _, w := range ws {
+		t := time.Unix(w.CreatedOn, 0)
+		hook := model.Webhook{
+			ID:     w.ID,
+			Fqdn:   w.Fqdn,
+			URL:    w.URL,
+			Secret: w.Secret,
+			Time:   &t,
+		}
+		if w.Events != "" {
+			hook.Events = strings.Split(w.Events, ",")
+		}
+		result = append(result, hook)
+	}
+
+	return result, nil
+}
+
+func (b *Backend) DeleteWebhook(fqdn, id string) error {
+	logrus.Debugf("delete webhook %s for domain: %s", id, fqdn)
+
+	err := database.GetDatabase().DeleteWebhook(fqdn, id)
+	return errors.Wrapf(err, errDeleteWebhookFromDatabase, fqdn, id)
+}
@@ -0,0 +1,79 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const selectAPIKeys = "SELECT id, name, tenant, root_domain, hash, created_on FROM api_keys"
+
+// SetAPIKey keeps the api key without an expiration, it is kept until it is revoked.
+func (b *Backend) SetAPIKey(k *model.APIKey) error {
+	logrus.Debugf("set api key: %s", k.ID)
+
+	if k.CreatedOn == nil {
+		t := time.Now()
+		k.CreatedOn = &t
+	}
+
+	if _, err := b.exec(b.DB, b.dialect.insert("api_keys", []string{"id"}, []string{"name", "tenant", "root_domain", "hash", "created_on"}, true),
+		k.ID, k.Name, k.Tenant, k.RootDomain, k.Hash, k.CreatedOn.Unix()); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeAPIKey, k.ID)
+	}
+
+	return nil
+}
+
+func (b *Backend) GetAPIKey(id string) (model.APIKey, error) {
+	k, err := scanAPIKey(b.queryRow(b.DB, selectAPIKeys+" WHERE id = ?", id))
+	if err == dbsql.ErrNoRows {
+		return k, errors.Errorf(errEmptyRecord, typeAPIKey, id)
+	}
+
+	return k, errors.Wrapf(err, errLookupRecords, typeAPIKey, id)
+}
+
+func (b *Backend) ListAPIKeys() ([]model.APIKey, error) {
+	rows, err := b.query(b.DB, selectAPIKeys+" ORDER BY id")
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeAPIKey, "api_keys")
+	}
+	defer rows.Close()
+
+	result := make([]model.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeAPIKey, "api_keys")
+		}
+		result = append(result, k)
+	}
+
+	return result, rows.Err()
+}
+
+func (b *Backend) DeleteAPIKey(id string) error {
+	logrus.Debugf("delete api key: %s", id)
+
+	if _, err := b.exec(b.DB, "DELETE FROM api_keys WHERE id = ?", id); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeAPIKey, id)
+	}
+
+	return nil
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (model.APIKey, error) {
+	var k model.APIKey
+	var created int64
+	if err := row.Scan(&k.ID, &k.Name, &k.Tenant, &k.RootDomain, &k.Hash, &created); err != nil {
+		return k, err
+	}
+	t := time.Unix(created, 0)
+	k.CreatedOn = &t
+	return k, nil
+}
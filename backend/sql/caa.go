@@ -0,0 +1,94 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetCAA replaces the CAA records of a domain, the records are kept in their order and are
+// deleted together with their domain.
+func (b *Backend) SetCAA(fqdn string, records []model.CAARecord) error {
+	logrus.Debugf("set %s records for domain: %s", typeCAA, fqdn)
+
+	return b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, fqdn); err != nil {
+			return errors.Wrapf(err, errEmptyRecord, typeToken, fqdn)
+		}
+		if _, err := b.exec(tx, "DELETE FROM caa_records WHERE domain = ?", fqdn); err != nil {
+			return errors.Wrapf(err, errSetRecord, typeCAA, fqdn)
+		}
+		for i, r := range records {
+			if _, err := b.exec(tx, "INSERT INTO caa_records (domain, seq, flag, tag, content) VALUES (?, ?, ?, ?, ?)", fqdn, i, r.Flag, r.Tag, r.Value); err != nil {
+				return errors.Wrapf(err, errSetRecord, typeCAA, fqdn)
+			}
+		}
+		return nil
+	})
+}
+
+func (b *Backend) GetCAA(fqdn string) ([]model.CAARecord, error) {
+	rows, err := b.query(b.DB, "SELECT flag, tag, content FROM caa_records WHERE domain = ? ORDER BY seq", fqdn)
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeCAA, fqdn)
+	}
+	defer rows.Close()
+
+	records := make([]model.CAARecord, 0)
+	for rows.Next() {
+		var r model.CAARecord
+		if err := rows.Scan(&r.Flag, &r.Tag, &r.Value); err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeCAA, fqdn)
+		}
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeCAA, fqdn)
+	}
+	if len(records) == 0 {
+		return nil, errors.Errorf(errEmptyRecord, typeCAA, fqdn)
+	}
+
+	return records, nil
+}
+
+func (b *Backend) DeleteCAA(fqdn string) error {
+	logrus.Debugf("delete %s records for domain: %s", typeCAA, fqdn)
+
+	if _, err := b.exec(b.DB, "DELETE FROM caa_records WHERE domain = ?", fqdn); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeCAA, fqdn)
+	}
+
+	return nil
+}
+
+// SetQueryTypes keeps the query types a domain is restricted to in the row of the domain, the dns
+// server leaves the records of the other types out of its answers. No types lift the restriction.
+func (b *Backend) SetQueryTypes(fqdn string, types []string) error {
+	logrus.Debugf("set %s of domain %s to %v", typeQueryTypes, fqdn, types)
+
+	return b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, fqdn); err != nil {
+			return errors.Wrapf(err, errEmptyRecord, typeToken, fqdn)
+		}
+		_, err := b.exec(tx, "UPDATE domains SET query_types = ? WHERE fqdn = ?", strings.Join(types, ","), fqdn)
+		return errors.Wrapf(err, errSetRecord, typeQueryTypes, fqdn)
+	})
+}
+
+func (b *Backend) GetQueryTypes(fqdn string) ([]string, error) {
+	var types string
+	err := b.queryRow(b.DB, "SELECT query_types FROM domains WHERE fqdn = ? AND expires_on > ?", fqdn, time.Now().Unix()).Scan(&types)
+	if err == dbsql.ErrNoRows || types == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeQueryTypes, fqdn)
+	}
+	return strings.Split(types, ","), nil
+}
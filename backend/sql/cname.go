@@ -0,0 +1,95 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"net"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The CNAME target is kept in the cname column of the domain, the dns server answers the domain
+// and its sub names with it, a domain with a target has no hosts.
+
+func (b *Backend) SetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("set %s record for domain options: %s", typeCNAME, opts.String())
+
+	if err := checkCNAME(opts.CNAME); err != nil {
+		return d, err
+	}
+
+	// the target is all a CNAME domain answers, the hosts of the options are not written
+	c := &model.DomainOptions{Fqdn: opts.Fqdn, Name: opts.Name, Lease: opts.Lease, Labels: opts.Labels, CreatorIP: opts.CreatorIP}
+	if err := b.create(c, opts.CNAME); err != nil {
+		return d, err
+	}
+	opts.Fqdn = c.Fqdn
+
+	return b.GetCNAME(opts)
+}
+
+func (b *Backend) GetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("get %s record for domain options: %s", typeCNAME, opts.String())
+
+	var expires int64
+	err = b.queryRow(b.DB, "SELECT cname, expires_on FROM domains WHERE fqdn = ? AND cname <> '' AND expires_on > ?", opts.Fqdn, time.Now().Unix()).Scan(&d.CNAME, &expires)
+	if err == dbsql.ErrNoRows {
+		return d, errors.Errorf(errEmptyRecord, typeCNAME, opts.Fqdn)
+	}
+	if err != nil {
+		return d, errors.Wrapf(err, errLookupRecords, typeCNAME, opts.Fqdn)
+	}
+
+	e := time.Unix(expires, 0)
+	d.Fqdn = opts.Fqdn
+	d.Expiration = &e
+
+	items := []model.Domain{d}
+	if err := b.readLabels(items); err != nil {
+		return d, err
+	}
+	return items[0], nil
+}
+
+func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("update %s record for domain options: %s", typeCNAME, opts.String())
+
+	if err := checkCNAME(opts.CNAME); err != nil {
+		return d, err
+	}
+
+	if _, err := b.GetCNAME(opts); err != nil {
+		return d, err
+	}
+
+	if _, err := b.exec(b.DB, "UPDATE domains SET cname = ? WHERE fqdn = ?", opts.CNAME, opts.Fqdn); err != nil {
+		return d, errors.Wrapf(err, errSetRecord, typeCNAME, opts.Fqdn)
+	}
+
+	return b.GetCNAME(opts)
+}
+
+func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
+	logrus.Debugf("delete %s record for domain options: %s", typeCNAME, opts.String())
+
+	if _, err := b.GetCNAME(opts); err != nil {
+		return err
+	}
+
+	if _, err := b.exec(b.DB, "DELETE FROM domains WHERE fqdn = ?", opts.Fqdn); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeCNAME, opts.Fqdn)
+	}
+
+	return nil
+}
+
+// an IP target would be answered as an A record instead of a CNAME
+func checkCNAME(target string) error {
+	if target == "" || net.ParseIP(target) != nil {
+		return errors.Errorf(errNotValidCNAME, target)
+	}
+	return nil
+}
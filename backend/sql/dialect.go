@@ -0,0 +1,90 @@
+package sql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// dialect keeps the differences of the databases, the statements of the backend are written
+// with ? placeholders and only use the types and clauses which both databases understand.
+type dialect struct {
+	// driver is the name the database/sql driver is registered with
+	driver string
+	// options are appended to the statements which create a table
+	options string
+	// numbered placeholders are written as $1, $2 ...
+	numbered bool
+	// upsert formats the clause which updates the columns of a row whose keys exist,
+	// no columns leave the row as it is
+	upsert func(keys, cols []string) string
+}
+
+var dialects = map[string]*dialect{
+	"mysql": {
+		driver:  "mysql",
+		options: " ENGINE=INNODB DEFAULT CHARSET=utf8",
+		upsert: func(keys, cols []string) string {
+			if len(cols) == 0 {
+				// the row is left unchanged, which is counted as no affected row
+				return " ON DUPLICATE KEY UPDATE " + keys[0] + " = " + keys[0]
+			}
+			sets := make([]string, 0, len(cols))
+			for _, c := range cols {
+				sets = append(sets, c+" = VALUES("+c+")")
+			}
+			return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+		},
+	},
+	"postgres": {
+		driver:   "postgres",
+		numbered: true,
+		upsert: func(keys, cols []string) string {
+			if len(cols) == 0 {
+				return " ON CONFLICT (" + strings.Join(keys, ", ") + ") DO NOTHING"
+			}
+			sets := make([]string, 0, len(cols))
+			for _, c := range cols {
+				sets = append(sets, c+" = EXCLUDED."+c)
+			}
+			return " ON CONFLICT (" + strings.Join(keys, ", ") + ") DO UPDATE SET " + strings.Join(sets, ", ")
+		},
+	},
+}
+
+// Used to rewrite the placeholders of a statement for the database
+// e.g. postgres: SELECT token FROM domains WHERE fqdn = ? => SELECT token FROM domains WHERE fqdn = $1
+func (d *dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// Used to get the statement which inserts a row or updates the columns of the row whose keys exist
+// e.g. mysql: labels, [domain name], [content] => INSERT INTO labels (domain, name, content) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE content = VALUES(content)
+func (d *dialect) insert(table string, keys, cols []string, update bool) string {
+	all := append(append([]string{}, keys...), cols...)
+	marks := strings.TrimSuffix(strings.Repeat("?, ", len(all)), ", ")
+	query := "INSERT INTO " + table + " (" + strings.Join(all, ", ") + ") VALUES (" + marks + ")"
+	if update {
+		return query + d.upsert(keys, cols)
+	}
+	return query + d.upsert(keys, nil)
+}
+
+// Used to get the placeholders of an IN clause of n values
+// e.g. 3 => (?, ?, ?)
+func in(n int) string {
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", n), ", ") + ")"
+}
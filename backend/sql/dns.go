@@ -0,0 +1,277 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/dnsutil"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	flagDNSPort = "SQL_DNS_PORT"
+	flagTTL     = "TTL"
+	defaultTTL  = 60
+	soaRefresh  = 3600
+	soaRetry    = 600
+	soaExpire   = 86400
+)
+
+// StartDNSDaemon answers the records of the root domains from the database on SQL_DNS_PORT, so the
+// sql backend needs no dns server of its own. An empty port leaves the answers to another server.
+func (b *Backend) StartDNSDaemon(done chan struct{}) error {
+	port := os.Getenv(flagDNSPort)
+	if port == "" {
+		<-done
+		return nil
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", b.serveDNS)
+
+	errs := make(chan error, 2)
+	servers := make([]*dns.Server, 0)
+	for _, proto := range []string{"udp", "tcp"} {
+		s := &dns.Server{Addr: ":" + port, Net: proto, Handler: mux}
+		servers = append(servers, s)
+		go func(s *dns.Server) {
+			if err := s.ListenAndServe(); err != nil {
+				errs <- errors.Wrapf(err, errServeDNS, s.Addr, s.Net)
+			}
+		}(s)
+	}
+	logrus.Infof("serving dns of %s on port %s", os.Getenv("DOMAIN"), port)
+
+	select {
+	case err := <-errs:
+		for _, s := range servers {
+			s.Shutdown()
+		}
+		return err
+	case <-done:
+		for _, s := range servers {
+			if err := s.Shutdown(); err != nil {
+				logrus.Debugf("failed to shutdown dns server %s/%s: %v", s.Addr, s.Net, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (b *Backend) serveDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	if len(r.Question) != 1 {
+		m.SetRcode(r, dns.RcodeFormatError)
+		w.WriteMsg(m)
+		return
+	}
+	q := r.Question[0]
+	name := strings.ToLower(strings.TrimSuffix(q.Name, "."))
+
+	root := b.rootOf(name)
+	if root == "" {
+		m.Authoritative = false
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+		return
+	}
+	rb := b.ForRoot(root)
+	soa := rb.soa()
+
+	if name == root {
+		if q.Qtype == dns.TypeSOA || q.Qtype == dns.TypeANY {
+			m.Answer = append(m.Answer, soa)
+		} else {
+			m.Ns = append(m.Ns, soa)
+		}
+		w.WriteMsg(m)
+		return
+	}
+
+	reader := rb.reader()
+	rrs, found, err := reader.lookupRRs(name)
+	if err != nil && reader != rb {
+		// the replica may fail before its next ping notices, the database answers then
+		rrs, found, err = rb.lookupRRs(name)
+	}
+	if err != nil {
+		logrus.Errorf("failed to answer %s %s: %v", dns.TypeToString[q.Qtype], name, err)
+		m.SetRcode(r, dns.RcodeServerFailure)
+		w.WriteMsg(m)
+		return
+	}
+	if !found {
+		m.SetRcode(r, dns.RcodeNameError)
+		m.Ns = append(m.Ns, soa)
+		w.WriteMsg(m)
+		return
+	}
+
+	for _, rr := range rrs {
+		t := rr.Header().Rrtype
+		// the target of a CNAME is answered for every type of the name
+		if t == q.Qtype || q.Qtype == dns.TypeANY || t == dns.TypeCNAME {
+			m.Answer = append(m.Answer, rr)
+		}
+	}
+	if len(m.Answer) == 0 {
+		m.Ns = append(m.Ns, soa)
+	}
+
+	w.WriteMsg(m)
+}
+
+// Used to read the records of a name, found is false when the name is neither a domain nor has
+// records. Drained hosts are left out unless every host of the name is drained.
+// e.g. x1.qrn7oq.lb.rancher.cloud => [A 1.1.1.1], true
+func (b *Backend) lookupRRs(name string) ([]dns.RR, bool, error) {
+	base := b.baseOf(name)
+	now := time.Now().Unix()
+
+	var cname, queryTypes string
+	var ttl uint32
+	err := b.queryRow(b.DB, "SELECT cname, ttl, query_types FROM domains WHERE fqdn = ? AND expires_on > ?", base, now).Scan(&cname, &ttl, &queryTypes)
+	if err == dbsql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrapf(err, errLookupRecords, typeA, base)
+	}
+	if ttl == 0 {
+		ttl = answerTTL()
+	}
+
+	if cname != "" {
+		rr := &dns.CNAME{Hdr: dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: ttl}, Target: dns.Fqdn(cname)}
+		return restrictRRs([]dns.RR{rr}, queryTypes), true, nil
+	}
+
+	rrs := make([]dns.RR, 0)
+	hosts, err := b.answerHosts(name, now)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(hosts) > 0 {
+		opts := &model.DomainOptions{Fqdn: name, Hosts: hosts}
+		rrs = append(rrs, dnsutil.ToRRs(opts.Records(), ttl)...)
+	}
+
+	rows, err := b.query(b.DB, "SELECT content FROM txt_records WHERE fqdn = ? AND (expires_on = 0 OR expires_on > ?) ORDER BY order_id", name, now)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, errLookupRecords, typeTXT, name)
+	}
+	texts, err := scanStrings(rows)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, errLookupRecords, typeTXT, name)
+	}
+	for _, t := range texts {
+		rrs = append(rrs, dnsutil.TXT(name, t, ttl))
+	}
+
+	if name == base {
+		if caa, err := b.GetCAA(base); err == nil {
+			for _, c := range caa {
+				rrs = append(rrs, dnsutil.CAA(name, ttl, c))
+			}
+		}
+	}
+
+	return restrictRRs(rrs, queryTypes), name == base || len(rrs) > 0, nil
+}
+
+// Used to leave out the records of the types a restricted domain does not answer, the name is
+// still found so the other types are answered with NODATA
+// e.g. [A 1.1.1.1, TXT xxx], TXT => [TXT xxx]
+func restrictRRs(rrs []dns.RR, queryTypes string) []dns.RR {
+	if queryTypes == "" {
+		return rrs
+	}
+	types := strings.Split(queryTypes, ",")
+	allowed := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if model.AllowsQueryType(types, dns.TypeToString[rr.Header().Rrtype]) {
+			allowed = append(allowed, rr)
+		}
+	}
+	return allowed
+}
+
+// Used to read the hosts of a name which are not drained, all of them when every host is drained
+func (b *Backend) answerHosts(name string, now int64) ([]string, error) {
+	rows, err := b.query(b.DB, "SELECT host, drained_until FROM hosts WHERE fqdn = ? ORDER BY host", name)
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeA, name)
+	}
+	defer rows.Close()
+
+	all, serving := make([]string, 0), make([]string, 0)
+	for rows.Next() {
+		var host string
+		var drained int64
+		if err := rows.Scan(&host, &drained); err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeA, name)
+		}
+		if net.ParseIP(host) == nil {
+			continue
+		}
+		all = append(all, host)
+		if drained <= now {
+			serving = append(serving, host)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeA, name)
+	}
+
+	if len(serving) == 0 {
+		return all, nil
+	}
+	return serving, nil
+}
+
+// Used to get the root domain a name is under, the longest root wins
+// e.g. x1.qrn7oq.lb.rancher.cloud => lb.rancher.cloud
+func (b *Backend) rootOf(name string) string {
+	root := ""
+	for _, r := range util.RootDomains(os.Getenv("DOMAIN")) {
+		if (name == r || strings.HasSuffix(name, "."+r)) && len(r) > len(root) {
+			root = r
+		}
+	}
+	return root
+}
+
+func (b *Backend) soa() dns.RR {
+	zone := dns.Fqdn(b.Domain)
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: answerTTL()},
+		Ns:      "ns." + zone,
+		Mbox:    "hostmaster." + zone,
+		Serial:  uint32(time.Now().Unix()),
+		Refresh: soaRefresh,
+		Retry:   soaRetry,
+		Expire:  soaExpire,
+		Minttl:  answerTTL(),
+	}
+}
+
+// Used to get the ttl of the answers of domains which have no ttl of their own
+func answerTTL() uint32 {
+	ttl, err := strconv.ParseUint(os.Getenv(flagTTL), 10, 32)
+	if err != nil {
+		return defaultTTL
+	}
+	return uint32(ttl)
+}
@@ -0,0 +1,23 @@
+package sql
+
+const (
+	errDeleteRecord       = "failed to delete %s record: %s"
+	errEmptyRecord        = "failed to found %s record: %s"
+	errExistSlug          = "slug name %s can not be used, try another"
+	errLookupRecords      = "failed to lookup %s record: %s"
+	errNoLookupResults    = "no lookup results for %s record: %s"
+	errNoDrainHost        = "host %s is not a host of domain %s"
+	errNotValidDomainName = "not valid domain name: %s"
+	errNotValidCNAME      = "not valid CNAME target: %s"
+	errInvalidRootDomains = "invalid root domains: %s"
+	errInvalidExpiration  = "expiration %s is not in the future"
+	errInvalidContinue    = "invalid continue token: %s"
+	errSetRecord          = "failed to set %s record: %s"
+	errRequestName        = "failed to request name %s"
+	errUnknownDriver      = "unknown database driver %s, expected mysql or postgres"
+	errOpenDatabase       = "failed to open %s database"
+	errOpenReplica        = "failed to open %s read replica"
+	errMigrateSchema      = "failed to apply schema migration %d: %s"
+	errPing               = "failed to ping %s database"
+	errServeDNS           = "failed to serve dns on %s/%s"
+)
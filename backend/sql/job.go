@@ -0,0 +1,84 @@
+package sql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The jobs are kept by kind and read in the order of their id, which starts with the time they
+// are queued at. The times of a job are kept in nanoseconds.
+
+func (b *Backend) EnqueueJob(j *model.Job) error {
+	logrus.Debugf("enqueue %s job: %s", j.Kind, j.ID)
+
+	if _, err := b.exec(b.DB, b.dialect.insert("jobs", []string{"kind", "id"}, []string{"payload", "attempts", "created_on", "visible_on"}, true),
+		j.Kind, j.ID, string(j.Payload), j.Attempts, j.Created.UnixNano(), j.Visible.UnixNano()); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeJob, j.ID)
+	}
+
+	return nil
+}
+
+// ClaimJobs hides the visible jobs until the visibility timeout, a job is only claimed when its
+// visibility is not changed since it was read, so a job which another worker claims at once is
+// left to that worker.
+func (b *Backend) ClaimJobs(kind string, limit int, visibility time.Duration) ([]model.Job, error) {
+	now := time.Now()
+
+	rows, err := b.query(b.DB, fmt.Sprintf("SELECT id, payload, attempts, created_on, visible_on FROM jobs WHERE kind = ? AND visible_on <= ? ORDER BY id LIMIT %d", limit), kind, now.UnixNano())
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeJob, kind)
+	}
+
+	jobs := make([]model.Job, 0)
+	for rows.Next() {
+		j := model.Job{Kind: kind}
+		var payload string
+		var created, visible int64
+		if err := rows.Scan(&j.ID, &payload, &j.Attempts, &created, &visible); err != nil {
+			rows.Close()
+			return nil, errors.Wrapf(err, errLookupRecords, typeJob, kind)
+		}
+		j.Payload = []byte(payload)
+		j.Created = time.Unix(0, created)
+		j.Visible = time.Unix(0, visible)
+		jobs = append(jobs, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeJob, kind)
+	}
+
+	result := make([]model.Job, 0, len(jobs))
+	for _, j := range jobs {
+		visible := now.Add(visibility)
+		res, err := b.exec(b.DB, "UPDATE jobs SET attempts = attempts + 1, visible_on = ? WHERE kind = ? AND id = ? AND visible_on = ?",
+			visible.UnixNano(), kind, j.ID, j.Visible.UnixNano())
+		if err != nil {
+			return result, errors.Wrapf(err, errSetRecord, typeJob, j.ID)
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			continue
+		}
+		j.Attempts++
+		j.Visible = visible
+		result = append(result, j)
+	}
+
+	return result, nil
+}
+
+func (b *Backend) DeleteJob(kind, id string) error {
+	logrus.Debugf("delete %s job: %s", kind, id)
+
+	if _, err := b.exec(b.DB, "DELETE FROM jobs WHERE kind = ? AND id = ?", kind, id); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeJob, id)
+	}
+
+	return nil
+}
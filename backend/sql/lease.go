@@ -0,0 +1,110 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Keepalive moves the expiration of a domain like Renew without reading the records of the domain.
+// The expiration is read back on its own, mysql does not count the rows an update leaves unchanged.
+func (b *Backend) Keepalive(fqdn string) (time.Time, error) {
+	logrus.Debugf("keepalive lease of fqdn %s", fqdn)
+
+	now := time.Now().Unix()
+	if _, err := b.exec(b.DB, "UPDATE domains SET expires_on = CASE WHEN deadline <> 0 AND lease + ? > deadline THEN deadline ELSE lease + ? END WHERE fqdn = ? AND expires_on > ?", now, now, fqdn, now); err != nil {
+		return time.Time{}, errors.Wrapf(err, errSetRecord, typeToken, fqdn)
+	}
+
+	var expires int64
+	err := b.queryRow(b.DB, "SELECT expires_on FROM domains WHERE fqdn = ? AND expires_on > ?", fqdn, now).Scan(&expires)
+	if err == dbsql.ErrNoRows {
+		return time.Time{}, errors.Errorf(errNoLookupResults, typeA, fqdn)
+	}
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, errLookupRecords, typeA, fqdn)
+	}
+
+	return time.Unix(expires, 0), nil
+}
+
+// Extend gives a domain the longer lease time, which renewals keep from then on, and moves its
+// expiration to the lease time from now. It returns false when the domain already has a lease
+// time which is as long, or is a temporary domain.
+func (b *Backend) Extend(fqdn string, leaseTime time.Duration) (bool, error) {
+	logrus.Debugf("extend lease of fqdn %s to %s", fqdn, leaseTime)
+
+	seconds := int64(leaseTime.Seconds())
+	extended := false
+	err := b.tx(func(tx *dbsql.Tx) error {
+		var lease, deadline int64
+		err := b.queryRow(tx, "SELECT lease, deadline FROM domains WHERE fqdn = ? AND expires_on > ?", fqdn, time.Now().Unix()).Scan(&lease, &deadline)
+		if err == dbsql.ErrNoRows {
+			return errors.Errorf(errEmptyRecord, typeToken, fqdn)
+		}
+		if err != nil {
+			return errors.Wrapf(err, errLookupRecords, typeToken, fqdn)
+		}
+		if lease >= seconds || deadline > 0 {
+			return nil
+		}
+
+		extended = true
+		_, err = b.exec(tx, "UPDATE domains SET lease = ?, expires_on = ? WHERE fqdn = ?", seconds, time.Now().Unix()+seconds, fqdn)
+		return errors.Wrapf(err, errSetRecord, typeToken, fqdn)
+	})
+
+	return extended, err
+}
+
+// SetExpiration moves the expiration of a domain to until, sooner or later than the one it has.
+// Renewals keep the lease time up to until from then on.
+func (b *Backend) SetExpiration(fqdn string, until time.Time) (d model.Domain, err error) {
+	logrus.Debugf("set expiration of fqdn %s to %s", fqdn, until.Format(time.RFC3339))
+
+	seconds := int64(time.Until(until).Seconds())
+	if seconds <= 0 {
+		return d, errors.Errorf(errInvalidExpiration, until.Format(time.RFC3339))
+	}
+
+	err = b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, fqdn); err != nil {
+			return err
+		}
+		_, err := b.exec(tx, "UPDATE domains SET lease = ?, expires_on = ? WHERE fqdn = ?", seconds, until.Unix(), fqdn)
+		return errors.Wrapf(err, errSetRecord, typeToken, fqdn)
+	})
+	if err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
+
+// SetDeadline keeps the absolute expiry of a temporary domain, its expiration is moved to the
+// deadline when it is later and renewals never move it past the deadline.
+func (b *Backend) SetDeadline(fqdn string, until time.Time) (d model.Domain, err error) {
+	logrus.Debugf("set deadline of fqdn %s to %s", fqdn, until.Format(time.RFC3339))
+
+	if !until.After(time.Now()) {
+		return d, errors.Errorf(errInvalidExpiration, until.Format(time.RFC3339))
+	}
+
+	err = b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, fqdn); err != nil {
+			return err
+		}
+		deadline := until.Unix()
+		_, err := b.exec(tx, "UPDATE domains SET deadline = ?, expires_on = CASE WHEN expires_on > ? THEN ? ELSE expires_on END WHERE fqdn = ?", deadline, deadline, deadline, fqdn)
+		return errors.Wrapf(err, errSetRecord, typeToken, fqdn)
+	})
+	if err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
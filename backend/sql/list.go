@@ -0,0 +1,65 @@
+package sql
+
+import (
+	"encoding/base64"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// List returns a page of domains of the zone in the order of their fqdn, the continue token is the
+// last fqdn of the page so the pages see the domains which are written in between.
+func (b *Backend) List(opts *model.ListOptions) (l model.DomainList, err error) {
+	logrus.Debugf("list %s records with limit %d", typeA, opts.Limit)
+
+	after, err := decodeContinue(opts.Continue)
+	if err != nil {
+		return l, err
+	}
+
+	items, err := b.readDomains(b.DB, "zone = ? AND fqdn > ?", []interface{}{b.Domain, after}, opts.Limit+1)
+	if err != nil {
+		return l, err
+	}
+	if int64(len(items)) > opts.Limit {
+		items = items[:opts.Limit]
+		l.Continue = encodeContinue(items[len(items)-1].Fqdn)
+	}
+	l.Items = items
+
+	return l, nil
+}
+
+// HostDomains returns the domains which have a record pointing at the host.
+func (b *Backend) HostDomains(host string) ([]string, error) {
+	rows, err := b.query(b.DB, "SELECT DISTINCT h.domain FROM hosts h JOIN domains d ON d.fqdn = h.domain WHERE h.host = ? AND d.zone = ? AND d.expires_on > ? ORDER BY h.domain", host, b.Domain, time.Now().Unix())
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeA, host)
+	}
+
+	fqdns, err := scanStrings(rows)
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeA, host)
+	}
+
+	return fqdns, nil
+}
+
+func encodeContinue(fqdn string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fqdn))
+}
+
+// Used to decode the fqdn the page continues after, an empty token starts at the first domain
+func decodeContinue(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	v, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return "", errors.Errorf(errInvalidContinue, s)
+	}
+	return string(v), nil
+}
@@ -0,0 +1,78 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// AppliedMigrations returns the data migrations which are recorded as applied with their apply time.
+func (b *Backend) AppliedMigrations() (map[string]time.Time, error) {
+	rows, err := b.query(b.DB, "SELECT id, applied_on FROM data_migrations")
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeMigration, "data_migrations")
+	}
+	defer rows.Close()
+
+	applied := make(map[string]time.Time)
+	for rows.Next() {
+		var id string
+		var on int64
+		if err := rows.Scan(&id, &on); err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeMigration, "data_migrations")
+		}
+		applied[id] = time.Unix(on, 0)
+	}
+
+	return applied, rows.Err()
+}
+
+// RecordMigration records a data migration as applied, a migration which is recorded already keeps
+// its apply time.
+func (b *Backend) RecordMigration(id string) error {
+	if _, err := b.exec(b.DB, b.dialect.insert("data_migrations", []string{"id"}, []string{"applied_on"}, false), id, time.Now().Unix()); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeMigration, id)
+	}
+	return nil
+}
+
+// MigrateFrozen keeps the frozen slug of the path until its expiration
+// e.g. qrn7oq => frozen_slugs {slug: qrn7oq}
+func (b *Backend) MigrateFrozen(opts *model.MigrateFrozen) error {
+	logrus.Debugf("migrate %s record: %s", typeFrozen, opts.Path)
+
+	if _, err := b.exec(b.DB, b.dialect.insert("frozen_slugs", []string{"slug"}, []string{"expires_on"}, true), opts.Path, opts.Expiration.Unix()); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeFrozen, opts.Path)
+	}
+
+	return nil
+}
+
+// MigrateToken creates the domain of the path with the token and the expiration, the records of
+// the domain are migrated after it.
+func (b *Backend) MigrateToken(opts *model.MigrateToken) error {
+	logrus.Debugf("migrate %s record: %s", typeToken, opts.Path)
+
+	now := time.Now().Unix()
+	if _, err := b.exec(b.DB, b.dialect.insert("domains", []string{"fqdn"}, []string{"zone", "token", "lease", "created_on", "expires_on"}, true),
+		opts.Path, b.Domain, opts.Token, int64(b.LeaseTime.Seconds()), now, opts.Expiration.Unix()); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeToken, opts.Path)
+	}
+
+	return nil
+}
+
+func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
+	logrus.Debugf("migrate record: %s", opts.Fqdn)
+
+	if opts.Text != "" {
+		_, err := b.SetText(&model.DomainOptions{Fqdn: opts.Fqdn, Text: opts.Text})
+		return err
+	}
+
+	_, err := b.Update(&model.DomainOptions{Fqdn: opts.Fqdn, Hosts: opts.Hosts, SubDomain: opts.SubDomain})
+	return err
+}
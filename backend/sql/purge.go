@@ -0,0 +1,38 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const purgeInterval = 10 * time.Minute
+
+// StartPurgeDaemon deletes the rows whose expiration is over, reads leave them out before they
+// are deleted. The rows of a domain are deleted together with it.
+func (b *Backend) StartPurgeDaemon(done chan struct{}) {
+	go wait.JitterUntil(b.purge, purgeInterval, .1, true, done)
+}
+
+func (b *Backend) purge() {
+	logrus.Debugf("running purge process")
+
+	now := time.Now().Unix()
+	purges := map[string]string{
+		typeA:      "DELETE FROM domains WHERE expires_on <= ?",
+		typeFrozen: "DELETE FROM frozen_slugs WHERE expires_on <= ?",
+		typeTXT:    "DELETE FROM txt_records WHERE expires_on <> 0 AND expires_on <= ?",
+	}
+	for t, query := range purges {
+		res, err := b.exec(b.DB, query, now)
+		if err != nil {
+			logrus.Error(errors.Wrapf(err, errDeleteRecord, t, "expired"))
+			continue
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			logrus.Debugf("purged %d expired %s records", n, t)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package sql
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// schemaMigration is a change of the tables which ships with a release, the schema migrations are
+// applied in order when the backend is opened and recorded with their version in schema_migrations.
+type schemaMigration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// schemaMigrations are only appended, released migrations are never changed or reordered.
+// Times are unix seconds, the times of the jobs are unix nanoseconds as the jobs are ordered by them.
+var schemaMigrations = []schemaMigration{
+	{
+		Version:     1,
+		Description: "domains, hosts, txt records and tokens",
+		Statements: []string{
+			`CREATE TABLE domains (
+    fqdn VARCHAR(255) NOT NULL,
+    zone VARCHAR(255) NOT NULL,
+    token VARCHAR(64) NOT NULL,
+    cname VARCHAR(255) NOT NULL DEFAULT '',
+    ttl BIGINT NOT NULL DEFAULT 0,
+    creator_ip VARCHAR(64) NOT NULL DEFAULT '',
+    lease BIGINT NOT NULL,
+    created_on BIGINT NOT NULL,
+    expires_on BIGINT NOT NULL,
+    PRIMARY KEY (fqdn)
+)`,
+			`CREATE INDEX index_zone_domains ON domains (zone, fqdn)`,
+			`CREATE INDEX index_expires_on_domains ON domains (expires_on)`,
+			`CREATE INDEX index_creator_ip_domains ON domains (creator_ip)`,
+			`CREATE TABLE hosts (
+    domain VARCHAR(255) NOT NULL,
+    fqdn VARCHAR(255) NOT NULL,
+    host VARCHAR(64) NOT NULL,
+    drained_until BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (fqdn, host),
+    CONSTRAINT fk_domains_hosts FOREIGN KEY (domain) REFERENCES domains (fqdn) ON DELETE CASCADE
+)`,
+			`CREATE INDEX index_domain_hosts ON hosts (domain)`,
+			`CREATE INDEX index_host_hosts ON hosts (host)`,
+			`CREATE TABLE txt_records (
+    domain VARCHAR(255) NOT NULL,
+    fqdn VARCHAR(255) NOT NULL,
+    order_id VARCHAR(64) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    expires_on BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (fqdn, order_id),
+    CONSTRAINT fk_domains_txt_records FOREIGN KEY (domain) REFERENCES domains (fqdn) ON DELETE CASCADE
+)`,
+			`CREATE INDEX index_domain_txt_records ON txt_records (domain)`,
+			`CREATE INDEX index_expires_on_txt_records ON txt_records (expires_on)`,
+			`CREATE TABLE scoped_tokens (
+    domain VARCHAR(255) NOT NULL,
+    scope VARCHAR(64) NOT NULL,
+    origin VARCHAR(255) NOT NULL,
+    PRIMARY KEY (domain, scope),
+    CONSTRAINT fk_domains_scoped_tokens FOREIGN KEY (domain) REFERENCES domains (fqdn) ON DELETE CASCADE
+)`,
+			`CREATE TABLE frozen_slugs (
+    slug VARCHAR(255) NOT NULL,
+    expires_on BIGINT NOT NULL,
+    PRIMARY KEY (slug)
+)`,
+			`CREATE INDEX index_expires_on_frozen_slugs ON frozen_slugs (expires_on)`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "labels, caa records and webhooks of the domains",
+		Statements: []string{
+			`CREATE TABLE labels (
+    domain VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    content VARCHAR(255) NOT NULL,
+    PRIMARY KEY (domain, name),
+    CONSTRAINT fk_domains_labels FOREIGN KEY (domain) REFERENCES domains (fqdn) ON DELETE CASCADE
+)`,
+			`CREATE INDEX index_name_labels ON labels (name, content)`,
+			`CREATE TABLE caa_records (
+    domain VARCHAR(255) NOT NULL,
+    seq INT NOT NULL,
+    flag INT NOT NULL,
+    tag VARCHAR(16) NOT NULL,
+    content VARCHAR(255) NOT NULL,
+    PRIMARY KEY (domain, seq),
+    CONSTRAINT fk_domains_caa_records FOREIGN KEY (domain) REFERENCES domains (fqdn) ON DELETE CASCADE
+)`,
+			`CREATE TABLE webhooks (
+    domain VARCHAR(255) NOT NULL,
+    id VARCHAR(64) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL DEFAULT '',
+    events VARCHAR(255) NOT NULL DEFAULT '',
+    created_on BIGINT NOT NULL,
+    PRIMARY KEY (domain, id),
+    CONSTRAINT fk_domains_webhooks FOREIGN KEY (domain) REFERENCES domains (fqdn) ON DELETE CASCADE
+)`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "suspensions, api keys, jobs, operational states and data migrations",
+		Statements: []string{
+			`CREATE TABLE suspensions (
+    fqdn VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL,
+    created_on BIGINT NOT NULL,
+    PRIMARY KEY (fqdn)
+)`,
+			`CREATE TABLE api_keys (
+    id VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    tenant VARCHAR(255) NOT NULL DEFAULT '',
+    root_domain VARCHAR(255) NOT NULL DEFAULT '',
+    hash VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL,
+    PRIMARY KEY (id)
+)`,
+			`CREATE TABLE jobs (
+    kind VARCHAR(64) NOT NULL,
+    id VARCHAR(128) NOT NULL,
+    payload TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    created_on BIGINT NOT NULL,
+    visible_on BIGINT NOT NULL,
+    PRIMARY KEY (kind, id)
+)`,
+			`CREATE INDEX index_visible_on_jobs ON jobs (kind, visible_on)`,
+			`CREATE TABLE operational_states (
+    state_key VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    version BIGINT NOT NULL,
+    updated_on BIGINT NOT NULL,
+    PRIMARY KEY (state_key)
+)`,
+			`CREATE TABLE data_migrations (
+    id VARCHAR(255) NOT NULL,
+    applied_on BIGINT NOT NULL,
+    PRIMARY KEY (id)
+)`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "deadlines of the temporary domains",
+		Statements: []string{
+			`ALTER TABLE domains ADD COLUMN deadline BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "query types of the restricted domains",
+		Statements: []string{
+			`ALTER TABLE domains ADD COLUMN query_types VARCHAR(64) NOT NULL DEFAULT ''`,
+		},
+	},
+}
+
+// Used to apply the schema migrations which are not recorded yet, every migration is applied in a
+// transaction of its own on postgres. MySQL commits every CREATE on its own, a migration which fails
+// halfway is completed by hand before the backend is opened again.
+func (b *Backend) migrate() error {
+	create := `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT NOT NULL,
+    applied_on BIGINT NOT NULL,
+    PRIMARY KEY (version)
+)`
+	if _, err := b.DB.Exec(create + b.dialect.options); err != nil {
+		return errors.Wrapf(err, errMigrateSchema, 0, "schema migrations")
+	}
+
+	applied := make(map[int]bool)
+	rows, err := b.DB.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return errors.Wrapf(err, errMigrateSchema, 0, "schema migrations")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return errors.Wrapf(err, errMigrateSchema, 0, "schema migrations")
+		}
+		applied[v] = true
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrapf(err, errMigrateSchema, 0, "schema migrations")
+	}
+
+	for _, m := range schemaMigrations {
+		if applied[m.Version] {
+			continue
+		}
+		logrus.Infof("applying schema migration %d to %s database: %s", m.Version, b.dialect.driver, m.Description)
+		if err := b.apply(m); err != nil {
+			return errors.Wrapf(err, errMigrateSchema, m.Version, m.Description)
+		}
+	}
+
+	return nil
+}
+
+func (b *Backend) apply(m schemaMigration) error {
+	tx, err := b.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, s := range m.Statements {
+		if strings.HasPrefix(s, "CREATE TABLE") {
+			s += b.dialect.options
+		}
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(b.dialect.rebind("INSERT INTO schema_migrations (version, applied_on) VALUES (?, ?)"), m.Version, time.Now().Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,106 @@
+package sql
+
+import (
+	"strings"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Search returns a page of domains which match all filters, every filter is a condition of
+// the query so the pages are read as the list pages are.
+func (b *Backend) Search(opts *model.SearchOptions) (l model.DomainList, err error) {
+	logrus.Debugf("search %s records with filters: %+v", typeA, opts)
+
+	after, err := decodeContinue(opts.Continue)
+	if err != nil {
+		return l, err
+	}
+
+	conds := []string{"zone = ?", "fqdn > ?"}
+	args := []interface{}{b.Domain, after}
+	if opts.Host != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM hosts h WHERE h.domain = domains.fqdn AND h.host = ?)")
+		args = append(args, opts.Host)
+	}
+	if opts.Label != "" {
+		kv := strings.SplitN(opts.Label, "=", 2)
+		if len(kv) == 2 {
+			conds = append(conds, "EXISTS (SELECT 1 FROM labels lb WHERE lb.domain = domains.fqdn AND lb.name = ? AND lb.content = ?)")
+			args = append(args, kv[0], kv[1])
+		}
+	}
+	if opts.CreatorIP != "" {
+		conds = append(conds, "creator_ip = ?")
+		args = append(args, opts.CreatorIP)
+	}
+	if opts.ExpiringBefore != nil {
+		conds = append(conds, "expires_on < ?")
+		args = append(args, opts.ExpiringBefore.Unix())
+	}
+	if opts.ExpiringAfter != nil {
+		conds = append(conds, "expires_on > ?")
+		args = append(args, opts.ExpiringAfter.Unix())
+	}
+	if opts.Text != "" {
+		conds = append(conds, "EXISTS (SELECT 1 FROM txt_records t WHERE t.domain = domains.fqdn AND t.content LIKE ?)")
+		args = append(args, "%"+escapeLike(opts.Text)+"%")
+	}
+
+	items, err := b.readDomains(b.DB, strings.Join(conds, " AND "), args, opts.Limit+1)
+	if err != nil {
+		return l, err
+	}
+	if int64(len(items)) > opts.Limit {
+		items = items[:opts.Limit]
+		l.Continue = encodeContinue(items[len(items)-1].Fqdn)
+	}
+	if err := b.readLabels(items); err != nil {
+		return l, err
+	}
+	l.Items = items
+
+	return l, nil
+}
+
+// Used to fill the labels of the domains of a page
+func (b *Backend) readLabels(items []model.Domain) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int)
+	fqdns := make([]interface{}, 0, len(items))
+	for i, d := range items {
+		index[d.Fqdn] = i
+		fqdns = append(fqdns, d.Fqdn)
+	}
+
+	rows, err := b.query(b.DB, "SELECT domain, name, content FROM labels WHERE domain IN "+in(len(fqdns)), fqdns...)
+	if err != nil {
+		return errors.Wrapf(err, errLookupRecords, typeLabel, items[0].Fqdn)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fqdn, name, content string
+		if err := rows.Scan(&fqdn, &name, &content); err != nil {
+			return errors.Wrapf(err, errLookupRecords, typeLabel, items[0].Fqdn)
+		}
+		d := &items[index[fqdn]]
+		if d.Labels == nil {
+			d.Labels = make(map[string]string)
+		}
+		d.Labels[name] = content
+	}
+
+	return rows.Err()
+}
+
+// Used to escape the wildcards of a LIKE pattern, both dialects escape with a backslash
+// e.g. 100%_done => 100\%\_done
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,686 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/config"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	// the drivers of the dialects
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Name             = "sql"
+	typeA            = "A"
+	typeTXT          = "TXT"
+	typeCNAME        = "CNAME"
+	typeCAA          = "CAA"
+	typeQueryTypes   = "QUERYTYPES"
+	typeToken        = "TOKEN"
+	typeFrozen       = "FROZEN"
+	typeLabel        = "LABEL"
+	typeSuspension   = "SUSPENSION"
+	typeWebhook      = "WEBHOOK"
+	typeTTL          = "TTL"
+	typeDrain        = "DRAIN"
+	typeJob          = "JOB"
+	typeAPIKey       = "APIKEY"
+	typeState        = "STATE"
+	typeMigration    = "MIGRATION"
+	maxSlugHashTimes = 100
+	tokenLength      = 32
+
+	maxOpenConnections = 100
+	maxIdleConnections = 50
+)
+
+// Backend keeps the domains with their hosts, txt records and tokens in the tables of a mysql or
+// postgres database. Every row of a domain is deleted with the domain, a domain whose expiration is
+// over is left out of every read and deleted by the purger later.
+type Backend struct {
+	Domain    string
+	FrozenTTL time.Duration
+	LeaseTime time.Duration
+	// ChallengeTTL is the lease time of ACME challenge TXT records, 0 means the domain lease
+	ChallengeTTL time.Duration
+
+	DB *dbsql.DB
+	// Replica is the database of READ_DSN, nil when the reads go to DB
+	Replica *dbsql.DB
+	dialect *dialect
+
+	readHealth *backend.ReadHealth
+}
+
+// querier is a database or a transaction of it
+type querier interface {
+	Exec(query string, args ...interface{}) (dbsql.Result, error)
+	Query(query string, args ...interface{}) (*dbsql.Rows, error)
+	QueryRow(query string, args ...interface{}) *dbsql.Row
+}
+
+func init() {
+	backend.Register(Name, func(cfg *backend.Config) (backend.Backend, error) {
+		dsn := cfg.DSN
+		if dsn == "" {
+			dsn = os.Getenv("DSN")
+		}
+		return NewBackend(os.Getenv("DATABASE"), dsn)
+	})
+}
+
+// NewBackend opens the database of the driver and applies the schema migrations which are not
+// applied yet, so a new database only has to be created.
+func NewBackend(driver, dsn string) (*Backend, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, errors.Errorf(errUnknownDriver, driver)
+	}
+
+	leaseTime, err := time.ParseDuration(os.Getenv("DATABASE_LEASE_TIME"))
+	if err != nil {
+		return nil, err
+	}
+	frozen, err := time.ParseDuration(os.Getenv("FROZEN"))
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := time.ParseDuration(os.Getenv("ACME_TXT_TTL"))
+	if err != nil {
+		return nil, err
+	}
+
+	roots := util.RootDomains(os.Getenv("DOMAIN"))
+	if len(roots) == 0 {
+		return nil, errors.Errorf(errInvalidRootDomains, os.Getenv("DOMAIN"))
+	}
+
+	db, err := dbsql.Open(d.driver, dsn)
+	if err != nil {
+		return nil, errors.Wrapf(err, errOpenDatabase, driver)
+	}
+	db.SetMaxOpenConns(maxOpenConnections)
+	db.SetMaxIdleConns(maxIdleConnections)
+
+	b := &Backend{
+		Domain:    roots[0],
+		FrozenTTL: frozen,
+		LeaseTime: leaseTime,
+		DB:        db,
+		dialect:   d,
+
+		ChallengeTTL: challenge,
+	}
+
+	if err := b.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := b.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// the replica may be down when the server starts, the reads go to DB until it answers
+	if readDSN := os.Getenv("READ_DSN"); readDSN != "" {
+		replica, err := dbsql.Open(d.driver, readDSN)
+		if err != nil {
+			db.Close()
+			return nil, errors.Wrapf(err, errOpenReplica, driver)
+		}
+		replica.SetMaxOpenConns(maxOpenConnections)
+		replica.SetMaxIdleConns(maxIdleConnections)
+		b.Replica = replica
+		b.readHealth = backend.NewReadHealth(Name, replica.Ping)
+	}
+
+	return b, nil
+}
+
+// Reader returns a backend of the same root domain which reads from the replica, the reads go to
+// the database while no replica is set or it is not healthy.
+func (b *Backend) Reader() backend.Backend {
+	return b.reader()
+}
+
+func (b *Backend) reader() *Backend {
+	if b.Replica == nil || !b.readHealth.Healthy() {
+		return b
+	}
+	r := *b
+	r.DB = b.Replica
+	return &r
+}
+
+// ForRoot returns a backend of another root domain which shares the database, the domains of
+// every root domain are told apart by their zone.
+func (b *Backend) ForRoot(root string) *Backend {
+	r := *b
+	r.Domain = root
+	return &r
+}
+
+func (b *Backend) GetName() string {
+	return Name
+}
+
+func (b *Backend) GetZone() string {
+	return b.Domain
+}
+
+func (b *Backend) Ping() error {
+	if err := b.DB.Ping(); err != nil {
+		return errors.Wrapf(err, errPing, b.dialect.driver)
+	}
+	return nil
+}
+
+func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("get %s record for domain options: %s", typeA, opts.String())
+
+	base := b.baseOf(opts.Fqdn)
+	items, err := b.readDomains(b.DB, "fqdn = ?", []interface{}{base}, 0)
+	if err != nil {
+		return d, err
+	}
+	if len(items) == 0 {
+		return d, errors.Errorf(errNoLookupResults, typeA, opts.Fqdn)
+	}
+	if err := b.readLabels(items[:1]); err != nil {
+		return d, err
+	}
+	d = items[0]
+
+	if opts.Fqdn == base {
+		return d, nil
+	}
+
+	// a sub domain is answered with its own hosts, like its path of the etcdv3 backend
+	hosts, ok := d.SubDomain[strings.TrimSuffix(opts.Fqdn, "."+base)]
+	if !ok {
+		return model.Domain{}, errors.Errorf(errNoLookupResults, typeA, opts.Fqdn)
+	}
+	sub := model.Domain{Fqdn: opts.Fqdn, Hosts: hosts, SubDomain: map[string][]string{}, TTL: d.TTL, Expiration: d.Expiration, Deadline: d.Deadline}
+	for _, h := range hosts {
+		if until, ok := d.Drained[h]; ok {
+			if sub.Drained == nil {
+				sub.Drained = make(map[string]*time.Time)
+			}
+			sub.Drained[h] = until
+		}
+	}
+	return sub, nil
+}
+
+func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("set %s record for domain options: %s", typeA, opts.String())
+
+	if err := b.create(opts, ""); err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: opts.Fqdn})
+}
+
+func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("update %s record for domain options: %s", typeA, opts.String())
+
+	err = b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, opts.Fqdn); err != nil {
+			return err
+		}
+		if err := b.setHosts(tx, opts); err != nil {
+			return err
+		}
+		if opts.Labels == nil {
+			return nil
+		}
+		return b.setLabels(tx, opts.Fqdn, opts.Labels)
+	})
+	if err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: opts.Fqdn})
+}
+
+// PatchHosts adds and removes the hosts of a domain or a sub domain in one transaction, concurrent
+// patches of other hosts are not lost. The patch is refused when the domain has expired meanwhile.
+func (b *Backend) PatchHosts(fqdn string, add, remove []string) (d model.Domain, err error) {
+	logrus.Debugf("patch %s record for domain %s: add %v, remove %v", typeA, fqdn, add, remove)
+
+	base := b.baseOf(fqdn)
+	err = b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, base); err != nil {
+			return err
+		}
+		for _, h := range remove {
+			if _, err := b.exec(tx, "DELETE FROM hosts WHERE fqdn = ? AND host = ?", fqdn, h); err != nil {
+				return errors.Wrapf(err, errDeleteRecord, typeA, fqdn)
+			}
+		}
+		for _, h := range add {
+			if err := b.insertHost(tx, base, fqdn, h); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
+
+// Delete deletes the domain row, its hosts, txt records, tokens and the other rows of the domain
+// are deleted by the database with it. The frozen slug is kept until it expires, a sub domain only
+// loses its hosts.
+func (b *Backend) Delete(opts *model.DomainOptions) error {
+	logrus.Debugf("delete %s record for domain options: %s", typeA, opts.String())
+
+	if _, err := b.Get(opts); err != nil {
+		return err
+	}
+
+	query := "DELETE FROM domains WHERE fqdn = ?"
+	if opts.Fqdn != b.baseOf(opts.Fqdn) {
+		query = "DELETE FROM hosts WHERE fqdn = ?"
+	}
+	if _, err := b.exec(b.DB, query, opts.Fqdn); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeA, opts.Fqdn)
+	}
+
+	return nil
+}
+
+// Renew moves the expiration of a domain to the lease time of the domain from now, the lease
+// time is the one the domain is created or last extended with. A temporary domain is renewed up
+// to its deadline at most.
+func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("renew %s record for domain options: %s", typeA, opts.String())
+
+	err = b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, opts.Fqdn); err != nil {
+			return err
+		}
+		now := time.Now().Unix()
+		_, err := b.exec(tx, "UPDATE domains SET expires_on = CASE WHEN deadline <> 0 AND lease + ? > deadline THEN deadline ELSE lease + ? END WHERE fqdn = ?", now, now, opts.Fqdn)
+		return errors.Wrapf(err, errSetRecord, typeToken, opts.Fqdn)
+	})
+	if err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: opts.Fqdn})
+}
+
+func (b *Backend) GetToken(fqdn string) (string, error) {
+	logrus.Debugf("get %s record for fqdn: %s", typeToken, fqdn)
+
+	var token string
+	err := b.queryRow(b.DB, "SELECT token FROM domains WHERE fqdn = ? AND expires_on > ?", fqdn, time.Now().Unix()).Scan(&token)
+	if err == dbsql.ErrNoRows {
+		return "", errors.Errorf(errEmptyRecord, typeToken, fqdn)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, errLookupRecords, typeToken, fqdn)
+	}
+
+	return token, nil
+}
+
+func (b *Backend) GetTokenCount() (int64, error) {
+	logrus.Debugf("get %s record count", typeToken)
+
+	var count int64
+	err := b.queryRow(b.DB, "SELECT COUNT(*) FROM domains WHERE zone = ? AND expires_on > ?", b.Domain, time.Now().Unix()).Scan(&count)
+	if err != nil {
+		return 0, errors.Wrapf(err, errLookupRecords, typeToken, b.Domain)
+	}
+
+	return count, nil
+}
+
+// Used to create the domain of a requested name, an imported fqdn or a random slug with its hosts,
+// the slug is frozen in the same transaction so two requests of the same name can not both get it.
+func (b *Backend) create(opts *model.DomainOptions, cname string) error {
+	return b.tx(func(tx *dbsql.Tx) error {
+		var fqdn string
+		switch {
+		case opts.Name != "":
+			fqdn = fmt.Sprintf("%s.%s", opts.Name, b.Domain)
+			if err := b.reserveSlugName(tx, opts.Name, fqdn); err != nil {
+				return err
+			}
+		case opts.Fqdn != "":
+			fqdn = opts.Fqdn
+			if err := b.reserveSlugName(tx, util.SlugWithZone(fqdn, b.Domain), fqdn); err != nil {
+				return err
+			}
+		}
+
+		for i := 0; i < maxSlugHashTimes && fqdn == ""; i++ {
+			slug := generateSlug(b.Domain)
+			err := b.reserveSlugName(tx, slug, fmt.Sprintf("%s.%s", slug, b.Domain))
+			if errors.Cause(err) == backend.ErrNameTaken {
+				logrus.Debugf(errExistSlug, slug)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			fqdn = fmt.Sprintf("%s.%s", slug, b.Domain)
+		}
+		if fqdn == "" {
+			return errors.Errorf(errSetRecord, typeA, opts.String())
+		}
+		opts.Fqdn = fqdn
+
+		lease := b.leaseSeconds(opts)
+		now := time.Now().Unix()
+		_, err := b.exec(tx, "INSERT INTO domains (fqdn, zone, token, cname, creator_ip, lease, created_on, expires_on) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			fqdn, b.Domain, util.RandStringWithAll(tokenLength), cname, opts.CreatorIP, lease, now, now+lease)
+		if err != nil {
+			return errors.Wrapf(err, errSetRecord, typeToken, fqdn)
+		}
+
+		if err := b.setHosts(tx, opts); err != nil {
+			return err
+		}
+		return b.setLabels(tx, fqdn, opts.Labels)
+	})
+}
+
+// Used to freeze a slug before its domain is set, the slug is only frozen when it is neither frozen
+// nor used. The rows of an expired domain of the fqdn are deleted, the purger may not have run yet.
+func (b *Backend) reserveSlugName(tx *dbsql.Tx, slug, fqdn string) error {
+	if slug == "" {
+		return errors.Errorf(errNotValidDomainName, fqdn)
+	}
+
+	now := time.Now().Unix()
+	if _, err := b.exec(tx, "DELETE FROM frozen_slugs WHERE slug = ? AND expires_on <= ?", slug, now); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeFrozen, slug)
+	}
+	if _, err := b.exec(tx, "DELETE FROM domains WHERE fqdn = ? AND expires_on <= ?", fqdn, now); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeA, fqdn)
+	}
+
+	var count int
+	if err := b.queryRow(tx, "SELECT COUNT(*) FROM domains WHERE fqdn = ?", fqdn).Scan(&count); err != nil {
+		return errors.Wrapf(err, errLookupRecords, typeA, fqdn)
+	}
+	if count > 0 {
+		return errors.Wrapf(backend.ErrNameTaken, errRequestName, slug)
+	}
+
+	res, err := b.exec(tx, b.dialect.insert("frozen_slugs", []string{"slug"}, []string{"expires_on"}, false), slug, now+int64(b.FrozenTTL.Seconds()))
+	if err != nil {
+		return errors.Wrapf(err, errSetRecord, typeFrozen, slug)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return errors.Wrapf(backend.ErrNameTaken, errRequestName, slug)
+	}
+
+	return nil
+}
+
+// Used to sync the hosts of a domain and its sub domains with the options, the hosts which are
+// kept keep the time they are drained until.
+func (b *Backend) setHosts(tx *dbsql.Tx, opts *model.DomainOptions) error {
+	base := opts.Fqdn
+
+	rows, err := b.query(tx, "SELECT DISTINCT fqdn FROM hosts WHERE domain = ? AND fqdn <> ?", base, base)
+	if err != nil {
+		return errors.Wrapf(err, errLookupRecords, typeA, base)
+	}
+	subs, err := scanStrings(rows)
+	if err != nil {
+		return errors.Wrapf(err, errLookupRecords, typeA, base)
+	}
+
+	for _, fqdn := range subs {
+		if _, ok := opts.SubDomain[strings.TrimSuffix(fqdn, "."+base)]; ok {
+			continue
+		}
+		if _, err := b.exec(tx, "DELETE FROM hosts WHERE fqdn = ?", fqdn); err != nil {
+			return errors.Wrapf(err, errDeleteRecord, typeA, fqdn)
+		}
+	}
+
+	if err := b.syncHosts(tx, base, base, opts.Hosts); err != nil {
+		return err
+	}
+	for prefix, hosts := range opts.SubDomain {
+		if err := b.syncHosts(tx, base, fmt.Sprintf("%s.%s", prefix, base), hosts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Backend) syncHosts(tx *dbsql.Tx, base, fqdn string, hosts []string) error {
+	rows, err := b.query(tx, "SELECT host FROM hosts WHERE fqdn = ?", fqdn)
+	if err != nil {
+		return errors.Wrapf(err, errLookupRecords, typeA, fqdn)
+	}
+	old, err := scanStrings(rows)
+	if err != nil {
+		return errors.Wrapf(err, errLookupRecords, typeA, fqdn)
+	}
+
+	left := sliceToMap(hosts)
+	right := sliceToMap(old)
+	for h := range right {
+		if left[h] {
+			continue
+		}
+		if _, err := b.exec(tx, "DELETE FROM hosts WHERE fqdn = ? AND host = ?", fqdn, h); err != nil {
+			return errors.Wrapf(err, errDeleteRecord, typeA, fqdn)
+		}
+	}
+	for h := range left {
+		if h == "" || right[h] {
+			continue
+		}
+		if err := b.insertHost(tx, base, fqdn, h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *Backend) insertHost(q querier, base, fqdn, host string) error {
+	if _, err := b.exec(q, b.dialect.insert("hosts", []string{"fqdn", "host"}, []string{"domain"}, false), fqdn, host, base); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeA, fqdn)
+	}
+	return nil
+}
+
+// Used to replace the labels of a domain, nil labels are left as they are
+func (b *Backend) setLabels(tx *dbsql.Tx, fqdn string, labels map[string]string) error {
+	if labels == nil {
+		return nil
+	}
+
+	if _, err := b.exec(tx, "DELETE FROM labels WHERE domain = ?", fqdn); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeLabel, fqdn)
+	}
+	for k, v := range labels {
+		if _, err := b.exec(tx, "INSERT INTO labels (domain, name, content) VALUES (?, ?, ?)", fqdn, k, v); err != nil {
+			return errors.Wrapf(err, errSetRecord, typeLabel, fqdn)
+		}
+	}
+
+	return nil
+}
+
+// Used to read the domains which match the condition in the order of their fqdn with their hosts,
+// domains whose expiration is over are left out. A limit of 0 reads all of them.
+func (b *Backend) readDomains(q querier, cond string, args []interface{}, limit int64) ([]model.Domain, error) {
+	query := "SELECT fqdn, cname, ttl, creator_ip, expires_on, deadline FROM domains WHERE " + cond + " AND expires_on > ? ORDER BY fqdn"
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := b.query(q, query, append(args, time.Now().Unix())...)
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeA, cond)
+	}
+	defer rows.Close()
+
+	items := make([]model.Domain, 0)
+	index := make(map[string]int)
+	for rows.Next() {
+		var d model.Domain
+		var expires, deadline int64
+		if err := rows.Scan(&d.Fqdn, &d.CNAME, &d.TTL, &d.CreatorIP, &expires, &deadline); err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeA, cond)
+		}
+		e := time.Unix(expires, 0)
+		d.Expiration = &e
+		if deadline > 0 {
+			t := time.Unix(deadline, 0)
+			d.Deadline = &t
+		}
+		d.Hosts = make([]string, 0)
+		d.SubDomain = make(map[string][]string)
+		index[d.Fqdn] = len(items)
+		items = append(items, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeA, cond)
+	}
+	if len(items) == 0 {
+		return items, nil
+	}
+
+	fqdns := make([]interface{}, 0, len(items))
+	for _, d := range items {
+		fqdns = append(fqdns, d.Fqdn)
+	}
+	hosts, err := b.query(q, "SELECT domain, fqdn, host, drained_until FROM hosts WHERE domain IN "+in(len(fqdns))+" ORDER BY fqdn, host", fqdns...)
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeA, cond)
+	}
+	defer hosts.Close()
+
+	now := time.Now().Unix()
+	for hosts.Next() {
+		var base, fqdn, host string
+		var drained int64
+		if err := hosts.Scan(&base, &fqdn, &host, &drained); err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeA, cond)
+		}
+		d := &items[index[base]]
+		if fqdn == base {
+			d.Hosts = append(d.Hosts, host)
+		} else {
+			prefix := strings.TrimSuffix(fqdn, "."+base)
+			d.SubDomain[prefix] = append(d.SubDomain[prefix], host)
+		}
+		if drained > now {
+			if d.Drained == nil {
+				d.Drained = make(map[string]*time.Time)
+			}
+			until := time.Unix(drained, 0).UTC()
+			d.Drained[host] = &until
+		}
+	}
+
+	return items, hosts.Err()
+}
+
+// Used to check that a domain exists and has not expired
+func (b *Backend) exists(q querier, fqdn string) error {
+	var count int
+	if err := b.queryRow(q, "SELECT COUNT(*) FROM domains WHERE fqdn = ? AND expires_on > ?", fqdn, time.Now().Unix()).Scan(&count); err != nil {
+		return errors.Wrapf(err, errLookupRecords, typeA, fqdn)
+	}
+	if count == 0 {
+		return errors.Errorf(errNoLookupResults, typeA, fqdn)
+	}
+	return nil
+}
+
+// Used to run f in a transaction which is committed when f returns no error
+func (b *Backend) tx(f func(tx *dbsql.Tx) error) error {
+	tx, err := b.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b *Backend) exec(q querier, query string, args ...interface{}) (dbsql.Result, error) {
+	return q.Exec(b.dialect.rebind(query), args...)
+}
+
+func (b *Backend) query(q querier, query string, args ...interface{}) (*dbsql.Rows, error) {
+	return q.Query(b.dialect.rebind(query), args...)
+}
+
+func (b *Backend) queryRow(q querier, query string, args ...interface{}) *dbsql.Row {
+	return q.QueryRow(b.dialect.rebind(query), args...)
+}
+
+// Used to get the fqdn of the domain which a fqdn is under
+// e.g. x1.qrn7oq.lb.rancher.cloud => qrn7oq.lb.rancher.cloud
+func (b *Backend) baseOf(fqdn string) string {
+	return fmt.Sprintf("%s.%s", util.SlugWithZone(fqdn, b.Domain), b.Domain)
+}
+
+// Used to get the seconds a new domain lives, the api clamps the requested lease
+// e.g. {Lease: 0} => 864000
+// e.g. {Lease: 3600} => 3600
+func (b *Backend) leaseSeconds(opts *model.DomainOptions) int64 {
+	if opts.Lease > 0 {
+		return opts.Lease
+	}
+	return int64(b.LeaseTime.Seconds())
+}
+
+// Used to generate a random slug with the slug length of the zone
+func generateSlug(zone string) string {
+	return util.RandStringWithSmall(config.Resolve(zone).SlugLength)
+}
+
+func scanStrings(rows *dbsql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	ss := make([]string, 0)
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+	return ss, rows.Err()
+}
+
+func sliceToMap(ss []string) map[string]bool {
+	m := make(map[string]bool)
+	for _, s := range ss {
+		m[s] = true
+	}
+	return m
+}
@@ -0,0 +1,86 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The operational state is kept by key with the version of its value, a write names the version it
+// was read at and is refused when the row is at another one, so a lost update is refused.
+
+// GetOperationalState returns the state of the key, a key which is never written has version 0.
+func (b *Backend) GetOperationalState(key string) (model.OperationalState, error) {
+	s, err := scanState(b.queryRow(b.DB, "SELECT state_key, content, version, updated_on FROM operational_states WHERE state_key = ?", key))
+	if err == dbsql.ErrNoRows {
+		return model.OperationalState{Key: key}, nil
+	}
+
+	return s, errors.Wrapf(err, errLookupRecords, typeState, key)
+}
+
+// SetOperationalState writes the state when its version is still the current one, the version of
+// the written state is set to s.
+func (b *Backend) SetOperationalState(s *model.OperationalState) error {
+	logrus.Debugf("set operational state: %s", s.Key)
+
+	t := time.Now()
+	value := string(s.Value)
+
+	var res dbsql.Result
+	var err error
+	if s.Version == 0 {
+		res, err = b.exec(b.DB, b.dialect.insert("operational_states", []string{"state_key"}, []string{"content", "version", "updated_on"}, false), s.Key, value, 1, t.Unix())
+	} else {
+		res, err = b.exec(b.DB, "UPDATE operational_states SET content = ?, version = version + 1, updated_on = ? WHERE state_key = ? AND version = ?", value, t.Unix(), s.Key, s.Version)
+	}
+	if err != nil {
+		return errors.Wrapf(err, errSetRecord, typeState, s.Key)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return errors.Wrapf(backend.ErrStateConflict, errSetRecord, typeState, s.Key)
+	}
+
+	s.Version++
+	s.Updated = &t
+	return nil
+}
+
+func (b *Backend) ListOperationalStates() ([]model.OperationalState, error) {
+	rows, err := b.query(b.DB, "SELECT state_key, content, version, updated_on FROM operational_states ORDER BY state_key")
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeState, "operational_states")
+	}
+	defer rows.Close()
+
+	result := make([]model.OperationalState, 0)
+	for rows.Next() {
+		s, err := scanState(rows)
+		if err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeState, "operational_states")
+		}
+		result = append(result, s)
+	}
+
+	return result, rows.Err()
+}
+
+func scanState(row interface{ Scan(...interface{}) error }) (model.OperationalState, error) {
+	var s model.OperationalState
+	var value string
+	var updated int64
+	if err := row.Scan(&s.Key, &value, &s.Version, &updated); err != nil {
+		return s, err
+	}
+	if value != "" {
+		s.Value = []byte(value)
+	}
+	t := time.Unix(updated, 0)
+	s.Updated = &t
+	return s, nil
+}
@@ -0,0 +1,59 @@
+package sql
+
+import (
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Suspend keeps the suspension in a table of its own, it outlives the rows of the domain
+// until it is removed by Unsuspend.
+func (b *Backend) Suspend(s *model.Suspension) error {
+	logrus.Debugf("suspend domain: %s", s.Fqdn)
+
+	if s.Time == nil {
+		t := time.Now()
+		s.Time = &t
+	}
+
+	if _, err := b.exec(b.DB, b.dialect.insert("suspensions", []string{"fqdn"}, []string{"reason", "created_on"}, true), s.Fqdn, s.Reason, s.Time.Unix()); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeSuspension, s.Fqdn)
+	}
+
+	return nil
+}
+
+func (b *Backend) Unsuspend(fqdn string) error {
+	logrus.Debugf("unsuspend domain: %s", fqdn)
+
+	if _, err := b.exec(b.DB, "DELETE FROM suspensions WHERE fqdn = ?", fqdn); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeSuspension, fqdn)
+	}
+
+	return nil
+}
+
+func (b *Backend) ListSuspensions() ([]model.Suspension, error) {
+	rows, err := b.query(b.DB, "SELECT fqdn, reason, created_on FROM suspensions ORDER BY fqdn")
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeSuspension, "suspensions")
+	}
+	defer rows.Close()
+
+	result := make([]model.Suspension, 0)
+	for rows.Next() {
+		var s model.Suspension
+		var created int64
+		if err := rows.Scan(&s.Fqdn, &s.Reason, &created); err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeSuspension, "suspensions")
+		}
+		t := time.Unix(created, 0)
+		s.Time = &t
+		result = append(result, s)
+	}
+
+	return result, rows.Err()
+}
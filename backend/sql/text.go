@@ -0,0 +1,165 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The TXT records are kept by fqdn and order, the values of ACME orders are kept apart so concurrent
+// orders of one fqdn are all answered. A record expires with its domain, unless it is an ACME
+// challenge record, which gets an expiration of its own
+// e.g. _acme-challenge.sample.lb.rancher.cloud, 4f1b => txt_records {fqdn, order_id: 4f1b, expires_on}
+
+func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("set %s record for domain options: %s", typeTXT, opts.String())
+
+	if err := b.checkText(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	if err := b.setText(opts, false); err != nil {
+		return d, err
+	}
+
+	return b.GetText(opts)
+}
+
+func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("get %s record for domain options: %s", typeTXT, opts.String())
+
+	if err := b.checkText(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	// without an order all values of the fqdn are read, the record answers until the last one expires
+	now := time.Now().Unix()
+	query := `SELECT t.content, t.expires_on, d.expires_on FROM txt_records t JOIN domains d ON d.fqdn = t.domain
+WHERE t.fqdn = ? AND d.expires_on > ? AND (t.expires_on = 0 OR t.expires_on > ?)`
+	args := []interface{}{opts.Fqdn, now, now}
+	if opts.Order != "" {
+		query += " AND t.order_id = ?"
+		args = append(args, opts.Order)
+	}
+	rows, err := b.query(b.DB, query+" ORDER BY t.order_id", args...)
+	if err != nil {
+		return d, errors.Wrapf(err, errLookupRecords, typeTXT, opts.Fqdn)
+	}
+	defer rows.Close()
+
+	texts := make([]string, 0)
+	var last int64
+	for rows.Next() {
+		var text string
+		var expires, domainExpires int64
+		if err := rows.Scan(&text, &expires, &domainExpires); err != nil {
+			return d, errors.Wrapf(err, errLookupRecords, typeTXT, opts.Fqdn)
+		}
+		if expires == 0 {
+			expires = domainExpires
+		}
+		if expires > last {
+			last = expires
+		}
+		texts = append(texts, text)
+	}
+	if err := rows.Err(); err != nil {
+		return d, errors.Wrapf(err, errLookupRecords, typeTXT, opts.Fqdn)
+	}
+	if len(texts) == 0 {
+		return d, errors.Errorf(errEmptyRecord, typeTXT, opts.Fqdn)
+	}
+	e := time.Unix(last, 0)
+
+	d.Fqdn = opts.Fqdn
+	d.Text = texts[0]
+	if opts.Order == "" {
+		d.Texts = texts
+	}
+	d.Order = opts.Order
+	d.Expiration = &e
+
+	return d, nil
+}
+
+func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err error) {
+	logrus.Debugf("update %s record for domain options: %s", typeTXT, opts.String())
+
+	if err := b.checkText(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	if _, err := b.GetText(opts.ReplacedText()); err != nil {
+		return d, err
+	}
+
+	if err := b.setText(opts, true); err != nil {
+		return d, err
+	}
+
+	return b.GetText(opts)
+}
+
+// Without an order all values of the fqdn are deleted.
+func (b *Backend) DeleteText(opts *model.DomainOptions) error {
+	logrus.Debugf("delete %s record for domain options: %s", typeTXT, opts.String())
+
+	query, args := "DELETE FROM txt_records WHERE fqdn = ?", []interface{}{opts.Fqdn}
+	if opts.Order != "" {
+		query += " AND order_id = ?"
+		args = append(args, opts.Order)
+	}
+	if _, err := b.exec(b.DB, query, args...); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeTXT, opts.Fqdn)
+	}
+
+	return nil
+}
+
+// Used to write a TXT record below the domain of its fqdn, which has to exist
+func (b *Backend) setText(opts *model.DomainOptions, replace bool) error {
+	base := b.baseOf(opts.Fqdn)
+
+	return b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, base); err != nil {
+			return errors.Wrapf(err, errEmptyRecord, typeToken, base)
+		}
+
+		// a value without an order replaces all values of the fqdn when it is updated
+		if replace && (opts.Order == "" || opts.Replace) {
+			if _, err := b.exec(tx, "DELETE FROM txt_records WHERE fqdn = ?", opts.Fqdn); err != nil {
+				return errors.Wrapf(err, errDeleteRecord, typeTXT, opts.Fqdn)
+			}
+		}
+
+		// an updated challenge record gets a new expiration, like a new lease of the etcdv3 backend
+		_, err := b.exec(tx, b.dialect.insert("txt_records", []string{"fqdn", "order_id"}, []string{"domain", "content", "expires_on"}, true),
+			opts.Fqdn, opts.Order, base, opts.Text, b.textExpiration(opts.Fqdn))
+		return errors.Wrapf(err, errSetRecord, typeTXT, opts.Fqdn)
+	})
+}
+
+// Used to get the expiration of a TXT record, ACME challenge records expire long before their
+// domain so stale challenge values are cleaned up. 0 means the record expires with its domain.
+func (b *Backend) textExpiration(fqdn string) int64 {
+	if b.ChallengeTTL <= 0 || !util.IsACMEChallenge(fqdn) {
+		return 0
+	}
+	return time.Now().Add(b.ChallengeTTL).Unix()
+}
+
+// Used to check that a TXT record is below a domain
+// e.g. _acme-challenge.sample.lb.rancher.cloud => ok
+// e.g. sample.lb.rancher.cloud => not valid
+func (b *Backend) checkText(fqdn string) error {
+	if len(strings.Split(fqdn, "."))-len(strings.Split(b.Domain, ".")) <= 1 {
+		return errors.Errorf(errNotValidDomainName, fqdn)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package sql
+
+import (
+	dbsql "database/sql"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// RotateToken replaces the token origin of a domain, the domain keeps its expiration and the
+// tokens derived from the old origin are refused.
+func (b *Backend) RotateToken(fqdn, token string) error {
+	logrus.Debugf("rotate %s record for fqdn: %s", typeToken, fqdn)
+
+	return b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, fqdn); err != nil {
+			return errors.Wrapf(err, errEmptyRecord, typeToken, fqdn)
+		}
+		_, err := b.exec(tx, "UPDATE domains SET token = ? WHERE fqdn = ?", token, fqdn)
+		return errors.Wrapf(err, errSetRecord, typeToken, fqdn)
+	})
+}
+
+// SetScopedToken keeps the origin of a secondary token of a domain, the secondary token is deleted
+// with the domain. An origin of the same scope is replaced.
+func (b *Backend) SetScopedToken(fqdn, scope, origin string) error {
+	logrus.Debugf("set %s %s record for fqdn: %s", scope, typeToken, fqdn)
+
+	return b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, fqdn); err != nil {
+			return errors.Wrapf(err, errEmptyRecord, typeToken, fqdn)
+		}
+		_, err := b.exec(tx, b.dialect.insert("scoped_tokens", []string{"domain", "scope"}, []string{"origin"}, true), fqdn, scope, origin)
+		return errors.Wrapf(err, errSetRecord, typeToken, fqdn)
+	})
+}
+
+func (b *Backend) GetScopedToken(fqdn, scope string) (string, error) {
+	var origin string
+	err := b.queryRow(b.DB, "SELECT origin FROM scoped_tokens WHERE domain = ? AND scope = ?", fqdn, scope).Scan(&origin)
+	if err == dbsql.ErrNoRows {
+		return "", errors.Errorf(errEmptyRecord, typeToken, fqdn+"/"+scope)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, errLookupRecords, typeToken, fqdn+"/"+scope)
+	}
+
+	return origin, nil
+}
+
+func (b *Backend) DeleteScopedToken(fqdn, scope string) error {
+	logrus.Debugf("delete %s %s record for fqdn: %s", scope, typeToken, fqdn)
+
+	if _, err := b.exec(b.DB, "DELETE FROM scoped_tokens WHERE domain = ? AND scope = ?", fqdn, scope); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeToken, fqdn+"/"+scope)
+	}
+
+	return nil
+}
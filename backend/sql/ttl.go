@@ -0,0 +1,64 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetTTL keeps the ttl of a domain, the dns server answers the hosts of the domain and its sub
+// domains with it. 0 restores the default ttl, TXT records keep the default ttl.
+func (b *Backend) SetTTL(fqdn string, ttl uint32) (d model.Domain, err error) {
+	logrus.Debugf("set %s of domain %s to %d", typeTTL, fqdn, ttl)
+
+	err = b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, fqdn); err != nil {
+			return err
+		}
+		_, err := b.exec(tx, "UPDATE domains SET ttl = ? WHERE fqdn = ?", ttl, fqdn)
+		return errors.Wrapf(err, errSetRecord, typeTTL, fqdn)
+	})
+	if err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
+
+// DrainHost keeps the time the host of the domain and its sub domains is drained until, the dns
+// server leaves the host out of its answers until then. A zero time undrains it.
+func (b *Backend) DrainHost(fqdn, host string, until time.Time) (d model.Domain, err error) {
+	logrus.Debugf("set %s of host %s of domain %s to %s", typeDrain, host, fqdn, until.Format(time.RFC3339))
+
+	var drained int64
+	if !until.IsZero() {
+		drained = until.Unix()
+	}
+
+	err = b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, fqdn); err != nil {
+			return err
+		}
+
+		var count int
+		if err := b.queryRow(tx, "SELECT COUNT(*) FROM hosts WHERE domain = ? AND host = ?", fqdn, host).Scan(&count); err != nil {
+			return errors.Wrapf(err, errLookupRecords, typeA, fqdn)
+		}
+		if count == 0 {
+			return errors.Wrapf(backend.ErrUnknownHost, errNoDrainHost, host, fqdn)
+		}
+
+		_, err := b.exec(tx, "UPDATE hosts SET drained_until = ? WHERE domain = ? AND host = ?", drained, fqdn, host)
+		return errors.Wrapf(err, errSetRecord, typeDrain, fqdn)
+	})
+	if err != nil {
+		return d, err
+	}
+
+	return b.Get(&model.DomainOptions{Fqdn: fqdn})
+}
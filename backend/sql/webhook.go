@@ -0,0 +1,68 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SetWebhook keeps the webhook with its domain, webhooks are deleted together with
+// their domain.
+func (b *Backend) SetWebhook(w *model.Webhook) error {
+	logrus.Debugf("set webhook %s for domain: %s", w.ID, w.Fqdn)
+
+	if w.Time == nil {
+		t := time.Now()
+		w.Time = &t
+	}
+
+	return b.tx(func(tx *dbsql.Tx) error {
+		if err := b.exists(tx, w.Fqdn); err != nil {
+			return errors.Wrapf(err, errEmptyRecord, typeToken, w.Fqdn)
+		}
+		_, err := b.exec(tx, b.dialect.insert("webhooks", []string{"domain", "id"}, []string{"url", "secret", "events", "created_on"}, true),
+			w.Fqdn, w.ID, w.URL, w.Secret, strings.Join(w.Events, ","), w.Time.Unix())
+		return errors.Wrapf(err, errSetRecord, typeWebhook, w.Fqdn)
+	})
+}
+
+func (b *Backend) ListWebhooks(fqdn string) ([]model.Webhook, error) {
+	rows, err := b.query(b.DB, "SELECT id, url, secret, events, created_on FROM webhooks WHERE domain = ? ORDER BY id", fqdn)
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeWebhook, fqdn)
+	}
+	defer rows.Close()
+
+	result := make([]model.Webhook, 0)
+	for rows.Next() {
+		w := model.Webhook{Fqdn: fqdn}
+		var events string
+		var created int64
+		if err := rows.Scan(&w.ID, &w.URL, &w.Secret, &events, &created); err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeWebhook, fqdn)
+		}
+		if events != "" {
+			w.Events = strings.Split(events, ",")
+		}
+		t := time.Unix(created, 0)
+		w.Time = &t
+		result = append(result, w)
+	}
+
+	return result, rows.Err()
+}
+
+func (b *Backend) DeleteWebhook(fqdn, id string) error {
+	logrus.Debugf("delete webhook %s for domain: %s", id, fqdn)
+
+	if _, err := b.exec(b.DB, "DELETE FROM webhooks WHERE domain = ? AND id = ?", fqdn, id); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeWebhook, fqdn+"/"+id)
+	}
+
+	return nil
+}
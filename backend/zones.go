@@ -0,0 +1,28 @@
+package backend
+
+import (
+	"github.com/rancher/rdns-server/util"
+)
+
+// Zoned is implemented by the backends which serve more than one root domain,
+// the zone of GetZone is the first one of them.
+type Zoned interface {
+	GetZones() []string
+}
+
+// Zones returns the root domains which the backend serves.
+func Zones(b Backend) []string {
+	if z, ok := b.(Zoned); ok {
+		return z.GetZones()
+	}
+	return []string{b.GetZone()}
+}
+
+// ZoneOf returns the root domain of a fqdn, the zone of the backend when the fqdn is under none of them
+// e.g. qrn7oq.lb.example2.com => lb.example2.com
+func ZoneOf(b Backend, fqdn string) string {
+	if root := util.RootOf(fqdn, Zones(b)); root != "" {
+		return root
+	}
+	return b.GetZone()
+}
@@ -0,0 +1,219 @@
+package approuter
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+const adminPath = "/admin"
+
+// AdminClient calls the admin routes with ADMIN_TOKEN or the credentials of a role of
+// ADMIN_ROLES_FILE, the routes a role may not call are answered with 403.
+type AdminClient struct {
+	client *Client
+	token  string
+}
+
+func NewAdminClient(base, token string) *AdminClient {
+	return &AdminClient{client: NewTokenClient(base), token: token}
+}
+
+func (a *AdminClient) call(method, path string, in, out interface{}) error {
+	_, err := a.client.call(method, adminPath+path, a.token, in, out)
+	return err
+}
+
+// Used to get the query of the search options, the filters which are not set are left out
+func searchQuery(opts *model.SearchOptions) string {
+	vals := url.Values{}
+	if opts == nil {
+		return ""
+	}
+	if opts.Limit > 0 {
+		vals.Set("limit", strconv.FormatInt(opts.Limit, 10))
+	}
+	for name, v := range map[string]string{
+		"continue":  opts.Continue,
+		"root":      opts.Root,
+		"host":      opts.Host,
+		"label":     opts.Label,
+		"creatorIP": opts.CreatorIP,
+		"text~":     opts.Text,
+	} {
+		if v != "" {
+			vals.Set(name, v)
+		}
+	}
+	for name, t := range map[string]*time.Time{
+		"expiringBefore": opts.ExpiringBefore,
+		"expiringAfter":  opts.ExpiringAfter,
+	} {
+		if t != nil {
+			vals.Set(name, t.Format(time.RFC3339))
+		}
+	}
+	if len(vals) == 0 {
+		return ""
+	}
+	return "?" + vals.Encode()
+}
+
+// ListDomains gets a page of the domains which match the options, the next page is listed with
+// the continue token of the answer.
+func (a *AdminClient) ListDomains(opts *model.SearchOptions) (l model.DomainList, err error) {
+	err = a.call(http.MethodGet, "/domains"+searchQuery(opts), nil, &l)
+	return l, errors.Wrap(err, "ListDomains: failed to execute a request")
+}
+
+// WalkDomains lists every domain which matches the options page by page, the walk stops at the
+// first error of f.
+func (a *AdminClient) WalkDomains(opts *model.SearchOptions, f func(model.Domain) error) error {
+	o := model.SearchOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	for {
+		l, err := a.ListDomains(&o)
+		if err != nil {
+			return err
+		}
+		for _, d := range l.Items {
+			if err := f(d); err != nil {
+				return err
+			}
+		}
+		if l.Continue == "" {
+			return nil
+		}
+		o.Continue = l.Continue
+	}
+}
+
+func (a *AdminClient) InspectDomain(fqdn string) (i model.DomainInspection, err error) {
+	err = a.call(http.MethodGet, "/domains/"+fqdn, nil, &i)
+	return i, errors.Wrap(err, "InspectDomain: failed to execute a request")
+}
+
+// ForceDeleteDomain deletes a domain without its token.
+func (a *AdminClient) ForceDeleteDomain(fqdn string) error {
+	err := a.call(http.MethodDelete, "/domains/"+fqdn, nil, nil)
+	return errors.Wrap(err, "ForceDeleteDomain: failed to execute a request")
+}
+
+func (a *AdminClient) SetDomainExpiration(fqdn string, opts *model.ExpirationOptions) (d model.Domain, err error) {
+	err = a.call(http.MethodPut, "/domains/"+fqdn+"/expiration", opts, &d)
+	return d, errors.Wrap(err, "SetDomainExpiration: failed to execute a request")
+}
+
+// GetHostDomains gets the domains which answer a host.
+func (a *AdminClient) GetHostDomains(host string) (h model.HostDomains, err error) {
+	err = a.call(http.MethodGet, "/hosts/"+host, nil, &h)
+	return h, errors.Wrap(err, "GetHostDomains: failed to execute a request")
+}
+
+// ReplaceHost swaps a host for another one in every domain which answers it.
+func (a *AdminClient) ReplaceHost(host, to string) (r model.HostReplace, err error) {
+	err = a.call(http.MethodPost, "/hosts/"+host+"/replace", &model.HostReplaceOptions{To: to}, &r)
+	return r, errors.Wrap(err, "ReplaceHost: failed to execute a request")
+}
+
+// RemoveNode removes the addresses of a node which left its cluster from every domain.
+func (a *AdminClient) RemoveNode(opts *model.NodeRemovalOptions) (r model.NodeRemoval, err error) {
+	err = a.call(http.MethodPost, "/nodes/removed", opts, &r)
+	return r, errors.Wrap(err, "RemoveNode: failed to execute a request")
+}
+
+func (a *AdminClient) ListSchedule() (cs []model.ScheduledChange, err error) {
+	err = a.call(http.MethodGet, "/schedule", nil, &cs)
+	return cs, errors.Wrap(err, "ListSchedule: failed to execute a request")
+}
+
+func (a *AdminClient) ListSuspensions() (ss []model.Suspension, err error) {
+	err = a.call(http.MethodGet, "/suspensions", nil, &ss)
+	return ss, errors.Wrap(err, "ListSuspensions: failed to execute a request")
+}
+
+func (a *AdminClient) SuspendDomain(fqdn, reason string) (s model.Suspension, err error) {
+	err = a.call(http.MethodPut, "/suspensions/"+fqdn, &model.SuspensionOptions{Reason: reason}, &s)
+	return s, errors.Wrap(err, "SuspendDomain: failed to execute a request")
+}
+
+func (a *AdminClient) UnsuspendDomain(fqdn string) error {
+	err := a.call(http.MethodDelete, "/suspensions/"+fqdn, nil, nil)
+	return errors.Wrap(err, "UnsuspendDomain: failed to execute a request")
+}
+
+func (a *AdminClient) GetCoreFileDrift() (d model.CoreFileDrift, err error) {
+	err = a.call(http.MethodGet, "/corefile/drift", nil, &d)
+	return d, errors.Wrap(err, "GetCoreFileDrift: failed to execute a request")
+}
+
+// GetConfig gets the effective configuration of the server, with the fqdn it is the one of the
+// root domain of the fqdn.
+func (a *AdminClient) GetConfig(fqdn string) (c model.Config, err error) {
+	path := "/config"
+	if fqdn != "" {
+		path += "?fqdn=" + url.QueryEscape(fqdn)
+	}
+	err = a.call(http.MethodGet, path, nil, &c)
+	return c, errors.Wrap(err, "GetConfig: failed to execute a request")
+}
+
+func (a *AdminClient) GetBackendState() (s model.BackendState, err error) {
+	err = a.call(http.MethodGet, "/backend/state", nil, &s)
+	return s, errors.Wrap(err, "GetBackendState: failed to execute a request")
+}
+
+func (a *AdminClient) SetBackendState(state *model.BackendState) (s model.BackendState, err error) {
+	err = a.call(http.MethodPut, "/backend/state", state, &s)
+	return s, errors.Wrap(err, "SetBackendState: failed to execute a request")
+}
+
+func (a *AdminClient) ListOperationalStates() (ss []model.OperationalState, err error) {
+	err = a.call(http.MethodGet, "/state", nil, &ss)
+	return ss, errors.Wrap(err, "ListOperationalStates: failed to execute a request")
+}
+
+func (a *AdminClient) GetMaintenance() (m model.Maintenance, err error) {
+	err = a.call(http.MethodGet, "/state/maintenance", nil, &m)
+	return m, errors.Wrap(err, "GetMaintenance: failed to execute a request")
+}
+
+// SetMaintenance turns the maintenance mode on or off, the writes of the domains are answered
+// with 503 and the message while it is on.
+func (a *AdminClient) SetMaintenance(enabled bool, message string) (m model.Maintenance, err error) {
+	err = a.call(http.MethodPut, "/state/maintenance", &model.Maintenance{Enabled: enabled, Message: message}, &m)
+	return m, errors.Wrap(err, "SetMaintenance: failed to execute a request")
+}
+
+func (a *AdminClient) ListFeatures() (features map[string]bool, err error) {
+	err = a.call(http.MethodGet, "/state/features", nil, &features)
+	return features, errors.Wrap(err, "ListFeatures: failed to execute a request")
+}
+
+func (a *AdminClient) SetFeature(name string, enabled bool) (features map[string]bool, err error) {
+	err = a.call(http.MethodPut, "/state/features/"+name, &model.Feature{Enabled: enabled}, &features)
+	return features, errors.Wrap(err, "SetFeature: failed to execute a request")
+}
+
+func (a *AdminClient) ListAPIKeys() (ks []model.APIKey, err error) {
+	err = a.call(http.MethodGet, "/apikeys", nil, &ks)
+	return ks, errors.Wrap(err, "ListAPIKeys: failed to execute a request")
+}
+
+// CreateAPIKey creates an api key, its secret is only in the answer of the creation.
+func (a *AdminClient) CreateAPIKey(opts *model.APIKeyOptions) (k model.APIKey, err error) {
+	err = a.call(http.MethodPost, "/apikeys", opts, &k)
+	return k, errors.Wrap(err, "CreateAPIKey: failed to execute a request")
+}
+
+func (a *AdminClient) RevokeAPIKey(id string) error {
+	err := a.call(http.MethodDelete, "/apikeys/"+id, nil, nil)
+	return errors.Wrap(err, "RevokeAPIKey: failed to execute a request")
+}
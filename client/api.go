@@ -0,0 +1,102 @@
+package approuter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// APIError is the error the api answers a request with, Status is its http status.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("got request status %d: %s", e.Status, e.Message)
+}
+
+// StatusOf gets the http status of an error of the api, 0 is returned for other errors
+// e.g. a domain which expired is answered with 403.
+func StatusOf(err error) int {
+	if e, ok := errors.Cause(err).(*APIError); ok {
+		return e.Status
+	}
+	return 0
+}
+
+// envelope is what every json answer of the api is wrapped in, data is decoded by the caller
+type envelope struct {
+	Status  int             `json:"status"`
+	Message string          `json:"msg"`
+	Data    json.RawMessage `json:"data"`
+	Token   string          `json:"token"`
+}
+
+// call sends the payload in to path under the base url with the token and decodes the data of the
+// answer into out, the token of the answer is returned for the routes which hand one out.
+func (c *Client) call(method, path, token string, in, out interface{}) (string, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := jsonBody(in)
+		if err != nil {
+			return "", err
+		}
+		body = b
+	}
+
+	req, err := c.request(method, c.base+path, body)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to build a request of %s %s", method, path)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to execute a request of %s %s", method, path)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "read response body error")
+	}
+
+	var e envelope
+	if err := json.Unmarshal(b, &e); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", &APIError{Status: resp.StatusCode, Message: string(b)}
+		}
+		return "", errors.Wrapf(err, "decode response error: %s", string(b))
+	}
+	logrus.Debugf("got response of %s %s: %d %s", method, path, e.Status, e.Message)
+	if resp.StatusCode != http.StatusOK || (e.Status != 0 && e.Status != http.StatusOK) {
+		status := e.Status
+		if status == 0 {
+			status = resp.StatusCode
+		}
+		return "", &APIError{Status: status, Message: e.Message}
+	}
+
+	if out != nil && len(e.Data) > 0 {
+		if err := json.Unmarshal(e.Data, out); err != nil {
+			return "", errors.Wrapf(err, "decode response data error: %s", string(e.Data))
+		}
+	}
+	// the records are checked end to end when the server sends their checksum
+	if d, ok := out.(*model.Domain); ok && resp.Header.Get(model.ChecksumHeader) != "" {
+		if sum := d.Checksum(); sum != resp.Header.Get(model.ChecksumHeader) {
+			return "", errors.Errorf("checksum %s of the records of %s does not match the answered %s", sum, d.Fqdn, resp.Header.Get(model.ChecksumHeader))
+		}
+	}
+	return e.Token, nil
+}
@@ -0,0 +1,91 @@
+package approuter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+// the errors of a polled device code, as in RFC 8628
+const (
+	deviceAuthorizationPending = "authorization_pending"
+	deviceSlowDown             = "slow_down"
+)
+
+// RequestDeviceCode asks for a device code of an existing domain, the owner of the domain
+// approves its user code at the verification uri.
+func (c *Client) RequestDeviceCode(fqdn string) (code model.DeviceCode, err error) {
+	body, err := jsonBody(&model.DeviceOptions{Fqdn: fqdn})
+	if err != nil {
+		return code, err
+	}
+
+	req, err := c.request(http.MethodPost, fmt.Sprintf("%s/device/code", c.base), body)
+	if err != nil {
+		return code, errors.Wrap(err, "RequestDeviceCode: failed to build a request")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return code, errors.Wrap(err, "RequestDeviceCode: failed to execute a request")
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return code, errors.Wrap(err, "read response body error")
+	}
+	var o model.DeviceCodeResponse
+	if err := json.Unmarshal(b, &o); err != nil {
+		return code, errors.Wrapf(err, "decode response error: %s", string(b))
+	}
+	if o.Status != http.StatusOK {
+		return code, errors.Errorf("RequestDeviceCode: got request error: %s", o.Message)
+	}
+
+	return o.Data, nil
+}
+
+// PollDeviceToken polls for the token of a device code until the user code is approved,
+// it fails once the device code expires.
+func (c *Client) PollDeviceToken(code model.DeviceCode) (string, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		body, err := jsonBody(&model.DeviceOptions{DeviceCode: code.DeviceCode})
+		if err != nil {
+			return "", err
+		}
+		req, err := c.request(http.MethodPost, fmt.Sprintf("%s/device/token", c.base), body)
+		if err != nil {
+			return "", errors.Wrap(err, "PollDeviceToken: failed to build a request")
+		}
+
+		o, err := c.do(req)
+		switch o.Message {
+		case deviceAuthorizationPending:
+			continue
+		case deviceSlowDown:
+			interval += 5 * time.Second
+			continue
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "PollDeviceToken: failed to execute a request")
+		}
+		if o.Status != http.StatusOK {
+			return "", errors.Errorf("PollDeviceToken: got request status %d", o.Status)
+		}
+		return o.Token, nil
+	}
+
+	return "", errors.Errorf("PollDeviceToken: user code %s expired", code.UserCode)
+}
@@ -0,0 +1,320 @@
+package approuter
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	domainPath = "/domain"
+	txtPath    = "/txt"
+)
+
+// DomainClient manages one domain with its token, the token is swapped when it is rotated so a
+// DomainClient can be kept for the life of the domain.
+type DomainClient struct {
+	client *Client
+	fqdn   string
+
+	lock  sync.RWMutex
+	token string
+	// OnToken is called with the new token after the token is rotated, so it can be stored
+	OnToken func(fqdn, token string)
+}
+
+// Register creates a domain of the options and gets the client which manages it,
+// a domain with a CNAME is created as a CNAME domain.
+func (c *Client) Register(opts *model.DomainOptions) (*DomainClient, model.Domain, error) {
+	return c.RegisterWithAPIKey(opts, "")
+}
+
+// RegisterWithAPIKey creates a domain like Register with an api key, the servers which restrict
+// who creates domains require one. The domain is managed with its own token afterwards.
+func (c *Client) RegisterWithAPIKey(opts *model.DomainOptions, key string) (*DomainClient, model.Domain, error) {
+	path := domainPath
+	if opts.CNAME != "" {
+		path += cnamePath
+	}
+	if opts.Normal {
+		path += "?normal=true"
+	}
+
+	var d model.Domain
+	token, err := c.call(http.MethodPost, path, key, opts, &d)
+	if err != nil {
+		return nil, d, errors.Wrap(err, "Register: failed to create a domain")
+	}
+	return c.Domain(d.Fqdn, token), d, nil
+}
+
+// Domain gets the client which manages an existing domain with its token.
+func (c *Client) Domain(fqdn, token string) *DomainClient {
+	return &DomainClient{client: c, fqdn: fqdn, token: token}
+}
+
+// RequestRecovery creates the recovery challenge of a domain, the challenge is served as the TXT
+// record _rdns-recovery.<fqdn> which proves the ownership of the domain.
+func (c *Client) RequestRecovery(fqdn string) (r model.RecoveryChallenge, err error) {
+	_, err = c.call(http.MethodPost, domainPath+"/"+fqdn+"/token/recovery", "", nil, &r)
+	return r, errors.Wrap(err, "RequestRecovery: failed to execute a request")
+}
+
+// RecoverToken gets a new token of a domain once the challenge is served, the old token is revoked.
+func (c *Client) RecoverToken(fqdn, challenge string) (*DomainClient, error) {
+	token, err := c.call(http.MethodPost, domainPath+"/"+fqdn+"/token/recovery/verify", "", &model.RecoveryOptions{Challenge: challenge}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "RecoverToken: failed to execute a request")
+	}
+	return c.Domain(fqdn, token), nil
+}
+
+// Fqdn gets the domain the client manages.
+func (d *DomainClient) Fqdn() string {
+	return d.fqdn
+}
+
+// Token gets the current token of the domain.
+func (d *DomainClient) Token() string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.token
+}
+
+func (d *DomainClient) setToken(token string) {
+	d.lock.Lock()
+	d.token = token
+	d.lock.Unlock()
+
+	if d.OnToken != nil {
+		d.OnToken(d.fqdn, token)
+	}
+}
+
+func (d *DomainClient) call(method, path string, in, out interface{}) (string, error) {
+	return d.client.call(method, domainPath+"/"+d.fqdn+path, d.Token(), in, out)
+}
+
+func (d *DomainClient) domain(name, method, path string, in interface{}) (r model.Domain, err error) {
+	_, err = d.call(method, path, in, &r)
+	return r, errors.Wrapf(err, "%s: failed to execute a request", name)
+}
+
+func (d *DomainClient) Get() (model.Domain, error) {
+	return d.domain("Get", http.MethodGet, "", nil)
+}
+
+// Update sets the hosts and subdomains of the domain, the ones which are not given are removed.
+func (d *DomainClient) Update(hosts []string, subDomain map[string][]string) (model.Domain, error) {
+	return d.domain("Update", http.MethodPut, "", &model.DomainOptions{Fqdn: d.fqdn, Hosts: hosts, SubDomain: subDomain})
+}
+
+// PatchHosts adds and removes hosts of the domain without sending the hosts which are kept.
+func (d *DomainClient) PatchHosts(add, remove []string) (model.Domain, error) {
+	return d.domain("PatchHosts", http.MethodPatch, "/hosts", &model.HostsPatch{Add: add, Remove: remove})
+}
+
+func (d *DomainClient) Delete() error {
+	_, err := d.call(http.MethodDelete, "", nil, nil)
+	return errors.Wrap(err, "Delete: failed to execute a request")
+}
+
+// Renew extends the expiration of the domain by its lease.
+func (d *DomainClient) Renew() (model.Domain, error) {
+	return d.domain("Renew", http.MethodPut, "/renew", nil)
+}
+
+// Keepalive extends the expiration of the domain like Renew, the answer only holds the new
+// expiration so it suits agents which renew often.
+func (d *DomainClient) Keepalive() (k model.Keepalive, err error) {
+	_, err = d.call(http.MethodPut, "/keepalive", nil, &k)
+	return k, errors.Wrap(err, "Keepalive: failed to execute a request")
+}
+
+// RotateToken replaces the token of the domain, the client uses the new token from then on.
+func (d *DomainClient) RotateToken() (string, error) {
+	token, err := d.call(http.MethodPost, "/token/rotate", nil, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "RotateToken: failed to execute a request")
+	}
+	d.setToken(token)
+	return token, nil
+}
+
+// CreateReadOnlyToken creates the read-only token of the domain, an existing one is replaced.
+func (d *DomainClient) CreateReadOnlyToken() (string, error) {
+	token, err := d.call(http.MethodPost, "/token/read-only", nil, nil)
+	return token, errors.Wrap(err, "CreateReadOnlyToken: failed to execute a request")
+}
+
+func (d *DomainClient) DeleteReadOnlyToken() error {
+	_, err := d.call(http.MethodDelete, "/token/read-only", nil, nil)
+	return errors.Wrap(err, "DeleteReadOnlyToken: failed to execute a request")
+}
+
+// ApproveDevice approves the user code of a device which asked for a token of the domain.
+func (d *DomainClient) ApproveDevice(userCode string) error {
+	_, err := d.call(http.MethodPost, "/device/approve", &model.DeviceOptions{Fqdn: d.fqdn, UserCode: userCode}, nil)
+	return errors.Wrap(err, "ApproveDevice: failed to execute a request")
+}
+
+func textPath(name, order string) string {
+	path := "/" + name + txtPath
+	if order != "" {
+		path += "?order=" + url.QueryEscape(order)
+	}
+	return path
+}
+
+// SetText creates the TXT record of a name under the domain e.g. _acme-challenge.<fqdn>, the order
+// keeps the text apart from the ones of the other orders, empty keeps it by its content so every
+// text of the name is answered.
+func (d *DomainClient) SetText(name, text, order string) (model.Domain, error) {
+	return d.text("SetText", http.MethodPost, name, order, &model.DomainOptions{Fqdn: name, Text: text, Order: order})
+}
+
+func (d *DomainClient) GetText(name, order string) (model.Domain, error) {
+	return d.text("GetText", http.MethodGet, name, order, nil)
+}
+
+func (d *DomainClient) UpdateText(name, text, order string) (model.Domain, error) {
+	return d.text("UpdateText", http.MethodPut, name, order, &model.DomainOptions{Fqdn: name, Text: text, Order: order})
+}
+
+// DeleteText deletes the text of the order, empty deletes all texts of the name.
+func (d *DomainClient) DeleteText(name, order string) error {
+	_, err := d.client.call(http.MethodDelete, domainPath+textPath(name, order), d.Token(), nil, nil)
+	return errors.Wrap(err, "DeleteText: failed to execute a request")
+}
+
+// DeleteTextValue deletes a text which is created without an order and keeps the other texts of the name.
+func (d *DomainClient) DeleteTextValue(name, text string) error {
+	return errors.Wrap(d.DeleteText(name, model.TextOrder(text)), "DeleteTextValue: failed to execute a request")
+}
+
+// the TXT records are served by the routes of their own name with the token of the domain
+func (d *DomainClient) text(fn, method, name, order string, in interface{}) (r model.Domain, err error) {
+	_, err = d.client.call(method, domainPath+textPath(name, order), d.Token(), in, &r)
+	return r, errors.Wrapf(err, "%s: failed to execute a request", fn)
+}
+
+func (d *DomainClient) GetCNAME() (model.Domain, error) {
+	return d.domain("GetCNAME", http.MethodGet, cnamePath, nil)
+}
+
+func (d *DomainClient) UpdateCNAME(cname string) (model.Domain, error) {
+	return d.domain("UpdateCNAME", http.MethodPut, cnamePath, &model.DomainOptions{Fqdn: d.fqdn, CNAME: cname})
+}
+
+func (d *DomainClient) DeleteCNAME() error {
+	_, err := d.call(http.MethodDelete, cnamePath, nil, nil)
+	return errors.Wrap(err, "DeleteCNAME: failed to execute a request")
+}
+
+// SetTTL sets the TTL of the records of the domain, 0 means the TTL of the server.
+func (d *DomainClient) SetTTL(ttl uint32) (model.Domain, error) {
+	return d.domain("SetTTL", http.MethodPut, "/ttl", &model.TTLOptions{TTL: ttl})
+}
+
+// DrainHost stops answering a host of the domain, the host is answered again after the timeout
+// in seconds, 0 means until it is undrained.
+func (d *DomainClient) DrainHost(host string, timeout int64) (model.Domain, error) {
+	return d.domain("DrainHost", http.MethodPost, "/hosts/"+host+"/drain", &model.DrainOptions{Timeout: timeout})
+}
+
+func (d *DomainClient) UndrainHost(host string) (model.Domain, error) {
+	return d.domain("UndrainHost", http.MethodDelete, "/hosts/"+host+"/drain", nil)
+}
+
+func (d *DomainClient) GetCAA() (rs []model.CAARecord, err error) {
+	_, err = d.call(http.MethodGet, "/caa", nil, &rs)
+	return rs, errors.Wrap(err, "GetCAA: failed to execute a request")
+}
+
+// SetCAA replaces the CAA records of the domain.
+func (d *DomainClient) SetCAA(records []model.CAARecord) (rs []model.CAARecord, err error) {
+	_, err = d.call(http.MethodPut, "/caa", &model.CAAOptions{Records: records}, &rs)
+	return rs, errors.Wrap(err, "SetCAA: failed to execute a request")
+}
+
+func (d *DomainClient) DeleteCAA() error {
+	_, err := d.call(http.MethodDelete, "/caa", nil, nil)
+	return errors.Wrap(err, "DeleteCAA: failed to execute a request")
+}
+
+func (d *DomainClient) GetQueryTypes() ([]string, error) {
+	var o model.QueryTypesOptions
+	_, err := d.call(http.MethodGet, "/types", nil, &o)
+	return o.Types, errors.Wrap(err, "GetQueryTypes: failed to execute a request")
+}
+
+// SetQueryTypes restricts the record types the domain and the names below it answer, e.g. TXT
+// for a domain which only serves ACME challenges.
+func (d *DomainClient) SetQueryTypes(types ...string) ([]string, error) {
+	var o model.QueryTypesOptions
+	_, err := d.call(http.MethodPut, "/types", &model.QueryTypesOptions{Types: types}, &o)
+	return o.Types, errors.Wrap(err, "SetQueryTypes: failed to execute a request")
+}
+
+func (d *DomainClient) DeleteQueryTypes() error {
+	_, err := d.call(http.MethodDelete, "/types", nil, nil)
+	return errors.Wrap(err, "DeleteQueryTypes: failed to execute a request")
+}
+
+// Health gets the last results of the health checks of the hosts of the domain.
+func (d *DomainClient) Health() (h model.DomainHealth, err error) {
+	_, err = d.call(http.MethodGet, "/health", nil, &h)
+	return h, errors.Wrap(err, "Health: failed to execute a request")
+}
+
+func (d *DomainClient) ListWebhooks() (hs []model.Webhook, err error) {
+	_, err = d.call(http.MethodGet, "/webhooks", nil, &hs)
+	return hs, errors.Wrap(err, "ListWebhooks: failed to execute a request")
+}
+
+// CreateWebhook subscribes an url to the events of the domain, the events are signed with the
+// secret of the answer which VerifyWebhook checks.
+func (d *DomainClient) CreateWebhook(opts *model.WebhookOptions) (h model.Webhook, err error) {
+	_, err = d.call(http.MethodPost, "/webhooks", opts, &h)
+	return h, errors.Wrap(err, "CreateWebhook: failed to execute a request")
+}
+
+func (d *DomainClient) DeleteWebhook(id string) error {
+	_, err := d.call(http.MethodDelete, "/webhooks/"+id, nil, nil)
+	return errors.Wrap(err, "DeleteWebhook: failed to execute a request")
+}
+
+func (d *DomainClient) ListSchedule() (cs []model.ScheduledChange, err error) {
+	_, err = d.call(http.MethodGet, "/schedule", nil, &cs)
+	return cs, errors.Wrap(err, "ListSchedule: failed to execute a request")
+}
+
+// Schedule adds a change of the hosts or a deletion of the domain which is applied at opts.At.
+func (d *DomainClient) Schedule(opts *model.ScheduleOptions) (c model.ScheduledChange, err error) {
+	_, err = d.call(http.MethodPost, "/schedule", opts, &c)
+	return c, errors.Wrap(err, "Schedule: failed to execute a request")
+}
+
+func (d *DomainClient) CancelSchedule(id string) error {
+	_, err := d.call(http.MethodDelete, "/schedule/"+id, nil, nil)
+	return errors.Wrap(err, "CancelSchedule: failed to execute a request")
+}
+
+func (d *DomainClient) GetStatus() (s model.DomainStatus, err error) {
+	_, err = d.call(http.MethodGet, "/status", nil, &s)
+	return s, errors.Wrap(err, "GetStatus: failed to execute a request")
+}
+
+func (d *DomainClient) SetStatus(status *model.DomainStatus) (s model.DomainStatus, err error) {
+	_, err = d.call(http.MethodPut, "/status", status, &s)
+	return s, errors.Wrap(err, "SetStatus: failed to execute a request")
+}
+
+func (d *DomainClient) DeleteStatus() error {
+	_, err := d.call(http.MethodDelete, "/status", nil, nil)
+	return errors.Wrap(err, "DeleteStatus: failed to execute a request")
+}
@@ -0,0 +1,227 @@
+package approuter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keyringVersion = 1
+	keyLength      = 32
+	saltLength     = 16
+)
+
+// KeyringEntry is the token of one domain, Server is the base url of the rdns api
+// (e.g. https://api.lb.rancher.cloud/v1) the domain was registered with.
+type KeyringEntry struct {
+	Fqdn       string     `json:"fqdn"`
+	Token      string     `json:"token"`
+	Server     string     `json:"server,omitempty"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+// Keyring keeps the tokens of several domains in a local file which is encrypted
+// with a key derived from a passphrase.
+type Keyring struct {
+	path       string
+	passphrase string
+	entries    map[string]KeyringEntry
+}
+
+type keyringFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// OpenKeyring decrypts the keyring file, an empty keyring is returned when the file
+// does not exist yet.
+func OpenKeyring(path, passphrase string) (*Keyring, error) {
+	if passphrase == "" {
+		return nil, errors.New("keyring passphrase can not be empty")
+	}
+
+	k := &Keyring{
+		path:       path,
+		passphrase: passphrase,
+		entries:    make(map[string]KeyringEntry),
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read keyring %s", path)
+	}
+
+	var f keyringFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode keyring %s", path)
+	}
+	if f.Version != keyringVersion {
+		return nil, errors.Errorf("unsupported keyring version %d of %s", f.Version, path)
+	}
+
+	gcm, err := keyringCipher(passphrase, f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, errors.Errorf("failed to decrypt keyring %s, wrong passphrase?", path)
+	}
+
+	if err := json.Unmarshal(data, &k.entries); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode keyring %s", path)
+	}
+
+	return k, nil
+}
+
+// Save encrypts the keyring with a new salt and replaces the file in place.
+func (k *Keyring) Save() error {
+	data, err := json.Marshal(k.entries)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode keyring")
+	}
+
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.Wrap(err, "failed to generate keyring salt")
+	}
+	gcm, err := keyringCipher(k.passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "failed to generate keyring nonce")
+	}
+
+	b, err := json.Marshal(&keyringFile{
+		Version: keyringVersion,
+		Salt:    salt,
+		Nonce:   nonce,
+		Data:    gcm.Seal(nil, nonce, data, nil),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode keyring")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return errors.Wrapf(err, "failed to write keyring %s", k.path)
+	}
+	tmp := k.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return errors.Wrapf(err, "failed to write keyring %s", k.path)
+	}
+
+	return errors.Wrapf(os.Rename(tmp, k.path), "failed to write keyring %s", k.path)
+}
+
+func (k *Keyring) Get(fqdn string) (KeyringEntry, bool) {
+	e, ok := k.entries[fqdn]
+	return e, ok
+}
+
+func (k *Keyring) Set(e KeyringEntry) {
+	k.entries[e.Fqdn] = e
+}
+
+func (k *Keyring) Remove(fqdn string) bool {
+	_, ok := k.entries[fqdn]
+	delete(k.entries, fqdn)
+	return ok
+}
+
+// List returns the entries sorted by fqdn.
+func (k *Keyring) List() []KeyringEntry {
+	result := make([]KeyringEntry, 0, len(k.entries))
+	for _, e := range k.entries {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Fqdn < result[j].Fqdn
+	})
+	return result
+}
+
+// Export writes the entries as plain JSON, e.g. to move them to another keyring.
+func (k *Keyring) Export(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return errors.Wrap(e.Encode(k.List()), "failed to export keyring")
+}
+
+// Import merges the entries written by Export, imported entries replace the entries
+// of the same fqdn. It returns the number of imported entries.
+func (k *Keyring) Import(r io.Reader) (int, error) {
+	var entries []KeyringEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return 0, errors.Wrap(err, "failed to import keyring")
+	}
+
+	n := 0
+	for _, e := range entries {
+		if e.Fqdn == "" || e.Token == "" {
+			continue
+		}
+		k.Set(e)
+		n++
+	}
+
+	return n, nil
+}
+
+// RenewAll renews every domain of the keyring and keeps the new expirations, servers of
+// entries without one default to base. It returns the errors of the domains which failed.
+func (k *Keyring) RenewAll(base string) map[string]error {
+	failed := make(map[string]error)
+
+	for _, e := range k.List() {
+		server := e.Server
+		if server == "" {
+			server = base
+		}
+
+		d, err := NewTokenClient(server).RenewDomainWithToken(e.Fqdn, e.Token)
+		if err != nil {
+			failed[e.Fqdn] = err
+			continue
+		}
+
+		e.Server = server
+		e.Expiration = d.Expiration
+		k.Set(e)
+	}
+
+	return failed
+}
+
+// Used to derive the keyring key from the passphrase
+func keyringCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, keyLength)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive keyring key")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create keyring cipher")
+	}
+
+	return cipher.NewGCM(block)
+}
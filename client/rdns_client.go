@@ -277,6 +277,166 @@ func (c *Client) RenewDomain() (string, error) {
 	return fqdn, err
 }
 
+// RenewDomainWithToken renews a domain with the given token instead of the stored secret.
+func (c *Client) RenewDomainWithToken(fqdn, token string) (d model.Domain, err error) {
+	url := buildURL(c.base, "/"+fqdn, "/renew")
+	req, err := c.request(http.MethodPut, url, nil)
+	if err != nil {
+		return d, errors.Wrap(err, "RenewDomainWithToken: failed to build a request")
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	o, err := c.do(req)
+	if err != nil {
+		return d, errors.Wrap(err, "RenewDomainWithToken: failed to execute a request")
+	}
+	if o.Status != http.StatusOK {
+		return d, errors.Errorf("RenewDomainWithToken: got request status %d", o.Status)
+	}
+
+	return o.Data, nil
+}
+
+// GetDomainWithToken gets a domain with the given token, nil is returned when it does not exist.
+func (c *Client) GetDomainWithToken(fqdn, token string) (*model.Domain, error) {
+	req, err := c.request(http.MethodGet, buildURL(c.base, "/"+fqdn, ""), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetDomainWithToken: failed to build a request")
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	o, err := c.do(req)
+	if o.Status == http.StatusForbidden {
+		// the token is not accepted any more once the domain expires
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "GetDomainWithToken: failed to execute a request")
+	}
+	if o.Data.Fqdn == "" {
+		return nil, nil
+	}
+
+	return &o.Data, nil
+}
+
+// UpdateDomainWithToken replaces the hosts and sub domains of a domain with the given token.
+func (c *Client) UpdateDomainWithToken(fqdn, token string, hosts []string, subDomain map[string][]string) error {
+	body, err := jsonBody(&model.DomainOptions{Hosts: hosts, SubDomain: subDomain})
+	if err != nil {
+		return err
+	}
+
+	req, err := c.request(http.MethodPut, buildURL(c.base, "/"+fqdn, ""), body)
+	if err != nil {
+		return errors.Wrap(err, "UpdateDomainWithToken: failed to build a request")
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	o, err := c.do(req)
+	if err != nil {
+		return errors.Wrap(err, "UpdateDomainWithToken: failed to execute a request")
+	}
+	if o.Status != http.StatusOK {
+		return errors.Errorf("UpdateDomainWithToken: got request status %d", o.Status)
+	}
+
+	return nil
+}
+
+// CreateDomainWithHosts creates a domain without storing its token in a secret,
+// the token is returned with the domain.
+func (c *Client) CreateDomainWithHosts(hosts []string) (d model.Domain, token string, err error) {
+	body, err := jsonBody(&model.DomainOptions{Hosts: hosts})
+	if err != nil {
+		return d, "", err
+	}
+
+	req, err := c.request(http.MethodPost, buildURL(c.base, "", ""), body)
+	if err != nil {
+		return d, "", errors.Wrap(err, "CreateDomainWithHosts: failed to build a request")
+	}
+
+	o, err := c.do(req)
+	if err != nil {
+		return d, "", errors.Wrap(err, "CreateDomainWithHosts: failed to execute a request")
+	}
+	if o.Status != http.StatusOK {
+		return d, "", errors.Errorf("CreateDomainWithHosts: got request status %d", o.Status)
+	}
+
+	return o.Data, o.Token, nil
+}
+
+// DeleteDomainWithToken deletes a domain with the given token.
+func (c *Client) DeleteDomainWithToken(fqdn, token string) error {
+	req, err := c.request(http.MethodDelete, buildURL(c.base, "/"+fqdn, ""), nil)
+	if err != nil {
+		return errors.Wrap(err, "DeleteDomainWithToken: failed to build a request")
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	o, err := c.do(req)
+	if err != nil {
+		return errors.Wrap(err, "DeleteDomainWithToken: failed to execute a request")
+	}
+	if o.Status != http.StatusOK {
+		return errors.Errorf("DeleteDomainWithToken: got request status %d", o.Status)
+	}
+
+	return nil
+}
+
+// SetTextWithToken sets the TXT record of a name under a domain with the token of the domain,
+// e.g. _acme-challenge.<fqdn>.
+func (c *Client) SetTextWithToken(fqdn, token, text string) (d model.Domain, err error) {
+	body, err := jsonBody(&model.DomainOptions{Text: text})
+	if err != nil {
+		return d, err
+	}
+
+	req, err := c.request(http.MethodPost, buildURL(c.base, "/"+fqdn, "/txt"), body)
+	if err != nil {
+		return d, errors.Wrap(err, "SetTextWithToken: failed to build a request")
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	o, err := c.do(req)
+	if err != nil {
+		return d, errors.Wrap(err, "SetTextWithToken: failed to execute a request")
+	}
+	if o.Status != http.StatusOK {
+		return d, errors.Errorf("SetTextWithToken: got request status %d", o.Status)
+	}
+
+	return o.Data, nil
+}
+
+// DeleteTextWithToken deletes the TXT record of a name under a domain with the token of the domain.
+func (c *Client) DeleteTextWithToken(fqdn, token string) error {
+	req, err := c.request(http.MethodDelete, buildURL(c.base, "/"+fqdn, "/txt"), nil)
+	if err != nil {
+		return errors.Wrap(err, "DeleteTextWithToken: failed to build a request")
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	o, err := c.do(req)
+	if err != nil {
+		return errors.Wrap(err, "DeleteTextWithToken: failed to execute a request")
+	}
+	if o.Status != http.StatusOK {
+		return errors.Errorf("DeleteTextWithToken: got request status %d", o.Status)
+	}
+
+	return nil
+}
+
 func (c *Client) SetBaseURL(base string) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
@@ -332,6 +492,16 @@ func NewClient(secrets SecretCreator, secretLister SecretLister, clusterName str
 	}
 }
 
+// NewTokenClient returns a client which is used with tokens of a keyring,
+// it has no secret store so only the *WithToken and *WithHosts methods can be used.
+func NewTokenClient(base string) *Client {
+	return &Client{
+		httpClient: http.DefaultClient,
+		base:       base,
+		lock:       &sync.RWMutex{},
+	}
+}
+
 //buildUrl return request url
 func buildURL(base, fqdn, path string) (url string) {
 	return fmt.Sprintf("%s/domain%s%s", base, fqdn, path)
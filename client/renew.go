@@ -0,0 +1,63 @@
+package approuter
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// a domain is renewed when half of its lease is left, none of the renewals are closer than
+	// minRenewInterval so a domain of a short lease does not flood the server
+	minRenewInterval = 10 * time.Second
+	// the interval of a domain whose answer has no expiration and the retry of a failed renewal
+	defaultRenewInterval = time.Hour
+	renewRetryInterval   = 30 * time.Second
+)
+
+// KeepRenewed renews the domain until done is closed, each renewal is handed to onRenew which may be nil.
+// The loop stops by itself when the domain is gone or its token is not valid anymore, the other errors are retried.
+func (d *DomainClient) KeepRenewed(done <-chan struct{}, onRenew func(model.Domain, error)) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+
+		r, err := d.Renew()
+		if onRenew != nil {
+			onRenew(r, err)
+		}
+		if err != nil {
+			switch StatusOf(err) {
+			case http.StatusForbidden, http.StatusNotFound:
+				logrus.Errorf("stop renewing domain %s: %v", d.fqdn, err)
+				return
+			}
+			logrus.Warnf("failed to renew domain %s, retry in %s: %v", d.fqdn, renewRetryInterval, err)
+			timer.Reset(renewRetryInterval)
+			continue
+		}
+
+		timer.Reset(renewInterval(r.Expiration, time.Now()))
+	}
+}
+
+// Used to get the time to the next renewal of a domain which expires at the expiration
+func renewInterval(expiration *time.Time, now time.Time) time.Duration {
+	if expiration == nil {
+		return defaultRenewInterval
+	}
+	interval := expiration.Sub(now) / 2
+	if interval < minRenewInterval {
+		return minRenewInterval
+	}
+	return interval
+}
@@ -0,0 +1,100 @@
+package approuter
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultWebhookTolerance is how far the timestamp of a delivery may be from the clock of the receiver.
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// WebhookVerifier checks the deliveries of a webhook, a delivery is accepted when its signature
+// matches the secret, its timestamp is within the tolerance and its delivery id was not seen before.
+type WebhookVerifier struct {
+	Secret    string
+	Tolerance time.Duration
+
+	mu   sync.Mutex
+	seen map[uint64]time.Time
+}
+
+func NewWebhookVerifier(secret string, tolerance time.Duration) *WebhookVerifier {
+	if tolerance <= 0 {
+		tolerance = DefaultWebhookTolerance
+	}
+	return &WebhookVerifier{
+		Secret:    secret,
+		Tolerance: tolerance,
+		seen:      make(map[uint64]time.Time),
+	}
+}
+
+// Verify reads and checks a delivery, it returns the event of an accepted delivery.
+func (v *WebhookVerifier) Verify(r *http.Request) (*model.WebhookEvent, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read webhook delivery")
+	}
+
+	now := time.Now()
+	id, err := VerifyWebhook(v.Secret, r.Header, body, v.Tolerance, now)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	// ids older than twice the tolerance are rejected by their timestamp already
+	for k, t := range v.seen {
+		if now.Sub(t) > 2*v.Tolerance {
+			delete(v.seen, k)
+		}
+	}
+	if _, ok := v.seen[id]; ok {
+		v.mu.Unlock()
+		return nil, errors.Errorf("webhook delivery %d was received before", id)
+	}
+	v.seen[id] = now
+	v.mu.Unlock()
+
+	var e model.WebhookEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return nil, errors.Wrap(err, "failed to decode webhook event")
+	}
+
+	return &e, nil
+}
+
+// VerifyWebhook checks the signature and the timestamp of a delivery and returns its delivery id,
+// receivers which do not use a WebhookVerifier must reject delivery ids they have seen themselves.
+func VerifyWebhook(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) (uint64, error) {
+	id, err := strconv.ParseUint(header.Get(model.HeaderWebhookDelivery), 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid webhook delivery id %q", header.Get(model.HeaderWebhookDelivery))
+	}
+
+	timestamp, err := strconv.ParseInt(header.Get(model.HeaderWebhookTimestamp), 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid webhook timestamp %q", header.Get(model.HeaderWebhookTimestamp))
+	}
+
+	expected := model.WebhookSignature(secret, timestamp, id, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(model.HeaderWebhookSignature))) {
+		return 0, errors.Errorf("signature of webhook delivery %d does not match", id)
+	}
+
+	skew := now.Sub(time.Unix(timestamp, 0))
+	if skew > tolerance || skew < -tolerance {
+		return 0, errors.Errorf("timestamp of webhook delivery %d is not within %s", id, tolerance)
+	}
+
+	return id, nil
+}
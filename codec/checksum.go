@@ -0,0 +1,44 @@
+package codec
+
+import (
+	"hash/crc32"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+	// ErrNoChecksum is returned by Verify for the values which are written without a checksum, e.g.
+	// by an earlier version or by hand.
+	ErrNoChecksum = errors.New("record has no checksum")
+)
+
+// Checksum gets the checksum of the canonical form of the record, the fields in the order of the
+// protobuf message separated by NUL, so the checksum is the same whatever codec wrote the value.
+// e.g. {host: 1.1.1.1, ttl: 60} => crc32c("1.1.1.1\x00\x0060\x000\x000")
+func (r *Record) Checksum() uint32 {
+	b := make([]byte, 0, len(r.Host)+len(r.Text)+32)
+	b = append(b, r.Host...)
+	b = append(b, 0)
+	b = append(b, r.Text...)
+	b = append(b, 0)
+	b = strconv.AppendUint(b, uint64(r.TTL), 10)
+	b = append(b, 0)
+	b = strconv.AppendUint(b, uint64(r.Weight), 10)
+	b = append(b, 0)
+	b = strconv.AppendInt(b, r.Drained, 10)
+	return crc32.Checksum(b, castagnoli)
+}
+
+// Verify checks that the decoded record matches the checksum it was written with.
+func (r *Record) Verify() error {
+	if r.Sum == 0 {
+		return ErrNoChecksum
+	}
+	if sum := r.Checksum(); sum != r.Sum {
+		return errors.Errorf(errChecksum, r.Sum, sum)
+	}
+	return nil
+}
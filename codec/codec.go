@@ -0,0 +1,69 @@
+package codec
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	// JSON is the legacy value encoding, e.g. {"host":"1.1.1.1"}
+	JSON = "json"
+	// Protobuf is the compact binary value encoding
+	Protobuf = "protobuf"
+)
+
+var codecs = map[string]Codec{
+	JSON:     &jsonCodec{},
+	Protobuf: &protobufCodec{},
+}
+
+// Record is the value stored for every backend key.
+// A record holds either a host (A record) or a text (TXT record),
+// a zero TTL is answered with the default TTL of the dns server and
+// the weight is only used by the weighted answer policy of the dns plugin.
+// The dns plugin leaves a host out of its answers until the unix time it is drained until.
+// Sum is the checksum of the other fields, the codecs write it so a value which is changed
+// outside of the backend can be told, values written by earlier versions have none.
+// TXT marks a TXT record, the codecs write its text even when it is empty, so an empty text
+// is still told from a host by the presence of the text like the values of earlier versions.
+type Record struct {
+	Host    string `json:"host,omitempty"`
+	Text    string `json:"text,omitempty"`
+	TTL     uint32 `json:"ttl,omitempty"`
+	Weight  uint32 `json:"weight,omitempty"`
+	Drained int64  `json:"drained,omitempty"`
+	Sum     uint32 `json:"sum,omitempty"`
+	TXT     bool   `json:"-"`
+}
+
+// Codec encodes records before they are written to the backend.
+type Codec interface {
+	Name() string
+	Encode(r *Record) ([]byte, error)
+	Decode(b []byte) (*Record, error)
+}
+
+// Get returns the codec registered with the name.
+func Get(name string) (Codec, error) {
+	c, ok := codecs[name]
+	if !ok {
+		return nil, errors.Errorf(errUnknownCodec, name)
+	}
+	return c, nil
+}
+
+// Decode decodes a stored value whatever codec it was written with,
+// legacy JSON values always start with '{' which is never a valid protobuf tag.
+func Decode(b []byte) (*Record, error) {
+	if len(b) == 0 {
+		return &Record{}, nil
+	}
+	if IsJSON(b) {
+		return codecs[JSON].Decode(b)
+	}
+	return codecs[Protobuf].Decode(b)
+}
+
+// IsJSON reports whether the value was written by the JSON codec.
+func IsJSON(b []byte) bool {
+	return len(b) > 0 && b[0] == '{'
+}
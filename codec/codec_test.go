@@ -0,0 +1,77 @@
+package codec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	records := []Record{
+		{Host: "1.1.1.1"},
+		{Host: "1.1.1.1", TTL: 60, Weight: 3, Drained: 1791936000},
+		{Text: "challenge", TXT: true},
+		// an empty text is still a TXT record and never a host
+		{TXT: true},
+		{Text: "challenge", TXT: true, TTL: 60},
+		{},
+	}
+
+	for _, name := range []string{JSON, Protobuf} {
+		c, err := Get(name)
+		if err != nil {
+			t.Fatalf("failed to get codec %s: %v", name, err)
+		}
+		for _, r := range records {
+			b, err := c.Encode(&r)
+			if err != nil {
+				t.Fatalf("%s: failed to encode %+v: %v", name, r, err)
+			}
+			got, err := Decode(b)
+			if err != nil {
+				t.Fatalf("%s: failed to decode %+v: %v", name, r, err)
+			}
+
+			want := r
+			want.Sum = r.Checksum()
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("%s: expected %+v after the round trip, got %+v", name, want, *got)
+			}
+			if err := got.Verify(); err != nil {
+				t.Errorf("%s: failed to verify %+v: %v", name, *got, err)
+			}
+		}
+	}
+}
+
+func TestDecodeLegacyJSON(t *testing.T) {
+	for value, want := range map[string]Record{
+		`{"host":"1.1.1.1"}`: {Host: "1.1.1.1"},
+		`{"host":""}`:        {},
+		`{"text":"value"}`:   {Text: "value", TXT: true},
+		`{"text":""}`:        {TXT: true},
+	} {
+		got, err := Decode([]byte(value))
+		if err != nil {
+			t.Fatalf("failed to decode %s: %v", value, err)
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("expected %+v of %s, got %+v", want, value, *got)
+		}
+	}
+}
+
+func TestDecodeCorruptProtobuf(t *testing.T) {
+	for _, b := range [][]byte{
+		// a text whose length is 2^63 or more, it must not be sliced
+		{0x0a, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		// a text which is longer than the value
+		{0x12, 0x05, 'a', 'b'},
+		// a length which is cut off
+		{0x12, 0xff},
+	} {
+		if _, err := Decode(b); err == nil || !strings.Contains(err.Error(), "truncated") {
+			t.Errorf("expected %x to be refused as truncated, got %v", b, err)
+		}
+	}
+}
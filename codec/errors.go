@@ -0,0 +1,9 @@
+package codec
+
+const (
+	errChecksum        = "checksum %08x of the record does not match its value, expected %08x"
+	errDecodeValue     = "failed to decode %s value"
+	errTruncatedField  = "truncated protobuf field %d"
+	errUnknownCodec    = "unknown value encoding: %s"
+	errUnknownWireType = "unknown protobuf wire type %d for field %d"
+)
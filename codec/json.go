@@ -0,0 +1,42 @@
+package codec
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+type jsonCodec struct{}
+
+func (c *jsonCodec) Name() string {
+	return JSON
+}
+
+// jsonRecord is a record whose text is written when it is present rather than when it is not empty
+type jsonRecord struct {
+	*Record
+	Text *string `json:"text,omitempty"`
+}
+
+func (c *jsonCodec) Encode(r *Record) ([]byte, error) {
+	sealed := *r
+	sealed.Sum = r.Checksum()
+
+	v := jsonRecord{Record: &sealed}
+	if sealed.TXT || sealed.Text != "" {
+		v.Text = &sealed.Text
+	}
+	return json.Marshal(&v)
+}
+
+func (c *jsonCodec) Decode(b []byte) (*Record, error) {
+	r := &Record{}
+	v := jsonRecord{Record: r}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, errors.Wrapf(err, errDecodeValue, JSON)
+	}
+	if v.Text != nil {
+		r.Text, r.TXT = *v.Text, true
+	}
+	return r, nil
+}
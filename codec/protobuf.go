@@ -0,0 +1,161 @@
+package codec
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// Field numbers of the record message:
+//
+//	message Record {
+//	  string host = 1;
+//	  string text = 2;
+//	  uint32 ttl = 3;
+//	  uint32 weight = 4;
+//	  int64 drained = 5;
+//	  uint32 sum = 6;
+//	}
+const (
+	fieldHost    = 1
+	fieldText    = 2
+	fieldTTL     = 3
+	fieldWeight  = 4
+	fieldDrained = 5
+	fieldSum     = 6
+
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+type protobufCodec struct{}
+
+func (c *protobufCodec) Name() string {
+	return Protobuf
+}
+
+func (c *protobufCodec) Encode(r *Record) ([]byte, error) {
+	buf := proto.NewBuffer(nil)
+	if err := encodeString(buf, fieldHost, r.Host); err != nil {
+		return nil, err
+	}
+	// the text of a TXT record is written even when it is empty, its presence tells the record from a host
+	if r.TXT || r.Text != "" {
+		if err := encodeBytes(buf, fieldText, r.Text); err != nil {
+			return nil, err
+		}
+	}
+	if err := encodeUint32(buf, fieldTTL, r.TTL); err != nil {
+		return nil, err
+	}
+	if err := encodeUint32(buf, fieldWeight, r.Weight); err != nil {
+		return nil, err
+	}
+	if err := encodeUint64(buf, fieldDrained, uint64(r.Drained)); err != nil {
+		return nil, err
+	}
+	if err := encodeUint32(buf, fieldSum, r.Checksum()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *protobufCodec) Decode(b []byte) (*Record, error) {
+	r := &Record{}
+	for i := 0; i < len(b); {
+		key, n := proto.DecodeVarint(b[i:])
+		if n == 0 {
+			return nil, errors.Errorf(errDecodeValue, Protobuf)
+		}
+		i += n
+
+		field, wire := int(key>>3), int(key&7)
+		size, err := fieldSize(b[i:], field, wire)
+		if err != nil {
+			return nil, errors.Wrapf(err, errDecodeValue, Protobuf)
+		}
+
+		// unknown fields are skipped, they are written by a newer version
+		if wire == wireVarint {
+			v, _ := proto.DecodeVarint(b[i:])
+			switch field {
+			case fieldTTL:
+				r.TTL = uint32(v)
+			case fieldWeight:
+				r.Weight = uint32(v)
+			case fieldDrained:
+				r.Drained = int64(v)
+			case fieldSum:
+				r.Sum = uint32(v)
+			}
+		}
+		if wire == wireBytes {
+			l, n := proto.DecodeVarint(b[i:])
+			value := string(b[i+n : i+n+int(l)])
+			switch field {
+			case fieldHost:
+				r.Host = value
+			case fieldText:
+				r.Text, r.TXT = value, true
+			}
+		}
+		i += size
+	}
+	return r, nil
+}
+
+func encodeString(buf *proto.Buffer, field int, s string) error {
+	// proto3 semantics, empty strings are not written
+	if s == "" {
+		return nil
+	}
+	return encodeBytes(buf, field, s)
+}
+
+func encodeBytes(buf *proto.Buffer, field int, s string) error {
+	if err := buf.EncodeVarint(uint64(field<<3 | wireBytes)); err != nil {
+		return err
+	}
+	return buf.EncodeStringBytes(s)
+}
+
+func encodeUint32(buf *proto.Buffer, field int, v uint32) error {
+	return encodeUint64(buf, field, uint64(v))
+}
+
+func encodeUint64(buf *proto.Buffer, field int, v uint64) error {
+	// proto3 semantics, zero values are not written
+	if v == 0 {
+		return nil
+	}
+	if err := buf.EncodeVarint(uint64(field<<3 | wireVarint)); err != nil {
+		return err
+	}
+	return buf.EncodeVarint(v)
+}
+
+// Used to get the encoded size of a field value
+func fieldSize(b []byte, field, wire int) (int, error) {
+	size := 0
+	switch wire {
+	case wireVarint:
+		_, size = proto.DecodeVarint(b)
+	case wireFixed64:
+		size = 8
+	case wireBytes:
+		l, n := proto.DecodeVarint(b)
+		// the length is checked before it is converted, a huge length would be negative as an int
+		if n > 0 && l <= uint64(len(b)-n) {
+			size = n + int(l)
+		}
+	case wireFixed32:
+		size = 4
+	default:
+		return 0, errors.Errorf(errUnknownWireType, wire, field)
+	}
+	if size == 0 || size > len(b) {
+		return 0, errors.Errorf(errTruncatedField, field)
+	}
+	return size, nil
+}
@@ -0,0 +1,321 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	approuter "github.com/rancher/rdns-server/client"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	Name        = "agent"
+	serviceName = "rdns-agent"
+)
+
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "server",
+			EnvVar: "RDNS_SERVER",
+			Usage:  "used to set the base url of the rdns api (e.g. https://api.lb.rancher.cloud/v1).",
+		},
+		cli.StringFlag{
+			Name:   "hosts",
+			EnvVar: "RDNS_AGENT_HOSTS",
+			Usage:  "used to set the comma separated host ips of the node, the ip which reaches the server is used when empty.",
+		},
+		cli.StringFlag{
+			Name:   "interval",
+			EnvVar: "RDNS_AGENT_INTERVAL",
+			Usage:  "used to set the interval the domain is synced and renewed.",
+			Value:  "1h",
+		},
+		cli.StringFlag{
+			Name:   "keyring_file",
+			EnvVar: "RDNS_AGENT_KEYRING_FILE",
+			Usage:  "used to set the keyring file the token of the node is kept in.",
+			Value:  defaultKeyringFile(),
+		},
+		cli.StringFlag{
+			Name:   "keyring_passphrase",
+			EnvVar: "RDNS_KEYRING_PASSPHRASE",
+			Usage:  "used to set the passphrase the keyring is encrypted with.",
+		},
+	}
+}
+
+func Commands() []cli.Command {
+	return []cli.Command{
+		{
+			Name:   "install",
+			Usage:  "install the agent as a service, a systemd unit on linux and a windows service on windows",
+			Action: InstallAction,
+		},
+		{
+			Name:   "uninstall",
+			Usage:  "uninstall the agent service",
+			Action: UninstallAction,
+		},
+		{
+			Name:  "login",
+			Usage: "get the token of an existing domain for this node, the owner approves it from a browser",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "fqdn", Usage: "used to set the domain."},
+			},
+			Action: LoginAction,
+		},
+	}
+}
+
+// Action registers the node with the hosts of the node and keeps its domain renewed,
+// it runs under the service manager when it is started by one.
+func Action(c *cli.Context) error {
+	a, err := newAgent(c)
+	if err != nil {
+		return err
+	}
+
+	return runService(a.run)
+}
+
+func InstallAction(c *cli.Context) error {
+	if _, err := newAgent(c); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "failed to find the agent executable")
+	}
+
+	// flags are passed to the service as its environments
+	environments := make(map[string]string)
+	for _, f := range Flags() {
+		if sf, ok := f.(cli.StringFlag); ok {
+			environments[sf.EnvVar] = flag(c, sf.Name)
+		}
+	}
+
+	return installService(exe, environments)
+}
+
+func UninstallAction(c *cli.Context) error {
+	return uninstallService()
+}
+
+// LoginAction runs the device flow for an existing domain, the agent keeps that domain
+// instead of registering a new one.
+func LoginAction(c *cli.Context) error {
+	if c.String("fqdn") == "" {
+		return errors.New("expected argument: fqdn")
+	}
+
+	a, err := newAgent(c)
+	if err != nil {
+		return err
+	}
+
+	client := approuter.NewTokenClient(a.server)
+	code, err := client.RequestDeviceCode(c.String("fqdn"))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.App.Writer, "open %s and enter the code %s to approve this node for %s\n", code.VerificationURI, code.UserCode, code.Fqdn)
+
+	token, err := client.PollDeviceToken(code)
+	if err != nil {
+		return err
+	}
+
+	if e, ok := a.entry(); ok {
+		a.keyring.Remove(e.Fqdn)
+	}
+	a.keyring.Set(approuter.KeyringEntry{
+		Fqdn:   code.Fqdn,
+		Token:  token,
+		Server: a.server,
+	})
+	if err := a.keyring.Save(); err != nil {
+		return err
+	}
+
+	logrus.Infof("got the token of %s, the agent keeps it from now on", code.Fqdn)
+	return nil
+}
+
+type agent struct {
+	server   string
+	hosts    []string
+	interval time.Duration
+	keyring  *approuter.Keyring
+}
+
+func newAgent(c *cli.Context) (*agent, error) {
+	if c.GlobalBool("debug") {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	server := flag(c, "server")
+	if server == "" {
+		return nil, errors.New("expected argument: server")
+	}
+
+	interval, err := time.ParseDuration(flag(c, "interval"))
+	if err != nil || interval <= 0 {
+		return nil, errors.Errorf("invalid agent interval: %s", flag(c, "interval"))
+	}
+
+	k, err := approuter.OpenKeyring(flag(c, "keyring_file"), flag(c, "keyring_passphrase"))
+	if err != nil {
+		return nil, err
+	}
+
+	a := &agent{
+		server:   server,
+		interval: interval,
+		keyring:  k,
+	}
+	for _, h := range strings.Split(flag(c, "hosts"), ",") {
+		if h = strings.TrimSpace(h); h == "" {
+			continue
+		}
+		if net.ParseIP(h) == nil {
+			return nil, errors.Errorf("invalid host ip: %s", h)
+		}
+		a.hosts = append(a.hosts, h)
+	}
+
+	return a, nil
+}
+
+func (a *agent) run(done chan struct{}) {
+	logrus.Infof("syncing the domain of this node with %s every %s", a.server, a.interval)
+	wait.Until(func() {
+		if err := a.sync(); err != nil {
+			logrus.Error(err)
+		}
+	}, a.interval, done)
+}
+
+// sync registers the node when it has no domain yet or its domain expired, otherwise the
+// hosts of the domain are updated when they are changed and the domain is renewed.
+func (a *agent) sync() error {
+	hosts, err := a.nodeHosts()
+	if err != nil {
+		return err
+	}
+
+	c := approuter.NewTokenClient(a.server)
+	e, ok := a.entry()
+	if ok {
+		d, err := c.GetDomainWithToken(e.Fqdn, e.Token)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			logrus.Warnf("domain %s expired, register a new domain", e.Fqdn)
+			a.keyring.Remove(e.Fqdn)
+			ok = false
+		} else {
+			current := append([]string{}, d.Hosts...)
+			sort.Strings(current)
+			if !reflect.DeepEqual(current, hosts) {
+				logrus.Infof("update hosts of %s from %v to %v", e.Fqdn, current, hosts)
+				if err := c.UpdateDomainWithToken(e.Fqdn, e.Token, hosts, d.SubDomain); err != nil {
+					return err
+				}
+			}
+			k, err := c.Domain(e.Fqdn, e.Token).Keepalive()
+			if err != nil {
+				return err
+			}
+			e.Expiration = &k.Expiration
+		}
+	}
+
+	if !ok {
+		d, token, err := c.CreateDomainWithHosts(hosts)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("registered domain %s with hosts %v", d.Fqdn, hosts)
+		e = approuter.KeyringEntry{
+			Fqdn:       d.Fqdn,
+			Token:      token,
+			Server:     a.server,
+			Expiration: d.Expiration,
+		}
+	}
+
+	a.keyring.Set(e)
+	return a.keyring.Save()
+}
+
+// Used to get the keyring entry of the node, the agent keeps one domain per server
+func (a *agent) entry() (approuter.KeyringEntry, bool) {
+	for _, e := range a.keyring.List() {
+		if e.Server == a.server {
+			return e, true
+		}
+	}
+	return approuter.KeyringEntry{}, false
+}
+
+// Used to get the sorted hosts of the node, the local ip of a connection to the server
+// is used when no hosts are configured
+// e.g. https://api.lb.rancher.cloud/v1 => 10.0.0.12
+func (a *agent) nodeHosts() ([]string, error) {
+	if len(a.hosts) > 0 {
+		hosts := append([]string{}, a.hosts...)
+		sort.Strings(hosts)
+		return hosts, nil
+	}
+
+	u, err := url.Parse(a.server)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid server url: %s", a.server)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	conn, err := net.Dial("udp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find the host ip which reaches %s", u.Host)
+	}
+	defer conn.Close()
+
+	return []string{conn.LocalAddr().(*net.UDPAddr).IP.String()}, nil
+}
+
+// Used to get a flag of the agent command, subcommands read it from the parent command
+func flag(c *cli.Context, name string) string {
+	if v := c.String(name); v != "" {
+		return v
+	}
+	return c.GlobalString(name)
+}
+
+func defaultKeyringFile() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "rdns", "agent-keyring")
+	}
+	return "/var/lib/rdns/agent-keyring"
+}
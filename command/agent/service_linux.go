@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const unitFile = "/etc/systemd/system/" + serviceName + ".service"
+
+const unitTmpl = `[Unit]
+Description=Rancher DNS agent
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%s agent
+Restart=always
+RestartSec=10
+%s
+[Install]
+WantedBy=multi-user.target
+`
+
+// runService runs the agent until it receives SIGINT or SIGTERM from systemd or the shell.
+func runService(run func(done chan struct{})) error {
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		close(done)
+	}()
+
+	run(done)
+	return nil
+}
+
+// installService writes the systemd unit of the agent, the unit is readable by root only
+// as it holds the keyring passphrase.
+func installService(exe string, environments map[string]string) error {
+	keys := make([]string, 0, len(environments))
+	for k := range environments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var envs strings.Builder
+	for _, k := range keys {
+		if environments[k] != "" {
+			fmt.Fprintf(&envs, "Environment=\"%s=%s\"\n", k, environments[k])
+		}
+	}
+
+	unit := fmt.Sprintf(unitTmpl, exe, envs.String())
+	if err := ioutil.WriteFile(unitFile, []byte(unit), 0600); err != nil {
+		return errors.Wrapf(err, "failed to write systemd unit %s", unitFile)
+	}
+
+	logrus.Infof("wrote %s, run `systemctl daemon-reload && systemctl enable --now %s` to start the agent", unitFile, serviceName)
+	return nil
+}
+
+func uninstallService() error {
+	if err := os.Remove(unitFile); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove systemd unit %s", unitFile)
+	}
+
+	logrus.Infof("removed %s, run `systemctl disable --now %s && systemctl daemon-reload` if it is still running", unitFile, serviceName)
+	return nil
+}
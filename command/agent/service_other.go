@@ -0,0 +1,35 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package agent
+
+import (
+	"os"
+	"os/signal"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// runService runs the agent in the foreground until it is interrupted.
+func runService(run func(done chan struct{})) error {
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		<-signals
+		close(done)
+	}()
+
+	run(done)
+	return nil
+}
+
+func installService(exe string, environments map[string]string) error {
+	return errors.Errorf("agent service is not supported on %s", runtime.GOOS)
+}
+
+func uninstallService() error {
+	return errors.Errorf("agent service is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+type handler struct {
+	run func(done chan struct{})
+}
+
+// Execute runs the agent until the service control manager stops the service.
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		h.run(done)
+		close(stopped)
+	}()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for r := range requests {
+		switch r.Cmd {
+		case svc.Interrogate:
+			status <- r.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			close(done)
+			<-stopped
+			return false, 0
+		}
+	}
+
+	return false, 0
+}
+
+// runService runs the agent as a windows service when it is started by the service
+// control manager, and in the foreground otherwise.
+func runService(run func(done chan struct{})) error {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil {
+		return errors.Wrap(err, "failed to detect the windows session")
+	}
+
+	if !interactive {
+		return svc.Run(serviceName, &handler{run: run})
+	}
+
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		<-signals
+		close(done)
+	}()
+
+	run(done)
+	return nil
+}
+
+// installService registers the agent as an automatic windows service, the flags are kept
+// as the environments of the service in its registry key.
+func installService(exe string, environments map[string]string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the service control manager")
+	}
+	defer m.Disconnect()
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "Rancher DNS agent",
+		Description: "Registers this node with rdns-server and keeps its domain renewed.",
+		StartType:   mgr.StartAutomatic,
+	}, Name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create service %s", serviceName)
+	}
+	defer s.Close()
+
+	keys := make([]string, 0, len(environments))
+	for k, v := range environments {
+		if v != "" {
+			keys = append(keys, k+"="+v)
+		}
+	}
+	sort.Strings(keys)
+
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+serviceName, registry.SET_VALUE)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open the registry key of service %s", serviceName)
+	}
+	defer k.Close()
+
+	if err := k.SetStringsValue("Environment", keys); err != nil {
+		return errors.Wrapf(err, "failed to set the environments of service %s", serviceName)
+	}
+
+	logrus.Infof("installed service %s, run `sc.exe start %s` to start the agent", serviceName, serviceName)
+	return nil
+}
+
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to the service control manager")
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open service %s", serviceName)
+	}
+	defer s.Close()
+
+	if err := s.Delete(); err != nil {
+		return errors.Wrapf(err, "failed to delete service %s", serviceName)
+	}
+
+	logrus.Infof("uninstalled service %s", serviceName)
+	return nil
+}
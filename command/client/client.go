@@ -0,0 +1,241 @@
+package client
+
+import (
+	"time"
+
+	approuter "github.com/rancher/rdns-server/client"
+	"github.com/rancher/rdns-server/command/output"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const Name = "client"
+
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "server",
+			EnvVar: "RDNS_SERVER",
+			Usage:  "used to set the base url of the rdns api.",
+			Value:  "http://127.0.0.1:9333/v1",
+		},
+		cli.StringFlag{
+			Name:   "token",
+			EnvVar: "RDNS_TOKEN",
+			Usage:  "used to set the token of the domain.",
+		},
+		output.Flag(),
+	}
+}
+
+func Commands() []cli.Command {
+	fqdn := cli.StringFlag{Name: "fqdn", Usage: "used to set the domain."}
+	name := cli.StringFlag{Name: "fqdn", Usage: "used to set the name of the TXT record, e.g. _acme-challenge.<domain>."}
+	order := cli.StringFlag{Name: "order", Usage: "used to set the ACME order the text belongs to, empty keeps the text by its content."}
+	text := cli.StringFlag{Name: "text", Usage: "used to set the text."}
+
+	return []cli.Command{
+		{
+			Name:  "create",
+			Usage: "create a domain and print it with its token",
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{Name: "host", Usage: "used to set a host ip of the domain, repeat it for more hosts."},
+				cli.StringFlag{Name: "cname", Usage: "used to set the CNAME target of a CNAME domain instead of hosts."},
+				cli.StringFlag{Name: "name", Usage: "used to set the requested slug of the domain, empty means a random slug."},
+				cli.StringFlag{Name: "root", Usage: "used to set the root domain of the domain, empty means the default root domain."},
+				cli.DurationFlag{Name: "lease", Usage: "used to set how long the domain lives without renewal, 0 means the default lease."},
+				cli.BoolFlag{Name: "normal", Usage: "used to create a domain without the wildcard record."},
+				cli.StringFlag{Name: "api_key", EnvVar: "RDNS_API_KEY", Usage: "used to set the api key of the servers which require one to create domains."},
+			},
+			Action: output.Action(CreateAction),
+		},
+		{
+			Name:   "get",
+			Usage:  "print a domain",
+			Flags:  []cli.Flag{fqdn},
+			Action: output.Action(GetAction),
+		},
+		{
+			Name:   "renew",
+			Usage:  "renew a domain and print it",
+			Flags:  []cli.Flag{fqdn},
+			Action: output.Action(RenewAction),
+		},
+		{
+			Name:   "delete",
+			Usage:  "delete a domain",
+			Flags:  []cli.Flag{fqdn},
+			Action: output.Action(DeleteAction),
+		},
+		{
+			Name:  "txt",
+			Usage: "manage the TXT records of the names under a domain with the token of the domain",
+			Subcommands: []cli.Command{
+				{
+					Name:   "create",
+					Usage:  "create a TXT record",
+					Flags:  []cli.Flag{name, text, order},
+					Action: output.Action(CreateTextAction),
+				},
+				{
+					Name:   "get",
+					Usage:  "print a TXT record, all texts of the name without --order",
+					Flags:  []cli.Flag{name, order},
+					Action: output.Action(GetTextAction),
+				},
+				{
+					Name:   "update",
+					Usage:  "replace the text of a TXT record",
+					Flags:  []cli.Flag{name, text, order},
+					Action: output.Action(UpdateTextAction),
+				},
+				{
+					Name:   "delete",
+					Usage:  "delete a TXT record, all texts of the name without --order or --text",
+					Flags:  []cli.Flag{name, order, text},
+					Action: output.Action(DeleteTextAction),
+				},
+			},
+		},
+	}
+}
+
+// created is printed by create like the answer of the api, the token is only in the answer of the creation
+type created struct {
+	Domain model.Domain `json:"data"`
+	Token  string       `json:"token"`
+}
+
+func CreateAction(c *cli.Context) error {
+	opts := &model.DomainOptions{
+		Hosts:  c.StringSlice("host"),
+		CNAME:  c.String("cname"),
+		Name:   c.String("name"),
+		Root:   c.String("root"),
+		Lease:  int64(c.Duration("lease") / time.Second),
+		Normal: c.Bool("normal"),
+	}
+	if len(opts.Hosts) == 0 && opts.CNAME == "" {
+		return output.Usagef("expected argument: host or cname")
+	}
+
+	dc, d, err := approuter.NewTokenClient(server(c)).RegisterWithAPIKey(opts, c.String("api_key"))
+	if err != nil {
+		return err
+	}
+	return output.Write(c, created{Domain: d, Token: dc.Token()})
+}
+
+func GetAction(c *cli.Context) error {
+	d, err := domain(c)
+	if err != nil {
+		return err
+	}
+	r, err := d.Get()
+	if err != nil {
+		return err
+	}
+	return output.Write(c, r)
+}
+
+func RenewAction(c *cli.Context) error {
+	d, err := domain(c)
+	if err != nil {
+		return err
+	}
+	r, err := d.Renew()
+	if err != nil {
+		return err
+	}
+	return output.Write(c, r)
+}
+
+func DeleteAction(c *cli.Context) error {
+	d, err := domain(c)
+	if err != nil {
+		return err
+	}
+	if err := d.Delete(); err != nil {
+		return err
+	}
+
+	logrus.Infof("deleted %s", d.Fqdn())
+	return nil
+}
+
+func CreateTextAction(c *cli.Context) error {
+	d, err := textDomain(c)
+	if err != nil {
+		return err
+	}
+	r, err := d.SetText(d.Fqdn(), c.String("text"), c.String("order"))
+	if err != nil {
+		return err
+	}
+	return output.Write(c, r)
+}
+
+func GetTextAction(c *cli.Context) error {
+	d, err := domain(c)
+	if err != nil {
+		return err
+	}
+	r, err := d.GetText(d.Fqdn(), c.String("order"))
+	if err != nil {
+		return err
+	}
+	return output.Write(c, r)
+}
+
+func UpdateTextAction(c *cli.Context) error {
+	d, err := textDomain(c)
+	if err != nil {
+		return err
+	}
+	r, err := d.UpdateText(d.Fqdn(), c.String("text"), c.String("order"))
+	if err != nil {
+		return err
+	}
+	return output.Write(c, r)
+}
+
+func DeleteTextAction(c *cli.Context) error {
+	d, err := domain(c)
+	if err != nil {
+		return err
+	}
+	order := c.String("order")
+	if order == "" && c.String("text") != "" {
+		order = model.TextOrder(c.String("text"))
+	}
+	if err := d.DeleteText(d.Fqdn(), order); err != nil {
+		return err
+	}
+
+	logrus.Infof("deleted the TXT record of %s", d.Fqdn())
+	return nil
+}
+
+// Used to get the client of the domain of --fqdn with the token of the client command
+func domain(c *cli.Context) (*approuter.DomainClient, error) {
+	if c.String("fqdn") == "" || c.GlobalString("token") == "" {
+		return nil, output.Usagef("expected argument: fqdn and token")
+	}
+	return approuter.NewTokenClient(server(c)).Domain(c.String("fqdn"), c.GlobalString("token")), nil
+}
+
+func textDomain(c *cli.Context) (*approuter.DomainClient, error) {
+	if c.String("text") == "" {
+		return nil, output.Usagef("expected argument: text")
+	}
+	return domain(c)
+}
+
+func server(c *cli.Context) string {
+	if c.GlobalBool("debug") {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+	return c.GlobalString("server")
+}
@@ -0,0 +1,321 @@
+package dnsbench
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+const (
+	Name = "dnsbench"
+
+	kindExisting = "existing"
+	kindNXDomain = "nxdomain"
+	kindText     = "txt"
+
+	slugLength = 6
+	textPrefix = "_acme-challenge"
+)
+
+var kinds = []string{kindExisting, kindNXDomain, kindText}
+
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "dnsbench_server",
+			EnvVar: "RDNS_DNSBENCH_SERVER",
+			Usage:  "used to set the address of the authoritative server which is queried.",
+			Value:  "127.0.0.1:53",
+		},
+		cli.StringFlag{
+			Name:   "dnsbench_zone",
+			EnvVar: "RDNS_DNSBENCH_ZONE",
+			Usage:  "used to set the zone of the random names which do not exist, e.g. lb.rancher.cloud.",
+		},
+		cli.StringSliceFlag{
+			Name:  "dnsbench_name",
+			Usage: "used to set an existing name which is queried, repeat it for more names.",
+		},
+		cli.StringFlag{
+			Name:  "dnsbench_names_file",
+			Usage: "used to set a file of existing names which are queried, one name per line.",
+		},
+		cli.StringFlag{
+			Name:  "dnsbench_mix",
+			Usage: "used to set the weights of the queries of existing names, names which do not exist and TXT records.",
+			Value: "existing=70,nxdomain=20,txt=10",
+		},
+		cli.StringFlag{
+			Name:  "dnsbench_duration",
+			Usage: "used to set how long the server is queried.",
+			Value: "30s",
+		},
+		cli.IntFlag{
+			Name:  "dnsbench_concurrency",
+			Usage: "used to set the number of queries in flight.",
+			Value: 16,
+		},
+		cli.IntFlag{
+			Name:  "dnsbench_qps",
+			Usage: "used to set the queries sent per second, 0 sends them as fast as they are answered.",
+		},
+		cli.StringFlag{
+			Name:  "dnsbench_timeout",
+			Usage: "used to set the timeout of a query.",
+			Value: "2s",
+		},
+		cli.BoolFlag{
+			Name:  "dnsbench_tcp",
+			Usage: "used to query over tcp instead of udp.",
+		},
+	}
+}
+
+// result is the outcome of one query, rcode is empty when the query was not answered
+type result struct {
+	kind    string
+	rcode   string
+	latency time.Duration
+}
+
+// Action queries the authoritative server with a mix of queries for --dnsbench_duration and reports
+// the qps, the latency and the rcodes by kind of query.
+func Action(c *cli.Context) error {
+	zone := strings.Trim(c.String("dnsbench_zone"), ".")
+	names, err := existingNames(c)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 && zone == "" {
+		return errors.New("expected argument: dnsbench_name, dnsbench_names_file or dnsbench_zone")
+	}
+	if zone == "" {
+		// the zone of the random names is guessed from the first existing name
+		zone = names[0][strings.Index(names[0], ".")+1:]
+	}
+
+	weights, err := parseMix(c.String("dnsbench_mix"), len(names) > 0)
+	if err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(c.String("dnsbench_duration"))
+	if err != nil {
+		return errors.Wrapf(err, "invalid duration %s", c.String("dnsbench_duration"))
+	}
+	timeout, err := time.ParseDuration(c.String("dnsbench_timeout"))
+	if err != nil {
+		return errors.Wrapf(err, "invalid timeout %s", c.String("dnsbench_timeout"))
+	}
+	concurrency := c.Int("dnsbench_concurrency")
+	if concurrency <= 0 {
+		return errors.Errorf("invalid concurrency %d", concurrency)
+	}
+
+	client := &dns.Client{Net: "udp", Timeout: timeout}
+	if c.Bool("dnsbench_tcp") {
+		client.Net = "tcp"
+	}
+
+	// a token is taken before every query when the qps is limited
+	var tokens <-chan time.Time
+	if qps := c.Int("dnsbench_qps"); qps > 0 {
+		t := time.NewTicker(time.Second / time.Duration(qps))
+		defer t.Stop()
+		tokens = t.C
+	}
+
+	server := c.String("dnsbench_server")
+	deadline := time.Now().Add(duration)
+	results := make([][]result, concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)))
+			for time.Now().Before(deadline) {
+				if tokens != nil {
+					<-tokens
+				}
+				kind := pick(r, weights)
+				results[i] = append(results[i], query(client, server, kind, question(r, kind, names, zone)))
+			}
+		}(i)
+	}
+
+	start := time.Now()
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	all := make([]result, 0)
+	for _, rs := range results {
+		all = append(all, rs...)
+	}
+	return report(c, all, elapsed)
+}
+
+func query(client *dns.Client, server, kind string, q dns.Question) result {
+	m := new(dns.Msg)
+	m.SetQuestion(q.Name, q.Qtype)
+
+	start := time.Now()
+	resp, _, err := client.Exchange(m, server)
+	res := result{kind: kind, latency: time.Since(start)}
+	if err == nil && resp != nil {
+		res.rcode = dns.RcodeToString[resp.Rcode]
+	}
+	return res
+}
+
+func question(r *rand.Rand, kind string, names []string, zone string) dns.Question {
+	switch kind {
+	case kindExisting:
+		return dns.Question{Name: dns.Fqdn(names[r.Intn(len(names))]), Qtype: dns.TypeA}
+	case kindText:
+		return dns.Question{Name: dns.Fqdn(textPrefix + "." + names[r.Intn(len(names))]), Qtype: dns.TypeTXT}
+	default:
+		return dns.Question{Name: dns.Fqdn(util.RandStringWithSmall(slugLength) + "." + zone), Qtype: dns.TypeA}
+	}
+}
+
+// e.g. existing=70,nxdomain=20,txt=10, the queries of existing names and TXT records need existing names
+func parseMix(mix string, existing bool) (map[string]int, error) {
+	weights := make(map[string]int)
+	total := 0
+	for _, part := range strings.Split(mix, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid mix %s", part)
+		}
+		w, err := strconv.Atoi(kv[1])
+		if err != nil || w < 0 {
+			return nil, errors.Errorf("invalid weight of %s: %s", kv[0], kv[1])
+		}
+		switch kv[0] {
+		case kindExisting, kindText:
+			if !existing {
+				continue
+			}
+		case kindNXDomain:
+		default:
+			return nil, errors.Errorf("invalid kind %s, expected one of %s", kv[0], strings.Join(kinds, ", "))
+		}
+		weights[kv[0]] = w
+		total += w
+	}
+	if total == 0 {
+		return nil, errors.Errorf("mix %s has no queries to send", mix)
+	}
+	return weights, nil
+}
+
+func pick(r *rand.Rand, weights map[string]int) string {
+	total := 0
+	for _, k := range kinds {
+		total += weights[k]
+	}
+	n := r.Intn(total)
+	for _, k := range kinds {
+		if n < weights[k] {
+			return k
+		}
+		n -= weights[k]
+	}
+	return kindNXDomain
+}
+
+func existingNames(c *cli.Context) ([]string, error) {
+	names := make([]string, 0)
+	for _, n := range c.StringSlice("dnsbench_name") {
+		names = append(names, strings.Trim(n, "."))
+	}
+
+	if f := c.String("dnsbench_names_file"); f != "" {
+		file, err := os.Open(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open names file %s", f)
+		}
+		defer file.Close()
+
+		s := bufio.NewScanner(file)
+		for s.Scan() {
+			if n := strings.Trim(strings.TrimSpace(s.Text()), "."); n != "" && !strings.HasPrefix(n, "#") {
+				names = append(names, n)
+			}
+		}
+		if err := s.Err(); err != nil {
+			return nil, errors.Wrapf(err, "failed to read names file %s", f)
+		}
+	}
+	return names, nil
+}
+
+func report(c *cli.Context, all []result, elapsed time.Duration) error {
+	w := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tQUERIES\tQPS\tP50\tP90\tP99\tMAX\tRCODES")
+
+	byKind := make(map[string][]result)
+	for _, r := range all {
+		byKind[r.kind] = append(byKind[r.kind], r)
+	}
+	for _, k := range kinds {
+		if rs, ok := byKind[k]; ok {
+			writeRow(w, k, rs, elapsed)
+		}
+	}
+	writeRow(w, "total", all, elapsed)
+	return w.Flush()
+}
+
+func writeRow(w *tabwriter.Writer, kind string, rs []result, elapsed time.Duration) {
+	latencies := make([]time.Duration, len(rs))
+	rcodes := make(map[string]int)
+	for i, r := range rs {
+		latencies[i] = r.latency
+		rcode := r.rcode
+		if rcode == "" {
+			rcode = "TIMEOUT"
+		}
+		rcodes[rcode]++
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	codes := make([]string, 0, len(rcodes))
+	for code, n := range rcodes {
+		codes = append(codes, fmt.Sprintf("%s=%d", code, n))
+	}
+	sort.Strings(codes)
+
+	fmt.Fprintf(w, "%s\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\n", kind, len(rs), float64(len(rs))/elapsed.Seconds(),
+		percentile(latencies, 0.5), percentile(latencies, 0.9), percentile(latencies, 0.99),
+		percentile(latencies, 1), strings.Join(codes, " "))
+}
+
+// the latencies are sorted
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(latencies))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(latencies) {
+		i = len(latencies) - 1
+	}
+	return latencies[i].Round(time.Microsecond)
+}
@@ -1,18 +1,23 @@
 package etcdv3
 
 import (
-	"net/http"
+	"io/ioutil"
 	"os"
-	"strconv"
 	"strings"
-	"text/template"
 
 	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/dual"
 	"github.com/rancher/rdns-server/backend/etcdv3"
+	"github.com/rancher/rdns-server/backend/multiroot"
+	"github.com/rancher/rdns-server/command/output"
 	"github.com/rancher/rdns-server/coredns"
-	"github.com/rancher/rdns-server/metric"
-	"github.com/rancher/rdns-server/model"
-	"github.com/rancher/rdns-server/service"
+	"github.com/rancher/rdns-server/importer"
+	"github.com/rancher/rdns-server/lifecycle"
+	"github.com/rancher/rdns-server/migration"
+	"github.com/rancher/rdns-server/migration/etcdv2"
+	"github.com/rancher/rdns-server/server"
+	"github.com/rancher/rdns-server/usage"
+	"github.com/rancher/rdns-server/util"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -20,17 +25,60 @@ import (
 )
 
 var (
+	globalFlags = []string{"ADMIN_TOKEN", "FROZEN", "METRICS_EXPORTER", "METRICS_ENDPOINT", "METRICS_INTERVAL",
+		"SLO_ALERT_WEBHOOK", "SLO_CANARY_RESOLVER", "SLO_CANARY_INTERVAL", "SLO_RESOLVE_THRESHOLD",
+		"DOUBLE_WRITE_BACKEND", "DOUBLE_WRITE_STATE", "ACME_TXT_TTL",
+		"RPZ_ZONE", "RPZ_FILE", "RPZ_INTERVAL", "MIGRATE_DATA",
+		"HEALTH_CHECK_PORT", "HEALTH_CHECK_INTERVAL", "HEALTH_CHECK_TIMEOUT", "HEALTH_CHECK_PARALLEL",
+		"WEBHOOK_URL", "WEBHOOK_SECRET", "WEBHOOK_WORKERS", "WEBHOOK_QUEUE_SIZE", "ADMIN_ROLES_FILE",
+		"EXPIRY_CHECK_INTERVAL", "EXPIRY_WARNING", "EXPIRY_WEBHOOK_URL", "JOB_VISIBILITY_TIMEOUT", "JOB_POLL_INTERVAL", "STATE_SYNC_INTERVAL",
+		"DOMAIN_TTL_MIN", "DOMAIN_TTL_MAX", "DOMAIN_LEASE_MIN", "DOMAIN_LEASE_MAX",
+		"TOKEN_RECOVERY_PORT", "TLS_CERT", "TLS_KEY", "TLS_CLIENT_CA",
+		"RECORD_CHANGE_LIMIT", "RECORD_CHANGE_BURST", "DISABLE_SUBSYSTEMS", "MAX_HOSTS", "REJECT_PRIVATE_HOSTS", "HOST_OWNERSHIP", "HOST_PROOF_SECRET", "TRUSTED_PROXIES",
+		"CREATE_API_KEYS", "CREATE_API_KEYS_FILE",
+		"REPUTATION_PROVIDERS", "REPUTATION_ACTION", "REPUTATION_CIDR_FILE", "REPUTATION_DNSBL", "REPUTATION_API_URL",
+		"CONFIG_FILE", "NODE_SYNC_URL", "NODE_SYNC_TOKEN", "NODE_SYNC_CA_FILE", "NODE_SYNC_INTERVAL", "SCHEDULE_INTERVAL"}
+
+	// optionalFlags may be empty, the option they set is disabled then
+	optionalFlags = map[string]bool{
+		"CORE_DNS_DB_FILE":        true,
+		"CORE_DNS_DB_ZONE":        true,
+		"ETCD_READ_ENDPOINTS":     true,
+		"ETCD_HERITAGE_OWNER":     true,
+		"CORE_DNS_RECURSION_NETS": true,
+		"USAGE_TIERS":             true,
+	}
+
 	flags = map[string]map[string]string{
-		"DOMAIN":           {"used to set etcd root domain.": "lb.rancher.cloud"},
-		"ETCD_ENDPOINTS":   {"used to set etcd endpoints.": "http://127.0.0.1:2379"},
-		"ETCD_PREFIX_PATH": {"used to set etcd prefix path.": "/rdnsv3"},
-		"ETCD_LEASE_TIME":  {"used to set etcd lease time.": "240h"},
-		"CORE_DNS_FILE":    {"used to set coredns file.": "/etc/rdns/config/Corefile"},
-		"CORE_DNS_PORT":    {"used to set coredns port.": "53"},
-		"CORE_DNS_CPU":     {"used to set coredns cpu, a number (e.g. 3) or a percent (e.g. 50%).": "50%"},
-		"CORE_DNS_DB_FILE": {"used to set coredns file plugin db's file name (e.g. /etc/rdns/config/dbfile).": ""},
-		"CORE_DNS_DB_ZONE": {"used to set coredns file plugin db's zone (e.g. api.lb.rancher.cloud).": ""},
-		"TTL":              {"used to set coredns ttl.": "60"},
+		"DOMAIN":                     {"used to set etcd root domains, comma separated, the first one is the default root domain of new domains.": "lb.rancher.cloud"},
+		"ETCD_ENDPOINTS":             {"used to set etcd endpoints.": "http://127.0.0.1:2379"},
+		"ETCD_READ_ENDPOINTS":        {"used to set etcd endpoints which serve the reads of the api and coredns (e.g. the followers), empty reads from etcd endpoints.": ""},
+		"ETCD_PREFIX_PATH":           {"used to set etcd prefix path.": "/rdnsv3"},
+		"ETCD_LEASE_TIME":            {"used to set etcd lease time.": "240h"},
+		"ETCD_VALUE_ENCODING":        {"used to set etcd value encoding, json or protobuf.": "json"},
+		"ETCD_SHARDS":                {"used to set etcd hashed shard count of the key layout, 0 disables sharding.": "0"},
+		"ETCD_HERITAGE_OWNER":        {"used to set the external-dns owner id of the ownership TXT records of the domains, empty writes none.": ""},
+		"CORE_DNS_FILE":              {"used to set coredns file.": "/etc/rdns/config/Corefile"},
+		"CORE_DNS_PORT":              {"used to set coredns port.": "53"},
+		"CORE_DNS_CPU":               {"used to set coredns cpu, a number (e.g. 3) or a percent (e.g. 50%).": "50%"},
+		"CORE_DNS_DB_FILE":           {"used to set coredns file plugin db's file name (e.g. /etc/rdns/config/dbfile).": ""},
+		"CORE_DNS_DB_ZONE":           {"used to set coredns file plugin db's zone (e.g. api.lb.rancher.cloud).": ""},
+		"CORE_DNS_PRELOAD_KEYS":      {"used to set how many records coredns preloads at startup before it is ready, 0 disables it.": "0"},
+		"CORE_DNS_WATCH_CACHE_KEYS":  {"used to set how many lookups coredns caches until etcd watches a change of their records, 0 disables it.": "0"},
+		"CORE_DNS_STALE_DURATION":    {"used to set how long coredns serves the last known records while etcd is unreachable, 0s disables it.": "0s"},
+		"CORE_DNS_ANSWER_POLICY":     {"used to set the order of the answered addresses, round_robin, random, fixed or weighted.": "random"},
+		"CORE_DNS_ANSWER_LIMIT":      {"used to set how many addresses an answer has at most, 0 disables it.": "0"},
+		"CORE_DNS_LIMIT_STRATEGY":    {"used to set which addresses are kept once the answer limit is reached, random or latency.": "random"},
+		"CORE_DNS_WILDCARD_FALLBACK": {"used to set whether coredns answers the names without records with the records of their closest parent.": "false"},
+		"CORE_DNS_MINIMAL_RESPONSES": {"used to set whether coredns omits the additional records of the answers.": "false"},
+		"CORE_DNS_REFUSE_ANY":        {"used to set whether coredns refuses ANY queries of the domain.": "false"},
+		"CORE_DNS_RECURSION_NETS":    {"used to set the networks whose queries outside the domain are forwarded (e.g. 10.0.0.0/8,192.168.0.0/16), empty allows all.": ""},
+		"CORE_DNS_COOKIES":           {"used to set whether coredns answers the DNS cookies of the queries.": "false"},
+		"CORE_DNS_COOKIE_SECRET":     {"used to set the secret of the server cookies, shared by the servers of one address, empty uses a random secret.": ""},
+		"CORE_DNS_PADDING":           {"used to set the block size the answers of the queries which ask for padding are padded to (e.g. 468), 0 disables it.": "0"},
+		"USAGE_TIERS":                {"used to set the queries within a usage window and the lease of the usage tiers (e.g. 100:720h,10000:2160h), empty disables them.": ""},
+		"USAGE_WINDOW":               {"used to set the window the queries of the usage tiers are counted in.": "24h"},
+		"TTL":                        {"used to set coredns ttl.": "60"},
 	}
 )
 
@@ -70,23 +118,184 @@ func Action(c *cli.Context) error {
 		return err
 	}
 
-	done := make(chan struct{})
+	s, err := server.New(&server.Config{
+		Backend: backend.GetBackend(),
+		Listen:  c.GlobalString("listen"),
+		Subsystems: []server.Subsystem{
+			{Name: "usage", Run: lifecycle.Daemon(usage.StartUsageDaemon)},
+			{Name: "coredns", Run: coredns.StartCoreDNSDaemon},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Run(lifecycle.Interrupted())
+}
+
+func ReshardAction(c *cli.Context) error {
+	if err := setEnvironments(c); err != nil {
+		return errors.Wrapf(err, "failed to set environments")
+	}
+
+	b, err := etcdv3.NewBackend()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := b.C.Close(); err != nil {
+			logrus.Fatalf("failed to close etcd-v3 client: %v", err)
+		}
+	}()
+
+	results := make([]resharded, 0)
+	for _, root := range util.RootDomains(os.Getenv("DOMAIN")) {
+		moved, err := b.ForRoot(root).Reshard()
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("moved %d keys of %s to the layout of %d shards", moved, root, b.Shards)
+		results = append(results, resharded{Root: root, Moved: moved, Shards: b.Shards})
+	}
+	if output.Requested(c) {
+		return output.Write(c, results)
+	}
+	return nil
+}
+
+// resharded is printed by the reshard command for every root domain with the output flag
+type resharded struct {
+	Root   string `json:"root"`
+	Moved  int    `json:"moved"`
+	Shards int    `json:"shards"`
+}
 
-	go metric.StartMetricDaemon(done)
+// VerifyFlags are the flags of the etcdv3 command and the seal flag of the verification.
+func VerifyFlags() []cli.Flag {
+	return append(Flags(), cli.BoolFlag{
+		Name:  "seal",
+		Usage: "used to write the checksums of the records which have none, e.g. the ones written by earlier versions.",
+	})
+}
+
+// VerifyAction checks every record of the root domains against its checksum, it fails when a
+// record is corrupt so it can run as a periodic job.
+func VerifyAction(c *cli.Context) error {
+	if err := setEnvironments(c); err != nil {
+		return errors.Wrapf(err, "failed to set environments")
+	}
+
+	b, err := etcdv3.NewBackend()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := b.C.Close(); err != nil {
+			logrus.Fatalf("failed to close etcd-v3 client: %v", err)
+		}
+	}()
+
+	corrupt := 0
+	results := make([]verified, 0)
+	for _, root := range util.RootDomains(os.Getenv("DOMAIN")) {
+		v, err := b.ForRoot(root).Verify(c.Bool("seal"))
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("verified %d records of %s: %d corrupt, %d without checksum, %d sealed", v.Checked, root, len(v.Corrupt), v.Missing, v.Sealed)
+		corrupt += len(v.Corrupt)
+		if v.Corrupt == nil {
+			v.Corrupt = make([]string, 0)
+		}
+		results = append(results, verified{Root: root, Verification: v})
+	}
+	if output.Requested(c) {
+		if err := output.Write(c, results); err != nil {
+			return err
+		}
+	}
+	if corrupt > 0 {
+		return errors.Errorf("%d records do not match their checksums", corrupt)
+	}
+	return nil
+}
+
+// verified is printed by the verify command for every root domain with the output flag
+type verified struct {
+	Root string `json:"root"`
+	etcdv3.Verification
+}
+
+func MigrateDataAction(c *cli.Context) error {
+	if err := setEnvironments(c); err != nil {
+		return errors.Wrapf(err, "failed to set environments")
+	}
 
-	go coredns.StartCoreDNSDaemon()
+	b, err := setBackend()
+	if err != nil {
+		return err
+	}
 
-	go func() {
-		if err := http.ListenAndServe(c.GlobalString("listen"), service.NewRouter()); err != nil {
-			logrus.Error(err)
-			done <- struct{}{}
+	defer func() {
+		if err := b.C.Close(); err != nil {
+			logrus.Fatalf("failed to close etcd-v3 client: %v", err)
 		}
 	}()
 
-	<-done
+	n, err := migration.Run(backend.GetBackend())
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("applied %d data migrations", n)
+	if output.Requested(c) {
+		return output.Write(c, migration.Result{Applied: n})
+	}
 	return nil
 }
 
+func ImportAction(c *cli.Context) error {
+	if err := setEnvironments(c); err != nil {
+		return errors.Wrapf(err, "failed to set environments")
+	}
+
+	b, err := setBackend()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := b.C.Close(); err != nil {
+			logrus.Fatalf("failed to close etcd-v3 client: %v", err)
+		}
+	}()
+
+	return importer.Import(c, backend.GetBackend())
+}
+
+func MigrateV2Action(c *cli.Context) error {
+	if err := setEnvironments(c); err != nil {
+		return errors.Wrapf(err, "failed to set environments")
+	}
+
+	b, err := setBackend()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := b.C.Close(); err != nil {
+			logrus.Fatalf("failed to close etcd-v3 client: %v", err)
+		}
+	}()
+
+	return etcdv2.Migrate(c, backend.GetBackend(), b.LeaseTime)
+}
+
 func setEnvironments(c *cli.Context) error {
 	if c.GlobalBool("debug") {
 		logrus.SetLevel(logrus.DebugLevel)
@@ -97,22 +306,55 @@ func setEnvironments(c *cli.Context) error {
 			return err
 		}
 		if os.Getenv(k) == "" {
-			if k == "CORE_DNS_DB_FILE" || k == "CORE_DNS_DB_ZONE" {
+			if optionalFlags[k] {
 				continue
 			}
-			return errors.Errorf("expected argument: %s", strings.ToLower(k))
+			return output.Usagef("expected argument: %s", strings.ToLower(k))
 		}
 	}
 
-	return os.Setenv("FROZEN", c.GlobalString("frozen"))
+	for _, k := range globalFlags {
+		if err := os.Setenv(k, c.GlobalString(strings.ToLower(k))); err != nil {
+			return err
+		}
+	}
+
+	if util.IsTestMode() {
+		// no real TTLs in test mode, domains and challenge records live until they are deleted
+		if err := os.Setenv("ETCD_LEASE_TIME", util.TestLeaseTime); err != nil {
+			return err
+		}
+		if err := os.Setenv("ACME_TXT_TTL", "0s"); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func setBackend() (*etcdv3.Backend, error) {
-	b, err := etcdv3.NewBackend()
+	o, err := backend.Open(&backend.Config{Name: etcdv3.Name})
+	if err != nil {
+		return nil, err
+	}
+	b := o.(*etcdv3.Backend)
+
+	// every root domain of DOMAIN keeps its records below its own path of the prefix
+	var r backend.Backend = b
+	if roots := util.RootDomains(os.Getenv("DOMAIN")); len(roots) > 1 {
+		r, err = multiroot.NewBackend(roots, func(root string) (backend.Backend, error) {
+			return b.ForRoot(root), nil
+		})
+		if err != nil {
+			return b, err
+		}
+	}
+
+	d, err := dual.Wrap(r)
 	if err != nil {
 		return b, err
 	}
-	backend.SetBackend(b)
+	backend.SetBackend(d)
 
 	return b, nil
 }
@@ -125,22 +367,11 @@ func generateCoreFile() error {
 	_, err := os.Stat(fp)
 	if err != nil {
 		// render CoreFile template
-		cf := &model.CoreFile{
-			CoreDNSDBFile:  os.Getenv("CORE_DNS_DB_FILE"),
-			CoreDNSDBZone:  os.Getenv("CORE_DNS_DB_ZONE"),
-			Domain:         os.Getenv("DOMAIN"),
-			EtcdPrefixPath: os.Getenv("ETCD_PREFIX_PATH"),
-			EtcdEndpoints:  strings.Join(strings.Split(os.Getenv("ETCD_ENDPOINTS"), ","), " "),
-			TTL:            os.Getenv("TTL"),
-			WildCardBound:  strconv.Itoa(len(strings.Split(strings.TrimRight(os.Getenv("DOMAIN"), "."), ".")) + 1),
-		}
-		p := template.Must(template.New("corefile-tmpl").Parse(model.CoreFileTmpl))
-		f, err := os.OpenFile(fp, os.O_WRONLY|os.O_CREATE, os.ModePerm)
+		contents, err := coredns.Render()
 		if err != nil {
 			return err
 		}
-		defer f.Close()
-		if err := p.Execute(f, cf); err != nil {
+		if err := ioutil.WriteFile(fp, contents, os.ModePerm); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,251 @@
+package keyring
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	approuter "github.com/rancher/rdns-server/client"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const Name = "keyring"
+
+func Flags() []cli.Flag {
+	home, _ := os.UserHomeDir()
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "keyring_file",
+			EnvVar: "RDNS_KEYRING_FILE",
+			Usage:  "used to set the keyring file.",
+			Value:  filepath.Join(home, ".rdns", "keyring"),
+		},
+		cli.StringFlag{
+			Name:   "keyring_passphrase",
+			EnvVar: "RDNS_KEYRING_PASSPHRASE",
+			Usage:  "used to set the passphrase the keyring is encrypted with.",
+		},
+		cli.StringFlag{
+			Name:   "server",
+			EnvVar: "RDNS_SERVER",
+			Usage:  "used to set the base url of the rdns api for entries without their own server.",
+			Value:  "http://127.0.0.1:9333/v1",
+		},
+	}
+}
+
+func Commands() []cli.Command {
+	return []cli.Command{
+		{
+			Name:   "list",
+			Usage:  "list the domains of the keyring",
+			Action: ListAction,
+		},
+		{
+			Name:  "add",
+			Usage: "add the token of an existing domain",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "fqdn", Usage: "used to set the domain."},
+				cli.StringFlag{Name: "token", Usage: "used to set the token of the domain."},
+			},
+			Action: AddAction,
+		},
+		{
+			Name:  "create",
+			Usage: "create a domain and add its token",
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{Name: "host", Usage: "used to set a host ip of the domain, repeat it for more hosts."},
+			},
+			Action: CreateAction,
+		},
+		{
+			Name:  "remove",
+			Usage: "remove a domain from the keyring, the domain itself is kept",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "fqdn", Usage: "used to set the domain."},
+			},
+			Action: RemoveAction,
+		},
+		{
+			Name:   "renew",
+			Usage:  "renew all domains of the keyring",
+			Action: RenewAction,
+		},
+		{
+			Name:  "export",
+			Usage: "export the keyring as plain JSON",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file", Usage: "used to set the export file, stdout when empty."},
+			},
+			Action: ExportAction,
+		},
+		{
+			Name:  "import",
+			Usage: "import plain JSON written by export",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file", Usage: "used to set the import file, stdin when empty."},
+			},
+			Action: ImportAction,
+		},
+	}
+}
+
+func ListAction(c *cli.Context) error {
+	k, err := open(c)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FQDN\tSERVER\tEXPIRATION")
+	for _, e := range k.List() {
+		expiration := "-"
+		if e.Expiration != nil {
+			expiration = e.Expiration.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Fqdn, e.Server, expiration)
+	}
+	return w.Flush()
+}
+
+func AddAction(c *cli.Context) error {
+	if c.String("fqdn") == "" || c.String("token") == "" {
+		return errors.New("expected argument: fqdn and token")
+	}
+
+	k, err := open(c)
+	if err != nil {
+		return err
+	}
+
+	k.Set(approuter.KeyringEntry{
+		Fqdn:   c.String("fqdn"),
+		Token:  c.String("token"),
+		Server: c.GlobalString("server"),
+	})
+	return k.Save()
+}
+
+func CreateAction(c *cli.Context) error {
+	if len(c.StringSlice("host")) == 0 {
+		return errors.New("expected argument: host")
+	}
+
+	k, err := open(c)
+	if err != nil {
+		return err
+	}
+
+	server := c.GlobalString("server")
+	d, token, err := approuter.NewTokenClient(server).CreateDomainWithHosts(c.StringSlice("host"))
+	if err != nil {
+		return err
+	}
+
+	k.Set(approuter.KeyringEntry{
+		Fqdn:       d.Fqdn,
+		Token:      token,
+		Server:     server,
+		Expiration: d.Expiration,
+	})
+	if err := k.Save(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.App.Writer, d.Fqdn)
+	return nil
+}
+
+func RemoveAction(c *cli.Context) error {
+	k, err := open(c)
+	if err != nil {
+		return err
+	}
+
+	if !k.Remove(c.String("fqdn")) {
+		return errors.Errorf("domain %s is not in the keyring", c.String("fqdn"))
+	}
+	return k.Save()
+}
+
+func RenewAction(c *cli.Context) error {
+	k, err := open(c)
+	if err != nil {
+		return err
+	}
+
+	failed := k.RenewAll(c.GlobalString("server"))
+	if err := k.Save(); err != nil {
+		return err
+	}
+
+	for fqdn, err := range failed {
+		logrus.Errorf("failed to renew %s: %v", fqdn, err)
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to renew %d of %d domains", len(failed), len(k.List()))
+	}
+
+	logrus.Infof("renewed %d domains", len(k.List()))
+	return nil
+}
+
+func ExportAction(c *cli.Context) error {
+	k, err := open(c)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = c.App.Writer
+	if f := c.String("file"); f != "" {
+		file, err := os.OpenFile(f, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	return k.Export(w)
+}
+
+func ImportAction(c *cli.Context) error {
+	k, err := open(c)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if f := c.String("file"); f != "" {
+		file, err := os.Open(f)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	n, err := k.Import(r)
+	if err != nil {
+		return err
+	}
+	if err := k.Save(); err != nil {
+		return err
+	}
+
+	logrus.Infof("imported %d domains", n)
+	return nil
+}
+
+func open(c *cli.Context) (*approuter.Keyring, error) {
+	if c.GlobalBool("debug") {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+	return approuter.OpenKeyring(c.GlobalString("keyring_file"), c.GlobalString("keyring_passphrase"))
+}
@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"os"
+	"strings"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/dual"
+	"github.com/rancher/rdns-server/backend/memory"
+	"github.com/rancher/rdns-server/backend/multiroot"
+	"github.com/rancher/rdns-server/command/output"
+	"github.com/rancher/rdns-server/lifecycle"
+	"github.com/rancher/rdns-server/server"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var (
+	globalFlags = []string{"ADMIN_TOKEN", "FROZEN", "METRICS_EXPORTER", "METRICS_ENDPOINT", "METRICS_INTERVAL",
+		"SLO_ALERT_WEBHOOK", "SLO_CANARY_RESOLVER", "SLO_CANARY_INTERVAL", "SLO_RESOLVE_THRESHOLD",
+		"DOUBLE_WRITE_BACKEND", "DOUBLE_WRITE_STATE", "ACME_TXT_TTL",
+		"RPZ_ZONE", "RPZ_FILE", "RPZ_INTERVAL", "MIGRATE_DATA",
+		"HEALTH_CHECK_PORT", "HEALTH_CHECK_INTERVAL", "HEALTH_CHECK_TIMEOUT", "HEALTH_CHECK_PARALLEL",
+		"WEBHOOK_URL", "WEBHOOK_SECRET", "WEBHOOK_WORKERS", "WEBHOOK_QUEUE_SIZE", "ADMIN_ROLES_FILE",
+		"EXPIRY_CHECK_INTERVAL", "EXPIRY_WARNING", "EXPIRY_WEBHOOK_URL", "JOB_VISIBILITY_TIMEOUT", "JOB_POLL_INTERVAL", "STATE_SYNC_INTERVAL",
+		"DOMAIN_TTL_MIN", "DOMAIN_TTL_MAX", "DOMAIN_LEASE_MIN", "DOMAIN_LEASE_MAX",
+		"TOKEN_RECOVERY_PORT", "TLS_CERT", "TLS_KEY", "TLS_CLIENT_CA",
+		"RECORD_CHANGE_LIMIT", "RECORD_CHANGE_BURST", "DISABLE_SUBSYSTEMS", "MAX_HOSTS", "REJECT_PRIVATE_HOSTS", "HOST_OWNERSHIP", "HOST_PROOF_SECRET", "TRUSTED_PROXIES",
+		"CREATE_API_KEYS", "CREATE_API_KEYS_FILE",
+		"REPUTATION_PROVIDERS", "REPUTATION_ACTION", "REPUTATION_CIDR_FILE", "REPUTATION_DNSBL", "REPUTATION_API_URL",
+		"CONFIG_FILE", "NODE_SYNC_URL", "NODE_SYNC_TOKEN", "NODE_SYNC_CA_FILE", "NODE_SYNC_INTERVAL", "SCHEDULE_INTERVAL"}
+
+	flags = map[string]map[string]string{
+		"DOMAIN":            {"used to set root domains, comma separated, the first one is the default root domain of new domains.": "lb.rancher.cloud"},
+		"MEMORY_LEASE_TIME": {"used to set memory lease time.": "240h"},
+	}
+)
+
+func Flags() []cli.Flag {
+	fgs := make([]cli.Flag, 0)
+	for key, value := range flags {
+		for k, v := range value {
+			f := cli.StringFlag{
+				Name:   strings.ToLower(key),
+				EnvVar: key,
+				Usage:  k,
+				Value:  v,
+			}
+			fgs = append(fgs, f)
+		}
+	}
+	return fgs
+}
+
+func Action(c *cli.Context) error {
+	if err := setEnvironments(c); err != nil {
+		return errors.Wrapf(err, "failed to set environments")
+	}
+
+	if err := setBackend(); err != nil {
+		return err
+	}
+	logrus.Warn("the domains are kept in memory, they are lost when the server stops")
+
+	s, err := server.New(&server.Config{
+		Backend: backend.GetBackend(),
+		Listen:  c.GlobalString("listen"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Run(lifecycle.Interrupted())
+}
+
+func setEnvironments(c *cli.Context) error {
+	if c.GlobalBool("debug") {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	for k := range flags {
+		if err := os.Setenv(k, c.String(strings.ToLower(k))); err != nil {
+			return err
+		}
+		if os.Getenv(k) == "" {
+			return output.Usagef("expected argument: %s", strings.ToLower(k))
+		}
+	}
+
+	for _, k := range globalFlags {
+		if err := os.Setenv(k, c.GlobalString(strings.ToLower(k))); err != nil {
+			return err
+		}
+	}
+
+	if util.IsTestMode() {
+		// no real TTLs in test mode, domains and challenge records live until they are deleted
+		if err := os.Setenv("MEMORY_LEASE_TIME", util.TestLeaseTime); err != nil {
+			return err
+		}
+		if err := os.Setenv("ACME_TXT_TTL", "0s"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Used to open the backend, the root domains of DOMAIN share the records
+func setBackend() error {
+	o, err := backend.Open(&backend.Config{Name: memory.Name})
+	if err != nil {
+		return err
+	}
+	b := o.(*memory.Backend)
+
+	var r backend.Backend = b
+	if roots := util.RootDomains(os.Getenv("DOMAIN")); len(roots) > 1 {
+		r, err = multiroot.NewBackend(roots, func(root string) (backend.Backend, error) {
+			return b.ForRoot(root), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	d, err := dual.Wrap(r)
+	if err != nil {
+		return err
+	}
+	backend.SetBackend(d)
+
+	return nil
+}
@@ -0,0 +1,306 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	approuter "github.com/rancher/rdns-server/client"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+	FormatTable = "table"
+
+	// ExitUsage is the exit code of a command whose arguments are invalid or are refused by the server
+	ExitUsage = 2
+	// ExitBackend is the exit code of a command which fails at the backend or the server
+	ExitBackend = 3
+	// ExitPartial is the exit code of a command which did some of its work, its result lists the rest
+	ExitPartial = 4
+)
+
+type usageError struct{ error }
+
+type partialError struct{ error }
+
+// Usagef gets an error of the arguments of a command, the command exits with ExitUsage.
+func Usagef(format string, args ...interface{}) error {
+	return usageError{errors.Errorf(format, args...)}
+}
+
+// Partialf gets an error of a command which did some of its work, the command exits with ExitPartial.
+func Partialf(format string, args ...interface{}) error {
+	return partialError{errors.Errorf(format, args...)}
+}
+
+// Flag is the output flag of the commands which print their result.
+func Flag() cli.Flag {
+	return cli.StringFlag{
+		Name:  "output, o",
+		Usage: "used to set the format the result is printed in, json, yaml or table.",
+	}
+}
+
+// Requested reports whether the output flag of the command or of one of its parents is set, the
+// commands which log their result by default only print it then.
+func Requested(c *cli.Context) bool {
+	return format(c) != ""
+}
+
+// Action runs a command and exits with the code of its error: ExitUsage, ExitPartial or ExitBackend.
+// The output flag is checked first so a command does not do its work to fail printing it.
+func Action(fn func(c *cli.Context) error) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		err := checkFormat(c)
+		if err == nil {
+			err = fn(c)
+		}
+		if err == nil {
+			return nil
+		}
+		return cli.NewExitError(err.Error(), exitCode(err))
+	}
+}
+
+func exitCode(err error) int {
+	switch errors.Cause(err).(type) {
+	case usageError:
+		return ExitUsage
+	case partialError:
+		return ExitPartial
+	}
+	// the requests the api refuses are errors of the arguments, e.g. a host which is not public
+	if s := approuter.StatusOf(err); s >= 400 && s < 500 {
+		return ExitUsage
+	}
+	return ExitBackend
+}
+
+// Write prints the result of a command in the format of the output flag, json by default. The field
+// names are the json names of the result in every format so scripts read one schema.
+func Write(c *cli.Context, v interface{}) error {
+	var b []byte
+	var err error
+
+	if err := checkFormat(c); err != nil {
+		return err
+	}
+
+	switch format(c) {
+	case FormatYAML:
+		b, err = toYAML(v)
+	case FormatTable:
+		b, err = toTable(v)
+	default:
+		b, err = json.MarshalIndent(v, "", "  ")
+		b = append(b, '\n')
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = c.App.Writer.Write(b)
+	return err
+}
+
+func checkFormat(c *cli.Context) error {
+	switch f := format(c); f {
+	case "", FormatJSON, FormatYAML, FormatTable:
+		return nil
+	default:
+		return Usagef("invalid output %s, expected %s, %s or %s", f, FormatJSON, FormatYAML, FormatTable)
+	}
+}
+
+func format(c *cli.Context) string {
+	if f := c.String("output"); f != "" {
+		return f
+	}
+	return c.GlobalString("output")
+}
+
+// Used to print a result as yaml with the json names of its fields
+// e.g. {"fqdn": "sample.lb.rancher.cloud"} => fqdn: sample.lb.rancher.cloud
+func toYAML(v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
+
+// Used to print a result as a table, a list gets one row by item and a column by field, anything
+// else gets a row by field. The columns are in the order of the fields of the result, values which
+// are not plain are printed as json.
+// e.g. [{"root": "lb.rancher.cloud", "moved": 3}] => "ROOT  MOVED", "lb.rancher.cloud  3"
+func toTable(v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+
+	switch g := generic.(type) {
+	case []interface{}:
+		columns := fieldNames(t, g...)
+		writeRow(w, upper(columns))
+		for _, item := range g {
+			m, _ := item.(map[string]interface{})
+			row := make([]string, len(columns))
+			for i, c := range columns {
+				row[i] = cell(m[c])
+			}
+			writeRow(w, row)
+		}
+	case map[string]interface{}:
+		for _, c := range fieldNames(t, g) {
+			writeRow(w, []string{strings.ToUpper(c), cell(g[c])})
+		}
+	default:
+		writeRow(w, []string{cell(g)})
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Used to get a result as the values json decodes to, the integers stay integers
+func toGeneric(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var generic interface{}
+	if err := d.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return numbers(generic), nil
+}
+
+func numbers(v interface{}) interface{} {
+	switch g := v.(type) {
+	case json.Number:
+		if i, err := g.Int64(); err == nil {
+			return i
+		}
+		f, _ := g.Float64()
+		return f
+	case []interface{}:
+		for i := range g {
+			g[i] = numbers(g[i])
+		}
+	case map[string]interface{}:
+		for k := range g {
+			g[k] = numbers(g[k])
+		}
+	}
+	return v
+}
+
+// Used to get the json names of the fields of a result in their order, the names of the items
+// which are not fields of a struct, e.g. the keys of a map, follow them sorted
+func fieldNames(t reflect.Type, items ...interface{}) []string {
+	names := structNames(t)
+	seen := make(map[string]bool)
+	for _, n := range names {
+		seen[n] = true
+	}
+
+	rest := make([]string, 0)
+	for _, item := range items {
+		m, _ := item.(map[string]interface{})
+		for k := range m {
+			if !seen[k] {
+				rest = append(rest, k)
+				seen[k] = true
+			}
+		}
+	}
+	sort.Strings(rest)
+
+	// the fields which are left out of every item are not printed
+	columns := make([]string, 0, len(names)+len(rest))
+	for _, n := range append(names, rest...) {
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				if _, ok := m[n]; ok {
+					columns = append(columns, n)
+					break
+				}
+			}
+		}
+	}
+	return columns
+}
+
+// Used to get the json names of the fields of a struct, the fields of an embedded struct are
+// fields of the struct as they are for json
+func structNames(t reflect.Type) []string {
+	names := make([]string, 0)
+	if t == nil || t.Kind() != reflect.Struct {
+		return names
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			names = append(names, structNames(f.Type)...)
+			continue
+		}
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func cell(v interface{}) string {
+	switch c := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return c
+	case int64, float64, bool:
+		return fmt.Sprint(c)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func upper(ss []string) []string {
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = strings.ToUpper(s)
+	}
+	return out
+}
+
+func writeRow(w io.Writer, row []string) {
+	fmt.Fprintln(w, strings.Join(row, "\t"))
+}
@@ -1,17 +1,21 @@
 package route53
 
 import (
-	"net/http"
 	"os"
 	"strings"
 
 	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/dual"
 	"github.com/rancher/rdns-server/backend/route53"
+	"github.com/rancher/rdns-server/command/output"
 	"github.com/rancher/rdns-server/database"
 	"github.com/rancher/rdns-server/database/mysql"
-	"github.com/rancher/rdns-server/metric"
+	"github.com/rancher/rdns-server/importer"
+	"github.com/rancher/rdns-server/lifecycle"
+	"github.com/rancher/rdns-server/migration"
 	"github.com/rancher/rdns-server/purge"
-	"github.com/rancher/rdns-server/service"
+	"github.com/rancher/rdns-server/server"
+	"github.com/rancher/rdns-server/util"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -19,14 +23,29 @@ import (
 )
 
 var (
+	globalFlags = []string{"ADMIN_TOKEN", "FROZEN", "METRICS_EXPORTER", "METRICS_ENDPOINT", "METRICS_INTERVAL",
+		"SLO_ALERT_WEBHOOK", "SLO_CANARY_RESOLVER", "SLO_CANARY_INTERVAL", "SLO_RESOLVE_THRESHOLD",
+		"DOUBLE_WRITE_BACKEND", "DOUBLE_WRITE_STATE", "ACME_TXT_TTL",
+		"RPZ_ZONE", "RPZ_FILE", "RPZ_INTERVAL", "MIGRATE_DATA",
+		"HEALTH_CHECK_PORT", "HEALTH_CHECK_INTERVAL", "HEALTH_CHECK_TIMEOUT", "HEALTH_CHECK_PARALLEL",
+		"WEBHOOK_URL", "WEBHOOK_SECRET", "WEBHOOK_WORKERS", "WEBHOOK_QUEUE_SIZE", "ADMIN_ROLES_FILE",
+		"EXPIRY_CHECK_INTERVAL", "EXPIRY_WARNING", "EXPIRY_WEBHOOK_URL", "JOB_VISIBILITY_TIMEOUT", "JOB_POLL_INTERVAL", "STATE_SYNC_INTERVAL",
+		"DOMAIN_TTL_MIN", "DOMAIN_TTL_MAX", "DOMAIN_LEASE_MIN", "DOMAIN_LEASE_MAX",
+		"TOKEN_RECOVERY_PORT", "TLS_CERT", "TLS_KEY", "TLS_CLIENT_CA",
+		"RECORD_CHANGE_LIMIT", "RECORD_CHANGE_BURST", "DISABLE_SUBSYSTEMS", "MAX_HOSTS", "REJECT_PRIVATE_HOSTS", "HOST_OWNERSHIP", "HOST_PROOF_SECRET", "TRUSTED_PROXIES",
+		"CREATE_API_KEYS", "CREATE_API_KEYS_FILE",
+		"REPUTATION_PROVIDERS", "REPUTATION_ACTION", "REPUTATION_CIDR_FILE", "REPUTATION_DNSBL", "REPUTATION_API_URL",
+		"CONFIG_FILE", "NODE_SYNC_URL", "NODE_SYNC_TOKEN", "NODE_SYNC_CA_FILE", "NODE_SYNC_INTERVAL", "SCHEDULE_INTERVAL"}
+
 	flags = map[string]map[string]string{
-		"AWS_HOSTED_ZONE_ID":    {"used to set aws hosted zone ID.": ""},
-		"AWS_ACCESS_KEY_ID":     {"used to set aws access key ID.": ""},
-		"AWS_SECRET_ACCESS_KEY": {"used to set aws secret access key.": ""},
-		"DATABASE":              {"used to set database driver.": "mysql"},
-		"DATABASE_LEASE_TIME":   {"used to set database lease time.": "240h"},
-		"DSN":                   {"used to set database dsn.": ""},
-		"TTL":                   {"used to set route53 ttl.": "10"},
+		"AWS_HOSTED_ZONE_ID":        {"used to set aws hosted zone ID.": ""},
+		"AWS_ACCESS_KEY_ID":         {"used to set aws access key ID.": ""},
+		"AWS_SECRET_ACCESS_KEY":     {"used to set aws secret access key.": ""},
+		"DATABASE":                  {"used to set database driver.": "mysql"},
+		"DATABASE_LEASE_TIME":       {"used to set database lease time.": "240h"},
+		"DSN":                       {"used to set database dsn.": ""},
+		"TTL":                       {"used to set route53 ttl.": "10"},
+		"ROUTE53_COALESCE_INTERVAL": {"used to set the interval which route53 changes are batched in, 0s disables batching.": "200ms"},
 	}
 )
 
@@ -61,23 +80,65 @@ func Action(c *cli.Context) error {
 		return err
 	}
 
-	done := make(chan struct{})
+	s, err := server.New(&server.Config{
+		Backend: backend.GetBackend(),
+		Listen:  c.GlobalString("listen"),
+		Subsystems: []server.Subsystem{
+			{Name: "purge", Run: lifecycle.Daemon(purge.StartPurgerDaemon)},
+		},
+	})
+	if err != nil {
+		return err
+	}
 
-	go metric.StartMetricDaemon(done)
+	return s.Run(lifecycle.Interrupted())
+}
 
-	go purge.StartPurgerDaemon(done)
+func MigrateDataAction(c *cli.Context) error {
+	if err := setEnvironments(c); err != nil {
+		return errors.Wrapf(err, "failed to set environments")
+	}
 
-	go func() {
-		if err := http.ListenAndServe(c.GlobalString("listen"), service.NewRouter()); err != nil {
-			logrus.Error(err)
-			done <- struct{}{}
-		}
-	}()
+	d, err := setDatabase(c)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
 
-	<-done
+	if err := setBackend(); err != nil {
+		return err
+	}
+
+	n, err := migration.Run(backend.GetBackend())
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("applied %d data migrations", n)
+	if output.Requested(c) {
+		return output.Write(c, migration.Result{Applied: n})
+	}
 	return nil
 }
 
+func ImportAction(c *cli.Context) error {
+	if err := setEnvironments(c); err != nil {
+		return errors.Wrapf(err, "failed to set environments")
+	}
+
+	d, err := setDatabase(c)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := setBackend(); err != nil {
+		return err
+	}
+
+	return importer.Import(c, backend.GetBackend())
+}
+
 func setEnvironments(c *cli.Context) error {
 	if c.GlobalBool("debug") {
 		logrus.SetLevel(logrus.DebugLevel)
@@ -88,11 +149,27 @@ func setEnvironments(c *cli.Context) error {
 			return err
 		}
 		if os.Getenv(k) == "" {
-			return errors.Errorf("expected argument: %s", strings.ToLower(k))
+			return output.Usagef("expected argument: %s", strings.ToLower(k))
 		}
 	}
 
-	return os.Setenv("FROZEN", c.GlobalString("frozen"))
+	for _, k := range globalFlags {
+		if err := os.Setenv(k, c.GlobalString(strings.ToLower(k))); err != nil {
+			return err
+		}
+	}
+
+	if util.IsTestMode() {
+		// no real TTLs in test mode, domains and challenge records live until they are deleted
+		if err := os.Setenv("DATABASE_LEASE_TIME", util.TestLeaseTime); err != nil {
+			return err
+		}
+		if err := os.Setenv("ACME_TXT_TTL", "0s"); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func setDatabase(c *cli.Context) (d *mysql.Database, err error) {
@@ -111,11 +188,16 @@ func setDatabase(c *cli.Context) (d *mysql.Database, err error) {
 }
 
 func setBackend() error {
-	b, err := route53.NewBackend()
+	b, err := backend.Open(&backend.Config{Name: route53.Name})
+	if err != nil {
+		return err
+	}
+
+	d, err := dual.Wrap(b)
 	if err != nil {
 		return err
 	}
-	backend.SetBackend(b)
+	backend.SetBackend(d)
 
 	return nil
 }
@@ -0,0 +1,306 @@
+package smoke
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	approuter "github.com/rancher/rdns-server/client"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+const (
+	Name = "smoke"
+
+	resultPass = "PASS"
+	resultFail = "FAIL"
+	resultSkip = "SKIP"
+
+	textPrefix = "_acme-challenge"
+	textValue  = "rdns-smoke"
+)
+
+// defaultHosts are documentation addresses, the smoke domain only has to resolve to them
+var defaultHosts = []string{"192.0.2.10", "192.0.2.11"}
+
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "target",
+			EnvVar: "RDNS_SMOKE_TARGET",
+			Usage:  "used to set the base url of the rdns api which is verified, e.g. https://api.lb.rancher.cloud/v1.",
+		},
+		cli.StringFlag{
+			Name:   "resolver",
+			EnvVar: "RDNS_SMOKE_RESOLVER",
+			Usage:  "used to set the name server the records are resolved with, e.g. ns1.lb.rancher.cloud or 10.0.0.2:53.",
+		},
+		cli.StringSliceFlag{
+			Name:  "host",
+			Usage: "used to set the hosts of the domain before and after the update, repeat it twice. (default: 192.0.2.10, 192.0.2.11)",
+		},
+		cli.StringFlag{
+			Name:  "propagation",
+			Usage: "used to set how long the resolver may answer the previous records after a change.",
+			Value: "30s",
+		},
+		cli.StringFlag{
+			Name:  "timeout",
+			Usage: "used to set the timeout of a query.",
+			Value: "2s",
+		},
+	}
+}
+
+// step is the outcome of one step of the lifecycle
+type step struct {
+	name     string
+	result   string
+	duration time.Duration
+	detail   string
+}
+
+// run verifies the lifecycle of one domain, the steps are run in order and the steps after
+// a failed one are skipped
+type run struct {
+	client      *approuter.Client
+	dns         *dns.Client
+	resolver    string
+	propagation time.Duration
+	hosts       []string
+
+	steps  []step
+	failed bool
+	fqdn   string
+	token  string
+}
+
+// Action creates a domain on --target and verifies that --resolver answers its records through
+// the whole lifecycle (create, update, renew, TXT and delete), then prints a report of the steps.
+// The domain is deleted even when a step fails, a failed step fails the command.
+func Action(c *cli.Context) error {
+	target := strings.TrimSuffix(c.String("target"), "/")
+	if target == "" {
+		return errors.New("expected argument: target")
+	}
+	resolver := c.String("resolver")
+	if resolver == "" {
+		return errors.New("expected argument: resolver")
+	}
+	if _, _, err := net.SplitHostPort(resolver); err != nil {
+		resolver = net.JoinHostPort(resolver, "53")
+	}
+
+	hosts := c.StringSlice("host")
+	if len(hosts) == 0 {
+		hosts = defaultHosts
+	}
+	if len(hosts) != 2 {
+		return errors.Errorf("expected 2 hosts, got %d", len(hosts))
+	}
+	for _, h := range hosts {
+		if net.ParseIP(h).To4() == nil {
+			return errors.Errorf("invalid host ipv4: %s", h)
+		}
+	}
+	propagation, err := time.ParseDuration(c.String("propagation"))
+	if err != nil {
+		return errors.Wrapf(err, "invalid propagation %s", c.String("propagation"))
+	}
+	timeout, err := time.ParseDuration(c.String("timeout"))
+	if err != nil {
+		return errors.Wrapf(err, "invalid timeout %s", c.String("timeout"))
+	}
+
+	r := &run{
+		client:      approuter.NewTokenClient(target),
+		dns:         &dns.Client{Net: "udp", Timeout: timeout},
+		resolver:    resolver,
+		propagation: propagation,
+		hosts:       hosts,
+	}
+	r.step("create", r.create)
+	r.step("resolve", func() (string, error) { return r.resolve(dns.TypeA, hosts[:1]) })
+	r.step("update", r.update)
+	r.step("resolve-update", func() (string, error) { return r.resolve(dns.TypeA, hosts[1:]) })
+	r.step("renew", r.renew)
+	r.step("txt", r.text)
+	r.step("resolve-txt", func() (string, error) { return r.resolveText([]string{textValue}) })
+	r.step("delete-txt", r.deleteText)
+	r.step("delete", r.delete)
+	r.step("resolve-delete", func() (string, error) { return r.resolve(dns.TypeA, nil) })
+	// the domain of a failed run is not left behind
+	if r.failed && r.token != "" {
+		r.client.DeleteDomainWithToken(r.fqdn, r.token)
+	}
+
+	if err := report(c, r.steps); err != nil {
+		return err
+	}
+	if r.failed {
+		return errors.Errorf("smoke test of %s failed", target)
+	}
+	return nil
+}
+
+func (r *run) step(name string, f func() (string, error)) {
+	if r.failed {
+		r.steps = append(r.steps, step{name: name, result: resultSkip})
+		return
+	}
+
+	start := time.Now()
+	detail, err := f()
+	s := step{name: name, result: resultPass, duration: time.Since(start), detail: detail}
+	if err != nil {
+		s.result, s.detail = resultFail, err.Error()
+		r.failed = true
+	}
+	r.steps = append(r.steps, s)
+}
+
+func (r *run) create() (string, error) {
+	d, token, err := r.client.CreateDomainWithHosts(r.hosts[:1])
+	if err != nil {
+		return "", err
+	}
+	if d.Fqdn == "" || token == "" {
+		return "", errors.New("created domain has no fqdn or token")
+	}
+	r.fqdn, r.token = d.Fqdn, token
+	return d.Fqdn, nil
+}
+
+func (r *run) update() (string, error) {
+	if err := r.client.UpdateDomainWithToken(r.fqdn, r.token, r.hosts[1:], nil); err != nil {
+		return "", err
+	}
+	return strings.Join(r.hosts[1:], ","), nil
+}
+
+func (r *run) renew() (string, error) {
+	before, err := r.client.GetDomainWithToken(r.fqdn, r.token)
+	if err != nil {
+		return "", err
+	}
+	if before == nil {
+		return "", errors.Errorf("domain %s does not exist", r.fqdn)
+	}
+	d, err := r.client.RenewDomainWithToken(r.fqdn, r.token)
+	if err != nil {
+		return "", err
+	}
+	if d.Expiration == nil {
+		return "", errors.New("renewed domain has no expiration")
+	}
+	if before.Expiration != nil && d.Expiration.Before(*before.Expiration) {
+		return "", errors.Errorf("expiration moved from %s to %s", before.Expiration.Format(time.RFC3339), d.Expiration.Format(time.RFC3339))
+	}
+	return "expires " + d.Expiration.Format(time.RFC3339), nil
+}
+
+func (r *run) text() (string, error) {
+	if _, err := r.client.SetTextWithToken(r.textName(), r.token, textValue); err != nil {
+		return "", err
+	}
+	return r.textName(), nil
+}
+
+func (r *run) deleteText() (string, error) {
+	return "", r.client.DeleteTextWithToken(r.textName(), r.token)
+}
+
+func (r *run) delete() (string, error) {
+	if err := r.client.DeleteDomainWithToken(r.fqdn, r.token); err != nil {
+		return "", err
+	}
+	r.token = ""
+	return "", nil
+}
+
+func (r *run) textName() string {
+	return textPrefix + "." + r.fqdn
+}
+
+// resolve queries the records of the domain until they are the expected ones or the propagation
+// ends, no expected values means the name must not resolve
+func (r *run) resolve(qtype uint16, expected []string) (string, error) {
+	return r.poll(r.fqdn, qtype, expected)
+}
+
+func (r *run) resolveText(expected []string) (string, error) {
+	return r.poll(r.textName(), dns.TypeTXT, expected)
+}
+
+func (r *run) poll(name string, qtype uint16, expected []string) (string, error) {
+	want := strings.Join(sorted(expected), ",")
+	deadline := time.Now().Add(r.propagation)
+	for {
+		got, err := r.query(name, qtype)
+		if err == nil && strings.Join(got, ",") == want {
+			return fmt.Sprintf("%s %s [%s]", dns.TypeToString[qtype], name, want), nil
+		}
+		if !time.Now().Before(deadline) {
+			if err != nil {
+				return "", err
+			}
+			return "", errors.Errorf("%s %s answered [%s], expected [%s]", dns.TypeToString[qtype], name, strings.Join(got, ","), want)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// query returns the sorted values of the answers of a name, a name which does not exist has none
+func (r *run) query(name string, qtype uint16) ([]string, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	resp, _, err := r.dns.Exchange(m, r.resolver)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query %s", name)
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, errors.Errorf("query of %s answered %s", name, dns.RcodeToString[resp.Rcode])
+	}
+
+	values := make([]string, 0)
+	for _, rr := range resp.Answer {
+		switch v := rr.(type) {
+		case *dns.A:
+			if qtype == dns.TypeA {
+				values = append(values, v.A.String())
+			}
+		case *dns.TXT:
+			if qtype == dns.TypeTXT {
+				values = append(values, strings.Join(v.Txt, ""))
+			}
+		}
+	}
+	return sorted(values), nil
+}
+
+func sorted(ss []string) []string {
+	ss = append([]string{}, ss...)
+	sort.Strings(ss)
+	return ss
+}
+
+func report(c *cli.Context, steps []step) error {
+	w := tabwriter.NewWriter(c.App.Writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STEP\tRESULT\tDURATION\tDETAIL")
+
+	passed := 0
+	for _, s := range steps {
+		if s.result == resultPass {
+			passed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.name, s.result, s.duration.Round(time.Millisecond), s.detail)
+	}
+	fmt.Fprintf(w, "total\t%d/%d passed\t\t\n", passed, len(steps))
+	return w.Flush()
+}
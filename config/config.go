@@ -0,0 +1,291 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	levelGlobal = "global"
+
+	defaultTTLMin      = 30
+	defaultTTLMax      = 3600
+	defaultLeaseMin    = time.Hour
+	defaultLeaseMax    = 720 * time.Hour
+	defaultSlugLength  = 6
+	defaultMaxWebhooks = 5
+	// ReputationReject refuses the domains with a listed host
+	ReputationReject = "reject"
+	// ReputationFlag keeps the domains with a listed host, they are logged and counted
+	ReputationFlag = "flag"
+
+	minSlugLength = 4
+	maxSlugLength = 32
+)
+
+var current struct {
+	sync.RWMutex
+	path string
+	file *model.ConfigFile
+}
+
+// Resolved are the settings which apply to a name.
+type Resolved struct {
+	Root             string
+	Zone             string
+	TTLMin           uint32
+	TTLMax           uint32
+	LeaseMin         time.Duration
+	LeaseMax         time.Duration
+	SlugLength       int
+	MaxWebhooks      int
+	ReputationAction string
+	// QueryTypes are the record types the domains may answer, empty means every type
+	QueryTypes []string
+}
+
+// Load reads and checks CONFIG_FILE, the environments apply alone when it is not set.
+func Load() error {
+	path := os.Getenv("CONFIG_FILE")
+
+	file := &model.ConfigFile{}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, errLoadFile, path)
+		}
+		if err := yaml.UnmarshalStrict(b, file); err != nil {
+			return errors.Wrapf(err, errLoadFile, path)
+		}
+		file = normalize(file)
+		if err := check(file); err != nil {
+			return errors.Wrapf(err, errLoadFile, path)
+		}
+		logrus.Infof("loaded the settings of %d root domains from %s", len(file.Roots), path)
+	}
+
+	current.Lock()
+	defer current.Unlock()
+	current.path = path
+	current.file = file
+	return nil
+}
+
+// File returns the path and the contents of the loaded config file, nil means none is loaded.
+func File() (string, *model.ConfigFile) {
+	current.RLock()
+	defer current.RUnlock()
+	return current.path, current.file
+}
+
+// Resolve returns the settings of a name, the zone is the longest zone of the longest root domain
+// the name is under.
+// e.g. x1.abcdef.eu.lb.rancher.cloud => global < lb.rancher.cloud < eu.lb.rancher.cloud
+func Resolve(fqdn string) *Resolved {
+	_, file := File()
+	if file == nil {
+		return fromEnv()
+	}
+	return resolve(file, fqdn)
+}
+
+// Settings returns the resolved settings with every setting set.
+func (r *Resolved) Settings() model.Settings {
+	return model.Settings{
+		TTLMin:           &r.TTLMin,
+		TTLMax:           &r.TTLMax,
+		LeaseMin:         r.LeaseMin.String(),
+		LeaseMax:         r.LeaseMax.String(),
+		SlugLength:       &r.SlugLength,
+		MaxWebhooks:      &r.MaxWebhooks,
+		ReputationAction: r.ReputationAction,
+		QueryTypes:       r.QueryTypes,
+	}
+}
+
+// Used to get the settings of the environments, invalid environments keep the defaults
+func fromEnv() *Resolved {
+	r := &Resolved{
+		TTLMin:           defaultTTLMin,
+		TTLMax:           defaultTTLMax,
+		LeaseMin:         defaultLeaseMin,
+		LeaseMax:         defaultLeaseMax,
+		SlugLength:       defaultSlugLength,
+		MaxWebhooks:      defaultMaxWebhooks,
+		ReputationAction: ReputationReject,
+	}
+	if v, err := strconv.ParseUint(os.Getenv("DOMAIN_TTL_MIN"), 10, 32); err == nil {
+		r.TTLMin = uint32(v)
+	}
+	if v, err := strconv.ParseUint(os.Getenv("DOMAIN_TTL_MAX"), 10, 32); err == nil {
+		r.TTLMax = uint32(v)
+	}
+	if v, err := time.ParseDuration(os.Getenv("DOMAIN_LEASE_MIN")); err == nil {
+		r.LeaseMin = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("DOMAIN_LEASE_MAX")); err == nil {
+		r.LeaseMax = v
+	}
+	if os.Getenv("REPUTATION_ACTION") == ReputationFlag {
+		r.ReputationAction = ReputationFlag
+	}
+	return r
+}
+
+// the settings are checked when they are loaded, so they are merged without errors
+func (r *Resolved) merge(s model.Settings) {
+	if s.TTLMin != nil {
+		r.TTLMin = *s.TTLMin
+	}
+	if s.TTLMax != nil {
+		r.TTLMax = *s.TTLMax
+	}
+	if d, err := time.ParseDuration(s.LeaseMin); err == nil {
+		r.LeaseMin = d
+	}
+	if d, err := time.ParseDuration(s.LeaseMax); err == nil {
+		r.LeaseMax = d
+	}
+	if s.SlugLength != nil {
+		r.SlugLength = *s.SlugLength
+	}
+	if s.MaxWebhooks != nil {
+		r.MaxWebhooks = *s.MaxWebhooks
+	}
+	if s.ReputationAction != "" {
+		r.ReputationAction = s.ReputationAction
+	}
+	if types, err := model.NormalizeQueryTypes(s.QueryTypes); err == nil && len(types) > 0 {
+		r.QueryTypes = types
+	}
+}
+
+func check(file *model.ConfigFile) error {
+	if err := checkSettings(file.Global, levelGlobal); err != nil {
+		return err
+	}
+	for root, rs := range file.Roots {
+		if err := checkSettings(rs.Settings, root); err != nil {
+			return err
+		}
+		for zone, zs := range rs.Zones {
+			if !strings.HasSuffix(normalizeName(zone), "."+normalizeName(root)) {
+				return errors.Errorf(errZoneNotUnderRoot, zone, root)
+			}
+			if err := checkSettings(zs, zone); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the ranges may only be empty once they are merged, e.g. a zone which raises ttlMin above the ttlMax of its root
+	if r := resolve(file, ""); r.TTLMin > r.TTLMax {
+		return errors.Errorf(errInvalidTTL, r.TTLMin, r.TTLMax, levelGlobal)
+	}
+	for root, rs := range file.Roots {
+		names := []string{root}
+		for zone := range rs.Zones {
+			names = append(names, zone)
+		}
+		for _, n := range names {
+			if r := resolve(file, n); r.TTLMin > r.TTLMax {
+				return errors.Errorf(errInvalidTTL, r.TTLMin, r.TTLMax, n)
+			}
+		}
+	}
+	return nil
+}
+
+func checkSettings(s model.Settings, level string) error {
+	if s.TTLMin != nil && s.TTLMax != nil && *s.TTLMin > *s.TTLMax {
+		return errors.Errorf(errInvalidTTL, *s.TTLMin, *s.TTLMax, level)
+	}
+	for name, v := range map[string]string{"leaseMin": s.LeaseMin, "leaseMax": s.LeaseMax} {
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return errors.Errorf(errInvalidDuration, name, v, level)
+		}
+	}
+	if s.SlugLength != nil && (*s.SlugLength < minSlugLength || *s.SlugLength > maxSlugLength) {
+		return errors.Errorf(errInvalidSlugLength, *s.SlugLength, level, minSlugLength, maxSlugLength)
+	}
+	if s.MaxWebhooks != nil && *s.MaxWebhooks < 0 {
+		return errors.Errorf(errInvalidWebhooks, *s.MaxWebhooks, level)
+	}
+	if s.ReputationAction != "" && s.ReputationAction != ReputationReject && s.ReputationAction != ReputationFlag {
+		return errors.Errorf(errInvalidAction, s.ReputationAction, level)
+	}
+	if _, err := model.NormalizeQueryTypes(s.QueryTypes); err != nil {
+		return errors.Wrapf(err, errInvalidQueryTypes, level)
+	}
+	return nil
+}
+
+func resolve(file *model.ConfigFile, fqdn string) *Resolved {
+	r := fromEnv()
+	r.merge(file.Global)
+
+	name := normalizeName(fqdn)
+	roots := make([]string, 0, len(file.Roots))
+	for root := range file.Roots {
+		roots = append(roots, root)
+	}
+	if r.Root = longestSuffix(name, roots); r.Root == "" {
+		return r
+	}
+	root := file.Roots[r.Root]
+	r.merge(root.Settings)
+
+	zones := make([]string, 0, len(root.Zones))
+	for zone := range root.Zones {
+		zones = append(zones, zone)
+	}
+	if r.Zone = longestSuffix(name, zones); r.Zone != "" {
+		r.merge(root.Zones[r.Zone])
+	}
+	return r
+}
+
+// Used to get the longest of the names which the name is or is under
+// e.g. abcdef.eu.lb.rancher.cloud, [lb.rancher.cloud eu.lb.rancher.cloud] => eu.lb.rancher.cloud
+func longestSuffix(name string, names []string) string {
+	longest := ""
+	for _, n := range names {
+		if (name == n || strings.HasSuffix(name, "."+n)) && len(n) > len(longest) {
+			longest = n
+		}
+	}
+	return longest
+}
+
+// Used to get the names of the file in the form they are matched with
+// e.g. LB.Rancher.Cloud. => lb.rancher.cloud
+func normalize(file *model.ConfigFile) *model.ConfigFile {
+	roots := make(map[string]model.RootSettings, len(file.Roots))
+	for root, rs := range file.Roots {
+		zones := make(map[string]model.Settings, len(rs.Zones))
+		for zone, zs := range rs.Zones {
+			zones[normalizeName(zone)] = zs
+		}
+		rs.Zones = zones
+		roots[normalizeName(root)] = rs
+	}
+	file.Roots = roots
+	return file
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.Trim(name, "."))
+}
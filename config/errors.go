@@ -0,0 +1,12 @@
+package config
+
+const (
+	errInvalidAction     = "invalid reputation action %s of %s"
+	errInvalidDuration   = "invalid %s %s of %s"
+	errInvalidQueryTypes = "invalid query types of %s"
+	errInvalidSlugLength = "slug length %d of %s is not within [%d, %d]"
+	errInvalidTTL        = "ttl range [%d, %d] of %s is empty"
+	errInvalidWebhooks   = "max webhooks %d of %s is negative"
+	errLoadFile          = "failed to load the config file %s"
+	errZoneNotUnderRoot  = "zone %s is not under root domain %s"
+)
@@ -41,7 +41,9 @@ func init() {
 	dnsserver.Directives = plugin.Directives
 }
 
-func StartCoreDNSDaemon() {
+// StartCoreDNSDaemon serves dns until done is closed, the signals are handled by the lifecycle
+// of the command so caddy does not trap them.
+func StartCoreDNSDaemon(done chan struct{}) error {
 	prepareFlags()
 
 	caddy.RegisterCaddyfileLoader("flag", caddy.LoaderFunc(confLoader))
@@ -52,23 +54,36 @@ func StartCoreDNSDaemon() {
 		Action:     rdns.Setup,
 	})
 
-	caddy.TrapSignals()
-
 	if err := setCPU(cpu); err != nil {
-		logrus.Fatal(err)
+		return err
 	}
 
 	f, err := caddy.LoadCaddyfile(CoreType)
 	if err != nil {
-		logrus.Fatal(err)
+		return err
 	}
 
 	instance, err := caddy.Start(f)
 	if err != nil {
-		logrus.Fatal(err)
+		return err
+	}
+
+	if d, err := Drift(); err != nil {
+		logrus.Error(err)
+	} else if d.Drift {
+		logrus.Warnf("running Corefile %s differs from the generated one, missing lines: %q, extra lines: %q", d.Path, d.Missing, d.Extra)
 	}
 
+	go func() {
+		<-done
+		// the reload plugin replaces the instance, so all running instances are stopped
+		if err := caddy.Stop(); err != nil {
+			logrus.Error(err)
+		}
+	}()
+
 	instance.Wait()
+	return nil
 }
 
 func confLoader(serverType string) (caddy.Input, error) {
@@ -84,6 +99,7 @@ func confLoader(serverType string) (caddy.Input, error) {
 	if err != nil {
 		return nil, err
 	}
+	setRunning(conf, contents)
 	return caddy.CaddyfileInput{
 		Contents:       contents,
 		Filepath:       conf,
@@ -99,6 +115,7 @@ func defaultLoader(serverType string) (caddy.Input, error) {
 		}
 		return nil, err
 	}
+	setRunning(caddy.DefaultConfigFile, contents)
 	return caddy.CaddyfileInput{
 		Contents:       contents,
 		Filepath:       caddy.DefaultConfigFile,
@@ -0,0 +1,166 @@
+package coredns
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// the Corefile contents which the embedded CoreDNS started or reloaded with
+	running struct {
+		sync.RWMutex
+		path     string
+		contents []byte
+		loaded   *time.Time
+	}
+
+	driftGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "rancher_dns_corefile_drift",
+		Help: "Whether the running Corefile differs from the generated one, 1 means drift",
+	})
+)
+
+// Render renders the Corefile which the etcdv3 command generates from its environments.
+func Render() ([]byte, error) {
+	roots := util.RootDomains(os.Getenv("DOMAIN"))
+	if len(roots) == 0 {
+		return nil, errors.Errorf("invalid root domains: %s", os.Getenv("DOMAIN"))
+	}
+
+	cf := &model.CoreFile{
+		CoreDNSDBFile:  os.Getenv("CORE_DNS_DB_FILE"),
+		CoreDNSDBZone:  os.Getenv("CORE_DNS_DB_ZONE"),
+		Domain:         strings.Join(roots, " "),
+		EtcdPrefixPath: os.Getenv("ETCD_PREFIX_PATH"),
+		EtcdEndpoints:  strings.Join(strings.Split(os.Getenv("ETCD_ENDPOINTS"), ","), " "),
+		EtcdShards:     os.Getenv("ETCD_SHARDS"),
+		// empty stays empty, the plugin reads from its endpoints then
+		EtcdReadEndpoints: strings.Join(strings.Split(os.Getenv("ETCD_READ_ENDPOINTS"), ","), " "),
+		PreloadKeys:       os.Getenv("CORE_DNS_PRELOAD_KEYS"),
+		WatchCacheKeys:    os.Getenv("CORE_DNS_WATCH_CACHE_KEYS"),
+		StaleDuration:     os.Getenv("CORE_DNS_STALE_DURATION"),
+		AnswerPolicy:      os.Getenv("CORE_DNS_ANSWER_POLICY"),
+		AnswerLimit:       os.Getenv("CORE_DNS_ANSWER_LIMIT"),
+		LimitStrategy:     os.Getenv("CORE_DNS_LIMIT_STRATEGY"),
+		WildcardFallback:  os.Getenv("CORE_DNS_WILDCARD_FALLBACK"),
+		MinimalResponses:  os.Getenv("CORE_DNS_MINIMAL_RESPONSES"),
+		RefuseAny:         os.Getenv("CORE_DNS_REFUSE_ANY"),
+		RecursionNets:     strings.Join(strings.Split(os.Getenv("CORE_DNS_RECURSION_NETS"), ","), " "),
+		UsageTiers:        os.Getenv("USAGE_TIERS"),
+		Cookies:           os.Getenv("CORE_DNS_COOKIES"),
+		Padding:           os.Getenv("CORE_DNS_PADDING"),
+		TTL:               os.Getenv("TTL"),
+		// the bound of the other root domains is shifted by the plugin
+		WildCardBound: strconv.Itoa(len(strings.Split(roots[0], ".")) + 1),
+	}
+
+	p, err := template.New("corefile-tmpl").Parse(model.CoreFileTmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := p.Execute(&buf, cf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Running reports whether the embedded CoreDNS has loaded a Corefile.
+func Running() bool {
+	running.RLock()
+	defer running.RUnlock()
+
+	return running.loaded != nil
+}
+
+// Drift compares the running Corefile with the generated one and with the file on disk, which is
+// loaded by the next reload. Blank lines, comments and indentation are ignored.
+func Drift() (model.CoreFileDrift, error) {
+	running.RLock()
+	path, contents, loaded := running.path, running.contents, running.loaded
+	running.RUnlock()
+
+	expected, err := Render()
+	if err != nil {
+		return model.CoreFileDrift{}, err
+	}
+
+	d := model.CoreFileDrift{
+		Path:   path,
+		Loaded: loaded,
+	}
+	d.Missing, d.Extra = diffLines(expected, contents)
+	d.Drift = len(d.Missing) > 0 || len(d.Extra) > 0
+
+	if disk, err := ioutil.ReadFile(path); err == nil {
+		m, e := diffLines(disk, contents)
+		d.ReloadPending = len(m) > 0 || len(e) > 0
+	}
+
+	if d.Drift {
+		driftGauge.Set(1)
+	} else {
+		driftGauge.Set(0)
+	}
+
+	return d, nil
+}
+
+func setRunning(path string, contents []byte) {
+	running.Lock()
+	defer running.Unlock()
+
+	now := time.Now()
+	running.path = path
+	running.contents = contents
+	running.loaded = &now
+}
+
+// Used to get the lines of a which are not in b and the lines of b which are not in a
+// e.g. "cache 60", "cache 30" => ["cache 60"], ["cache 30"]
+func diffLines(a, b []byte) (missing, extra []string) {
+	count := make(map[string]int)
+	for _, l := range configLines(b) {
+		count[l]++
+	}
+	for _, l := range configLines(a) {
+		if count[l] > 0 {
+			count[l]--
+			continue
+		}
+		missing = append(missing, l)
+	}
+	for _, l := range configLines(b) {
+		if count[l] > 0 {
+			count[l]--
+			extra = append(extra, l)
+		}
+	}
+	return missing, extra
+}
+
+func configLines(contents []byte) []string {
+	lines := make([]string, 0)
+	for _, l := range strings.Split(string(contents), "\n") {
+		l = strings.Join(strings.Fields(l), " ")
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
@@ -0,0 +1,111 @@
+package rdns
+
+import (
+	"net"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	aclAllow = "allow"
+	aclDeny  = "deny"
+
+	refusedACL       = "acl"
+	refusedAny       = "any"
+	refusedRecursion = "recursion"
+)
+
+var refusedQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rdns",
+	Name:      "refused_queries_total",
+	Help:      "Counter of queries refused by the acls, by reason.",
+}, []string{"reason"})
+
+type aclRule struct {
+	allow bool
+	nets  []*net.IPNet
+	zones plugin.Zones
+}
+
+// acl refuses the queries which may be abused for amplification: ANY queries, queries of sources
+// denied by a zone and queries outside the zones which would be passed to the next plugin.
+type acl struct {
+	rules     []aclRule
+	anyZones  plugin.Zones
+	recursion []*net.IPNet // sources which may query outside the zones, nil means all sources
+}
+
+// Used to get the reason a query is refused for, empty means it is answered
+func (a *acl) refuse(zone string, state request.Request) string {
+	ip := net.ParseIP(state.IP())
+	if zone == "" {
+		if a.recursion != nil && !contains(a.recursion, ip) {
+			return refusedRecursion
+		}
+		return ""
+	}
+
+	if state.QType() == dns.TypeANY && a.anyZones.Matches(state.Name()) != "" {
+		return refusedAny
+	}
+
+	// the first rule which matches the source wins, sources without a rule are allowed
+	for _, r := range a.rules {
+		if r.zones.Matches(state.Name()) == "" || !contains(r.nets, ip) {
+			continue
+		}
+		if !r.allow {
+			return refusedACL
+		}
+		break
+	}
+	return ""
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Used to parse networks, a single address is a network of its own
+// e.g. 10.0.0.0/8,192.0.2.1 => [10.0.0.0/8 192.0.2.1/32]
+func parseNets(args []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0)
+	for _, arg := range args {
+		for _, s := range strings.Split(arg, ",") {
+			if s == "" {
+				continue
+			}
+			if !strings.Contains(s, "/") {
+				if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+					s += "/32"
+				} else {
+					s += "/128"
+				}
+			}
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, err
+			}
+			nets = append(nets, n)
+		}
+	}
+	return nets, nil
+}
+
+func normalizeZones(zones []string) plugin.Zones {
+	result := make(plugin.Zones, len(zones))
+	for i, z := range zones {
+		result[i] = plugin.Host(z).Normalize()
+	}
+	return result
+}
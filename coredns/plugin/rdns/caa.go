@@ -0,0 +1,39 @@
+package rdns
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rancher/rdns-server/dnsutil"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// CAA looks up the CAA records of the exact name, which the rdns backend keeps in one key of the
+// domain. CAs climb to the parent names themselves, so the records of a domain also cover its sub domains.
+func (e *ETCD) CAA(ctx context.Context, state request.Request) ([]dns.RR, error) {
+	ctx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	defer cancel()
+
+	r, err := e.read(ctx, util.CAAKey(e.PathPrefix, state.Name()))
+	if err != nil {
+		return nil, err
+	}
+	if r.Count == 0 {
+		return nil, nil
+	}
+
+	var records []model.CAARecord
+	if err := json.Unmarshal(r.Kvs[0].Value, &records); err != nil {
+		return nil, err
+	}
+
+	rrs := make([]dns.RR, 0, len(records))
+	for _, c := range records {
+		rrs = append(rrs, dnsutil.CAA(state.QName(), ttl, c))
+	}
+	return rrs, nil
+}
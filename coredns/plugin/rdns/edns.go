@@ -0,0 +1,253 @@
+package rdns
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"time"
+
+	"github.com/rancher/rdns-server/coredns/plugin"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ednsHandlerName = "rdns_edns"
+	// cookieSecretEnv keeps the secret out of the generated Corefile, servers behind one address share it
+	cookieSecretEnv = "CORE_DNS_COOKIE_SECRET"
+
+	clientCookieLength = 8
+	// a server cookie is 8 to 32 bytes, ours is the version, 3 reserved bytes, the timestamp and the hash of RFC 9018
+	serverCookieLength  = 16
+	maxServerCookie     = 32
+	serverCookieVersion = 1
+	// cookies older than an hour or more than 5 minutes in the future are not valid, as RFC 9018 recommends
+	cookieMaxAge  = time.Hour
+	cookieMaxSkew = 5 * time.Minute
+	// the padding option adds a header of 4 bytes to the message
+	paddingHeader = 4
+
+	cookieNone      = "none"
+	cookieClient    = "client"
+	cookieValid     = "valid"
+	cookieInvalid   = "invalid"
+	cookieMalformed = "malformed"
+)
+
+var cookieQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rdns",
+	Name:      "cookie_queries_total",
+	Help:      "Counter of queries by the DNS cookie they carry.",
+}, []string{"cookie"})
+
+// ednsOptions answers the DNS cookies (RFC 7873) and pads the responses (RFC 7830) of the queries
+// which ask for them. It is installed in front of the plugin chain, so the answers served by the
+// cache plugin carry them too.
+type ednsOptions struct {
+	// secret of the server cookies, nil means cookies are not answered
+	secret []byte
+	// padding block sizes by zone, zones without one are not padded
+	padding map[string]int
+	names   plugin.Zones
+}
+
+// Used to get the secret of the server cookies, the secret of CORE_DNS_COOKIE_SECRET is used when
+// none is given and a random one when neither is set
+func cookieSecret(s string) ([]byte, error) {
+	if s == "" {
+		s = os.Getenv(cookieSecretEnv)
+	}
+	if s != "" {
+		return []byte(s), nil
+	}
+
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	return secret, err
+}
+
+func (o *ednsOptions) setPadding(block int, zones []string) {
+	if o.padding == nil {
+		o.padding = make(map[string]int)
+	}
+	for _, z := range zones {
+		if _, ok := o.padding[z]; !ok {
+			o.names = append(o.names, z)
+		}
+		o.padding[z] = block
+	}
+}
+
+// Used to get the padding block of the longest zone which matches the name, 0 means not padded
+func (o *ednsOptions) paddingOf(name string) int {
+	return o.padding[o.names.Matches(name)]
+}
+
+// Used to get the server cookie of a client cookie
+// e.g. 01000000 5f5e1000 <8 bytes of hmac-sha256(secret, client cookie | version | reserved | timestamp | client ip)>
+func (o *ednsOptions) serverCookie(client []byte, ip net.IP, at time.Time) []byte {
+	cookie := make([]byte, 8, serverCookieLength)
+	cookie[0] = serverCookieVersion
+	binary.BigEndian.PutUint32(cookie[4:], uint32(at.Unix()))
+
+	mac := hmac.New(sha256.New, o.secret)
+	mac.Write(client)
+	mac.Write(cookie)
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	mac.Write(ip)
+	return append(cookie, mac.Sum(nil)[:serverCookieLength-8]...)
+}
+
+// Used to tell whether the server cookie was issued by a server of the secret to the client
+func (o *ednsOptions) validCookie(client, server []byte, ip net.IP, now time.Time) bool {
+	if len(server) != serverCookieLength || server[0] != serverCookieVersion {
+		return false
+	}
+	at := time.Unix(int64(binary.BigEndian.Uint32(server[4:8])), 0)
+	if now.Sub(at) > cookieMaxAge || at.Sub(now) > cookieMaxSkew {
+		return false
+	}
+	return hmac.Equal(server, o.serverCookie(client, ip, at))
+}
+
+type ednsHandler struct {
+	Next    plugin.Handler
+	options *ednsOptions
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (h *ednsHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return plugin.NextOrFailure(ctx, h.Name(), h.Next, w, r)
+	}
+
+	state := request.Request{W: w, Req: r}
+	ip := net.ParseIP(state.IP())
+	ew := &ednsWriter{ResponseWriter: w, options: h.options, udpSize: opt.UDPSize(), do: opt.Do(), size: state.Size()}
+	for _, o := range opt.Option {
+		switch e := o.(type) {
+		case *dns.EDNS0_COOKIE:
+			ew.cookie = e.Cookie
+		case *dns.EDNS0_PADDING:
+			ew.block = h.options.paddingOf(state.Name())
+		}
+	}
+
+	if h.options.secret != nil {
+		result, client := h.checkCookie(ew.cookie, ip)
+		cookieQueries.WithLabelValues(result).Inc()
+		if result == cookieMalformed {
+			// RFC 7873 5.2.2, a cookie of an invalid length is a format error
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeFormatError)
+			w.WriteMsg(m)
+			return dns.RcodeFormatError, nil
+		}
+		ew.client, ew.ip = client, ip
+	}
+
+	return plugin.NextOrFailure(ctx, h.Name(), h.Next, ew, r)
+}
+
+// Used to check the cookie of a query, the client cookie is nil without a cookie
+func (h *ednsHandler) checkCookie(cookie string, ip net.IP) (string, []byte) {
+	if cookie == "" {
+		return cookieNone, nil
+	}
+	b, err := hex.DecodeString(cookie)
+	if err != nil || len(b) < clientCookieLength || (len(b) > clientCookieLength && len(b) < clientCookieLength+8) || len(b) > clientCookieLength+maxServerCookie {
+		return cookieMalformed, nil
+	}
+
+	client := b[:clientCookieLength]
+	if len(b) == clientCookieLength {
+		return cookieClient, client
+	}
+	if !h.options.validCookie(client, b[clientCookieLength:], ip, time.Now()) {
+		return cookieInvalid, client
+	}
+	return cookieValid, client
+}
+
+// Name implements the Handler interface.
+func (h *ednsHandler) Name() string { return ednsHandlerName }
+
+type ednsWriter struct {
+	dns.ResponseWriter
+	options *ednsOptions
+
+	udpSize uint16
+	do      bool
+	size    int
+	// the option of the query, the client cookie once it is checked
+	cookie string
+	client []byte
+	ip     net.IP
+	// padding block of the query, 0 means it does not ask for padding or its zone is not padded
+	block int
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *ednsWriter) WriteMsg(res *dns.Msg) error {
+	if w.client == nil && w.block == 0 {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	opt := res.IsEdns0()
+	if opt == nil {
+		opt = &dns.OPT{Hdr: dns.RR_Header{Name: ".", Rrtype: dns.TypeOPT}}
+		opt.SetUDPSize(w.udpSize)
+		if w.do {
+			opt.SetDo()
+		}
+		res.Extra = append(res.Extra, opt)
+	}
+
+	// the options of the query are echoed by the answers of the other plugins
+	options := make([]dns.EDNS0, 0, len(opt.Option)+2)
+	for _, o := range opt.Option {
+		if c := o.Option(); c != dns.EDNS0COOKIE && c != dns.EDNS0PADDING {
+			options = append(options, o)
+		}
+	}
+	if w.client != nil {
+		cookie := append(append([]byte{}, w.client...), w.options.serverCookie(w.client, w.ip, time.Now())...)
+		options = append(options, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: hex.EncodeToString(cookie)})
+	}
+	opt.Option = options
+
+	if w.block > 0 {
+		w.pad(res, opt)
+	}
+
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// The padding is counted without compression as the answers are scrubbed without it, a response
+// is padded up to the size of the client at most.
+func (w *ednsWriter) pad(res *dns.Msg, opt *dns.OPT) {
+	compress := res.Compress
+	res.Compress = false
+	l := res.Len() + paddingHeader
+	res.Compress = compress
+
+	n := (w.block - l%w.block) % w.block
+	if l+n > w.size {
+		n = w.size - l
+	}
+	if n < 0 {
+		return
+	}
+	opt.Option = append(opt.Option, &dns.EDNS0_PADDING{Padding: make([]byte, n)})
+}
@@ -9,8 +9,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rancher/rdns-server/codec"
 	"github.com/rancher/rdns-server/coredns/plugin"
 	"github.com/rancher/rdns-server/coredns/plugin/rdns/msg"
+	"github.com/rancher/rdns-server/util"
 
 	"github.com/coredns/coredns/plugin/pkg/fall"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
@@ -35,7 +37,20 @@ type ETCD struct {
 	PathPrefix    string
 	Upstream      *upstream.Upstream
 	Client        *etcdcv3.Client
-	WildcardBound int8 // Calculate the boundary of WildcardDNS
+	ReadClient    *etcdcv3.Client // Serves the lookups when it is set, the lookups which fail are retried on Client
+	WildcardBound int8            // Calculate the boundary of WildcardDNS
+	Shards        int             // Hashed shard count of the key layout, 0 means not sharded
+	Minimal       bool            // Answers only carry the records which are asked for, negative answers keep the SOA
+	Usage         bool            // Answered queries are counted by domain for the usage tiers
+
+	stale   *staleCache   // Last known records served while etcd is unreachable, nil means disabled
+	policy  *answerPolicy // Ordering of the answered addresses, nil means they are not ordered
+	acl     *acl          // Queries which are refused, nil means all queries are answered
+	edns    *ednsOptions  // Cookies and padding of the answers, nil means neither is answered
+	preload *preloadCache // Records read at startup which answer the first lookups, nil means disabled
+	watch   *watchCache   // Lookups answered from memory until etcd watches a change, nil means disabled
+	// Zones whose names without records are answered with the records of their closest parent, nil means none
+	fallback plugin.Zones
 
 	endpoints []string // Stored here as well, to aid in testing.
 }
@@ -68,7 +83,34 @@ func (e *ETCD) IsNameError(err error) bool {
 
 // Records looks up records in etcd. If exact is true, it will lookup just this
 // name. This is used when find matches when completing SRV lookups for instance.
+// When stale serving is enabled and etcd fails, the last known records are returned.
 func (e *ETCD) Records(ctx context.Context, state request.Request, exact bool) ([]msg.Service, error) {
+	services, err := e.records(ctx, state, exact)
+	if err == nil && e.policy != nil {
+		e.policy.remember(state.Name(), services)
+	}
+	if e.stale == nil {
+		return services, err
+	}
+
+	key := staleKey(state.Name(), state.QType(), exact)
+	switch err {
+	case nil:
+		e.stale.put(key, services)
+	case errKeyNotFound:
+		e.stale.delete(key)
+	default:
+		if s, ok := e.stale.get(key); ok {
+			log.Warningf("serving stale records of %s: %v", state.Name(), err)
+			staleAnswers.Inc()
+			return s, nil
+		}
+	}
+
+	return services, err
+}
+
+func (e *ETCD) records(ctx context.Context, state request.Request, exact bool) ([]msg.Service, error) {
 	name := state.Name()
 	qType := state.QType()
 
@@ -83,14 +125,21 @@ func (e *ETCD) Records(ctx context.Context, state request.Request, exact bool) (
 		}
 	}
 
-	if e.WildcardBound > 0 && qType != dns.TypeTXT {
+	if qType != dns.TypeTXT {
+		name = e.closestName(ctx, name)
+	}
+
+	bound := e.wildcardBound(name)
+	if bound > 0 && qType != dns.TypeTXT {
 		temp := dns.SplitDomainName(name)
-		if int8(len(temp)) > e.WildcardBound && !e.pathExist(ctx, temp) {
-			start := int8(len(temp)) - e.WildcardBound
+		if int8(len(temp)) > bound && !e.pathExist(ctx, temp) {
+			start := int8(len(temp)) - bound
 			name = fmt.Sprintf("*.%s", strings.Join(temp[start:], "."))
 		}
 	}
 
+	name = e.shardName(name)
+
 	path, star := msg.PathWithWildcard(name, e.PathPrefix)
 	r, err := e.get(ctx, path, !exact)
 	if err != nil {
@@ -98,7 +147,7 @@ func (e *ETCD) Records(ctx context.Context, state request.Request, exact bool) (
 	}
 	segments := strings.Split(msg.Path(name, e.PathPrefix), "/")
 
-	kvs := e.filterKvs(r.Kvs, segments, qType)
+	kvs := e.filterKvs(r.Kvs, segments, qType, bound)
 
 	return e.loopNodes(kvs, segments, star, state.QType())
 }
@@ -110,13 +159,13 @@ func (e *ETCD) get(ctx context.Context, path string, recursive bool) (*etcdcv3.G
 		if !strings.HasSuffix(path, "/") {
 			path = path + "/"
 		}
-		r, err := e.Client.Get(ctx, path, etcdcv3.WithPrefix())
+		r, err := e.lookup(ctx, path, true)
 		if err != nil {
 			return nil, err
 		}
 		if r.Count == 0 {
 			path = strings.TrimSuffix(path, "/")
-			r, err = e.Client.Get(ctx, path)
+			r, err = e.lookup(ctx, path, false)
 			if err != nil {
 				return nil, err
 			}
@@ -127,7 +176,7 @@ func (e *ETCD) get(ctx context.Context, path string, recursive bool) (*etcdcv3.G
 		return r, nil
 	}
 
-	r, err := e.Client.Get(ctx, path)
+	r, err := e.lookup(ctx, path, false)
 	if err != nil {
 		return nil, err
 	}
@@ -137,8 +186,34 @@ func (e *ETCD) get(ctx context.Context, path string, recursive bool) (*etcdcv3.G
 	return r, nil
 }
 
+// lookup answers the key from the preloaded records when they have it, then from the watch cache
+// when it is enabled, otherwise from etcd.
+func (e *ETCD) lookup(ctx context.Context, key string, prefix bool) (*etcdcv3.GetResponse, error) {
+	if e.preload != nil {
+		if r, ok := e.preload.get(key, prefix); ok {
+			return r, nil
+		}
+	}
+	if e.watch != nil {
+		return e.watch.lookup(key, prefix, func() (*etcdcv3.GetResponse, error) {
+			return e.readKey(ctx, key, prefix)
+		})
+	}
+	return e.readKey(ctx, key, prefix)
+}
+
+func (e *ETCD) readKey(ctx context.Context, key string, prefix bool) (*etcdcv3.GetResponse, error) {
+	if prefix {
+		return e.read(ctx, key, etcdcv3.WithPrefix())
+	}
+	return e.read(ctx, key)
+}
+
+// Drained hosts are left out, unless every host of the answer is drained.
 func (e *ETCD) loopNodes(kv []*mvccpb.KeyValue, nameParts []string, star bool, qType uint16) (sx []msg.Service, err error) {
 	bx := make(map[msg.Service]struct{})
+	var drained []msg.Service
+	now := time.Now().Unix()
 Nodes:
 	for _, n := range kv {
 		if star {
@@ -158,7 +233,7 @@ Nodes:
 			}
 		}
 		serv := new(msg.Service)
-		if err := json.Unmarshal(n.Value, serv); err != nil {
+		if err := unmarshalService(n.Value, serv); err != nil {
 			return nil, fmt.Errorf("%s: %s", n.Key, err.Error())
 		}
 		serv.Key = string(n.Key)
@@ -172,13 +247,39 @@ Nodes:
 			serv.Priority = priority
 		}
 
-		if shouldInclude(serv, qType) {
-			sx = append(sx, *serv)
+		if !shouldInclude(serv, qType) {
+			continue
+		}
+		if serv.Drained > now {
+			drained = append(drained, *serv)
+			continue
 		}
+		sx = append(sx, *serv)
+	}
+	if len(sx) == 0 && len(drained) > 0 {
+		return drained, nil
 	}
 	return sx, nil
 }
 
+// unmarshalService decodes a stored value into a service. Values written with the
+// protobuf encoding only carry the host, text, ttl, weight and drain of the service.
+func unmarshalService(b []byte, serv *msg.Service) error {
+	if codec.IsJSON(b) {
+		return json.Unmarshal(b, serv)
+	}
+	r, err := codec.Decode(b)
+	if err != nil {
+		return err
+	}
+	serv.Host = r.Host
+	serv.Text = r.Text
+	serv.TTL = r.TTL
+	serv.Weight = int(r.Weight)
+	serv.Drained = r.Drained
+	return nil
+}
+
 // TTL returns the smaller of the etcd TTL and the service's
 // TTL. If neither of these are set (have a zero value), a default is used.
 func (e *ETCD) TTL(kv *mvccpb.KeyValue, serv *msg.Service) uint32 {
@@ -210,15 +311,15 @@ func shouldInclude(serv *msg.Service, qType uint16) bool {
 }
 
 // filterKvs returns kvs which not contain sub domain records.
-func (e *ETCD) filterKvs(kvs []*mvccpb.KeyValue, segments []string, qType uint16) []*mvccpb.KeyValue {
-	if qType == dns.TypeA {
+func (e *ETCD) filterKvs(kvs []*mvccpb.KeyValue, segments []string, qType uint16, bound int8) []*mvccpb.KeyValue {
+	if qType == dns.TypeA || qType == dns.TypeAAAA {
 		result := make([]*mvccpb.KeyValue, 0)
 		for _, v := range kvs {
 			ss := strings.Split(string(v.Key), "/")
 			s := segments[len(segments)-1:][0]
 			p := `^\d{1,3}_\d{1,3}_\d{1,3}_\d{1,3}$`
 			m, _ := regexp.MatchString(p, s)
-			if s != "*" && m && e.WildcardBound == (int8(len(segments))-3) {
+			if s != "*" && m && bound == (int8(len(segments))-3-e.shardDepth()) {
 				continue
 			}
 			if s != "*" && len(ss)-len(segments) == 1 || s == "*" && len(ss)-(len(segments)-1) == 1 {
@@ -234,9 +335,9 @@ func (e *ETCD) pathExist(ctx context.Context, ss []string) bool {
 	ctx, cancel := context.WithTimeout(ctx, etcdTimeout)
 	defer cancel()
 
-	path, _ := msg.PathWithWildcard(strings.Join(ss, "."), e.PathPrefix)
+	path, _ := msg.PathWithWildcard(e.shardName(strings.Join(ss, ".")), e.PathPrefix)
 
-	r, err := e.Client.Get(ctx, path, etcdcv3.WithPrefix())
+	r, err := e.lookup(ctx, path, true)
 	if err != nil {
 		return false
 	}
@@ -246,3 +347,31 @@ func (e *ETCD) pathExist(ctx context.Context, ss []string) bool {
 	}
 	return false
 }
+
+// wildcardBound returns the wildcard boundary of the zone of the name, the boundary is set for the
+// first zone and the zones with more or less labels shift it by the difference.
+func (e *ETCD) wildcardBound(name string) int8 {
+	zone := plugin.Zones(e.Zones).Matches(name)
+	if e.WildcardBound <= 0 || zone == "" || len(e.Zones) == 0 {
+		return e.WildcardBound
+	}
+	return e.WildcardBound + int8(dns.CountLabel(zone)-dns.CountLabel(e.Zones[0]))
+}
+
+// shardName inserts the shard label of the name's slug, so the name maps to the
+// sharded key layout written by the rdns backend.
+func (e *ETCD) shardName(name string) string {
+	zone := plugin.Zones(e.Zones).Matches(name)
+	if zone == "" {
+		return name
+	}
+	return util.ShardFqdn(name, zone, e.Shards)
+}
+
+// shardDepth returns the number of key segments added by the shard layout.
+func (e *ETCD) shardDepth() int8 {
+	if e.Shards > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,29 @@
+package rdns
+
+import (
+	"context"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// closestName gets the name whose records answer a name of a fallback zone, it is the name itself
+// or its closest parent which has keys, down to the slug below the zone. So foo.x1.qrn7oq.lb.rancher.cloud.
+// is answered with the hosts of the sub domain x1.qrn7oq.lb.rancher.cloud. instead of the wildcard of
+// the domain. The owner of the answered records is still the name which is asked for.
+func (e *ETCD) closestName(ctx context.Context, name string) string {
+	zone := e.fallback.Matches(name)
+	if zone == "" {
+		return name
+	}
+
+	labels := dns.SplitDomainName(name)
+	// the slug is the shortest parent, the zone itself has no records
+	shortest := dns.CountLabel(zone) + 1
+	for i := 0; len(labels)-i >= shortest; i++ {
+		if e.pathExist(ctx, labels[i:]) {
+			return dns.Fqdn(strings.Join(labels[i:], "."))
+		}
+	}
+	return name
+}
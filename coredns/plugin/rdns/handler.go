@@ -15,6 +15,15 @@ func (e *ETCD) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 	state := request.Request{W: w, Req: r}
 
 	zone := plugin.Zones(e.Zones).Matches(state.Name())
+	if e.acl != nil {
+		if reason := e.acl.refuse(zone, state); reason != "" {
+			refusedQueries.WithLabelValues(reason).Inc()
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeRefused)
+			w.WriteMsg(m)
+			return dns.RcodeRefused, nil
+		}
+	}
 	if zone == "" {
 		return plugin.NextOrFailure(ctx, e.Name(), e.Next, w, r)
 	}
@@ -39,6 +48,12 @@ func (e *ETCD) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 		records, extra, err = plugin.MX(ctx, e, zone, state, opt)
 	case dns.TypeSRV:
 		records, extra, err = plugin.SRV(ctx, e, zone, state, opt)
+	case dns.TypeCAA:
+		records, err = e.CAA(ctx, state)
+		if err == nil && len(records) == 0 {
+			// names without CAA records are told apart like other NODATA answers
+			_, err = plugin.A(ctx, e, zone, state, nil, opt)
+		}
 	case dns.TypeSOA:
 		records, err = plugin.SOA(ctx, e, zone, state, opt)
 	case dns.TypeNS:
@@ -62,6 +77,10 @@ func (e *ETCD) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 		return plugin.BackendError(ctx, e, zone, dns.RcodeServerFailure, state, err, opt)
 	}
 
+	if len(records) > 0 {
+		// the records of restricted domains are dropped before the answer, a name left without records is NODATA
+		records = e.restrict(ctx, zone, state.Name(), records)
+	}
 	if len(records) == 0 {
 		return plugin.BackendError(ctx, e, zone, dns.RcodeSuccess, state, err, opt)
 	}
@@ -70,7 +89,10 @@ func (e *ETCD) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 	m.SetReply(r)
 	m.Authoritative = true
 	m.Answer = append(m.Answer, records...)
-	m.Extra = append(m.Extra, extra...)
+	if !e.Minimal {
+		// the additional records of MX, SRV and NS answers are only hints, the resolver looks them up itself
+		m.Extra = append(m.Extra, extra...)
+	}
 
 	w.WriteMsg(m)
 	return dns.RcodeSuccess, nil
@@ -0,0 +1,100 @@
+package rdns
+
+import (
+	"sort"
+
+	"github.com/rancher/rdns-server/health"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// limitRandom answers a random subset of the addresses
+	limitRandom = "random"
+	// limitLatency answers the addresses of the hosts with the lowest latency of the health checks
+	limitLatency = "latency"
+)
+
+var limits = map[string]bool{
+	limitRandom:  true,
+	limitLatency: true,
+}
+
+var truncatedAnswers = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rdns",
+	Name:      "truncated_answers_total",
+	Help:      "Counter of answers whose addresses were cut down to the answer limit of their zone.",
+})
+
+// answerLimit caps the A and AAAA records of an answer, the addresses which are kept are chosen by
+// the strategy before the policy orders them.
+type answerLimit struct {
+	max      int
+	strategy string
+}
+
+func (p *answerPolicy) setLimit(l answerLimit, zones []string) {
+	for _, z := range zones {
+		if _, ok := p.limits[z]; !ok {
+			p.limitNames = append(p.limitNames, z)
+		}
+		p.limits[z] = l
+	}
+}
+
+// Used to get the limit of the longest zone which matches the name
+func (p *answerPolicy) getLimit(name string) (answerLimit, bool) {
+	l, ok := p.limits[p.limitNames.Matches(name)]
+	return l, ok
+}
+
+// truncate keeps max of the addresses, they are in the order they came in so the policy still
+// orders the ones which are kept.
+func (p *answerPolicy) truncate(l answerLimit, address []dns.RR) []dns.RR {
+	if len(address) <= l.max {
+		return address
+	}
+	truncatedAnswers.Inc()
+
+	picked := make([]int, len(address))
+	for i := range picked {
+		picked[i] = i
+	}
+
+	switch l.strategy {
+	case limitLatency:
+		sort.SliceStable(picked, func(i, j int) bool {
+			return latencyRank(address[picked[i]]) < latencyRank(address[picked[j]])
+		})
+	default:
+		p.randomMu.Lock()
+		p.random.Shuffle(len(picked), func(i, j int) { picked[i], picked[j] = picked[j], picked[i] })
+		p.randomMu.Unlock()
+	}
+
+	picked = picked[:l.max]
+	sort.Ints(picked)
+
+	kept := make([]dns.RR, 0, l.max)
+	for _, i := range picked {
+		kept = append(kept, address[i])
+	}
+	return kept
+}
+
+// Used to rank an address by the last health check of its host, the hosts which are up come first
+// by their latency, then the hosts which are not checked, then the hosts which are down
+// e.g. up in 12ms => 12, not checked => 1<<62, down => 1<<63 - 1
+func latencyRank(r dns.RR) int64 {
+	h, ok := health.Host(addressOf(r))
+	switch {
+	case !ok:
+		return 1 << 62
+	case !h.Up:
+		return 1<<63 - 1
+	}
+	return h.Latency
+}
@@ -5,6 +5,8 @@ import (
 	"net"
 	"strings"
 
+	"github.com/rancher/rdns-server/dnsutil"
+
 	"github.com/miekg/dns"
 )
 
@@ -19,6 +21,7 @@ type Service struct {
 	Text     string `json:"text,omitempty"`
 	Mail     bool   `json:"mail,omitempty"` // Be an MX record. Priority becomes Preference.
 	TTL      uint32 `json:"ttl,omitempty"`
+	Drained  int64  `json:"drained,omitempty"` // Unix time the host is left out of the answers until
 
 	// When a SRV record with a "Host: IP-address" is added, we synthesize
 	// a srv.Target domain name.  Normally we convert the full Key where
@@ -75,7 +78,7 @@ func (s *Service) NewCNAME(name string, target string) *dns.CNAME {
 
 // NewTXT returns a new TXT record based on the Service.
 func (s *Service) NewTXT(name string) *dns.TXT {
-	return &dns.TXT{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: s.TTL}, Txt: split255(s.Text)}
+	return dnsutil.TXT(name, s.Text, s.TTL)
 }
 
 // NewPTR returns a new PTR record based on the Service.
@@ -141,27 +144,6 @@ func Group(sx []Service) []Service {
 	return ret
 }
 
-// Split255 splits a string into 255 byte chunks.
-func split255(s string) []string {
-	if len(s) < 255 {
-		return []string{s}
-	}
-	sx := []string{}
-	p, i := 0, 255
-	for {
-		if i <= len(s) {
-			sx = append(sx, s[p:i])
-		} else {
-			sx = append(sx, s[p:])
-			break
-
-		}
-		p, i = p+255, i+255
-	}
-
-	return sx
-}
-
 // targetStrip strips "targetstrip" labels from the left side of the fully qualified name.
 func targetStrip(name string, targetStrip int) string {
 	offset, end := 0, false
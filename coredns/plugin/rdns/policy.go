@@ -0,0 +1,225 @@
+package rdns
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rancher/rdns-server/coredns/plugin"
+	"github.com/rancher/rdns-server/coredns/plugin/rdns/msg"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// policyRoundRobin rotates the first address of every answer
+	policyRoundRobin = "round_robin"
+	// policyRandom shuffles the addresses of every answer
+	policyRandom = "random"
+	// policyFixed answers the addresses in the order they are stored
+	policyFixed = "fixed"
+	// policyWeighted shuffles the addresses, heavier records are more likely to come first
+	policyWeighted = "weighted"
+
+	policyHandlerName = "rdns_policy"
+	defaultWeight     = 1
+	maxWeightEntries  = 100000
+)
+
+var policies = map[string]bool{
+	policyRoundRobin: true,
+	policyRandom:     true,
+	policyFixed:      true,
+	policyWeighted:   true,
+}
+
+// answerPolicy orders and limits the A and AAAA records of the answers of every zone. It is installed
+// in front of the plugin chain so the answers served by the cache plugin are ordered too.
+type answerPolicy struct {
+	zones    map[string]string
+	names    plugin.Zones
+	counter  uint64
+	weights  *weightCache
+	random   *rand.Rand
+	randomMu sync.Mutex
+
+	// limits cap the addresses of the answers by zone, a zone may have a limit without a policy
+	limits     map[string]answerLimit
+	limitNames plugin.Zones
+}
+
+func newAnswerPolicy() *answerPolicy {
+	return &answerPolicy{
+		zones:   make(map[string]string),
+		limits:  make(map[string]answerLimit),
+		weights: &weightCache{entries: make(map[string]int)},
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (p *answerPolicy) set(policy string, zones []string) {
+	for _, z := range zones {
+		if _, ok := p.zones[z]; !ok {
+			p.names = append(p.names, z)
+		}
+		p.zones[z] = policy
+	}
+}
+
+// Used to get the policy of the longest zone which matches the name, empty means not ordered
+func (p *answerPolicy) get(name string) string {
+	return p.zones[p.names.Matches(name)]
+}
+
+// remember keeps the weights of the services answered for a name, the addresses of
+// cached answers are ordered with them as well.
+func (p *answerPolicy) remember(name string, services []msg.Service) {
+	if p.get(name) != policyWeighted {
+		return
+	}
+	for _, s := range services {
+		if ip := net.ParseIP(s.Host); ip != nil {
+			p.weights.put(weightKey(name, ip.String()), s.Weight)
+		}
+	}
+}
+
+func (p *answerPolicy) order(name string, in []dns.RR) []dns.RR {
+	policy := p.get(name)
+	limit, limited := p.getLimit(name)
+	if (policy == "" || policy == policyFixed) && !limited {
+		return in
+	}
+
+	others := make([]dns.RR, 0, len(in))
+	address := make([]dns.RR, 0, len(in))
+	for _, r := range in {
+		switch r.Header().Rrtype {
+		case dns.TypeA, dns.TypeAAAA:
+			address = append(address, r)
+		default:
+			others = append(others, r)
+		}
+	}
+	if limited {
+		address = p.truncate(limit, address)
+	}
+	if len(address) < 2 {
+		return append(others, address...)
+	}
+
+	switch policy {
+	case policyRoundRobin:
+		n := int(atomic.AddUint64(&p.counter, 1) % uint64(len(address)))
+		address = append(append(make([]dns.RR, 0, len(address)), address[n:]...), address[:n]...)
+	case policyRandom:
+		p.randomMu.Lock()
+		p.random.Shuffle(len(address), func(i, j int) { address[i], address[j] = address[j], address[i] })
+		p.randomMu.Unlock()
+	case policyWeighted:
+		address = p.weighted(address)
+	}
+
+	return append(others, address...)
+}
+
+// weighted orders the records by a random key of u^(1/weight), which draws them one by one
+// with a probability proportional to their weight.
+func (p *answerPolicy) weighted(records []dns.RR) []dns.RR {
+	keys := make(map[dns.RR]float64, len(records))
+	p.randomMu.Lock()
+	for _, r := range records {
+		w := p.weights.get(weightKey(r.Header().Name, addressOf(r)))
+		keys[r] = math.Pow(p.random.Float64(), 1/float64(w))
+	}
+	p.randomMu.Unlock()
+
+	sort.SliceStable(records, func(i, j int) bool { return keys[records[i]] > keys[records[j]] })
+	return records
+}
+
+type policyHandler struct {
+	Next   plugin.Handler
+	policy *answerPolicy
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (h *policyHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return plugin.NextOrFailure(ctx, h.Name(), h.Next, &policyWriter{ResponseWriter: w, policy: h.policy}, r)
+}
+
+// Name implements the Handler interface.
+func (h *policyHandler) Name() string { return policyHandlerName }
+
+type policyWriter struct {
+	dns.ResponseWriter
+	policy *answerPolicy
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *policyWriter) WriteMsg(res *dns.Msg) error {
+	if res.Rcode != dns.RcodeSuccess || len(res.Question) == 0 {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+	if q := res.Question[0].Qtype; q == dns.TypeAXFR || q == dns.TypeIXFR {
+		return w.ResponseWriter.WriteMsg(res)
+	}
+
+	res.Answer = w.policy.order(res.Question[0].Name, res.Answer)
+	return w.ResponseWriter.WriteMsg(res)
+}
+
+// weightCache keeps the weights of the recently answered addresses, it is bounded like the stale cache.
+type weightCache struct {
+	mu      sync.RWMutex
+	entries map[string]int
+}
+
+func (c *weightCache) put(key string, weight int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if weight <= 0 {
+		delete(c.entries, key)
+		return
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxWeightEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = weight
+}
+
+func (c *weightCache) get(key string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if w, ok := c.entries[key]; ok {
+		return w
+	}
+	return defaultWeight
+}
+
+// Used to get the weight cache key of an address
+// e.g. x1.qrn7oq.lb.rancher.cloud., 1.1.1.1 => x1.qrn7oq.lb.rancher.cloud./1.1.1.1
+func weightKey(name, host string) string {
+	return strings.ToLower(dns.Fqdn(name)) + "/" + host
+}
+
+func addressOf(r dns.RR) string {
+	switch rr := r.(type) {
+	case *dns.A:
+		return rr.A.String()
+	case *dns.AAAA:
+		return rr.AAAA.String()
+	}
+	return ""
+}
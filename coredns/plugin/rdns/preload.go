@@ -0,0 +1,149 @@
+package rdns
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/coredns/plugin"
+	"github.com/rancher/rdns-server/lifecycle"
+
+	etcdcv3 "github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultPreloadKeys   = 100000 // bounded like the stale cache
+	defaultPreloadWindow = 30 * time.Second
+	preloadPage          = 1000
+	preloadProgress      = 10 // pages between the progress logs
+)
+
+var preloadAnswers = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rdns",
+	Name:      "preload_answers_total",
+	Help:      "Counter of lookups answered from the records preloaded at startup.",
+})
+
+// preloadCache keeps the records which are read from etcd at startup, the lookups are answered
+// from them for a window after they are loaded, so the first queries of a restarted instance do
+// not all wait for etcd until the cache plugin has their answers.
+type preloadCache struct {
+	maxKeys int
+	window  time.Duration
+
+	mu     sync.RWMutex
+	keys   []string // sorted
+	kvs    []*mvccpb.KeyValue
+	loaded time.Time
+	ready  bool
+}
+
+func newPreloadCache(maxKeys int, window time.Duration) *preloadCache {
+	return &preloadCache{maxKeys: maxKeys, window: window}
+}
+
+// Ready implements the ready.Readiness interface, the plugin is not ready until the records are
+// preloaded or the preload failed.
+func (e *ETCD) Ready() bool {
+	if e.preload == nil {
+		return true
+	}
+	e.preload.mu.RLock()
+	defer e.preload.mu.RUnlock()
+	return e.preload.ready
+}
+
+// Used to read the records below the path prefix page by page, the keys after maxKeys are left out
+// so the memory stays bounded. The instance is marked as warming up until the load ends.
+func (e *ETCD) load(c *preloadCache) {
+	done := lifecycle.Warming("coredns")
+	defer done()
+
+	start := time.Now()
+	prefix := "/" + strings.Trim(e.PathPrefix, "/") + "/"
+	end := etcdcv3.GetPrefixRangeEnd(prefix)
+	kvs := make([]*mvccpb.KeyValue, 0)
+	key, pages, more := prefix, 0, true
+
+	for more && len(kvs) < c.maxKeys {
+		limit := c.maxKeys - len(kvs)
+		if limit > preloadPage {
+			limit = preloadPage
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), etcdTimeout)
+		r, err := e.read(ctx, key, etcdcv3.WithRange(end), etcdcv3.WithLimit(int64(limit)))
+		cancel()
+		if err != nil {
+			log.Errorf("failed to preload the records of %s, the lookups are answered from etcd: %v", prefix, err)
+			c.set(nil)
+			return
+		}
+		kvs = append(kvs, r.Kvs...)
+		more = r.More && len(r.Kvs) > 0
+		if len(r.Kvs) > 0 {
+			key = string(r.Kvs[len(r.Kvs)-1].Key) + "\x00"
+		}
+
+		if pages++; pages%preloadProgress == 0 {
+			log.Infof("preloaded %d records of %s", len(kvs), prefix)
+		}
+	}
+
+	if more {
+		log.Warningf("preloaded the first %d records of %s only, the others are answered from etcd", len(kvs), prefix)
+	}
+	log.Infof("preloaded %d records of %s in %s", len(kvs), prefix, time.Since(start))
+	c.set(kvs)
+}
+
+// set stores the records which are read in the key order of etcd and marks the cache as ready.
+func (c *preloadCache) set(kvs []*mvccpb.KeyValue) {
+	keys := make([]string, len(kvs))
+	for i, kv := range kvs {
+		keys[i] = string(kv.Key)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys, c.kvs, c.loaded, c.ready = keys, kvs, time.Now(), true
+
+	// the cache plugin has the answers of the names which are asked for by then
+	time.AfterFunc(c.window, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.keys, c.kvs = nil, nil
+	})
+}
+
+// get returns the preloaded records of a key, or of the keys below it when prefix is true. Only
+// the keys which are preloaded are answered, the others may be written after the preload and are
+// left to etcd.
+func (c *preloadCache) get(key string, prefix bool) (*etcdcv3.GetResponse, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.keys) == 0 || time.Since(c.loaded) > c.window {
+		return nil, false
+	}
+
+	i := sort.SearchStrings(c.keys, key)
+	j := i
+	if prefix {
+		for j < len(c.keys) && strings.HasPrefix(c.keys[j], key) {
+			j++
+		}
+	} else if j < len(c.keys) && c.keys[j] == key {
+		j++
+	}
+	if j == i {
+		return nil, false
+	}
+
+	preloadAnswers.Inc()
+	return &etcdcv3.GetResponse{Kvs: c.kvs[i:j], Count: int64(j - i)}, true
+}
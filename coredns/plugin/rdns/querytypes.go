@@ -0,0 +1,90 @@
+package rdns
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/rancher/rdns-server/coredns/plugin"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var restrictedQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rdns",
+	Name:      "restricted_queries_total",
+	Help:      "Counter of queries whose records were left out by the query types of their domain, by type.",
+}, []string{"type"})
+
+// QueryTypes looks up the query types the domain of a name is restricted to, which the rdns
+// backend keeps in one key of the domain. nil means the domain answers every type.
+// e.g. lb.rancher.cloud., _acme-challenge.abc.lb.rancher.cloud. => abc.lb.rancher.cloud., [TXT]
+func (e *ETCD) QueryTypes(ctx context.Context, zone, name string) (string, []string, error) {
+	domain := domainOf(zone, name)
+	if domain == "" {
+		return "", nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	defer cancel()
+
+	r, err := e.read(ctx, util.QueryTypesKey(e.PathPrefix, domain))
+	if err != nil {
+		return domain, nil, err
+	}
+	if r.Count == 0 {
+		return domain, nil, nil
+	}
+
+	var types []string
+	if err := json.Unmarshal(r.Kvs[0].Value, &types); err != nil {
+		return domain, nil, err
+	}
+	return domain, types, nil
+}
+
+// Used to leave out the records of the domain and the names below it whose types the domain does
+// not answer, the records of other names (e.g. the target of a CNAME) are kept. The writes of these
+// records are refused by the api already, so a lookup which fails answers the records as they are.
+func (e *ETCD) restrict(ctx context.Context, zone, name string, records []dns.RR) []dns.RR {
+	domain, types, err := e.QueryTypes(ctx, zone, name)
+	if err != nil {
+		log.Debugf("failed to look up the query types of %s: %v", name, err)
+		return records
+	}
+	if len(types) == 0 {
+		return records
+	}
+
+	allowed := make([]dns.RR, 0, len(records))
+	for _, rr := range records {
+		h := rr.Header()
+		t := dns.TypeToString[h.Rrtype]
+		if within(strings.ToLower(h.Name), domain) && !model.AllowsQueryType(types, t) {
+			restrictedQueries.WithLabelValues(t).Inc()
+			continue
+		}
+		allowed = append(allowed, rr)
+	}
+	return allowed
+}
+
+// Used to get the domain of a name, which is the name of the label right below the zone
+// e.g. lb.rancher.cloud., x1.abc.lb.rancher.cloud. => abc.lb.rancher.cloud.
+// e.g. lb.rancher.cloud., lb.rancher.cloud. => ""
+func domainOf(zone, name string) string {
+	name, zone = strings.ToLower(name), strings.ToLower(zone)
+	if name == zone || !strings.HasSuffix(name, "."+zone) {
+		return ""
+	}
+	labels := dns.SplitDomainName(strings.TrimSuffix(name, "."+zone))
+	return labels[len(labels)-1] + "." + zone
+}
+
+func within(name, domain string) bool {
+	return name == domain || strings.HasSuffix(name, "."+domain)
+}
@@ -0,0 +1,41 @@
+package rdns
+
+import (
+	"context"
+
+	"github.com/rancher/rdns-server/coredns/plugin"
+
+	etcdcv3 "github.com/coreos/etcd/clientv3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// a lookup only waits this long for the read endpoints, so the rest of etcdTimeout is left for
+// the retry on the write endpoints
+const readTimeout = etcdTimeout / 2
+
+var readFallbacks = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rdns",
+	Name:      "read_fallbacks_total",
+	Help:      "Counter of lookups which failed on the read endpoints and were retried on the endpoints.",
+})
+
+// Used to get a key from the read endpoints, which answer from their own copy without asking the
+// leader. A lookup which fails on them is retried on the endpoints, so queries are only left
+// unanswered when both are down.
+func (e *ETCD) read(ctx context.Context, key string, opts ...etcdcv3.OpOption) (*etcdcv3.GetResponse, error) {
+	if e.ReadClient == nil {
+		return e.Client.Get(ctx, key, opts...)
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, readTimeout)
+	r, err := e.ReadClient.Get(rctx, key, append(opts, etcdcv3.WithSerializable())...)
+	cancel()
+	if err == nil {
+		return r, nil
+	}
+
+	readFallbacks.Inc()
+	log.Debugf("retry %s on the endpoints: %v", key, err)
+	return e.Client.Get(ctx, key, opts...)
+}
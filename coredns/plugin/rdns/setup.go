@@ -1,16 +1,21 @@
 package rdns
 
 import (
+	"context"
 	"crypto/tls"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 	mwtls "github.com/coredns/coredns/plugin/pkg/tls"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
 	etcdcv3 "github.com/coreos/etcd/clientv3"
 	"github.com/mholt/caddy"
+	"github.com/miekg/dns"
 )
 
 var log = clog.NewWithPlugin("rdns")
@@ -21,22 +26,67 @@ func Setup(c *caddy.Controller) error {
 		return plugin.Error("rdns", err)
 	}
 
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, staleAnswers, refusedQueries, cookieQueries, restrictedQueries, readFallbacks, preloadAnswers, truncatedAnswers,
+			watchAnswers, watchInvalidations)
+		return nil
+	})
+
+	if e.preload != nil {
+		// the plugin and the instance are not ready until the load ends, it runs while the servers start
+		go e.load(e.preload)
+	}
+
+	if e.watch != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		go e.watchRecords(ctx, e.watch)
+		c.OnShutdown(func() error {
+			cancel()
+			return nil
+		})
+	}
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		e.Next = next
 		return e
 	})
 
+	if e.Usage {
+		// answers of the cache plugin are counted too
+		cfg := dnsserver.GetConfig(c)
+		cfg.Plugin = append([]plugin.Plugin{func(next plugin.Handler) plugin.Handler {
+			return &usageHandler{Next: next, zones: e.Zones}
+		}}, cfg.Plugin...)
+	}
+
+	if e.policy != nil {
+		// the policy handler goes first, answers of the plugins in front of rdns (e.g. cache) are ordered too
+		cfg := dnsserver.GetConfig(c)
+		cfg.Plugin = append([]plugin.Plugin{func(next plugin.Handler) plugin.Handler {
+			return &policyHandler{Next: next, policy: e.policy}
+		}}, cfg.Plugin...)
+	}
+
+	if e.edns != nil {
+		// the edns handler goes before the policy handler, the padding is counted once the answers are ordered
+		cfg := dnsserver.GetConfig(c)
+		cfg.Plugin = append([]plugin.Plugin{func(next plugin.Handler) plugin.Handler {
+			return &ednsHandler{Next: next, options: e.edns}
+		}}, cfg.Plugin...)
+	}
+
 	return nil
 }
 
 func etcdParse(c *caddy.Controller) (*ETCD, error) {
 	etc := ETCD{PathPrefix: "skydns"}
 	var (
-		tlsConfig *tls.Config
-		err       error
-		endpoints = []string{defaultEndpoint}
-		username  string
-		password  string
+		tlsConfig     *tls.Config
+		err           error
+		endpoints     = []string{defaultEndpoint}
+		readEndpoints []string
+		username      string
+		password      string
 	)
 	for c.Next() {
 		etc.Zones = c.RemainingArgs()
@@ -67,6 +117,12 @@ func etcdParse(c *caddy.Controller) (*ETCD, error) {
 					return &ETCD{}, c.ArgErr()
 				}
 				endpoints = args
+			case "read_endpoint":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return &ETCD{}, c.ArgErr()
+				}
+				readEndpoints = args
 			case "upstream":
 				// check args != 0 and error in the future
 				c.RemainingArgs() // clear buffer
@@ -98,6 +154,228 @@ func etcdParse(c *caddy.Controller) (*ETCD, error) {
 					return &ETCD{}, c.Errf("wildcardbound value can not be negative: %d", v)
 				}
 				etc.WildcardBound = int8(v)
+			case "fallback":
+				// fallback [ZONES...]
+				zones := c.RemainingArgs()
+				if len(zones) == 0 {
+					zones = etc.Zones
+				}
+				for _, z := range zones {
+					etc.fallback = append(etc.fallback, plugin.Host(z).Normalize())
+				}
+			case "shards":
+				if !c.NextArg() {
+					return &ETCD{}, c.ArgErr()
+				}
+				v, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return &ETCD{}, err
+				}
+				if v < 0 {
+					return &ETCD{}, c.Errf("shards value can not be negative: %d", v)
+				}
+				etc.Shards = v
+			case "refuse_any":
+				if etc.acl == nil {
+					etc.acl = &acl{}
+				}
+				zones := c.RemainingArgs()
+				if len(zones) == 0 {
+					zones = etc.Zones
+				}
+				etc.acl.anyZones = append(etc.acl.anyZones, normalizeZones(zones)...)
+			case "acl":
+				// acl allow|deny NET[,NET...] [ZONES...]
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return &ETCD{}, c.ArgErr()
+				}
+				if args[0] != aclAllow && args[0] != aclDeny {
+					return &ETCD{}, c.Errf("unknown acl action '%s'", args[0])
+				}
+				nets, err := parseNets(args[1:2])
+				if err != nil {
+					return &ETCD{}, err
+				}
+				zones := etc.Zones
+				if len(args) > 2 {
+					zones = args[2:]
+				}
+				if etc.acl == nil {
+					etc.acl = &acl{}
+				}
+				etc.acl.rules = append(etc.acl.rules, aclRule{allow: args[0] == aclAllow, nets: nets, zones: normalizeZones(zones)})
+			case "recursion":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return &ETCD{}, c.ArgErr()
+				}
+				nets, err := parseNets(args)
+				if err != nil {
+					return &ETCD{}, err
+				}
+				if etc.acl == nil {
+					etc.acl = &acl{}
+				}
+				etc.acl.recursion = append(etc.acl.recursion, nets...)
+			case "minimal":
+				if c.NextArg() {
+					return &ETCD{}, c.ArgErr()
+				}
+				etc.Minimal = true
+			case "usage":
+				if c.NextArg() {
+					return &ETCD{}, c.ArgErr()
+				}
+				etc.Usage = true
+			case "policy":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return &ETCD{}, c.ArgErr()
+				}
+				if !policies[args[0]] {
+					return &ETCD{}, c.Errf("unknown policy '%s'", args[0])
+				}
+				zones := etc.Zones
+				if len(args) > 1 {
+					zones = args[1:]
+					for i, str := range zones {
+						zones[i] = plugin.Host(str).Normalize()
+					}
+				}
+				if etc.policy == nil {
+					etc.policy = newAnswerPolicy()
+				}
+				etc.policy.set(args[0], zones)
+			case "limit":
+				// limit MAX [random|latency] [ZONES...]
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return &ETCD{}, c.ArgErr()
+				}
+				v, err := strconv.Atoi(args[0])
+				if err != nil {
+					return &ETCD{}, err
+				}
+				if v <= 0 {
+					return &ETCD{}, c.Errf("answer limit must be positive: %d", v)
+				}
+				l := answerLimit{max: v, strategy: limitRandom}
+				if len(args) > 1 {
+					if !limits[args[1]] {
+						return &ETCD{}, c.Errf("unknown limit strategy '%s'", args[1])
+					}
+					l.strategy = args[1]
+				}
+				zones := etc.Zones
+				if len(args) > 2 {
+					zones = normalizeZones(args[2:])
+				}
+				if etc.policy == nil {
+					etc.policy = newAnswerPolicy()
+				}
+				etc.policy.setLimit(l, zones)
+			case "cookie":
+				args := c.RemainingArgs()
+				if len(args) > 1 {
+					return &ETCD{}, c.ArgErr()
+				}
+				secret, err := cookieSecret(strings.Join(args, ""))
+				if err != nil {
+					return &ETCD{}, err
+				}
+				if etc.edns == nil {
+					etc.edns = &ednsOptions{}
+				}
+				etc.edns.secret = secret
+			case "padding":
+				// padding BLOCK [ZONES...]
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return &ETCD{}, c.ArgErr()
+				}
+				v, err := strconv.Atoi(args[0])
+				if err != nil {
+					return &ETCD{}, err
+				}
+				if v <= 0 || v > dns.MaxMsgSize {
+					return &ETCD{}, c.Errf("padding block must be within 1 and %d: %d", dns.MaxMsgSize, v)
+				}
+				zones := etc.Zones
+				if len(args) > 1 {
+					zones = normalizeZones(args[1:])
+				}
+				if etc.edns == nil {
+					etc.edns = &ednsOptions{}
+				}
+				etc.edns.setPadding(v, zones)
+			case "preload":
+				// preload [MAX_KEYS] [WINDOW]
+				args := c.RemainingArgs()
+				if len(args) > 2 {
+					return &ETCD{}, c.ArgErr()
+				}
+				p := newPreloadCache(defaultPreloadKeys, defaultPreloadWindow)
+				if len(args) > 0 {
+					v, err := strconv.Atoi(args[0])
+					if err != nil {
+						return &ETCD{}, err
+					}
+					if v <= 0 {
+						return &ETCD{}, c.Errf("preload keys must be positive: %d", v)
+					}
+					p.maxKeys = v
+				}
+				if len(args) > 1 {
+					d, err := time.ParseDuration(args[1])
+					if err != nil {
+						return &ETCD{}, err
+					}
+					if d <= 0 {
+						return &ETCD{}, c.Errf("preload window must be positive: %s", d)
+					}
+					p.window = d
+				}
+				etc.preload = p
+			case "watch_cache":
+				// watch_cache [MAX_KEYS] [MAX_AGE]
+				args := c.RemainingArgs()
+				if len(args) > 2 {
+					return &ETCD{}, c.ArgErr()
+				}
+				w := newWatchCache(defaultWatchKeys, defaultWatchMaxAge)
+				if len(args) > 0 {
+					v, err := strconv.Atoi(args[0])
+					if err != nil {
+						return &ETCD{}, err
+					}
+					if v <= 0 {
+						return &ETCD{}, c.Errf("watch cache keys must be positive: %d", v)
+					}
+					w.maxKeys = v
+				}
+				if len(args) > 1 {
+					d, err := time.ParseDuration(args[1])
+					if err != nil {
+						return &ETCD{}, err
+					}
+					if d <= 0 {
+						return &ETCD{}, c.Errf("watch cache max age must be positive: %s", d)
+					}
+					w.maxAge = d
+				}
+				etc.watch = w
+			case "stale":
+				if !c.NextArg() {
+					return &ETCD{}, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return &ETCD{}, err
+				}
+				if d > 0 {
+					etc.stale = newStaleCache(d)
+				}
 			default:
 				if c.Val() != "}" {
 					return &ETCD{}, c.Errf("unknown property '%s'", c.Val())
@@ -110,6 +388,11 @@ func etcdParse(c *caddy.Controller) (*ETCD, error) {
 		}
 		etc.Client = client
 		etc.endpoints = endpoints
+		if len(readEndpoints) > 0 {
+			if etc.ReadClient, err = newEtcdClient(readEndpoints, tlsConfig, username, password); err != nil {
+				return &ETCD{}, err
+			}
+		}
 
 		return &etc, nil
 	}
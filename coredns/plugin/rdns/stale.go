@@ -0,0 +1,91 @@
+package rdns
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/coredns/plugin/rdns/msg"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	staleTTL        = 30 // RFC 8767 recommends 30 seconds for stale answers
+	maxStaleEntries = 100000
+)
+
+var staleAnswers = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Subsystem: "rdns",
+	Name:      "stale_answers_total",
+	Help:      "Counter of answers served from the last known records while etcd is unreachable.",
+})
+
+type staleEntry struct {
+	services []msg.Service
+	updated  time.Time
+}
+
+// staleCache keeps the last known records of every lookup, they are served
+// for a bounded period when etcd can not be reached.
+type staleCache struct {
+	maxAge time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]*staleEntry
+}
+
+func newStaleCache(maxAge time.Duration) *staleCache {
+	return &staleCache{
+		maxAge:  maxAge,
+		entries: make(map[string]*staleEntry),
+	}
+}
+
+func (c *staleCache) put(key string, services []msg.Service) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxStaleEntries {
+		// drop an arbitrary entry to keep the cache bounded
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = &staleEntry{services: services, updated: time.Now()}
+}
+
+func (c *staleCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// get returns a copy of the last known records with the stale ttl.
+func (c *staleCache) get(key string) ([]msg.Service, bool) {
+	c.mu.RLock()
+	e, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Since(e.updated) > c.maxAge {
+		return nil, false
+	}
+
+	services := make([]msg.Service, len(e.services))
+	copy(services, e.services)
+	for i := range services {
+		services[i].TTL = staleTTL
+	}
+
+	return services, true
+}
+
+// Used to get the stale cache key of a lookup
+// e.g. x1.qrn7oq.lb.rancher.cloud. A exact => x1.qrn7oq.lb.rancher.cloud./1/true
+func staleKey(name string, qType uint16, exact bool) string {
+	return name + "/" + strconv.Itoa(int(qType)) + "/" + strconv.FormatBool(exact)
+}
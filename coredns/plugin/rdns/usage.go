@@ -0,0 +1,41 @@
+package rdns
+
+import (
+	"context"
+
+	"github.com/rancher/rdns-server/coredns/plugin"
+	"github.com/rancher/rdns-server/usage"
+
+	"github.com/miekg/dns"
+)
+
+const usageHandlerName = "rdns_usage"
+
+type usageHandler struct {
+	Next  plugin.Handler
+	zones plugin.Zones
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (h *usageHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return plugin.NextOrFailure(ctx, h.Name(), h.Next, &usageWriter{ResponseWriter: w, zones: h.zones}, r)
+}
+
+// Name implements the Handler interface.
+func (h *usageHandler) Name() string { return usageHandlerName }
+
+type usageWriter struct {
+	dns.ResponseWriter
+	zones plugin.Zones
+}
+
+// WriteMsg implements the dns.ResponseWriter interface, only answers with records are counted.
+func (w *usageWriter) WriteMsg(res *dns.Msg) error {
+	if res.Rcode == dns.RcodeSuccess && len(res.Answer) > 0 && len(res.Question) > 0 {
+		name := res.Question[0].Name
+		if zone := w.zones.Matches(name); zone != "" {
+			usage.Record(name, zone)
+		}
+	}
+	return w.ResponseWriter.WriteMsg(res)
+}
@@ -0,0 +1,202 @@
+package rdns
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/coredns/plugin"
+
+	etcdcv3 "github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultWatchKeys   = 100000 // bounded like the stale cache
+	defaultWatchMaxAge = 10 * time.Minute
+	watchRetry         = 5 * time.Second
+)
+
+var errWatchClosed = errors.New("watch channel closed")
+
+var (
+	watchAnswers = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "rdns",
+		Name:      "watch_cache_answers_total",
+		Help:      "Counter of lookups answered from the records cached until etcd watches a change of them.",
+	})
+	watchInvalidations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "rdns",
+		Name:      "watch_cache_invalidations_total",
+		Help:      "Counter of the changes watched in etcd which dropped the cached records of their keys.",
+	})
+)
+
+type watchEntry struct {
+	r      *etcdcv3.GetResponse
+	stored time.Time
+}
+
+// watchCache keeps the lookups of the records, they are answered from memory until a change of
+// one of their keys is watched in etcd. Nothing is answered or stored while the watch is down,
+// the lookups go to etcd then.
+type watchCache struct {
+	maxKeys int
+	maxAge  time.Duration // a bound for changes which are missed, e.g. of a watch which lags
+
+	mu       sync.RWMutex
+	live     bool
+	gen      uint64 // bumped by every change and by every start of the watch
+	revision int64  // revision the watch started at or of its last change
+	keys     map[string]*watchEntry
+	prefixes map[string]*watchEntry
+}
+
+func newWatchCache(maxKeys int, maxAge time.Duration) *watchCache {
+	return &watchCache{
+		maxKeys:  maxKeys,
+		maxAge:   maxAge,
+		keys:     make(map[string]*watchEntry),
+		prefixes: make(map[string]*watchEntry),
+	}
+}
+
+// Used to watch the keys below the path prefix until ctx is done, a watch which fails is started
+// again after watchRetry and the records which are cached meanwhile are dropped.
+func (e *ETCD) watchRecords(ctx context.Context, c *watchCache) {
+	prefix := "/" + strings.Trim(e.PathPrefix, "/") + "/"
+	for {
+		err := e.watchOnce(ctx, c, prefix)
+		c.stop()
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warningf("the watch of %s stopped, the lookups are answered from etcd until it is back: %v", prefix, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetry):
+		}
+	}
+}
+
+func (e *ETCD) watchOnce(ctx context.Context, c *watchCache, prefix string) error {
+	// without a leader the watch misses the changes, so it is canceled
+	wctx, cancel := context.WithCancel(etcdcv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	for wr := range e.Client.Watch(wctx, prefix, etcdcv3.WithPrefix(), etcdcv3.WithCreatedNotify()) {
+		if err := wr.Err(); err != nil {
+			return err
+		}
+		if wr.Created {
+			c.start(wr.Header.Revision)
+			log.Infof("watching %s from revision %d, the lookups are cached until their keys change", prefix, wr.Header.Revision)
+			continue
+		}
+		c.invalidate(wr.Events, wr.Header.Revision)
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errWatchClosed
+}
+
+// lookup answers a lookup from the cache, the lookups which are not cached are read and stored
+// unless something changed while they were read.
+func (c *watchCache) lookup(key string, prefix bool, read func() (*etcdcv3.GetResponse, error)) (*etcdcv3.GetResponse, error) {
+	c.mu.RLock()
+	live, gen, revision := c.live, c.gen, c.revision
+	entry, ok := c.entries(prefix)[key]
+	c.mu.RUnlock()
+
+	if live && ok && time.Since(entry.stored) <= c.maxAge {
+		watchAnswers.Inc()
+		return entry.r, nil
+	}
+
+	r, err := read()
+	if err != nil || !live {
+		return r, err
+	}
+	// a lagging read endpoint answers a revision before the watch, it is not stored
+	if r.Header != nil && r.Header.Revision < revision {
+		return r, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.live || c.gen != gen {
+		return r, nil
+	}
+
+	entries := c.entries(prefix)
+	if _, ok := entries[key]; !ok && len(c.keys)+len(c.prefixes) >= c.maxKeys {
+		// drop an arbitrary entry to keep the cache bounded
+		for k := range entries {
+			delete(entries, k)
+			break
+		}
+	}
+	entries[key] = &watchEntry{r: &etcdcv3.GetResponse{Kvs: r.Kvs, Count: r.Count}, stored: time.Now()}
+
+	return r, nil
+}
+
+func (c *watchCache) entries(prefix bool) map[string]*watchEntry {
+	if prefix {
+		return c.prefixes
+	}
+	return c.keys
+}
+
+// invalidate drops the lookups of the keys which changed, the key itself and every prefix of it
+// e.g. /skydns/cloud/rancher/lb/qrn7oq/1_1_1_1 => /skydns/cloud/rancher/lb/qrn7oq/1_1_1_1, /skydns/cloud/rancher/lb/qrn7oq/, ...
+func (c *watchCache) invalidate(events []*etcdcv3.Event, revision int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, ev := range events {
+		c.drop(ev.Kv)
+	}
+	c.gen++
+	c.revision = revision
+
+	watchInvalidations.Add(float64(len(events)))
+}
+
+func (c *watchCache) drop(kv *mvccpb.KeyValue) {
+	k := string(kv.Key)
+	delete(c.keys, k)
+	for i := 1; i <= len(k); i++ {
+		delete(c.prefixes, k[:i])
+	}
+}
+
+// start marks the watch as running from a revision, the lookups are cached from then on.
+func (c *watchCache) start(revision int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.live = true
+	c.gen++
+	c.revision = revision
+}
+
+// stop drops the cached lookups, the changes are not watched until the watch starts again.
+func (c *watchCache) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.live = false
+	c.gen++
+	c.keys = make(map[string]*watchEntry)
+	c.prefixes = make(map[string]*watchEntry)
+}
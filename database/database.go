@@ -21,7 +21,13 @@ type Database interface {
 	QueryTokenCount() (int64, error)
 	QueryToken(name string) (*model.Token, error)
 	QueryExpiredTokens(*time.Time) ([]*model.Token, error)
+	ListTokens(lastID, limit int64) ([]*model.Token, error)
+	SearchTokens(f *model.TokenFilter) ([]*model.Token, error)
+	SetIndexes(tid int64, name string, values []string) error
+	ListIndexes(tid int64) (map[string][]string, error)
+	QueryHostTokens(host string) ([]*model.Token, error)
 	RenewToken(name string) (int64, int64, error)
+	UpdateToken(token, name string) error
 	DeleteToken(prefix string) error
 	MigrateToken(token, name string, expiration int64) error
 	InsertA(*model.RecordA) (int64, error)
@@ -41,7 +47,36 @@ type Database interface {
 	UpdateTXT(*model.RecordTXT) (int64, error)
 	QueryTXT(name string) (*model.RecordTXT, error)
 	QueryExpiredTXTs(id int64) ([]*model.RecordTXT, error)
+	QueryExpiredChallengeTXTs(*time.Time) ([]*model.RecordTXT, error)
 	DeleteTXT(name string) error
+	SetTXTOrder(*model.TXTOrder) error
+	QueryTXTOrder(name, order string) (*model.TXTOrder, error)
+	ListTXTOrders(name string) ([]*model.TXTOrder, error)
+	DeleteTXTOrder(name, order string) error
+	SetSuspension(*model.SuspendedDomain) error
+	ListSuspensions() ([]*model.SuspendedDomain, error)
+	DeleteSuspension(name string) error
+	InsertWebhook(*model.DomainWebhook) error
+	ListWebhooks(name string) ([]*model.DomainWebhook, error)
+	DeleteWebhook(name, id string) error
+	SetScopedToken(*model.DomainScopedToken) error
+	QueryScopedToken(name, scope string) (*model.DomainScopedToken, error)
+	DeleteScopedToken(name, scope string) error
+	InsertAPIKey(*model.DatabaseAPIKey) error
+	QueryAPIKey(id string) (*model.DatabaseAPIKey, error)
+	ListAPIKeys() ([]*model.DatabaseAPIKey, error)
+	DeleteAPIKey(id string) error
+	InsertJob(*model.DatabaseJob) error
+	QueryVisibleJobs(kind string, visibleOn int64, limit int) ([]*model.DatabaseJob, error)
+	ClaimJob(id string, visibleOn, until int64) (bool, error)
+	DeleteJob(id string) error
+	QueryOperationalState(key string) (*model.DatabaseOperationalState, error)
+	ListOperationalStates() ([]*model.DatabaseOperationalState, error)
+	InsertOperationalState(*model.DatabaseOperationalState) (bool, error)
+	UpdateOperationalState(s *model.DatabaseOperationalState, version int64) (bool, error)
+	InsertDataMigration(*model.DataMigration) error
+	ListDataMigrations() ([]*model.DataMigration, error)
+	Ping() error
 	Close() error
 }
 
@@ -0,0 +1,360 @@
+package dynamodb
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	DriverName = "dynamodb"
+
+	// the items of every table of the mysql database are kept in one table, they are told apart
+	// by the prefix of their partition key
+	prefixToken      = "token#"
+	prefixTokenValue = "tokenvalue#"
+	prefixFrozen     = "frozen#"
+	prefixA          = "a#"
+	prefixAID        = "aid#"
+	prefixSubA       = "suba#"
+	prefixHost       = "host#"
+	prefixIndex      = "index#"
+	prefixCNAME      = "cname#"
+	prefixTXT        = "txt#"
+	prefixTXTOrder   = "txtorder#"
+	prefixWebhook    = "webhook#"
+	prefixScoped     = "scoped#"
+	prefixJob        = "job#"
+	prefixCounter    = "counter#"
+
+	keySuspension = "suspension"
+	keyAPIKey     = "apikey"
+	keyState      = "state"
+	keyMigration  = "migration"
+
+	// kinds group the items which are listed in order of their ord or created_on
+	kindToken     = "token"
+	kindFrozen    = "frozen"
+	kindA         = "a"
+	kindChallenge = "challenge"
+
+	// tidIndex finds the items of a token, kindIndex lists the items of a kind by ord and
+	// createdIndex lists them by creation
+	tidIndex     = "tid-index"
+	kindIndex    = "kind-index"
+	createdIndex = "created-index"
+
+	// ttlAttribute is the unix time the table expires an item at
+	ttlAttribute = "expires_at"
+)
+
+var attributeNames = regexp.MustCompile(`#[a-z_]+`)
+
+// Options configures the table and how long its items live, a domain lives Lease after it is
+// renewed and a prefix is frozen for Frozen. The table expires them Grace later, so the purger
+// deletes the route53 records of a domain before its items are gone.
+type Options struct {
+	Table    string
+	Endpoint string
+	Lease    time.Duration
+	Frozen   time.Duration
+	Grace    time.Duration
+}
+
+// Database keeps the tokens and records of the route53 backend in a DynamoDB table instead of
+// mysql, the table is created with its indexes and time to live when it does not exist.
+type Database struct {
+	Svc   dynamodbiface.DynamoDBAPI
+	Table string
+
+	lease  time.Duration
+	frozen time.Duration
+	grace  time.Duration
+}
+
+// item is an item of the table, the attributes of the item are the columns of its mysql row
+type item struct {
+	PK         string `dynamodbav:"pk"`
+	SK         string `dynamodbav:"sk"`
+	Kind       string `dynamodbav:"kind,omitempty"`
+	Ord        int64  `dynamodbav:"ord,omitempty"`
+	TID        int64  `dynamodbav:"tid,omitempty"`
+	ID         int64  `dynamodbav:"id,omitempty"`
+	Key        string `dynamodbav:"key,omitempty"`
+	PID        int64  `dynamodbav:"pid,omitempty"`
+	Fqdn       string `dynamodbav:"fqdn,omitempty"`
+	Token      string `dynamodbav:"token,omitempty"`
+	Prefix     string `dynamodbav:"prefix,omitempty"`
+	Type       int    `dynamodbav:"type,omitempty"`
+	Content    string `dynamodbav:"content,omitempty"`
+	Host       string `dynamodbav:"host,omitempty"`
+	Name       string `dynamodbav:"name,omitempty"`
+	Value      string `dynamodbav:"value,omitempty"`
+	OrderID    string `dynamodbav:"order_id,omitempty"`
+	Reason     string `dynamodbav:"reason,omitempty"`
+	URL        string `dynamodbav:"url,omitempty"`
+	Secret     string `dynamodbav:"secret,omitempty"`
+	Events     string `dynamodbav:"events,omitempty"`
+	Scope      string `dynamodbav:"scope,omitempty"`
+	Tenant     string `dynamodbav:"tenant,omitempty"`
+	RootDomain string `dynamodbav:"root_domain,omitempty"`
+	Hash       string `dynamodbav:"hash,omitempty"`
+	JobKind    string `dynamodbav:"job_kind,omitempty"`
+	Payload    string `dynamodbav:"payload,omitempty"`
+	Attempts   int    `dynamodbav:"attempts,omitempty"`
+	VisibleOn  int64  `dynamodbav:"visible_on,omitempty"`
+	Version    int64  `dynamodbav:"version,omitempty"`
+	CreatedOn  int64  `dynamodbav:"created_on,omitempty"`
+	UpdatedOn  int64  `dynamodbav:"updated_on,omitempty"`
+	AppliedOn  int64  `dynamodbav:"applied_on,omitempty"`
+	ExpiresAt  int64  `dynamodbav:"expires_at,omitempty"`
+}
+
+func NewDatabase(opts *Options) (*Database, error) {
+	s, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &aws.Config{
+		Credentials: credentials.NewEnvCredentials(),
+		MaxRetries:  aws.Int(3),
+	}
+	if opts.Endpoint != "" {
+		cfg.Endpoint = aws.String(opts.Endpoint)
+	}
+
+	d := &Database{
+		Svc:    dynamodb.New(s, cfg),
+		Table:  opts.Table,
+		lease:  opts.Lease,
+		frozen: opts.Frozen,
+		grace:  opts.Grace,
+	}
+	if err := d.ensureTable(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Database) Ping() error {
+	_, err := d.Svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(d.Table)})
+	return err
+}
+
+// Close does nothing, the client of the table has no connections to close.
+func (d *Database) Close() error {
+	return nil
+}
+
+// Used to get an item by its keys, nil means it does not exist
+func (d *Database) get(pk, sk string) (*item, error) {
+	out, err := d.Svc.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(d.Table),
+		Key:            keys(pk, sk),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Item) == 0 {
+		return nil, nil
+	}
+
+	it := &item{}
+	return it, dynamodbattribute.UnmarshalMap(out.Item, it)
+}
+
+// Used to put an item, a condition which fails returns an error
+func (d *Database) put(it *item, condition string) error {
+	in, err := d.putInput(it, condition)
+	if err != nil {
+		return err
+	}
+	_, err = d.Svc.PutItem(in)
+	return err
+}
+
+// Used to put an item which must not exist, false means it exists
+func (d *Database) insert(it *item) (bool, error) {
+	err := d.put(it, "attribute_not_exists(#pk)")
+	if isConditionFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (d *Database) putInput(it *item, condition string) (*dynamodb.PutItemInput, error) {
+	av, err := dynamodbattribute.MarshalMap(it)
+	if err != nil {
+		return nil, err
+	}
+	in := &dynamodb.PutItemInput{TableName: aws.String(d.Table), Item: av}
+	if condition != "" {
+		in.ConditionExpression = aws.String(condition)
+		in.ExpressionAttributeNames = names(condition)
+	}
+	return in, nil
+}
+
+func (d *Database) delete(pk, sk string) error {
+	_, err := d.Svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(d.Table),
+		Key:       keys(pk, sk),
+	})
+	return err
+}
+
+// Used to update the attributes of an item, the item is returned as it is after the update
+func (d *Database) update(pk, sk, update, condition string, values map[string]interface{}) (*item, error) {
+	av, err := dynamodbattribute.MarshalMap(values)
+	if err != nil {
+		return nil, err
+	}
+	in := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(d.Table),
+		Key:                       keys(pk, sk),
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeNames:  names(update + " " + condition),
+		ExpressionAttributeValues: av,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	}
+	if condition != "" {
+		in.ConditionExpression = aws.String(condition)
+	}
+
+	out, err := d.Svc.UpdateItem(in)
+	if err != nil {
+		return nil, err
+	}
+	it := &item{}
+	return it, dynamodbattribute.UnmarshalMap(out.Attributes, it)
+}
+
+// Used to query the items of a key condition page by page, f returns false to stop
+func (d *Database) query(index, condition string, values map[string]interface{}, forward bool, f func(it *item) bool) error {
+	av, err := dynamodbattribute.MarshalMap(values)
+	if err != nil {
+		return err
+	}
+	in := &dynamodb.QueryInput{
+		TableName:                 aws.String(d.Table),
+		KeyConditionExpression:    aws.String(condition),
+		ExpressionAttributeNames:  names(condition),
+		ExpressionAttributeValues: av,
+		ScanIndexForward:          aws.Bool(forward),
+	}
+	if index != "" {
+		in.IndexName = aws.String(index)
+	} else {
+		in.ConsistentRead = aws.Bool(true)
+	}
+
+	for {
+		out, err := d.Svc.Query(in)
+		if err != nil {
+			return err
+		}
+		for _, av := range out.Items {
+			it := &item{}
+			if err := dynamodbattribute.UnmarshalMap(av, it); err != nil {
+				return err
+			}
+			if !f(it) {
+				return nil
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		in.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// Used to query all items of a partition key in the order of their sort key
+func (d *Database) partition(pk string) ([]*item, error) {
+	items := make([]*item, 0)
+	err := d.query("", "#pk = :pk", map[string]interface{}{":pk": pk}, true, func(it *item) bool {
+		items = append(items, it)
+		return true
+	})
+	return items, err
+}
+
+// Used to query the items of a token whose partition keys start with the prefix
+func (d *Database) tokenItems(tid int64, prefix string) ([]*item, error) {
+	items := make([]*item, 0)
+	condition, values := "#tid = :tid", map[string]interface{}{":tid": tid}
+	if prefix != "" {
+		condition += " AND begins_with(#pk, :prefix)"
+		values[":prefix"] = prefix
+	}
+	err := d.query(tidIndex, condition, values, true, func(it *item) bool {
+		items = append(items, it)
+		return true
+	})
+	return items, err
+}
+
+// Used to get the next id of a counter, the ids of a counter start at 1
+func (d *Database) next(counter string) (int64, error) {
+	it, err := d.update(prefixCounter+counter, counter, "ADD #id :one", "", map[string]interface{}{":one": 1})
+	if err != nil {
+		return 0, err
+	}
+	return it.ID, nil
+}
+
+// Used to get the unix time the items of a token expire at, a token which is not found in the
+// index yet is just created and expires a lease from now
+func (d *Database) expiresAt(tid int64) (int64, error) {
+	t, err := d.tokenByID(tid)
+	if err != nil {
+		return 0, err
+	}
+	if t == nil {
+		return d.tokenExpiresAt(time.Now().UnixNano()), nil
+	}
+	return d.tokenExpiresAt(t.CreatedOn), nil
+}
+
+// Used to get the unix time the items of a token which is renewed at a unix nano time expire at
+func (d *Database) tokenExpiresAt(renewed int64) int64 {
+	return time.Unix(0, renewed).Add(d.lease + d.grace).Unix()
+}
+
+func keys(pk, sk string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"pk": {S: aws.String(pk)},
+		"sk": {S: aws.String(sk)},
+	}
+}
+
+// Used to get the attribute names of an expression, every attribute is written as #name
+// because many of them are reserved words
+// e.g. #name = :name AND #value = :value => {#name: name, #value: value}
+func names(expression string) map[string]*string {
+	result := make(map[string]*string)
+	for _, n := range attributeNames.FindAllString(expression, -1) {
+		result[n] = aws.String(n[1:])
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+func isConditionFailed(err error) bool {
+	if e, ok := err.(awserr.Error); ok {
+		return e.Code() == dynamodb.ErrCodeConditionalCheckFailedException ||
+			e.Code() == dynamodb.ErrCodeTransactionCanceledException
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+package dynamodb
+
+import "github.com/pkg/errors"
+
+const (
+	errExist = "%s %s exists"
+)
+
+// Used to return the error of an item which must not exist, as the unique keys of mysql do
+func errDuplicate(what, key string) error {
+	return errors.Errorf(errExist, what, key)
+}
@@ -0,0 +1,251 @@
+package dynamodb
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/rancher/rdns-server/model"
+)
+
+const (
+	sortKeyJob = "job"
+
+	counterSuspension = "suspension"
+)
+
+func (d *Database) SetSuspension(s *model.SuspendedDomain) error {
+	id, err := d.next(counterSuspension)
+	if err != nil {
+		return err
+	}
+
+	// a domain which is suspended again only changes its reason
+	_, err = d.update(keySuspension, s.Fqdn,
+		"SET #fqdn = :fqdn, #reason = :reason, #created_on = if_not_exists(#created_on, :created), #id = if_not_exists(#id, :id)", "",
+		map[string]interface{}{":fqdn": s.Fqdn, ":reason": s.Reason, ":created": s.CreatedOn, ":id": id})
+	return err
+}
+
+func (d *Database) ListSuspensions() ([]*model.SuspendedDomain, error) {
+	result := make([]*model.SuspendedDomain, 0)
+	items, err := d.partition(keySuspension)
+	for _, it := range items {
+		result = append(result, &model.SuspendedDomain{ID: it.ID, Fqdn: it.Fqdn, Reason: it.Reason, CreatedOn: it.CreatedOn})
+	}
+	return result, err
+}
+
+func (d *Database) DeleteSuspension(name string) error {
+	return d.delete(keySuspension, name)
+}
+
+func (d *Database) InsertWebhook(w *model.DomainWebhook) error {
+	ok, err := d.insert(&item{
+		PK:        prefixWebhook + w.Fqdn,
+		SK:        w.ID,
+		Key:       w.ID,
+		TID:       w.TID,
+		Fqdn:      w.Fqdn,
+		URL:       w.URL,
+		Secret:    w.Secret,
+		Events:    w.Events,
+		CreatedOn: w.CreatedOn,
+	})
+	if err == nil && !ok {
+		return errDuplicate("webhook", w.ID)
+	}
+	return err
+}
+
+func (d *Database) ListWebhooks(name string) ([]*model.DomainWebhook, error) {
+	result := make([]*model.DomainWebhook, 0)
+	items, err := d.partition(prefixWebhook + name)
+	for _, it := range items {
+		result = append(result, &model.DomainWebhook{ID: it.Key, Fqdn: it.Fqdn, URL: it.URL, Secret: it.Secret, Events: it.Events, CreatedOn: it.CreatedOn, TID: it.TID})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].CreatedOn < result[j].CreatedOn })
+	return result, err
+}
+
+func (d *Database) DeleteWebhook(name, id string) error {
+	return d.delete(prefixWebhook+name, id)
+}
+
+func (d *Database) SetScopedToken(t *model.DomainScopedToken) error {
+	return d.put(&item{
+		PK:        prefixScoped + t.Fqdn,
+		SK:        t.Scope,
+		Scope:     t.Scope,
+		TID:       t.TID,
+		Fqdn:      t.Fqdn,
+		Token:     t.Token,
+		CreatedOn: t.CreatedOn,
+	}, "")
+}
+
+func (d *Database) QueryScopedToken(name, scope string) (*model.DomainScopedToken, error) {
+	it, err := d.get(prefixScoped+name, scope)
+	if err != nil {
+		return nil, err
+	}
+	if it == nil {
+		return &model.DomainScopedToken{}, sql.ErrNoRows
+	}
+	return &model.DomainScopedToken{Fqdn: it.Fqdn, Scope: it.Scope, Token: it.Token, CreatedOn: it.CreatedOn, TID: it.TID}, nil
+}
+
+func (d *Database) DeleteScopedToken(name, scope string) error {
+	return d.delete(prefixScoped+name, scope)
+}
+
+func (d *Database) InsertAPIKey(k *model.DatabaseAPIKey) error {
+	ok, err := d.insert(&item{
+		PK:         keyAPIKey,
+		SK:         k.ID,
+		Key:        k.ID,
+		Name:       k.Name,
+		Tenant:     k.Tenant,
+		RootDomain: k.RootDomain,
+		Hash:       k.Hash,
+		CreatedOn:  k.CreatedOn,
+	})
+	if err == nil && !ok {
+		return errDuplicate("api key", k.ID)
+	}
+	return err
+}
+
+func (d *Database) QueryAPIKey(id string) (*model.DatabaseAPIKey, error) {
+	it, err := d.get(keyAPIKey, id)
+	if err != nil {
+		return nil, err
+	}
+	if it == nil {
+		return &model.DatabaseAPIKey{}, sql.ErrNoRows
+	}
+	return toAPIKey(it), nil
+}
+
+func (d *Database) ListAPIKeys() ([]*model.DatabaseAPIKey, error) {
+	result := make([]*model.DatabaseAPIKey, 0)
+	items, err := d.partition(keyAPIKey)
+	for _, it := range items {
+		result = append(result, toAPIKey(it))
+	}
+	return result, err
+}
+
+func (d *Database) DeleteAPIKey(id string) error {
+	return d.delete(keyAPIKey, id)
+}
+
+func (d *Database) InsertJob(j *model.DatabaseJob) error {
+	ok, err := d.insert(&item{
+		PK:        prefixJob + j.ID,
+		SK:        sortKeyJob,
+		Kind:      prefixJob + j.Kind,
+		Ord:       j.VisibleOn,
+		Key:       j.ID,
+		JobKind:   j.Kind,
+		Payload:   j.Payload,
+		Attempts:  j.Attempts,
+		CreatedOn: j.CreatedOn,
+		VisibleOn: j.VisibleOn,
+	})
+	if err == nil && !ok {
+		return errDuplicate("job", j.ID)
+	}
+	return err
+}
+
+func (d *Database) QueryVisibleJobs(kind string, visibleOn int64, limit int) ([]*model.DatabaseJob, error) {
+	result := make([]*model.DatabaseJob, 0)
+	if limit <= 0 {
+		return result, nil
+	}
+
+	err := d.query(kindIndex, "#kind = :kind AND #ord <= :visible", map[string]interface{}{":kind": prefixJob + kind, ":visible": visibleOn}, true, func(it *item) bool {
+		result = append(result, &model.DatabaseJob{ID: it.Key, Kind: it.JobKind, Payload: it.Payload, Attempts: it.Attempts, CreatedOn: it.CreatedOn, VisibleOn: it.VisibleOn})
+		return len(result) < limit
+	})
+	return result, err
+}
+
+// ClaimJob hides a job until the time, it is not claimed when another worker claimed it since it was queried.
+func (d *Database) ClaimJob(id string, visibleOn, until int64) (bool, error) {
+	_, err := d.update(prefixJob+id, sortKeyJob, "SET #visible_on = :until, #ord = :until ADD #attempts :one", "#visible_on = :visible",
+		map[string]interface{}{":until": until, ":visible": visibleOn, ":one": 1})
+	if isConditionFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (d *Database) DeleteJob(id string) error {
+	return d.delete(prefixJob+id, sortKeyJob)
+}
+
+func (d *Database) QueryOperationalState(key string) (*model.DatabaseOperationalState, error) {
+	it, err := d.get(keyState, key)
+	if err != nil {
+		return nil, err
+	}
+	if it == nil {
+		return &model.DatabaseOperationalState{}, sql.ErrNoRows
+	}
+	return toOperationalState(it), nil
+}
+
+func (d *Database) ListOperationalStates() ([]*model.DatabaseOperationalState, error) {
+	result := make([]*model.DatabaseOperationalState, 0)
+	items, err := d.partition(keyState)
+	for _, it := range items {
+		result = append(result, toOperationalState(it))
+	}
+	return result, err
+}
+
+// InsertOperationalState inserts a state which is never written, false means another writer inserted it first.
+func (d *Database) InsertOperationalState(s *model.DatabaseOperationalState) (bool, error) {
+	return d.insert(&item{
+		PK:        keyState,
+		SK:        s.Key,
+		Key:       s.Key,
+		Value:     s.Value,
+		Version:   s.Version,
+		UpdatedOn: s.UpdatedOn,
+	})
+}
+
+// UpdateOperationalState updates a state which is still of the version, false means another writer changed it.
+func (d *Database) UpdateOperationalState(s *model.DatabaseOperationalState, version int64) (bool, error) {
+	_, err := d.update(keyState, s.Key, "SET #value = :value, #version = :next, #updated_on = :updated", "#version = :version",
+		map[string]interface{}{":value": s.Value, ":next": s.Version, ":updated": s.UpdatedOn, ":version": version})
+	if isConditionFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// InsertDataMigration keeps the time a migration is applied at first, as the mysql database does.
+func (d *Database) InsertDataMigration(m *model.DataMigration) error {
+	_, err := d.insert(&item{PK: keyMigration, SK: m.ID, Key: m.ID, AppliedOn: m.AppliedOn})
+	return err
+}
+
+func (d *Database) ListDataMigrations() ([]*model.DataMigration, error) {
+	result := make([]*model.DataMigration, 0)
+	items, err := d.partition(keyMigration)
+	for _, it := range items {
+		result = append(result, &model.DataMigration{ID: it.Key, AppliedOn: it.AppliedOn})
+	}
+	return result, err
+}
+
+func toAPIKey(it *item) *model.DatabaseAPIKey {
+	return &model.DatabaseAPIKey{ID: it.Key, Name: it.Name, Tenant: it.Tenant, RootDomain: it.RootDomain, Hash: it.Hash, CreatedOn: it.CreatedOn}
+}
+
+func toOperationalState(it *item) *model.DatabaseOperationalState {
+	return &model.DatabaseOperationalState{Key: it.Key, Value: it.Value, Version: it.Version, UpdatedOn: it.UpdatedOn}
+}
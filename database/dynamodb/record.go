@@ -0,0 +1,461 @@
+package dynamodb
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+)
+
+const (
+	sortKeyA     = "a"
+	sortKeySubA  = "suba"
+	sortKeyCNAME = "cname"
+	sortKeyTXT   = "txt"
+
+	counterRecord = "record"
+)
+
+func (d *Database) InsertA(a *model.RecordA) (int64, error) {
+	id, err := d.next(counterRecord)
+	if err != nil {
+		return 0, err
+	}
+	expires, err := d.expiresAt(a.TID)
+	if err != nil {
+		return 0, err
+	}
+
+	ok, err := d.insert(&item{
+		PK:        prefixA + a.Fqdn,
+		SK:        sortKeyA,
+		Kind:      kindA,
+		Ord:       id,
+		ID:        id,
+		TID:       a.TID,
+		Fqdn:      a.Fqdn,
+		Type:      a.Type,
+		Content:   a.Content,
+		CreatedOn: a.CreatedOn,
+		ExpiresAt: expires,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, errDuplicate("A record", a.Fqdn)
+	}
+	if err := d.put(parentItem(id, a.TID, a.Fqdn, expires), ""); err != nil {
+		return 0, err
+	}
+	return id, d.setHosts(a.Fqdn, "", a.Content, a.TID, expires)
+}
+
+func (d *Database) UpdateA(a *model.RecordA) (int64, error) {
+	old, err := d.get(prefixA+a.Fqdn, sortKeyA)
+	if err != nil || old == nil {
+		return 0, err
+	}
+	expires, err := d.expiresAt(a.TID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := d.put(&item{
+		PK:        old.PK,
+		SK:        old.SK,
+		Kind:      kindA,
+		Ord:       old.ID,
+		ID:        old.ID,
+		TID:       a.TID,
+		Fqdn:      a.Fqdn,
+		Type:      a.Type,
+		Content:   a.Content,
+		CreatedOn: a.CreatedOn,
+		UpdatedOn: old.UpdatedOn,
+		ExpiresAt: expires,
+	}, ""); err != nil {
+		return 0, err
+	}
+	if err := d.put(parentItem(old.ID, a.TID, a.Fqdn, expires), ""); err != nil {
+		return 0, err
+	}
+	return old.ID, d.setHosts(a.Fqdn, old.Content, a.Content, a.TID, expires)
+}
+
+func (d *Database) QueryA(name string) (*model.RecordA, error) {
+	it, err := d.get(prefixA+name, sortKeyA)
+	if err != nil || it == nil {
+		return &model.RecordA{}, err
+	}
+	return &model.RecordA{ID: it.ID, Fqdn: it.Fqdn, Type: it.Type, Content: it.Content, CreatedOn: it.CreatedOn, UpdatedOn: nullInt64(it.UpdatedOn), TID: it.TID}, nil
+}
+
+func (d *Database) ListSubA(id int64) ([]*model.SubRecordA, error) {
+	rs := make([]*model.SubRecordA, 0)
+	err := d.query(kindIndex, "#kind = :kind", map[string]interface{}{":kind": subKind(id)}, true, func(it *item) bool {
+		rs = append(rs, toSubA(it))
+		return true
+	})
+	return rs, err
+}
+
+// DeleteA deletes the sub records of the A record as well, as the foreign keys of mysql do.
+func (d *Database) DeleteA(name string) error {
+	old, err := d.get(prefixA+name, sortKeyA)
+	if err != nil || old == nil {
+		return err
+	}
+
+	subs, err := d.ListSubA(old.ID)
+	if err != nil {
+		return err
+	}
+	for _, s := range subs {
+		if err := d.DeleteSubA(s.Fqdn); err != nil {
+			return err
+		}
+	}
+
+	if err := d.setHosts(name, old.Content, "", old.TID, 0); err != nil {
+		return err
+	}
+	if err := d.delete(parentKey(old.ID), sortKeyA); err != nil {
+		return err
+	}
+	return d.delete(old.PK, old.SK)
+}
+
+func (d *Database) InsertSubA(a *model.SubRecordA) (int64, error) {
+	tid, err := d.parentTokenID(a.PID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := d.next(counterRecord)
+	if err != nil {
+		return 0, err
+	}
+	expires, err := d.expiresAt(tid)
+	if err != nil {
+		return 0, err
+	}
+
+	ok, err := d.insert(subItem(a, id, tid, 0, expires))
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, errDuplicate("sub A record", a.Fqdn)
+	}
+	return id, d.setHosts(a.Fqdn, "", a.Content, tid, expires)
+}
+
+func (d *Database) UpdateSubA(a *model.SubRecordA) (int64, error) {
+	old, err := d.get(prefixSubA+a.Fqdn, sortKeySubA)
+	if err != nil || old == nil {
+		return 0, err
+	}
+	tid, err := d.parentTokenID(a.PID)
+	if err != nil {
+		return 0, err
+	}
+	expires, err := d.expiresAt(tid)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := d.put(subItem(a, old.ID, tid, old.UpdatedOn, expires), ""); err != nil {
+		return 0, err
+	}
+	return old.ID, d.setHosts(a.Fqdn, old.Content, a.Content, tid, expires)
+}
+
+func (d *Database) QuerySubA(name string) (*model.SubRecordA, error) {
+	it, err := d.get(prefixSubA+name, sortKeySubA)
+	if err != nil || it == nil {
+		return &model.SubRecordA{}, err
+	}
+	return toSubA(it), nil
+}
+
+func (d *Database) DeleteSubA(name string) error {
+	old, err := d.get(prefixSubA+name, sortKeySubA)
+	if err != nil || old == nil {
+		return err
+	}
+	if err := d.setHosts(name, old.Content, "", old.TID, 0); err != nil {
+		return err
+	}
+	return d.delete(old.PK, old.SK)
+}
+
+func (d *Database) InsertCNAME(c *model.RecordCNAME) (int64, error) {
+	id, err := d.next(counterRecord)
+	if err != nil {
+		return 0, err
+	}
+	it, err := d.recordItem(prefixCNAME+c.Fqdn, sortKeyCNAME, id, c.TID, c.Fqdn, c.Type, c.Content, c.CreatedOn, 0)
+	if err != nil {
+		return 0, err
+	}
+	ok, err := d.insert(it)
+	if err == nil && !ok {
+		return 0, errDuplicate("CNAME record", c.Fqdn)
+	}
+	return id, err
+}
+
+func (d *Database) UpdateCNAME(c *model.RecordCNAME) (int64, error) {
+	old, err := d.get(prefixCNAME+c.Fqdn, sortKeyCNAME)
+	if err != nil || old == nil {
+		return 0, err
+	}
+	it, err := d.recordItem(old.PK, old.SK, old.ID, c.TID, c.Fqdn, c.Type, c.Content, c.CreatedOn, old.UpdatedOn)
+	if err != nil {
+		return 0, err
+	}
+	return old.ID, d.put(it, "")
+}
+
+func (d *Database) QueryCNAME(name string) (*model.RecordCNAME, error) {
+	it, err := d.get(prefixCNAME+name, sortKeyCNAME)
+	if err != nil || it == nil {
+		return &model.RecordCNAME{}, err
+	}
+	return &model.RecordCNAME{ID: it.ID, Fqdn: it.Fqdn, Type: it.Type, Content: it.Content, CreatedOn: it.CreatedOn, UpdatedOn: nullInt64(it.UpdatedOn), TID: it.TID}, nil
+}
+
+func (d *Database) DeleteCNAME(name string) error {
+	return d.delete(prefixCNAME+name, sortKeyCNAME)
+}
+
+func (d *Database) InsertTXT(t *model.RecordTXT) (int64, error) {
+	id, err := d.next(counterRecord)
+	if err != nil {
+		return 0, err
+	}
+	it, err := d.recordItem(prefixTXT+t.Fqdn, sortKeyTXT, id, t.TID, t.Fqdn, t.Type, t.Content, t.CreatedOn, 0)
+	if err != nil {
+		return 0, err
+	}
+	ok, err := d.insert(challengeKind(it))
+	if err == nil && !ok {
+		return 0, errDuplicate("TXT record", t.Fqdn)
+	}
+	return id, err
+}
+
+func (d *Database) UpdateTXT(t *model.RecordTXT) (int64, error) {
+	old, err := d.get(prefixTXT+t.Fqdn, sortKeyTXT)
+	if err != nil || old == nil {
+		return 0, err
+	}
+	it, err := d.recordItem(old.PK, old.SK, old.ID, t.TID, t.Fqdn, t.Type, t.Content, t.CreatedOn, old.UpdatedOn)
+	if err != nil {
+		return 0, err
+	}
+	return old.ID, d.put(challengeKind(it), "")
+}
+
+func (d *Database) QueryTXT(name string) (*model.RecordTXT, error) {
+	it, err := d.get(prefixTXT+name, sortKeyTXT)
+	if err != nil || it == nil {
+		return &model.RecordTXT{}, err
+	}
+	return toTXT(it), nil
+}
+
+func (d *Database) QueryExpiredTXTs(id int64) ([]*model.RecordTXT, error) {
+	result := make([]*model.RecordTXT, 0)
+	items, err := d.tokenItems(id, prefixTXT)
+	for _, it := range items {
+		result = append(result, toTXT(it))
+	}
+	return result, err
+}
+
+func (d *Database) QueryExpiredChallengeTXTs(t *time.Time) ([]*model.RecordTXT, error) {
+	result := make([]*model.RecordTXT, 0)
+	// TXT records keep created_on in seconds
+	items, err := d.created(kindChallenge, t.Unix())
+	for _, it := range items {
+		result = append(result, toTXT(it))
+	}
+	return result, err
+}
+
+// DeleteTXT deletes the order values of the TXT record as well, they are part of it.
+func (d *Database) DeleteTXT(name string) error {
+	orders, err := d.partition(prefixTXTOrder + name)
+	if err != nil {
+		return err
+	}
+	for _, o := range orders {
+		if err := d.delete(o.PK, o.SK); err != nil {
+			return err
+		}
+	}
+	return d.delete(prefixTXT+name, sortKeyTXT)
+}
+
+func (d *Database) SetTXTOrder(t *model.TXTOrder) error {
+	id, err := d.next(counterRecord)
+	if err != nil {
+		return err
+	}
+	expires, err := d.expiresAt(t.TID)
+	if err != nil {
+		return err
+	}
+
+	// an order which is set again keeps its id and token, as ON DUPLICATE KEY UPDATE does
+	_, err = d.update(prefixTXTOrder+t.Fqdn, t.OrderID,
+		"SET #fqdn = :fqdn, #order_id = :order, #content = :content, #created_on = :created, #expires_at = :e, #tid = if_not_exists(#tid, :tid), #id = if_not_exists(#id, :id)", "",
+		map[string]interface{}{":fqdn": t.Fqdn, ":order": t.OrderID, ":content": t.Content, ":created": t.CreatedOn, ":e": expires, ":tid": t.TID, ":id": id})
+	return err
+}
+
+func (d *Database) QueryTXTOrder(name, order string) (*model.TXTOrder, error) {
+	it, err := d.get(prefixTXTOrder+name, order)
+	if err != nil {
+		return &model.TXTOrder{}, err
+	}
+	if it == nil {
+		return &model.TXTOrder{}, sql.ErrNoRows
+	}
+	return toTXTOrder(it), nil
+}
+
+func (d *Database) ListTXTOrders(name string) ([]*model.TXTOrder, error) {
+	result := make([]*model.TXTOrder, 0)
+	items, err := d.partition(prefixTXTOrder + name)
+	for _, it := range items {
+		result = append(result, toTXTOrder(it))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, err
+}
+
+func (d *Database) DeleteTXTOrder(name, order string) error {
+	return d.delete(prefixTXTOrder+name, order)
+}
+
+// Used to rewrite the host index of an A record from its old hosts to its new hosts, the hosts
+// are comma separated and empty new hosts only delete the index
+func (d *Database) setHosts(fqdn, old, hosts string, tid, expires int64) error {
+	keep := make(map[string]bool)
+	for _, h := range strings.Split(hosts, ",") {
+		if h != "" {
+			keep[h] = true
+		}
+	}
+
+	for _, h := range strings.Split(old, ",") {
+		if h == "" || keep[h] {
+			continue
+		}
+		if err := d.delete(prefixHost+h, fqdn); err != nil {
+			return err
+		}
+	}
+	for h := range keep {
+		if err := d.put(&item{PK: prefixHost + h, SK: fqdn, Host: h, Fqdn: fqdn, TID: tid, ExpiresAt: expires}, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Used to get the token id of a sub record from its parent A record, the parent is found by its
+// id without an index because a sub record is written right after its parent
+func (d *Database) parentTokenID(pid int64) (int64, error) {
+	it, err := d.get(parentKey(pid), sortKeyA)
+	if err != nil {
+		return 0, err
+	}
+	if it == nil {
+		return 0, sql.ErrNoRows
+	}
+	return it.TID, nil
+}
+
+func (d *Database) recordItem(pk, sk string, id, tid int64, fqdn string, t int, content string, created, updated int64) (*item, error) {
+	expires, err := d.expiresAt(tid)
+	if err != nil {
+		return nil, err
+	}
+	return &item{
+		PK:        pk,
+		SK:        sk,
+		ID:        id,
+		TID:       tid,
+		Fqdn:      fqdn,
+		Type:      t,
+		Content:   content,
+		CreatedOn: created,
+		UpdatedOn: updated,
+		ExpiresAt: expires,
+	}, nil
+}
+
+// Used to find an A record by its id
+// e.g. 12 => aid#12
+func parentKey(id int64) string {
+	return prefixAID + strconv.FormatInt(id, 10)
+}
+
+func parentItem(id, tid int64, fqdn string, expires int64) *item {
+	return &item{PK: parentKey(id), SK: sortKeyA, ID: id, TID: tid, Fqdn: fqdn, ExpiresAt: expires}
+}
+
+// Used to list the ACME challenge TXT records by creation, the purger expires them before their domain
+func challengeKind(it *item) *item {
+	if strings.HasPrefix(it.Fqdn, util.ACMEChallengeLabel+".") {
+		it.Kind = kindChallenge
+	}
+	return it
+}
+
+func subItem(a *model.SubRecordA, id, tid, updated, expires int64) *item {
+	return &item{
+		PK:        prefixSubA + a.Fqdn,
+		SK:        sortKeySubA,
+		Kind:      subKind(a.PID),
+		Ord:       id,
+		ID:        id,
+		PID:       a.PID,
+		TID:       tid,
+		Fqdn:      a.Fqdn,
+		Type:      a.Type,
+		Content:   a.Content,
+		CreatedOn: a.CreatedOn,
+		UpdatedOn: updated,
+		ExpiresAt: expires,
+	}
+}
+
+// Used to list the sub records of an A record
+// e.g. 12 => suba#12
+func subKind(pid int64) string {
+	return prefixSubA + strconv.FormatInt(pid, 10)
+}
+
+func toSubA(it *item) *model.SubRecordA {
+	return &model.SubRecordA{ID: it.ID, Fqdn: it.Fqdn, Type: it.Type, Content: it.Content, CreatedOn: it.CreatedOn, UpdatedOn: nullInt64(it.UpdatedOn), PID: it.PID}
+}
+
+func toTXT(it *item) *model.RecordTXT {
+	return &model.RecordTXT{ID: it.ID, Fqdn: it.Fqdn, Type: it.Type, Content: it.Content, CreatedOn: it.CreatedOn, UpdatedOn: nullInt64(it.UpdatedOn), TID: it.TID}
+}
+
+func toTXTOrder(it *item) *model.TXTOrder {
+	return &model.TXTOrder{ID: it.ID, Fqdn: it.Fqdn, OrderID: it.OrderID, Content: it.Content, CreatedOn: it.CreatedOn, TID: it.TID}
+}
+
+func nullInt64(v int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: v, Valid: v != 0}
+}
@@ -0,0 +1,91 @@
+package dynamodb
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Used to create the table when it does not exist and to turn on its time to live, a table
+// which exists is expected to have the keys and indexes of createTable.
+func (d *Database) ensureTable() error {
+	_, err := d.Svc.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(d.Table)})
+	if e, ok := err.(awserr.Error); ok && e.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		err = d.createTable()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe dynamodb table %s", d.Table)
+	}
+
+	ttl, err := d.Svc.DescribeTimeToLive(&dynamodb.DescribeTimeToLiveInput{TableName: aws.String(d.Table)})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe the time to live of dynamodb table %s", d.Table)
+	}
+	if s := ttl.TimeToLiveDescription; s != nil && (aws.StringValue(s.TimeToLiveStatus) == dynamodb.TimeToLiveStatusEnabled ||
+		aws.StringValue(s.TimeToLiveStatus) == dynamodb.TimeToLiveStatusEnabling) {
+		return nil
+	}
+
+	logrus.Infof("enabling the time to live of dynamodb table %s on %s", d.Table, ttlAttribute)
+	_, err = d.Svc.UpdateTimeToLive(&dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(d.Table),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(ttlAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return errors.Wrapf(err, "failed to enable the time to live of dynamodb table %s", d.Table)
+}
+
+func (d *Database) createTable() error {
+	logrus.Infof("creating dynamodb table %s", d.Table)
+
+	_, err := d.Svc.CreateTable(&dynamodb.CreateTableInput{
+		TableName:   aws.String(d.Table),
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			attribute("pk", dynamodb.ScalarAttributeTypeS),
+			attribute("sk", dynamodb.ScalarAttributeTypeS),
+			attribute("tid", dynamodb.ScalarAttributeTypeN),
+			attribute("kind", dynamodb.ScalarAttributeTypeS),
+			attribute("ord", dynamodb.ScalarAttributeTypeN),
+			attribute("created_on", dynamodb.ScalarAttributeTypeN),
+		},
+		KeySchema: keySchema("pk", "sk"),
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{
+			{
+				IndexName:  aws.String(tidIndex),
+				KeySchema:  keySchema("tid", "pk"),
+				Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+			},
+			{
+				IndexName:  aws.String(kindIndex),
+				KeySchema:  keySchema("kind", "ord"),
+				Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+			},
+			{
+				IndexName:  aws.String(createdIndex),
+				KeySchema:  keySchema("kind", "created_on"),
+				Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.Svc.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: aws.String(d.Table)})
+}
+
+func attribute(name, t string) *dynamodb.AttributeDefinition {
+	return &dynamodb.AttributeDefinition{AttributeName: aws.String(name), AttributeType: aws.String(t)}
+}
+
+func keySchema(hash, rang string) []*dynamodb.KeySchemaElement {
+	return []*dynamodb.KeySchemaElement{
+		{AttributeName: aws.String(hash), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		{AttributeName: aws.String(rang), KeyType: aws.String(dynamodb.KeyTypeRange)},
+	}
+}
@@ -0,0 +1,490 @@
+package dynamodb
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func (d *Database) InsertFrozen(prefix string) error {
+	return d.MigrateFrozen(prefix, time.Now().UnixNano())
+}
+
+func (d *Database) QueryFrozen(prefix string) (string, error) {
+	it, err := d.get(prefixFrozen+prefix, kindFrozen)
+	if err != nil {
+		return "", err
+	}
+	if it == nil {
+		return "", sql.ErrNoRows
+	}
+	return it.Prefix, nil
+}
+
+func (d *Database) RenewFrozen(prefix string) error {
+	t := time.Now().UnixNano()
+	_, err := d.update(prefixFrozen+prefix, kindFrozen, "SET #created_on = :t, #expires_at = :e", "attribute_exists(#pk)",
+		map[string]interface{}{":t": t, ":e": d.frozenExpiresAt(t)})
+	if isConditionFailed(err) {
+		return nil
+	}
+	return err
+}
+
+func (d *Database) DeleteFrozen(prefix string) error {
+	return d.delete(prefixFrozen+prefix, kindFrozen)
+}
+
+func (d *Database) DeleteExpiredFrozen(t *time.Time) error {
+	items, err := d.created(kindFrozen, t.UnixNano())
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		if err := d.delete(it.PK, it.SK); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Database) MigrateFrozen(prefix string, expiration int64) error {
+	ok, err := d.insert(&item{
+		PK:        prefixFrozen + prefix,
+		SK:        kindFrozen,
+		Kind:      kindFrozen,
+		Prefix:    prefix,
+		CreatedOn: expiration,
+		ExpiresAt: d.frozenExpiresAt(expiration),
+	})
+	if err == nil && !ok {
+		return errDuplicate("frozen prefix", prefix)
+	}
+	return err
+}
+
+func (d *Database) InsertToken(token, name string) (int64, error) {
+	return d.insertToken(token, name, time.Now().UnixNano())
+}
+
+func (d *Database) QueryTokenCount() (int64, error) {
+	var count int64
+	in := &dynamodb.QueryInput{
+		TableName:                 aws.String(d.Table),
+		IndexName:                 aws.String(kindIndex),
+		KeyConditionExpression:    aws.String("#kind = :kind"),
+		ExpressionAttributeNames:  names("#kind"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":kind": {S: aws.String(kindToken)}},
+		Select:                    aws.String(dynamodb.SelectCount),
+	}
+	for {
+		out, err := d.Svc.Query(in)
+		if err != nil {
+			return 0, err
+		}
+		count += aws.Int64Value(out.Count)
+		if len(out.LastEvaluatedKey) == 0 {
+			return count, nil
+		}
+		in.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func (d *Database) QueryToken(name string) (*model.Token, error) {
+	it, err := d.get(prefixToken+name, kindToken)
+	if err != nil {
+		return &model.Token{}, err
+	}
+	if it == nil {
+		return &model.Token{}, sql.ErrNoRows
+	}
+	return toToken(it), nil
+}
+
+func (d *Database) QueryExpiredTokens(t *time.Time) ([]*model.Token, error) {
+	result := make([]*model.Token, 0)
+	items, err := d.created(kindToken, t.UnixNano())
+	for _, it := range items {
+		result = append(result, toToken(it))
+	}
+	return result, err
+}
+
+func (d *Database) ListTokens(lastID, limit int64) ([]*model.Token, error) {
+	result := make([]*model.Token, 0)
+	err := d.query(kindIndex, "#kind = :kind AND #ord > :last", map[string]interface{}{":kind": kindToken, ":last": lastID}, true, func(it *item) bool {
+		result = append(result, toToken(it))
+		return int64(len(result)) < limit
+	})
+	return result, err
+}
+
+// SearchTokens narrows the tokens down with the host and the indexes of the filter first, the
+// tokens of a filter without them are listed in order. The text and the created times are
+// checked token by token.
+func (d *Database) SearchTokens(f *model.TokenFilter) ([]*model.Token, error) {
+	result := make([]*model.Token, 0)
+
+	tids, narrowed, err := d.filteredTokenIDs(f)
+	if err != nil {
+		return result, err
+	}
+
+	match := func(t *model.Token) (bool, error) {
+		if f.CreatedAfter > 0 && t.CreatedOn <= f.CreatedAfter {
+			return false, nil
+		}
+		if f.CreatedBefore > 0 && t.CreatedOn >= f.CreatedBefore {
+			return false, nil
+		}
+		if f.Text == "" {
+			return true, nil
+		}
+		txts, err := d.tokenItems(t.ID, prefixTXT)
+		if err != nil {
+			return false, err
+		}
+		for _, txt := range txts {
+			if strings.Contains(txt.Content, f.Text) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	if narrowed {
+		for _, tid := range tids {
+			if tid <= f.LastID {
+				continue
+			}
+			t, err := d.tokenByID(tid)
+			if err != nil {
+				return result, err
+			}
+			if t == nil {
+				continue
+			}
+			ok, err := match(t)
+			if err != nil {
+				return result, err
+			}
+			if ok {
+				result = append(result, t)
+			}
+			if int64(len(result)) >= f.Limit {
+				break
+			}
+		}
+		return result, nil
+	}
+
+	var matchErr error
+	err = d.query(kindIndex, "#kind = :kind AND #ord > :last", map[string]interface{}{":kind": kindToken, ":last": f.LastID}, true, func(it *item) bool {
+		t := toToken(it)
+		ok, err := match(t)
+		if err != nil {
+			matchErr = err
+			return false
+		}
+		if ok {
+			result = append(result, t)
+		}
+		return int64(len(result)) < f.Limit
+	})
+	if err == nil {
+		err = matchErr
+	}
+	return result, err
+}
+
+// Used to get the ids of the tokens which have the host and all indexes of a filter in order,
+// false means the filter has neither and every token is a candidate
+func (d *Database) filteredTokenIDs(f *model.TokenFilter) ([]int64, bool, error) {
+	sets := make([]map[int64]bool, 0)
+	if f.Host != "" {
+		items, err := d.partition(prefixHost + f.Host)
+		if err != nil {
+			return nil, true, err
+		}
+		sets = append(sets, tokenIDs(items))
+	}
+	for name, value := range f.Indexes {
+		if value == "" {
+			continue
+		}
+		items, err := d.partition(indexKey(name, value))
+		if err != nil {
+			return nil, true, err
+		}
+		sets = append(sets, tokenIDs(items))
+	}
+	if len(sets) == 0 {
+		return nil, false, nil
+	}
+
+	ids := make([]int64, 0)
+	for tid := range sets[0] {
+		all := true
+		for _, s := range sets[1:] {
+			all = all && s[tid]
+		}
+		if all {
+			ids = append(ids, tid)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, true, nil
+}
+
+func (d *Database) SetIndexes(tid int64, name string, values []string) error {
+	items, err := d.tokenItems(tid, prefixIndex+name+"#")
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		if it.Name != name {
+			continue
+		}
+		if err := d.delete(it.PK, it.SK); err != nil {
+			return err
+		}
+	}
+
+	expires, err := d.expiresAt(tid)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if err := d.put(&item{
+			PK:        indexKey(name, v),
+			SK:        strconv.FormatInt(tid, 10),
+			TID:       tid,
+			Name:      name,
+			Value:     v,
+			ExpiresAt: expires,
+		}, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Database) ListIndexes(tid int64) (map[string][]string, error) {
+	result := make(map[string][]string)
+	items, err := d.tokenItems(tid, prefixIndex)
+	for _, it := range items {
+		result[it.Name] = append(result[it.Name], it.Value)
+	}
+	return result, err
+}
+
+func (d *Database) QueryHostTokens(host string) ([]*model.Token, error) {
+	result := make([]*model.Token, 0)
+	items, err := d.partition(prefixHost + host)
+	if err != nil {
+		return result, err
+	}
+
+	ids := make([]int64, 0)
+	for tid := range tokenIDs(items) {
+		ids = append(ids, tid)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, tid := range ids {
+		t, err := d.tokenByID(tid)
+		if err != nil {
+			return result, err
+		}
+		if t != nil {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+// RenewToken moves the time the items of the token expire at as well.
+func (d *Database) RenewToken(name string) (int64, int64, error) {
+	t := time.Now().UnixNano()
+	expires := d.tokenExpiresAt(t)
+	it, err := d.update(prefixToken+name, kindToken, "SET #created_on = :t, #expires_at = :e", "attribute_exists(#pk)",
+		map[string]interface{}{":t": t, ":e": expires})
+	if isConditionFailed(err) {
+		return 0, 0, sql.ErrNoRows
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	items, err := d.tokenItems(it.ID, "")
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, i := range items {
+		if i.PK == it.PK {
+			continue
+		}
+		if _, err := d.update(i.PK, i.SK, "SET #expires_at = :e", "attribute_exists(#pk)", map[string]interface{}{":e": expires}); err != nil && !isConditionFailed(err) {
+			return 0, 0, err
+		}
+	}
+
+	return it.ID, t, nil
+}
+
+func (d *Database) UpdateToken(token, name string) error {
+	it, err := d.get(prefixToken+name, kindToken)
+	if err != nil {
+		return err
+	}
+	if it == nil {
+		return sql.ErrNoRows
+	}
+
+	// the token and the item which finds it by value are changed together
+	old := it.Token
+	if old == token {
+		return nil
+	}
+	it.Token = token
+	value := tokenValueItem(it)
+	tokenPut, err := d.putInput(it, "attribute_exists(#pk)")
+	if err != nil {
+		return err
+	}
+	valuePut, err := d.putInput(value, "attribute_not_exists(#pk)")
+	if err != nil {
+		return err
+	}
+	_, err = d.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{TableName: tokenPut.TableName, Item: tokenPut.Item, ConditionExpression: tokenPut.ConditionExpression, ExpressionAttributeNames: tokenPut.ExpressionAttributeNames}},
+			{Put: &dynamodb.Put{TableName: valuePut.TableName, Item: valuePut.Item, ConditionExpression: valuePut.ConditionExpression, ExpressionAttributeNames: valuePut.ExpressionAttributeNames}},
+			{Delete: &dynamodb.Delete{TableName: aws.String(d.Table), Key: keys(prefixTokenValue+old, kindToken)}},
+		},
+	})
+	return err
+}
+
+// DeleteToken deletes the token and every item of the token, as the foreign keys of mysql do.
+func (d *Database) DeleteToken(token string) error {
+	v, err := d.get(prefixTokenValue+token, kindToken)
+	if err != nil || v == nil {
+		return err
+	}
+
+	items, err := d.tokenItems(v.TID, "")
+	if err != nil {
+		return err
+	}
+	for _, it := range items {
+		if err := d.delete(it.PK, it.SK); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Database) MigrateToken(token, name string, expiration int64) error {
+	_, err := d.insertToken(token, name, expiration)
+	return err
+}
+
+// Used to insert a token with the item which finds it by value, the token values are unique
+func (d *Database) insertToken(token, name string, created int64) (int64, error) {
+	id, err := d.next(kindToken)
+	if err != nil {
+		return 0, err
+	}
+
+	it := &item{
+		PK:        prefixToken + name,
+		SK:        kindToken,
+		Kind:      kindToken,
+		Ord:       id,
+		TID:       id,
+		ID:        id,
+		Token:     token,
+		Fqdn:      name,
+		CreatedOn: created,
+		ExpiresAt: d.tokenExpiresAt(created),
+	}
+	tokenPut, err := d.putInput(it, "attribute_not_exists(#pk)")
+	if err != nil {
+		return 0, err
+	}
+	valuePut, err := d.putInput(tokenValueItem(it), "attribute_not_exists(#pk)")
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = d.Svc.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{TableName: tokenPut.TableName, Item: tokenPut.Item, ConditionExpression: tokenPut.ConditionExpression, ExpressionAttributeNames: tokenPut.ExpressionAttributeNames}},
+			{Put: &dynamodb.Put{TableName: valuePut.TableName, Item: valuePut.Item, ConditionExpression: valuePut.ConditionExpression, ExpressionAttributeNames: valuePut.ExpressionAttributeNames}},
+		},
+	})
+	if isConditionFailed(err) {
+		return 0, errDuplicate("token of domain", name)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Used to get a token by its id, nil means it does not exist
+func (d *Database) tokenByID(tid int64) (*model.Token, error) {
+	items, err := d.tokenItems(tid, prefixToken)
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return toToken(items[0]), nil
+}
+
+// Used to query the items of a kind which are created at or before the time
+func (d *Database) created(kind string, before int64) ([]*item, error) {
+	items := make([]*item, 0)
+	err := d.query(createdIndex, "#kind = :kind AND #created_on <= :t", map[string]interface{}{":kind": kind, ":t": before}, true, func(it *item) bool {
+		items = append(items, it)
+		return true
+	})
+	return items, err
+}
+
+func (d *Database) frozenExpiresAt(created int64) int64 {
+	return time.Unix(0, created).Add(d.frozen + d.grace).Unix()
+}
+
+func tokenValueItem(t *item) *item {
+	return &item{
+		PK:        prefixTokenValue + t.Token,
+		SK:        kindToken,
+		TID:       t.ID,
+		Fqdn:      t.Fqdn,
+		ExpiresAt: t.ExpiresAt,
+	}
+}
+
+func toToken(it *item) *model.Token {
+	return &model.Token{ID: it.ID, Token: it.Token, Fqdn: it.Fqdn, CreatedOn: it.CreatedOn}
+}
+
+func tokenIDs(items []*item) map[int64]bool {
+	ids := make(map[int64]bool, len(items))
+	for _, it := range items {
+		ids[it.TID] = true
+	}
+	return ids
+}
+
+func indexKey(name, value string) string {
+	return prefixIndex + name + "#" + value
+}
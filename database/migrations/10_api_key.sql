@@ -0,0 +1,16 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS api_key (
+    id VARCHAR(32) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    tenant VARCHAR(64) NOT NULL DEFAULT '',
+    root_domain VARCHAR(255) NOT NULL DEFAULT '',
+    hash VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX index_tenant_api_key (tenant)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS api_key;
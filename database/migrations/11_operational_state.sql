@@ -0,0 +1,13 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS operational_state (
+    state_key VARCHAR(64) NOT NULL,
+    value MEDIUMTEXT NOT NULL,
+    version BIGINT NOT NULL,
+    updated_on BIGINT NOT NULL,
+    PRIMARY KEY (state_key)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS operational_state;
@@ -0,0 +1,16 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS domain_index (
+    id INT AUTO_INCREMENT,
+    name VARCHAR(32) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    tid INT NOT NULL,
+    CONSTRAINT fk_token_index FOREIGN KEY(tid) REFERENCES token(id) ON DELETE CASCADE,
+    PRIMARY KEY (id),
+    UNIQUE INDEX index_tid_name_value (tid, name, value),
+    INDEX index_name_value (name, value)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS domain_index;
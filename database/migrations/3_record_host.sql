@@ -0,0 +1,39 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS record_host (
+    id INT AUTO_INCREMENT,
+    host VARCHAR(64) NOT NULL,
+    fqdn VARCHAR(255) NOT NULL,
+    tid INT NOT NULL,
+    CONSTRAINT fk_token_host FOREIGN KEY(tid) REFERENCES token(id) ON DELETE CASCADE,
+    PRIMARY KEY (id),
+    UNIQUE INDEX index_fqdn_host (fqdn, host),
+    INDEX index_host (host)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Up
+-- backfill the hosts of the existing A records
+INSERT IGNORE INTO record_host (host, fqdn, tid)
+    SELECT SUBSTRING_INDEX(SUBSTRING_INDEX(r.content, ',', n.n), ',', -1), r.fqdn, r.tid
+    FROM record_a r
+    JOIN (SELECT 1 n UNION SELECT 2 UNION SELECT 3 UNION SELECT 4 UNION SELECT 5 UNION SELECT 6 UNION SELECT 7 UNION SELECT 8
+          UNION SELECT 9 UNION SELECT 10 UNION SELECT 11 UNION SELECT 12 UNION SELECT 13 UNION SELECT 14 UNION SELECT 15 UNION SELECT 16) n
+        ON CHAR_LENGTH(r.content) - CHAR_LENGTH(REPLACE(r.content, ',', '')) >= n.n - 1
+    WHERE r.content != '';
+
+-- +migrate Up
+INSERT IGNORE INTO record_host (host, fqdn, tid)
+    SELECT SUBSTRING_INDEX(SUBSTRING_INDEX(s.content, ',', n.n), ',', -1), s.fqdn, r.tid
+    FROM sub_record_a s
+    JOIN record_a r ON r.id = s.pid
+    JOIN (SELECT 1 n UNION SELECT 2 UNION SELECT 3 UNION SELECT 4 UNION SELECT 5 UNION SELECT 6 UNION SELECT 7 UNION SELECT 8
+          UNION SELECT 9 UNION SELECT 10 UNION SELECT 11 UNION SELECT 12 UNION SELECT 13 UNION SELECT 14 UNION SELECT 15 UNION SELECT 16) n
+        ON CHAR_LENGTH(s.content) - CHAR_LENGTH(REPLACE(s.content, ',', '')) >= n.n - 1
+    WHERE s.content != '';
+
+-- +migrate Up
+DELETE FROM domain_index WHERE name = 'host';
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS record_host;
@@ -0,0 +1,17 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS txt_order (
+    id INT AUTO_INCREMENT,
+    fqdn VARCHAR(255) NOT NULL,
+    order_id VARCHAR(64) NOT NULL,
+    content VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL,
+    tid INT NOT NULL,
+    CONSTRAINT fk_token_txt_order FOREIGN KEY(tid) REFERENCES token(id) ON DELETE CASCADE,
+    PRIMARY KEY (id),
+    UNIQUE INDEX index_fqdn_order (fqdn, order_id)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS txt_order;
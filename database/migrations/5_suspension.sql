@@ -0,0 +1,14 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS suspension (
+    id INT AUTO_INCREMENT,
+    fqdn VARCHAR(255) NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    created_on BIGINT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX index_fqdn (fqdn)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS suspension;
@@ -0,0 +1,18 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS webhook (
+    id VARCHAR(32) NOT NULL,
+    fqdn VARCHAR(255) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL DEFAULT '',
+    events VARCHAR(512) NOT NULL DEFAULT '',
+    created_on BIGINT NOT NULL,
+    tid INT NOT NULL,
+    CONSTRAINT fk_token_webhook FOREIGN KEY(tid) REFERENCES token(id) ON DELETE CASCADE,
+    PRIMARY KEY (id),
+    INDEX index_fqdn_webhook (fqdn)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS webhook;
@@ -0,0 +1,16 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS job (
+    id VARCHAR(32) NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    created_on BIGINT NOT NULL,
+    visible_on BIGINT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX index_kind_visible_job (kind, visible_on)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS job;
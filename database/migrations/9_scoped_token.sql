@@ -0,0 +1,15 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS scoped_token (
+    fqdn VARCHAR(255) NOT NULL,
+    scope VARCHAR(32) NOT NULL,
+    token VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL,
+    tid INT NOT NULL,
+    CONSTRAINT fk_token_scoped_token FOREIGN KEY(tid) REFERENCES token(id) ON DELETE CASCADE,
+    PRIMARY KEY (fqdn, scope)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS scoped_token;
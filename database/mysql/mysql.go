@@ -2,9 +2,11 @@ package mysql
 
 import (
 	"database/sql"
+	"strings"
 	"time"
 
 	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
 
 	// in order to make build through
 	_ "github.com/go-sql-driver/mysql"
@@ -176,6 +178,131 @@ func (d *Database) QueryExpiredTokens(t *time.Time) ([]*model.Token, error) {
 	return result, nil
 }
 
+func (d *Database) ListTokens(lastID, limit int64) ([]*model.Token, error) {
+	result := make([]*model.Token, 0)
+	st, err := d.Db.Prepare("SELECT * FROM token WHERE id > ? ORDER BY id LIMIT ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(lastID, limit)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.Token{}
+		if err := rows.Scan(&temp.ID, &temp.Token, &temp.Fqdn, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) SearchTokens(f *model.TokenFilter) ([]*model.Token, error) {
+	result := make([]*model.Token, 0)
+
+	query := "SELECT * FROM token WHERE id > ?"
+	args := []interface{}{f.LastID}
+	if f.Host != "" {
+		query += " AND id IN (SELECT tid FROM record_host WHERE host = ?)"
+		args = append(args, f.Host)
+	}
+	for name, value := range f.Indexes {
+		if value == "" {
+			continue
+		}
+		query += " AND EXISTS (SELECT 1 FROM domain_index WHERE domain_index.tid = token.id AND name = ? AND value = ?)"
+		args = append(args, name, value)
+	}
+	if f.Text != "" {
+		query += " AND EXISTS (SELECT 1 FROM record_txt WHERE record_txt.tid = token.id AND content LIKE ?)"
+		args = append(args, "%"+escapeLike(f.Text)+"%")
+	}
+	if f.CreatedAfter > 0 {
+		query += " AND created_on > ?"
+		args = append(args, f.CreatedAfter)
+	}
+	if f.CreatedBefore > 0 {
+		query += " AND created_on < ?"
+		args = append(args, f.CreatedBefore)
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, f.Limit)
+
+	st, err := d.Db.Prepare(query)
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.Token{}
+		if err := rows.Scan(&temp.ID, &temp.Token, &temp.Fqdn, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) SetIndexes(tid int64, name string, values []string) error {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM domain_index WHERE tid = ? AND name = ?", tid, name); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, v := range values {
+		if _, err := tx.Exec("INSERT IGNORE INTO domain_index (name, value, tid) VALUES( ?, ?, ? )", name, v, tid); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (d *Database) ListIndexes(tid int64) (map[string][]string, error) {
+	result := make(map[string][]string)
+	st, err := d.Db.Prepare("SELECT name, value FROM domain_index WHERE tid = ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(tid)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return result, err
+		}
+		result[name] = append(result[name], value)
+	}
+
+	return result, rows.Err()
+}
+
 func (d *Database) RenewToken(name string) (int64, int64, error) {
 	st, err := d.Db.Prepare("UPDATE token SET created_on = ? WHERE fqdn = ?")
 	if err != nil {
@@ -197,6 +324,29 @@ func (d *Database) RenewToken(name string) (int64, int64, error) {
 	return id, t, nil
 }
 
+func (d *Database) UpdateToken(token, name string) error {
+	st, err := d.Db.Prepare("UPDATE token SET token = ? WHERE fqdn = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	resp, err := st.Exec(token, name)
+	if err != nil {
+		return err
+	}
+
+	n, err := resp.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
 func (d *Database) DeleteToken(token string) error {
 	st, err := d.Db.Prepare("DELETE FROM token WHERE token = ?")
 	if err != nil {
@@ -224,17 +374,14 @@ func (d *Database) MigrateToken(token, name string, expiration int64) error {
 }
 
 func (d *Database) InsertA(a *model.RecordA) (int64, error) {
-	st, err := d.Db.Prepare("INSERT INTO record_a (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)")
-	if err != nil {
-		return 0, err
-	}
-	defer st.Close()
-
-	r, err := st.Exec(a.Fqdn, a.Type, a.Content, a.CreatedOn, a.TID)
-	if err != nil {
-		return 0, err
-	}
-	return r.LastInsertId()
+	return d.execWithHosts(a.Fqdn, a.Content, func(tx *sql.Tx) (int64, int64, error) {
+		r, err := tx.Exec("INSERT INTO record_a (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)", a.Fqdn, a.Type, a.Content, a.CreatedOn, a.TID)
+		if err != nil {
+			return 0, 0, err
+		}
+		id, err := r.LastInsertId()
+		return id, a.TID, err
+	})
 }
 
 func (d *Database) QueryA(name string) (*model.RecordA, error) {
@@ -260,56 +407,52 @@ func (d *Database) QueryA(name string) (*model.RecordA, error) {
 }
 
 func (d *Database) UpdateA(a *model.RecordA) (int64, error) {
-	st, err := d.Db.Prepare("UPDATE record_a SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ?")
-	if err != nil {
-		return 0, err
-	}
-	defer st.Close()
-
-	r, err := st.Exec(a.Type, a.Content, a.CreatedOn, a.TID, a.Fqdn)
-	if err != nil {
-		return 0, err
-	}
-	return r.LastInsertId()
+	return d.execWithHosts(a.Fqdn, a.Content, func(tx *sql.Tx) (int64, int64, error) {
+		r, err := tx.Exec("UPDATE record_a SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ?", a.Type, a.Content, a.CreatedOn, a.TID, a.Fqdn)
+		if err != nil {
+			return 0, 0, err
+		}
+		id, err := r.LastInsertId()
+		return id, a.TID, err
+	})
 }
 
 func (d *Database) DeleteA(name string) error {
-	st, err := d.Db.Prepare("DELETE FROM record_a WHERE fqdn = ?")
-	if err != nil {
-		return err
-	}
-	defer st.Close()
-
-	_, err = st.Exec(name)
+	_, err := d.execWithHosts(name, "", func(tx *sql.Tx) (int64, int64, error) {
+		_, err := tx.Exec("DELETE FROM record_a WHERE fqdn = ?", name)
+		return 0, 0, err
+	})
 	return err
 }
 
 func (d *Database) InsertSubA(a *model.SubRecordA) (int64, error) {
-	st, err := d.Db.Prepare("INSERT INTO sub_record_a (fqdn, type, content, created_on, pid) VALUES (?, ?, ?, ?, ?)")
-	if err != nil {
-		return 0, err
-	}
-	defer st.Close()
-
-	r, err := st.Exec(a.Fqdn, a.Type, a.Content, a.CreatedOn, a.PID)
-	if err != nil {
-		return 0, err
-	}
-	return r.LastInsertId()
+	return d.execWithHosts(a.Fqdn, a.Content, func(tx *sql.Tx) (int64, int64, error) {
+		r, err := tx.Exec("INSERT INTO sub_record_a (fqdn, type, content, created_on, pid) VALUES (?, ?, ?, ?, ?)", a.Fqdn, a.Type, a.Content, a.CreatedOn, a.PID)
+		if err != nil {
+			return 0, 0, err
+		}
+		id, err := r.LastInsertId()
+		if err != nil {
+			return 0, 0, err
+		}
+		tid, err := parentTokenID(tx, a.PID)
+		return id, tid, err
+	})
 }
 
 func (d *Database) UpdateSubA(a *model.SubRecordA) (int64, error) {
-	st, err := d.Db.Prepare("UPDATE sub_record_a SET type = ?, content = ?, created_on = ?, pid = ? WHERE fqdn = ?")
-	if err != nil {
-		return 0, err
-	}
-	defer st.Close()
-
-	r, err := st.Exec(a.Type, a.Content, a.CreatedOn, a.PID, a.Fqdn)
-	if err != nil {
-		return 0, err
-	}
-	return r.LastInsertId()
+	return d.execWithHosts(a.Fqdn, a.Content, func(tx *sql.Tx) (int64, int64, error) {
+		r, err := tx.Exec("UPDATE sub_record_a SET type = ?, content = ?, created_on = ?, pid = ? WHERE fqdn = ?", a.Type, a.Content, a.CreatedOn, a.PID, a.Fqdn)
+		if err != nil {
+			return 0, 0, err
+		}
+		id, err := r.LastInsertId()
+		if err != nil {
+			return 0, 0, err
+		}
+		tid, err := parentTokenID(tx, a.PID)
+		return id, tid, err
+	})
 }
 
 func (d *Database) QuerySubA(name string) (*model.SubRecordA, error) {
@@ -360,14 +503,36 @@ func (d *Database) ListSubA(id int64) ([]*model.SubRecordA, error) {
 }
 
 func (d *Database) DeleteSubA(name string) error {
-	st, err := d.Db.Prepare("DELETE FROM sub_record_a WHERE fqdn = ?")
+	_, err := d.execWithHosts(name, "", func(tx *sql.Tx) (int64, int64, error) {
+		_, err := tx.Exec("DELETE FROM sub_record_a WHERE fqdn = ?", name)
+		return 0, 0, err
+	})
+	return err
+}
+
+func (d *Database) QueryHostTokens(host string) ([]*model.Token, error) {
+	result := make([]*model.Token, 0)
+	st, err := d.Db.Prepare("SELECT * FROM token WHERE id IN (SELECT tid FROM record_host WHERE host = ?) ORDER BY id")
 	if err != nil {
-		return err
+		return result, err
 	}
 	defer st.Close()
 
-	_, err = st.Exec(name)
-	return err
+	rows, err := st.Query(host)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.Token{}
+		if err := rows.Scan(&temp.ID, &temp.Token, &temp.Fqdn, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
 }
 
 func (d *Database) InsertCNAME(c *model.RecordCNAME) (int64, error) {
@@ -460,7 +625,122 @@ func (d *Database) UpdateTXT(a *model.RecordTXT) (int64, error) {
 }
 
 func (d *Database) DeleteTXT(name string) error {
-	st, err := d.Db.Prepare("DELETE FROM record_txt WHERE fqdn = ?")
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// the order values are part of the TXT record
+	for _, q := range []string{"DELETE FROM record_txt WHERE fqdn = ?", "DELETE FROM txt_order WHERE fqdn = ?"} {
+		if _, err := tx.Exec(q, name); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (d *Database) SetTXTOrder(t *model.TXTOrder) error {
+	st, err := d.Db.Prepare("INSERT INTO txt_order (fqdn, order_id, content, created_on, tid) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE content = VALUES(content), created_on = VALUES(created_on)")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(t.Fqdn, t.OrderID, t.Content, t.CreatedOn, t.TID)
+	return err
+}
+
+func (d *Database) QueryTXTOrder(name, order string) (*model.TXTOrder, error) {
+	t := &model.TXTOrder{}
+	st, err := d.Db.Prepare("SELECT * FROM txt_order WHERE fqdn = ? AND order_id = ?")
+	if err != nil {
+		return t, err
+	}
+	defer st.Close()
+
+	if err := st.QueryRow(name, order).Scan(&t.ID, &t.Fqdn, &t.OrderID, &t.Content, &t.CreatedOn, &t.TID); err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+func (d *Database) ListTXTOrders(name string) ([]*model.TXTOrder, error) {
+	result := make([]*model.TXTOrder, 0)
+	st, err := d.Db.Prepare("SELECT * FROM txt_order WHERE fqdn = ? ORDER BY id")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(name)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t := &model.TXTOrder{}
+		if err := rows.Scan(&t.ID, &t.Fqdn, &t.OrderID, &t.Content, &t.CreatedOn, &t.TID); err != nil {
+			return result, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) DeleteTXTOrder(name, order string) error {
+	st, err := d.Db.Prepare("DELETE FROM txt_order WHERE fqdn = ? AND order_id = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(name, order)
+	return err
+}
+
+func (d *Database) SetSuspension(s *model.SuspendedDomain) error {
+	st, err := d.Db.Prepare("INSERT INTO suspension (fqdn, reason, created_on) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE reason = VALUES(reason)")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(s.Fqdn, s.Reason, s.CreatedOn)
+	return err
+}
+
+func (d *Database) ListSuspensions() ([]*model.SuspendedDomain, error) {
+	result := make([]*model.SuspendedDomain, 0)
+	st, err := d.Db.Prepare("SELECT * FROM suspension ORDER BY fqdn")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query()
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &model.SuspendedDomain{}
+		if err := rows.Scan(&s.ID, &s.Fqdn, &s.Reason, &s.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, s)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) DeleteSuspension(name string) error {
+	st, err := d.Db.Prepare("DELETE FROM suspension WHERE fqdn = ?")
 	if err != nil {
 		return err
 	}
@@ -470,6 +750,314 @@ func (d *Database) DeleteTXT(name string) error {
 	return err
 }
 
+func (d *Database) InsertWebhook(w *model.DomainWebhook) error {
+	st, err := d.Db.Prepare("INSERT INTO webhook (id, fqdn, url, secret, events, created_on, tid) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(w.ID, w.Fqdn, w.URL, w.Secret, w.Events, w.CreatedOn, w.TID)
+	return err
+}
+
+func (d *Database) ListWebhooks(name string) ([]*model.DomainWebhook, error) {
+	result := make([]*model.DomainWebhook, 0)
+	st, err := d.Db.Prepare("SELECT * FROM webhook WHERE fqdn = ? ORDER BY created_on, id")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(name)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		w := &model.DomainWebhook{}
+		if err := rows.Scan(&w.ID, &w.Fqdn, &w.URL, &w.Secret, &w.Events, &w.CreatedOn, &w.TID); err != nil {
+			return result, err
+		}
+		result = append(result, w)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) DeleteWebhook(name, id string) error {
+	st, err := d.Db.Prepare("DELETE FROM webhook WHERE fqdn = ? AND id = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(name, id)
+	return err
+}
+
+func (d *Database) SetScopedToken(t *model.DomainScopedToken) error {
+	st, err := d.Db.Prepare("INSERT INTO scoped_token (fqdn, scope, token, created_on, tid) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE token = VALUES(token), created_on = VALUES(created_on), tid = VALUES(tid)")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(t.Fqdn, t.Scope, t.Token, t.CreatedOn, t.TID)
+	return err
+}
+
+func (d *Database) QueryScopedToken(name, scope string) (*model.DomainScopedToken, error) {
+	st, err := d.Db.Prepare("SELECT * FROM scoped_token WHERE fqdn = ? AND scope = ?")
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	t := &model.DomainScopedToken{}
+	err = st.QueryRow(name, scope).Scan(&t.Fqdn, &t.Scope, &t.Token, &t.CreatedOn, &t.TID)
+	return t, err
+}
+
+func (d *Database) DeleteScopedToken(name, scope string) error {
+	st, err := d.Db.Prepare("DELETE FROM scoped_token WHERE fqdn = ? AND scope = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(name, scope)
+	return err
+}
+
+func (d *Database) InsertAPIKey(k *model.DatabaseAPIKey) error {
+	st, err := d.Db.Prepare("INSERT INTO api_key (id, name, tenant, root_domain, hash, created_on) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(k.ID, k.Name, k.Tenant, k.RootDomain, k.Hash, k.CreatedOn)
+	return err
+}
+
+func (d *Database) QueryAPIKey(id string) (*model.DatabaseAPIKey, error) {
+	st, err := d.Db.Prepare("SELECT * FROM api_key WHERE id = ?")
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	k := &model.DatabaseAPIKey{}
+	err = st.QueryRow(id).Scan(&k.ID, &k.Name, &k.Tenant, &k.RootDomain, &k.Hash, &k.CreatedOn)
+	return k, err
+}
+
+func (d *Database) ListAPIKeys() ([]*model.DatabaseAPIKey, error) {
+	result := make([]*model.DatabaseAPIKey, 0)
+	st, err := d.Db.Prepare("SELECT * FROM api_key ORDER BY id")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query()
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		k := &model.DatabaseAPIKey{}
+		if err := rows.Scan(&k.ID, &k.Name, &k.Tenant, &k.RootDomain, &k.Hash, &k.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, k)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) DeleteAPIKey(id string) error {
+	st, err := d.Db.Prepare("DELETE FROM api_key WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(id)
+	return err
+}
+
+func (d *Database) InsertJob(j *model.DatabaseJob) error {
+	st, err := d.Db.Prepare("INSERT INTO job (id, kind, payload, attempts, created_on, visible_on) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(j.ID, j.Kind, j.Payload, j.Attempts, j.CreatedOn, j.VisibleOn)
+	return err
+}
+
+func (d *Database) QueryVisibleJobs(kind string, visibleOn int64, limit int) ([]*model.DatabaseJob, error) {
+	result := make([]*model.DatabaseJob, 0)
+	st, err := d.Db.Prepare("SELECT * FROM job WHERE kind = ? AND visible_on <= ? ORDER BY visible_on, id LIMIT ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(kind, visibleOn, limit)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		j := &model.DatabaseJob{}
+		if err := rows.Scan(&j.ID, &j.Kind, &j.Payload, &j.Attempts, &j.CreatedOn, &j.VisibleOn); err != nil {
+			return result, err
+		}
+		result = append(result, j)
+	}
+
+	return result, rows.Err()
+}
+
+// ClaimJob hides a job until the time, it is not claimed when another worker claimed it since it was queried.
+func (d *Database) ClaimJob(id string, visibleOn, until int64) (bool, error) {
+	st, err := d.Db.Prepare("UPDATE job SET visible_on = ?, attempts = attempts + 1 WHERE id = ? AND visible_on = ?")
+	if err != nil {
+		return false, err
+	}
+	defer st.Close()
+
+	r, err := st.Exec(until, id, visibleOn)
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	return n == 1, err
+}
+
+func (d *Database) DeleteJob(id string) error {
+	st, err := d.Db.Prepare("DELETE FROM job WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(id)
+	return err
+}
+
+func (d *Database) QueryOperationalState(key string) (*model.DatabaseOperationalState, error) {
+	st, err := d.Db.Prepare("SELECT * FROM operational_state WHERE state_key = ?")
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+
+	s := &model.DatabaseOperationalState{}
+	err = st.QueryRow(key).Scan(&s.Key, &s.Value, &s.Version, &s.UpdatedOn)
+	return s, err
+}
+
+func (d *Database) ListOperationalStates() ([]*model.DatabaseOperationalState, error) {
+	result := make([]*model.DatabaseOperationalState, 0)
+	st, err := d.Db.Prepare("SELECT * FROM operational_state ORDER BY state_key")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query()
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s := &model.DatabaseOperationalState{}
+		if err := rows.Scan(&s.Key, &s.Value, &s.Version, &s.UpdatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, s)
+	}
+
+	return result, rows.Err()
+}
+
+// InsertOperationalState inserts a state which is never written, false means another writer inserted it first.
+func (d *Database) InsertOperationalState(s *model.DatabaseOperationalState) (bool, error) {
+	st, err := d.Db.Prepare("INSERT IGNORE INTO operational_state (state_key, value, version, updated_on) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return false, err
+	}
+	defer st.Close()
+
+	r, err := st.Exec(s.Key, s.Value, s.Version, s.UpdatedOn)
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	return n == 1, err
+}
+
+// UpdateOperationalState updates a state which is still of the version, false means another writer changed it.
+func (d *Database) UpdateOperationalState(s *model.DatabaseOperationalState, version int64) (bool, error) {
+	st, err := d.Db.Prepare("UPDATE operational_state SET value = ?, version = ?, updated_on = ? WHERE state_key = ? AND version = ?")
+	if err != nil {
+		return false, err
+	}
+	defer st.Close()
+
+	r, err := st.Exec(s.Value, s.Version, s.UpdatedOn, s.Key, version)
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	return n == 1, err
+}
+
+func (d *Database) InsertDataMigration(m *model.DataMigration) error {
+	st, err := d.Db.Prepare("INSERT INTO data_migration (id, applied_on) VALUES (?, ?) ON DUPLICATE KEY UPDATE applied_on = applied_on")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(m.ID, m.AppliedOn)
+	return err
+}
+
+func (d *Database) ListDataMigrations() ([]*model.DataMigration, error) {
+	result := make([]*model.DataMigration, 0)
+	st, err := d.Db.Prepare("SELECT * FROM data_migration ORDER BY id")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query()
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := &model.DataMigration{}
+		if err := rows.Scan(&m.ID, &m.AppliedOn); err != nil {
+			return result, err
+		}
+		result = append(result, m)
+	}
+
+	return result, rows.Err()
+}
+
 func (d *Database) QueryTXT(name string) (*model.RecordTXT, error) {
 	r := &model.RecordTXT{}
 	st, err := d.Db.Prepare("SELECT * FROM record_txt WHERE fqdn = ?")
@@ -516,6 +1104,82 @@ func (d *Database) QueryExpiredTXTs(id int64) ([]*model.RecordTXT, error) {
 	return result, nil
 }
 
+func (d *Database) QueryExpiredChallengeTXTs(t *time.Time) ([]*model.RecordTXT, error) {
+	result := make([]*model.RecordTXT, 0)
+	st, err := d.Db.Prepare("SELECT * FROM record_txt WHERE fqdn LIKE ? AND created_on <= ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	// TXT records keep created_on in seconds
+	rows, err := st.Query(escapeLike(util.ACMEChallengeLabel)+".%", t.Unix())
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.RecordTXT{}
+		if err := rows.Scan(&temp.ID, &temp.Fqdn, &temp.Type, &temp.Content, &temp.CreatedOn, &temp.UpdatedOn, &temp.TID); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) Ping() error {
+	return d.Db.Ping()
+}
+
 func (d *Database) Close() error {
 	return d.Db.Close()
 }
+
+// Used to escape the wildcards of a LIKE pattern
+// e.g. 50%_off => 50\%\_off
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
+// Used to run a write of an A record and rewrite the record_host rows of the record in
+// one transaction, the write returns the record id and the token id of the record.
+// An empty content only deletes the host rows.
+func (d *Database) execWithHosts(fqdn, content string, write func(tx *sql.Tx) (int64, int64, error)) (int64, error) {
+	tx, err := d.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	id, tid, err := write(tx)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM record_host WHERE fqdn = ?", fqdn); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	for _, h := range strings.Split(content, ",") {
+		if h == "" {
+			continue
+		}
+		if _, err := tx.Exec("INSERT IGNORE INTO record_host (host, fqdn, tid) VALUES( ?, ?, ? )", h, fqdn, tid); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+// Used to get the token id of a sub record from its parent A record
+func parentTokenID(tx *sql.Tx, pid int64) (int64, error) {
+	var tid int64
+	err := tx.QueryRow("SELECT tid FROM record_a WHERE id = ?", pid).Scan(&tid)
+	return tid, err
+}
@@ -0,0 +1,169 @@
+package device
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	codeTTL      = 10 * time.Minute
+	pollInterval = 5 * time.Second
+	maxPending   = 10000
+
+	deviceCodeLength = 32
+	// user codes skip vowels and look-alike letters, they are typed by hand
+	userCodeLetters = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+)
+
+// pending keeps the device codes of this instance until they are redeemed or expire
+var pending = struct {
+	sync.Mutex
+	devices map[string]*authorization
+	users   map[string]string
+}{
+	devices: make(map[string]*authorization),
+	users:   make(map[string]string),
+}
+
+type authorization struct {
+	fqdn       string
+	userCode   string
+	expiration time.Time
+	approved   bool
+	polled     time.Time
+}
+
+// Request issues a device code for the domain, verificationURI is the page the owner approves it on.
+func Request(fqdn, verificationURI string) (model.DeviceCode, error) {
+	now := time.Now()
+
+	pending.Lock()
+	defer pending.Unlock()
+
+	forget(now)
+	if len(pending.devices) >= maxPending {
+		return model.DeviceCode{}, errors.New(errTooManyPending)
+	}
+
+	a := &authorization{
+		fqdn:       fqdn,
+		userCode:   newUserCode(),
+		expiration: now.Add(codeTTL),
+	}
+	deviceCode := util.RandStringWithAll(deviceCodeLength)
+	pending.devices[deviceCode] = a
+	pending.users[a.userCode] = deviceCode
+
+	return model.DeviceCode{
+		Fqdn:                    fqdn,
+		DeviceCode:              deviceCode,
+		UserCode:                a.userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + a.userCode,
+		ExpiresIn:               int64(codeTTL.Seconds()),
+		Interval:                int64(pollInterval.Seconds()),
+	}, nil
+}
+
+// Lookup returns the domain a user code was issued for, so the owner sees what is approved.
+func Lookup(userCode string) (string, bool) {
+	pending.Lock()
+	defer pending.Unlock()
+
+	a, ok := find(normalize(userCode), time.Now())
+	if !ok {
+		return "", false
+	}
+	return a.fqdn, true
+}
+
+// Approve approves a user code of the domain, the request is authorized with the token of the domain.
+func Approve(fqdn, userCode string) error {
+	userCode = normalize(userCode)
+
+	pending.Lock()
+	defer pending.Unlock()
+
+	a, ok := find(userCode, time.Now())
+	if !ok {
+		return errors.Errorf(errUnknownUserCode, userCode)
+	}
+	if a.fqdn != fqdn {
+		return errors.Errorf(errWrongDomain, userCode, fqdn)
+	}
+	a.approved = true
+	return nil
+}
+
+// Redeem returns the domain of an approved device code once, the error is one of the RFC 8628
+// errors until then.
+func Redeem(deviceCode string) (string, error) {
+	now := time.Now()
+
+	pending.Lock()
+	defer pending.Unlock()
+
+	a, ok := pending.devices[deviceCode]
+	if !ok || now.After(a.expiration) {
+		return "", errors.New(ErrExpiredToken)
+	}
+	if !a.approved {
+		if now.Sub(a.polled) < pollInterval {
+			a.polled = now
+			return "", errors.New(ErrSlowDown)
+		}
+		a.polled = now
+		return "", errors.New(ErrAuthorizationPending)
+	}
+
+	delete(pending.devices, deviceCode)
+	delete(pending.users, a.userCode)
+	return a.fqdn, nil
+}
+
+func find(userCode string, now time.Time) (*authorization, bool) {
+	a, ok := pending.devices[pending.users[userCode]]
+	if !ok || now.After(a.expiration) {
+		return nil, false
+	}
+	return a, true
+}
+
+// Used to forget the expired device codes
+func forget(now time.Time) {
+	for code, a := range pending.devices {
+		if now.After(a.expiration) {
+			delete(pending.devices, code)
+			delete(pending.users, a.userCode)
+		}
+	}
+}
+
+// Used to generate a user code which is not pending
+// e.g. BCDF-GHJK
+func newUserCode() string {
+	for {
+		s := util.SecureRandomString(userCodeLetters, userCodeLength)
+		code := s[:userCodeLength/2] + "-" + s[userCodeLength/2:]
+		if _, ok := pending.users[code]; !ok {
+			return code
+		}
+	}
+}
+
+// Used to normalize a typed user code
+// e.g. bcdfghjk => BCDF-GHJK
+func normalize(userCode string) string {
+	s := strings.ToUpper(strings.Replace(strings.TrimSpace(userCode), "-", "", -1))
+	if len(s) != userCodeLength {
+		return s
+	}
+	return s[:userCodeLength/2] + "-" + s[userCodeLength/2:]
+}
@@ -0,0 +1,12 @@
+package device
+
+const (
+	// the errors a polling device gets, as in RFC 8628
+	ErrAuthorizationPending = "authorization_pending"
+	ErrSlowDown             = "slow_down"
+	ErrExpiredToken         = "expired_token"
+
+	errTooManyPending  = "too many pending device codes, retry later"
+	errUnknownUserCode = "unknown or expired user code: %s"
+	errWrongDomain     = "user code %s was not issued for %s"
+)
@@ -0,0 +1,143 @@
+// Package dnsutil converts the typed records of the model to the resource records of miekg/dns
+// and back, so the dns server, the export and the checks of the answers agree on one mapping.
+package dnsutil
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/miekg/dns"
+)
+
+// maxTextLength is the length of a character string of a TXT record
+const maxTextLength = 255
+
+// ToRRs converts the typed records to resource records with the ttl, hosts which are no IP
+// addresses are left out. The records are returned in the order of the typed records.
+func ToRRs(records model.DomainRecords, ttl uint32) []dns.RR {
+	rrs := make([]dns.RR, 0)
+	for _, a := range records.A {
+		for _, h := range a.Hosts {
+			if ip := net.ParseIP(h).To4(); ip != nil {
+				rrs = append(rrs, &dns.A{Hdr: header(a.Name, dns.TypeA, ttl), A: ip})
+			}
+		}
+	}
+	for _, a := range records.AAAA {
+		for _, h := range a.Hosts {
+			if ip := net.ParseIP(h); ip != nil && ip.To4() == nil {
+				rrs = append(rrs, &dns.AAAA{Hdr: header(a.Name, dns.TypeAAAA, ttl), AAAA: ip})
+			}
+		}
+	}
+	for _, t := range records.TXT {
+		rrs = append(rrs, TXT(t.Name, t.Text, ttl))
+	}
+	if c := records.CNAME; c != nil {
+		rrs = append(rrs, &dns.CNAME{Hdr: header(c.Name, dns.TypeCNAME, ttl), Target: dns.Fqdn(c.Target)})
+	}
+	return rrs
+}
+
+// DomainRRs converts the records of a domain, its sub domains and its text to resource records
+// with the ttl of the domain.
+func DomainRRs(d model.Domain) []dns.RR {
+	opts := model.DomainOptions{Fqdn: d.Fqdn, Hosts: d.Hosts, SubDomain: d.SubDomain, Text: d.Text, CNAME: d.CNAME}
+	return ToRRs(opts.Records(), d.TTL)
+}
+
+// FromRRs converts resource records to typed records, the hosts of a name are kept in one record
+// and names are sorted. The types without a typed record are left out, the CAA records are
+// converted whatever their name is.
+func FromRRs(rrs []dns.RR) model.DomainRecords {
+	var r model.DomainRecords
+	a, aaaa := make(map[string][]string), make(map[string][]string)
+
+	for _, rr := range rrs {
+		name := Name(rr)
+		switch v := rr.(type) {
+		case *dns.A:
+			a[name] = append(a[name], v.A.String())
+		case *dns.AAAA:
+			aaaa[name] = append(aaaa[name], v.AAAA.String())
+		case *dns.TXT:
+			r.TXT = append(r.TXT, model.TXTRecord{Name: name, Text: strings.Join(v.Txt, "")})
+		case *dns.CNAME:
+			r.CNAME = &model.CNAMERecord{Name: name, Target: strings.TrimSuffix(v.Target, ".")}
+		case *dns.CAA:
+			r.CAA = append(r.CAA, model.CAARecord{Flag: v.Flag, Tag: v.Tag, Value: v.Value})
+		}
+	}
+
+	for _, name := range sortedNames(a) {
+		r.A = append(r.A, model.ARecord{Name: name, Hosts: a[name]})
+	}
+	for _, name := range sortedNames(aaaa) {
+		r.AAAA = append(r.AAAA, model.AAAARecord{Name: name, Hosts: aaaa[name]})
+	}
+	return r
+}
+
+// TXT returns the TXT record of a text, texts longer than 255 bytes are split into several strings.
+func TXT(name, text string, ttl uint32) *dns.TXT {
+	return &dns.TXT{Hdr: header(name, dns.TypeTXT, ttl), Txt: SplitText(text)}
+}
+
+// CAA returns the CAA resource record of a typed CAA record of the name.
+func CAA(name string, ttl uint32, c model.CAARecord) *dns.CAA {
+	return &dns.CAA{Hdr: header(name, dns.TypeCAA, ttl), Flag: c.Flag, Tag: c.Tag, Value: c.Value}
+}
+
+// SplitText splits a text into the 255 byte strings of a TXT record
+// e.g. 300 bytes => [255 bytes, 45 bytes]
+func SplitText(s string) []string {
+	if len(s) <= maxTextLength {
+		return []string{s}
+	}
+	ss := make([]string, 0, len(s)/maxTextLength+1)
+	for len(s) > maxTextLength {
+		ss = append(ss, s[:maxTextLength])
+		s = s[maxTextLength:]
+	}
+	return append(ss, s)
+}
+
+// Value returns the value of a resource record as the model keeps it, a CNAME target is fully
+// qualified as it is answered
+// e.g. A 1.1.1.1 => 1.1.1.1
+// e.g. TXT "a" "b" => ab
+func Value(rr dns.RR) string {
+	switch v := rr.(type) {
+	case *dns.A:
+		return v.A.String()
+	case *dns.AAAA:
+		return v.AAAA.String()
+	case *dns.TXT:
+		return strings.Join(v.Txt, "")
+	case *dns.CNAME:
+		return v.Target
+	}
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+// Name returns the name of a resource record without the trailing dot
+// e.g. qrn7oq.lb.rancher.cloud. => qrn7oq.lb.rancher.cloud
+func Name(rr dns.RR) string {
+	return strings.TrimSuffix(rr.Header().Name, ".")
+}
+
+func header(name string, rrtype uint16, ttl uint32) dns.RR_Header {
+	return dns.RR_Header{Name: dns.Fqdn(name), Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+}
+
+func sortedNames(m map[string][]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
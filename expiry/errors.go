@@ -0,0 +1,6 @@
+package expiry
+
+const (
+	errListDomains  = "failed to list domains to watch their expiration"
+	errListWebhooks = "failed to list the webhooks of expiring domain %s"
+)
@@ -0,0 +1,158 @@
+package expiry
+
+import (
+	"os"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/schedule"
+	"github.com/rancher/rdns-server/webhook"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultInterval = 5 * time.Minute
+	defaultWarning  = 24 * time.Hour
+)
+
+var (
+	expiringGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "rancher_dns_expiring_domains",
+		Help: "The number of domains which expire within the expiry warning",
+	})
+
+	expiredCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rancher_dns_expired_domains",
+		Help: "The number of domains which expired without being renewed",
+	})
+)
+
+// reaper lists the domains every interval and tells their owners about the domains which expire
+// within the warning, once before they expire and once after.
+type reaper struct {
+	interval time.Duration
+	warning  time.Duration
+
+	// the domains which were warned, by fqdn
+	expiring map[string]*expiring
+}
+
+type expiring struct {
+	domain   model.Domain
+	webhooks []model.Webhook
+	seen     bool
+}
+
+// StartExpiryDaemon watches the expiration of domains every EXPIRY_CHECK_INTERVAL, domains which
+// expire within EXPIRY_WARNING are published as domain.expiring and as domain.expired once they expire.
+func StartExpiryDaemon(done chan struct{}) {
+	interval, err := time.ParseDuration(os.Getenv("EXPIRY_CHECK_INTERVAL"))
+	if err != nil || interval <= 0 {
+		logrus.Errorf("invalid expiry check interval %s, use %s", os.Getenv("EXPIRY_CHECK_INTERVAL"), defaultInterval)
+		interval = defaultInterval
+	}
+	warning, err := time.ParseDuration(os.Getenv("EXPIRY_WARNING"))
+	if err != nil || warning <= 0 {
+		logrus.Errorf("invalid expiry warning %s, use %s", os.Getenv("EXPIRY_WARNING"), defaultWarning)
+		warning = defaultWarning
+	}
+	// a domain which expires between two checks would never be warned
+	if warning < interval {
+		logrus.Warnf("expiry warning %s is shorter than the check interval, use %s", warning, interval)
+		warning = interval
+	}
+
+	r := &reaper{
+		interval: interval,
+		warning:  warning,
+		expiring: make(map[string]*expiring),
+	}
+
+	logrus.Infof("warning about domains which expire within %s every %s", warning, interval)
+	wait.Until(r.check, interval, done)
+}
+
+func (r *reaper) check() {
+	now := time.Now()
+	for _, e := range r.expiring {
+		e.seen = false
+	}
+
+	b := backend.GetBackend()
+	opts := &model.ListOptions{Limit: model.DefaultListLimit}
+	for {
+		l, err := b.List(opts)
+		if err != nil {
+			// domains which are not listed would be taken as expired
+			logrus.Error(errors.Wrap(err, errListDomains))
+			return
+		}
+		for _, d := range l.Items {
+			r.checkDomain(d, now)
+		}
+		if l.Continue == "" {
+			break
+		}
+		opts.Continue = l.Continue
+	}
+
+	for fqdn, e := range r.expiring {
+		switch {
+		case !e.domain.Expiration.After(now):
+			logrus.Infof("domain %s expired at %s", fqdn, e.domain.Expiration.Format(time.RFC3339))
+			expiredCounter.Inc()
+			webhook.PublishExpiry(model.EventDomainExpired, fqdn, e.domain, e.webhooks)
+			// the changes of an expired domain are never made, its name is registered again
+			if err := schedule.Purge(backend.GetBackend(), fqdn, time.Time{}); err != nil {
+				logrus.Errorf("failed to purge the scheduled changes of %s, err: %v", fqdn, err)
+			}
+			delete(r.expiring, fqdn)
+		case !e.seen:
+			// deleted by its owner before it expired
+			delete(r.expiring, fqdn)
+		}
+	}
+
+	expiringGauge.Set(float64(len(r.expiring)))
+}
+
+func (r *reaper) checkDomain(d model.Domain, now time.Time) {
+	if d.Expiration == nil {
+		return
+	}
+
+	e, ok := r.expiring[d.Fqdn]
+	if d.Expiration.After(now.Add(r.warning)) {
+		// renewed since it was warned
+		delete(r.expiring, d.Fqdn)
+		return
+	}
+	if !ok && !d.Expiration.After(now) {
+		// expired before it was warned, or it is told already and waits to be purged
+		return
+	}
+
+	// the webhooks are looked up on every check, so the ones registered after the warning are told too
+	ws, err := backend.GetBackend().ListWebhooks(d.Fqdn)
+	if err != nil {
+		logrus.Warn(errors.Wrapf(err, errListWebhooks, d.Fqdn))
+	}
+	if ok {
+		e.domain = d
+		e.seen = true
+		if err == nil {
+			e.webhooks = ws
+		}
+		return
+	}
+	r.expiring[d.Fqdn] = &expiring{domain: d, webhooks: ws, seen: true}
+
+	logrus.Infof("domain %s expires at %s", d.Fqdn, d.Expiration.Format(time.RFC3339))
+	webhook.PublishExpiry(model.EventDomainExpiring, d.Fqdn, d, ws)
+}
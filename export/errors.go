@@ -0,0 +1,9 @@
+package export
+
+const (
+	errGetDomain       = "failed to get the records of domain %s"
+	errGetToken        = "failed to get the token of domain %s"
+	errListDomains     = "failed to list the domains of %s"
+	errInvalidFormat   = "invalid export format: %s"
+	errInvalidEncoding = "invalid export encoding: %s"
+)
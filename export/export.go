@@ -0,0 +1,196 @@
+package export
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/dnsutil"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// FormatOctoDNS is the zone config of the octodns YamlProvider
+	FormatOctoDNS = "octodns"
+	// FormatExternalDNS is a DNSEndpoint resource of the external-dns crd source
+	FormatExternalDNS = "external-dns"
+
+	EncodingYAML = "yaml"
+	EncodingJSON = "json"
+
+	typeCNAME = "CNAME"
+	typeTXT   = "TXT"
+)
+
+// Record is a record set of a domain as it is answered, wildcard names are listed since
+// both backends answer them.
+type Record struct {
+	Name   string
+	Type   string
+	TTL    uint32
+	Values []string
+}
+
+// Records collects the record sets of a domain, its sub domains, its wildcard and the ACME
+// challenges of them. Other TXT records can not be listed and are left out.
+func Records(b backend.Backend, fqdn string) ([]Record, error) {
+	records := make([]Record, 0)
+
+	d, err := b.Get(&model.DomainOptions{Fqdn: fqdn})
+	if err != nil {
+		c, cerr := b.GetCNAME(&model.DomainOptions{Fqdn: fqdn})
+		if cerr != nil {
+			return nil, errors.Wrapf(err, errGetDomain, fqdn)
+		}
+		for _, name := range []string{fqdn, "*." + fqdn} {
+			records = append(records, sets(dnsutil.ToRRs(model.DomainRecords{
+				CNAME: &model.CNAMERecord{Name: name, Target: c.CNAME},
+			}, c.TTL))...)
+		}
+		return records, nil
+	}
+
+	names := []string{fqdn}
+	records = append(records, addresses(fqdn, d.TTL, d.Hosts)...)
+	records = append(records, addresses("*."+fqdn, d.TTL, d.Hosts)...)
+	subs := make([]string, 0, len(d.SubDomain))
+	for sub := range d.SubDomain {
+		subs = append(subs, sub)
+	}
+	sort.Strings(subs)
+	for _, sub := range subs {
+		name := sub + "." + fqdn
+		names = append(names, name)
+		records = append(records, addresses(name, d.TTL, d.SubDomain[sub])...)
+	}
+
+	for _, name := range names {
+		challenge := util.ACMEChallengeLabel + "." + name
+		t, err := b.GetText(&model.DomainOptions{Fqdn: challenge})
+		if err != nil || t.Text == "" {
+			continue
+		}
+		records = append(records, sets([]dns.RR{dnsutil.TXT(challenge, t.Text, t.TTL)})...)
+	}
+
+	return records, nil
+}
+
+func addresses(name string, ttl uint32, hosts []string) []Record {
+	opts := model.DomainOptions{Fqdn: name, Hosts: hosts}
+	return sets(dnsutil.ToRRs(opts.Records(), ttl))
+}
+
+// Used to group the resource records of a name and type into a record set, the records of a set
+// are next to each other
+// e.g. A 1.1.1.1, A 2.2.2.2, AAAA 2001:db8::1 => [{A [1.1.1.1 2.2.2.2]} {AAAA [2001:db8::1]}]
+func sets(rrs []dns.RR) []Record {
+	records := make([]Record, 0)
+	for _, rr := range rrs {
+		name, rType := dnsutil.Name(rr), dns.TypeToString[rr.Header().Rrtype]
+		if n := len(records); n > 0 && records[n-1].Name == name && records[n-1].Type == rType {
+			records[n-1].Values = append(records[n-1].Values, dnsutil.Value(rr))
+			continue
+		}
+		records = append(records, Record{Name: name, Type: rType, TTL: rr.Header().Ttl, Values: []string{dnsutil.Value(rr)}})
+	}
+	return records
+}
+
+// Render renders the record sets in the format and the encoding, it returns the content type as well.
+func Render(format, encoding, zone, fqdn string, records []Record) ([]byte, string, error) {
+	var v interface{}
+	switch format {
+	case FormatOctoDNS:
+		v = octoDNS(zone, records)
+	case FormatExternalDNS:
+		v = externalDNS(fqdn, records)
+	default:
+		return nil, "", errors.Errorf(errInvalidFormat, format)
+	}
+
+	switch encoding {
+	case EncodingYAML, "":
+		b, err := yaml.Marshal(v)
+		return b, "application/x-yaml", err
+	case EncodingJSON:
+		b, err := json.MarshalIndent(v, "", "  ")
+		return b, "application/json", err
+	}
+	return nil, "", errors.Errorf(errInvalidEncoding, encoding)
+}
+
+type octoDNSRecord struct {
+	Type   string   `json:"type" yaml:"type"`
+	TTL    uint32   `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	Value  string   `json:"value,omitempty" yaml:"value,omitempty"`
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+}
+
+// Used to get the octodns zone config, names are relative to the zone and a name with several
+// types lists a record of every type
+// e.g. x1.qrn7oq.lb.rancher.cloud in lb.rancher.cloud => x1.qrn7oq: [{type: A, values: [1.1.1.1]}]
+func octoDNS(zone string, records []Record) map[string][]octoDNSRecord {
+	suffix := "." + strings.Trim(zone, ".")
+	result := make(map[string][]octoDNSRecord)
+	for _, r := range records {
+		name := strings.TrimSuffix(r.Name, suffix)
+		o := octoDNSRecord{Type: r.Type, TTL: r.TTL}
+		switch r.Type {
+		case typeCNAME:
+			o.Value = r.Values[0]
+		case typeTXT:
+			// octodns expects the semicolons of TXT values to be escaped
+			for _, v := range r.Values {
+				o.Values = append(o.Values, strings.Replace(v, ";", `\;`, -1))
+			}
+		default:
+			o.Values = r.Values
+		}
+		result[name] = append(result[name], o)
+	}
+	return result
+}
+
+type dnsEndpoint struct {
+	APIVersion string            `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string            `json:"kind" yaml:"kind"`
+	Metadata   map[string]string `json:"metadata" yaml:"metadata"`
+	Spec       dnsEndpointSpec   `json:"spec" yaml:"spec"`
+}
+
+type dnsEndpointSpec struct {
+	Endpoints []endpoint `json:"endpoints" yaml:"endpoints"`
+}
+
+type endpoint struct {
+	DNSName    string   `json:"dnsName" yaml:"dnsName"`
+	RecordType string   `json:"recordType" yaml:"recordType"`
+	RecordTTL  uint32   `json:"recordTTL,omitempty" yaml:"recordTTL,omitempty"`
+	Targets    []string `json:"targets" yaml:"targets"`
+}
+
+// Used to get the DNSEndpoint resource of a domain, it is named after the domain
+// e.g. qrn7oq.lb.rancher.cloud => qrn7oq-lb-rancher-cloud
+func externalDNS(fqdn string, records []Record) dnsEndpoint {
+	e := dnsEndpoint{
+		APIVersion: "externaldns.k8s.io/v1alpha1",
+		Kind:       "DNSEndpoint",
+		Metadata:   map[string]string{"name": strings.Replace(fqdn, ".", "-", -1)},
+		Spec:       dnsEndpointSpec{Endpoints: make([]endpoint, 0, len(records))},
+	}
+	for _, r := range records {
+		targets := r.Values
+		if r.Type == typeCNAME {
+			targets = []string{strings.TrimSuffix(r.Values[0], ".")}
+		}
+		e.Spec.Endpoints = append(e.Spec.Endpoints, endpoint{DNSName: r.Name, RecordType: r.Type, RecordTTL: r.TTL, Targets: targets})
+	}
+	return e
+}
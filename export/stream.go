@@ -0,0 +1,61 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+// Stream writes the domains of the root domains as NDJSON export records, one line per domain.
+// A page of domains is only listed once the page before is written and flushed, so a slow
+// reader slows the listing down instead of the export being buffered. The number of written
+// records is returned.
+func Stream(b backend.Backend, roots []string, w io.Writer, flush func()) (int, error) {
+	encoder := json.NewEncoder(w)
+	n := 0
+	for _, root := range roots {
+		opts := &model.ListOptions{Limit: model.DefaultListLimit, Root: root}
+		for {
+			l, err := b.List(opts)
+			if err != nil {
+				return n, errors.Wrapf(err, errListDomains, root)
+			}
+
+			for _, d := range l.Items {
+				token, err := b.GetToken(d.Fqdn)
+				if err != nil {
+					return n, errors.Wrapf(err, errGetToken, d.Fqdn)
+				}
+				if err := encoder.Encode(exportRecord(d, token)); err != nil {
+					return n, err
+				}
+				n++
+			}
+			flush()
+
+			if l.Continue == "" {
+				break
+			}
+			opts.Continue = l.Continue
+		}
+	}
+	return n, nil
+}
+
+func exportRecord(d model.Domain, token string) model.ExportRecord {
+	return model.ExportRecord{
+		MigrateRecord: model.MigrateRecord{
+			Fqdn:       d.Fqdn,
+			Hosts:      d.Hosts,
+			SubDomain:  d.SubDomain,
+			Text:       d.Text,
+			Token:      token,
+			Expiration: d.Expiration,
+		},
+		CNAME: d.CNAME,
+	}
+}
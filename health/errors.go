@@ -0,0 +1,5 @@
+package health
+
+const (
+	errGetDomain = "failed to get the hosts of domain %s"
+)
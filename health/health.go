@@ -0,0 +1,275 @@
+package health
+
+import (
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultInterval = 30 * time.Second
+	defaultTimeout  = 2 * time.Second
+	// domains which are not asked for within watchPeriods intervals are not checked any more
+	watchPeriods    = 10
+	defaultParallel = 16
+)
+
+var (
+	hostUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rancher_dns_host_up",
+		Help: "Whether the host of a watched domain accepts connections on the health check port",
+	}, []string{"fqdn", "host"})
+
+	checkCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_health_checks",
+		Help: "The number of host health checks",
+	}, []string{"result"})
+
+	current *checker
+)
+
+// checker connects to the hosts of the domains which are asked for with TCP. Domains are watched
+// once they are asked for, their hosts are checked every interval and exported as metrics.
+type checker struct {
+	port     string
+	interval time.Duration
+	timeout  time.Duration
+	parallel int
+
+	mu      sync.Mutex
+	results map[string]model.HostHealth
+	watched map[string]*watch
+}
+
+type watch struct {
+	asked time.Time
+	hosts []string
+}
+
+// Enabled returns whether HEALTH_CHECK_PORT is set.
+func Enabled() bool {
+	return current != nil
+}
+
+// StartHealthDaemon checks the hosts of the watched domains every HEALTH_CHECK_INTERVAL,
+// it returns at once when no HEALTH_CHECK_PORT is configured.
+func StartHealthDaemon(done chan struct{}) {
+	port := os.Getenv("HEALTH_CHECK_PORT")
+	if port == "" {
+		return
+	}
+
+	interval, err := time.ParseDuration(os.Getenv("HEALTH_CHECK_INTERVAL"))
+	if err != nil || interval <= 0 {
+		logrus.Errorf("invalid health check interval %s, use %s", os.Getenv("HEALTH_CHECK_INTERVAL"), defaultInterval)
+		interval = defaultInterval
+	}
+	timeout, err := time.ParseDuration(os.Getenv("HEALTH_CHECK_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		logrus.Errorf("invalid health check timeout %s, use %s", os.Getenv("HEALTH_CHECK_TIMEOUT"), defaultTimeout)
+		timeout = defaultTimeout
+	}
+	parallel, err := strconv.Atoi(os.Getenv("HEALTH_CHECK_PARALLEL"))
+	if err != nil || parallel <= 0 {
+		logrus.Errorf("invalid health check parallel %s, use %d", os.Getenv("HEALTH_CHECK_PARALLEL"), defaultParallel)
+		parallel = defaultParallel
+	}
+
+	c := &checker{
+		port:     port,
+		interval: interval,
+		timeout:  timeout,
+		parallel: parallel,
+		results:  make(map[string]model.HostHealth),
+		watched:  make(map[string]*watch),
+	}
+	current = c
+
+	logrus.Infof("checking hosts of watched domains on port %s every %s", port, interval)
+	wait.Until(c.checkWatched, interval, done)
+}
+
+// Domain returns the health of the hosts of the domain and watches it, results which are
+// newer than the interval are reused.
+func Domain(d model.Domain) model.DomainHealth {
+	c := current
+	hosts := domainHosts(d)
+
+	c.mu.Lock()
+	c.watched[d.Fqdn] = &watch{asked: time.Now(), hosts: hosts}
+	c.mu.Unlock()
+
+	return c.health(d.Fqdn, hosts, false)
+}
+
+// Host returns the last check result of a host, hosts of the domains which are not watched have
+// none. It is used by the dns plugin to answer the hosts with the lowest latency first.
+func Host(host string) (model.HostHealth, bool) {
+	c := current
+	if c == nil {
+		return model.HostHealth{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.results[host]
+	return r, ok && r.Checked != nil
+}
+
+func (c *checker) checkWatched() {
+	c.mu.Lock()
+	fqdns := make([]string, 0, len(c.watched))
+	for fqdn, w := range c.watched {
+		if time.Since(w.asked) > watchPeriods*c.interval {
+			delete(c.watched, fqdn)
+			for _, h := range w.hosts {
+				hostUp.DeleteLabelValues(fqdn, h)
+			}
+			continue
+		}
+		fqdns = append(fqdns, fqdn)
+	}
+	c.mu.Unlock()
+
+	for _, fqdn := range fqdns {
+		d, err := backend.GetBackend().Get(&model.DomainOptions{Fqdn: fqdn})
+		if err != nil {
+			logrus.Warn(errors.Wrapf(err, errGetDomain, fqdn))
+			continue
+		}
+		hosts := domainHosts(d)
+
+		c.mu.Lock()
+		w, ok := c.watched[fqdn]
+		if ok {
+			for _, h := range w.hosts {
+				hostUp.DeleteLabelValues(fqdn, h)
+			}
+			w.hosts = hosts
+		}
+		c.mu.Unlock()
+
+		c.health(fqdn, hosts, true)
+	}
+
+	// drop the results of hosts which no watched domain points at
+	c.mu.Lock()
+	used := make(map[string]bool)
+	for _, w := range c.watched {
+		for _, h := range w.hosts {
+			used[h] = true
+		}
+	}
+	for h := range c.results {
+		if !used[h] {
+			delete(c.results, h)
+		}
+	}
+	c.mu.Unlock()
+}
+
+func (c *checker) health(fqdn string, hosts []string, refresh bool) model.DomainHealth {
+	result := model.DomainHealth{
+		Fqdn:  fqdn,
+		Hosts: make([]model.HostHealth, len(hosts)),
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.parallel)
+	for i, h := range hosts {
+		wg.Add(1)
+		go func(i int, h string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			result.Hosts[i] = c.check(h, refresh)
+			<-sem
+		}(i, h)
+	}
+	wg.Wait()
+
+	up := 0
+	for _, h := range result.Hosts {
+		v := 0.0
+		if h.Up {
+			up++
+			v = 1
+		}
+		hostUp.WithLabelValues(fqdn, h.Host).Set(v)
+	}
+
+	switch {
+	case len(hosts) == 0:
+		result.Status = model.HealthUnknown
+	case up == len(hosts):
+		result.Status = model.HealthHealthy
+	case up == 0:
+		result.Status = model.HealthUnhealthy
+	default:
+		result.Status = model.HealthDegraded
+	}
+
+	return result
+}
+
+func (c *checker) check(host string, refresh bool) model.HostHealth {
+	c.mu.Lock()
+	r, ok := c.results[host]
+	c.mu.Unlock()
+	if ok && !refresh && r.Checked != nil && time.Since(*r.Checked) < c.interval {
+		return r
+	}
+
+	start := time.Now()
+	r = model.HostHealth{Host: host, Checked: &start}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, c.port), c.timeout)
+	r.Latency = time.Since(start).Nanoseconds() / int64(time.Millisecond)
+	if err != nil {
+		r.Error = err.Error()
+		checkCounter.WithLabelValues("down").Inc()
+	} else {
+		conn.Close()
+		r.Up = true
+		checkCounter.WithLabelValues("up").Inc()
+	}
+
+	c.mu.Lock()
+	c.results[host] = r
+	c.mu.Unlock()
+
+	return r
+}
+
+// Used to get the distinct hosts of a domain and its sub domains
+// e.g. {hosts: [1.1.1.1], subdomain: {x1: [1.1.1.1, 2.2.2.2]}} => [1.1.1.1, 2.2.2.2]
+func domainHosts(d model.Domain) []string {
+	seen := make(map[string]bool)
+	hosts := make([]string, 0)
+	add := func(hs []string) {
+		for _, h := range hs {
+			if h != "" && !seen[h] {
+				seen[h] = true
+				hosts = append(hosts, h)
+			}
+		}
+	}
+
+	add(d.Hosts)
+	for _, hs := range d.SubDomain {
+		add(hs)
+	}
+	sort.Strings(hosts)
+
+	return hosts
+}
@@ -0,0 +1,15 @@
+package importer
+
+const (
+	errInvalidFormat = "invalid import format: %s"
+	errInvalidName   = "invalid domain name: %s"
+	errMissingColumn = "the %s export has no %s column"
+	errImportDomain  = "failed to import domain %s"
+	errImportCNAME   = "CNAME domain %s can not be imported"
+	errNoToken       = "record of domain %s has no token"
+	errNotUnderRoot  = "domain %s is not under the root domains"
+	errReadRecord    = "failed to read record %d of the import"
+	errReadExport    = "failed to read the %s export"
+
+	errSkippedEntries = "skipped %d of %d entries of the import"
+)
@@ -0,0 +1,263 @@
+package importer
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/dual"
+	"github.com/rancher/rdns-server/command/output"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// FormatACMEDNS is the csv which sqlite3 exports of the records and txt tables of acme-dns:
+	// sqlite3 -header -csv acme-dns.db "SELECT r.Subdomain, t.Value FROM records r LEFT JOIN txt t ON t.Subdomain = r.Subdomain"
+	FormatACMEDNS = "acme-dns"
+	// FormatDynDNS is a csv of dynamic dns names with the columns name, ipv4, ipv6 and txt, like the domains of duckdns
+	FormatDynDNS = "dyndns"
+
+	tokenLength = 32
+)
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Entry is a name of another service with the records it answers.
+type Entry struct {
+	Name  string
+	Hosts []string
+	Texts []string
+}
+
+// Result is an imported domain with the token its owner uses with the api, Skipped tells why
+// an entry is not imported.
+type Result struct {
+	Fqdn    string `json:"fqdn"`
+	Token   string `json:"token"`
+	Skipped string `json:"skipped"`
+}
+
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "import_format",
+			Usage: "used to set the format of the import file, acme-dns or dyndns.",
+			Value: FormatDynDNS,
+		},
+		cli.StringFlag{
+			Name:  "import_file",
+			Usage: "used to set the csv file which is imported, - reads stdin.",
+			Value: "-",
+		},
+		cli.StringFlag{
+			Name:  "import_lease",
+			Usage: "used to set the lease of the imported domains.",
+			Value: "240h",
+		},
+	}
+}
+
+// Import imports the file of the import flags into the backend and writes the results to stdout, as
+// csv unless the output flag is set. Some entries which are skipped are a partial import.
+func Import(c *cli.Context, b backend.Backend) error {
+	lease, err := time.ParseDuration(c.String("import_lease"))
+	if err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if f := c.String("import_file"); f != "-" {
+		file, err := os.Open(f)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	entries, err := Parse(c.String("import_format"), in)
+	if err != nil {
+		return err
+	}
+
+	results := Run(b, entries, lease)
+	n := imported(results)
+	logrus.Infof("imported %d of %d %s entries", n, len(entries), c.String("import_format"))
+
+	if output.Requested(c) {
+		err = output.Write(c, results)
+	} else {
+		err = WriteResults(os.Stdout, results)
+	}
+	if err != nil {
+		return err
+	}
+
+	if n < len(results) {
+		return output.Partialf(errSkippedEntries, len(results)-n, len(entries))
+	}
+	return nil
+}
+
+func imported(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Skipped == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Parse reads the entries of an export, the names keep their first label only
+// e.g. dyndns: myhost.duckdns.org,1.1.1.1,, => {myhost [1.1.1.1] []}
+func Parse(format string, r io.Reader) ([]Entry, error) {
+	var columns map[string][]string
+	switch format {
+	case FormatACMEDNS:
+		columns = map[string][]string{"name": {"subdomain"}, "txt": {"value"}}
+	case FormatDynDNS:
+		columns = map[string][]string{"name": {"name", "domain", "hostname"}, "ipv4": {"ipv4", "ip"}, "ipv6": {"ipv6"}, "txt": {"txt"}}
+	default:
+		return nil, errors.Errorf(errInvalidFormat, format)
+	}
+
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, errReadExport, format)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	index := make(map[string]int)
+	for i, h := range rows[0] {
+		h = strings.ToLower(strings.TrimSpace(h))
+		for c, names := range columns {
+			for _, n := range names {
+				if h == n {
+					index[c] = i
+				}
+			}
+		}
+	}
+	if _, ok := index["name"]; !ok {
+		return nil, errors.Errorf(errMissingColumn, format, columns["name"][0])
+	}
+
+	entries := make([]Entry, 0)
+	seen := make(map[string]int)
+	for _, row := range rows[1:] {
+		name := strings.ToLower(strings.Split(strings.TrimSpace(value(row, index, "name")), ".")[0])
+		if name == "" {
+			continue
+		}
+		i, ok := seen[name]
+		if !ok {
+			i = len(entries)
+			seen[name] = i
+			entries = append(entries, Entry{Name: name})
+		}
+		for _, c := range []string{"ipv4", "ipv6"} {
+			if h := strings.TrimSpace(value(row, index, c)); net.ParseIP(h) != nil {
+				entries[i].Hosts = append(entries[i].Hosts, h)
+			}
+		}
+		if t := value(row, index, "txt"); t != "" {
+			entries[i].Texts = append(entries[i].Texts, t)
+		}
+	}
+
+	return entries, nil
+}
+
+func value(row []string, index map[string]int, column string) string {
+	i, ok := index[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
+
+// Run creates a domain with a new token for each entry under the zone of the backend, the TXT
+// values are answered by the _acme-challenge record of the domain. Names which are taken are skipped.
+func Run(b backend.Backend, entries []Entry, lease time.Duration) []Result {
+	zone := strings.Trim(b.GetZone(), ".")
+	results := make([]Result, 0, len(entries))
+	for _, e := range entries {
+		fqdn := e.Name + "." + zone
+		r, err := importEntry(b, fqdn, e, lease)
+		if err != nil {
+			logrus.Warn(errors.Wrapf(err, errImportDomain, fqdn))
+			r = Result{Fqdn: fqdn, Skipped: err.Error()}
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func importEntry(b backend.Backend, fqdn string, e Entry, lease time.Duration) (Result, error) {
+	if !labelPattern.MatchString(e.Name) {
+		return Result{}, errors.Errorf(errInvalidName, e.Name)
+	}
+	if _, err := b.GetToken(fqdn); err == nil {
+		return Result{Fqdn: fqdn, Skipped: "exists"}, nil
+	}
+
+	origin := util.RandStringWithAll(tokenLength)
+	expiration := time.Now().Add(lease)
+
+	if err := b.MigrateToken(&model.MigrateToken{Path: dual.TokenPath(b, fqdn), Token: origin, Expiration: &expiration}); err != nil {
+		return Result{}, err
+	}
+	if err := b.MigrateRecord(&model.MigrateRecord{Fqdn: fqdn, Hosts: e.Hosts, Token: origin, Expiration: &expiration}); err != nil {
+		return Result{}, err
+	}
+
+	for i, t := range e.Texts {
+		opts := &model.DomainOptions{Fqdn: util.ACMEChallengeLabel + "." + fqdn, Text: t}
+		if len(e.Texts) > 1 {
+			// every value is kept as an order of its own, the record answers all of them
+			opts.Order = fmt.Sprintf("import-%d", i)
+		}
+		if _, err := b.SetText(opts); err != nil {
+			return Result{}, err
+		}
+	}
+
+	// the token of the api is a bcrypt hash of the origin, like the ones the api returns
+	hash, err := bcrypt.GenerateFromPassword([]byte(origin), bcrypt.MinCost)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Fqdn: fqdn, Token: base64.StdEncoding.EncodeToString(hash)}, nil
+}
+
+// WriteResults writes the imported domains and their tokens as csv, they are handed to the owners.
+func WriteResults(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"fqdn", "token", "skipped"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := cw.Write([]string{r.Fqdn, r.Token, r.Skipped}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
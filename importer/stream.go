@@ -0,0 +1,82 @@
+package importer
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/dual"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+)
+
+// maxFailures are the failures an import summary lists, the others are only counted
+const maxFailures = 100
+
+// ImportStream reads NDJSON export records one at a time and migrates each domain with its
+// token, the next record is only read once the domain before is written, so a fast writer is
+// slowed down to the backend instead of the records being buffered. Domains which exist are
+// skipped, a line which is no record ends the import.
+func ImportStream(b backend.Backend, r io.Reader) (model.ImportSummary, error) {
+	var summary model.ImportSummary
+	decoder := json.NewDecoder(r)
+	for {
+		var rec model.ExportRecord
+		err := decoder.Decode(&rec)
+		if err == io.EOF {
+			return summary, nil
+		}
+		if err != nil {
+			return summary, errors.Wrapf(err, errReadRecord, summary.Imported+summary.Skipped+summary.Failed+1)
+		}
+
+		skipped, err := importRecord(b, &rec)
+		switch {
+		case err != nil:
+			summary.Failed++
+			if len(summary.Failures) < maxFailures {
+				summary.Failures = append(summary.Failures, model.ImportFailure{Fqdn: rec.Fqdn, Error: err.Error()})
+			}
+		case skipped:
+			summary.Skipped++
+		default:
+			summary.Imported++
+		}
+	}
+}
+
+func importRecord(b backend.Backend, rec *model.ExportRecord) (bool, error) {
+	if err := model.ValidateFqdn(rec.Fqdn); err != nil {
+		return false, err
+	}
+	if util.RootOf(rec.Fqdn, backend.Zones(b)) == "" {
+		return false, errors.Errorf(errNotUnderRoot, rec.Fqdn)
+	}
+	if rec.Token == "" {
+		return false, errors.Errorf(errNoToken, rec.Fqdn)
+	}
+	// the migrate apis write A and TXT records only
+	if rec.CNAME != "" {
+		return false, errors.Errorf(errImportCNAME, rec.Fqdn)
+	}
+	if _, err := b.GetToken(rec.Fqdn); err == nil {
+		return true, nil
+	}
+
+	if err := b.MigrateToken(&model.MigrateToken{Path: dual.TokenPath(b, rec.Fqdn), Token: rec.Token, Expiration: rec.Expiration}); err != nil {
+		return false, err
+	}
+
+	// a text is migrated on its own, the migrate record of a text has no hosts
+	hosts := rec.MigrateRecord
+	hosts.Text = ""
+	if err := b.MigrateRecord(&hosts); err != nil {
+		return false, err
+	}
+	if rec.Text != "" {
+		return false, b.MigrateRecord(&model.MigrateRecord{Fqdn: rec.Fqdn, Text: rec.Text, Token: rec.Token, Expiration: rec.Expiration})
+	}
+	return false, nil
+}
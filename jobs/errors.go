@@ -0,0 +1,7 @@
+package jobs
+
+const (
+	errEnqueue         = "failed to enqueue %s job"
+	errJob             = "%s job %s failed after %d attempts"
+	errTooManyAttempts = "gave up after %d attempts"
+)
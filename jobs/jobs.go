@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultVisibility   = 30 * time.Second
+	defaultPollInterval = time.Second
+	idLength            = 8
+)
+
+var (
+	jobCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_jobs",
+		Help: "The number of queued jobs which are done by kind and result",
+	}, []string{"kind", "result"})
+
+	// workers of a kind are woken once a job of the kind is queued by this instance
+	wakeups   = make(map[string]chan struct{})
+	wakeupsMu sync.Mutex
+)
+
+// Handler does a job, the job is retried after the visibility timeout when an error is returned.
+type Handler func(j model.Job) error
+
+type permanent struct {
+	error
+}
+
+// Permanent marks an error which is not retried, e.g. a reply which a retry would not change.
+func Permanent(err error) error {
+	return permanent{err}
+}
+
+// IsPermanent returns whether an error is not retried.
+func IsPermanent(err error) bool {
+	_, ok := errors.Cause(err).(permanent)
+	return ok
+}
+
+// Enqueue keeps a job of the kind in the backend, so it is done even when this instance stops before.
+func Enqueue(kind string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrapf(err, errEnqueue, kind)
+	}
+
+	now := time.Now()
+	j := &model.Job{
+		ID:      fmt.Sprintf("%019d-%s", now.UnixNano(), util.RandStringWithSmall(idLength)),
+		Kind:    kind,
+		Payload: body,
+		Created: now,
+		Visible: now,
+	}
+	if err := backend.GetBackend().EnqueueJob(j); err != nil {
+		return errors.Wrapf(err, errEnqueue, kind)
+	}
+
+	select {
+	case wakeup(kind) <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Worker claims the jobs of a kind from the backend and does them, a job is deleted once it is done
+// or has failed MaxAttempts times.
+type Worker struct {
+	Kind        string
+	Workers     int
+	MaxAttempts int
+	Handle      Handler
+}
+
+// Run claims jobs every JOB_POLL_INTERVAL until done is closed, claimed jobs are hidden from other
+// workers for JOB_VISIBILITY_TIMEOUT.
+func (w *Worker) Run(done chan struct{}) {
+	visibility, err := time.ParseDuration(os.Getenv("JOB_VISIBILITY_TIMEOUT"))
+	if err != nil || visibility <= 0 {
+		logrus.Errorf("invalid job visibility timeout %s, use %s", os.Getenv("JOB_VISIBILITY_TIMEOUT"), defaultVisibility)
+		visibility = defaultVisibility
+	}
+	poll, err := time.ParseDuration(os.Getenv("JOB_POLL_INTERVAL"))
+	if err != nil || poll <= 0 {
+		logrus.Errorf("invalid job poll interval %s, use %s", os.Getenv("JOB_POLL_INTERVAL"), defaultPollInterval)
+		poll = defaultPollInterval
+	}
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		js, err := backend.GetBackend().ClaimJobs(w.Kind, w.Workers, visibility)
+		if err != nil {
+			logrus.Warn(err)
+		}
+
+		var wg sync.WaitGroup
+		for _, j := range js {
+			wg.Add(1)
+			go func(j model.Job) {
+				defer wg.Done()
+				w.do(j)
+			}(j)
+		}
+		wg.Wait()
+
+		// a full batch is followed by the next one at once
+		if err == nil && len(js) >= w.Workers {
+			select {
+			case <-done:
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		case <-wakeup(w.Kind):
+		}
+	}
+}
+
+func (w *Worker) do(j model.Job) {
+	result := "success"
+	err := errors.Errorf(errTooManyAttempts, j.Attempts-1)
+	// the worker which claimed the last attempt stopped before it was done
+	if j.Attempts <= w.MaxAttempts {
+		err = w.Handle(j)
+	}
+
+	if err != nil {
+		if !IsPermanent(err) && j.Attempts < w.MaxAttempts {
+			jobCounter.WithLabelValues(w.Kind, "retry").Inc()
+			logrus.Debugf("retry %s job %s after attempt %d: %v", w.Kind, j.ID, j.Attempts, err)
+			return
+		}
+		result = "failure"
+		logrus.Warn(errors.Wrapf(err, errJob, w.Kind, j.ID, j.Attempts))
+	}
+
+	jobCounter.WithLabelValues(w.Kind, result).Inc()
+	if err := backend.GetBackend().DeleteJob(w.Kind, j.ID); err != nil {
+		logrus.Warn(err)
+	}
+}
+
+func wakeup(kind string) chan struct{} {
+	wakeupsMu.Lock()
+	defer wakeupsMu.Unlock()
+
+	c, ok := wakeups[kind]
+	if !ok {
+		c = make(chan struct{}, 1)
+		wakeups[kind] = c
+	}
+	return c
+}
@@ -0,0 +1,7 @@
+package lifecycle
+
+const (
+	errStopTimeout = "subsystems %v did not stop within %s"
+	errSubsystem   = "subsystem %s failed"
+	errWarming     = "subsystems %v are warming up"
+)
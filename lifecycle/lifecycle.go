@@ -0,0 +1,141 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// stopTimeout is how long a subsystem may take to stop before the next one is stopped
+const stopTimeout = 10 * time.Second
+
+// Run runs a subsystem until done is closed, an error returned before is fatal and stops the others.
+type Run func(done chan struct{}) error
+
+// Disabled returns whether the subsystem is listed in DISABLE_SUBSYSTEMS
+// e.g. health,webhook => health is disabled
+func Disabled(name string) bool {
+	for _, s := range strings.Split(os.Getenv("DISABLE_SUBSYSTEMS"), ",") {
+		if strings.TrimSpace(s) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Daemon wraps the daemons which have no fatal errors as a Run.
+func Daemon(d func(done chan struct{})) Run {
+	return func(done chan struct{}) error {
+		d(done)
+		return nil
+	}
+}
+
+type subsystem struct {
+	name   string
+	run    Run
+	done   chan struct{}
+	exited chan struct{}
+}
+
+// Manager starts the subsystems in the order they are added, so a subsystem is added after
+// the ones it depends on, and stops them in the reverse order.
+type Manager struct {
+	subsystems []*subsystem
+	once       sync.Once
+	err        error
+}
+
+func New() *Manager {
+	return &Manager{}
+}
+
+// Add adds a subsystem unless it is disabled.
+func (m *Manager) Add(name string, run Run) {
+	if Disabled(name) {
+		logrus.Infof("subsystem %s is disabled", name)
+		return
+	}
+	m.subsystems = append(m.subsystems, &subsystem{name: name, run: run})
+}
+
+// Run starts all subsystems and blocks until one of them fails or the process is interrupted,
+// then stops them all and returns the first fatal error.
+func (m *Manager) Run() error {
+	return m.RunUntil(Interrupted())
+}
+
+// RunUntil starts all subsystems and blocks until one of them fails or done is closed, then
+// stops them all and returns the first fatal error.
+func (m *Manager) RunUntil(done chan struct{}) error {
+	g, ctx := errgroup.WithContext(context.Background())
+
+	for _, s := range m.subsystems {
+		s := s
+		s.done = make(chan struct{})
+		s.exited = make(chan struct{})
+
+		logrus.Debugf("starting subsystem %s", s.name)
+		g.Go(func() error {
+			defer close(s.exited)
+			if err := s.run(s.done); err != nil {
+				err = errors.Wrapf(err, errSubsystem, s.name)
+				m.once.Do(func() { m.err = err })
+				return err
+			}
+			return nil
+		})
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+
+	if stuck := m.stop(); len(stuck) > 0 {
+		m.once.Do(func() { m.err = errors.Errorf(errStopTimeout, stuck, stopTimeout) })
+		return m.err
+	}
+	return g.Wait()
+}
+
+// Interrupted returns a channel which is closed once the process receives SIGINT or SIGTERM.
+func Interrupted() chan struct{} {
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		logrus.Infof("received %s, stopping subsystems", sig)
+		close(done)
+	}()
+	return done
+}
+
+// Used to stop the subsystems in the reverse order, the names of those which did not stop in time are returned
+func (m *Manager) stop() []string {
+	stuck := make([]string, 0)
+	for i := len(m.subsystems) - 1; i >= 0; i-- {
+		s := m.subsystems[i]
+		close(s.done)
+
+		select {
+		case <-s.exited:
+			logrus.Debugf("stopped subsystem %s", s.name)
+		case <-time.After(stopTimeout):
+			logrus.Warnf("subsystem %s did not stop within %s", s.name, stopTimeout)
+			stuck = append(stuck, s.name)
+		}
+	}
+	return stuck
+}
@@ -0,0 +1,48 @@
+package lifecycle
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var warming = struct {
+	sync.Mutex
+	names map[string]int
+}{names: make(map[string]int)}
+
+// Warming marks a subsystem as warming up until the returned func is called, the instance is not
+// ready until every subsystem is warm.
+func Warming(name string) func() {
+	warming.Lock()
+	warming.names[name]++
+	warming.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			warming.Lock()
+			defer warming.Unlock()
+			if warming.names[name]--; warming.names[name] <= 0 {
+				delete(warming.names, name)
+			}
+		})
+	}
+}
+
+// Warm returns an error naming the subsystems which are still warming up.
+func Warm() error {
+	warming.Lock()
+	defer warming.Unlock()
+
+	if len(warming.names) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(warming.names))
+	for name := range warming.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return errors.Errorf(errWarming, names)
+}
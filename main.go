@@ -3,9 +3,24 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
 
+	"github.com/rancher/rdns-server/command/agent"
+	"github.com/rancher/rdns-server/command/client"
+	"github.com/rancher/rdns-server/command/dnsbench"
+	"github.com/rancher/rdns-server/command/dynamodb"
 	"github.com/rancher/rdns-server/command/etcdv3"
+	"github.com/rancher/rdns-server/command/keyring"
+	"github.com/rancher/rdns-server/command/memory"
+	"github.com/rancher/rdns-server/command/output"
 	"github.com/rancher/rdns-server/command/route53"
+	"github.com/rancher/rdns-server/command/smoke"
+	"github.com/rancher/rdns-server/command/sql"
+	"github.com/rancher/rdns-server/importer"
+	"github.com/rancher/rdns-server/migration/etcdv2"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
@@ -15,6 +30,15 @@ var (
 	DNSDate    string
 )
 
+// clientCommands are the commands which run on any node and any os
+var clientCommands = map[string]bool{
+	agent.Name:    true,
+	keyring.Name:  true,
+	client.Name:   true,
+	dnsbench.Name: true,
+	smoke.Name:    true,
+}
+
 func init() {
 	cli.VersionPrinter = versionPrinter
 }
@@ -33,6 +57,23 @@ func main() {
 			EnvVar: "DEBUG",
 			Usage:  "used to set debug mode.",
 		},
+		cli.StringFlag{
+			Name:   "log-format",
+			EnvVar: "LOG_FORMAT",
+			Usage:  "used to set the format of the logs, text or json.",
+			Value:  "text",
+		},
+		cli.BoolFlag{
+			Name:   "test-mode",
+			EnvVar: "TEST_MODE",
+			Usage:  "used to set test mode, slugs and tokens are generated from --test-mode-seed and domains never expire, never use it for real domains.",
+		},
+		cli.StringFlag{
+			Name:   "test-mode-seed",
+			EnvVar: "TEST_MODE_SEED",
+			Usage:  "used to set the seed of the generated slugs and tokens in test mode.",
+			Value:  "1",
+		},
 		cli.StringFlag{
 			Name:   "listen",
 			EnvVar: "LISTEN",
@@ -45,6 +86,330 @@ func main() {
 			Usage:  "used to set the duration when the domain name can be used again.",
 			Value:  "2160h",
 		},
+		cli.StringFlag{
+			Name:   "admin_token",
+			EnvVar: "ADMIN_TOKEN",
+			Usage:  "used to set the bearer token of the admin api, the admin api is disabled when empty.",
+		},
+		cli.StringFlag{
+			Name:   "admin_roles_file",
+			EnvVar: "ADMIN_ROLES_FILE",
+			Usage:  "used to set the json file of the admin tokens and oidc groups which are mapped to viewer, abuse-handler or operator roles.",
+		},
+		cli.StringFlag{
+			Name:   "metrics_exporter",
+			EnvVar: "METRICS_EXPORTER",
+			Usage:  "used to set the push metrics exporter, statsd or otlp, metrics are only scraped from /metrics when empty.",
+		},
+		cli.StringFlag{
+			Name:   "metrics_endpoint",
+			EnvVar: "METRICS_ENDPOINT",
+			Usage:  "used to set the push metrics endpoint (e.g. 127.0.0.1:8125 or http://127.0.0.1:4318/v1/metrics).",
+		},
+		cli.StringFlag{
+			Name:   "metrics_interval",
+			EnvVar: "METRICS_INTERVAL",
+			Usage:  "used to set the push metrics interval.",
+			Value:  "10s",
+		},
+		cli.StringFlag{
+			Name:   "slo_alert_webhook",
+			EnvVar: "SLO_ALERT_WEBHOOK",
+			Usage:  "used to set the webhook url which slo burn-rate alerts are posted to.",
+		},
+		cli.StringFlag{
+			Name:   "slo_canary_resolver",
+			EnvVar: "SLO_CANARY_RESOLVER",
+			Usage:  "used to set the dns resolver the canary prober resolves its records with (e.g. 127.0.0.1:53), the prober is disabled when empty.",
+		},
+		cli.StringFlag{
+			Name:   "slo_canary_interval",
+			EnvVar: "SLO_CANARY_INTERVAL",
+			Usage:  "used to set the canary prober interval.",
+			Value:  "1m",
+		},
+		cli.StringFlag{
+			Name:   "slo_resolve_threshold",
+			EnvVar: "SLO_RESOLVE_THRESHOLD",
+			Usage:  "used to set the duration a canary record must be resolved within.",
+			Value:  "10s",
+		},
+		cli.StringFlag{
+			Name:   "double_write_backend",
+			EnvVar: "DOUBLE_WRITE_BACKEND",
+			Usage:  "used to set the new backend (etcdv3, route53, dynamodb or sql) which writes are mirrored to, it is configured with the environments of its own command.",
+		},
+		cli.StringFlag{
+			Name:   "double_write_state",
+			EnvVar: "DOUBLE_WRITE_STATE",
+			Usage:  "used to set the initial double-write state, old, read-old, read-new or new.",
+			Value:  "read-old",
+		},
+		cli.StringFlag{
+			Name:   "acme_txt_ttl",
+			EnvVar: "ACME_TXT_TTL",
+			Usage:  "used to set how long _acme-challenge TXT records live before they are cleaned up, 0s keeps them as long as the domain.",
+			Value:  "1h",
+		},
+		cli.StringFlag{
+			Name:   "rpz_zone",
+			EnvVar: "RPZ_ZONE",
+			Usage:  "used to set the origin of the response policy zone which suspended domains are exported to.",
+			Value:  "rpz.local",
+		},
+		cli.StringFlag{
+			Name:   "rpz_file",
+			EnvVar: "RPZ_FILE",
+			Usage:  "used to set the file the response policy zone is written to (e.g. /etc/rdns/config/rpz.db), it is only served by the admin api when empty.",
+		},
+		cli.StringFlag{
+			Name:   "rpz_interval",
+			EnvVar: "RPZ_INTERVAL",
+			Usage:  "used to set the interval the response policy zone file is written.",
+			Value:  "1m",
+		},
+		cli.StringFlag{
+			Name:   "migrate_data",
+			EnvVar: "MIGRATE_DATA",
+			Usage:  "used to set whether pending data migrations are applied on startup, true or false.",
+			Value:  "true",
+		},
+		cli.StringFlag{
+			Name:   "health_check_port",
+			EnvVar: "HEALTH_CHECK_PORT",
+			Usage:  "used to set the tcp port the hosts of a domain are checked on (e.g. 443), health checks are disabled when empty.",
+		},
+		cli.StringFlag{
+			Name:   "health_check_interval",
+			EnvVar: "HEALTH_CHECK_INTERVAL",
+			Usage:  "used to set the interval the hosts of the watched domains are checked.",
+			Value:  "30s",
+		},
+		cli.StringFlag{
+			Name:   "health_check_timeout",
+			EnvVar: "HEALTH_CHECK_TIMEOUT",
+			Usage:  "used to set the timeout of connecting to a host.",
+			Value:  "2s",
+		},
+		cli.StringFlag{
+			Name:   "health_check_parallel",
+			EnvVar: "HEALTH_CHECK_PARALLEL",
+			Usage:  "used to set how many hosts of a domain are checked at once.",
+			Value:  "16",
+		},
+		cli.StringFlag{
+			Name:   "webhook_url",
+			EnvVar: "WEBHOOK_URL",
+			Usage:  "used to set the webhook url which the events of all domains are posted to.",
+		},
+		cli.StringFlag{
+			Name:   "webhook_secret",
+			EnvVar: "WEBHOOK_SECRET",
+			Usage:  "used to set the secret which the events posted to the webhook url are signed with.",
+		},
+		cli.StringFlag{
+			Name:   "webhook_workers",
+			EnvVar: "WEBHOOK_WORKERS",
+			Usage:  "used to set how many webhook events are delivered at once.",
+			Value:  "4",
+		},
+		cli.StringFlag{
+			Name:   "webhook_queue_size",
+			EnvVar: "WEBHOOK_QUEUE_SIZE",
+			Usage:  "deprecated, webhook deliveries wait in the job queue of the backend.",
+			Value:  "1024",
+		},
+		cli.StringFlag{
+			Name:   "job_visibility_timeout",
+			EnvVar: "JOB_VISIBILITY_TIMEOUT",
+			Usage:  "used to set how long a claimed job is hidden from other workers, it is retried afterwards unless it is done.",
+			Value:  "30s",
+		},
+		cli.StringFlag{
+			Name:   "job_poll_interval",
+			EnvVar: "JOB_POLL_INTERVAL",
+			Usage:  "used to set the interval the job queue of the backend is polled.",
+			Value:  "1s",
+		},
+		cli.StringFlag{
+			Name:   "state_sync_interval",
+			EnvVar: "STATE_SYNC_INTERVAL",
+			Usage:  "used to set the interval the operational state is synced from the backend.",
+			Value:  "10s",
+		},
+		cli.StringFlag{
+			Name:   "expiry_check_interval",
+			EnvVar: "EXPIRY_CHECK_INTERVAL",
+			Usage:  "used to set the interval the expiration of the domains is checked.",
+			Value:  "5m",
+		},
+		cli.StringFlag{
+			Name:   "expiry_warning",
+			EnvVar: "EXPIRY_WARNING",
+			Usage:  "used to set how long before their expiration the owners of domains are warned.",
+			Value:  "24h",
+		},
+		cli.StringFlag{
+			Name:   "expiry_webhook_url",
+			EnvVar: "EXPIRY_WEBHOOK_URL",
+			Usage:  "used to set the webhook url which the expiring and expired events of all domains are posted to.",
+		},
+		cli.StringFlag{
+			Name:   "domain_ttl_min",
+			EnvVar: "DOMAIN_TTL_MIN",
+			Usage:  "used to set the lowest ttl in seconds which domain owners can set.",
+			Value:  "30",
+		},
+		cli.StringFlag{
+			Name:   "domain_ttl_max",
+			EnvVar: "DOMAIN_TTL_MAX",
+			Usage:  "used to set the highest ttl in seconds which domain owners can set.",
+			Value:  "3600",
+		},
+		cli.StringFlag{
+			Name:   "domain_lease_min",
+			EnvVar: "DOMAIN_LEASE_MIN",
+			Usage:  "used to set the shortest lease which domain owners can request, shorter leases are raised to it.",
+			Value:  "1h",
+		},
+		cli.StringFlag{
+			Name:   "domain_lease_max",
+			EnvVar: "DOMAIN_LEASE_MAX",
+			Usage:  "used to set the longest lease which domain owners can request, longer leases are cut to it.",
+			Value:  "720h",
+		},
+		cli.StringFlag{
+			Name:   "token_recovery_port",
+			EnvVar: "TOKEN_RECOVERY_PORT",
+			Usage:  "used to set the port the hosts serve the token recovery challenge on, empty disables token recovery.",
+		},
+		cli.StringFlag{
+			Name:   "tls_cert",
+			EnvVar: "TLS_CERT",
+			Usage:  "used to set the certificate file the api is served with over https, the api is served over http when empty.",
+		},
+		cli.StringFlag{
+			Name:   "tls_key",
+			EnvVar: "TLS_KEY",
+			Usage:  "used to set the key file of the tls certificate.",
+		},
+		cli.StringFlag{
+			Name:   "tls_client_ca",
+			EnvVar: "TLS_CLIENT_CA",
+			Usage:  "used to set the ca file which the certificates of the api clients must be signed by, empty allows clients without certificates.",
+		},
+		cli.StringFlag{
+			Name:   "record_change_limit",
+			EnvVar: "RECORD_CHANGE_LIMIT",
+			Usage:  "used to set how many times per hour the hosts and TXT records of a domain can change, 0 disables the limit.",
+			Value:  "0",
+		},
+		cli.StringFlag{
+			Name:   "record_change_burst",
+			EnvVar: "RECORD_CHANGE_BURST",
+			Usage:  "used to set how many changes of a domain are allowed at once within the record change limit.",
+			Value:  "10",
+		},
+		cli.StringFlag{
+			Name:   "max_hosts",
+			EnvVar: "MAX_HOSTS",
+			Usage:  "used to set how many hosts a domain or a sub domain has at most.",
+			Value:  "64",
+		},
+		cli.StringFlag{
+			Name:   "reject_private_hosts",
+			EnvVar: "REJECT_PRIVATE_HOSTS",
+			Usage:  "used to set whether private, loopback and link local hosts are refused.",
+			Value:  "false",
+		},
+		cli.StringFlag{
+			Name:   "host_ownership",
+			EnvVar: "HOST_OWNERSHIP",
+			Usage:  "used to set how the hosts of created and updated domains are proven to belong to the client, source or proof, empty accepts any host.",
+		},
+		cli.StringFlag{
+			Name:   "host_proof_secret",
+			EnvVar: "HOST_PROOF_SECRET",
+			Usage:  "used to set the secret which the host proofs of the proof ownership are signed with.",
+		},
+		cli.StringFlag{
+			Name:   "trusted_proxies",
+			EnvVar: "TRUSTED_PROXIES",
+			Usage:  "used to set the addresses of the proxies whose X-Forwarded-For headers are trusted, comma separated ips or cidrs (e.g. 10.0.0.0/8).",
+		},
+		cli.StringFlag{
+			Name:   "disable_subsystems",
+			EnvVar: "DISABLE_SUBSYSTEMS",
+			Usage:  "used to set the subsystems which are not started (e.g. health,webhook,purge).",
+		},
+		cli.StringFlag{
+			Name:   "create_api_keys",
+			EnvVar: "CREATE_API_KEYS",
+			Usage:  "used to set the api keys which are required to create domains, comma separated, empty allows anyone to create domains.",
+		},
+		cli.StringFlag{
+			Name:   "create_api_keys_file",
+			EnvVar: "CREATE_API_KEYS_FILE",
+			Usage:  "used to set the file of the api keys which are required to create domains, it lists a key per line.",
+		},
+		cli.StringFlag{
+			Name:   "reputation_providers",
+			EnvVar: "REPUTATION_PROVIDERS",
+			Usage:  "used to set the providers which the hosts of created and updated domains are checked with (e.g. cidr,dnsbl,api), empty disables the checks.",
+		},
+		cli.StringFlag{
+			Name:   "reputation_action",
+			EnvVar: "REPUTATION_ACTION",
+			Usage:  "used to set what happens to a domain with a listed host, reject refuses it and flag keeps it with a warning.",
+			Value:  "reject",
+		},
+		cli.StringFlag{
+			Name:   "reputation_cidr_file",
+			EnvVar: "REPUTATION_CIDR_FILE",
+			Usage:  "used to set the file of the cidr provider, it lists a network or an address per line.",
+		},
+		cli.StringFlag{
+			Name:   "reputation_dnsbl",
+			EnvVar: "REPUTATION_DNSBL",
+			Usage:  "used to set the zones of the dnsbl provider (e.g. zen.spamhaus.org).",
+		},
+		cli.StringFlag{
+			Name:   "reputation_api_url",
+			EnvVar: "REPUTATION_API_URL",
+			Usage:  "used to set the url the api provider asks with GET <url>?ip=<host>.",
+		},
+		cli.StringFlag{
+			Name:   "node_sync_url",
+			EnvVar: "NODE_SYNC_URL",
+			Usage:  "used to set the nodes api of a cluster whose removed nodes are removed from the domains (e.g. https://rancher.example.com/k8s/clusters/c-xxxxx/api/v1/nodes), empty disables polling.",
+		},
+		cli.StringFlag{
+			Name:   "node_sync_token",
+			EnvVar: "NODE_SYNC_TOKEN",
+			Usage:  "used to set the bearer token the nodes api is listed with.",
+		},
+		cli.StringFlag{
+			Name:   "node_sync_ca_file",
+			EnvVar: "NODE_SYNC_CA_FILE",
+			Usage:  "used to set the ca file of the nodes api, empty uses the system roots.",
+		},
+		cli.StringFlag{
+			Name:   "node_sync_interval",
+			EnvVar: "NODE_SYNC_INTERVAL",
+			Usage:  "used to set the interval the nodes api is listed.",
+			Value:  "1m",
+		},
+		cli.StringFlag{
+			Name:   "schedule_interval",
+			EnvVar: "SCHEDULE_INTERVAL",
+			Usage:  "used to set the interval the scheduled changes of domains which are due are made.",
+			Value:  "10s",
+		},
+		cli.StringFlag{
+			Name:   "config_file",
+			EnvVar: "CONFIG_FILE",
+			Usage:  "used to set the yaml file of the settings of root domains and zones, empty uses the environments alone.",
+		},
 	}
 	app.Commands = []cli.Command{
 		{
@@ -54,6 +419,25 @@ func main() {
 			Flags:   route53.Flags(),
 			Action:  route53.Action,
 		},
+		{
+			Name:    "dynamodb",
+			Aliases: []string{"ddb"},
+			Usage:   "use aws route53 backend with the records kept in a dynamodb table",
+			Flags:   dynamodb.Flags(),
+			Action:  dynamodb.Action,
+		},
+		{
+			Name:   "sql",
+			Usage:  "use mysql or postgres backend",
+			Flags:  sql.Flags(),
+			Action: sql.Action,
+		},
+		{
+			Name:   "memory",
+			Usage:  "use in-memory backend, for tests and demos",
+			Flags:  memory.Flags(),
+			Action: memory.Action,
+		},
 		{
 			Name:    "etcdv3",
 			Aliases: []string{"ev3"},
@@ -61,6 +445,128 @@ func main() {
 			Flags:   etcdv3.Flags(),
 			Action:  etcdv3.Action,
 		},
+		{
+			Name:   "etcdv3-reshard",
+			Usage:  "move etcd-v3 records to the key layout of --etcd_shards",
+			Flags:  append(etcdv3.Flags(), output.Flag()),
+			Action: output.Action(etcdv3.ReshardAction),
+		},
+		{
+			Name:   "etcdv3-verify",
+			Usage:  "check etcd-v3 records against their checksums",
+			Flags:  append(etcdv3.VerifyFlags(), output.Flag()),
+			Action: output.Action(etcdv3.VerifyAction),
+		},
+		{
+			Name:        agent.Name,
+			Usage:       "register this node and keep its domain renewed",
+			Flags:       agent.Flags(),
+			Action:      agent.Action,
+			Subcommands: agent.Commands(),
+		},
+		{
+			Name:        keyring.Name,
+			Usage:       "manage the tokens of several domains in an encrypted local keyring",
+			Flags:       keyring.Flags(),
+			Subcommands: keyring.Commands(),
+		},
+		{
+			Name:        client.Name,
+			Usage:       "manage the records of a domain on a running server",
+			Flags:       client.Flags(),
+			Subcommands: client.Commands(),
+		},
+		{
+			Name:   dnsbench.Name,
+			Usage:  "benchmark the answers of the authoritative server with a mix of queries",
+			Flags:  dnsbench.Flags(),
+			Action: dnsbench.Action,
+		},
+		{
+			Name:   smoke.Name,
+			Usage:  "verify the lifecycle of a domain against a live deployment",
+			Flags:  smoke.Flags(),
+			Action: smoke.Action,
+		},
+		{
+			Name:  "migrate-data",
+			Usage: "apply the pending data migrations of a backend",
+			Subcommands: []cli.Command{
+				{
+					Name:    "route53",
+					Aliases: []string{"r53"},
+					Usage:   "migrate aws route53 backend",
+					Flags:   append(route53.Flags(), output.Flag()),
+					Action:  output.Action(route53.MigrateDataAction),
+				},
+				{
+					Name:    "dynamodb",
+					Aliases: []string{"ddb"},
+					Usage:   "migrate aws route53 backend with a dynamodb table",
+					Flags:   append(dynamodb.Flags(), output.Flag()),
+					Action:  output.Action(dynamodb.MigrateDataAction),
+				},
+				{
+					Name:   "sql",
+					Usage:  "migrate mysql or postgres backend",
+					Flags:  append(sql.Flags(), output.Flag()),
+					Action: output.Action(sql.MigrateDataAction),
+				},
+				{
+					Name:    "etcdv3",
+					Aliases: []string{"ev3"},
+					Usage:   "migrate etcd-v3 backend",
+					Flags:   append(etcdv3.Flags(), output.Flag()),
+					Action:  output.Action(etcdv3.MigrateDataAction),
+				},
+			},
+		},
+		{
+			Name:  "import",
+			Usage: "import the names of acme-dns or a dynamic dns service with new tokens",
+			Subcommands: []cli.Command{
+				{
+					Name:    "route53",
+					Aliases: []string{"r53"},
+					Usage:   "import into aws route53 backend",
+					Flags:   append(append(route53.Flags(), importer.Flags()...), output.Flag()),
+					Action:  output.Action(route53.ImportAction),
+				},
+				{
+					Name:    "dynamodb",
+					Aliases: []string{"ddb"},
+					Usage:   "import into aws route53 backend with a dynamodb table",
+					Flags:   append(append(dynamodb.Flags(), importer.Flags()...), output.Flag()),
+					Action:  output.Action(dynamodb.ImportAction),
+				},
+				{
+					Name:   "sql",
+					Usage:  "import into mysql or postgres backend",
+					Flags:  append(append(sql.Flags(), importer.Flags()...), output.Flag()),
+					Action: output.Action(sql.ImportAction),
+				},
+				{
+					Name:    "etcdv3",
+					Aliases: []string{"ev3"},
+					Usage:   "import into etcd-v3 backend",
+					Flags:   append(append(etcdv3.Flags(), importer.Flags()...), output.Flag()),
+					Action:  output.Action(etcdv3.ImportAction),
+				},
+			},
+		},
+		{
+			Name:  "migrate",
+			Usage: "migrate the domains of the v0.4.x etcd-v2 tree, including their tokens and TXT records",
+			Subcommands: []cli.Command{
+				{
+					Name:    "etcdv3",
+					Aliases: []string{"ev3"},
+					Usage:   "migrate into etcd-v3 backend",
+					Flags:   append(append(etcdv3.Flags(), etcdv2.Flags()...), output.Flag()),
+					Action:  output.Action(etcdv3.MigrateV2Action),
+				},
+			},
+		},
 	}
 	if err := app.Run(os.Args); err != nil {
 		logrus.Fatal(err)
@@ -68,14 +574,30 @@ func main() {
 }
 
 func beforeFunc(c *cli.Context) error {
-	if os.Getuid() != 0 {
+	switch c.GlobalString("log-format") {
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	case "text":
+	default:
+		return errors.Errorf("invalid log format: %s", c.GlobalString("log-format"))
+	}
+	// the agent and the keyring are clients of the api, they run as any user
+	if os.Getuid() != 0 && !clientCommands[c.Args().First()] {
 		logrus.Fatalf("%s: need to be root", os.Args[0])
 	}
+	if c.GlobalBool("test-mode") {
+		seed, err := strconv.ParseInt(c.GlobalString("test-mode-seed"), 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid test mode seed: %s", c.GlobalString("test-mode-seed"))
+		}
+		util.SetTestMode(seed)
+		logrus.Warnf("running in test mode with seed %d, slugs and tokens are predictable", seed)
+	}
 	return nil
 }
 
 func versionPrinter(c *cli.Context) {
-	if _, err := fmt.Fprintf(c.App.Writer, DNSVersion); err != nil {
+	if _, err := fmt.Fprintf(c.App.Writer, "%s", DNSVersion); err != nil {
 		logrus.Error(err)
 	}
 }
@@ -0,0 +1,9 @@
+package metric
+
+const (
+	errEmptyEndpoint   = "metrics exporter %s requires an endpoint"
+	errExportMetrics   = "failed to export metrics with %s"
+	errGatherMetrics   = "failed to gather metrics"
+	errUnknownExporter = "unknown metrics exporter: %s"
+	errUnexpectedReply = "unexpected reply status from %s: %s"
+)
@@ -0,0 +1,80 @@
+package metric
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	exporterStatsD = "statsd"
+	exporterOTLP   = "otlp"
+)
+
+// Exporter pushes the gathered metric families to a monitoring system,
+// it is used where no prometheus scrapes the /metrics endpoint.
+type Exporter interface {
+	Name() string
+	Export(families []*dto.MetricFamily) error
+}
+
+func newExporter(name, endpoint string) (Exporter, error) {
+	if endpoint == "" {
+		return nil, errors.Errorf(errEmptyEndpoint, name)
+	}
+
+	switch name {
+	case exporterStatsD:
+		return newStatsDExporter(endpoint)
+	case exporterOTLP:
+		return newOTLPExporter(endpoint), nil
+	}
+
+	return nil, errors.Errorf(errUnknownExporter, name)
+}
+
+// StartExporterDaemon pushes the metrics with the exporter of METRICS_EXPORTER every
+// METRICS_INTERVAL, it returns at once when no exporter is configured.
+func StartExporterDaemon(done chan struct{}) {
+	name := os.Getenv("METRICS_EXPORTER")
+	if name == "" {
+		return
+	}
+
+	e, err := newExporter(name, os.Getenv("METRICS_ENDPOINT"))
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	interval, err := time.ParseDuration(os.Getenv("METRICS_INTERVAL"))
+	if err != nil || interval <= 0 {
+		logrus.Errorf("invalid metrics interval %s, use %s", os.Getenv("METRICS_INTERVAL"), queryDuration)
+		interval = queryDuration
+	}
+
+	logrus.Infof("pushing metrics with %s exporter every %s", e.Name(), interval)
+	wait.Until(func() {
+		if err := export(e); err != nil {
+			logrus.Error(err)
+		}
+	}, interval, done)
+}
+
+func export(e Exporter) error {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return errors.Wrap(err, errGatherMetrics)
+	}
+
+	if err := e.Export(families); err != nil {
+		return errors.Wrapf(err, errExportMetrics, e.Name())
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package metric
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	otlpServiceName = "rdns-server"
+	// cumulative aggregation temporality, which matches prometheus counters
+	otlpCumulative = 2
+	otlpTimeout    = 5 * time.Second
+)
+
+// otlpExporter posts the metrics to an OTLP/HTTP collector with the json encoding,
+// e.g. http://127.0.0.1:4318/v1/metrics
+type otlpExporter struct {
+	endpoint string
+	start    string
+	client   *http.Client
+}
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpMetric struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Gauge       *otlpData `json:"gauge,omitempty"`
+	Sum         *otlpData `json:"sum,omitempty"`
+	Histogram   *otlpData `json:"histogram,omitempty"`
+	Summary     *otlpData `json:"summary,omitempty"`
+}
+
+type otlpData struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality,omitempty"`
+	IsMonotonic            bool            `json:"isMonotonic,omitempty"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          *float64        `json:"asDouble,omitempty"`
+	Count             string          `json:"count,omitempty"`
+	Sum               *float64        `json:"sum,omitempty"`
+	BucketCounts      []string        `json:"bucketCounts,omitempty"`
+	ExplicitBounds    []float64       `json:"explicitBounds,omitempty"`
+	QuantileValues    []otlpQuantile  `json:"quantileValues,omitempty"`
+}
+
+type otlpQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+func newOTLPExporter(endpoint string) *otlpExporter {
+	return &otlpExporter{
+		endpoint: endpoint,
+		start:    unixNano(time.Now()),
+		client:   &http.Client{Timeout: otlpTimeout},
+	}
+}
+
+func (e *otlpExporter) Name() string {
+	return exporterOTLP
+}
+
+func (e *otlpExporter) Export(families []*dto.MetricFamily) error {
+	now := unixNano(time.Now())
+
+	metrics := make([]otlpMetric, 0, len(families))
+	for _, f := range families {
+		metrics = append(metrics, e.convert(f, now))
+	}
+
+	body, err := json.Marshal(&otlpRequest{
+		ResourceMetrics: []otlpResourceMetrics{
+			{
+				Resource: otlpResource{
+					Attributes: []otlpAttribute{{Key: "service.name", Value: otlpAnyValue{StringValue: otlpServiceName}}},
+				},
+				ScopeMetrics: []otlpScopeMetrics{
+					{
+						Scope:   otlpScope{Name: otlpServiceName},
+						Metrics: metrics,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf(errUnexpectedReply, e.endpoint, resp.Status)
+	}
+
+	return nil
+}
+
+func (e *otlpExporter) convert(f *dto.MetricFamily, now string) otlpMetric {
+	m := otlpMetric{
+		Name:        f.GetName(),
+		Description: f.GetHelp(),
+	}
+
+	points := make([]otlpDataPoint, 0, len(f.GetMetric()))
+	for _, v := range f.GetMetric() {
+		p := otlpDataPoint{
+			Attributes:   otlpAttributes(v.GetLabel()),
+			TimeUnixNano: now,
+		}
+
+		switch f.GetType() {
+		case dto.MetricType_COUNTER:
+			p.StartTimeUnixNano = e.start
+			p.AsDouble = float64Ptr(v.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			p.AsDouble = float64Ptr(v.GetGauge().GetValue())
+		case dto.MetricType_HISTOGRAM:
+			h := v.GetHistogram()
+			p.StartTimeUnixNano = e.start
+			p.Count = strconv.FormatUint(h.GetSampleCount(), 10)
+			p.Sum = float64Ptr(h.GetSampleSum())
+			// prometheus buckets are cumulative, otlp buckets are not
+			var prev uint64
+			for _, b := range h.GetBucket() {
+				p.ExplicitBounds = append(p.ExplicitBounds, b.GetUpperBound())
+				p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-prev, 10))
+				prev = b.GetCumulativeCount()
+			}
+			p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(h.GetSampleCount()-prev, 10))
+		case dto.MetricType_SUMMARY:
+			s := v.GetSummary()
+			p.StartTimeUnixNano = e.start
+			p.Count = strconv.FormatUint(s.GetSampleCount(), 10)
+			p.Sum = float64Ptr(s.GetSampleSum())
+			for _, q := range s.GetQuantile() {
+				p.QuantileValues = append(p.QuantileValues, otlpQuantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+			}
+		default:
+			p.AsDouble = float64Ptr(v.GetUntyped().GetValue())
+		}
+
+		points = append(points, p)
+	}
+
+	switch f.GetType() {
+	case dto.MetricType_COUNTER:
+		m.Sum = &otlpData{DataPoints: points, AggregationTemporality: otlpCumulative, IsMonotonic: true}
+	case dto.MetricType_HISTOGRAM:
+		m.Histogram = &otlpData{DataPoints: points, AggregationTemporality: otlpCumulative}
+	case dto.MetricType_SUMMARY:
+		m.Summary = &otlpData{DataPoints: points}
+	default:
+		m.Gauge = &otlpData{DataPoints: points}
+	}
+
+	return m
+}
+
+func otlpAttributes(labels []*dto.LabelPair) []otlpAttribute {
+	attrs := make([]otlpAttribute, 0, len(labels))
+	for _, l := range labels {
+		attrs = append(attrs, otlpAttribute{Key: l.GetName(), Value: otlpAnyValue{StringValue: l.GetValue()}})
+	}
+	return attrs
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,122 @@
+package metric
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// keep a packet under the common ethernet mtu
+const maxStatsDPacketSize = 1432
+
+// statsDExporter writes the metrics in the dogstatsd line format, labels are sent as tags.
+// Counters are sent as the delta since the last export, others as gauges.
+type statsDExporter struct {
+	conn net.Conn
+	last map[string]float64
+}
+
+func newStatsDExporter(endpoint string) (*statsDExporter, error) {
+	conn, err := net.Dial("udp", endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &statsDExporter{
+		conn: conn,
+		last: make(map[string]float64),
+	}, nil
+}
+
+func (e *statsDExporter) Name() string {
+	return exporterStatsD
+}
+
+func (e *statsDExporter) Export(families []*dto.MetricFamily) error {
+	var packet bytes.Buffer
+
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			tags := statsDTags(m.GetLabel())
+
+			for _, line := range e.lines(f, m, tags) {
+				if packet.Len() > 0 && packet.Len()+len(line)+1 > maxStatsDPacketSize {
+					if _, err := e.conn.Write(packet.Bytes()); err != nil {
+						return err
+					}
+					packet.Reset()
+				}
+				if packet.Len() > 0 {
+					packet.WriteByte('\n')
+				}
+				packet.WriteString(line)
+			}
+		}
+	}
+
+	if packet.Len() > 0 {
+		if _, err := e.conn.Write(packet.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *statsDExporter) lines(f *dto.MetricFamily, m *dto.Metric, tags string) []string {
+	name := f.GetName()
+
+	switch f.GetType() {
+	case dto.MetricType_COUNTER:
+		key := name + tags
+		v := m.GetCounter().GetValue()
+		delta := v - e.last[key]
+		if delta < 0 {
+			// the counter is reset
+			delta = v
+		}
+		e.last[key] = v
+		return []string{statsDLine(name, delta, "c", tags)}
+	case dto.MetricType_GAUGE:
+		return []string{statsDLine(name, m.GetGauge().GetValue(), "g", tags)}
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		return []string{
+			statsDLine(name+"_sum", h.GetSampleSum(), "g", tags),
+			statsDLine(name+"_count", float64(h.GetSampleCount()), "g", tags),
+		}
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		return []string{
+			statsDLine(name+"_sum", s.GetSampleSum(), "g", tags),
+			statsDLine(name+"_count", float64(s.GetSampleCount()), "g", tags),
+		}
+	}
+
+	return []string{statsDLine(name, m.GetUntyped().GetValue(), "g", tags)}
+}
+
+// Used to format a statsd line
+// e.g. rancher_dns_tokens:10|g|#backend:etcdv3
+func statsDLine(name string, value float64, typ, tags string) string {
+	return fmt.Sprintf("%s:%s|%s%s", name, strconv.FormatFloat(value, 'f', -1, 64), typ, tags)
+}
+
+// Used to format labels as dogstatsd tags
+// e.g. [{backend etcdv3}] => |#backend:etcdv3
+func statsDTags(labels []*dto.LabelPair) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	ss := make([]string, 0, len(labels))
+	for _, l := range labels {
+		ss = append(ss, l.GetName()+":"+l.GetValue())
+	}
+
+	return "|#" + strings.Join(ss, ",")
+}
@@ -0,0 +1,8 @@
+package migration
+
+const (
+	errApplyMigration   = "failed to apply data migration %s to %s"
+	errListMigrations   = "failed to list applied data migrations of %s"
+	errRecordMigration  = "failed to record data migration %s of %s"
+	errUnsupportedStore = "backend %s can not record data migrations"
+)
@@ -0,0 +1,10 @@
+package etcdv2
+
+const (
+	errDecodeNode      = "failed to decode etcd-v2 key %s"
+	errMigrateDomain   = "failed to migrate domain %s"
+	errMigrateFrozen   = "failed to migrate frozen slug %s"
+	errReadTree        = "failed to read etcd-v2 tree %s"
+	errUnexpectedReply = "unexpected reply status from %s: %s"
+	errVerify          = "%d of %d domains differ between etcd-v2 and the backend"
+)
@@ -0,0 +1,99 @@
+package etcdv2
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// The v0.4.x servers keep the domains in the etcd-v2 tree of the reversed zone, every domain is a
+// directory whose ttl is the lease of the domain:
+// <prefix>/cloud/rancher/lb/qrn7oq/<host key>               => {"host": "1.1.1.1"}
+// <prefix>/cloud/rancher/lb/qrn7oq/token_origin             => the token origin of the domain
+// <prefix>/cloud/rancher/lb/qrn7oq/_txt                     => {"text": "..."} of qrn7oq.lb.rancher.cloud
+// <prefix>/cloud/rancher/lb/qrn7oq/x1/<host key>            => {"host": "2.2.2.2"} of x1.qrn7oq.lb.rancher.cloud
+// <prefix>/cloud/rancher/lb/qrn7oq/_acme-challenge/_txt     => {"text": "..."} of _acme-challenge.qrn7oq.lb.rancher.cloud
+// <frozen prefix>/qrn7oq                                    => the slug which is frozen until its ttl
+
+const (
+	tokenOriginKey = "token_origin"
+	textKey        = "_txt"
+
+	readTimeout = time.Minute
+)
+
+func Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "etcd_v2_endpoint",
+			Usage: "used to set the etcd-v2 endpoint the v0.4.x servers write to.",
+			Value: "http://127.0.0.1:2379",
+		},
+		cli.StringFlag{
+			Name:  "etcd_v2_prefix",
+			Usage: "used to set the etcd-v2 directory of the domains.",
+			Value: "/rdns",
+		},
+		cli.StringFlag{
+			Name:  "etcd_v2_frozen_prefix",
+			Usage: "used to set the etcd-v2 directory of the frozen slugs, empty skips them.",
+			Value: "/frozen",
+		},
+		cli.BoolFlag{
+			Name:  "migrate_dry_run",
+			Usage: "used to print what would be migrated without writing to the backend.",
+		},
+		cli.BoolFlag{
+			Name:  "migrate_verify",
+			Usage: "used to compare every domain of etcd-v2 with the backend after it is migrated, or without migrating it with --migrate_dry_run.",
+		},
+	}
+}
+
+// node is a key of the etcd-v2 keys api
+type node struct {
+	Key        string     `json:"key"`
+	Value      string     `json:"value"`
+	Dir        bool       `json:"dir"`
+	Nodes      []*node    `json:"nodes"`
+	Expiration *time.Time `json:"expiration"`
+}
+
+func (n *node) name() string {
+	return n.Key[strings.LastIndex(n.Key, "/")+1:]
+}
+
+// Used to read a directory of etcd-v2 with all keys below it, an empty tree is not an error
+// e.g. http://127.0.0.1:2379, /rdns/cloud/rancher/lb => GET http://127.0.0.1:2379/v2/keys/rdns/cloud/rancher/lb?recursive=true
+func readTree(endpoint, path string) (*node, error) {
+	client := &http.Client{Timeout: readTimeout}
+	u := strings.TrimRight(endpoint, "/") + "/v2/keys" + path + "?recursive=true&sorted=true"
+
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, errReadTree, path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return &node{Key: path, Dir: true}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(errUnexpectedReply, u, resp.Status)
+	}
+
+	var r struct {
+		Node *node `json:"node"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, errors.Wrapf(err, errReadTree, path)
+	}
+	if r.Node == nil {
+		return nil, errors.Errorf(errUnexpectedReply, u, resp.Status)
+	}
+	return r.Node, nil
+}
@@ -0,0 +1,294 @@
+package etcdv2
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/dual"
+	"github.com/rancher/rdns-server/command/output"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// domain is a domain of the etcd-v2 tree with all records below it
+type domain struct {
+	fqdn       string
+	token      string
+	hosts      []string
+	subDomain  map[string][]string
+	texts      map[string]string
+	expiration time.Time
+}
+
+type frozen struct {
+	slug       string
+	expiration time.Time
+}
+
+type value struct {
+	Host string `json:"host"`
+	Text string `json:"text"`
+}
+
+// summary is printed by the migration with the output flag, Differ are the domains which differ
+// between etcd-v2 and the backend after the migration is verified
+type summary struct {
+	Domains int      `json:"domains"`
+	Frozen  int      `json:"frozen"`
+	DryRun  bool     `json:"dryRun"`
+	Differ  []string `json:"differ"`
+}
+
+// Migrate reads the domains of the v0.4.x etcd-v2 tree and writes them to the backend, the domains
+// which have expired are skipped and the domains which exist in the backend are overwritten.
+// A domain directory without ttl gets the lease time of the backend, the v0.4.x servers only have
+// one zone which is migrated to the default root domain of the backend.
+func Migrate(c *cli.Context, b backend.Backend, leaseTime time.Duration) error {
+	zone := b.GetZone()
+	dryRun, verify := c.Bool("migrate_dry_run"), c.Bool("migrate_verify")
+
+	root, err := readTree(c.String("etcd_v2_endpoint"), c.String("etcd_v2_prefix")+zonePath(zone))
+	if err != nil {
+		return err
+	}
+	ds := domains(root, zone, leaseTime)
+
+	var fs []frozen
+	if p := c.String("etcd_v2_frozen_prefix"); p != "" {
+		tree, err := readTree(c.String("etcd_v2_endpoint"), p)
+		if err != nil {
+			return err
+		}
+		fs = frozens(tree)
+	}
+
+	for _, d := range ds {
+		if dryRun {
+			logrus.Infof("would migrate domain %s expiring at %s: %d hosts, %d sub domains, %d TXT records",
+				d.fqdn, d.expiration.Format(time.RFC3339), len(d.hosts), len(d.subDomain), len(d.texts))
+			continue
+		}
+		if err := migrateDomain(b, d); err != nil {
+			return errors.Wrapf(err, errMigrateDomain, d.fqdn)
+		}
+	}
+	for _, f := range fs {
+		if dryRun {
+			logrus.Infof("would migrate frozen slug %s expiring at %s", f.slug, f.expiration.Format(time.RFC3339))
+			continue
+		}
+		if err := b.MigrateFrozen(&model.MigrateFrozen{Path: f.slug, Expiration: &f.expiration}); err != nil {
+			return errors.Wrapf(err, errMigrateFrozen, f.slug)
+		}
+	}
+	if !dryRun {
+		logrus.Infof("migrated %d domains and %d frozen slugs from etcd-v2", len(ds), len(fs))
+	}
+
+	s := summary{Domains: len(ds), Frozen: len(fs), DryRun: dryRun, Differ: make([]string, 0)}
+	if verify {
+		for _, d := range ds {
+			diffs := verifyDomain(b, d)
+			for _, diff := range diffs {
+				logrus.Warnf("domain %s differs: %s", d.fqdn, diff)
+			}
+			if len(diffs) > 0 {
+				s.Differ = append(s.Differ, d.fqdn)
+			}
+		}
+		if len(s.Differ) == 0 {
+			logrus.Infof("verified %d domains against the backend", len(ds))
+		}
+	}
+
+	if output.Requested(c) {
+		if err := output.Write(c, s); err != nil {
+			return err
+		}
+	}
+	if len(s.Differ) > 0 {
+		return output.Partialf(errVerify, len(s.Differ), len(ds))
+	}
+	return nil
+}
+
+// The token is written first, because the records are written with the lease of the token.
+func migrateDomain(b backend.Backend, d *domain) error {
+	if err := b.MigrateToken(&model.MigrateToken{
+		Path:       dual.TokenPath(b, d.fqdn),
+		Token:      d.token,
+		Expiration: &d.expiration,
+	}); err != nil {
+		return err
+	}
+
+	if err := b.MigrateRecord(&model.MigrateRecord{
+		Fqdn:      d.fqdn,
+		Hosts:     d.hosts,
+		SubDomain: d.subDomain,
+	}); err != nil {
+		return err
+	}
+
+	for fqdn, text := range d.texts {
+		if err := b.MigrateRecord(&model.MigrateRecord{Fqdn: fqdn, Text: text}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyDomain(b backend.Backend, d *domain) (diffs []string) {
+	token, err := b.GetToken(d.fqdn)
+	if err != nil {
+		return append(diffs, err.Error())
+	}
+	if token != d.token {
+		diffs = append(diffs, "the token differs")
+	}
+
+	got, err := b.Get(&model.DomainOptions{Fqdn: d.fqdn})
+	if err != nil {
+		return append(diffs, err.Error())
+	}
+	if !sameHosts(got.Hosts, d.hosts) {
+		diffs = append(diffs, fmt.Sprintf("hosts are %v instead of %v", got.Hosts, d.hosts))
+	}
+	for sub, hosts := range d.subDomain {
+		if !sameHosts(got.SubDomain[sub], hosts) {
+			diffs = append(diffs, fmt.Sprintf("hosts of sub domain %s are %v instead of %v", sub, got.SubDomain[sub], hosts))
+		}
+	}
+
+	for fqdn, text := range d.texts {
+		got, err := b.GetText(&model.DomainOptions{Fqdn: fqdn})
+		if err != nil {
+			diffs = append(diffs, err.Error())
+			continue
+		}
+		if got.Text != text {
+			diffs = append(diffs, fmt.Sprintf("TXT record of %s is %q instead of %q", fqdn, got.Text, text))
+		}
+	}
+	return diffs
+}
+
+// Used to convert the directories below the zone to domains, every directory is a domain and keeps
+// its sub domains and TXT records in the directories below it.
+func domains(root *node, zone string, leaseTime time.Duration) []*domain {
+	now := time.Now()
+	ds := make([]*domain, 0)
+
+	for _, n := range root.Nodes {
+		if !n.Dir {
+			continue
+		}
+
+		fqdn := fmt.Sprintf("%s.%s", n.name(), zone)
+		if n.Expiration != nil && !n.Expiration.After(now) {
+			logrus.Debugf("skip domain %s which has expired at %s", fqdn, n.Expiration.Format(time.RFC3339))
+			continue
+		}
+
+		d := &domain{
+			fqdn:       fqdn,
+			hosts:      make([]string, 0),
+			subDomain:  make(map[string][]string),
+			texts:      make(map[string]string),
+			expiration: now.Add(leaseTime),
+		}
+		if n.Expiration != nil {
+			d.expiration = *n.Expiration
+		}
+		walk(d, n, fqdn)
+
+		if d.token == "" {
+			logrus.Warnf("skip domain %s which has no %s key", fqdn, tokenOriginKey)
+			continue
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+func walk(d *domain, dir *node, fqdn string) {
+	for _, n := range dir.Nodes {
+		if n.Dir {
+			walk(d, n, fmt.Sprintf("%s.%s", n.name(), fqdn))
+			continue
+		}
+
+		switch n.name() {
+		case tokenOriginKey:
+			if fqdn == d.fqdn {
+				d.token = n.Value
+			}
+		case textKey:
+			if fqdn == d.fqdn {
+				logrus.Warnf("skip TXT record of domain %s, only the names below a domain have TXT records", fqdn)
+				continue
+			}
+			d.texts[fqdn] = decode(n).Text
+		default:
+			v := decode(n)
+			if v.Host == "" {
+				continue
+			}
+			if fqdn == d.fqdn {
+				d.hosts = append(d.hosts, v.Host)
+				continue
+			}
+			sub := strings.TrimSuffix(fqdn, "."+d.fqdn)
+			d.subDomain[sub] = append(d.subDomain[sub], v.Host)
+		}
+	}
+}
+
+func frozens(root *node) []frozen {
+	now := time.Now()
+	fs := make([]frozen, 0)
+
+	for _, n := range root.Nodes {
+		if n.Expiration == nil || !n.Expiration.After(now) {
+			continue
+		}
+		fs = append(fs, frozen{slug: n.name(), expiration: *n.Expiration})
+	}
+	return fs
+}
+
+// The values are json, the value of a TXT record is also kept as plain text by some v0.4.x versions.
+func decode(n *node) value {
+	var v value
+	if err := json.Unmarshal([]byte(n.Value), &v); err != nil {
+		if n.name() == textKey {
+			return value{Text: n.Value}
+		}
+		logrus.Warn(errors.Wrapf(err, errDecodeNode, n.Key))
+	}
+	return v
+}
+
+// e.g. lb.rancher.cloud => /cloud/rancher/lb
+func zonePath(zone string) string {
+	labels := strings.Split(strings.Trim(zone, "."), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return "/" + strings.Join(labels, "/")
+}
+
+func sameHosts(a, b []string) bool {
+	x, y := append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	return len(x) == len(y) && (len(x) == 0 || reflect.DeepEqual(x, y))
+}
@@ -0,0 +1,148 @@
+package migration
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/dual"
+	"github.com/rancher/rdns-server/backend/etcdv3"
+	"github.com/rancher/rdns-server/backend/multiroot"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Migration is a change of the stored data which ships with a release. Migrations are
+// applied in the order of the list and recorded in the backend once they succeed, a
+// migration may run again when it fails before it is recorded so it must be idempotent.
+type Migration struct {
+	// ID is recorded in the backend, it must never change once released
+	ID string
+	// Backend limits the migration to one backend, empty applies it to every backend
+	Backend     string
+	Description string
+	Up          func(b backend.Backend) error
+}
+
+// Store is implemented by the backends which record the applied data migrations.
+type Store interface {
+	AppliedMigrations() (map[string]time.Time, error)
+	RecordMigration(id string) error
+}
+
+// migrations are only appended, released migrations are never removed or reordered
+var migrations = []Migration{
+	{
+		ID:          "0001-etcdv3-reindex",
+		Backend:     etcdv3.Name,
+		Description: "index the domains which were written before the search and host indexes exist",
+		Up: func(b backend.Backend) error {
+			n, err := b.(*etcdv3.Backend).Reindex()
+			logrus.Infof("indexed %d domains", n)
+			return err
+		},
+	},
+	{
+		ID:          "0002-etcdv3-token-path",
+		Backend:     etcdv3.Name,
+		Description: "move the tokens from /tokenv3 to the token path under the prefix and the root domain",
+		Up: func(b backend.Backend) error {
+			n, err := b.(*etcdv3.Backend).MoveTokens()
+			logrus.Infof("moved %d tokens", n)
+			return err
+		},
+	},
+}
+
+// Result is the result of a run the migrate-data commands print with the output flag.
+type Result struct {
+	Applied int `json:"applied"`
+}
+
+// Run applies the pending data migrations of the backend, both backends of the
+// double-write mode are migrated. It returns the number of applied migrations.
+func Run(b backend.Backend) (int, error) {
+	if d, ok := b.(*dual.Backend); ok {
+		n, err := Run(d.Old)
+		if err != nil {
+			return n, err
+		}
+		m, err := Run(d.New)
+		return n + m, err
+	}
+
+	// the root domains share the store of the prefix, a migration is applied to every one of them
+	// before it is recorded once
+	roots := []backend.Backend{b}
+	if r, ok := b.(*multiroot.Backend); ok {
+		roots = r.Backends()
+		b = roots[0]
+	}
+
+	pending, err := Pending(b)
+	if err != nil {
+		return 0, err
+	}
+
+	s := b.(Store)
+	for i, m := range pending {
+		logrus.Infof("applying data migration %s to %s: %s", m.ID, b.GetName(), m.Description)
+		for _, r := range roots {
+			if err := m.Up(r); err != nil {
+				return i, errors.Wrapf(err, errApplyMigration, m.ID, b.GetName())
+			}
+		}
+		if err := s.RecordMigration(m.ID); err != nil {
+			return i, errors.Wrapf(err, errRecordMigration, m.ID, b.GetName())
+		}
+	}
+
+	return len(pending), nil
+}
+
+// RunOnStartup applies the pending data migrations before the api is served, it is
+// skipped when MIGRATE_DATA is false so they can be applied with the migrate-data command.
+func RunOnStartup(b backend.Backend) error {
+	if enabled, err := strconv.ParseBool(os.Getenv("MIGRATE_DATA")); err == nil && !enabled {
+		logrus.Info("data migrations are not applied on startup")
+		return nil
+	}
+
+	n, err := Run(b)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		logrus.Infof("applied %d data migrations", n)
+	}
+
+	return nil
+}
+
+// Pending returns the data migrations of the backend which are not applied yet in order.
+func Pending(b backend.Backend) ([]Migration, error) {
+	s, ok := b.(Store)
+	if !ok {
+		return nil, errors.Errorf(errUnsupportedStore, b.GetName())
+	}
+
+	applied, err := s.AppliedMigrations()
+	if err != nil {
+		return nil, errors.Wrapf(err, errListMigrations, b.GetName())
+	}
+
+	pending := make([]Migration, 0)
+	for _, m := range migrations {
+		if m.Backend != "" && m.Backend != b.GetName() {
+			continue
+		}
+		if _, ok := applied[m.ID]; ok {
+			continue
+		}
+		pending = append(pending, m)
+	}
+
+	return pending, nil
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LabelTenant is the label of the domains which belong to a tenant, the domains created with an
+// api key of a tenant get it.
+const LabelTenant = "tenant"
+
+// APIKey is provisioned by an admin for a trusted controller which manages many domains, it is
+// used instead of the tokens of the domains. A key of a tenant only manages the domains labeled
+// with the tenant, a key of a root domain manages the domains below it.
+type APIKey struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Tenant     string `json:"tenant,omitempty"`
+	RootDomain string `json:"rootDomain,omitempty"`
+	// Key is only returned when the api key is created, the backends keep the hash of its secret
+	Key       string     `json:"key,omitempty"`
+	Hash      string     `json:"hash,omitempty"`
+	CreatedOn *time.Time `json:"createdOn,omitempty"`
+}
+
+type APIKeyOptions struct {
+	Name       string `json:"name" schema:"required"`
+	Tenant     string `json:"tenant" schema:"pattern=^[A-Za-z0-9-]*$"`
+	RootDomain string `json:"rootDomain"`
+}
+
+type APIKeyResponse struct {
+	Status  int    `json:"status"`
+	Message string `json:"msg"`
+	Data    APIKey `json:"data"`
+}
+
+type APIKeyListResponse struct {
+	Status  int      `json:"status"`
+	Message string   `json:"msg"`
+	Data    []APIKey `json:"data"`
+}
+
+func ParseAPIKeyOptions(r *http.Request) (*APIKeyOptions, error) {
+	var opts APIKeyOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
+
+// Validate checks an api key has a scope, a key without tenant and root domain would manage every domain.
+func (o *APIKeyOptions) Validate() error {
+	if o.Name == "" {
+		return errors.New("expected the name of the api key")
+	}
+	if o.Tenant == "" && o.RootDomain == "" {
+		return errors.New("expected the tenant or the root domain of the api key")
+	}
+	if o.Tenant != "" && !orderPattern.MatchString(o.Tenant) {
+		return errors.Errorf("invalid tenant %s, expected letters, digits and hyphens", o.Tenant)
+	}
+	return nil
+}
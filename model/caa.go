@@ -0,0 +1,80 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	CAATagIssue     = "issue"
+	CAATagIssueWild = "issuewild"
+	CAATagIODEF     = "iodef"
+
+	// CAAFlagCritical tells CAs which do not understand the tag to refuse issuing
+	CAAFlagCritical = 128
+
+	maxCAARecords     = 16
+	maxCAAValueLength = 255
+)
+
+// CAARecord restricts the CAs which may issue certificates for a domain and its sub domains,
+// e.g. {"flag": 0, "tag": "issue", "value": "letsencrypt.org"}
+type CAARecord struct {
+	Flag  uint8  `json:"flag" schema:"enum=0|128"`
+	Tag   string `json:"tag" schema:"required;enum=issue|issuewild|iodef"`
+	Value string `json:"value" schema:"maxLength=255"`
+}
+
+// CAAOptions replaces all CAA records of a domain.
+type CAAOptions struct {
+	Records []CAARecord `json:"records" schema:"required"`
+}
+
+type CAAResponse struct {
+	Status  int         `json:"status"`
+	Message string      `json:"msg"`
+	Data    []CAARecord `json:"data"`
+}
+
+func ParseCAAOptions(r *http.Request) (*CAAOptions, error) {
+	var opts CAAOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
+
+// Validate checks the tags and values, only the tags of RFC 8659 are accepted.
+func (o *CAAOptions) Validate() error {
+	if len(o.Records) == 0 {
+		return errors.New("expected at least one CAA record")
+	}
+	if len(o.Records) > maxCAARecords {
+		return errors.Errorf("a domain has at most %d CAA records", maxCAARecords)
+	}
+
+	for i := range o.Records {
+		if err := o.Records[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks the tag, flag and value of one CAA record.
+func (r *CAARecord) Validate() error {
+	switch r.Tag {
+	case CAATagIssue, CAATagIssueWild, CAATagIODEF:
+	default:
+		return errors.Errorf("invalid CAA tag %s, expected one of %s, %s or %s", r.Tag, CAATagIssue, CAATagIssueWild, CAATagIODEF)
+	}
+	if r.Flag != 0 && r.Flag != CAAFlagCritical {
+		return errors.Errorf("invalid CAA flag %d, expected 0 or %d", r.Flag, CAAFlagCritical)
+	}
+	// an empty issue value forbids every CA, iodef needs an url to report to
+	if (r.Tag == CAATagIODEF && r.Value == "") || len(r.Value) > maxCAAValueLength {
+		return errors.Errorf("invalid CAA value %q of tag %s", r.Value, r.Tag)
+	}
+	return nil
+}
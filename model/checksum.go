@@ -0,0 +1,55 @@
+package model
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChecksumHeader carries the checksum of the records of the domain an answer holds, so a client can
+// tell the records it decodes are the ones the server read.
+const ChecksumHeader = "X-Record-Checksum"
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksum gets the checksum of the canonical form of the records of the domain, one line of type,
+// name and value for every record in sorted order. The expiration, labels and drains are left out,
+// so it only changes with the records.
+// e.g. {fqdn: sample.lb.rancher.cloud., hosts: [1.1.1.1]} => crc32c("A sample.lb.rancher.cloud 1.1.1.1\n")
+func (d *Domain) Checksum() string {
+	fqdn := strings.TrimSuffix(strings.ToLower(d.Fqdn), ".")
+	lines := make([]string, 0, len(d.Hosts)+2)
+	for _, h := range d.Hosts {
+		lines = append(lines, recordLine(fqdn, h))
+	}
+	for name, hosts := range d.SubDomain {
+		for _, h := range hosts {
+			lines = append(lines, recordLine(name+"."+fqdn, h))
+		}
+	}
+	if d.CNAME != "" {
+		lines = append(lines, fmt.Sprintf("%s %s %s", TypeCNAME, fqdn, d.CNAME))
+	}
+	texts := d.Texts
+	if len(texts) == 0 && d.Text != "" {
+		texts = []string{d.Text}
+	}
+	for _, t := range texts {
+		lines = append(lines, fmt.Sprintf("%s %s %s", TypeTXT, fqdn, strconv.Quote(t)))
+	}
+	sort.Strings(lines)
+	if d.TTL > 0 {
+		lines = append(lines, fmt.Sprintf("TTL %d", d.TTL))
+	}
+
+	return fmt.Sprintf("%08x", crc32.Checksum([]byte(strings.Join(lines, "\n")+"\n"), castagnoli))
+}
+
+func recordLine(name, host string) string {
+	if strings.Contains(host, ":") {
+		return fmt.Sprintf("%s %s %s", TypeAAAA, name, host)
+	}
+	return fmt.Sprintf("%s %s %s", TypeA, name, host)
+}
@@ -0,0 +1,53 @@
+package model
+
+// Settings override the settings of the level above, settings which are not set are inherited.
+// The levels are resolved from the environments to the global settings of the config file,
+// then to the settings of the root domain and then to the settings of the zone of a name.
+type Settings struct {
+	TTLMin           *uint32 `json:"ttlMin,omitempty" yaml:"ttlMin,omitempty"`
+	TTLMax           *uint32 `json:"ttlMax,omitempty" yaml:"ttlMax,omitempty"`
+	LeaseMin         string  `json:"leaseMin,omitempty" yaml:"leaseMin,omitempty"`
+	LeaseMax         string  `json:"leaseMax,omitempty" yaml:"leaseMax,omitempty"`
+	SlugLength       *int    `json:"slugLength,omitempty" yaml:"slugLength,omitempty"`
+	MaxWebhooks      *int    `json:"maxWebhooks,omitempty" yaml:"maxWebhooks,omitempty"`
+	ReputationAction string  `json:"reputationAction,omitempty" yaml:"reputationAction,omitempty"`
+	// QueryTypes are the record types the domains may answer, empty means every type
+	QueryTypes []string `json:"queryTypes,omitempty" yaml:"queryTypes,omitempty"`
+}
+
+// RootSettings are the settings of a root domain and of the zones under it.
+type RootSettings struct {
+	Settings `yaml:",inline"`
+	Zones    map[string]Settings `json:"zones,omitempty" yaml:"zones,omitempty"`
+}
+
+// ConfigFile is the file of CONFIG_FILE, e.g.
+//
+//	global:
+//	  ttlMax: 600
+//	roots:
+//	  lb.rancher.cloud:
+//	    slugLength: 8
+//	    zones:
+//	      eu.lb.rancher.cloud:
+//	        maxWebhooks: 2
+type ConfigFile struct {
+	Global Settings                `json:"global" yaml:"global"`
+	Roots  map[string]RootSettings `json:"roots,omitempty" yaml:"roots,omitempty"`
+}
+
+// Config tells which settings apply to a name and which root and zone they are resolved from.
+type Config struct {
+	Fqdn     string      `json:"fqdn"`
+	Root     string      `json:"root,omitempty"`
+	Zone     string      `json:"zone,omitempty"`
+	Settings Settings    `json:"settings"`
+	File     string      `json:"file,omitempty"`
+	Config   *ConfigFile `json:"config,omitempty"`
+}
+
+type ConfigResponse struct {
+	Status  int    `json:"status"`
+	Message string `json:"msg"`
+	Data    Config `json:"data"`
+}
@@ -9,6 +9,18 @@ type Token struct {
 	CreatedOn int64  `db:"created_on"`
 }
 
+// TokenFilter selects tokens by their domain indexes, the created times are in unix nano
+// and a filter is skipped when its value is empty.
+type TokenFilter struct {
+	Host          string
+	Indexes       map[string]string
+	Text          string
+	CreatedAfter  int64
+	CreatedBefore int64
+	LastID        int64
+	Limit         int64
+}
+
 type FrozenPrefix struct {
 	ID        int64  `db:"id"`
 	Prefix    string `db:"prefix"`
@@ -45,6 +57,17 @@ type RecordTXT struct {
 	TID       int64         `db:"tid"`
 }
 
+// TXTOrder is the TXT value of one ACME order, the TXT record of the fqdn answers the
+// values of all its orders.
+type TXTOrder struct {
+	ID        int64  `db:"id"`
+	Fqdn      string `db:"fqdn"`
+	OrderID   string `db:"order_id"`
+	Content   string `db:"content"`
+	CreatedOn int64  `db:"created_on"`
+	TID       int64  `db:"tid"`
+}
+
 type RecordCNAME struct {
 	ID        int64         `db:"id"`
 	Fqdn      string        `db:"fqdn"`
@@ -54,3 +77,63 @@ type RecordCNAME struct {
 	UpdatedOn sql.NullInt64 `db:"updated_on"`
 	TID       int64         `db:"tid"`
 }
+
+type SuspendedDomain struct {
+	ID        int64  `db:"id"`
+	Fqdn      string `db:"fqdn"`
+	Reason    string `db:"reason"`
+	CreatedOn int64  `db:"created_on"`
+}
+
+// DomainWebhook is a webhook of a domain, its events are kept as a comma separated list.
+type DomainWebhook struct {
+	ID        string `db:"id"`
+	Fqdn      string `db:"fqdn"`
+	URL       string `db:"url"`
+	Secret    string `db:"secret"`
+	Events    string `db:"events"`
+	CreatedOn int64  `db:"created_on"`
+	TID       int64  `db:"tid"`
+}
+
+// DomainScopedToken is the origin of a secondary token of a domain, there is one per scope.
+type DomainScopedToken struct {
+	Fqdn      string `db:"fqdn"`
+	Scope     string `db:"scope"`
+	Token     string `db:"token"`
+	CreatedOn int64  `db:"created_on"`
+	TID       int64  `db:"tid"`
+}
+
+// DatabaseAPIKey is an api key, its secret is kept as a hash.
+type DatabaseAPIKey struct {
+	ID         string `db:"id"`
+	Name       string `db:"name"`
+	Tenant     string `db:"tenant"`
+	RootDomain string `db:"root_domain"`
+	Hash       string `db:"hash"`
+	CreatedOn  int64  `db:"created_on"`
+}
+
+// DatabaseJob is a job of the queue, its visibility is kept as unix milliseconds.
+type DatabaseJob struct {
+	ID        string `db:"id"`
+	Kind      string `db:"kind"`
+	Payload   string `db:"payload"`
+	Attempts  int    `db:"attempts"`
+	CreatedOn int64  `db:"created_on"`
+	VisibleOn int64  `db:"visible_on"`
+}
+
+type DataMigration struct {
+	ID        string `db:"id"`
+	AppliedOn int64  `db:"applied_on"`
+}
+
+// DatabaseOperationalState is a value of the operational state, its update time is kept as unix seconds.
+type DatabaseOperationalState struct {
+	Key       string `db:"state_key"`
+	Value     string `db:"value"`
+	Version   int64  `db:"version"`
+	UpdatedOn int64  `db:"updated_on"`
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DeviceCode is issued to a device which asks for the token of a domain, the owner of the domain
+// approves the user code at the verification uri and the device polls for the token meanwhile.
+type DeviceCode struct {
+	Fqdn                    string `json:"fqdn"`
+	DeviceCode              string `json:"deviceCode"`
+	UserCode                string `json:"userCode"`
+	VerificationURI         string `json:"verificationURI"`
+	VerificationURIComplete string `json:"verificationURIComplete"`
+	// ExpiresIn and Interval are in seconds
+	ExpiresIn int64 `json:"expiresIn"`
+	Interval  int64 `json:"interval"`
+}
+
+type DeviceOptions struct {
+	Fqdn       string `json:"fqdn"`
+	DeviceCode string `json:"deviceCode"`
+	UserCode   string `json:"userCode"`
+}
+
+type DeviceCodeResponse struct {
+	Status  int        `json:"status"`
+	Message string     `json:"msg"`
+	Data    DeviceCode `json:"data"`
+}
+
+func ParseDeviceOptions(r *http.Request) (*DeviceOptions, error) {
+	var opts DeviceOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"sort"
+)
+
+// RecordChange is the values which were added to and removed from the records of a name and type,
+// e.g. {"name": "x1.qrn7oq.lb.rancher.cloud", "type": "A", "added": ["2.2.2.2"], "removed": ["1.1.1.1"]}
+type RecordChange struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// DiffRecords returns the changes from the records of before to the records of after, a nil domain
+// has no records. The changes are sorted by name and type.
+func DiffRecords(before, after *Domain) []RecordChange {
+	b, a := records(before), records(after)
+
+	keys := make(map[[2]string]bool)
+	for k := range b {
+		keys[k] = true
+	}
+	for k := range a {
+		keys[k] = true
+	}
+
+	changes := make([]RecordChange, 0)
+	for k := range keys {
+		added, removed := difference(a[k], b[k]), difference(b[k], a[k])
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		changes = append(changes, RecordChange{Name: k[0], Type: k[1], Added: added, Removed: removed})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		return changes[i].Type < changes[j].Type
+	})
+	return changes
+}
+
+// records are the values of a domain by name and type
+func records(d *Domain) map[[2]string][]string {
+	rs := make(map[[2]string][]string)
+	if d == nil {
+		return rs
+	}
+
+	addHosts := func(name string, hosts []string) {
+		for _, h := range hosts {
+			t := "A"
+			if IsIPv6(h) {
+				t = "AAAA"
+			}
+			k := [2]string{name, t}
+			rs[k] = append(rs[k], h)
+		}
+	}
+
+	addHosts(d.Fqdn, d.Hosts)
+	for sub, hosts := range d.SubDomain {
+		addHosts(sub+"."+d.Fqdn, hosts)
+	}
+	if d.Text != "" {
+		rs[[2]string{d.Fqdn, "TXT"}] = []string{d.Text}
+	}
+	if d.CNAME != "" {
+		rs[[2]string{d.Fqdn, "CNAME"}] = []string{d.CNAME}
+	}
+	return rs
+}
+
+// the values of x which are not in y
+func difference(x, y []string) []string {
+	in := make(map[string]bool, len(y))
+	for _, v := range y {
+		in[v] = true
+	}
+
+	var d []string
+	for _, v := range x {
+		if !in[v] {
+			d = append(d, v)
+		}
+	}
+	sort.Strings(d)
+	return d
+}
@@ -1,19 +1,46 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
 	"time"
+
+	"github.com/pkg/errors"
+)
+
+const maxOrderLength = 64
+
+var (
+	orderPattern = regexp.MustCompile(`^[A-Za-z0-9-]*$`)
+	// a requested name is a dns label of 3 to 63 lowercase letters, digits and hyphens
+	namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
 )
 
 type Domain struct {
-	Fqdn       string              `json:"fqdn,omitempty"`
-	Hosts      []string            `json:"hosts,omitempty"`
-	SubDomain  map[string][]string `json:"subdomain,omitempty"`
-	Text       string              `json:"text,omitempty"`
-	CNAME      string              `json:"cname,omitempty"`
-	Expiration *time.Time          `json:"expiration,omitempty"`
+	Fqdn      string              `json:"fqdn,omitempty"`
+	Hosts     []string            `json:"hosts,omitempty"`
+	SubDomain map[string][]string `json:"subdomain,omitempty"`
+	Text      string              `json:"text,omitempty"`
+	// Texts are all values of a TXT record which is read without an order, Text is the first one
+	Texts      []string          `json:"texts,omitempty"`
+	CNAME      string            `json:"cname,omitempty"`
+	Order      string            `json:"order,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	CreatorIP  string            `json:"creatorIP,omitempty"`
+	TTL        uint32            `json:"ttl,omitempty"`
+	Expiration *time.Time        `json:"expiration,omitempty"`
+	// Deadline is the absolute expiry of a temporary domain, renewals never move its expiration past it
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Drained are the hosts which are left out of the answers, until the time
+	Drained map[string]*time.Time `json:"drained,omitempty"`
+	// Propagation is only set by backends whose name servers pick up changes with a delay
+	Propagation *Propagation `json:"propagation,omitempty"`
+	// Annotations are the labels of the external-dns ownership TXT record of the domain
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 func (d *Domain) String() string {
@@ -35,7 +62,23 @@ type DomainOptions struct {
 	SubDomain map[string][]string `json:"subdomain"`
 	Text      string              `json:"text"`
 	CNAME     string              `json:"cname"`
+	Order     string              `json:"order"`
+	Labels    map[string]string   `json:"labels"`
 	Normal    bool                `json:"normal"`
+	// Name is the requested slug of a new domain, empty means a random slug
+	Name string `json:"name" schema:"pattern=^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$"`
+	// Lease is the seconds a new domain lives without renewal, 0 means the default lease
+	Lease int64 `json:"lease"`
+	// Root is the root domain of a new domain, empty means the first root domain of the server
+	Root string `json:"root,omitempty"`
+	// Deadline is the absolute expiry of a new temporary domain, empty means it lives as long as it is renewed
+	Deadline *time.Time `json:"deadline,omitempty"`
+
+	// CreatorIP is filled by the api from the request, it is not part of the payload
+	CreatorIP string `json:"-"`
+	// Replace is set by the api when a TXT value is updated without an order, the value is kept by
+	// its order and replaces all values of the fqdn. It is not part of the payload
+	Replace bool `json:"-"`
 }
 
 func (d *DomainOptions) String() string {
@@ -58,6 +101,41 @@ func ParseDomainOptions(r *http.Request) (*DomainOptions, error) {
 	return &opts, err
 }
 
+// ValidateOrder checks an ACME order id, it becomes part of the record key
+// e.g. 4f1b-9c2a => nil
+func ValidateOrder(order string) error {
+	if len(order) > maxOrderLength || !orderPattern.MatchString(order) {
+		return errors.Errorf("invalid order id: %s", order)
+	}
+	return nil
+}
+
+// ReplacedText gets the options of the TXT values an update replaces, all values of the fqdn when
+// Replace is set and the value of the order otherwise.
+func (d *DomainOptions) ReplacedText() *DomainOptions {
+	if !d.Replace {
+		return d
+	}
+	return &DomainOptions{Fqdn: d.Fqdn}
+}
+
+// TextOrder gets the order a TXT value is kept by when it is written without one, so the values of
+// one fqdn are kept apart by their content and writing a value twice keeps it once
+// e.g. abc => v-ba7816bf8f01cfea
+func TextOrder(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return "v-" + hex.EncodeToString(sum[:8])
+}
+
+// ValidateName checks the requested slug of a new domain, it becomes the first label of the fqdn
+// e.g. my-cluster => nil
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return errors.Errorf("invalid domain name: %s", name)
+	}
+	return nil
+}
+
 func mapToString(m map[string][]string) string {
 	b, err := json.Marshal(m)
 	if err != nil {
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+const (
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+	HealthUnknown   = "unknown"
+)
+
+type HostHealth struct {
+	Host    string     `json:"host"`
+	Up      bool       `json:"up"`
+	Latency int64      `json:"latencyMs"`
+	Checked *time.Time `json:"checked,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// DomainHealth aggregates the reachability of the hosts of a domain and its sub domains.
+type DomainHealth struct {
+	Fqdn   string       `json:"fqdn"`
+	Status string       `json:"status"`
+	Hosts  []HostHealth `json:"hosts"`
+}
+
+type HealthResponse struct {
+	Status  int          `json:"status"`
+	Message string       `json:"msg"`
+	Data    DomainHealth `json:"data"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The ownership TXT records of the external-dns txt registry label the records of a name with
+// their owner, an external-dns instance only changes the records of its own owner id
+// e.g. "heritage=external-dns,external-dns/owner=default,external-dns/resource=service/default/nginx"
+const (
+	HeritageExternalDNS = "external-dns"
+
+	LabelHeritage = "heritage"
+	LabelOwner    = "external-dns/owner"
+	LabelResource = "external-dns/resource"
+)
+
+// HeritageText gets the ownership TXT record of a resource of an owner, it is quoted like the ones
+// external-dns writes.
+func HeritageText(owner, resource string) string {
+	return fmt.Sprintf("\"%s=%s,%s=%s,%s=%s\"", LabelHeritage, HeritageExternalDNS, LabelOwner, owner, LabelResource, resource)
+}
+
+// ParseHeritage reads the labels of an ownership TXT record, texts which are not of the external-dns
+// heritage are not ownership records
+// e.g. "heritage=external-dns,external-dns/owner=default" => {heritage: external-dns, external-dns/owner: default}, true
+func ParseHeritage(text string) (map[string]string, bool) {
+	labels := make(map[string]string)
+	for _, l := range strings.Split(strings.Trim(text, "\""), ",") {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) != 2 {
+			return nil, false
+		}
+		labels[kv[0]] = kv[1]
+	}
+	if labels[LabelHeritage] != HeritageExternalDNS {
+		return nil, false
+	}
+	return labels, true
+}
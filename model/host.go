@@ -0,0 +1,81 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type HostDomains struct {
+	Host    string   `json:"host"`
+	Domains []string `json:"domains"`
+}
+
+type HostResponse struct {
+	Status  int         `json:"status"`
+	Message string      `json:"msg"`
+	Data    HostDomains `json:"data"`
+}
+
+type HostReplace struct {
+	From     string            `json:"from"`
+	To       string            `json:"to,omitempty"`
+	Replaced []string          `json:"replaced"`
+	Failed   map[string]string `json:"failed,omitempty"`
+}
+
+type HostReplaceOptions struct {
+	To string `json:"to"`
+}
+
+type HostReplaceResponse struct {
+	Status  int         `json:"status"`
+	Message string      `json:"msg"`
+	Data    HostReplace `json:"data"`
+}
+
+func ParseHostReplaceOptions(r *http.Request) (*HostReplaceOptions, error) {
+	var opts HostReplaceOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
+
+// DrainOptions holds the seconds a host is drained for, 0 means the default drain timeout.
+type DrainOptions struct {
+	Timeout int64 `json:"timeout"`
+}
+
+func ParseDrainOptions(r *http.Request) (*DrainOptions, error) {
+	var opts DrainOptions
+	if r.ContentLength == 0 {
+		return &opts, nil
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
+
+// NodeRemovalOptions is a node which is removed from its cluster, its addresses are removed
+// from the domains which point at them.
+type NodeRemovalOptions struct {
+	Node      string   `json:"node"`
+	Addresses []string `json:"addresses"`
+}
+
+type NodeRemoval struct {
+	Node  string        `json:"node"`
+	Hosts []HostReplace `json:"hosts"`
+}
+
+type NodeRemovalResponse struct {
+	Status  int         `json:"status"`
+	Message string      `json:"msg"`
+	Data    NodeRemoval `json:"data"`
+}
+
+func ParseNodeRemovalOptions(r *http.Request) (*NodeRemovalOptions, error) {
+	var opts NodeRemovalOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
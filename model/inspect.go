@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DomainInspection is what the backend keeps of a domain, it is only shown to the admins which
+// handle abuse. The token origin is not shown, its hash tells whether two domains share it.
+type DomainInspection struct {
+	Domain Domain `json:"domain"`
+	// TokenHash is the hex sha256 of the token origin of the domain
+	TokenHash     string      `json:"tokenHash"`
+	ReadOnlyToken bool        `json:"readOnlyToken"`
+	Suspension    *Suspension `json:"suspension,omitempty"`
+	CAA           []CAARecord `json:"caa,omitempty"`
+	QueryTypes    []string    `json:"queryTypes,omitempty"`
+	// Webhooks are listed without their secrets
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+}
+
+type DomainInspectionResponse struct {
+	Status  int              `json:"status"`
+	Message string           `json:"msg"`
+	Data    DomainInspection `json:"data"`
+}
+
+// ExpirationOptions moves the expiration of a domain sooner or later, either to a time or to
+// the seconds from now, e.g. {"expiration": "2026-10-15T00:00:00Z"} or {"lease": 3600}.
+type ExpirationOptions struct {
+	Expiration *time.Time `json:"expiration"`
+	Lease      int64      `json:"lease"`
+}
+
+func ParseExpirationOptions(r *http.Request) (*ExpirationOptions, error) {
+	var opts ExpirationOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
+
+// Until returns the time the domain expires at, one of expiration and lease must be set.
+func (o *ExpirationOptions) Until(now time.Time) (time.Time, error) {
+	switch {
+	case o.Expiration != nil && o.Lease != 0:
+		return time.Time{}, errors.New("expected either expiration or lease, not both")
+	case o.Expiration != nil:
+		if !o.Expiration.After(now) {
+			return time.Time{}, errors.Errorf("expiration %s is not in the future", o.Expiration.Format(time.RFC3339))
+		}
+		return *o.Expiration, nil
+	case o.Lease > 0:
+		return now.Add(time.Duration(o.Lease) * time.Second), nil
+	}
+	return time.Time{}, errors.New("expected a future expiration or a positive lease")
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"encoding/json"
+	"net"
+)
+
+// Hosts and SubDomain of a domain hold both IPv4 and IPv6 addresses, the backends store them
+// side by side and the dns plugin answers them as A and AAAA records. The json payload lists
+// the IPv6 addresses in the hostsv6 and subdomainv6 fields:
+// {"hosts": ["1.1.1.1"], "hostsv6": ["2001:db8::1"], "subdomainv6": {"x1": ["2001:db8::2"]}}
+
+type domain Domain
+
+type domainPayload struct {
+	*domain
+	Hosts       []string            `json:"hosts,omitempty" schema:"format=ipv4"`
+	HostsV6     []string            `json:"hostsv6,omitempty" schema:"format=ipv6"`
+	SubDomain   map[string][]string `json:"subdomain,omitempty"`
+	SubDomainV6 map[string][]string `json:"subdomainv6,omitempty"`
+}
+
+func (d Domain) MarshalJSON() ([]byte, error) {
+	p := domainPayload{domain: (*domain)(&d)}
+	p.Hosts, p.HostsV6 = SplitHosts(d.Hosts)
+	p.SubDomain, p.SubDomainV6 = splitSubDomain(d.SubDomain)
+	return json.Marshal(&p)
+}
+
+func (d *Domain) UnmarshalJSON(b []byte) error {
+	p := domainPayload{domain: (*domain)(d)}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	d.Hosts = append(p.Hosts, p.HostsV6...)
+	d.SubDomain = mergeSubDomain(p.SubDomain, p.SubDomainV6)
+	return nil
+}
+
+type domainOptions DomainOptions
+
+type domainOptionsPayload struct {
+	*domainOptions
+	Hosts       []string            `json:"hosts" schema:"format=ipv4"`
+	HostsV6     []string            `json:"hostsv6,omitempty" schema:"format=ipv6"`
+	SubDomain   map[string][]string `json:"subdomain"`
+	SubDomainV6 map[string][]string `json:"subdomainv6,omitempty"`
+}
+
+func (d DomainOptions) MarshalJSON() ([]byte, error) {
+	p := domainOptionsPayload{domainOptions: (*domainOptions)(&d)}
+	p.Hosts, p.HostsV6 = SplitHosts(d.Hosts)
+	p.SubDomain, p.SubDomainV6 = splitSubDomain(d.SubDomain)
+	return json.Marshal(&p)
+}
+
+// UnmarshalJSON merges the hosts fields with the hostsv6 fields, the validator of the api refuses the
+// hosts which are set with the fields of the other family.
+func (d *DomainOptions) UnmarshalJSON(b []byte) error {
+	p := domainOptionsPayload{domainOptions: (*domainOptions)(d)}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+
+	d.Hosts = append(p.Hosts, p.HostsV6...)
+	d.SubDomain = mergeSubDomain(p.SubDomain, p.SubDomainV6)
+	return nil
+}
+
+// IsIPv6 reports whether a host is an IPv6 address which is served as an AAAA record
+// e.g. 2001:db8::1 => true, 1.1.1.1 => false, ::ffff:1.1.1.1 => false
+func IsIPv6(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() == nil
+}
+
+// HasIPv6 reports whether any of the hosts or the sub domain hosts is an IPv6 address.
+func HasIPv6(hosts []string, subDomain map[string][]string) bool {
+	for _, h := range hosts {
+		if IsIPv6(h) {
+			return true
+		}
+	}
+	for _, hs := range subDomain {
+		if HasIPv6(hs, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitHosts splits hosts into the IPv4 hosts and the IPv6 hosts, keeping their order.
+func SplitHosts(hosts []string) (v4, v6 []string) {
+	for _, h := range hosts {
+		if IsIPv6(h) {
+			v6 = append(v6, h)
+		} else {
+			v4 = append(v4, h)
+		}
+	}
+	return v4, v6
+}
+
+func splitSubDomain(subDomain map[string][]string) (v4, v6 map[string][]string) {
+	for sub, hosts := range subDomain {
+		h4, h6 := SplitHosts(hosts)
+		if len(h4) > 0 || len(h6) == 0 {
+			if v4 == nil {
+				v4 = make(map[string][]string)
+			}
+			v4[sub] = append([]string{}, h4...)
+		}
+		if len(h6) > 0 {
+			if v6 == nil {
+				v6 = make(map[string][]string)
+			}
+			v6[sub] = h6
+		}
+	}
+	return v4, v6
+}
+
+func mergeSubDomain(v4, v6 map[string][]string) map[string][]string {
+	if len(v6) == 0 {
+		return v4
+	}
+	result := make(map[string][]string, len(v4)+len(v6))
+	for sub, hosts := range v4 {
+		result[sub] = append([]string{}, hosts...)
+	}
+	for sub, hosts := range v6 {
+		result[sub] = append(result[sub], hosts...)
+	}
+	return result
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job is a side effect which is kept in the backend until it is done, e.g. a webhook delivery.
+// A claimed job is invisible to other workers until its visibility timeout, so a job of a worker
+// which stopped is claimed again and jobs are done at least once.
+type Job struct {
+	ID       string          `json:"id"`
+	Kind     string          `json:"kind"`
+	Payload  json.RawMessage `json:"payload"`
+	Attempts int             `json:"attempts"`
+	Created  time.Time       `json:"created"`
+	Visible  time.Time       `json:"visible"`
+}
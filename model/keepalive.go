@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Keepalive is the answer of a keepalive, which only moves the expiration of a domain so the
+// records are not read back.
+type Keepalive struct {
+	Fqdn       string    `json:"fqdn"`
+	Expiration time.Time `json:"expiration"`
+}
+
+type KeepaliveResponse struct {
+	Status  int       `json:"status"`
+	Message string    `json:"msg"`
+	Data    Keepalive `json:"data"`
+}
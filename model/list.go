@@ -0,0 +1,49 @@
+package model
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+type ListOptions struct {
+	Limit    int64  `json:"limit"`
+	Continue string `json:"continue"`
+	// Root is the root domain which is listed, empty means the first root domain of the server
+	Root string `json:"root"`
+}
+
+type DomainList struct {
+	Items    []Domain `json:"items"`
+	Continue string   `json:"continue,omitempty"`
+	Revision int64    `json:"revision,omitempty"`
+}
+
+func ParseListOptions(r *http.Request) (*ListOptions, error) {
+	vals := r.URL.Query()
+	opts := &ListOptions{
+		Limit:    DefaultListLimit,
+		Continue: vals.Get("continue"),
+		Root:     strings.Trim(strings.ToLower(vals.Get("root")), "."),
+	}
+
+	if l := vals.Get("limit"); l != "" {
+		limit, err := strconv.ParseInt(l, 10, 64)
+		if err != nil || limit <= 0 {
+			return opts, errors.Errorf("invalid list limit: %s", l)
+		}
+		if limit > MaxListLimit {
+			limit = MaxListLimit
+		}
+		opts.Limit = limit
+	}
+
+	return opts, nil
+}
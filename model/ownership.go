@@ -0,0 +1,50 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderHostProof carries the proofs of the hosts of a request which are not its source address,
+// e.g. X-Host-Proof: 1.1.1.1=1559803622.<hex>, 2.2.2.2=1559803622.<hex>
+const HeaderHostProof = "X-Host-Proof"
+
+// HostProof signs a host until the time it expires, the owner of the secret vouches that the
+// holder of the proof may point domains at the host
+// e.g. 1559803622.<hex encoded HMAC-SHA256 of "1.1.1.1.1559803622">
+func HostProof(secret, host string, expires int64) string {
+	e := strconv.FormatInt(expires, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(host + "." + e))
+	return e + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyHostProof checks a proof is signed for the host with the secret and is not expired.
+func VerifyHostProof(secret, host, proof string, now time.Time) bool {
+	i := strings.Index(proof, ".")
+	if i <= 0 {
+		return false
+	}
+	expires, err := strconv.ParseInt(proof[:i], 10, 64)
+	if err != nil || now.Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(HostProof(secret, host, expires)), []byte(proof))
+}
+
+// ParseHostProofs reads the proofs of a HeaderHostProof value by host
+// e.g. "1.1.1.1=1559803622.ab12, 2.2.2.2=1559803622.cd34" => {1.1.1.1: 1559803622.ab12, 2.2.2.2: 1559803622.cd34}
+func ParseHostProofs(value string) map[string]string {
+	proofs := make(map[string]string)
+	for _, p := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+		if len(kv) == 2 && kv[0] != "" {
+			proofs[kv[0]] = kv[1]
+		}
+	}
+	return proofs
+}
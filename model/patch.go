@@ -0,0 +1,73 @@
+package model
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// MaxPatchHosts keeps a patch in one etcd transaction, every host is written with its host index
+// key and etcd allows 128 operations in a transaction by default.
+const MaxPatchHosts = 64
+
+// HostsPatch adds hosts to and removes hosts from a domain, the other hosts and the sub domains
+// are left as they are, e.g. {"add": ["2.2.2.2"], "remove": ["1.1.1.1"]}
+type HostsPatch struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+func ParseHostsPatch(r *http.Request) (*HostsPatch, error) {
+	var p HostsPatch
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&p)
+	return &p, err
+}
+
+// Validate checks the hosts are ips and a host is not added and removed at once.
+func (p *HostsPatch) Validate() error {
+	if len(p.Add) == 0 && len(p.Remove) == 0 {
+		return errors.New("expected hosts to add or to remove")
+	}
+	if len(p.Add)+len(p.Remove) > MaxPatchHosts {
+		return errors.Errorf("a patch has at most %d hosts, got %d", MaxPatchHosts, len(p.Add)+len(p.Remove))
+	}
+
+	added := make(map[string]bool, len(p.Add))
+	for _, h := range p.Add {
+		if net.ParseIP(h) == nil {
+			return errors.Errorf("invalid host ip: %s", h)
+		}
+		added[h] = true
+	}
+	for _, h := range p.Remove {
+		if net.ParseIP(h) == nil {
+			return errors.Errorf("invalid host ip: %s", h)
+		}
+		if added[h] {
+			return errors.Errorf("host %s is added and removed at once", h)
+		}
+	}
+	return nil
+}
+
+// Apply returns the hosts after the patch, the order of the kept hosts is not changed.
+func (p *HostsPatch) Apply(hosts []string) []string {
+	removed := make(map[string]bool, len(p.Remove))
+	for _, h := range p.Remove {
+		removed[h] = true
+	}
+
+	result := make([]string, 0, len(hosts)+len(p.Add))
+	seen := make(map[string]bool, len(hosts)+len(p.Add))
+	for _, h := range append(append([]string{}, hosts...), p.Add...) {
+		if h == "" || removed[h] || seen[h] {
+			continue
+		}
+		seen[h] = true
+		result = append(result, h)
+	}
+	return result
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+const (
+	// PropagationPending means the name servers of the backend may still answer the old records
+	PropagationPending = "pending"
+	// PropagationInSync means all name servers of the backend answer the new records
+	PropagationInSync = "insync"
+)
+
+// Propagation is the state of the last change of a domain on a backend whose name servers pick
+// up changes with a delay, the new records are answered everywhere by the deadline at the latest.
+type Propagation struct {
+	ChangeID string    `json:"changeID"`
+	Status   string    `json:"status"`
+	Deadline time.Time `json:"deadline"`
+}
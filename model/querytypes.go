@@ -0,0 +1,108 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// QueryTypes are the record types a domain can be restricted to, the other types are never
+// answered for a restricted domain.
+var QueryTypes = []string{TypeA, TypeAAAA, TypeCNAME, TypeTXT, TypeCAA}
+
+const (
+	TypeA     = "A"
+	TypeAAAA  = "AAAA"
+	TypeCNAME = "CNAME"
+	TypeTXT   = "TXT"
+	TypeCAA   = "CAA"
+)
+
+// QueryTypesOptions restricts the record types a domain and the names below it answer, e.g.
+// {"types": ["TXT"]} for a domain which only serves ACME challenges.
+type QueryTypesOptions struct {
+	Types []string `json:"types" schema:"required;enum=A|AAAA|CNAME|TXT|CAA"`
+}
+
+type QueryTypesResponse struct {
+	Status  int               `json:"status"`
+	Message string            `json:"msg"`
+	Data    QueryTypesOptions `json:"data"`
+}
+
+func ParseQueryTypesOptions(r *http.Request) (*QueryTypesOptions, error) {
+	var opts QueryTypesOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
+
+// NormalizeQueryTypes checks the types and gets them in upper case, sorted and without duplicates
+// e.g. [txt, A, TXT] => [A, TXT]
+func NormalizeQueryTypes(types []string) ([]string, error) {
+	set := make(map[string]bool, len(types))
+	for _, t := range types {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if !isQueryType(t) {
+			return nil, errors.Errorf("invalid query type %s, expected one of %s", t, strings.Join(QueryTypes, ", "))
+		}
+		set[t] = true
+	}
+
+	normalized := make([]string, 0, len(set))
+	for t := range set {
+		normalized = append(normalized, t)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// AllowsQueryType reports whether the restriction allows a type, an empty restriction allows every type.
+func AllowsQueryType(types []string, t string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, allowed := range types {
+		if allowed == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Types gets the record types of the records which hold values, sorted by name
+// e.g. {a: [{sample.lb.rancher.cloud [1.1.1.1]}], txt: [...]} => [A, TXT]
+func (r *DomainRecords) Types() []string {
+	types := make([]string, 0)
+	for _, a := range r.A {
+		if len(a.Hosts) > 0 {
+			types = append(types, TypeA)
+			break
+		}
+	}
+	if len(r.AAAA) > 0 {
+		types = append(types, TypeAAAA)
+	}
+	if len(r.CAA) > 0 {
+		types = append(types, TypeCAA)
+	}
+	if r.CNAME != nil {
+		types = append(types, TypeCNAME)
+	}
+	if len(r.TXT) > 0 {
+		types = append(types, TypeTXT)
+	}
+	return types
+}
+
+func isQueryType(t string) bool {
+	for _, q := range QueryTypes {
+		if q == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package model
+
+import (
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const maxNameLength = 253
+
+// a label of a record name, underscores are allowed for names like _acme-challenge
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?$`)
+
+// The typed records of a domain, the Name of a record is its fqdn. The name of a domain which
+// is not created yet is empty, its sub domains are named relative to it.
+
+// ARecord is the IPv4 hosts of a domain or a sub domain.
+type ARecord struct {
+	Name  string   `json:"name"`
+	Hosts []string `json:"hosts" schema:"required;format=ipv4"`
+}
+
+// AAAARecord is the IPv6 hosts of a domain or a sub domain.
+type AAAARecord struct {
+	Name  string   `json:"name"`
+	Hosts []string `json:"hosts" schema:"required;format=ipv6"`
+}
+
+// TXTRecord is a TXT record below a domain, the values of ACME orders are kept by order.
+type TXTRecord struct {
+	Name  string `json:"name" schema:"required"`
+	Text  string `json:"text" schema:"required"`
+	Order string `json:"order,omitempty" schema:"pattern=^[A-Za-z0-9-]*$;maxLength=64"`
+}
+
+// CNAMERecord points a domain at a name outside of it.
+type CNAMERecord struct {
+	Name   string `json:"name"`
+	Target string `json:"target" schema:"required;format=hostname"`
+}
+
+// DomainRecords are the typed records of the options of a domain.
+type DomainRecords struct {
+	A     []ARecord    `json:"a,omitempty"`
+	AAAA  []AAAARecord `json:"aaaa,omitempty"`
+	TXT   []TXTRecord  `json:"txt,omitempty"`
+	CNAME *CNAMERecord `json:"cname,omitempty"`
+	CAA   []CAARecord  `json:"caa,omitempty"`
+}
+
+func (r *ARecord) Validate() error {
+	if err := validateRecordName(r.Name); err != nil {
+		return err
+	}
+	for _, h := range r.Hosts {
+		if ip := net.ParseIP(h); ip == nil || ip.To4() == nil {
+			return errors.Errorf("invalid host ip of %s: %s", r.Name, h)
+		}
+	}
+	return nil
+}
+
+func (r *AAAARecord) Validate() error {
+	if err := validateRecordName(r.Name); err != nil {
+		return err
+	}
+	for _, h := range r.Hosts {
+		if !IsIPv6(h) {
+			return errors.Errorf("invalid IPv6 host of %s: %s", r.Name, h)
+		}
+	}
+	return nil
+}
+
+func (r *TXTRecord) Validate() error {
+	if err := validateRecordName(r.Name); err != nil {
+		return err
+	}
+	if r.Text == "" {
+		return errors.Errorf("expected the text of TXT record %s", r.Name)
+	}
+	return ValidateOrder(r.Order)
+}
+
+func (r *CNAMERecord) Validate() error {
+	if err := validateRecordName(r.Name); err != nil {
+		return err
+	}
+	target := strings.TrimSuffix(r.Target, ".")
+	if target == "" || validateRecordName(target) != nil {
+		return errors.Errorf("invalid CNAME target of %s: %s", r.Name, r.Target)
+	}
+	if strings.EqualFold(target, r.Name) {
+		return errors.Errorf("CNAME record %s can not point at itself", r.Name)
+	}
+	return nil
+}
+
+// Validate checks every record, the first invalid record is reported.
+func (r *DomainRecords) Validate() error {
+	for i := range r.A {
+		if err := r.A[i].Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range r.AAAA {
+		if err := r.AAAA[i].Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range r.TXT {
+		if err := r.TXT[i].Validate(); err != nil {
+			return err
+		}
+	}
+	if r.CNAME != nil {
+		if err := r.CNAME.Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range r.CAA {
+		if err := r.CAA[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Records converts the hosts, sub domains, text and cname of the options to typed records
+// e.g. {fqdn: sample.lb.rancher.cloud, hosts: [1.1.1.1], subdomain: {x1: [2001:db8::1]}}
+// => {a: [{sample.lb.rancher.cloud [1.1.1.1]}], aaaa: [{x1.sample.lb.rancher.cloud [2001:db8::1]}]}
+func (o *DomainOptions) Records() DomainRecords {
+	var r DomainRecords
+
+	addHosts := func(name string, hosts []string) {
+		v4, v6 := SplitHosts(hosts)
+		if len(v4) > 0 || len(v6) == 0 {
+			r.A = append(r.A, ARecord{Name: name, Hosts: v4})
+		}
+		if len(v6) > 0 {
+			r.AAAA = append(r.AAAA, AAAARecord{Name: name, Hosts: v6})
+		}
+	}
+
+	if len(o.Hosts) > 0 {
+		addHosts(o.Fqdn, o.Hosts)
+	}
+	subs := make([]string, 0, len(o.SubDomain))
+	for sub := range o.SubDomain {
+		subs = append(subs, sub)
+	}
+	sort.Strings(subs)
+	for _, sub := range subs {
+		addHosts(joinName(sub, o.Fqdn), o.SubDomain[sub])
+	}
+	if o.Text != "" {
+		r.TXT = append(r.TXT, TXTRecord{Name: o.Fqdn, Text: o.Text, Order: o.Order})
+	}
+	if o.CNAME != "" {
+		r.CNAME = &CNAMERecord{Name: o.Fqdn, Target: o.CNAME}
+	}
+	return r
+}
+
+// Validate checks the records of the options and the requested name of a new domain.
+func (o *DomainOptions) Validate() error {
+	if o.Name != "" {
+		if err := ValidateName(o.Name); err != nil {
+			return err
+		}
+	}
+	for sub := range o.SubDomain {
+		if sub == "" || validateRecordName(sub) != nil {
+			return errors.Errorf("invalid sub domain: %s", sub)
+		}
+	}
+	records := o.Records()
+	return records.Validate()
+}
+
+// ValidateFqdn checks the fqdn of a request, it is a dns name of at most 253 characters
+// e.g. x1.qrn7oq.lb.rancher.cloud => nil
+func ValidateFqdn(fqdn string) error {
+	if strings.Trim(fqdn, ".") == "" {
+		return errors.New("expected a fqdn")
+	}
+	return validateRecordName(fqdn)
+}
+
+// ValidateBelowRoot checks the fqdn of a write is a valid fqdn strictly below the root domain, the
+// root itself and the names above it or beside it are never written
+// e.g. x1.qrn7oq.lb.rancher.cloud, lb.rancher.cloud => nil
+// e.g. lb.rancher.cloud, lb.rancher.cloud => fqdn lb.rancher.cloud is not below root domain lb.rancher.cloud
+// e.g. ..lb.rancher.cloud, lb.rancher.cloud => invalid name ..lb.rancher.cloud, label "" is not a valid dns label
+func ValidateBelowRoot(fqdn, root string) error {
+	if err := ValidateFqdn(fqdn); err != nil {
+		return err
+	}
+	name := strings.ToLower(strings.TrimSuffix(fqdn, "."))
+	if !strings.HasSuffix(name, "."+strings.ToLower(strings.Trim(root, "."))) {
+		return errors.Errorf("fqdn %s is not below root domain %s", fqdn, root)
+	}
+	return nil
+}
+
+// An empty name is the name of a domain which is not created yet.
+func validateRecordName(name string) error {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return nil
+	}
+	if len(name) > maxNameLength {
+		return errors.Errorf("invalid name %s, a name has at most %d characters", name, maxNameLength)
+	}
+	for _, l := range strings.Split(name, ".") {
+		if !labelPattern.MatchString(l) {
+			return errors.Errorf("invalid name %s, label %q is not a valid dns label", name, l)
+		}
+	}
+	return nil
+}
+
+func joinName(sub, fqdn string) string {
+	if fqdn == "" {
+		return sub
+	}
+	return sub + "." + fqdn
+}
@@ -0,0 +1,34 @@
+package model
+
+import "testing"
+
+func TestValidateBelowRoot(t *testing.T) {
+	root := "lb.rancher.cloud"
+	for _, c := range []struct {
+		fqdn  string
+		valid bool
+	}{
+		{"x1.qrn7oq.lb.rancher.cloud", true},
+		{"qrn7oq.lb.rancher.cloud", true},
+		{"qrn7oq.lb.rancher.cloud.", true},
+		{"QRN7OQ.LB.RANCHER.CLOUD", true},
+		{"lb.rancher.cloud", false},
+		{"lb.rancher.cloud.", false},
+		{"..lb.rancher.cloud", false},
+		{".lb.rancher.cloud", false},
+		{"a..lb.rancher.cloud", false},
+		{"rancher.cloud", false},
+		{"cloud", false},
+		{"", false},
+		{"xlb.rancher.cloud", false},
+		{"qrn7oq.lb.example.com", false},
+	} {
+		err := ValidateBelowRoot(c.fqdn, root)
+		if c.valid && err != nil {
+			t.Errorf("expected %q to be below %s, got %v", c.fqdn, root, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("expected %q to be refused below %s", c.fqdn, root)
+		}
+	}
+}
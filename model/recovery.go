@@ -0,0 +1,37 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// RecoveryPathPrefix is the path the hosts of a domain serve the recovery challenge on
+const RecoveryPathPrefix = "/.well-known/rdns-recovery/"
+
+// RecoveryChallenge is served by every host of a domain to prove control of the hosts when
+// the token is lost, e.g. http://<host>:<TOKEN_RECOVERY_PORT>/.well-known/rdns-recovery/<fqdn>
+type RecoveryChallenge struct {
+	Fqdn       string     `json:"fqdn"`
+	Challenge  string     `json:"challenge"`
+	Path       string     `json:"path"`
+	Port       string     `json:"port"`
+	Expiration *time.Time `json:"expiration"`
+}
+
+type RecoveryOptions struct {
+	Challenge string `json:"challenge"`
+}
+
+type RecoveryResponse struct {
+	Status  int               `json:"status"`
+	Message string            `json:"msg"`
+	Data    RecoveryChallenge `json:"data"`
+}
+
+func ParseRecoveryOptions(r *http.Request) (*RecoveryOptions, error) {
+	var opts RecoveryOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
@@ -6,3 +6,9 @@ type Response struct {
 	Data    Domain `json:"data,omitempty"`
 	Token   string `json:"token"`
 }
+
+type ListResponse struct {
+	Status  int        `json:"status"`
+	Message string     `json:"msg"`
+	Data    DomainList `json:"data"`
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ScheduleHosts replaces the hosts and the sub domains of the domain
+	ScheduleHosts = "hosts"
+	// ScheduleDelete deletes the domain
+	ScheduleDelete = "delete"
+)
+
+// ScheduledChange is a change of a domain which the scheduler makes at a time, e.g. the hosts of a
+// planned migration or the deletion of a domain which is no longer needed.
+type ScheduledChange struct {
+	ID        string              `json:"id"`
+	Fqdn      string              `json:"fqdn"`
+	Action    string              `json:"action"`
+	Hosts     []string            `json:"hosts,omitempty"`
+	SubDomain map[string][]string `json:"subdomain,omitempty"`
+	At        time.Time           `json:"at"`
+	Created   *time.Time          `json:"created,omitempty"`
+	// Source and Proofs are the source address and the host proofs of the request which scheduled
+	// the change, the hosts are checked with them again when the change is made
+	Source string            `json:"source,omitempty"`
+	Proofs map[string]string `json:"proofs,omitempty"`
+}
+
+type ScheduledChangeResponse struct {
+	Status  int             `json:"status"`
+	Message string          `json:"msg"`
+	Data    ScheduledChange `json:"data"`
+}
+
+type ScheduledChangeListResponse struct {
+	Status  int               `json:"status"`
+	Message string            `json:"msg"`
+	Data    []ScheduledChange `json:"data"`
+}
+
+// ScheduleOptions schedules a change of a domain
+// e.g. {"action": "hosts", "hosts": ["2.2.2.2"], "at": "2026-10-15T02:00:00Z"}
+// e.g. {"action": "delete", "at": "2026-10-16T00:00:00Z"}
+type ScheduleOptions struct {
+	Action    string              `json:"action" schema:"required;enum=hosts|delete"`
+	Hosts     []string            `json:"hosts" schema:"format=ipv4"`
+	SubDomain map[string][]string `json:"subdomain"`
+	At        *time.Time          `json:"at" schema:"required"`
+}
+
+func ParseScheduleOptions(r *http.Request) (*ScheduleOptions, error) {
+	var opts ScheduleOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
+
+// Validate checks the time of the change is in the future and the hosts of a hosts change, a
+// deletion has no hosts.
+func (o *ScheduleOptions) Validate(now time.Time) error {
+	if o.At == nil || !o.At.After(now) {
+		return errors.New("expected a future time of the change")
+	}
+
+	switch o.Action {
+	case ScheduleHosts:
+		if len(o.Hosts) == 0 && len(o.SubDomain) == 0 {
+			return errors.New("expected the hosts of the change")
+		}
+		d := &DomainOptions{Hosts: o.Hosts, SubDomain: o.SubDomain}
+		return d.Validate()
+	case ScheduleDelete:
+		if len(o.Hosts) > 0 || len(o.SubDomain) > 0 {
+			return errors.New("a deletion has no hosts")
+		}
+		return nil
+	}
+	return errors.Errorf("invalid action %s, expected %s or %s", o.Action, ScheduleHosts, ScheduleDelete)
+}
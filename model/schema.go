@@ -0,0 +1,183 @@
+package model
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// JSONSchema is the subset of JSON schema draft-07 the payloads of the api are described with.
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+}
+
+type SchemasResponse struct {
+	Status  int                    `json:"status"`
+	Message string                 `json:"msg"`
+	Data    map[string]*JSONSchema `json:"data"`
+}
+
+// schemaTypes are the payloads whose schemas are published, the domain payloads are described by
+// the structs they are marshaled with so the hostsv6 and subdomainv6 fields are part of them.
+var schemaTypes = map[string]interface{}{
+	"Domain":             domainPayload{},
+	"DomainOptions":      domainOptionsPayload{},
+	"HostsPatch":         HostsPatch{},
+	"ARecord":            ARecord{},
+	"AAAARecord":         AAAARecord{},
+	"TXTRecord":          TXTRecord{},
+	"CNAMERecord":        CNAMERecord{},
+	"CAARecord":          CAARecord{},
+	"CAAOptions":         CAAOptions{},
+	"DomainRecords":      DomainRecords{},
+	"DomainStatus":       DomainStatus{},
+	"WebhookOptions":     WebhookOptions{},
+	"SuspensionOptions":  SuspensionOptions{},
+	"APIKeyOptions":      APIKeyOptions{},
+	"ExpirationOptions":  ExpirationOptions{},
+	"ScheduleOptions":    ScheduleOptions{},
+	"ScheduledChange":    ScheduledChange{},
+	"TTLOptions":         TTLOptions{},
+	"DrainOptions":       DrainOptions{},
+	"RecoveryOptions":    RecoveryOptions{},
+	"DeviceOptions":      DeviceOptions{},
+	"HostReplaceOptions": HostReplaceOptions{},
+	"NodeRemovalOptions": NodeRemovalOptions{},
+	"BackendState":       BackendState{},
+	"Maintenance":        Maintenance{},
+	"Feature":            Feature{},
+	"QueryTypesOptions":  QueryTypesOptions{},
+	"Keepalive":          Keepalive{},
+}
+
+// Schemas generates the schemas of the payloads from their structs, by name.
+func Schemas() map[string]*JSONSchema {
+	result := make(map[string]*JSONSchema, len(schemaTypes))
+	for name, v := range schemaTypes {
+		s := schemaOf(reflect.TypeOf(v))
+		s.Schema, s.Title = jsonSchemaDraft, name
+		result[name] = s
+	}
+	return result
+}
+
+// The json tags name the properties, the schema tag adds the constraints which the Validate
+// methods check, constraints of a slice apply to its items but required
+// e.g. `json:"hosts" schema:"required;format=ipv4"`
+func schemaOf(t reflect.Type) *JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct:
+		s := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+		addProperties(s, t)
+		sort.Strings(s.Required)
+		return s
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaOf(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+	case t.Kind() == reflect.String:
+		return &JSONSchema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	}
+	return &JSONSchema{}
+}
+
+// Embedded structs are flattened like encoding/json does, the fields of the outer struct win.
+func addProperties(s *JSONSchema, t reflect.Type) {
+	embedded := make([]reflect.Type, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded = append(embedded, f.Type)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		p := schemaOf(f.Type)
+		if required := applyConstraints(p, f); required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = p
+	}
+
+	for _, e := range embedded {
+		for e.Kind() == reflect.Ptr {
+			e = e.Elem()
+		}
+		inner := &JSONSchema{Properties: make(map[string]*JSONSchema)}
+		addProperties(inner, e)
+		for name, p := range inner.Properties {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = p
+			}
+		}
+		s.Required = append(s.Required, inner.Required...)
+	}
+}
+
+func applyConstraints(p *JSONSchema, f reflect.StructField) (required bool) {
+	target := p
+	if p.Type == "array" {
+		target = p.Items
+	}
+
+	for _, c := range strings.Split(f.Tag.Get("schema"), ";") {
+		kv := strings.SplitN(c, "=", 2)
+		switch kv[0] {
+		case "required":
+			required = true
+		case "format":
+			target.Format = kv[1]
+		case "pattern":
+			target.Pattern = kv[1]
+		case "maxLength":
+			if n, err := strconv.Atoi(kv[1]); err == nil {
+				target.MaxLength = &n
+			}
+		case "enum":
+			for _, v := range strings.Split(kv[1], "|") {
+				if n, err := strconv.Atoi(v); err == nil && target.Type == "integer" {
+					target.Enum = append(target.Enum, n)
+					continue
+				}
+				target.Enum = append(target.Enum, v)
+			}
+		}
+	}
+	return required
+}
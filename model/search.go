@@ -0,0 +1,122 @@
+package model
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type SearchOptions struct {
+	ListOptions
+	Host           string     `json:"host"`
+	Label          string     `json:"label"`
+	CreatorIP      string     `json:"creatorIP"`
+	Text           string     `json:"text"`
+	ExpiringBefore *time.Time `json:"expiringBefore"`
+	ExpiringAfter  *time.Time `json:"expiringAfter"`
+}
+
+// ParseSearchOptions parses the filters of the admin domain search, the text filter
+// is a substring match and is given as text~=<value>.
+func ParseSearchOptions(r *http.Request) (*SearchOptions, error) {
+	l, err := ParseListOptions(r)
+	if err != nil {
+		return nil, err
+	}
+
+	vals := r.URL.Query()
+	opts := &SearchOptions{
+		ListOptions: *l,
+		Host:        vals.Get("host"),
+		Label:       vals.Get("label"),
+		CreatorIP:   vals.Get("creatorIP"),
+		Text:        vals.Get("text~"),
+	}
+
+	if opts.Label != "" && !strings.Contains(opts.Label, "=") {
+		return opts, errors.Errorf("invalid label filter, expected key=value: %s", opts.Label)
+	}
+
+	for name, t := range map[string]**time.Time{
+		"expiringBefore": &opts.ExpiringBefore,
+		"expiringAfter":  &opts.ExpiringAfter,
+	} {
+		v := vals.Get(name)
+		if v == "" {
+			continue
+		}
+		e, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, errors.Errorf("invalid %s filter, expected RFC3339 time: %s", name, v)
+		}
+		*t = &e
+	}
+
+	return opts, nil
+}
+
+// IsEmpty returns true when no filter is set, the search is a plain list then.
+func (s *SearchOptions) IsEmpty() bool {
+	return s.Host == "" && s.Label == "" && s.CreatorIP == "" && s.Text == "" &&
+		s.ExpiringBefore == nil && s.ExpiringAfter == nil
+}
+
+// Match checks the filters against a domain, indexes may lag behind the records
+// so backends use it to verify the candidates of an index.
+func (s *SearchOptions) Match(d *Domain, texts []string) bool {
+	if s.Host != "" && !hasHost(d, s.Host) {
+		return false
+	}
+	if s.Label != "" {
+		kv := strings.SplitN(s.Label, "=", 2)
+		if v, ok := d.Labels[kv[0]]; !ok || v != kv[1] {
+			return false
+		}
+	}
+	if s.CreatorIP != "" && d.CreatorIP != s.CreatorIP {
+		return false
+	}
+	if s.ExpiringBefore != nil && (d.Expiration == nil || !d.Expiration.Before(*s.ExpiringBefore)) {
+		return false
+	}
+	if s.ExpiringAfter != nil && (d.Expiration == nil || !d.Expiration.After(*s.ExpiringAfter)) {
+		return false
+	}
+	if s.Text != "" {
+		for _, t := range texts {
+			if strings.Contains(t, s.Text) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// LabelValues returns the labels as the index values of a domain
+// e.g. {"team": "foo"} => [team=foo]
+func LabelValues(labels map[string]string) []string {
+	ss := make([]string, 0, len(labels))
+	for k, v := range labels {
+		ss = append(ss, k+"="+v)
+	}
+	return ss
+}
+
+func hasHost(d *Domain, host string) bool {
+	for _, h := range d.Hosts {
+		if h == host {
+			return true
+		}
+	}
+	for _, hosts := range d.SubDomain {
+		for _, h := range hosts {
+			if h == host {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type BackendState struct {
+	State string `json:"state"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+type StateResponse struct {
+	Status  int          `json:"status"`
+	Message string       `json:"msg"`
+	Data    BackendState `json:"data"`
+}
+
+func ParseBackendState(r *http.Request) (*BackendState, error) {
+	var opts BackendState
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
+
+// OperationalState is a value of the operational state of the server, which every replica reads
+// from the backend. Version is the version it is written with, a value which is never written
+// has version 0.
+type OperationalState struct {
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Version int64           `json:"version"`
+	Updated *time.Time      `json:"updated,omitempty"`
+}
+
+type OperationalStatesResponse struct {
+	Status  int                `json:"status"`
+	Message string             `json:"msg"`
+	Data    []OperationalState `json:"data"`
+}
+
+// Maintenance refuses the changes of the domains while it is enabled, the message is returned
+// to the clients which are refused.
+type Maintenance struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+type MaintenanceResponse struct {
+	Status  int         `json:"status"`
+	Message string      `json:"msg"`
+	Data    Maintenance `json:"data"`
+}
+
+func ParseMaintenance(r *http.Request) (*Maintenance, error) {
+	var opts Maintenance
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
+
+// Feature switches a feature flag, e.g. {"enabled": true}.
+type Feature struct {
+	Enabled bool `json:"enabled"`
+}
+
+type FeaturesResponse struct {
+	Status  int             `json:"status"`
+	Message string          `json:"msg"`
+	Data    map[string]bool `json:"data"`
+}
+
+func ParseFeature(r *http.Request) (*Feature, error) {
+	var opts Feature
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The status of a domain is published as the TXT record of _status.<fqdn>, so tools can discover
+// maintenance windows with a dns query:
+// _status.qrn7oq.lb.rancher.cloud TXT "v=rdns1; state=maintenance; start=2026-10-14T02:00:00Z; end=2026-10-14T04:00:00Z; msg=upgrading"
+
+const (
+	StatusPrefix  = "_status."
+	StatusVersion = "rdns1"
+
+	StatusOK          = "ok"
+	StatusMaintenance = "maintenance"
+	StatusDegraded    = "degraded"
+	StatusOutage      = "outage"
+
+	maxStatusMessageLength = 128
+	// a TXT character string holds at most 255 bytes
+	maxStatusTextLength = 255
+)
+
+var (
+	statusStates = map[string]bool{
+		StatusOK:          true,
+		StatusMaintenance: true,
+		StatusDegraded:    true,
+		StatusOutage:      true,
+	}
+
+	// the message is printable ascii without the separator and quotes of the TXT record
+	statusMessagePattern = regexp.MustCompile(`^[ !#-:<-~]*$`)
+)
+
+type DomainStatus struct {
+	Fqdn    string     `json:"fqdn,omitempty"`
+	State   string     `json:"state" schema:"required;enum=ok|maintenance|degraded|outage"`
+	Start   *time.Time `json:"start,omitempty"`
+	End     *time.Time `json:"end,omitempty"`
+	Message string     `json:"msg,omitempty" schema:"maxLength=128"`
+	Text    string     `json:"text,omitempty"`
+}
+
+type DomainStatusResponse struct {
+	Status  int          `json:"status"`
+	Message string       `json:"msg"`
+	Data    DomainStatus `json:"data"`
+}
+
+func ParseDomainStatus(r *http.Request) (*DomainStatus, error) {
+	var s DomainStatus
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&s)
+	return &s, err
+}
+
+// StatusName is the name the status TXT record of a domain is kept under
+// e.g. qrn7oq.lb.rancher.cloud => _status.qrn7oq.lb.rancher.cloud
+func StatusName(fqdn string) string {
+	return StatusPrefix + fqdn
+}
+
+// Validate checks the state, the window and the message, and that they fit in one TXT string.
+func (s *DomainStatus) Validate() error {
+	if !statusStates[s.State] {
+		return errors.Errorf("invalid status state: %s", s.State)
+	}
+	if s.End != nil && s.Start != nil && !s.End.After(*s.Start) {
+		return errors.Errorf("status end %s is not after start %s", s.End.Format(time.RFC3339), s.Start.Format(time.RFC3339))
+	}
+	if len(s.Message) > maxStatusMessageLength {
+		return errors.Errorf("status message is longer than %d characters", maxStatusMessageLength)
+	}
+	if !statusMessagePattern.MatchString(s.Message) {
+		return errors.Errorf("invalid status message %q, it must be printable ascii without ; and \"", s.Message)
+	}
+	if t := s.Format(); len(t) > maxStatusTextLength {
+		return errors.Errorf("status text is longer than %d characters: %s", maxStatusTextLength, t)
+	}
+	return nil
+}
+
+// Format renders the TXT record of the status, the window is kept in UTC seconds
+// e.g. {State: maintenance, Message: upgrading} => v=rdns1; state=maintenance; msg=upgrading
+func (s *DomainStatus) Format() string {
+	fields := []string{"v=" + StatusVersion, "state=" + s.State}
+	if s.Start != nil {
+		fields = append(fields, "start="+s.Start.UTC().Format(time.RFC3339))
+	}
+	if s.End != nil {
+		fields = append(fields, "end="+s.End.UTC().Format(time.RFC3339))
+	}
+	if s.Message != "" {
+		fields = append(fields, "msg="+s.Message)
+	}
+	return strings.Join(fields, "; ")
+}
+
+// ParseStatusText parses the TXT record of a status, unknown fields are skipped for later versions
+// e.g. v=rdns1; state=ok => {State: ok}
+func ParseStatusText(text string) (*DomainStatus, error) {
+	s := &DomainStatus{Text: text}
+	version := ""
+	for _, f := range strings.Split(text, ";") {
+		kv := strings.SplitN(strings.TrimSpace(f), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "v":
+			version = kv[1]
+		case "state":
+			s.State = kv[1]
+		case "start", "end":
+			t, err := time.Parse(time.RFC3339, kv[1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid status %s", kv[0])
+			}
+			if kv[0] == "start" {
+				s.Start = &t
+			} else {
+				s.End = &t
+			}
+		case "msg":
+			s.Message = kv[1]
+		}
+	}
+	if version != StatusVersion {
+		return nil, errors.Errorf("unknown status version: %s", version)
+	}
+	return s, nil
+}
@@ -0,0 +1,28 @@
+package model
+
+// ExportRecord is a line of the NDJSON export of the domains, it is the migrate payload of a
+// domain with its token origin so the import keeps the tokens of the owners, e.g.
+// {"fqdn": "qrn7oq.lb.rancher.cloud", "hosts": ["1.1.1.1"], "token": "...", "expiration": "..."}
+type ExportRecord struct {
+	MigrateRecord
+	CNAME string `json:"cname,omitempty"`
+}
+
+type ImportFailure struct {
+	Fqdn  string `json:"fqdn"`
+	Error string `json:"error"`
+}
+
+// ImportSummary counts the records of an import, only the first failures are listed.
+type ImportSummary struct {
+	Imported int             `json:"imported"`
+	Skipped  int             `json:"skipped"`
+	Failed   int             `json:"failed"`
+	Failures []ImportFailure `json:"failures,omitempty"`
+}
+
+type ImportSummaryResponse struct {
+	Status  int           `json:"status"`
+	Message string        `json:"msg"`
+	Data    ImportSummary `json:"data"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Suspension marks an abusive domain, suspended domains are exported as a response
+// policy zone so the resolvers of the operator can block them.
+type Suspension struct {
+	Fqdn   string     `json:"fqdn"`
+	Reason string     `json:"reason,omitempty"`
+	Time   *time.Time `json:"time,omitempty"`
+}
+
+type SuspensionOptions struct {
+	Reason string `json:"reason"`
+}
+
+type SuspensionResponse struct {
+	Status  int        `json:"status"`
+	Message string     `json:"msg"`
+	Data    Suspension `json:"data"`
+}
+
+type SuspensionListResponse struct {
+	Status  int          `json:"status"`
+	Message string       `json:"msg"`
+	Data    []Suspension `json:"data"`
+}
+
+func ParseSuspensionOptions(r *http.Request) (*SuspensionOptions, error) {
+	var opts SuspensionOptions
+	if r.ContentLength == 0 {
+		return &opts, nil
+	}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 var CoreFileTmpl = `
 . {
     {{- if and .CoreDNSDBFile .CoreDNSDBZone}}
@@ -10,11 +12,55 @@ var CoreFileTmpl = `
     rdns {{.Domain}} {
         path {{.EtcdPrefixPath}}
         endpoint {{.EtcdEndpoints}}
+        {{- if .EtcdReadEndpoints}}
+        read_endpoint {{.EtcdReadEndpoints}}
+        {{- end}}
         upstream 8.8.8.8:53 8.8.4.4:53
         wildcardbound {{.WildCardBound}}
+        {{- if and .EtcdShards (ne .EtcdShards "0")}}
+        shards {{.EtcdShards}}
+        {{- end}}
+        {{- if and .PreloadKeys (ne .PreloadKeys "0")}}
+        preload {{.PreloadKeys}}
+        {{- end}}
+        {{- if and .WatchCacheKeys (ne .WatchCacheKeys "0")}}
+        watch_cache {{.WatchCacheKeys}}
+        {{- end}}
+        {{- if and .StaleDuration (ne .StaleDuration "0s")}}
+        stale {{.StaleDuration}}
+        {{- end}}
+        {{- if .AnswerPolicy}}
+        policy {{.AnswerPolicy}}
+        {{- end}}
+        {{- if and .AnswerLimit (ne .AnswerLimit "0")}}
+        limit {{.AnswerLimit}} {{.LimitStrategy}}
+        {{- end}}
+        {{- if eq .WildcardFallback "true"}}
+        fallback
+        {{- end}}
+        {{- if eq .MinimalResponses "true"}}
+        minimal
+        {{- end}}
+        {{- if eq .RefuseAny "true"}}
+        refuse_any
+        {{- end}}
+        {{- if .RecursionNets}}
+        recursion {{.RecursionNets}}
+        {{- end}}
+        {{- if .UsageTiers}}
+        usage
+        {{- end}}
+        {{- if eq .Cookies "true"}}
+        cookie
+        {{- end}}
+        {{- if and .Padding (ne .Padding "0")}}
+        padding {{.Padding}}
+        {{- end}}
     }
     cache {{.TTL}} {{.Domain}}
+    {{- if not .AnswerPolicy}}
     loadbalance
+    {{- end}}
     forward . 8.8.8.8:53 8.8.4.4:53
     log stdout
     errors
@@ -26,6 +72,51 @@ type CoreFile struct {
 	Domain         string
 	EtcdPrefixPath string
 	EtcdEndpoints  string
-	TTL            string
-	WildCardBound  string
+	// EtcdReadEndpoints serve the lookups of the plugin, the lookups which fail are retried on EtcdEndpoints
+	EtcdReadEndpoints string
+	EtcdShards        string
+	// PreloadKeys is how many records the plugin reads at startup before it is ready, empty or "0" disables it
+	PreloadKeys string
+	// WatchCacheKeys is how many lookups the plugin caches until etcd watches a change of them, empty or "0" disables it
+	WatchCacheKeys string
+	StaleDuration  string
+	AnswerPolicy   string
+	// AnswerLimit is how many addresses an answer has at most, empty or "0" disables it
+	AnswerLimit string
+	// LimitStrategy chooses the addresses which are kept, random or latency
+	LimitStrategy string
+	// WildcardFallback answers the names without records with the records of their closest parent when it is "true"
+	WildcardFallback string
+	// MinimalResponses omits the additional records of the answers when it is "true"
+	MinimalResponses string
+	// RefuseAny refuses the ANY queries of the domain when it is "true"
+	RefuseAny string
+	// RecursionNets are the sources whose queries outside the domain are forwarded, empty means all sources
+	RecursionNets string
+	// UsageTiers counts the queries of every domain for the usage tiers when it is set
+	UsageTiers string
+	// Cookies answers the DNS cookies of the queries when it is "true", the secret is not written to the Corefile
+	Cookies string
+	// Padding is the block size the answers of the queries which ask for padding are padded to
+	Padding       string
+	TTL           string
+	WildCardBound string
+}
+
+// CoreFileDrift compares the Corefile which the embedded CoreDNS runs with against the generated one.
+type CoreFileDrift struct {
+	Drift bool   `json:"drift"`
+	Path  string `json:"path"`
+	// Missing are the generated lines which the running Corefile lacks, Extra are the lines it adds
+	Missing []string `json:"missing,omitempty"`
+	Extra   []string `json:"extra,omitempty"`
+	// ReloadPending is set when the file on disk has changed since it was loaded
+	ReloadPending bool       `json:"reloadPending"`
+	Loaded        *time.Time `json:"loaded,omitempty"`
+}
+
+type CoreFileDriftResponse struct {
+	Status  int           `json:"status"`
+	Message string        `json:"msg"`
+	Data    CoreFileDrift `json:"data"`
 }
@@ -0,0 +1,5 @@
+package model
+
+// TokenScopeReadOnly is the scope of the secondary tokens which only read and renew their domain,
+// so automation which keeps a domain alive can not change or delete it.
+const TokenScopeReadOnly = "read-only"
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// TTLOptions sets the serving ttl of a domain in seconds, 0 restores the default ttl.
+type TTLOptions struct {
+	TTL uint32 `json:"ttl"`
+}
+
+func ParseTTLOptions(r *http.Request) (*TTLOptions, error) {
+	var opts TTLOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
@@ -0,0 +1,16 @@
+package model
+
+// FieldError is a field of a request which is refused by the validation, e.g.
+// {"field": "subdomain.x1", "value": "10.0.0.1", "reason": "host is not a public address"}.
+type FieldError struct {
+	Field  string `json:"field"`
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ValidationResponse is the 400 response of a refused request, msg sums up the first error.
+type ValidationResponse struct {
+	Status  int          `json:"status"`
+	Message string       `json:"msg"`
+	Errors  []FieldError `json:"errors"`
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderWebhookEvent     = "X-RDNS-Event"
+	HeaderWebhookDelivery  = "X-RDNS-Delivery"
+	HeaderWebhookTimestamp = "X-RDNS-Timestamp"
+	HeaderWebhookSignature = "X-RDNS-Signature"
+
+	webhookSignatureVersion = "v1="
+)
+
+const (
+	EventDomainCreated     = "domain.created"
+	EventDomainUpdated     = "domain.updated"
+	EventDomainRenewed     = "domain.renewed"
+	EventDomainDeleted     = "domain.deleted"
+	EventDomainSuspended   = "domain.suspended"
+	EventDomainUnsuspended = "domain.unsuspended"
+	EventDomainExpiring    = "domain.expiring"
+	EventDomainExpired     = "domain.expired"
+	EventTextSet           = "txt.set"
+	EventTextDeleted       = "txt.deleted"
+	EventCNAMESet          = "cname.set"
+	EventCNAMEDeleted      = "cname.deleted"
+	EventTokenRotated      = "token.rotated"
+)
+
+// WebhookEvents are the events which webhooks can subscribe to.
+var WebhookEvents = []string{
+	EventDomainCreated, EventDomainUpdated, EventDomainRenewed, EventDomainDeleted,
+	EventDomainSuspended, EventDomainUnsuspended, EventDomainExpiring, EventDomainExpired,
+	EventTextSet, EventTextDeleted, EventCNAMESet, EventCNAMEDeleted, EventTokenRotated,
+}
+
+// Webhook is registered by the owner of a domain, it receives the events of the
+// domain and its sub domains only.
+type Webhook struct {
+	ID     string     `json:"id"`
+	Fqdn   string     `json:"fqdn"`
+	URL    string     `json:"url"`
+	Secret string     `json:"secret,omitempty"`
+	Events []string   `json:"events,omitempty"`
+	Time   *time.Time `json:"time,omitempty"`
+}
+
+// Subscribed returns whether the webhook receives the event, a webhook without
+// events receives all events.
+func (w *Webhook) Subscribed(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookOptions struct {
+	URL    string   `json:"url" schema:"required;format=uri"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// WebhookEvent is the payload posted to webhooks. The events which change records also carry the
+// records before and after the change and the diff of them, a created name has no before and a
+// deleted name has no after.
+type WebhookEvent struct {
+	Type   string         `json:"type"`
+	Fqdn   string         `json:"fqdn"`
+	Time   time.Time      `json:"time"`
+	Data   interface{}    `json:"data,omitempty"`
+	Before *Domain        `json:"before,omitempty"`
+	After  *Domain        `json:"after,omitempty"`
+	Diff   []RecordChange `json:"diff,omitempty"`
+}
+
+type WebhookResponse struct {
+	Status  int     `json:"status"`
+	Message string  `json:"msg"`
+	Data    Webhook `json:"data"`
+}
+
+type WebhookListResponse struct {
+	Status  int       `json:"status"`
+	Message string    `json:"msg"`
+	Data    []Webhook `json:"data"`
+}
+
+func ParseWebhookOptions(r *http.Request) (*WebhookOptions, error) {
+	var opts WebhookOptions
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&opts)
+	return &opts, err
+}
+
+// WebhookSignature signs the timestamp, the delivery id and the body of a webhook delivery,
+// so a captured delivery can not be sent again with another timestamp or id
+// e.g. v1=<hex encoded HMAC-SHA256 of "1559803622.1559803600123456789.{"type": ...}">
+func WebhookSignature(secret string, timestamp int64, delivery uint64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "." + strconv.FormatUint(delivery, 10) + "."))
+	mac.Write(body)
+	return webhookSignatureVersion + hex.EncodeToString(mac.Sum(nil))
+}
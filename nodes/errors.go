@@ -0,0 +1,8 @@
+package nodes
+
+const (
+	errListNodes       = "failed to list the nodes of %s"
+	errReadCAFile      = "failed to read node sync ca file %s"
+	errRemoveNode      = "failed to remove the addresses of node %s"
+	errUnexpectedNodes = "unexpected status %d listing the nodes of %s"
+)
@@ -0,0 +1,203 @@
+// Package nodes keeps the domains in sync with the members of a Rancher or k3s cluster, the
+// addresses of a node which is removed from the cluster are removed from the domains pointing
+// at them. The removals are polled from the nodes api of the cluster or pushed to the admin api.
+package nodes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	k8scorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultInterval = time.Minute
+	listTimeout     = 30 * time.Second
+)
+
+var removedHosts = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "rancher_dns_removed_node_hosts",
+	Help: "The number of hosts which were removed from domains as their node left the cluster",
+})
+
+// poller lists the nodes of the cluster every interval and removes the addresses of the nodes
+// which are gone since the previous list.
+type poller struct {
+	url    string
+	token  string
+	client *http.Client
+
+	// the addresses of the nodes of the previous list, by node name, nil before the first list
+	nodes map[string][]string
+}
+
+// StartNodeDaemon polls the nodes of NODE_SYNC_URL every NODE_SYNC_INTERVAL, e.g. the nodes api of
+// a k3s server or of a cluster proxied by Rancher. It is disabled when NODE_SYNC_URL is empty.
+func StartNodeDaemon(done chan struct{}) {
+	url := os.Getenv("NODE_SYNC_URL")
+	if url == "" {
+		return
+	}
+
+	interval, err := time.ParseDuration(os.Getenv("NODE_SYNC_INTERVAL"))
+	if err != nil || interval <= 0 {
+		logrus.Errorf("invalid node sync interval %s, use %s", os.Getenv("NODE_SYNC_INTERVAL"), defaultInterval)
+		interval = defaultInterval
+	}
+
+	client, err := newClient(os.Getenv("NODE_SYNC_CA_FILE"))
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	p := &poller{
+		url:    url,
+		token:  os.Getenv("NODE_SYNC_TOKEN"),
+		client: client,
+	}
+
+	logrus.Infof("syncing the hosts of domains with the nodes of %s every %s", url, interval)
+	wait.Until(p.poll, interval, done)
+}
+
+// Remove removes the addresses of a node from every domain which points at them, a domain whose
+// last host is an address keeps it and is reported as failed.
+func Remove(node string, addresses []string) (model.NodeRemoval, error) {
+	r := model.NodeRemoval{
+		Node:  node,
+		Hosts: make([]model.HostReplace, 0, len(addresses)),
+	}
+
+	for _, a := range addresses {
+		h, err := backend.RemoveHost(backend.GetBackend(), a)
+		if err != nil {
+			return r, errors.Wrapf(err, errRemoveNode, node)
+		}
+		removedHosts.Add(float64(len(h.Replaced)))
+		r.Hosts = append(r.Hosts, h)
+	}
+
+	logrus.Infof("removed the addresses %v of node %s", addresses, node)
+	return r, nil
+}
+
+func (p *poller) poll() {
+	l, err := p.list()
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	// a cluster never loses all nodes at once, an empty list is more likely a wrong url or token
+	if len(l.Items) == 0 && len(p.nodes) > 0 {
+		logrus.Warnf("no nodes listed by %s, keep the hosts of %d nodes", p.url, len(p.nodes))
+		return
+	}
+
+	current := make(map[string][]string, len(l.Items))
+	used := make(map[string]bool)
+	for _, n := range l.Items {
+		current[n.Name] = addresses(n)
+		for _, a := range current[n.Name] {
+			used[a] = true
+		}
+	}
+
+	if p.nodes != nil {
+		for name, as := range p.nodes {
+			if _, ok := current[name]; ok {
+				continue
+			}
+
+			// an address which is taken over by another node stays in the domains
+			removed := make([]string, 0, len(as))
+			for _, a := range as {
+				if !used[a] {
+					removed = append(removed, a)
+				}
+			}
+			if _, err := Remove(name, removed); err != nil {
+				logrus.Error(err)
+				// the removal is retried with the next list
+				current[name] = as
+			}
+		}
+	}
+
+	p.nodes = current
+}
+
+func (p *poller) list() (*k8scorev1.NodeList, error) {
+	req, err := http.NewRequest(http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, errListNodes, p.url)
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, errListNodes, p.url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(errUnexpectedNodes, resp.StatusCode, p.url)
+	}
+
+	l := &k8scorev1.NodeList{}
+	if err := json.NewDecoder(resp.Body).Decode(l); err != nil {
+		return nil, errors.Wrapf(err, errListNodes, p.url)
+	}
+	return l, nil
+}
+
+// Used to get the internal and external ip addresses of a node
+func addresses(n k8scorev1.Node) []string {
+	result := make([]string, 0, len(n.Status.Addresses))
+	for _, a := range n.Status.Addresses {
+		if a.Type != k8scorev1.NodeInternalIP && a.Type != k8scorev1.NodeExternalIP {
+			continue
+		}
+		if net.ParseIP(a.Address) == nil {
+			continue
+		}
+		result = append(result, a.Address)
+	}
+	return result
+}
+
+// Used to trust the ca of a cluster with a self signed certificate, e.g. a k3s server
+func newClient(caFile string) (*http.Client, error) {
+	c := &http.Client{Timeout: listTimeout}
+	if caFile == "" {
+		return c, nil
+	}
+
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, errReadCAFile, caFile)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf(errReadCAFile, caFile)
+	}
+	c.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	return c, nil
+}
@@ -8,12 +8,14 @@ import (
 	"github.com/rancher/rdns-server/backend"
 	"github.com/rancher/rdns-server/database"
 	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/schedule"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
+	flagChallengeTTL      = "ACME_TXT_TTL"
 	flagFrozen            = "FROZEN"
 	flagLeaseTime         = "DATABASE_LEASE_TIME"
 	intervalSeconds int64 = 600
@@ -42,6 +44,9 @@ func (p *purger) purge() {
 		logrus.Error(err)
 	}
 
+	// check ACME challenge TXT records, they expire long before their domains
+	p.purgeChallenges()
+
 	for _, token := range tokens {
 		// delete route53 A records & sub A records & wildcard records
 		opts := &model.DomainOptions{
@@ -76,6 +81,11 @@ func (p *purger) purge() {
 			}
 		}
 
+		// delete the scheduled changes of the domain
+		if err := schedule.Purge(backend.GetBackend(), token.Fqdn, time.Time{}); err != nil {
+			logrus.Error(err)
+		}
+
 		// delete token records & referenced records
 		if err := database.GetDatabase().DeleteToken(token.Token); err != nil {
 			logrus.Error(err)
@@ -83,6 +93,27 @@ func (p *purger) purge() {
 	}
 }
 
+func (p *purger) purgeChallenges() {
+	t, err := time.ParseDuration(os.Getenv(flagChallengeTTL))
+	if err != nil || t <= 0 {
+		return
+	}
+	e := time.Now().Add(-t)
+
+	ts, err := database.GetDatabase().QueryExpiredChallengeTXTs(&e)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	for _, r := range ts {
+		logrus.Debugf("purge expired ACME challenge TXT record: %s", r.Fqdn)
+		if err := backend.GetBackend().DeleteText(&model.DomainOptions{Fqdn: r.Fqdn}); err != nil {
+			logrus.Error(err)
+		}
+	}
+}
+
 func calculateFrozenTime() *time.Time {
 	f, err := time.ParseDuration(os.Getenv(flagFrozen))
 	if err != nil {
@@ -0,0 +1,9 @@
+package recovery
+
+const (
+	errExpiredChallenge = "recovery challenge of %s is expired"
+	errInvalidChallenge = "invalid recovery challenge of %s"
+	errNoHosts          = "domain %s has no hosts to prove control of"
+	errPrivateHost      = "host %s of domain %s is not public"
+	errUnexpectedProof  = "host %s does not serve the recovery challenge of %s"
+)
@@ -0,0 +1,127 @@
+package recovery
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	challengeTTL  = 10 * time.Minute
+	verifyTimeout = 5 * time.Second
+	maxProofSize  = 1024
+)
+
+var (
+	recoveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_token_recoveries",
+		Help: "The number of token recovery attempts by result",
+	}, []string{"result"})
+
+	client = &http.Client{
+		Timeout: verifyTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// Enabled returns whether TOKEN_RECOVERY_PORT is set.
+func Enabled() bool {
+	return os.Getenv("TOKEN_RECOVERY_PORT") != ""
+}
+
+// Challenge issues a challenge which the hosts of the domain serve to recover the token.
+// Challenges are signed with the token origin and not stored, so any instance verifies them
+// and they are void once the token is rotated.
+func Challenge(fqdn, origin string) model.RecoveryChallenge {
+	expiration := time.Now().Add(challengeTTL).Truncate(time.Second)
+	return model.RecoveryChallenge{
+		Fqdn:       fqdn,
+		Challenge:  sign(fqdn, origin, expiration.Unix()),
+		Path:       model.RecoveryPathPrefix + fqdn,
+		Port:       os.Getenv("TOKEN_RECOVERY_PORT"),
+		Expiration: &expiration,
+	}
+}
+
+// Verify checks the challenge and that every host of the domain serves it, sub domain hosts
+// are not asked for. Only public hosts are connected, a private address would reach the
+// network of the server instead of the network of the domain owner.
+func Verify(d model.Domain, origin, challenge string) (err error) {
+	defer func() {
+		if err != nil {
+			recoveries.WithLabelValues("failure").Inc()
+			return
+		}
+		recoveries.WithLabelValues("success").Inc()
+	}()
+
+	ss := strings.SplitN(challenge, ".", 2)
+	expiration, perr := strconv.ParseInt(ss[0], 10, 64)
+	if perr != nil || !hmac.Equal([]byte(sign(d.Fqdn, origin, expiration)), []byte(challenge)) {
+		return errors.Errorf(errInvalidChallenge, d.Fqdn)
+	}
+	if time.Now().Unix() > expiration {
+		return errors.Errorf(errExpiredChallenge, d.Fqdn)
+	}
+
+	if len(d.Hosts) == 0 {
+		return errors.Errorf(errNoHosts, d.Fqdn)
+	}
+	for _, h := range d.Hosts {
+		ip := net.ParseIP(h)
+		if ip == nil || !util.IsPublicIP(ip) {
+			return errors.Errorf(errPrivateHost, h, d.Fqdn)
+		}
+	}
+	for _, h := range d.Hosts {
+		if err := prove(h, d.Fqdn, challenge); err != nil {
+			return err
+		}
+	}
+
+	logrus.Infof("token of %s is recovered with the proof of hosts %v", d.Fqdn, d.Hosts)
+	return nil
+}
+
+func prove(host, fqdn, challenge string) error {
+	u := fmt.Sprintf("http://%s%s%s", net.JoinHostPort(host, os.Getenv("TOKEN_RECOVERY_PORT")), model.RecoveryPathPrefix, fqdn)
+
+	resp, err := client.Get(u)
+	if err != nil {
+		return errors.Wrapf(err, errUnexpectedProof, host, fqdn)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxProofSize))
+	if err != nil || resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != challenge {
+		return errors.Errorf(errUnexpectedProof, host, fqdn)
+	}
+	return nil
+}
+
+// Used to sign a challenge with the token origin
+// e.g. qrn7oq.lb.rancher.cloud, 1609459200 => 1609459200.5d41402abc4b2a76b9719d911017c592...
+func sign(fqdn, origin string, expiration int64) string {
+	mac := hmac.New(sha256.New, []byte(origin))
+	fmt.Fprintf(mac, "%s\n%d", fqdn, expiration)
+	return fmt.Sprintf("%d.%s", expiration, hex.EncodeToString(mac.Sum(nil)))
+}
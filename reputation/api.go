@@ -0,0 +1,82 @@
+package reputation
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	nameAPI = "api"
+	// replies larger than this are not decoded
+	maxReplySize = 64 * 1024
+)
+
+func init() {
+	Register(nameAPI, newAPI)
+}
+
+// api asks REPUTATION_API_URL about the hosts with GET <url>?ip=<host>, the reply is
+// {"listed": true, "reason": "botnet controller"}.
+type api struct {
+	url    *url.URL
+	client *http.Client
+}
+
+type apiReply struct {
+	Listed bool   `json:"listed"`
+	Reason string `json:"reason"`
+}
+
+func newAPI() (Provider, error) {
+	u, err := url.Parse(os.Getenv("REPUTATION_API_URL"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf(errInvalidURL, u.String())
+	}
+	return &api{url: u, client: &http.Client{Timeout: checkTimeout}}, nil
+}
+
+func (a *api) Name() string {
+	return nameAPI
+}
+
+func (a *api) Check(ip net.IP) (string, error) {
+	u := *a.url
+	q := u.Query()
+	q.Set("ip", ip.String())
+	u.RawQuery = q.Encode()
+
+	resp, err := a.client.Get(u.String())
+	if err != nil {
+		return "", errors.Wrapf(err, errQueryAPI, ip, a.url.Host)
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxReplySize))
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf(errUnexpectedReply, a.url.Host, resp.Status)
+	}
+
+	var r apiReply
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxReplySize)).Decode(&r); err != nil {
+		return "", errors.Wrapf(err, errQueryAPI, ip, a.url.Host)
+	}
+	if !r.Listed {
+		return "", nil
+	}
+	if r.Reason == "" {
+		r.Reason = "listed"
+	}
+	return r.Reason, nil
+}
@@ -0,0 +1,66 @@
+package reputation
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const nameCIDR = "cidr"
+
+func init() {
+	Register(nameCIDR, newCIDR)
+}
+
+// cidr lists the hosts within the networks of REPUTATION_CIDR_FILE, the file holds one network or
+// address per line and lines starting with # are comments.
+type cidr struct {
+	nets []*net.IPNet
+}
+
+func newCIDR() (Provider, error) {
+	path := os.Getenv("REPUTATION_CIDR_FILE")
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &cidr{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.Contains(line, "/") {
+			if ip := net.ParseIP(line); ip != nil && ip.To4() != nil {
+				line += "/32"
+			} else {
+				line += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, errInvalidNetwork, line, path)
+		}
+		c.nets = append(c.nets, n)
+	}
+	return c, scanner.Err()
+}
+
+func (c *cidr) Name() string {
+	return nameCIDR
+}
+
+func (c *cidr) Check(ip net.IP) (string, error) {
+	for _, n := range c.nets {
+		if n.Contains(ip) {
+			return "within " + n.String(), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,97 @@
+package reputation
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const nameDNSBL = "dnsbl"
+
+func init() {
+	Register(nameDNSBL, newDNSBL)
+}
+
+var (
+	// a dnsbl lists a host with an address of 127.0.0.0/8
+	listedNet = mustParseCIDR("127.0.0.0/8")
+	// replies of 127.255.255.0/24 tell the query is refused, e.g. from a public resolver
+	refusedNet = mustParseCIDR("127.255.255.0/24")
+)
+
+// dnsbl looks the hosts up in the zones of REPUTATION_DNSBL, e.g. zen.spamhaus.org.
+type dnsbl struct {
+	zones []string
+}
+
+func newDNSBL() (Provider, error) {
+	d := &dnsbl{}
+	for _, z := range strings.Split(os.Getenv("REPUTATION_DNSBL"), ",") {
+		if z = strings.Trim(strings.TrimSpace(z), "."); z != "" {
+			d.zones = append(d.zones, z)
+		}
+	}
+	if len(d.zones) == 0 {
+		return nil, errors.New(errNoZones)
+	}
+	return d, nil
+}
+
+func (d *dnsbl) Name() string {
+	return nameDNSBL
+}
+
+func (d *dnsbl) Check(ip net.IP) (string, error) {
+	for _, z := range d.zones {
+		name := reverseName(ip) + "." + z
+
+		ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+		addrs, err := net.DefaultResolver.LookupHost(ctx, name)
+		cancel()
+		if err != nil {
+			if e, ok := err.(*net.DNSError); ok && !e.IsTimeout && !e.Temporary() {
+				continue
+			}
+			return "", errors.Wrapf(err, errLookupDNSBL, ip, z)
+		}
+
+		for _, a := range addrs {
+			reply := net.ParseIP(a)
+			if reply == nil || !listedNet.Contains(reply) {
+				continue
+			}
+			if refusedNet.Contains(reply) {
+				return "", errors.Errorf(errLookupDNSBL, ip, z)
+			}
+			return fmt.Sprintf("listed in %s (%s)", z, a), nil
+		}
+	}
+	return "", nil
+}
+
+// Used to get the name of a host in a dnsbl zone, IPv6 hosts are written in nibbles
+// e.g. 192.0.2.1 => 1.2.0.192
+// e.g. 2001:db8::1 => 1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2
+func reverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d", v4[3], v4[2], v4[1], v4[0])
+	}
+	v6 := ip.To16()
+	nibbles := make([]string, 0, 32)
+	for i := len(v6) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, fmt.Sprintf("%x", v6[i]&0xf), fmt.Sprintf("%x", v6[i]>>4))
+	}
+	return strings.Join(nibbles, ".")
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
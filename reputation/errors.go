@@ -0,0 +1,12 @@
+package reputation
+
+const (
+	errInvalidNetwork  = "invalid network %s in %s"
+	errInvalidURL      = "invalid reputation api url: %s"
+	errLookupDNSBL     = "failed to look up %s in %s"
+	errNoZones         = "no dnsbl zones are set"
+	errOpenProvider    = "failed to open %s reputation provider"
+	errQueryAPI        = "failed to query the reputation of %s from %s"
+	errUnexpectedReply = "unexpected reply status from %s: %s"
+	errUnknownProvider = "unknown reputation provider %s, available providers: %s"
+)
@@ -0,0 +1,198 @@
+package reputation
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/config"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	checkTimeout = 2 * time.Second
+	// verdicts are cached, a dnsbl is not asked for every update of a domain
+	cacheTTL  = 10 * time.Minute
+	maxCached = 10000
+)
+
+var (
+	listedHosts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_reputation_listed_hosts",
+		Help: "The number of domain hosts listed by a reputation provider, by provider and action",
+	}, []string{"provider", "action"})
+
+	checkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_reputation_errors",
+		Help: "The number of reputation checks which failed and were skipped, by provider",
+	}, []string{"provider"})
+
+	factories = make(map[string]Factory)
+
+	providers     []Provider
+	providersOnce sync.Once
+
+	cache = struct {
+		sync.Mutex
+		verdicts map[string]verdict
+	}{verdicts: make(map[string]verdict)}
+)
+
+// Provider tells whether a host is known to belong to malicious infrastructure.
+type Provider interface {
+	Name() string
+	// Check returns why the host is listed, empty means it is not listed
+	Check(ip net.IP) (string, error)
+}
+
+// Factory opens a provider from its environments.
+type Factory func() (Provider, error)
+
+// Listing is a host which a provider lists.
+type Listing struct {
+	Host     string
+	Provider string
+	Reason   string
+}
+
+func (l *Listing) String() string {
+	return fmt.Sprintf("host %s is listed by the %s reputation provider: %s", l.Host, l.Provider, l.Reason)
+}
+
+type verdict struct {
+	listing *Listing
+	expires time.Time
+}
+
+// Register makes a provider available by its name, it is called from the init of the provider.
+func Register(name string, f Factory) {
+	factories[name] = f
+}
+
+// Enabled returns whether REPUTATION_PROVIDERS is set.
+func Enabled() bool {
+	return os.Getenv("REPUTATION_PROVIDERS") != ""
+}
+
+// Rejects returns whether listed hosts of the domain are refused, REPUTATION_ACTION defaults to reject
+// and the config file may set it for the root domain or the zone of the domain.
+func Rejects(fqdn string) bool {
+	return config.Resolve(fqdn).ReputationAction != config.ReputationFlag
+}
+
+// Check asks the providers of REPUTATION_PROVIDERS about the hosts, it returns the first listed host.
+// Providers which fail are skipped, so an outage of a provider does not stop registrations.
+// The owner is logged with a listed host, e.g. the domain or the client which creates it.
+func Check(fqdn, owner string, hosts []string) *Listing {
+	if !Enabled() {
+		return nil
+	}
+
+	for _, h := range hosts {
+		ip := net.ParseIP(h)
+		if ip == nil {
+			continue
+		}
+		if l := check(ip); l != nil {
+			action := config.ReputationReject
+			if !Rejects(fqdn) {
+				action = config.ReputationFlag
+			}
+			listedHosts.WithLabelValues(l.Provider, action).Inc()
+			logrus.Warnf("%s: %s (%s)", owner, l.String(), action)
+			return l
+		}
+	}
+	return nil
+}
+
+func check(ip net.IP) *Listing {
+	key := ip.String()
+	now := time.Now()
+
+	cache.Lock()
+	v, ok := cache.verdicts[key]
+	cache.Unlock()
+	if ok && now.Before(v.expires) {
+		return v.listing
+	}
+
+	var listing *Listing
+	failed := false
+	for _, p := range getProviders() {
+		reason, err := p.Check(ip)
+		if err != nil {
+			checkErrors.WithLabelValues(p.Name()).Inc()
+			logrus.Errorf("skip the %s reputation check of %s: %v", p.Name(), key, err)
+			failed = true
+			continue
+		}
+		if reason != "" {
+			listing = &Listing{Host: key, Provider: p.Name(), Reason: reason}
+			break
+		}
+	}
+
+	// a host which is not listed is asked for again when a provider failed
+	if listing == nil && failed {
+		return nil
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+	if _, ok := cache.verdicts[key]; !ok && len(cache.verdicts) >= maxCached {
+		for k, v := range cache.verdicts {
+			if now.After(v.expires) || len(cache.verdicts) >= maxCached {
+				delete(cache.verdicts, k)
+			}
+		}
+	}
+	cache.verdicts[key] = verdict{listing: listing, expires: now.Add(cacheTTL)}
+
+	return listing
+}
+
+// Used to open the providers of REPUTATION_PROVIDERS once, providers which fail to open are left out
+// e.g. cidr,dnsbl => [cidr dnsbl]
+func getProviders() []Provider {
+	providersOnce.Do(func() {
+		for _, name := range strings.Split(os.Getenv("REPUTATION_PROVIDERS"), ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			p, err := open(name)
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
+			providers = append(providers, p)
+		}
+	})
+	return providers
+}
+
+func open(name string) (Provider, error) {
+	f, ok := factories[name]
+	if !ok {
+		names := make([]string, 0, len(factories))
+		for n := range factories {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, errors.Errorf(errUnknownProvider, name, strings.Join(names, ","))
+	}
+	p, err := f()
+	if err != nil {
+		return nil, errors.Wrapf(err, errOpenProvider, name)
+	}
+	return p, nil
+}
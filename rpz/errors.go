@@ -0,0 +1,6 @@
+package rpz
+
+const (
+	errListSuspensions = "failed to list suspended domains"
+	errWriteZone       = "failed to write response policy zone to %s"
+)
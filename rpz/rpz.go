@@ -0,0 +1,162 @@
+package rpz
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultInterval = time.Minute
+	defaultZone     = "rpz.local"
+	// the rdns records live no longer than their lease, blocked names are cached shortly
+	zoneTTL = 300
+)
+
+// Zone builds the response policy zone of the suspended domains of the backend, every
+// suspended domain and its sub domains are answered with NXDOMAIN (CNAME .) by the
+// resolvers which load the zone.
+func Zone(b backend.Backend, serial uint32) ([]byte, error) {
+	ss, err := b.ListSuspensions()
+	if err != nil {
+		return nil, errors.Wrap(err, errListSuspensions)
+	}
+
+	return build(origin(), serial, ss), nil
+}
+
+// Used to get the origin of the response policy zone
+// e.g. RPZ_ZONE=rpz.rancher.cloud => rpz.rancher.cloud.
+func origin() string {
+	zone := os.Getenv("RPZ_ZONE")
+	if zone == "" {
+		zone = defaultZone
+	}
+	return dns.Fqdn(zone)
+}
+
+func build(origin string, serial uint32, ss []model.Suspension) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("$ORIGIN " + origin + "\n")
+	header := []dns.RR{
+		&dns.SOA{
+			Hdr:     dns.RR_Header{Name: origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: zoneTTL},
+			Ns:      "localhost.",
+			Mbox:    "hostmaster.localhost.",
+			Serial:  serial,
+			Refresh: 3600,
+			Retry:   600,
+			Expire:  86400,
+			Minttl:  zoneTTL,
+		},
+		&dns.NS{
+			Hdr: dns.RR_Header{Name: origin, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: zoneTTL},
+			Ns:  "localhost.",
+		},
+	}
+	for _, rr := range header {
+		buf.WriteString(rr.String() + "\n")
+	}
+
+	buf.Write(entries(origin, ss))
+	return buf.Bytes()
+}
+
+// Used to get the policy records of the suspended domains, the wildcard blocks the sub domains
+// e.g. qrn7oq.lb.rancher.cloud => qrn7oq.lb.rancher.cloud.rpz.local. CNAME .
+// e.g. qrn7oq.lb.rancher.cloud => *.qrn7oq.lb.rancher.cloud.rpz.local. CNAME .
+func entries(origin string, ss []model.Suspension) []byte {
+	var buf bytes.Buffer
+	for _, s := range ss {
+		name := dns.Fqdn(s.Fqdn) + origin
+		for _, n := range []string{name, "*." + name} {
+			rr := &dns.CNAME{
+				Hdr:    dns.RR_Header{Name: n, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: zoneTTL},
+				Target: ".",
+			}
+			buf.WriteString(rr.String() + "\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+type writer struct {
+	file string
+	last []byte
+}
+
+// StartRPZDaemon writes the response policy zone to RPZ_FILE every RPZ_INTERVAL, the file
+// can be served to the resolvers by any authoritative server (e.g. the coredns file plugin
+// with zone transfers). It returns at once when no file is configured.
+func StartRPZDaemon(done chan struct{}) {
+	file := os.Getenv("RPZ_FILE")
+	if file == "" {
+		return
+	}
+
+	interval, err := time.ParseDuration(os.Getenv("RPZ_INTERVAL"))
+	if err != nil || interval <= 0 {
+		logrus.Errorf("invalid rpz interval %s, use %s", os.Getenv("RPZ_INTERVAL"), defaultInterval)
+		interval = defaultInterval
+	}
+
+	w := &writer{file: file}
+	logrus.Infof("writing response policy zone %s to %s every %s", origin(), file, interval)
+	wait.Until(func() {
+		if err := w.write(); err != nil {
+			logrus.Error(err)
+		}
+	}, interval, done)
+}
+
+// write only rewrites the file when the suspended domains are changed, so the serial
+// is bumped and the resolvers transfer the zone only when it is needed.
+func (w *writer) write() error {
+	ss, err := backend.GetBackend().ListSuspensions()
+	if err != nil {
+		return errors.Wrap(err, errListSuspensions)
+	}
+
+	o := origin()
+	current := entries(o, ss)
+	if w.last != nil && bytes.Equal(current, w.last) {
+		return nil
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(w.file), filepath.Base(w.file))
+	if err != nil {
+		return errors.Wrapf(err, errWriteZone, w.file)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, errWriteZone, w.file)
+	}
+	if _, err := tmp.Write(build(o, uint32(time.Now().Unix()), ss)); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, errWriteZone, w.file)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, errWriteZone, w.file)
+	}
+	// rename in place so the zone is never read half written
+	if err := os.Rename(tmp.Name(), w.file); err != nil {
+		return errors.Wrapf(err, errWriteZone, w.file)
+	}
+
+	w.last = current
+	logrus.Infof("wrote response policy zone %s with %d suspended domains", o, len(ss))
+	return nil
+}
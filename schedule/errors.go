@@ -0,0 +1,10 @@
+package schedule
+
+const (
+	errApplyChange      = "failed to make scheduled %s change %s of domain %s"
+	errDecodeSchedule   = "failed to decode the scheduled changes of %s"
+	errNoChange         = "no scheduled change %s of domain %s"
+	errTooManyChanges   = "domain %s has %d scheduled changes already"
+	errTooManyScheduled = "%d changes are scheduled already"
+	errUnknownAction    = "unknown action %s"
+)
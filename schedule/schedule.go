@@ -0,0 +1,339 @@
+// Package schedule makes the changes of domains which are scheduled for a time, e.g. the hosts of
+// a planned migration. The changes are kept in the schedule keys of the operational state, so they
+// survive restarts and every replica sees them.
+package schedule
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/state"
+	"github.com/rancher/rdns-server/util"
+	"github.com/rancher/rdns-server/webhook"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// KeySchedule keeps the scheduled changes of the domains, they are kept in shards below it by
+	// the hash of their fqdn e.g. schedule/2a. The changes of earlier versions are kept in the key itself.
+	KeySchedule = "schedule"
+
+	defaultInterval = 10 * time.Second
+	idLength        = 8
+	// the domains which schedule many changes fill the shard of their fqdn, not the schedule of
+	// every domain. The changes of a shard are kept in one value, so their number is bounded
+	shards           = 64
+	maxDomainChanges = 10
+	maxShardChanges  = 500
+)
+
+// ErrNoChange is returned when a change which is canceled is not scheduled, it may be made already.
+var ErrNoChange = errors.New("no such scheduled change")
+
+// errUnchanged leaves a shard as it is when none of its changes is taken or removed
+var errUnchanged = errors.New("the scheduled changes are unchanged")
+
+// check refuses the changes which the api would refuse at the time they are made, see SetCheck
+var check func(c model.ScheduledChange) error
+
+// SetCheck sets the check a change passes again before it is made, e.g. the validation of its hosts
+// and the change limit of its domain, so a change which the api refuses is not made by scheduling it.
+func SetCheck(f func(c model.ScheduledChange) error) {
+	check = f
+}
+
+var changeCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rancher_dns_scheduled_changes",
+	Help: "The number of scheduled changes which are made by action and result",
+}, []string{"action", "result"})
+
+// Add schedules a change of a domain, the options are validated by the caller. The source and
+// the proofs of the request are kept so the hosts are checked with them again.
+func Add(b backend.Backend, fqdn string, opts *model.ScheduleOptions, source string, proofs map[string]string) (model.ScheduledChange, error) {
+	now := time.Now()
+	c := model.ScheduledChange{
+		ID:        util.RandStringWithSmall(idLength),
+		Fqdn:      fqdn,
+		Action:    opts.Action,
+		Hosts:     opts.Hosts,
+		SubDomain: opts.SubDomain,
+		At:        *opts.At,
+		Created:   &now,
+		Source:    source,
+		Proofs:    proofs,
+	}
+
+	// the changes of earlier versions count against the domain until they are made
+	var legacy []model.ScheduledChange
+	if _, err := state.Get(b, KeySchedule, &legacy); err != nil {
+		return c, err
+	}
+
+	var changes []model.ScheduledChange
+	err := state.Update(b, shardKey(fqdn), &changes, func() error {
+		if len(changes) >= maxShardChanges {
+			return errors.Errorf(errTooManyScheduled, len(changes))
+		}
+		if n := len(filter(changes, fqdn)) + len(filter(legacy, fqdn)); n >= maxDomainChanges {
+			return errors.Errorf(errTooManyChanges, fqdn, n)
+		}
+		changes = append(changes, c)
+		return nil
+	})
+
+	return c, err
+}
+
+// List returns the scheduled changes of a domain in the order they are made, an empty fqdn lists
+// the changes of all domains.
+func List(b backend.Backend, fqdn string) ([]model.ScheduledChange, error) {
+	var result []model.ScheduledChange
+	if fqdn == "" {
+		all, err := listShards(b)
+		if err != nil {
+			return nil, err
+		}
+		result = make([]model.ScheduledChange, 0)
+		for _, changes := range all {
+			result = append(result, changes...)
+		}
+	} else {
+		result = make([]model.ScheduledChange, 0)
+		for _, key := range []string{KeySchedule, shardKey(fqdn)} {
+			var changes []model.ScheduledChange
+			if _, err := state.Get(b, key, &changes); err != nil {
+				return nil, err
+			}
+			result = append(result, filter(changes, fqdn)...)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].At.Before(result[j].At) })
+	return result, nil
+}
+
+// Cancel removes a scheduled change of a domain before it is made.
+func Cancel(b backend.Backend, fqdn, id string) error {
+	for _, key := range []string{shardKey(fqdn), KeySchedule} {
+		err := remove(b, key, func(c model.ScheduledChange) bool { return c.ID == id && c.Fqdn == fqdn })
+		if err != errUnchanged {
+			return err
+		}
+	}
+	return errors.Wrapf(ErrNoChange, errNoChange, id, fqdn)
+}
+
+// Purge removes the changes of a domain which are due after a time, a zero time removes all of
+// them. The changes of a domain which is deleted or expires are purged, so they are never made to
+// a domain which is registered with its name again.
+func Purge(b backend.Backend, fqdn string, after time.Time) error {
+	for _, key := range []string{shardKey(fqdn), KeySchedule} {
+		err := remove(b, key, func(c model.ScheduledChange) bool { return c.Fqdn == fqdn && c.At.After(after) })
+		if err != nil && err != errUnchanged {
+			return err
+		}
+	}
+	return nil
+}
+
+// Used to remove the changes of a key which match, errUnchanged is returned when none matches
+func remove(b backend.Backend, key string, match func(c model.ScheduledChange) bool) error {
+	var changes []model.ScheduledChange
+	return state.Update(b, key, &changes, func() error {
+		kept := make([]model.ScheduledChange, 0, len(changes))
+		for _, c := range changes {
+			if !match(c) {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == len(changes) {
+			return errUnchanged
+		}
+		changes = kept
+		return nil
+	})
+}
+
+// Used to get the key of the shard which keeps the changes of a domain
+// e.g. sample.lb.rancher.cloud => schedule/2a
+func shardKey(fqdn string) string {
+	sum := sha256.Sum256([]byte(fqdn))
+	return fmt.Sprintf("%s/%02x", KeySchedule, sum[0]%shards)
+}
+
+// Used to read the changes of every schedule key at once by key, the keys without changes are left out
+func listShards(b backend.Backend) (map[string][]model.ScheduledChange, error) {
+	states, err := b.ListOperationalStates()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]model.ScheduledChange)
+	for _, s := range states {
+		if (s.Key != KeySchedule && !strings.HasPrefix(s.Key, KeySchedule+"/")) || len(s.Value) == 0 {
+			continue
+		}
+		var changes []model.ScheduledChange
+		if err := json.Unmarshal(s.Value, &changes); err != nil {
+			return nil, errors.Wrapf(err, errDecodeSchedule, s.Key)
+		}
+		if len(changes) > 0 {
+			result[s.Key] = changes
+		}
+	}
+	return result, nil
+}
+
+// StartScheduleDaemon makes the changes which are due every SCHEDULE_INTERVAL. A due change is
+// taken out of the schedule before it is made, the versioned write lets one replica take it so
+// every change is made once.
+func StartScheduleDaemon(done chan struct{}) {
+	interval, err := time.ParseDuration(os.Getenv("SCHEDULE_INTERVAL"))
+	if err != nil || interval <= 0 {
+		logrus.Errorf("invalid schedule interval %s, use %s", os.Getenv("SCHEDULE_INTERVAL"), defaultInterval)
+		interval = defaultInterval
+	}
+
+	logrus.Infof("making the scheduled changes of domains every %s", interval)
+	wait.Until(run, interval, done)
+}
+
+func run() {
+	// the changes which are due during the maintenance are made once it is over, like the changes of the api
+	if m := state.InMaintenance(); m.Enabled {
+		logrus.Debugf("the scheduled changes are not made during maintenance")
+		return
+	}
+
+	b := backend.GetBackend()
+
+	due, err := take(b, time.Now())
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	for _, c := range due {
+		result := "success"
+		if err := apply(b, c); err != nil {
+			result = "failure"
+			logrus.Error(errors.Wrapf(err, errApplyChange, c.Action, c.ID, c.Fqdn))
+		} else {
+			logrus.Infof("made scheduled %s change %s of domain %s", c.Action, c.ID, c.Fqdn)
+		}
+		changeCounter.WithLabelValues(c.Action, result).Inc()
+	}
+}
+
+// Used to take the changes which are due out of the schedule, a change which another replica
+// takes meanwhile is left to it. Only the shards which hold a due change are written.
+func take(b backend.Backend, now time.Time) ([]model.ScheduledChange, error) {
+	all, err := listShards(b)
+	if err != nil {
+		return nil, err
+	}
+
+	due := make([]model.ScheduledChange, 0)
+	for key, changes := range all {
+		if !hasDue(changes, now) {
+			continue
+		}
+		taken, err := takeShard(b, key, now)
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+		due = append(due, taken...)
+	}
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].At.Before(due[j].At) })
+	return due, nil
+}
+
+func takeShard(b backend.Backend, key string, now time.Time) ([]model.ScheduledChange, error) {
+	var changes, due []model.ScheduledChange
+	err := state.Update(b, key, &changes, func() error {
+		due = make([]model.ScheduledChange, 0)
+		pending := make([]model.ScheduledChange, 0, len(changes))
+		for _, c := range changes {
+			if c.At.After(now) {
+				pending = append(pending, c)
+				continue
+			}
+			due = append(due, c)
+		}
+		if len(due) == 0 {
+			return errUnchanged
+		}
+		changes = pending
+		return nil
+	})
+	if err == errUnchanged {
+		return nil, nil
+	}
+	return due, err
+}
+
+func hasDue(changes []model.ScheduledChange, now time.Time) bool {
+	for _, c := range changes {
+		if !c.At.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Used to make a change the way the api makes it, the webhooks of the domain are told about it
+func apply(b backend.Backend, c model.ScheduledChange) error {
+	if check != nil {
+		if err := check(c); err != nil {
+			return err
+		}
+	}
+
+	before := webhook.Snapshot(b.Get, &model.DomainOptions{Fqdn: c.Fqdn})
+
+	switch c.Action {
+	case model.ScheduleHosts:
+		d, err := b.Update(&model.DomainOptions{Fqdn: c.Fqdn, Hosts: c.Hosts, SubDomain: c.SubDomain})
+		if err != nil {
+			return err
+		}
+		webhook.PublishChange(model.EventDomainUpdated, c.Fqdn, d, before, &d)
+	case model.ScheduleDelete:
+		if err := b.Delete(&model.DomainOptions{Fqdn: c.Fqdn}); err != nil {
+			return err
+		}
+		webhook.PublishChange(model.EventDomainDeleted, c.Fqdn, nil, before, nil)
+		webhook.Forget(c.Fqdn)
+		if err := Purge(b, c.Fqdn, time.Time{}); err != nil {
+			logrus.Error(err)
+		}
+	default:
+		return errors.Errorf(errUnknownAction, c.Action)
+	}
+
+	return nil
+}
+
+func filter(changes []model.ScheduledChange, fqdn string) []model.ScheduledChange {
+	result := make([]model.ScheduledChange, 0)
+	for _, c := range changes {
+		if fqdn == "" || c.Fqdn == fqdn {
+			result = append(result, c)
+		}
+	}
+	return result
+}
@@ -0,0 +1,6 @@
+package server
+
+const (
+	errNoBackend = "expected a backend or the name of a backend to serve the domains"
+	errSetEnv    = "failed to set environment %s"
+)
@@ -0,0 +1,141 @@
+// Package server embeds rdns-server in other Go programs, e.g. Rancher or test harnesses. The
+// program opens the backend, New prepares the api and Run runs the background subsystems.
+//
+// The server is configured by the environments of the binary and keeps its state in the
+// packages it is built of, so a process embeds one server at a time.
+package server
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/rancher/rdns-server/admin"
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/dual"
+	"github.com/rancher/rdns-server/config"
+	"github.com/rancher/rdns-server/expiry"
+	"github.com/rancher/rdns-server/health"
+	"github.com/rancher/rdns-server/lifecycle"
+	"github.com/rancher/rdns-server/metric"
+	"github.com/rancher/rdns-server/migration"
+	"github.com/rancher/rdns-server/nodes"
+	"github.com/rancher/rdns-server/rpz"
+	"github.com/rancher/rdns-server/schedule"
+	"github.com/rancher/rdns-server/service"
+	"github.com/rancher/rdns-server/slo"
+	"github.com/rancher/rdns-server/state"
+	"github.com/rancher/rdns-server/webhook"
+
+	"github.com/pkg/errors"
+)
+
+// Config configures an embedded server.
+type Config struct {
+	// Backend serves the domains, the registered backend of Name is opened when it is nil
+	Backend backend.Backend
+	Name    string
+	// Env sets the environments of the options before the backend is opened
+	// e.g. {"DOMAIN": "lb.example.com", "ADMIN_TOKEN": "..."}
+	Env map[string]string
+	// Listen is the address Run serves the api on, the api is only returned by Handler when it is empty
+	Listen string
+	// Subsystems are run after the common subsystems and before the api, e.g. the dns server of a backend
+	Subsystems []Subsystem
+}
+
+// Subsystem is a background subsystem, it is skipped when it is listed in DISABLE_SUBSYSTEMS.
+type Subsystem struct {
+	Name string
+	Run  lifecycle.Run
+}
+
+type Server struct {
+	handler    http.Handler
+	listen     string
+	subsystems []Subsystem
+}
+
+// New sets the backend, applies the pending data migrations and loads the settings, the admin
+// credentials and the operational state, then returns the server of the api.
+func New(c *Config) (*Server, error) {
+	if c.Backend == nil && c.Name == "" {
+		return nil, errors.New(errNoBackend)
+	}
+	for k, v := range c.Env {
+		if err := os.Setenv(k, v); err != nil {
+			return nil, errors.Wrapf(err, errSetEnv, k)
+		}
+	}
+
+	if err := config.Load(); err != nil {
+		return nil, err
+	}
+
+	b, err := open(c)
+	if err != nil {
+		return nil, err
+	}
+	backend.SetBackend(b)
+
+	if err := migration.RunOnStartup(b); err != nil {
+		return nil, err
+	}
+
+	if err := admin.Load(); err != nil {
+		return nil, err
+	}
+
+	if err := state.Load(b); err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		handler:    service.NewRouter(),
+		listen:     c.Listen,
+		subsystems: c.Subsystems,
+	}, nil
+}
+
+// Handler returns the api, it can be mounted on a router of the program.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Run runs the background subsystems and the api of Listen until one of them fails or done is
+// closed, then stops them all and returns the first fatal error.
+func (s *Server) Run(done chan struct{}) error {
+	m := lifecycle.New()
+	m.Add("state", lifecycle.Daemon(state.StartStateDaemon))
+	m.Add("metric", lifecycle.Daemon(metric.StartMetricDaemon))
+	m.Add("exporter", lifecycle.Daemon(metric.StartExporterDaemon))
+	m.Add("slo", lifecycle.Daemon(slo.StartSLODaemon))
+	m.Add("rpz", lifecycle.Daemon(rpz.StartRPZDaemon))
+	m.Add("health", lifecycle.Daemon(health.StartHealthDaemon))
+	m.Add("webhook", lifecycle.Daemon(webhook.StartWebhookDaemon))
+	m.Add("expiry", lifecycle.Daemon(expiry.StartExpiryDaemon))
+	m.Add("nodes", lifecycle.Daemon(nodes.StartNodeDaemon))
+	m.Add("schedule", lifecycle.Daemon(schedule.StartScheduleDaemon))
+	for _, sub := range s.subsystems {
+		m.Add(sub.Name, sub.Run)
+	}
+	if s.listen != "" {
+		m.Add("api", func(done chan struct{}) error {
+			return service.Serve(s.listen, s.handler, done)
+		})
+	}
+
+	return m.RunUntil(done)
+}
+
+// Used to open the backend of the name in the double-write mode of DOUBLE_WRITE_BACKEND, an opened
+// backend is used as it is
+func open(c *Config) (backend.Backend, error) {
+	if c.Backend != nil {
+		return c.Backend, nil
+	}
+	b, err := backend.Open(&backend.Config{Name: c.Name, DSN: os.Getenv("DSN")})
+	if err != nil {
+		return nil, err
+	}
+	return dual.Wrap(b)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// apiKeyPrefix tells api keys from the tokens of the domains, which are base64 bcrypt hashes
+	// e.g. rdnsk_k3x9a2m7.<secret>
+	apiKeyPrefix       = "rdnsk_"
+	apiKeyIDLength     = 8
+	apiKeySecretLength = 32
+)
+
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// Used to generate a new api key, only the hash of its secret is kept by the backend.
+func newAPIKey(opts *model.APIKeyOptions) (k model.APIKey, key string) {
+	id := util.RandStringWithSmall(apiKeyIDLength)
+	secret := util.RandStringWithAll(apiKeySecretLength)
+	now := time.Now()
+
+	return model.APIKey{
+		ID:         id,
+		Name:       opts.Name,
+		Tenant:     opts.Tenant,
+		RootDomain: strings.Trim(opts.RootDomain, "."),
+		Hash:       hashAPIKeySecret(secret),
+		CreatedOn:  &now,
+	}, apiKeyPrefix + id + "." + secret
+}
+
+// The secrets are random, a sha256 hash is enough to keep them and to compare them.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Used to get the api key of a bearer token, false if the token is not a valid api key.
+func lookupAPIKey(token string) (model.APIKey, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(token, apiKeyPrefix), ".", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return model.APIKey{}, false
+	}
+
+	k, err := backend.GetBackend().GetAPIKey(parts[0])
+	if err != nil {
+		logrus.Debugf("failed to get api key %s, err: %v", parts[0], err)
+		return model.APIKey{}, false
+	}
+	if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashAPIKeySecret(parts[1]))) != 1 {
+		return model.APIKey{}, false
+	}
+	return k, true
+}
+
+// Used to check an api key manages a domain, the domain must be below the root domain of the key
+// and labeled with the tenant of the key.
+// e.g. {rootDomain: lb.rancher.cloud}, sample.lb.rancher.cloud => true
+// e.g. {tenant: acme}, sample.lb.rancher.cloud labeled tenant=other => false
+func apiKeyAllows(k model.APIKey, fqdn string) bool {
+	if k.RootDomain != "" && fqdn != k.RootDomain && !strings.HasSuffix(fqdn, "."+k.RootDomain) {
+		return false
+	}
+	if k.Tenant == "" {
+		return true
+	}
+
+	// a CNAME domain has no A records on every backend, it is read with its target then
+	b := backend.GetBackend()
+	d, err := b.Get(&model.DomainOptions{Fqdn: fqdn})
+	if err != nil {
+		d, err = b.GetCNAME(&model.DomainOptions{Fqdn: fqdn})
+	}
+	if err != nil {
+		logrus.Debugf("failed to get domain %s of api key %s, err: %v", fqdn, k.ID, err)
+		return false
+	}
+	return d.Labels[model.LabelTenant] == k.Tenant
+}
+
+// Used to check the api key a domain is created with, the key must manage the root domain of the new
+// domain. The domains created with an api key of a tenant are labeled with the tenant, so the key
+// manages them afterwards. The requests without an api key are left to the frontdoor.
+func checkCreateKey(r *http.Request, opts *model.DomainOptions) error {
+	key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !isAPIKey(key) {
+		return nil
+	}
+
+	k, ok := lookupAPIKey(key)
+	if !ok || !apiKeyAllows(model.APIKey{RootDomain: k.RootDomain}, newDomainFqdn(&model.DomainOptions{Root: opts.Root})) {
+		authFailures.WithLabelValues(authFailureToken).Inc()
+		return errors.New("forbidden to use")
+	}
+	if k.Tenant != "" {
+		if opts.Labels == nil {
+			opts.Labels = make(map[string]string)
+		}
+		opts.Labels[model.LabelTenant] = k.Tenant
+	}
+	return nil
+}
+
+// Used to authorize a request of a domain with an api key instead of the token of the domain.
+func compareAPIKey(fqdn, token string) bool {
+	k, ok := lookupAPIKey(token)
+	if !ok {
+		logrus.Errorf("failed to compare api key with fqdn %s", fqdn)
+		return false
+	}
+	if !apiKeyAllows(k, tokenFqdn(fqdn)) {
+		logrus.Errorf("api key %s (%s) is not allowed to manage %s", k.ID, k.Name, fqdn)
+		return false
+	}
+	logrus.Debugf("api key %s matched with fqdn %s", k.ID, fqdn)
+	return true
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/rancher/rdns-server/model"
+)
+
+func TestTenantKeyManagesCNAMEDomain(t *testing.T) {
+	b, c := newTestServer(t)
+
+	newKey := func(tenant, root string) string {
+		k, key := newAPIKey(&model.APIKeyOptions{Name: tenant, Tenant: tenant, RootDomain: root})
+		if err := b.SetAPIKey(&k); err != nil {
+			t.Fatalf("failed to set the api key: %v", err)
+		}
+		return key
+	}
+	acme, other := newKey("acme", testRoot), newKey("other", testRoot)
+
+	_, d, err := c.RegisterWithAPIKey(&model.DomainOptions{CNAME: "target.example.com"}, acme)
+	if err != nil {
+		t.Fatalf("failed to create a CNAME domain with the api key: %v", err)
+	}
+	if d.Labels[model.LabelTenant] != "acme" {
+		t.Fatalf("expected the domain to be labeled with the tenant of the key, got %v", d.Labels)
+	}
+
+	if _, err := c.Domain(d.Fqdn, acme).UpdateCNAME("other.example.com"); err != nil {
+		t.Fatalf("failed to update the CNAME domain with the api key of its tenant: %v", err)
+	}
+	if _, err := c.Domain(d.Fqdn, other).UpdateCNAME("third.example.com"); err == nil {
+		t.Fatalf("expected the api key of another tenant to be refused")
+	}
+
+	// a key of another root domain creates no CNAME domains of this one
+	if _, _, err := c.RegisterWithAPIKey(&model.DomainOptions{CNAME: "target.example.com"}, newKey("acme", "lb.example.com")); err == nil {
+		t.Fatalf("expected the api key of another root domain to be refused")
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/device"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const devicePath = "/v1/device"
+
+// devicePage lets the owner of a domain approve a user code from a browser with the token of the domain,
+// the token is only sent to this api.
+var devicePage = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Approve a device</title></head>
+<body>
+<h1>Approve a device</h1>
+{{if .Fqdn}}<p>The device asks for the token of <b>{{.Fqdn}}</b>, only approve it if you started the request.</p>{{end}}
+<form id="approve">
+<p><label>User code <input id="user-code" value="{{.UserCode}}" required></label></p>
+<p><label>Domain <input id="fqdn" value="{{.Fqdn}}" required></label></p>
+<p><label>Token of the domain <input id="token" type="password" required></label></p>
+<p><button type="submit">Approve</button></p>
+</form>
+<p id="result"></p>
+<script>
+document.getElementById("approve").addEventListener("submit", function (e) {
+  e.preventDefault();
+  var fqdn = document.getElementById("fqdn").value;
+  fetch("domain/" + encodeURIComponent(fqdn) + "/device/approve", {
+    method: "POST",
+    headers: {"Content-Type": "application/json", "Authorization": "Bearer " + document.getElementById("token").value},
+    body: JSON.stringify({userCode: document.getElementById("user-code").value})
+  }).then(function (r) { return r.json(); }).then(function (r) {
+    document.getElementById("result").textContent = r.status === 200 ? "Approved, the device gets the token now." : r.msg;
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// The domain must exist, the device gets its token once the owner approves the user code.
+func createDeviceCode(w http.ResponseWriter, r *http.Request) {
+	opts, err := model.ParseDeviceOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	fqdn, err := zoneFqdn(opts.Fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := backend.GetBackend().GetToken(fqdn); err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	c, err := device.Request(fqdn, verificationURI(r))
+	if err != nil {
+		returnHTTPError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	returnSuccessWithDeviceCode(w, c)
+}
+
+func createDeviceToken(w http.ResponseWriter, r *http.Request) {
+	opts, err := model.ParseDeviceOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	fqdn, err := device.Redeem(opts.DeviceCode)
+	if err != nil {
+		// polling devices get the RFC 8628 error as the message, it is not logged as an error
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		res, _ := json.Marshal(model.Response{Status: http.StatusBadRequest, Message: err.Error()})
+		w.Write(res)
+		return
+	}
+
+	logrus.Infof("issued the token of %s to an approved device", fqdn)
+	returnSuccessWithToken(w, model.Domain{Fqdn: fqdn}, "")
+}
+
+func approveDevice(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := model.ParseDeviceOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := device.Approve(fqdn, opts.UserCode); err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	returnSuccessNoData(w)
+}
+
+func getDevicePage(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		UserCode string
+		Fqdn     string
+	}{
+		UserCode: r.URL.Query().Get("user_code"),
+	}
+	if data.UserCode != "" {
+		data.Fqdn, _ = device.Lookup(data.UserCode)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := devicePage.Execute(w, data); err != nil {
+		logrus.Error(errors.Wrap(err, "failed to render the device page"))
+	}
+}
+
+// Used to get the url of the device page as the client reached it
+// e.g. https://api.lb.rancher.cloud/v1/device
+func verificationURI(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = strings.TrimSpace(strings.Split(p, ",")[0])
+	}
+	return scheme + "://" + r.Host + devicePath
+}
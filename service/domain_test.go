@@ -0,0 +1,85 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rancher/rdns-server/model"
+)
+
+func TestDomainLifecycle(t *testing.T) {
+	b, c := newTestServer(t)
+
+	dc, d, err := c.Register(&model.DomainOptions{Hosts: []string{"1.1.1.1"}, SubDomain: map[string][]string{"x1": {"2.2.2.2"}}})
+	if err != nil {
+		t.Fatalf("failed to create a domain: %v", err)
+	}
+	if !strings.HasSuffix(d.Fqdn, "."+testRoot) || dc.Token() == "" {
+		t.Fatalf("expected a domain below %s with a token, got %s", testRoot, d.Fqdn)
+	}
+	stored, err := b.Get(&model.DomainOptions{Fqdn: d.Fqdn})
+	if err != nil {
+		t.Fatalf("failed to get the domain from the backend: %v", err)
+	}
+	if !reflect.DeepEqual(stored.Hosts, []string{"1.1.1.1"}) || !reflect.DeepEqual(stored.SubDomain["x1"], []string{"2.2.2.2"}) {
+		t.Fatalf("expected the hosts of the request, got %v %v", stored.Hosts, stored.SubDomain)
+	}
+
+	if _, err := c.Domain(d.Fqdn, "wrong").Get(); err == nil {
+		t.Fatalf("expected the domain to be refused with a wrong token")
+	}
+	if _, err := dc.Update([]string{"3.3.3.3"}, nil); err != nil {
+		t.Fatalf("failed to update the domain: %v", err)
+	}
+	if d, err = dc.Get(); err != nil || !reflect.DeepEqual(d.Hosts, []string{"3.3.3.3"}) || len(d.SubDomain) != 0 {
+		t.Fatalf("expected the hosts of the update, got %v %v, err: %v", d.Hosts, d.SubDomain, err)
+	}
+
+	if err := dc.Delete(); err != nil {
+		t.Fatalf("failed to delete the domain: %v", err)
+	}
+	if _, err := b.Get(&model.DomainOptions{Fqdn: d.Fqdn}); err == nil {
+		t.Fatalf("expected the domain to be deleted from the backend")
+	}
+	if _, err := dc.Get(); err == nil {
+		t.Fatalf("expected the deleted domain to be gone")
+	}
+}
+
+func TestUpdateTextOfOrder(t *testing.T) {
+	_, c := newTestServer(t)
+
+	dc, d, err := c.Register(&model.DomainOptions{Hosts: []string{"1.1.1.1"}})
+	if err != nil {
+		t.Fatalf("failed to create a domain: %v", err)
+	}
+	name := "_acme-challenge." + d.Fqdn
+
+	if _, err := dc.SetText(name, "first", "o1"); err != nil {
+		t.Fatalf("failed to set a text: %v", err)
+	}
+	if _, err := dc.SetText(name, "second", "o2"); err != nil {
+		t.Fatalf("failed to set a text: %v", err)
+	}
+	if _, err := dc.UpdateText(name, "updated", "o1"); err != nil {
+		t.Fatalf("failed to update the text: %v", err)
+	}
+
+	r, err := dc.GetText(name, "o1")
+	if err != nil || r.Text != "updated" {
+		t.Fatalf("expected the updated text of the order, got %q, err: %v", r.Text, err)
+	}
+	// the texts of the other orders are kept
+	r, err = dc.GetText(name, "o2")
+	if err != nil || r.Text != "second" {
+		t.Fatalf("expected the text of the other order, got %q, err: %v", r.Text, err)
+	}
+
+	if err := dc.DeleteText(name, ""); err != nil {
+		t.Fatalf("failed to delete the texts: %v", err)
+	}
+	if r, _ := dc.GetText(name, ""); r.Text != "" {
+		t.Fatalf("expected no text after the delete, got %v", r.Texts)
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/rdns-server/model"
+)
+
+func TestDrainUnknownHost(t *testing.T) {
+	_, c := newTestServer(t)
+
+	dc, d, err := c.Register(&model.DomainOptions{Hosts: []string{"1.1.1.1"}})
+	if err != nil {
+		t.Fatalf("failed to create a domain: %v", err)
+	}
+
+	send := func(method, host string) int {
+		r := httptest.NewRequest(method, "/v1/domain/"+d.Fqdn+"/hosts/"+host+"/drain", strings.NewReader(`{"timeout": 60}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer "+dc.Token())
+		w := httptest.NewRecorder()
+		NewRouter().ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := send(http.MethodPost, "2.2.2.2"); code != http.StatusNotFound {
+		t.Fatalf("expected draining a host which the domain has not to be not found, got %d", code)
+	}
+	if code := send(http.MethodDelete, "2.2.2.2"); code != http.StatusNotFound {
+		t.Fatalf("expected undraining a host which the domain has not to be not found, got %d", code)
+	}
+
+	if _, err := dc.DrainHost("1.1.1.1", 60); err != nil {
+		t.Fatalf("failed to drain a host of the domain: %v", err)
+	}
+	if _, err := dc.UndrainHost("1.1.1.1"); err != nil {
+		t.Fatalf("failed to undrain a host of the domain: %v", err)
+	}
+}
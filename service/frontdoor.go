@@ -0,0 +1,123 @@
+package service
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// createRoutes are the routes which create new domains, the other routes of a domain are checked
+// with its own token
+var createRoutes = map[string]bool{
+	"createDomain":      true,
+	"createDomainCNAME": true,
+}
+
+// frontdoor keeps the keys of CREATE_API_KEYS and CREATE_API_KEYS_FILE, a new domain is only created
+// with one of them or with an api key of the backend. Only the sha256 hashes of the keys are kept.
+type frontdoor struct {
+	hashes [][]byte
+}
+
+// Used to get the frontdoor of CREATE_API_KEYS and CREATE_API_KEYS_FILE, nil means anyone creates domains
+func newFrontdoor() *frontdoor {
+	keys := make([]string, 0)
+	for _, k := range strings.Split(os.Getenv("CREATE_API_KEYS"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	if path := os.Getenv("CREATE_API_KEYS_FILE"); path != "" {
+		fileKeys, err := readCreateKeys(path)
+		if err != nil {
+			// a file which can not be read must not open the door, no static key is accepted then
+			logrus.Errorf("failed to read create api keys %s, only the api keys of the backend create domains, err: %v", path, err)
+		}
+		keys = append(keys, fileKeys...)
+	} else if len(keys) == 0 {
+		return nil
+	}
+
+	f := &frontdoor{}
+	for _, k := range keys {
+		if isAPIKey(k) {
+			logrus.Warnf("create api key %s... has the prefix of the api keys of the backend, it is never matched", apiKeyPrefix)
+			continue
+		}
+		sum := sha256.Sum256([]byte(k))
+		f.hashes = append(f.hashes, sum[:])
+	}
+
+	logrus.Infof("new domains are only created with one of %d create api keys or an api key of the backend", len(f.hashes))
+	return f
+}
+
+// The file holds one key per line, lines starting with # are comments.
+func readCreateKeys(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keys := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, scanner.Err()
+}
+
+// Used to check a bearer key is allowed to create domains, every key is compared so the time of the
+// check does not tell which one is close.
+func (f *frontdoor) allows(key string) bool {
+	if isAPIKey(key) {
+		_, ok := lookupAPIKey(key)
+		return ok
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	match := 0
+	for _, h := range f.hashes {
+		match |= subtle.ConstantTimeCompare(h, sum[:])
+	}
+	return match == 1
+}
+
+// middleware refuses the creation of a domain without a key with 401 and with a wrong key with 403,
+// the domains keep their own tokens for everything else.
+func (f *frontdoor) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil || !createRoutes[route.GetName()] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "Bearer ") {
+			authFailures.WithLabelValues(authFailureCreate).Inc()
+			returnHTTPError(w, http.StatusUnauthorized, errors.New("must specific the api key to create a domain"))
+			return
+		}
+		if !f.allows(strings.TrimPrefix(authorization, "Bearer ")) {
+			authFailures.WithLabelValues(authFailureCreate).Inc()
+			returnHTTPError(w, http.StatusForbidden, errors.New("forbidden to use"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
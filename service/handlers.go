@@ -2,18 +2,60 @@ package service
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/dual"
+	"github.com/rancher/rdns-server/config"
+	"github.com/rancher/rdns-server/coredns"
+	"github.com/rancher/rdns-server/export"
+	"github.com/rancher/rdns-server/health"
+	"github.com/rancher/rdns-server/lifecycle"
 	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/nodes"
+	"github.com/rancher/rdns-server/recovery"
+	"github.com/rancher/rdns-server/reputation"
+	"github.com/rancher/rdns-server/rpz"
+	"github.com/rancher/rdns-server/schedule"
+	"github.com/rancher/rdns-server/slo"
+	"github.com/rancher/rdns-server/state"
+	"github.com/rancher/rdns-server/util"
+	"github.com/rancher/rdns-server/webhook"
 
 	"github.com/gorilla/context"
 	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	defaultDrainTimeout = time.Hour
+	maxDrainTimeout     = 24 * time.Hour
+
+	tokenOriginLength = 32
+)
+
+// Used to get the status of an error of a write to the backend, the names which external-dns owns
+// are a conflict with their owner and a drained host which the domain has not is not found
+func writeStatus(err error) int {
+	switch errors.Cause(err) {
+	case backend.ErrForeignOwner:
+		return http.StatusConflict
+	case backend.ErrUnknownHost:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
 func returnHTTPError(w http.ResponseWriter, httpStatus int, err error) {
-	logrus.Errorf("got a response error: %v", err)
+	if l := requestLogOf(w); l != nil {
+		l.err = err
+	} else {
+		logrus.Errorf("got a response error: %v", err)
+	}
 	o := model.Response{
 		Status:  httpStatus,
 		Message: err.Error(),
@@ -37,6 +79,9 @@ func returnSuccess(w http.ResponseWriter, d model.Domain, msg string) {
 		return
 	}
 
+	if d.Fqdn != "" {
+		w.Header().Set(model.ChecksumHeader, d.Checksum())
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(res)
 }
@@ -59,285 +104,1966 @@ func returnSuccessWithToken(w http.ResponseWriter, d model.Domain, msg string) {
 		return
 	}
 
+	if d.Fqdn != "" {
+		w.Header().Set(model.ChecksumHeader, d.Checksum())
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(res)
 }
 
-func returnSuccessNoData(w http.ResponseWriter) {
-	o := model.Response{
+func returnSuccessWithList(w http.ResponseWriter, l model.DomainList) {
+	o := model.ListResponse{
 		Status: http.StatusOK,
+		Data:   l,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
 	}
-	res, _ := json.Marshal(o)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(res)
 }
 
-func apiHandler(f http.Handler) http.Handler {
-	return context.ClearHandler(f)
+func returnSuccessWithState(w http.ResponseWriter, d *dual.Backend) {
+	o := model.StateResponse{
+		Status: http.StatusOK,
+		Data: model.BackendState{
+			State: d.State(),
+			Old:   d.Old.GetName(),
+			New:   d.New.GetName(),
+		},
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
 }
 
-func createDomain(w http.ResponseWriter, r *http.Request) {
-	vals := r.URL.Query()
+func returnSuccessWithHost(w http.ResponseWriter, host string, domains []string) {
+	o := model.HostResponse{
+		Status: http.StatusOK,
+		Data: model.HostDomains{
+			Host:    host,
+			Domains: domains,
+		},
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithReplace(w http.ResponseWriter, r model.HostReplace) {
+	o := model.HostReplaceResponse{
+		Status: http.StatusOK,
+		Data:   r,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithNodeRemoval(w http.ResponseWriter, r model.NodeRemoval) {
+	o := model.NodeRemovalResponse{
+		Status: http.StatusOK,
+		Data:   r,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithSuspension(w http.ResponseWriter, s model.Suspension) {
+	o := model.SuspensionResponse{
+		Status: http.StatusOK,
+		Data:   s,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithSuspensions(w http.ResponseWriter, ss []model.Suspension) {
+	o := model.SuspensionListResponse{
+		Status: http.StatusOK,
+		Data:   ss,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithOperationalStates(w http.ResponseWriter, ss []model.OperationalState) {
+	o := model.OperationalStatesResponse{
+		Status: http.StatusOK,
+		Data:   ss,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithMaintenance(w http.ResponseWriter, m model.Maintenance) {
+	o := model.MaintenanceResponse{
+		Status: http.StatusOK,
+		Data:   m,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithFeatures(w http.ResponseWriter, features map[string]bool) {
+	o := model.FeaturesResponse{
+		Status: http.StatusOK,
+		Data:   features,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithInspection(w http.ResponseWriter, i model.DomainInspection) {
+	o := model.DomainInspectionResponse{
+		Status: http.StatusOK,
+		Data:   i,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithAPIKey(w http.ResponseWriter, k model.APIKey) {
+	o := model.APIKeyResponse{
+		Status: http.StatusOK,
+		Data:   k,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithAPIKeys(w http.ResponseWriter, ks []model.APIKey) {
+	o := model.APIKeyListResponse{
+		Status: http.StatusOK,
+		Data:   ks,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithHealth(w http.ResponseWriter, h model.DomainHealth) {
+	o := model.HealthResponse{
+		Status: http.StatusOK,
+		Data:   h,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithCAA(w http.ResponseWriter, records []model.CAARecord) {
+	o := model.CAAResponse{
+		Status: http.StatusOK,
+		Data:   records,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithKeepalive(w http.ResponseWriter, k model.Keepalive) {
+	o := model.KeepaliveResponse{
+		Status: http.StatusOK,
+		Data:   k,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithRecovery(w http.ResponseWriter, c model.RecoveryChallenge) {
+	o := model.RecoveryResponse{
+		Status: http.StatusOK,
+		Data:   c,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithDeviceCode(w http.ResponseWriter, c model.DeviceCode) {
+	o := model.DeviceCodeResponse{
+		Status: http.StatusOK,
+		Data:   c,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithCoreFileDrift(w http.ResponseWriter, d model.CoreFileDrift) {
+	o := model.CoreFileDriftResponse{
+		Status: http.StatusOK,
+		Data:   d,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithConfig(w http.ResponseWriter, c model.Config) {
+	o := model.ConfigResponse{
+		Status: http.StatusOK,
+		Data:   c,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithWebhook(w http.ResponseWriter, h model.Webhook) {
+	o := model.WebhookResponse{
+		Status: http.StatusOK,
+		Data:   h,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithWebhooks(w http.ResponseWriter, hs []model.Webhook) {
+	for i := range hs {
+		hs[i].Secret = ""
+	}
+	o := model.WebhookListResponse{
+		Status: http.StatusOK,
+		Data:   hs,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithScheduledChange(w http.ResponseWriter, c model.ScheduledChange) {
+	o := model.ScheduledChangeResponse{
+		Status: http.StatusOK,
+		Data:   c,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessWithScheduledChanges(w http.ResponseWriter, cs []model.ScheduledChange) {
+	o := model.ScheduledChangeListResponse{
+		Status: http.StatusOK,
+		Data:   cs,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func returnSuccessNoData(w http.ResponseWriter) {
+	o := model.Response{
+		Status: http.StatusOK,
+	}
+	res, _ := json.Marshal(o)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func apiHandler(f http.Handler) http.Handler {
+	return context.ClearHandler(f)
+}
+
+func createDomain(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+
+	opts, err := model.ParseDomainOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
+		opts.Normal = true
+	}
+	opts.CreatorIP = clientIP(r)
+
+	if err := checkRoot(opts); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := checkCreateKey(r, opts); err != nil {
+		returnHTTPError(w, http.StatusForbidden, err)
+		return
+	}
+
+	// the fqdn of a new domain is generated, unless a name is requested for it
+	opts.Fqdn = ""
+	if err := opts.Validate(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if opts.Name != "" {
+		if err := model.ValidateBelowRoot(newDomainFqdn(opts), newDomainFqdn(&model.DomainOptions{Root: opts.Root})); err != nil {
+			returnHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	clampLease(newDomainFqdn(opts), opts)
+	if err := checkDeadline(opts); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := checkQueryTypes(newDomainFqdn(opts), opts.Records()); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	msg, err := checkReputation(newDomainFqdn(opts), opts.CreatorIP, opts)
+	if err != nil {
+		returnHTTPError(w, http.StatusForbidden, err)
+		return
+	}
+
+	b := backend.GetBackend()
+	d, err := b.Set(opts)
+	if errors.Cause(err) == backend.ErrNameTaken {
+		returnHTTPError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		returnHTTPError(w, writeStatus(err), err)
+		return
+	}
+	if d, err = setDeadline(d, opts, b.Delete); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.PublishChange(model.EventDomainCreated, d.Fqdn, d, nil, &d)
+
+	returnSuccessWithToken(w, d, msg)
+}
+
+func getDomain(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+	msg := ""
+
+	opts := &model.DomainOptions{Fqdn: fqdn}
+	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
+		opts.Normal = true
+	}
+
+	b := backend.GetReader()
+	d, err := b.Get(opts)
+	if err != nil {
+		msg = err.Error()
+	}
+	returnSuccess(w, d, msg)
+}
+
+func renewDomain(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	opts := &model.DomainOptions{Fqdn: fqdn}
+
+	b := backend.GetBackend()
+	d, err := b.Renew(opts)
+	slo.Record(slo.RenewSuccess, err == nil)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.Publish(model.EventDomainRenewed, fqdn, d)
+
+	returnSuccess(w, d, "")
+}
+
+// Renews a domain like renewDomain without reading its records, so agents which renew often only
+// get the new expiration back.
+func keepaliveDomain(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	expiration, err := backend.GetBackend().Keepalive(fqdn)
+	slo.Record(slo.RenewSuccess, err == nil)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	k := model.Keepalive{Fqdn: fqdn, Expiration: expiration}
+	webhook.Publish(model.EventDomainRenewed, fqdn, k)
+
+	returnSuccessWithKeepalive(w, k)
+}
+
+// The old token is refused as soon as the new origin is stored, the sub domains and
+// text records share the token of their domain so they are rotated with it.
+func rotateDomainToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	b := backend.GetBackend()
+	if err := b.RotateToken(fqdn, util.RandStringWithAll(tokenOriginLength)); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	d := model.Domain{Fqdn: fqdn}
+	webhook.Publish(model.EventTokenRotated, fqdn, d)
+
+	returnSuccessWithToken(w, d, "")
+}
+
+// A new read-only token replaces the read-only token the domain had, it expires with the domain.
+func createReadOnlyToken(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	origin := util.RandStringWithAll(tokenOriginLength)
+	if err := backend.GetBackend().SetScopedToken(fqdn, model.TokenScopeReadOnly, origin); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	token, err := hashOrigin(fqdn, origin)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	res, err := json.Marshal(model.Response{
+		Status: http.StatusOK,
+		Data:   model.Domain{Fqdn: fqdn},
+		Token:  token,
+	})
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+func deleteReadOnlyToken(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := backend.GetBackend().DeleteScopedToken(fqdn, model.TokenScopeReadOnly); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessNoData(w)
+}
+
+// The records are rendered as octodns or external-dns config, e.g. ?format=octodns&encoding=json
+func exportDomain(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	vals := r.URL.Query()
+	format := vals.Get("format")
+	if format == "" {
+		format = export.FormatOctoDNS
+	}
+
+	b := backend.GetReader()
+	records, err := export.Records(b, fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	res, contentType, err := export.Render(format, vals.Get("encoding"), backend.ZoneOf(b, fqdn), fqdn, records)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(res)
+}
+
+func createRecoveryChallenge(w http.ResponseWriter, r *http.Request) {
+	if !recovery.Enabled() {
+		returnHTTPError(w, http.StatusNotFound, errors.New("token recovery is not enabled"))
+		return
+	}
+
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	origin, err := backend.GetBackend().GetToken(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	returnSuccessWithRecovery(w, recovery.Challenge(fqdn, origin))
+}
+
+// The token is rotated once the hosts serve the challenge, so the lost token can not be used any more.
+func recoverDomainToken(w http.ResponseWriter, r *http.Request) {
+	if !recovery.Enabled() {
+		returnHTTPError(w, http.StatusNotFound, errors.New("token recovery is not enabled"))
+		return
+	}
+
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := model.ParseRecoveryOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b := backend.GetBackend()
+	origin, err := b.GetToken(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+	d, err := b.Get(&model.DomainOptions{Fqdn: fqdn})
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if err := recovery.Verify(d, origin, opts.Challenge); err != nil {
+		returnHTTPError(w, http.StatusForbidden, err)
+		return
+	}
+
+	if err := b.RotateToken(fqdn, util.RandStringWithAll(tokenOriginLength)); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.Publish(model.EventTokenRotated, fqdn, model.Domain{Fqdn: fqdn})
+
+	returnSuccessWithToken(w, d, "")
+}
+
+func updateDomain(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	opts, err := model.ParseDomainOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
+		opts.Normal = true
+	}
+	opts.Fqdn = fqdn
+	if err := opts.Validate(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := checkQueryTypes(fqdn, opts.Records()); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	msg, err := checkReputation(fqdn, fqdn, opts)
+	if err != nil {
+		returnHTTPError(w, http.StatusForbidden, err)
+		return
+	}
+
+	b := backend.GetBackend()
+	before := webhook.Snapshot(b.Get, &model.DomainOptions{Fqdn: fqdn})
+	d, err := b.Update(opts)
+	if err != nil {
+		returnHTTPError(w, writeStatus(err), err)
+		return
+	}
+	webhook.PublishChange(model.EventDomainUpdated, fqdn, d, before, &d)
+
+	returnSuccess(w, d, msg)
+}
+
+// GET /v1/schemas returns all schemas, GET /v1/schemas/{name} returns the bare schema of one payload
+// so validators can load it by url.
+func getSchemas(w http.ResponseWriter, r *http.Request) {
+	schemas := model.Schemas()
+
+	name, ok := mux.Vars(r)["name"]
+	if !ok {
+		o := model.SchemasResponse{
+			Status: http.StatusOK,
+			Data:   schemas,
+		}
+		res, err := json.Marshal(o)
+		if err != nil {
+			returnHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(res)
+		return
+	}
+
+	s, ok := schemas[name]
+	if !ok {
+		returnHTTPError(w, http.StatusNotFound, errors.Errorf("no schema of %s", name))
+		return
+	}
+	res, err := json.Marshal(s)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(res)
+}
+
+// Only the added hosts are checked against the host reputation, the kept hosts were checked before.
+func patchDomainHosts(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	p, err := model.ParseHostsPatch(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := p.Validate(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	added := &model.DomainOptions{Fqdn: fqdn, Hosts: p.Add}
+	if err := checkQueryTypes(fqdn, added.Records()); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	msg, err := checkReputation(fqdn, fqdn, added)
+	if err != nil {
+		returnHTTPError(w, http.StatusForbidden, err)
+		return
+	}
+
+	b := backend.GetBackend()
+	before := webhook.Snapshot(b.Get, &model.DomainOptions{Fqdn: fqdn})
+	d, err := b.PatchHosts(fqdn, p.Add, p.Remove)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.PublishChange(model.EventDomainUpdated, fqdn, d, before, &d)
+
+	returnSuccess(w, d, msg)
+}
+
+func deleteDomain(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	opts := &model.DomainOptions{Fqdn: fqdn}
+	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
+		opts.Normal = true
+	}
+
+	b := backend.GetBackend()
+	before := webhook.Snapshot(b.Get, &model.DomainOptions{Fqdn: fqdn})
+	err := b.Delete(opts)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.PublishChange(model.EventDomainDeleted, fqdn, nil, before, nil)
+	webhook.Forget(fqdn)
+	purgeSchedule(b, fqdn, time.Time{})
+
+	returnSuccessNoData(w)
+}
+
+func createDomainCNAME(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+
+	opts, err := model.ParseDomainOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
+		opts.Normal = true
+	}
+	if err := checkRoot(opts); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := checkCreateKey(r, opts); err != nil {
+		returnHTTPError(w, http.StatusForbidden, err)
+		return
+	}
+	clampLease(newDomainFqdn(&model.DomainOptions{Root: opts.Root}), opts)
+	if err := checkDeadline(opts); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := checkQueryTypes(newDomainFqdn(&model.DomainOptions{Root: opts.Root}), opts.Records()); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b := backend.GetBackend()
+	if err := backend.CheckCNAME(b, "", opts.CNAME); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	d, err := b.SetCNAME(opts)
+	if err != nil {
+		returnHTTPError(w, writeStatus(err), err)
+		return
+	}
+	if d, err = setDeadline(d, opts, b.DeleteCNAME); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.PublishChange(model.EventCNAMESet, d.Fqdn, d, nil, &d)
+
+	returnSuccessWithToken(w, d, "")
+}
+
+func getDomainCNAME(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+	msg := ""
+
+	opts := &model.DomainOptions{Fqdn: fqdn}
+	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
+		opts.Normal = true
+	}
+
+	b := backend.GetReader()
+	d, err := b.GetCNAME(opts)
+	if err != nil {
+		msg = err.Error()
+	}
+	returnSuccess(w, d, msg)
+}
+
+func updateDomainCNAME(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	opts, err := model.ParseDomainOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
+		opts.Normal = true
+	}
+	opts.Fqdn = fqdn
+
+	b := backend.GetBackend()
+	if err := backend.CheckCNAME(b, fqdn, opts.CNAME); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := checkQueryTypes(fqdn, opts.Records()); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	before := webhook.Snapshot(b.GetCNAME, &model.DomainOptions{Fqdn: fqdn})
+	d, err := b.UpdateCNAME(opts)
+	if err != nil {
+		returnHTTPError(w, writeStatus(err), err)
+		return
+	}
+	webhook.PublishChange(model.EventCNAMESet, fqdn, d, before, &d)
+
+	returnSuccess(w, d, "")
+}
+
+func deleteDomainCNAME(w http.ResponseWriter, r *http.Request) {
+	vals := r.URL.Query()
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	opts := &model.DomainOptions{Fqdn: fqdn}
+	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
+		opts.Normal = true
+	}
+
+	b := backend.GetBackend()
+	before := webhook.Snapshot(b.GetCNAME, &model.DomainOptions{Fqdn: fqdn})
+	err := b.DeleteCNAME(opts)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.PublishChange(model.EventCNAMEDeleted, fqdn, nil, before, nil)
+
+	returnSuccessNoData(w)
+}
+
+func createDomainText(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+	opts, err := model.ParseDomainOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	opts.Fqdn = fqdn
+	if err := parseTextOrder(r, opts); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := checkQueryTypes(fqdn, opts.Records()); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	// a value without an order is kept by its content, so the values of concurrent ACME challenges
+	// of one fqdn are all answered
+	if opts.Order == "" {
+		opts.Order = model.TextOrder(opts.Text)
+	}
+
+	b := backend.GetBackend()
+	d, err := b.SetText(opts)
+	if err != nil {
+		returnHTTPError(w, writeStatus(err), err)
+		return
+	}
+	webhook.PublishChange(model.EventTextSet, fqdn, d, nil, &d)
+
+	returnSuccess(w, d, "")
+}
+
+func getDomainText(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+	msg := ""
+
+	opts := &model.DomainOptions{Fqdn: fqdn}
+	if err := parseTextOrder(r, opts); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	b := backend.GetReader()
+	d, err := b.GetText(opts)
+	if err != nil {
+		msg = err.Error()
+	}
+	returnSuccess(w, d, msg)
+}
+
+func updateDomainText(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	opts, err := model.ParseDomainOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	opts.Fqdn = fqdn
+	if err := parseTextOrder(r, opts); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := checkQueryTypes(fqdn, opts.Records()); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	b := backend.GetBackend()
+	before := webhook.Snapshot(b.GetText, &model.DomainOptions{Fqdn: fqdn, Order: opts.Order})
+	// a value without an order replaces all values of the fqdn, it is kept by its content like a
+	// new value so no value is written to the record itself
+	if opts.Order == "" {
+		opts.Order = model.TextOrder(opts.Text)
+		opts.Replace = true
+	}
+	d, err := b.UpdateText(opts)
+	if err != nil {
+		returnHTTPError(w, writeStatus(err), err)
+		return
+	}
+	webhook.PublishChange(model.EventTextSet, fqdn, d, before, &d)
+
+	returnSuccess(w, d, "")
+}
+
+func deleteDomainText(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	opts := &model.DomainOptions{Fqdn: fqdn}
+	if err := parseTextOrder(r, opts); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	// only the value is deleted, without an order or a value all values are
+	if t := r.URL.Query().Get("text"); t != "" && opts.Order == "" {
+		opts.Order = model.TextOrder(t)
+	}
+	b := backend.GetBackend()
+	before := webhook.Snapshot(b.GetText, opts)
+	err := b.DeleteText(opts)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.PublishChange(model.EventTextDeleted, fqdn, model.Domain{Fqdn: fqdn, Order: opts.Order}, before, nil)
+
+	returnSuccessNoData(w)
+}
+
+func listDomains(w http.ResponseWriter, r *http.Request) {
+	opts, err := model.ParseSearchOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := checkRoot(&model.DomainOptions{Root: opts.Root}); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b := backend.GetReader()
+	var l model.DomainList
+	if opts.IsEmpty() {
+		l, err = b.List(&opts.ListOptions)
+	} else {
+		l, err = b.Search(opts)
+	}
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessWithList(w, l)
+}
+
+func getHostDomains(w http.ResponseWriter, r *http.Request) {
+	host := mux.Vars(r)["host"]
+	if net.ParseIP(host) == nil {
+		returnHTTPError(w, http.StatusBadRequest, errors.Errorf("invalid host ip: %s", host))
+		return
+	}
+
+	b := backend.GetReader()
+	domains, err := b.HostDomains(host)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessWithHost(w, host, domains)
+}
+
+func replaceHost(w http.ResponseWriter, r *http.Request) {
+	host := mux.Vars(r)["host"]
+	opts, err := model.ParseHostReplaceOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	for _, ip := range []string{host, opts.To} {
+		if net.ParseIP(ip) == nil {
+			returnHTTPError(w, http.StatusBadRequest, errors.Errorf("invalid host ip: %s", ip))
+			return
+		}
+	}
+
+	result, err := backend.ReplaceHost(backend.GetBackend(), host, opts.To)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessWithReplace(w, result)
+}
+
+// removeNode is called when a node is removed from its cluster, e.g. by a Rancher node driver hook.
+func removeNode(w http.ResponseWriter, r *http.Request) {
+	opts, err := model.ParseNodeRemovalOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if opts.Node == "" || len(opts.Addresses) == 0 {
+		returnHTTPError(w, http.StatusBadRequest, errors.New("node and addresses are required"))
+		return
+	}
+	for _, ip := range opts.Addresses {
+		if net.ParseIP(ip) == nil {
+			returnHTTPError(w, http.StatusBadRequest, errors.Errorf("invalid host ip: %s", ip))
+			return
+		}
+	}
+
+	result, err := nodes.Remove(opts.Node, opts.Addresses)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessWithNodeRemoval(w, result)
+}
+
+func listSuspensions(w http.ResponseWriter, r *http.Request) {
+	ss, err := backend.GetBackend().ListSuspensions()
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessWithSuspensions(w, ss)
+}
+
+func suspendDomain(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := model.ParseSuspensionOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s := model.Suspension{
+		Fqdn:   fqdn,
+		Reason: opts.Reason,
+	}
+	if err := backend.GetBackend().Suspend(&s); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.Publish(model.EventDomainSuspended, fqdn, s)
+
+	returnSuccessWithSuspension(w, s)
+}
+
+func unsuspendDomain(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := backend.GetBackend().Unsuspend(fqdn); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.Publish(model.EventDomainUnsuspended, fqdn, nil)
+
+	returnSuccessNoData(w)
+}
+
+// The stored value of any domain for the abuse handlers, a CNAME domain is shown with its target.
+func inspectDomain(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b := backend.GetReader()
+	opts := &model.DomainOptions{Fqdn: fqdn}
+	d, err := b.Get(opts)
+	if err != nil {
+		if d, err = b.GetCNAME(opts); err != nil {
+			returnHTTPError(w, http.StatusNotFound, errors.Errorf("domain %s is not found", fqdn))
+			return
+		}
+	}
+
+	origin, err := b.GetToken(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	i := model.DomainInspection{
+		Domain:    d,
+		TokenHash: hashAPIKeySecret(origin),
+	}
+	if _, err := b.GetScopedToken(fqdn, model.TokenScopeReadOnly); err == nil {
+		i.ReadOnlyToken = true
+	}
+	if ss, err := b.ListSuspensions(); err == nil {
+		for k := range ss {
+			if ss[k].Fqdn == fqdn {
+				i.Suspension = &ss[k]
+			}
+		}
+	}
+	if cs, err := b.GetCAA(fqdn); err == nil {
+		i.CAA = cs
+	}
+	if types, err := b.GetQueryTypes(fqdn); err == nil {
+		i.QueryTypes = types
+	}
+	if ws, err := b.ListWebhooks(fqdn); err == nil {
+		for k := range ws {
+			ws[k].Secret = ""
+		}
+		i.Webhooks = ws
+	}
+
+	returnSuccessWithInspection(w, i)
+}
+
+// Deletes any domain without its token, the records of a CNAME domain are deleted with DeleteCNAME.
+func forceDeleteDomain(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b := backend.GetBackend()
+	opts := &model.DomainOptions{Fqdn: fqdn}
+	if before, err := b.Get(opts); err == nil {
+		if err := b.Delete(opts); err != nil {
+			returnHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		webhook.PublishChange(model.EventDomainDeleted, fqdn, nil, &before, nil)
+	} else if before, err := b.GetCNAME(opts); err == nil {
+		if err := b.DeleteCNAME(opts); err != nil {
+			returnHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+		webhook.PublishChange(model.EventCNAMEDeleted, fqdn, nil, &before, nil)
+	} else {
+		returnHTTPError(w, http.StatusNotFound, errors.Errorf("domain %s is not found", fqdn))
+		return
+	}
+	webhook.Forget(fqdn)
+	purgeSchedule(b, fqdn, time.Time{})
+
+	logrus.Infof("domain %s is deleted by an admin", fqdn)
+	returnSuccessNoData(w)
+}
+
+// expirationSetter is implemented by the backends which can move a domain to another expiration.
+type expirationSetter interface {
+	SetExpiration(fqdn string, until time.Time) (model.Domain, error)
+}
+
+// deadlineSetter is implemented by the backends which keep the deadline of a temporary domain.
+type deadlineSetter interface {
+	SetDeadline(fqdn string, until time.Time) (model.Domain, error)
+}
+
+// Used to check the deadline of a new temporary domain before it is created
+func checkDeadline(opts *model.DomainOptions) error {
+	if opts.Deadline == nil {
+		return nil
+	}
+	b := backend.GetBackend()
+	if _, ok := b.(deadlineSetter); !ok {
+		return errors.Errorf("temporary domains are not supported by the %s backend", b.GetName())
+	}
+	if !opts.Deadline.After(time.Now()) {
+		return errors.Errorf("deadline %s is not in the future", opts.Deadline.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Used to keep the deadline of a new temporary domain, a domain whose deadline can not be kept is
+// deleted again with remove so it never outlives the deadline.
+func setDeadline(d model.Domain, opts *model.DomainOptions, remove func(*model.DomainOptions) error) (model.Domain, error) {
+	if opts.Deadline == nil {
+		return d, nil
+	}
+
+	b := backend.GetBackend()
+	t, err := b.(deadlineSetter).SetDeadline(d.Fqdn, *opts.Deadline)
+	if err != nil {
+		if err := remove(&model.DomainOptions{Fqdn: d.Fqdn}); err != nil {
+			logrus.Errorf("failed to delete temporary domain %s, err: %v", d.Fqdn, err)
+		}
+		return d, err
+	}
+
+	// the token and the records of the created domain are not answered again
+	d.Expiration = t.Expiration
+	d.Deadline = t.Deadline
+	return d, nil
+}
+
+// Extends or shortens the expiration of any domain, the lease range of the domain does not apply.
+func setDomainExpiration(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b := backend.GetBackend()
+	e, ok := b.(expirationSetter)
+	if !ok {
+		returnHTTPError(w, http.StatusBadRequest, errors.Errorf("setting expirations is not supported by the %s backend", b.GetName()))
+		return
+	}
+
+	opts, err := model.ParseExpirationOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	until, err := opts.Until(time.Now())
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	d, err := e.SetExpiration(fqdn, until)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// the changes which are due after a shortened expiration are never made
+	purgeSchedule(b, fqdn, until)
+
+	returnSuccess(w, d, "")
+}
+
+// The hashes of the secrets are not returned.
+func listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ks, err := backend.GetBackend().ListAPIKeys()
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	for i := range ks {
+		ks[i].Hash = ""
+	}
+	returnSuccessWithAPIKeys(w, ks)
+}
+
+// The key is only returned once, it can not be recovered from the backend.
+func createAPIKey(w http.ResponseWriter, r *http.Request) {
+	opts, err := model.ParseAPIKeyOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	k, key := newAPIKey(opts)
+	if err := backend.GetBackend().SetAPIKey(&k); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	logrus.Infof("created api key %s (%s) of tenant %q and root domain %q", k.ID, k.Name, k.Tenant, k.RootDomain)
+
+	k.Hash, k.Key = "", key
+	returnSuccessWithAPIKey(w, k)
+}
+
+func revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := backend.GetBackend().GetAPIKey(id); err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := backend.GetBackend().DeleteAPIKey(id); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	logrus.Infof("revoked api key %s", id)
+
+	returnSuccessNoData(w)
+}
+
+func getRPZ(w http.ResponseWriter, r *http.Request) {
+	zone, err := rpz.Zone(backend.GetBackend(), uint32(time.Now().Unix()))
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/dns")
+	w.Write(zone)
+}
+
+func getCoreFileDrift(w http.ResponseWriter, r *http.Request) {
+	if !coredns.Running() {
+		returnHTTPError(w, http.StatusNotFound, errors.New("coredns is not running"))
+		return
+	}
+
+	d, err := coredns.Drift()
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessWithCoreFileDrift(w, d)
+}
+
+// The settings which apply to the fqdn of the query, the root domain of the backend by default
+func getConfig(w http.ResponseWriter, r *http.Request) {
+	fqdn := r.URL.Query().Get("fqdn")
+	if fqdn == "" {
+		fqdn = strings.Trim(backend.GetBackend().GetZone(), ".")
+	}
+
+	c := config.Resolve(fqdn)
+	path, file := config.File()
+
+	returnSuccessWithConfig(w, model.Config{
+		Fqdn:     fqdn,
+		Root:     c.Root,
+		Zone:     c.Zone,
+		Settings: c.Settings(),
+		File:     path,
+		Config:   file,
+	})
+}
+
+func getDomainCAA(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	records, err := backend.GetReader().GetCAA(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	returnSuccessWithCAA(w, records)
+}
+
+// The CAA records of the domain are replaced, they also restrict the CAs of its sub domains.
+func setDomainCAA(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := model.ParseCAAOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := checkQueryTypes(fqdn, model.DomainRecords{CAA: opts.Records}); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := backend.GetBackend().SetCAA(fqdn, opts.Records); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessWithCAA(w, opts.Records)
+}
+
+func deleteDomainCAA(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := backend.GetBackend().DeleteCAA(fqdn); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessNoData(w)
+}
+
+func getDomainHealth(w http.ResponseWriter, r *http.Request) {
+	if !health.Enabled() {
+		returnHTTPError(w, http.StatusNotFound, errors.New("health check is not enabled"))
+		return
+	}
+
+	fqdn := mux.Vars(r)["fqdn"]
+
+	d, err := backend.GetBackend().Get(&model.DomainOptions{Fqdn: fqdn})
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessWithHealth(w, health.Domain(d))
+}
+
+func setDomainTTL(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := model.ParseTTLOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := checkTTL(fqdn, opts.TTL); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b := backend.GetBackend()
+	before := webhook.Snapshot(b.Get, &model.DomainOptions{Fqdn: fqdn})
+	d, err := b.SetTTL(fqdn, opts.TTL)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.PublishChange(model.EventDomainUpdated, fqdn, d, before, &d)
+
+	returnSuccess(w, d, "")
+}
 
-	opts, err := model.ParseDomainOptions(r)
+// The host is left out of the answers of the domain and its sub domains until the timeout,
+// it is answered again without another request.
+func drainHost(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
-		opts.Normal = true
+	host := mux.Vars(r)["ip"]
+	if net.ParseIP(host) == nil {
+		returnHTTPError(w, http.StatusBadRequest, errors.Errorf("invalid host: %s", host))
+		return
 	}
 
-	b := backend.GetBackend()
-	d, err := b.Set(opts)
+	opts, err := model.ParseDrainOptions(r)
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
 		return
 	}
-	returnSuccessWithToken(w, d, "")
-}
-
-func getDomain(w http.ResponseWriter, r *http.Request) {
-	vals := r.URL.Query()
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
-	msg := ""
 
-	opts := &model.DomainOptions{Fqdn: fqdn}
-	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
-		opts.Normal = true
+	timeout := time.Duration(opts.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+	}
+	if timeout > maxDrainTimeout {
+		returnHTTPError(w, http.StatusBadRequest, errors.Errorf("drain timeout %s is longer than %s", timeout, maxDrainTimeout))
+		return
 	}
 
 	b := backend.GetBackend()
-	d, err := b.Get(opts)
+	before := webhook.Snapshot(b.Get, &model.DomainOptions{Fqdn: fqdn})
+	d, err := b.DrainHost(fqdn, host, time.Now().Add(timeout))
 	if err != nil {
-		msg = err.Error()
+		returnHTTPError(w, writeStatus(err), err)
+		return
 	}
-	returnSuccess(w, d, msg)
-}
+	webhook.PublishChange(model.EventDomainUpdated, fqdn, d, before, &d)
 
-func renewDomain(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
+	returnSuccess(w, d, "")
+}
 
-	opts := &model.DomainOptions{Fqdn: fqdn}
+func undrainHost(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
 
 	b := backend.GetBackend()
-	d, err := b.Renew(opts)
+	before := webhook.Snapshot(b.Get, &model.DomainOptions{Fqdn: fqdn})
+	d, err := b.DrainHost(fqdn, mux.Vars(r)["ip"], time.Time{})
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, writeStatus(err), err)
 		return
 	}
+	webhook.PublishChange(model.EventDomainUpdated, fqdn, d, before, &d)
 
 	returnSuccess(w, d, "")
 }
 
-func updateDomain(w http.ResponseWriter, r *http.Request) {
-	vals := r.URL.Query()
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
-
-	opts, err := model.ParseDomainOptions(r)
+func listDomainWebhooks(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
 		return
 	}
-	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
-		opts.Normal = true
-	}
-	opts.Fqdn = fqdn
 
-	b := backend.GetBackend()
-	d, err := b.Update(opts)
+	hs, err := backend.GetBackend().ListWebhooks(fqdn)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	returnSuccess(w, d, "")
+	returnSuccessWithWebhooks(w, hs)
 }
 
-func deleteDomain(w http.ResponseWriter, r *http.Request) {
-	vals := r.URL.Query()
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
+func createDomainWebhook(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
 
-	opts := &model.DomainOptions{Fqdn: fqdn}
-	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
-		opts.Normal = true
+	opts, err := model.ParseWebhookOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
 	}
 
-	b := backend.GetBackend()
-	err := b.Delete(opts)
+	h, err := webhook.New(fqdn, opts)
 	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := backend.GetBackend().SetWebhook(h); err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	returnSuccessNoData(w)
+	returnSuccessWithWebhook(w, *h)
 }
 
-func createDomainCNAME(w http.ResponseWriter, r *http.Request) {
-	vals := r.URL.Query()
-
-	opts, err := model.ParseDomainOptions(r)
+func deleteDomainWebhook(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
 	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := backend.GetBackend().DeleteWebhook(fqdn, mux.Vars(r)["id"]); err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
-		opts.Normal = true
+	returnSuccessNoData(w)
+}
+
+func listDomainSchedule(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
 	}
 
-	b := backend.GetBackend()
-	d, err := b.SetCNAME(opts)
+	cs, err := schedule.List(backend.GetBackend(), fqdn)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
-	returnSuccessWithToken(w, d, "")
+
+	returnSuccessWithScheduledChanges(w, cs)
 }
 
-func getDomainCNAME(w http.ResponseWriter, r *http.Request) {
-	vals := r.URL.Query()
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
-	msg := ""
+// The hosts of a change are checked when it is scheduled, the domain is changed with them as they
+// are at the time of the change.
+func scheduleDomainChange(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
 
-	opts := &model.DomainOptions{Fqdn: fqdn}
-	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
-		opts.Normal = true
+	opts, err := model.ParseScheduleOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := opts.Validate(time.Now()); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	hosts := &model.DomainOptions{Fqdn: fqdn, Hosts: opts.Hosts, SubDomain: opts.SubDomain}
+	if err := checkQueryTypes(fqdn, hosts.Records()); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := checkReputation(fqdn, fqdn, hosts); err != nil {
+		returnHTTPError(w, http.StatusForbidden, err)
+		return
 	}
 
+	// a change is made before the domain expires, so it is never made to a domain which is
+	// registered with its name again
 	b := backend.GetBackend()
-	d, err := b.GetCNAME(opts)
+	d, err := b.Get(&model.DomainOptions{Fqdn: fqdn})
 	if err != nil {
-		msg = err.Error()
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
 	}
-	returnSuccess(w, d, msg)
+	if d.Expiration != nil && opts.At.After(*d.Expiration) {
+		returnHTTPError(w, http.StatusBadRequest, errors.Errorf("the change is due after the domain expires at %s", d.Expiration.Format(time.RFC3339)))
+		return
+	}
+
+	c, err := schedule.Add(b, fqdn, opts, clientIP(r), model.ParseHostProofs(r.Header.Get(model.HeaderHostProof)))
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	returnSuccessWithScheduledChange(w, c)
 }
 
-func updateDomainCNAME(w http.ResponseWriter, r *http.Request) {
-	vals := r.URL.Query()
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
+// Used to remove the scheduled changes of a domain which are due after a time, the domain is
+// changed already so a failure is only logged
+func purgeSchedule(b backend.Backend, fqdn string, after time.Time) {
+	if err := schedule.Purge(b, fqdn, after); err != nil {
+		logrus.Errorf("failed to purge the scheduled changes of %s, err: %v", fqdn, err)
+	}
+}
 
-	opts, err := model.ParseDomainOptions(r)
+// Used to check a scheduled change again before it is made, like the api checks a change of the
+// hosts of a request: the validation of its hosts, their reputation and the change limit.
+func scheduleCheck(v *validator, l *changeLimiter) func(c model.ScheduledChange) error {
+	return func(c model.ScheduledChange) error {
+		if err := v.checkChange(c); err != nil {
+			return err
+		}
+		if c.Action == model.ScheduleHosts {
+			if _, err := checkReputation(c.Fqdn, c.Fqdn, &model.DomainOptions{Fqdn: c.Fqdn, Hosts: c.Hosts, SubDomain: c.SubDomain}); err != nil {
+				return err
+			}
+		}
+		if l != nil {
+			return l.checkChange(c)
+		}
+		return nil
+	}
+}
+
+func cancelDomainChange(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
 		return
 	}
-	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
-		opts.Normal = true
+
+	err = schedule.Cancel(backend.GetBackend(), fqdn, mux.Vars(r)["id"])
+	if errors.Cause(err) == schedule.ErrNoChange {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
 	}
-	opts.Fqdn = fqdn
 
-	b := backend.GetBackend()
-	d, err := b.UpdateCNAME(opts)
+	returnSuccessNoData(w)
+}
+
+func listSchedule(w http.ResponseWriter, r *http.Request) {
+	cs, err := schedule.List(backend.GetBackend(), "")
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	returnSuccess(w, d, "")
+	returnSuccessWithScheduledChanges(w, cs)
 }
 
-func deleteDomainCNAME(w http.ResponseWriter, r *http.Request) {
-	vals := r.URL.Query()
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
+func getBackendState(w http.ResponseWriter, r *http.Request) {
+	d, ok := backend.GetBackend().(*dual.Backend)
+	if !ok {
+		returnHTTPError(w, http.StatusNotFound, errors.New("double-write mode is not enabled"))
+		return
+	}
 
-	opts := &model.DomainOptions{Fqdn: fqdn}
-	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
-		opts.Normal = true
+	returnSuccessWithState(w, d)
+}
+
+func setBackendState(w http.ResponseWriter, r *http.Request) {
+	d, ok := backend.GetBackend().(*dual.Backend)
+	if !ok {
+		returnHTTPError(w, http.StatusNotFound, errors.New("double-write mode is not enabled"))
+		return
 	}
 
-	b := backend.GetBackend()
-	err := b.DeleteCNAME(opts)
+	opts, err := model.ParseBackendState(r)
 	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	previous := d.State()
+	if err := d.SetState(opts.State); err != nil {
+		returnHTTPError(w, http.StatusConflict, err)
+		return
+	}
+
+	// the other replicas and the restarted ones switch to the persisted state
+	if err := state.SetBackendState(d, opts.State); err != nil {
+		d.Restore(previous)
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	returnSuccessNoData(w)
+	returnSuccessWithState(w, d)
 }
 
-func createDomainText(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
-	opts, err := model.ParseDomainOptions(r)
+func listOperationalStates(w http.ResponseWriter, r *http.Request) {
+	ss, err := backend.GetBackend().ListOperationalStates()
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
-	opts.Fqdn = fqdn
 
-	b := backend.GetBackend()
-	d, err := b.SetText(opts)
+	returnSuccessWithOperationalStates(w, ss)
+}
+
+func getMaintenance(w http.ResponseWriter, r *http.Request) {
+	m, err := state.GetMaintenance(backend.GetBackend())
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	returnSuccess(w, d, "")
+	returnSuccessWithMaintenance(w, m)
 }
 
-func getDomainText(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
-	msg := ""
-
-	opts := &model.DomainOptions{Fqdn: fqdn}
-	b := backend.GetBackend()
-	d, err := b.GetText(opts)
+func setMaintenance(w http.ResponseWriter, r *http.Request) {
+	opts, err := model.ParseMaintenance(r)
 	if err != nil {
-		msg = err.Error()
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
 	}
-	returnSuccess(w, d, msg)
-}
-
-func updateDomainText(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
 
-	opts, err := model.ParseDomainOptions(r)
+	m, err := state.SetMaintenance(backend.GetBackend(), *opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
-	opts.Fqdn = fqdn
-	b := backend.GetBackend()
-	d, err := b.UpdateText(opts)
+
+	logrus.Infof("switched maintenance mode: enabled=%t", m.Enabled)
+	returnSuccessWithMaintenance(w, m)
+}
+
+func listFeatures(w http.ResponseWriter, r *http.Request) {
+	features, err := state.GetFeatures(backend.GetBackend())
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	returnSuccess(w, d, "")
+	returnSuccessWithFeatures(w, features)
 }
 
-func deleteDomainText(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	fqdn := vars["fqdn"]
+func setFeature(w http.ResponseWriter, r *http.Request) {
+	opts, err := model.ParseFeature(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
 
-	opts := &model.DomainOptions{Fqdn: fqdn}
-	b := backend.GetBackend()
-	err := b.DeleteText(opts)
+	name := mux.Vars(r)["name"]
+	features, err := state.SetFeature(backend.GetBackend(), name, opts.Enabled)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	returnSuccessNoData(w)
+	logrus.Infof("switched feature %s: enabled=%t", name, opts.Enabled)
+	returnSuccessWithFeatures(w, features)
 }
 
 func ping(w http.ResponseWriter, r *http.Request) {
 	returnSuccessNoData(w)
 }
 
+// healthz only tells the process serves requests, a backend outage must not restart it.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	returnSuccessNoData(w)
+}
+
+// readyz tells the backend answers, so no requests are sent to an instance which can not serve them.
+// An instance which still warms up is not ready either, e.g. while coredns preloads the records.
+func readyz(w http.ResponseWriter, r *http.Request) {
+	if err := lifecycle.Warm(); err != nil {
+		returnHTTPError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err := backend.GetBackend().Ping(); err != nil {
+		returnHTTPError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	returnSuccessNoData(w)
+}
+
 func migrateRecord(w http.ResponseWriter, r *http.Request) {
 	opts, err := model.ParseMigrateRecord(r)
 	if err != nil {
@@ -388,3 +2114,117 @@ func migrateToken(w http.ResponseWriter, r *http.Request) {
 
 	returnSuccessNoData(w)
 }
+
+// Used to get the domain a request applies to, sub domains are suspended and watched with their domain
+// e.g. x1.qrn7oq.lb.rancher.cloud => qrn7oq.lb.rancher.cloud
+func domainFqdn(r *http.Request) (string, error) {
+	return zoneFqdn(mux.Vars(r)["fqdn"])
+}
+
+func zoneFqdn(fqdn string) (string, error) {
+	zone := strings.Trim(backend.ZoneOf(backend.GetBackend(), fqdn), ".")
+
+	slug := util.SlugWithZone(fqdn, zone)
+	if slug == "" || slug == "*" {
+		return "", errors.Errorf("domain %s is not under zone %s", fqdn, zone)
+	}
+
+	return slug + "." + zone, nil
+}
+
+// Used to check a ttl against the ttl range of the domain, 0 restores the default ttl
+// e.g. 30 => nil
+// e.g. 5 => ttl 5 is not within [30, 3600]
+func checkTTL(fqdn string, ttl uint32) error {
+	if ttl == 0 {
+		return nil
+	}
+
+	c := config.Resolve(fqdn)
+	if ttl < c.TTLMin || ttl > c.TTLMax {
+		return errors.Errorf("ttl %d is not within [%d, %d]", ttl, c.TTLMin, c.TTLMax)
+	}
+	return nil
+}
+
+// Used to clamp the requested lease within the lease range of the domain, 0 keeps the default lease
+// e.g. 60 => 3600
+// e.g. 86400 => 86400
+func clampLease(fqdn string, opts *model.DomainOptions) {
+	if opts.Lease == 0 {
+		return
+	}
+
+	c := config.Resolve(fqdn)
+	lease := time.Duration(opts.Lease) * time.Second
+	if lease < c.LeaseMin {
+		lease = c.LeaseMin
+	}
+	if lease > c.LeaseMax {
+		lease = c.LeaseMax
+	}
+	opts.Lease = int64(lease.Seconds())
+}
+
+// Used to get the name the settings of a new domain are resolved with, its fqdn is not generated yet
+// e.g. {Name: my-cluster} => my-cluster.lb.rancher.cloud
+// e.g. {Root: lb.example2.com} => lb.example2.com
+// e.g. {} => lb.rancher.cloud
+func newDomainFqdn(opts *model.DomainOptions) string {
+	zone := strings.Trim(backend.GetBackend().GetZone(), ".")
+	if opts.Root != "" {
+		zone = opts.Root
+	}
+	if opts.Name == "" {
+		return zone
+	}
+	return opts.Name + "." + zone
+}
+
+// Used to check the requested root domain of a new domain against the root domains the backend serves,
+// empty means the first one
+// e.g. {Root: lb.example2.com.} => {Root: lb.example2.com}
+// e.g. {Root: lb.example3.com} => root domain lb.example3.com is not served, expected one of lb.example1.com, lb.example2.com
+func checkRoot(opts *model.DomainOptions) error {
+	if opts.Root == "" {
+		return nil
+	}
+
+	zones := backend.Zones(backend.GetBackend())
+	root := strings.Trim(strings.ToLower(opts.Root), ".")
+	for _, z := range zones {
+		if strings.Trim(z, ".") == root {
+			opts.Root = root
+			return nil
+		}
+	}
+	return errors.Errorf("root domain %s is not served, expected one of %s", opts.Root, strings.Join(zones, ", "))
+}
+
+// Used to check the hosts of a domain and its sub domains with the reputation providers, a listed host
+// is refused or flagged in the message of the response
+// e.g. 6.6.6.6 => host 6.6.6.6 is listed by the dnsbl reputation provider: listed in zen.spamhaus.org (127.0.0.2)
+func checkReputation(fqdn, owner string, opts *model.DomainOptions) (string, error) {
+	hosts := append([]string{}, opts.Hosts...)
+	for _, hs := range opts.SubDomain {
+		hosts = append(hosts, hs...)
+	}
+
+	l := reputation.Check(fqdn, owner, hosts)
+	if l == nil {
+		return "", nil
+	}
+	if reputation.Rejects(fqdn) {
+		return "", errors.New(l.String())
+	}
+	return l.String(), nil
+}
+
+// Used to get the ACME order of a TXT request, the order query overrides the payload
+// e.g. /v1/domain/_acme-challenge.qrn7oq.lb.rancher.cloud/txt?order=4f1b-9c2a => 4f1b-9c2a
+func parseTextOrder(r *http.Request, opts *model.DomainOptions) error {
+	if o := r.URL.Query().Get("order"); o != "" {
+		opts.Order = o
+	}
+	return model.ValidateOrder(opts.Order)
+}
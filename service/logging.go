@@ -0,0 +1,92 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// requestLog is the response writer of a request with the fields its log lines are scoped with,
+// the error a request is answered with is logged once by the access line of the request.
+type requestLog struct {
+	http.ResponseWriter
+	entry  *logrus.Entry
+	status int
+	err    error
+}
+
+func (l *requestLog) WriteHeader(status int) {
+	l.status = status
+	l.ResponseWriter.WriteHeader(status)
+}
+
+func (l *requestLog) Unwrap() http.ResponseWriter {
+	return l.ResponseWriter
+}
+
+// loggingMiddleware logs a line for every api request with its operation, fqdn, client ip, status and
+// latency. Requests which succeed are logged in debug level unless the logs are formatted as json.
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probePaths[r.URL.Path] || r.URL.Path == "/metrics" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fields := logrus.Fields{
+			"method":    r.Method,
+			"path":      r.URL.Path,
+			"client_ip": clientIP(r),
+		}
+		if route := mux.CurrentRoute(r); route != nil && route.GetName() != "" {
+			fields["operation"] = route.GetName()
+		}
+		if fqdn := mux.Vars(r)["fqdn"]; fqdn != "" {
+			fields["fqdn"] = fqdn
+		}
+
+		start := time.Now()
+		l := &requestLog{ResponseWriter: w, entry: logrus.WithFields(fields), status: http.StatusOK}
+		next.ServeHTTP(l, r)
+
+		e := l.entry.WithFields(logrus.Fields{
+			"status":  l.status,
+			"latency": time.Since(start).Seconds(),
+		})
+		if l.err != nil {
+			e = e.WithError(l.err)
+		}
+		switch {
+		case l.status >= http.StatusInternalServerError:
+			e.Error("api request failed")
+		case l.status >= http.StatusBadRequest:
+			e.Warn("api request refused")
+		case jsonLogs():
+			e.Info("api request")
+		default:
+			e.Debug("api request")
+		}
+	})
+}
+
+// Used to get the log of the request a response writer answers, the writers of other middlewares
+// are unwrapped until it is found
+func requestLogOf(w http.ResponseWriter) *requestLog {
+	for {
+		switch rw := w.(type) {
+		case *requestLog:
+			return rw
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+func jsonLogs() bool {
+	_, ok := logrus.StandardLogger().Formatter.(*logrus.JSONFormatter)
+	return ok
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rancher/rdns-server/state"
+
+	"github.com/pkg/errors"
+)
+
+// maintenanceMiddleware refuses the changes with 503 while the maintenance mode is enabled, the
+// reads and the admin api are still served so the maintenance can be switched off.
+func maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || strings.HasPrefix(r.URL.Path, adminPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		m := state.InMaintenance()
+		if !m.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		message := "changes are refused during maintenance"
+		if m.Message != "" {
+			message += ": " + m.Message
+		}
+		returnHTTPError(w, http.StatusServiceUnavailable, errors.New(message))
+	})
+}
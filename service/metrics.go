@@ -0,0 +1,62 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	authFailureToken = "token"
+	authFailureFqdn  = "fqdn"
+	authFailureAdmin = "admin"
+	// a new domain without a key of the frontdoor
+	authFailureCreate = "create"
+)
+
+var (
+	operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_operations",
+		Help: "The number of api operations by route and result",
+	}, []string{"operation", "result"})
+
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rancher_dns_operation_duration_seconds",
+		Help:    "The latency of api operations by route and backend",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation", "backend"})
+
+	authFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_token_auth_failures",
+		Help: "The number of requests refused by the token check by reason",
+	}, []string{"reason"})
+)
+
+// metricsMiddleware counts the api operations by the name of their route, a failure is
+// answered with 4xx or 5xx. The latency is labeled with the backend which serves the reads.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil || route.GetName() == "" || strings.HasPrefix(r.URL.Path, "/metrics") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		result := "success"
+		if rec.status >= http.StatusBadRequest {
+			result = "failure"
+		}
+		operations.WithLabelValues(route.GetName(), result).Inc()
+		operationDuration.WithLabelValues(route.GetName(), backend.GetBackend().GetName()).Observe(time.Since(start).Seconds())
+	})
+}
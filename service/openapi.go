@@ -0,0 +1,286 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/rancher/rdns-server/admin"
+	"github.com/rancher/rdns-server/model"
+)
+
+const (
+	openAPITitle   = "rdns-server"
+	openAPIVersion = "v1"
+	schemeToken    = "token"
+	schemeAdmin    = "admin"
+)
+
+var (
+	pathParameter = regexp.MustCompile(`\{(\w+)\}`)
+
+	// requestPayloads are the schemas of the bodies of the routes by route name
+	requestPayloads = map[string]string{
+		"createDomain":         "DomainOptions",
+		"updateDomain":         "DomainOptions",
+		"patchDomainHosts":     "HostsPatch",
+		"createDomainCNAME":    "DomainOptions",
+		"updateDomainCNAME":    "DomainOptions",
+		"createDomainText":     "DomainOptions",
+		"updateDomainText":     "DomainOptions",
+		"recoverDomainToken":   "RecoveryOptions",
+		"approveDevice":        "DeviceOptions",
+		"createDeviceCode":     "DeviceOptions",
+		"createDeviceToken":    "DeviceOptions",
+		"setDomainTTL":         "TTLOptions",
+		"drainHost":            "DrainOptions",
+		"setDomainCAA":         "CAAOptions",
+		"setDomainQueryTypes":  "QueryTypesOptions",
+		"createDomainWebhook":  "WebhookOptions",
+		"scheduleDomainChange": "ScheduleOptions",
+		"setDomainStatus":      "DomainStatus",
+		"setDomainExpiration":  "ExpirationOptions",
+		"replaceHost":          "HostReplaceOptions",
+		"removeNode":           "NodeRemovalOptions",
+		"suspendDomain":        "SuspensionOptions",
+		"setBackendState":      "BackendState",
+		"setMaintenance":       "Maintenance",
+		"setFeature":           "Feature",
+		"createAPIKey":         "APIKeyOptions",
+	}
+
+	// domainRoutes answer with a domain and the token field, which only some of them fill
+	domainRoutes = map[string]bool{
+		"getDomain":           true,
+		"createDomain":        true,
+		"updateDomain":        true,
+		"patchDomainHosts":    true,
+		"renewDomain":         true,
+		"rotateDomainToken":   true,
+		"createReadOnlyToken": true,
+		"recoverDomainToken":  true,
+		"createDeviceToken":   true,
+		"createDomainCNAME":   true,
+		"getDomainCNAME":      true,
+		"updateDomainCNAME":   true,
+		"createDomainText":    true,
+		"getDomainText":       true,
+		"updateDomainText":    true,
+		"setDomainTTL":        true,
+		"drainHost":           true,
+		"undrainHost":         true,
+		"setDomainExpiration": true,
+	}
+
+	// responsePayloads are the schemas of the data of the other answers by route name
+	responsePayloads = map[string]*model.JSONSchema{
+		"listDomains":           domainListSchema(),
+		"listRegisteredDomains": domainListSchema(),
+		"getDomainStatus":       model.SchemaRef("DomainStatus"),
+		"setDomainStatus":       model.SchemaRef("DomainStatus"),
+		"getDomainCAA":          arraySchema(model.SchemaRef("CAARecord")),
+		"setDomainCAA":          arraySchema(model.SchemaRef("CAARecord")),
+		"keepaliveDomain":       model.SchemaRef("Keepalive"),
+		"getDomainQueryTypes":   model.SchemaRef("QueryTypesOptions"),
+		"setDomainQueryTypes":   model.SchemaRef("QueryTypesOptions"),
+		"listDomainSchedule":    arraySchema(model.SchemaRef("ScheduledChange")),
+		"scheduleDomainChange":  model.SchemaRef("ScheduledChange"),
+		"listSchedule":          arraySchema(model.SchemaRef("ScheduledChange")),
+		"getBackendState":       model.SchemaRef("BackendState"),
+		"setBackendState":       model.SchemaRef("BackendState"),
+		"getMaintenance":        model.SchemaRef("Maintenance"),
+		"setMaintenance":        model.SchemaRef("Maintenance"),
+	}
+
+	// queryParameters are the query parameters of the routes by route name
+	queryParameters = map[string][]string{
+		"createDomain":          {"normal"},
+		"getDomain":             {"normal"},
+		"updateDomain":          {"normal"},
+		"deleteDomain":          {"normal"},
+		"createDomainCNAME":     {"normal"},
+		"getDomainCNAME":        {"normal"},
+		"updateDomainCNAME":     {"normal"},
+		"deleteDomainCNAME":     {"normal"},
+		"createDomainText":      {"order"},
+		"updateDomainText":      {"order"},
+		"deleteDomainText":      {"order"},
+		"exportDomain":          {"format", "encoding"},
+		"getDevicePage":         {"user_code"},
+		"listDomains":           searchParameters,
+		"listRegisteredDomains": searchParameters,
+		"exportDomains":         {"root"},
+		"getConfig":             {"fqdn"},
+	}
+
+	searchParameters = []string{"limit", "continue", "root", "host", "label", "creatorIP", "text~", "expiringBefore", "expiringAfter"}
+
+	// mediaTypes are the content types of the routes which do not answer json
+	mediaTypes = map[string]string{
+		"exportDomain":  "*/*",
+		"getDevicePage": "text/html",
+		"getSchema":     "application/schema+json",
+		"getRPZ":        "text/dns",
+		"exportDomains": "application/x-ndjson",
+	}
+)
+
+// GET /v1/openapi.json describes every route of the api, so clients can be generated from it and
+// tested against the running server.
+func openAPIHandler(doc *model.OpenAPI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := json.Marshal(doc)
+		if err != nil {
+			returnHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(res)
+	}
+}
+
+// The operations are generated from the routes, the security of an operation is the check which
+// tokenMiddleware runs for its path.
+func newOpenAPI(rs Routes) *model.OpenAPI {
+	doc := model.NewOpenAPI(openAPITitle, openAPIVersion)
+	doc.Components.SecuritySchemes[schemeToken] = model.OpenAPISecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "The token of the domain or an api key which manages it",
+	}
+	doc.Components.SecuritySchemes[schemeAdmin] = model.OpenAPISecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "ADMIN_TOKEN or the credentials of ADMIN_ROLES_FILE",
+	}
+
+	for _, route := range rs {
+		op := &model.OpenAPIOperation{
+			OperationID: route.Name,
+			Tags:        []string{routeTag(route.Pattern)},
+			Parameters:  parametersOf(route),
+			Responses:   responsesOf(route.Name),
+		}
+		if name, ok := requestPayloads[route.Name]; ok {
+			op.RequestBody = &model.OpenAPIRequestBody{
+				Required: true,
+				Content:  map[string]model.OpenAPIMediaType{"application/json": {Schema: model.SchemaRef(name)}},
+			}
+		}
+
+		switch {
+		case isAdminPath(route.Pattern):
+			role, ok := adminRoles[route.Name]
+			if !ok {
+				role = admin.RoleOperator
+			}
+			op.Security = []map[string][]string{{schemeAdmin: {}}}
+			op.Description = "Needs the " + role + " role or a higher one."
+		case tokenRequired(route.Method, route.Pattern):
+			op.Security = []map[string][]string{{schemeToken: {}}}
+			if readOnlyRoutes[route.Name] {
+				op.Description = "Also accepts the read-only token of the domain."
+			}
+		}
+
+		if doc.Paths[route.Pattern] == nil {
+			doc.Paths[route.Pattern] = make(map[string]*model.OpenAPIOperation)
+		}
+		doc.Paths[route.Pattern][strings.ToLower(route.Method)] = op
+	}
+
+	return doc
+}
+
+// Used to group the operations by the resource of their path
+// e.g. /v1/domain/{fqdn}/txt => txt, /v1/domain/{fqdn} => domain, /healthz => probes
+func routeTag(pattern string) string {
+	parts := strings.Split(strings.Trim(pattern, "/"), "/")
+	switch {
+	case parts[0] != "v1":
+		return "probes"
+	case parts[1] == "domain" && len(parts) > 3:
+		return parts[3]
+	case parts[1] == "domain" && len(parts) == 3 && !pathParameter.MatchString(parts[2]):
+		return parts[2]
+	}
+	return parts[1]
+}
+
+func parametersOf(route Route) []model.OpenAPIParameter {
+	params := make([]model.OpenAPIParameter, 0)
+	for _, m := range pathParameter.FindAllStringSubmatch(route.Pattern, -1) {
+		params = append(params, model.OpenAPIParameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   &model.JSONSchema{Type: "string"},
+		})
+	}
+	for _, name := range queryParameters[route.Name] {
+		params = append(params, model.OpenAPIParameter{
+			Name:   name,
+			In:     "query",
+			Schema: &model.JSONSchema{Type: "string"},
+		})
+	}
+	return params
+}
+
+// Answers are described by the envelope they are marshaled with, errors by the one of
+// returnHTTPError.
+func responsesOf(name string) map[string]*model.OpenAPIResponse {
+	ok := &model.OpenAPIResponse{Description: "OK"}
+	if mediaType, found := mediaTypes[name]; found {
+		ok.Content = map[string]model.OpenAPIMediaType{mediaType: {Schema: &model.JSONSchema{}}}
+	} else {
+		data := responsePayloads[name]
+		if domainRoutes[name] {
+			data = model.SchemaRef("Domain")
+		}
+		ok.Content = map[string]model.OpenAPIMediaType{"application/json": {Schema: envelopeSchema(data, domainRoutes[name])}}
+	}
+
+	return map[string]*model.OpenAPIResponse{
+		"200": ok,
+		"default": {
+			Description: "The error of the request",
+			Content:     map[string]model.OpenAPIMediaType{"application/json": {Schema: envelopeSchema(nil, false)}},
+		},
+	}
+}
+
+func envelopeSchema(data *model.JSONSchema, token bool) *model.JSONSchema {
+	if data == nil {
+		data = &model.JSONSchema{Type: "object"}
+	}
+	s := &model.JSONSchema{
+		Type: "object",
+		Properties: map[string]*model.JSONSchema{
+			"status": {Type: "integer"},
+			"msg":    {Type: "string"},
+			"data":   data,
+		},
+	}
+	if token {
+		s.Properties["token"] = &model.JSONSchema{Type: "string"}
+	}
+	return s
+}
+
+func arraySchema(items *model.JSONSchema) *model.JSONSchema {
+	return &model.JSONSchema{Type: "array", Items: items}
+}
+
+func domainListSchema() *model.JSONSchema {
+	return &model.JSONSchema{
+		Type: "object",
+		Properties: map[string]*model.JSONSchema{
+			"items":    arraySchema(model.SchemaRef("Domain")),
+			"continue": {Type: "string"},
+			"revision": {Type: "integer"},
+		},
+	}
+}
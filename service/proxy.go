@@ -0,0 +1,79 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// proxies are the addresses of TRUSTED_PROXIES, only their X-Forwarded-For headers are read
+var proxies []*net.IPNet
+
+// Used to get the networks of TRUSTED_PROXIES, addresses without a prefix length are single hosts
+// e.g. 10.0.0.0/8,192.168.1.10 => [10.0.0.0/8 192.168.1.10/32]
+func newTrustedProxies() []*net.IPNet {
+	nets := make([]*net.IPNet, 0)
+	for _, s := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			logrus.Errorf("invalid trusted proxy %s, it is ignored, err: %v", s, err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func trusted(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range proxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Used to get the ip of the client, the X-Forwarded-For header is only read when the request comes
+// from a trusted proxy. Its entries are read from the last one, the first entry which is not a
+// trusted proxy is the client, so the entries a client sends itself are never used.
+// e.g. RemoteAddr: 1.2.3.4:52144 => 1.2.3.4
+// e.g. RemoteAddr: 1.2.3.4:52144, X-Forwarded-For: 8.8.8.8 => 1.2.3.4
+// e.g. RemoteAddr: 10.0.0.1:52144, X-Forwarded-For: 8.8.8.8, 1.2.3.4 => 1.2.3.4 (10.0.0.1 is trusted)
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trusted(host) {
+		return host
+	}
+
+	entries := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(entries) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(entries[i])
+		if ip == "" {
+			continue
+		}
+		if !trusted(ip) || i == 0 {
+			return ip
+		}
+	}
+	return host
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/config"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+func returnSuccessWithQueryTypes(w http.ResponseWriter, types []string) {
+	if types == nil {
+		types = make([]string, 0)
+	}
+	o := model.QueryTypesResponse{
+		Status: http.StatusOK,
+		Data:   model.QueryTypesOptions{Types: types},
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// No types are answered when the domain is not restricted.
+func getDomainQueryTypes(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	types, err := backend.GetReader().GetQueryTypes(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessWithQueryTypes(w, types)
+}
+
+// The domain and the names below it only answer the types from then on, a restriction which
+// leaves out the types of the records the domain has is refused.
+func setDomainQueryTypes(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	opts, err := model.ParseQueryTypesOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	types, err := model.NormalizeQueryTypes(opts.Types)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(types) == 0 {
+		returnHTTPError(w, http.StatusBadRequest, errors.New("expected at least one query type"))
+		return
+	}
+
+	allowed := config.Resolve(fqdn).QueryTypes
+	for _, t := range types {
+		if !model.AllowsQueryType(allowed, t) {
+			returnHTTPError(w, http.StatusBadRequest, errors.Errorf("domains of %s only answer %s records", fqdn, strings.Join(allowed, ", ")))
+			return
+		}
+	}
+
+	b := backend.GetBackend()
+	for _, t := range domainTypes(b, fqdn) {
+		if !model.AllowsQueryType(types, t) {
+			returnHTTPError(w, http.StatusConflict, errors.Errorf("domain %s has %s records, delete them before the domain is restricted", fqdn, t))
+			return
+		}
+	}
+
+	if err := b.SetQueryTypes(fqdn, types); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessWithQueryTypes(w, types)
+}
+
+func deleteDomainQueryTypes(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := backend.GetBackend().SetQueryTypes(fqdn, nil); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessNoData(w)
+}
+
+// Used to get the types of the records a domain has, the TXT records are not listed by domain so
+// they are left out
+// e.g. {hosts: [1.1.1.1], subdomain: {x1: [2001:db8::1]}} => [A, AAAA]
+func domainTypes(b backend.Backend, fqdn string) []string {
+	var records model.DomainRecords
+	if d, err := b.Get(&model.DomainOptions{Fqdn: fqdn}); err == nil {
+		opts := &model.DomainOptions{Fqdn: fqdn, Hosts: d.Hosts, SubDomain: d.SubDomain}
+		records = opts.Records()
+	}
+	if d, err := b.GetCNAME(&model.DomainOptions{Fqdn: fqdn}); err == nil && d.CNAME != "" {
+		records.CNAME = &model.CNAMERecord{Name: fqdn, Target: d.CNAME}
+	}
+	if cs, err := b.GetCAA(fqdn); err == nil {
+		records.CAA = cs
+	}
+	return records.Types()
+}
+
+// Used to check that a write only adds records of the types the domain answers, the restriction of
+// the domain and the query types of the settings of its name both apply. Records of the names below
+// a domain are checked with the restriction of the domain.
+// e.g. sample.lb.rancher.cloud restricted to [TXT], {hosts: [1.1.1.1]} => domain ... only answers TXT records
+func checkQueryTypes(fqdn string, records model.DomainRecords) error {
+	types := records.Types()
+	if len(types) == 0 {
+		return nil
+	}
+
+	allowed := config.Resolve(fqdn).QueryTypes
+	for _, t := range types {
+		if !model.AllowsQueryType(allowed, t) {
+			return errors.Errorf("domains of %s only answer %s records", fqdn, strings.Join(allowed, ", "))
+		}
+	}
+
+	domain := tokenFqdn(fqdn)
+	restricted, err := backend.GetBackend().GetQueryTypes(domain)
+	if err != nil {
+		return err
+	}
+	for _, t := range types {
+		if !model.AllowsQueryType(restricted, t) {
+			return errors.Errorf("domain %s only answers %s records", domain, strings.Join(restricted, ", "))
+		}
+	}
+	return nil
+}
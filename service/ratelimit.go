@@ -0,0 +1,155 @@
+package service
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultChangeBurst = 10
+	// idle domains are forgotten once the map holds maxLimitedDomains, their buckets are full again anyway
+	maxLimitedDomains = 100000
+)
+
+var (
+	// changeRoutes are the routes which change the hosts, their drains or the TXT records of a domain
+	changeRoutes = map[string]bool{
+		"updateDomain":       true,
+		"patchDomainHosts":   true,
+		"createDomainText":   true,
+		"updateDomainText":   true,
+		"deleteDomainText":   true,
+		"setDomainStatus":    true,
+		"deleteDomainStatus": true,
+		"drainHost":          true,
+		"undrainHost":        true,
+		// a scheduled change of the hosts counts when it is scheduled and when it is made
+		"scheduleDomainChange": true,
+	}
+
+	limitedChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_rate_limited_changes",
+		Help: "The number of record changes refused by the rate of change limit by route",
+	}, []string{"operation"})
+)
+
+// changeLimiter keeps a token bucket of every domain, RECORD_CHANGE_LIMIT changes are allowed
+// per hour and RECORD_CHANGE_BURST changes at once.
+type changeLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu      sync.Mutex
+	domains map[string]*domainLimiter
+}
+
+type domainLimiter struct {
+	*rate.Limiter
+	last time.Time
+}
+
+// Used to get the limiter of RECORD_CHANGE_LIMIT and RECORD_CHANGE_BURST, nil means no limit
+func newChangeLimiter() *changeLimiter {
+	v := os.Getenv("RECORD_CHANGE_LIMIT")
+	if v == "" || v == "0" {
+		return nil
+	}
+	perHour, err := strconv.Atoi(v)
+	if err != nil || perHour < 0 {
+		logrus.Errorf("invalid record change limit %s, changes are not limited", v)
+		return nil
+	}
+	burst, err := strconv.Atoi(os.Getenv("RECORD_CHANGE_BURST"))
+	if err != nil || burst <= 0 {
+		logrus.Errorf("invalid record change burst %s, use %d", os.Getenv("RECORD_CHANGE_BURST"), defaultChangeBurst)
+		burst = defaultChangeBurst
+	}
+
+	logrus.Infof("limit record changes to %d per hour and domain with a burst of %d", perHour, burst)
+	return &changeLimiter{
+		limit:   rate.Limit(float64(perHour) / time.Hour.Seconds()),
+		burst:   burst,
+		domains: make(map[string]*domainLimiter),
+	}
+}
+
+// Used to check whether a change of the domain is allowed, the wait till the next change is returned otherwise
+func (l *changeLimiter) allow(fqdn string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d, ok := l.domains[fqdn]
+	if !ok {
+		if len(l.domains) >= maxLimitedDomains {
+			l.forget(now)
+		}
+		d = &domainLimiter{Limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.domains[fqdn] = d
+	}
+	d.last = now
+
+	r := d.ReserveN(now, 1)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Used to forget the domains whose buckets have refilled since their last change
+func (l *changeLimiter) forget(now time.Time) {
+	refill := time.Duration(float64(l.burst) / float64(l.limit) * float64(time.Second))
+	for fqdn, d := range l.domains {
+		if now.Sub(d.last) > refill {
+			delete(l.domains, fqdn)
+		}
+	}
+}
+
+// Used to check a scheduled change of the hosts against the rate of its domain when it is made, a
+// change which exceeds it is refused like a request of the api.
+func (l *changeLimiter) checkChange(c model.ScheduledChange) error {
+	if c.Action != model.ScheduleHosts {
+		return nil
+	}
+	if ok, delay := l.allow(c.Fqdn, time.Now()); !ok {
+		limitedChanges.WithLabelValues("scheduleDomainChange").Inc()
+		return errors.Errorf("too many changes of %s, retry in %s", c.Fqdn, delay.Round(time.Second))
+	}
+	return nil
+}
+
+// middleware refuses the changes of a domain which exceed its rate with 429, it runs after the token
+// check so requests without the token of the domain do not use up its changes.
+func (l *changeLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil || !changeRoutes[route.GetName()] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fqdn := mux.Vars(r)["fqdn"]
+		if ok, delay := l.allow(fqdn, time.Now()); !ok {
+			limitedChanges.WithLabelValues(route.GetName()).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			returnHTTPError(w, http.StatusTooManyRequests, errors.Errorf("too many changes of %s, retry in %s", fqdn, delay.Round(time.Second)))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"net/http"
 
+	"github.com/rancher/rdns-server/schedule"
+
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
@@ -24,6 +26,18 @@ var routes = Routes{
 		"/ping",
 		ping,
 	},
+	Route{
+		"healthz",
+		"GET",
+		"/healthz",
+		healthz,
+	},
+	Route{
+		"readyz",
+		"GET",
+		"/readyz",
+		readyz,
+	},
 	Route{
 		"getDomain",
 		"GET",
@@ -42,6 +56,12 @@ var routes = Routes{
 		"/v1/domain/{fqdn}",
 		updateDomain,
 	},
+	Route{
+		"patchDomainHosts",
+		"PATCH",
+		"/v1/domain/{fqdn}/hosts",
+		patchDomainHosts,
+	},
 	Route{
 		"deleteDomain",
 		"DELETE",
@@ -54,6 +74,168 @@ var routes = Routes{
 		"/v1/domain/{fqdn}/renew",
 		renewDomain,
 	},
+	Route{
+		"keepaliveDomain",
+		"PUT",
+		"/v1/domain/{fqdn}/keepalive",
+		keepaliveDomain,
+	},
+	Route{
+		"rotateDomainToken",
+		"POST",
+		"/v1/domain/{fqdn}/token/rotate",
+		rotateDomainToken,
+	},
+	Route{
+		"createReadOnlyToken",
+		"POST",
+		"/v1/domain/{fqdn}/token/read-only",
+		createReadOnlyToken,
+	},
+	Route{
+		"deleteReadOnlyToken",
+		"DELETE",
+		"/v1/domain/{fqdn}/token/read-only",
+		deleteReadOnlyToken,
+	},
+	Route{
+		"exportDomain",
+		"GET",
+		"/v1/domain/{fqdn}/export",
+		exportDomain,
+	},
+	Route{
+		"createRecoveryChallenge",
+		"POST",
+		"/v1/domain/{fqdn}/token/recovery",
+		createRecoveryChallenge,
+	},
+	Route{
+		"recoverDomainToken",
+		"POST",
+		"/v1/domain/{fqdn}/token/recovery/verify",
+		recoverDomainToken,
+	},
+	Route{
+		"approveDevice",
+		"POST",
+		"/v1/domain/{fqdn}/device/approve",
+		approveDevice,
+	},
+	Route{
+		"createDeviceCode",
+		"POST",
+		"/v1/device/code",
+		createDeviceCode,
+	},
+	Route{
+		"createDeviceToken",
+		"POST",
+		"/v1/device/token",
+		createDeviceToken,
+	},
+	Route{
+		"getDevicePage",
+		"GET",
+		"/v1/device",
+		getDevicePage,
+	},
+	Route{
+		"setDomainTTL",
+		"PUT",
+		"/v1/domain/{fqdn}/ttl",
+		setDomainTTL,
+	},
+	Route{
+		"drainHost",
+		"POST",
+		"/v1/domain/{fqdn}/hosts/{ip}/drain",
+		drainHost,
+	},
+	Route{
+		"undrainHost",
+		"DELETE",
+		"/v1/domain/{fqdn}/hosts/{ip}/drain",
+		undrainHost,
+	},
+	Route{
+		"getDomainCAA",
+		"GET",
+		"/v1/domain/{fqdn}/caa",
+		getDomainCAA,
+	},
+	Route{
+		"setDomainCAA",
+		"PUT",
+		"/v1/domain/{fqdn}/caa",
+		setDomainCAA,
+	},
+	Route{
+		"deleteDomainCAA",
+		"DELETE",
+		"/v1/domain/{fqdn}/caa",
+		deleteDomainCAA,
+	},
+	Route{
+		"getDomainQueryTypes",
+		"GET",
+		"/v1/domain/{fqdn}/types",
+		getDomainQueryTypes,
+	},
+	Route{
+		"setDomainQueryTypes",
+		"PUT",
+		"/v1/domain/{fqdn}/types",
+		setDomainQueryTypes,
+	},
+	Route{
+		"deleteDomainQueryTypes",
+		"DELETE",
+		"/v1/domain/{fqdn}/types",
+		deleteDomainQueryTypes,
+	},
+	Route{
+		"getDomainHealth",
+		"GET",
+		"/v1/domain/{fqdn}/health",
+		getDomainHealth,
+	},
+	Route{
+		"listDomainWebhooks",
+		"GET",
+		"/v1/domain/{fqdn}/webhooks",
+		listDomainWebhooks,
+	},
+	Route{
+		"createDomainWebhook",
+		"POST",
+		"/v1/domain/{fqdn}/webhooks",
+		createDomainWebhook,
+	},
+	Route{
+		"deleteDomainWebhook",
+		"DELETE",
+		"/v1/domain/{fqdn}/webhooks/{id}",
+		deleteDomainWebhook,
+	},
+	Route{
+		"listDomainSchedule",
+		"GET",
+		"/v1/domain/{fqdn}/schedule",
+		listDomainSchedule,
+	},
+	Route{
+		"scheduleDomainChange",
+		"POST",
+		"/v1/domain/{fqdn}/schedule",
+		scheduleDomainChange,
+	},
+	Route{
+		"cancelDomainChange",
+		"DELETE",
+		"/v1/domain/{fqdn}/schedule/{id}",
+		cancelDomainChange,
+	},
 	Route{
 		"createDomainCNAME",
 		"POST",
@@ -102,6 +284,198 @@ var routes = Routes{
 		"/v1/domain/{fqdn}/txt",
 		deleteDomainText,
 	},
+	Route{
+		"getDomainStatus",
+		"GET",
+		"/v1/domain/{fqdn}/status",
+		getDomainStatus,
+	},
+	Route{
+		"setDomainStatus",
+		"PUT",
+		"/v1/domain/{fqdn}/status",
+		setDomainStatus,
+	},
+	Route{
+		"deleteDomainStatus",
+		"DELETE",
+		"/v1/domain/{fqdn}/status",
+		deleteDomainStatus,
+	},
+	Route{
+		"getSchemas",
+		"GET",
+		"/v1/schemas",
+		getSchemas,
+	},
+	Route{
+		"getSchema",
+		"GET",
+		"/v1/schemas/{name}",
+		getSchemas,
+	},
+	Route{
+		"listDomains",
+		"GET",
+		"/v1/admin/domains",
+		listDomains,
+	},
+	Route{
+		"listRegisteredDomains",
+		"GET",
+		"/v1/domains",
+		listDomains,
+	},
+	Route{
+		"inspectDomain",
+		"GET",
+		"/v1/admin/domains/{fqdn}",
+		inspectDomain,
+	},
+	Route{
+		"forceDeleteDomain",
+		"DELETE",
+		"/v1/admin/domains/{fqdn}",
+		forceDeleteDomain,
+	},
+	Route{
+		"setDomainExpiration",
+		"PUT",
+		"/v1/admin/domains/{fqdn}/expiration",
+		setDomainExpiration,
+	},
+	Route{
+		"getHostDomains",
+		"GET",
+		"/v1/admin/hosts/{host}",
+		getHostDomains,
+	},
+	Route{
+		"replaceHost",
+		"POST",
+		"/v1/admin/hosts/{host}/replace",
+		replaceHost,
+	},
+	Route{
+		"removeNode",
+		"POST",
+		"/v1/admin/nodes/removed",
+		removeNode,
+	},
+	Route{
+		"listSchedule",
+		"GET",
+		"/v1/admin/schedule",
+		listSchedule,
+	},
+	Route{
+		"listSuspensions",
+		"GET",
+		"/v1/admin/suspensions",
+		listSuspensions,
+	},
+	Route{
+		"suspendDomain",
+		"PUT",
+		"/v1/admin/suspensions/{fqdn}",
+		suspendDomain,
+	},
+	Route{
+		"unsuspendDomain",
+		"DELETE",
+		"/v1/admin/suspensions/{fqdn}",
+		unsuspendDomain,
+	},
+	Route{
+		"getRPZ",
+		"GET",
+		"/v1/admin/rpz",
+		getRPZ,
+	},
+	Route{
+		"exportDomains",
+		"GET",
+		"/v1/admin/export",
+		exportDomains,
+	},
+	Route{
+		"importDomains",
+		"POST",
+		"/v1/admin/import",
+		importDomains,
+	},
+	Route{
+		"getCoreFileDrift",
+		"GET",
+		"/v1/admin/corefile/drift",
+		getCoreFileDrift,
+	},
+	Route{
+		"getConfig",
+		"GET",
+		"/v1/admin/config",
+		getConfig,
+	},
+	Route{
+		"getBackendState",
+		"GET",
+		"/v1/admin/backend/state",
+		getBackendState,
+	},
+	Route{
+		"setBackendState",
+		"PUT",
+		"/v1/admin/backend/state",
+		setBackendState,
+	},
+	Route{
+		"listOperationalStates",
+		"GET",
+		"/v1/admin/state",
+		listOperationalStates,
+	},
+	Route{
+		"getMaintenance",
+		"GET",
+		"/v1/admin/state/maintenance",
+		getMaintenance,
+	},
+	Route{
+		"setMaintenance",
+		"PUT",
+		"/v1/admin/state/maintenance",
+		setMaintenance,
+	},
+	Route{
+		"listFeatures",
+		"GET",
+		"/v1/admin/state/features",
+		listFeatures,
+	},
+	Route{
+		"setFeature",
+		"PUT",
+		"/v1/admin/state/features/{name}",
+		setFeature,
+	},
+	Route{
+		"listAPIKeys",
+		"GET",
+		"/v1/admin/apikeys",
+		listAPIKeys,
+	},
+	Route{
+		"createAPIKey",
+		"POST",
+		"/v1/admin/apikeys",
+		createAPIKey,
+	},
+	Route{
+		"revokeAPIKey",
+		"DELETE",
+		"/v1/admin/apikeys/{id}",
+		revokeAPIKey,
+	},
 	Route{
 		"migrateRecords",
 		"POST",
@@ -134,9 +508,26 @@ func NewRouter() *mux.Router {
 			Handler(apiHandler(route.HandlerFunc))
 	}
 
+	// the api description is generated from the routes, so it is not one of them
+	router.Methods(http.MethodGet).Path(openAPIPath).Name("getOpenAPI").Handler(apiHandler(openAPIHandler(newOpenAPI(routes))))
 	router.Handle("/metrics", promhttp.Handler())
 
+	proxies = newTrustedProxies()
+	router.Use(loggingMiddleware)
+	router.Use(sloMiddleware)
+	router.Use(metricsMiddleware)
+	v := newValidator()
+	router.Use(v.middleware)
 	router.Use(tokenMiddleware)
+	if f := newFrontdoor(); f != nil {
+		router.Use(f.middleware)
+	}
+	router.Use(maintenanceMiddleware)
+	l := newChangeLimiter()
+	if l != nil {
+		router.Use(l.middleware)
+	}
+	schedule.SetCheck(scheduleCheck(v, l))
 
 	return router
 }
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/schedule"
+)
+
+func TestScheduledChangesOfDeletedDomain(t *testing.T) {
+	b, c := newTestServer(t)
+
+	dc, d, err := c.Register(&model.DomainOptions{Hosts: []string{"1.1.1.1"}})
+	if err != nil {
+		t.Fatalf("failed to create a domain: %v", err)
+	}
+	if d.Expiration == nil {
+		t.Fatalf("expected the domain to expire")
+	}
+
+	late := d.Expiration.Add(time.Hour)
+	if _, err := dc.Schedule(&model.ScheduleOptions{Action: model.ScheduleHosts, Hosts: []string{"2.2.2.2"}, At: &late}); err == nil {
+		t.Fatalf("expected a change after the expiration of the domain to be refused")
+	}
+
+	at := time.Now().Add(time.Hour)
+	if _, err := dc.Schedule(&model.ScheduleOptions{Action: model.ScheduleHosts, Hosts: []string{"2.2.2.2"}, At: &at}); err != nil {
+		t.Fatalf("failed to schedule a change: %v", err)
+	}
+	cs, err := schedule.List(b, d.Fqdn)
+	if err != nil {
+		t.Fatalf("failed to list the scheduled changes: %v", err)
+	}
+	if len(cs) != 1 || cs[0].Source == "" {
+		t.Fatalf("expected the change with the source of its request, got %v", cs)
+	}
+
+	// the change is never made to a domain which is registered with the name again
+	if err := dc.Delete(); err != nil {
+		t.Fatalf("failed to delete the domain: %v", err)
+	}
+	if cs, err := schedule.List(b, d.Fqdn); err != nil || len(cs) != 0 {
+		t.Fatalf("expected the changes to be purged with the domain, got %v, err: %v", cs, err)
+	}
+}
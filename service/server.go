@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// shutdownTimeout is how long the requests in flight may take once the api is stopped
+const shutdownTimeout = 5 * time.Second
+
+// ListenAndServe serves the api on the address until done is closed, it is served with TLS when TLS_CERT
+// and TLS_KEY are set and clients must present a certificate signed by TLS_CLIENT_CA when it is set too.
+func ListenAndServe(addr string, done chan struct{}) error {
+	return Serve(addr, NewRouter(), done)
+}
+
+// Serve serves the handler on the address like ListenAndServe, it is used to serve a router
+// which is also handed to an embedding program.
+func Serve(addr string, handler http.Handler, done chan struct{}) error {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+
+	cert, key := os.Getenv("TLS_CERT"), os.Getenv("TLS_KEY")
+	if cert == "" && key == "" {
+		if os.Getenv("TLS_CLIENT_CA") != "" {
+			return errors.New("tls client ca is set without tls cert and key")
+		}
+		return serve(server, done, server.ListenAndServe)
+	}
+	if cert == "" || key == "" {
+		return errors.New("tls cert and tls key must be set together")
+	}
+
+	server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if ca := os.Getenv("TLS_CLIENT_CA"); ca != "" {
+		pem, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read tls client ca %s", ca)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.Errorf("no certificates in tls client ca %s", ca)
+		}
+		server.TLSConfig.ClientCAs = pool
+		server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		logrus.Infof("api clients must present a certificate of %s", ca)
+	}
+
+	return serve(server, done, func() error { return server.ListenAndServeTLS(cert, key) })
+}
+
+func serve(server *http.Server, done chan struct{}, listen func() error) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- listen()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-done:
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(ctx)
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/memory"
+	approuter "github.com/rancher/rdns-server/client"
+)
+
+const testRoot = "lb.rancher.cloud"
+
+// Used to serve the api of a new memory backend, the client talks to it like to a running server
+func newTestServer(t *testing.T) (*memory.Backend, *approuter.Client) {
+	t.Helper()
+
+	for k, v := range map[string]string{
+		"DOMAIN":            testRoot,
+		"MEMORY_LEASE_TIME": "240h",
+		"FROZEN":            "0s",
+		"ACME_TXT_TTL":      "0s",
+	} {
+		os.Setenv(k, v)
+	}
+
+	b, err := memory.NewBackend()
+	if err != nil {
+		t.Fatalf("failed to create the memory backend: %v", err)
+	}
+	backend.SetBackend(b)
+
+	s := httptest.NewServer(NewRouter())
+	t.Cleanup(s.Close)
+
+	return b, approuter.NewTokenClient(s.URL + "/v1")
+}
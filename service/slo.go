@@ -0,0 +1,36 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rancher/rdns-server/slo"
+)
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// sloMiddleware counts the api requests which are not answered with 5xx as available.
+func sloMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/metrics") || probePaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		slo.Record(slo.APIAvailability, rec.status < http.StatusInternalServerError)
+	})
+}
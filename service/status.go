@@ -0,0 +1,118 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/webhook"
+)
+
+func returnSuccessWithStatus(w http.ResponseWriter, s model.DomainStatus, msg string) {
+	o := model.DomainStatusResponse{
+		Status:  http.StatusOK,
+		Message: msg,
+		Data:    s,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// The status of a domain is kept as the TXT record of _status.<fqdn>, setting it creates the
+// record or replaces the previous status.
+func setDomainStatus(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s, err := model.ParseDomainStatus(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.Validate(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// the status is kept by its content like the other TXT values, an update replaces the old one
+	opts := &model.DomainOptions{Fqdn: model.StatusName(fqdn), Text: s.Format()}
+	opts.Order = model.TextOrder(opts.Text)
+	if err := checkQueryTypes(opts.Fqdn, opts.Records()); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b := backend.GetBackend()
+	var d model.Domain
+	var before *model.Domain
+	prev, err := b.GetText(&model.DomainOptions{Fqdn: opts.Fqdn})
+	if err == nil {
+		before = &prev
+		opts.Replace = true
+		d, err = b.UpdateText(opts)
+	} else {
+		d, err = b.SetText(opts)
+	}
+	if err != nil {
+		returnHTTPError(w, writeStatus(err), err)
+		return
+	}
+	webhook.PublishChange(model.EventTextSet, fqdn, d, before, &d)
+
+	s.Fqdn = d.Fqdn
+	s.Text = d.Text
+	returnSuccessWithStatus(w, *s, "")
+}
+
+func getDomainStatus(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	b := backend.GetBackend()
+	d, err := b.GetText(&model.DomainOptions{Fqdn: model.StatusName(fqdn)})
+	if err != nil {
+		returnSuccessWithStatus(w, model.DomainStatus{}, err.Error())
+		return
+	}
+
+	s, err := model.ParseStatusText(d.Text)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.Fqdn = d.Fqdn
+	returnSuccessWithStatus(w, *s, "")
+}
+
+func deleteDomainStatus(w http.ResponseWriter, r *http.Request) {
+	fqdn, err := domainFqdn(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	opts := &model.DomainOptions{Fqdn: model.StatusName(fqdn)}
+
+	b := backend.GetBackend()
+	before := webhook.Snapshot(b.GetText, opts)
+	if err := b.DeleteText(opts); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.PublishChange(model.EventTextDeleted, fqdn, model.Domain{Fqdn: opts.Fqdn}, before, nil)
+
+	returnSuccessNoData(w)
+}
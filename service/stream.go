@@ -0,0 +1,84 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/export"
+	"github.com/rancher/rdns-server/importer"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+func returnSuccessWithImportSummary(w http.ResponseWriter, s model.ImportSummary) {
+	o := model.ImportSummaryResponse{
+		Status: http.StatusOK,
+		Data:   s,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// The export streams the domains of one root domain or of all of them as NDJSON, the status is
+// written with the first page so an export which fails later only ends early and is logged.
+func exportDomains(w http.ResponseWriter, r *http.Request) {
+	b := backend.GetReader()
+	roots := backend.Zones(b)
+	if root := r.URL.Query().Get("root"); root != "" {
+		opts := &model.DomainOptions{Root: root}
+		if err := checkRoot(opts); err != nil {
+			returnHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+		roots = []string{opts.Root}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flush := func() {}
+	if f := flusherOf(w); f != nil {
+		flush = f.Flush
+	}
+	n, err := export.Stream(b, roots, w, flush)
+	if err != nil {
+		logrus.Errorf("export ended after %d domains: %v", n, err)
+		return
+	}
+	logrus.Infof("exported %d domains", n)
+}
+
+// The import reads the NDJSON of an export one record at a time and answers with a summary once
+// the whole body is read, a body which is no NDJSON ends the import with the domains before it imported.
+func importDomains(w http.ResponseWriter, r *http.Request) {
+	s, err := importer.ImportStream(backend.GetBackend(), r.Body)
+	if err != nil {
+		logrus.Errorf("import ended after %d domains: %v", s.Imported+s.Skipped+s.Failed, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	logrus.Infof("imported %d domains, skipped %d, failed %d", s.Imported, s.Skipped, s.Failed)
+
+	returnSuccessWithImportSummary(w, s)
+}
+
+// Used to get the flusher of the response writer, the writers of other middlewares are
+// unwrapped until one flushes
+func flusherOf(w http.ResponseWriter) http.Flusher {
+	for {
+		switch rw := w.(type) {
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		case http.Flusher:
+			return rw
+		default:
+			return nil
+		}
+	}
+}
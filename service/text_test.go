@@ -0,0 +1,55 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rancher/rdns-server/model"
+)
+
+func TestUpdateTextWithoutOrder(t *testing.T) {
+	b, c := newTestServer(t)
+
+	dc, d, err := c.Register(&model.DomainOptions{Hosts: []string{"1.1.1.1"}})
+	if err != nil {
+		t.Fatalf("failed to create a domain: %v", err)
+	}
+	name := "_acme-challenge." + d.Fqdn
+
+	if _, err := dc.SetText(name, "first", ""); err != nil {
+		t.Fatalf("failed to set a text: %v", err)
+	}
+	if _, err := dc.SetText(name, "second", ""); err != nil {
+		t.Fatalf("failed to set a text: %v", err)
+	}
+	// a value of an earlier version is kept by the record itself
+	if _, err := b.SetText(&model.DomainOptions{Fqdn: name, Text: "legacy"}); err != nil {
+		t.Fatalf("failed to set a text without an order: %v", err)
+	}
+
+	r, err := dc.GetText(name, "")
+	if err != nil {
+		t.Fatalf("failed to get the texts: %v", err)
+	}
+	if len(r.Texts) != 3 {
+		t.Fatalf("expected the texts of the orders and of the record, got %v", r.Texts)
+	}
+
+	if _, err := dc.UpdateText(name, "third", ""); err != nil {
+		t.Fatalf("failed to update the text: %v", err)
+	}
+	r, err = dc.GetText(name, "")
+	if err != nil {
+		t.Fatalf("failed to get the texts: %v", err)
+	}
+	if !reflect.DeepEqual(r.Texts, []string{"third"}) {
+		t.Fatalf("expected the updated text to replace all texts, got %v", r.Texts)
+	}
+	// the updated value is kept by its content, so it is deleted like any other of them
+	if err := dc.DeleteTextValue(name, "third"); err != nil {
+		t.Fatalf("failed to delete the text: %v", err)
+	}
+	if r, _ := dc.GetText(name, ""); r.Text != "" {
+		t.Fatalf("expected no text after the delete, got %v", r.Texts)
+	}
+}
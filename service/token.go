@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/rancher/rdns-server/admin"
 	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -13,6 +15,65 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	adminPathPrefix = "/v1/admin/"
+	// the domain list is an admin route outside of the admin prefix
+	domainsPath = "/v1/domains"
+	// the schemas of the payloads are public
+	schemasPath = "/v1/schemas"
+	// the api description is public
+	openAPIPath = "/v1/openapi.json"
+)
+
+// probePaths are the liveness and readiness probes, they are not authenticated and do not count against the slo
+var probePaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// adminRoles are the lowest roles which are allowed to use the admin routes
+var adminRoles = map[string]string{
+	"listDomains":           admin.RoleViewer,
+	"listRegisteredDomains": admin.RoleViewer,
+	"getHostDomains":        admin.RoleViewer,
+	"listSuspensions":       admin.RoleViewer,
+	"listSchedule":          admin.RoleViewer,
+	"getRPZ":                admin.RoleViewer,
+	"getCoreFileDrift":      admin.RoleViewer,
+	"getConfig":             admin.RoleViewer,
+	"getBackendState":       admin.RoleViewer,
+	"listOperationalStates": admin.RoleViewer,
+	"getMaintenance":        admin.RoleViewer,
+	"listFeatures":          admin.RoleViewer,
+	"listAPIKeys":           admin.RoleViewer,
+	"inspectDomain":         admin.RoleAbuseHandler,
+	"forceDeleteDomain":     admin.RoleAbuseHandler,
+	"setDomainExpiration":   admin.RoleAbuseHandler,
+	"suspendDomain":         admin.RoleAbuseHandler,
+	"unsuspendDomain":       admin.RoleAbuseHandler,
+	"replaceHost":           admin.RoleOperator,
+	"removeNode":            admin.RoleOperator,
+	"setBackendState":       admin.RoleOperator,
+	"setMaintenance":        admin.RoleOperator,
+	"setFeature":            admin.RoleOperator,
+}
+
+// readOnlyRoutes are the routes of a domain which also accept its read-only token
+var readOnlyRoutes = map[string]bool{
+	"getDomain":           true,
+	"renewDomain":         true,
+	"keepaliveDomain":     true,
+	"exportDomain":        true,
+	"getDomainCAA":        true,
+	"getDomainQueryTypes": true,
+	"getDomainHealth":     true,
+	"listDomainWebhooks":  true,
+	"listDomainSchedule":  true,
+	"getDomainCNAME":      true,
+	"getDomainText":       true,
+	"getDomainStatus":     true,
+}
+
 func generateToken(fqdn string) (string, error) {
 	b := backend.GetBackend()
 	origin, err := b.GetToken(fqdn)
@@ -20,6 +81,10 @@ func generateToken(fqdn string) (string, error) {
 		logrus.Errorf("failed to get token origin %s, err: %v", fqdn, err)
 		return "", err
 	}
+	return hashOrigin(fqdn, origin)
+}
+
+func hashOrigin(fqdn, origin string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(origin), bcrypt.MinCost)
 	if err != nil {
 		logrus.Errorf("failed to generate token with %s, err: %v", fqdn, err)
@@ -30,15 +95,23 @@ func generateToken(fqdn string) (string, error) {
 	return token, nil
 }
 
-func compareToken(fqdn, token string) bool {
-	// normal text record & acme text record need special treatment
+// Used to get the domain whose token authorizes the fqdn
+// normal text record & acme text record need special treatment
+// e.g. _acme-challenge.sample.lb.rancher.cloud => sample.lb.rancher.cloud
+func tokenFqdn(fqdn string) string {
 	fqdnLen := len(strings.Split(fqdn, "."))
-	rootDomainLen := len(strings.Split(backend.GetBackend().GetZone(), "."))
+	rootDomainLen := len(strings.Split(strings.Trim(backend.ZoneOf(backend.GetBackend(), fqdn), "."), "."))
 	diffLen := fqdnLen - rootDomainLen
 	if diffLen > 1 {
 		sp := strings.SplitAfterN(fqdn, ".", diffLen)
 		fqdn = sp[len(sp)-1]
 	}
+	return fqdn
+}
+
+// The read-only token of the domain is only compared when readOnly is set.
+func compareToken(fqdn, token string, readOnly bool) bool {
+	fqdn = tokenFqdn(fqdn)
 
 	hash, err := base64.StdEncoding.DecodeString(token)
 	if err != nil {
@@ -54,6 +127,12 @@ func compareToken(fqdn, token string) bool {
 	}
 
 	err = bcrypt.CompareHashAndPassword(hash, []byte(origin))
+	if err != nil && readOnly {
+		if o, serr := b.GetScopedToken(fqdn, model.TokenScopeReadOnly); serr == nil && bcrypt.CompareHashAndPassword(hash, []byte(o)) == nil {
+			logrus.Debugf("read-only token **** matched with fqdn %s", fqdn)
+			return true
+		}
+	}
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"token": token,
@@ -65,21 +144,89 @@ func compareToken(fqdn, token string) bool {
 	return true
 }
 
+// Used to authorize an admin request, the role of the credential must allow the route
+// e.g. viewer, listDomains => true
+// e.g. viewer, suspendDomain => false
+func authorizeAdmin(r *http.Request) error {
+	if !admin.Enabled() {
+		logrus.Debugf("admin credentials are not set, admin api is disabled")
+		return errors.New("forbidden to use")
+	}
+
+	id, ok := admin.Authenticate(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if !ok {
+		return errors.New("forbidden to use")
+	}
+
+	name := ""
+	if route := mux.CurrentRoute(r); route != nil {
+		name = route.GetName()
+	}
+	// admin routes without a role are only opened to operators
+	role, ok := adminRoles[name]
+	if !ok {
+		role = admin.RoleOperator
+	}
+	if !id.Allows(role) {
+		return errors.Errorf("role %s of %s is not allowed to %s", id.Role, id.Name, name)
+	}
+
+	if r.Method != http.MethodGet {
+		logrus.Infof("admin %s (%s) requested %s %s", id.Name, id.Role, r.Method, r.URL.Path)
+	}
+	return nil
+}
+
+func isAdminPath(path string) bool {
+	return strings.HasPrefix(path, adminPathPrefix) || strings.TrimSuffix(path, "/") == domainsPath
+}
+
+// Used to tell whether a request outside of the admin api needs the token of its domain, the
+// api description is generated with it too
+func tokenRequired(method, path string) bool {
+	if method == http.MethodPost {
+		return strings.Contains(path, "/txt") || strings.HasSuffix(path, "/webhooks") || strings.HasSuffix(path, "/schedule") || strings.HasSuffix(path, "/token/rotate") || strings.HasSuffix(path, "/token/read-only") || strings.HasSuffix(path, "/device/approve") || strings.HasSuffix(path, "/drain")
+	}
+	return !strings.HasPrefix(path, "/ping") && !strings.HasPrefix(path, "/metrics") && path != devicePath && !probePaths[path] && !strings.HasPrefix(path, schemasPath) && path != openAPIPath
+}
+
 func tokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// createDomain and ping and metrics have no need to check token
+		// createDomain and ping and metrics and the probes and the device page have no need to check token
 		logrus.Debugf("request URL path: %s", r.URL.Path)
-		if (r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/txt")) ||
-			(r.Method != http.MethodPost && !strings.HasPrefix(r.URL.Path, "/ping") && !strings.HasPrefix(r.URL.Path, "/metrics")) {
+		// admin api is only checked with the admin token
+		if isAdminPath(r.URL.Path) {
+			if err := authorizeAdmin(r); err != nil {
+				authFailures.WithLabelValues(authFailureAdmin).Inc()
+				returnHTTPError(w, http.StatusForbidden, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if tokenRequired(r.Method, r.URL.Path) {
 			authorization := r.Header.Get("Authorization")
 			token := strings.TrimLeft(authorization, "Bearer ")
 			fqdn, ok := mux.Vars(r)["fqdn"]
 			if ok {
-				if !compareToken(fqdn, token) {
+				name := ""
+				if route := mux.CurrentRoute(r); route != nil {
+					name = route.GetName()
+				}
+				// trusted controllers manage the domains of their scope with an api key
+				if key := strings.TrimPrefix(authorization, "Bearer "); isAPIKey(key) {
+					if !compareAPIKey(fqdn, key) {
+						authFailures.WithLabelValues(authFailureToken).Inc()
+						returnHTTPError(w, http.StatusForbidden, errors.New("forbidden to use"))
+						return
+					}
+				} else if !compareToken(fqdn, token, readOnlyRoutes[name]) {
+					authFailures.WithLabelValues(authFailureToken).Inc()
 					returnHTTPError(w, http.StatusForbidden, errors.New("forbidden to use"))
 					return
 				}
 			} else {
+				authFailures.WithLabelValues(authFailureFqdn).Inc()
 				returnHTTPError(w, http.StatusForbidden, errors.New("must specific the fqdn"))
 				return
 			}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxHosts = 64
+
+	// ownershipSource only accepts the hosts which are the source address of the request
+	ownershipSource = "source"
+	// ownershipProof also accepts the hosts which carry a proof of HOST_PROOF_SECRET
+	ownershipProof = "proof"
+)
+
+var (
+	// hostRoutes read the hosts of their payload by field
+	hostRoutes = map[string]func(body []byte, req *validationRequest) error{
+		"createDomain":     domainHosts,
+		"updateDomain":     domainHosts,
+		"patchDomainHosts": patchHosts,
+		// the hosts of a scheduled change are checked when it is scheduled and when it is made
+		"scheduleDomainChange": domainHosts,
+	}
+
+	invalidRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_invalid_requests",
+		Help: "The number of requests refused by the validation by check",
+	}, []string{"check"})
+)
+
+// validator checks the fqdn and the hosts of a request before it reaches the token check and the
+// backend, the checks run in order and the first one which refuses a field ends the validation.
+type validator struct {
+	maxHosts   int
+	publicOnly bool
+	ownership  string
+	proofKey   string
+	checks     []check
+}
+
+type check struct {
+	name string
+	run  func(req *validationRequest) []model.FieldError
+}
+
+type validationRequest struct {
+	fqdn string
+	// the hosts of the payload by field, e.g. hosts, subdomain.x1 or add
+	hosts map[string][]string
+	// the hosts of the fields which are set with hostsv6 and subdomainv6, they are the last hosts of
+	// their field. nil when the fields of the hosts are not known, e.g. of a scheduled change
+	hostsV6 map[string][]string
+	// the source address and the host proofs of the request, only read when the ownership is checked
+	source string
+	proofs map[string]string
+}
+
+// Used to get the validator of MAX_HOSTS, REJECT_PRIVATE_HOSTS and HOST_OWNERSHIP
+func newValidator() *validator {
+	v := &validator{maxHosts: defaultMaxHosts}
+	if s := os.Getenv("MAX_HOSTS"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			logrus.Errorf("invalid max hosts %s, use %d", s, defaultMaxHosts)
+		} else {
+			v.maxHosts = n
+		}
+	}
+	v.publicOnly, _ = strconv.ParseBool(os.Getenv("REJECT_PRIVATE_HOSTS"))
+
+	v.checks = []check{
+		{"fqdn", v.checkFqdn},
+		{"host", v.checkHostSyntax},
+		{"host_family", v.checkHostFamily},
+		{"host_count", v.checkHostCount},
+	}
+	if v.publicOnly {
+		v.checks = append(v.checks, check{"public_host", v.checkPublicHosts})
+	}
+
+	switch v.ownership = os.Getenv("HOST_OWNERSHIP"); v.ownership {
+	case "":
+	case ownershipProof:
+		if v.proofKey = os.Getenv("HOST_PROOF_SECRET"); v.proofKey == "" {
+			logrus.Errorf("host ownership %s needs HOST_PROOF_SECRET, only the source address is accepted", ownershipProof)
+			v.ownership = ownershipSource
+		}
+		fallthrough
+	case ownershipSource:
+		v.checks = append(v.checks, check{"host_owner", v.checkHostOwnership})
+	default:
+		logrus.Errorf("invalid host ownership %s, expected %s or %s, hosts are not checked", v.ownership, ownershipSource, ownershipProof)
+		v.ownership = ""
+	}
+	return v
+}
+
+// middleware refuses the requests which fail a check with a 400 which lists the refused fields.
+func (v *validator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		req := &validationRequest{fqdn: mux.Vars(r)["fqdn"]}
+		if parse, ok := hostRoutes[route.GetName()]; ok && r.Body != nil {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				returnHTTPError(w, http.StatusBadRequest, err)
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			// a payload which can not be parsed is refused by its handler
+			_ = parse(body, req)
+		}
+		if v.ownership != "" {
+			req.source = clientIP(r)
+			req.proofs = model.ParseHostProofs(r.Header.Get(model.HeaderHostProof))
+		}
+
+		for _, c := range v.checks {
+			if errs := c.run(req); len(errs) > 0 {
+				invalidRequests.WithLabelValues(c.name).Inc()
+				returnValidationErrors(w, errs)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Used to check the fqdn of the path and the names of its sub domains are dns names strictly below
+// one of the root domains, a root domain itself is never a domain of a request. The backends build
+// their paths of these names without checking them again.
+func (v *validator) checkFqdn(req *validationRequest) []model.FieldError {
+	if req.fqdn == "" {
+		return nil
+	}
+	if err := model.ValidateFqdn(req.fqdn); err != nil {
+		return []model.FieldError{{Field: "fqdn", Value: req.fqdn, Reason: err.Error()}}
+	}
+	zones := backend.Zones(backend.GetBackend())
+	root := util.RootOf(req.fqdn, zones)
+	if root == "" {
+		return []model.FieldError{{Field: "fqdn", Value: req.fqdn, Reason: "fqdn is not under the root domains " + strings.Join(zones, ", ")}}
+	}
+	if err := model.ValidateBelowRoot(req.fqdn, root); err != nil {
+		return []model.FieldError{{Field: "fqdn", Value: req.fqdn, Reason: err.Error()}}
+	}
+
+	errs := make([]model.FieldError, 0)
+	for _, field := range sortedFields(req.hosts) {
+		if !strings.HasPrefix(field, "subdomain.") {
+			continue
+		}
+		sub := strings.TrimPrefix(field, "subdomain.") + "." + req.fqdn
+		if err := model.ValidateBelowRoot(sub, root); err != nil {
+			errs = append(errs, model.FieldError{Field: field, Value: sub, Reason: err.Error()})
+		}
+	}
+	return errs
+}
+
+// Used to check the hosts are IP addresses
+func (v *validator) checkHostSyntax(req *validationRequest) []model.FieldError {
+	return eachHost(req, func(h string) string {
+		if net.ParseIP(h) == nil {
+			return "host is not an IP address"
+		}
+		return ""
+	})
+}
+
+// Used to check the IPv4 hosts are set with hosts and subdomain and the IPv6 hosts with hostsv6 and
+// subdomainv6, so a client can not mix them up by mistake
+// e.g. {"hosts": ["::1"]} => hosts ::1: IPv6 hosts are set with hostsv6
+func (v *validator) checkHostFamily(req *validationRequest) []model.FieldError {
+	if req.hostsV6 == nil {
+		return nil
+	}
+
+	errs := make([]model.FieldError, 0)
+	for _, field := range sortedFields(req.hosts) {
+		hosts := req.hosts[field]
+		n := len(hosts) - len(req.hostsV6[field])
+		v6, fieldV6 := "hostsv6", "hostsv6"
+		if sub := strings.TrimPrefix(field, "subdomain."); sub != field {
+			v6, fieldV6 = "subdomainv6", "subdomainv6."+sub
+		}
+		for _, h := range hosts[:n] {
+			if model.IsIPv6(h) {
+				errs = append(errs, model.FieldError{Field: field, Value: h, Reason: "IPv6 hosts are set with " + v6})
+			}
+		}
+		for _, h := range hosts[n:] {
+			if !model.IsIPv6(h) {
+				errs = append(errs, model.FieldError{Field: fieldV6, Value: h, Reason: "host is not an IPv6 address"})
+			}
+		}
+	}
+	return errs
+}
+
+// Used to check a domain or a sub domain has at most MAX_HOSTS hosts
+func (v *validator) checkHostCount(req *validationRequest) []model.FieldError {
+	errs := make([]model.FieldError, 0)
+	for _, field := range sortedFields(req.hosts) {
+		if n := len(req.hosts[field]); n > v.maxHosts {
+			errs = append(errs, model.FieldError{Field: field, Reason: fmt.Sprintf("%d hosts, at most %d are allowed", n, v.maxHosts)})
+		}
+	}
+	return errs
+}
+
+// Used to check the hosts are reachable from the internet, private, loopback and link local
+// addresses are refused
+func (v *validator) checkPublicHosts(req *validationRequest) []model.FieldError {
+	return eachHost(req, func(h string) string {
+		if !util.IsPublicIP(net.ParseIP(h)) {
+			return "host is not a public address"
+		}
+		return ""
+	})
+}
+
+// Used to check the hosts belong to the client, a host is accepted when it is the source address of
+// the request or, with the proof ownership, when the request carries an unexpired proof of it
+func (v *validator) checkHostOwnership(req *validationRequest) []model.FieldError {
+	source := net.ParseIP(req.source)
+	proofs := make(map[string]string, len(req.proofs))
+	for h, p := range req.proofs {
+		if ip := net.ParseIP(h); ip != nil {
+			proofs[ip.String()] = p
+		}
+	}
+
+	now := time.Now()
+	return eachHost(req, func(h string) string {
+		ip := net.ParseIP(h)
+		if ip.Equal(source) {
+			return ""
+		}
+		if v.ownership == ownershipProof {
+			if p, ok := proofs[ip.String()]; ok && model.VerifyHostProof(v.proofKey, ip.String(), p, now) {
+				return ""
+			}
+			return "host is neither the source address of the request nor proven by " + model.HeaderHostProof
+		}
+		return "host is not the source address of the request"
+	})
+}
+
+func eachHost(req *validationRequest, reason func(h string) string) []model.FieldError {
+	errs := make([]model.FieldError, 0)
+	for _, field := range sortedFields(req.hosts) {
+		for _, h := range req.hosts[field] {
+			if r := reason(h); r != "" {
+				errs = append(errs, model.FieldError{Field: field, Value: h, Reason: r})
+			}
+		}
+	}
+	return errs
+}
+
+func sortedFields(hosts map[string][]string) []string {
+	fields := make([]string, 0, len(hosts))
+	for f := range hosts {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// hostsPayload keeps the fields of the hosts of a domain payload apart, the options merge them
+type hostsPayload struct {
+	Hosts       []string            `json:"hosts"`
+	HostsV6     []string            `json:"hostsv6"`
+	SubDomain   map[string][]string `json:"subdomain"`
+	SubDomainV6 map[string][]string `json:"subdomainv6"`
+}
+
+// Used to read the hosts of a domain payload, the IPv6 hosts follow the others of their field
+// e.g. {"hosts": ["1.1.1.1"], "subdomain": {"x1": ["2.2.2.2"]}} => {hosts: [1.1.1.1], subdomain.x1: [2.2.2.2]}
+// e.g. {"hosts": ["1.1.1.1"], "hostsv6": ["2001:db8::1"]} => {hosts: [1.1.1.1 2001:db8::1]}
+func domainHosts(body []byte, req *validationRequest) error {
+	var p hostsPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return err
+	}
+
+	req.hosts = optionHosts(p.Hosts, p.SubDomain)
+	req.hostsV6 = optionHosts(p.HostsV6, p.SubDomainV6)
+	for field, hosts := range req.hostsV6 {
+		req.hosts[field] = append(req.hosts[field], hosts...)
+	}
+	return nil
+}
+
+func optionHosts(hs []string, subDomain map[string][]string) map[string][]string {
+	hosts := map[string][]string{"hosts": hs}
+	for sub, hs := range subDomain {
+		hosts["subdomain."+sub] = hs
+	}
+	return hosts
+}
+
+// Used to check the hosts of a scheduled change again before it is made, they are validated like
+// the hosts of a request from the source of the change
+func (v *validator) checkChange(c model.ScheduledChange) error {
+	if c.Action != model.ScheduleHosts {
+		return nil
+	}
+
+	req := &validationRequest{fqdn: c.Fqdn, hosts: optionHosts(c.Hosts, c.SubDomain), source: c.Source, proofs: c.Proofs}
+	for _, ch := range v.checks {
+		if errs := ch.run(req); len(errs) > 0 {
+			invalidRequests.WithLabelValues(ch.name).Inc()
+			return validationError(errs)
+		}
+	}
+	return nil
+}
+
+// Used to read the added hosts of a patch, removed hosts need not be valid anymore
+func patchHosts(body []byte, req *validationRequest) error {
+	var p model.HostsPatch
+	if err := json.Unmarshal(body, &p); err != nil {
+		return err
+	}
+	req.hosts = map[string][]string{"add": p.Add}
+	return nil
+}
+
+func validationError(errs []model.FieldError) error {
+	if errs[0].Value != "" {
+		return errors.Errorf("invalid %s %s: %s", errs[0].Field, errs[0].Value, errs[0].Reason)
+	}
+	return errors.Errorf("invalid %s: %s", errs[0].Field, errs[0].Reason)
+}
+
+func returnValidationErrors(w http.ResponseWriter, errs []model.FieldError) {
+	err := validationError(errs)
+	if l := requestLogOf(w); l != nil {
+		l.err = err
+	}
+	o := model.ValidationResponse{
+		Status:  http.StatusBadRequest,
+		Message: err.Error(),
+		Errors:  errs,
+	}
+	res, _ := json.Marshal(o)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(res)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/rdns-server/model"
+)
+
+func TestWritesBelowRoot(t *testing.T) {
+	_, c := newTestServer(t)
+
+	dc, d, err := c.Register(&model.DomainOptions{Hosts: []string{"1.1.1.1"}})
+	if err != nil {
+		t.Fatalf("failed to create a domain: %v", err)
+	}
+
+	// the validator refuses them before the token is checked
+	if _, err := c.Domain(testRoot, dc.Token()).Update([]string{"2.2.2.2"}, nil); err == nil {
+		t.Fatalf("expected the update of the root domain to be refused")
+	}
+	if _, err := dc.Update([]string{"2.2.2.2"}, map[string][]string{"": {"3.3.3.3"}}); err == nil {
+		t.Fatalf("expected an empty sub domain of %s to be refused", d.Fqdn)
+	}
+	if _, err := dc.Update([]string{"2.2.2.2"}, map[string][]string{"x1": {"3.3.3.3"}}); err != nil {
+		t.Fatalf("failed to update the domain: %v", err)
+	}
+}
+
+func TestSourceOwnershipBehindProxy(t *testing.T) {
+	t.Setenv("HOST_OWNERSHIP", "source")
+	newTestServer(t)
+
+	create := func(host, forwarded string) int {
+		r := httptest.NewRequest(http.MethodPost, "/v1/domain", strings.NewReader(`{"hosts": ["`+host+`"]}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.RemoteAddr = "192.0.2.1:52144"
+		if forwarded != "" {
+			r.Header.Set("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		NewRouter().ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := create("8.8.8.8", ""); code != http.StatusBadRequest {
+		t.Fatalf("expected a host of a third party to be refused, got %d", code)
+	}
+	// a client which is not a trusted proxy can not claim an address with the header
+	if code := create("8.8.8.8", "8.8.8.8"); code != http.StatusBadRequest {
+		t.Fatalf("expected a spoofed X-Forwarded-For to be refused, got %d", code)
+	}
+	if code := create("192.0.2.1", "8.8.8.8"); code != http.StatusOK {
+		t.Fatalf("expected the remote address to be accepted, got %d", code)
+	}
+
+	t.Setenv("TRUSTED_PROXIES", "192.0.2.0/24")
+	if code := create("8.8.8.8", "8.8.8.8"); code != http.StatusOK {
+		t.Fatalf("expected the client of a trusted proxy to be accepted, got %d", code)
+	}
+	// the entries before the ones the proxies add are sent by the client
+	if code := create("8.8.8.8", "8.8.8.8, 1.1.1.1"); code != http.StatusBadRequest {
+		t.Fatalf("expected the entry the client sent to be ignored, got %d", code)
+	}
+}
+
+func TestHostFamilies(t *testing.T) {
+	_, c := newTestServer(t)
+
+	send := func(method, path, token, body string) int {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		NewRouter().ServeHTTP(w, r)
+		return w.Code
+	}
+	create := func(body string) int {
+		return send(http.MethodPost, "/v1/domain", "", body)
+	}
+
+	// a host of the wrong family is an error of the client
+	for _, body := range []string{
+		`{"hosts": ["::1"]}`,
+		`{"hosts": ["1.1.1.1"], "hostsv6": ["2.2.2.2"]}`,
+		`{"hosts": ["1.1.1.1"], "subdomain": {"x1": ["2001:db8::1"]}}`,
+		`{"hosts": ["1.1.1.1"], "subdomainv6": {"x1": ["2.2.2.2"]}}`,
+	} {
+		if code := create(body); code != http.StatusBadRequest {
+			t.Errorf("expected %s to be refused with 400, got %d", body, code)
+		}
+	}
+	if code := create(`{"hosts": ["1.1.1.1"], "hostsv6": ["2001:db8::1"]}`); code != http.StatusOK {
+		t.Fatalf("expected the hosts of both families to be accepted, got %d", code)
+	}
+
+	dc, _, err := c.Register(&model.DomainOptions{Hosts: []string{"1.1.1.1"}})
+	if err != nil {
+		t.Fatalf("failed to create a domain: %v", err)
+	}
+	if code := send(http.MethodPut, "/v1/domain/"+dc.Fqdn(), dc.Token(), `{"hosts": ["::1"]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected the update with an IPv6 address in hosts to be refused with 400, got %d", code)
+	}
+	// the client sets the IPv6 hosts with hostsv6
+	if _, err := dc.Update([]string{"1.1.1.1", "2001:db8::1"}, nil); err != nil {
+		t.Fatalf("failed to update the hosts of both families: %v", err)
+	}
+}
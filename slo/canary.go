@@ -0,0 +1,133 @@
+package slo
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/dnsutil"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// documentation address (RFC 5737), the canary record never serves real traffic
+	canaryHost        = "192.0.2.1"
+	canaryPollPeriod  = 500 * time.Millisecond
+	canaryPrefix      = "canary"
+	canaryQueryPeriod = 2 * time.Second
+)
+
+var resolveDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "rancher_dns_canary_resolve_seconds",
+	Help:    "The duration from writing a canary record to resolving it",
+	Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60},
+})
+
+// canary writes a new sub domain of its own domain every probe and measures how long
+// the record takes to be answered by the resolver.
+type canary struct {
+	resolver  string
+	threshold time.Duration
+	fqdn      string
+	client    *dns.Client
+}
+
+func startCanary(resolver string, done chan struct{}) {
+	threshold, err := time.ParseDuration(os.Getenv("SLO_RESOLVE_THRESHOLD"))
+	if err != nil || threshold <= 0 {
+		threshold = 10 * time.Second
+	}
+	interval, err := time.ParseDuration(os.Getenv("SLO_CANARY_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = time.Minute
+	}
+
+	c := &canary{
+		resolver:  resolver,
+		threshold: threshold,
+		client:    &dns.Client{Timeout: canaryQueryPeriod},
+	}
+	wait.Until(c.probe, interval, done)
+}
+
+func (c *canary) probe() {
+	start := time.Now()
+	name, err := c.write()
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	deadline := start.Add(c.threshold)
+	for {
+		if c.resolved(name) {
+			resolveDuration.Observe(time.Since(start).Seconds())
+			Record(ResolveLatency, true)
+			return
+		}
+		if time.Now().After(deadline) {
+			resolveDuration.Observe(time.Since(start).Seconds())
+			Record(ResolveLatency, false)
+			logrus.Warn(errors.Errorf(errCanaryResolve, name, c.threshold))
+			return
+		}
+		time.Sleep(canaryPollPeriod)
+	}
+}
+
+// Used to write a new canary sub domain, the canary domain is created on first use
+// e.g. canary-x8k2p.qrn7oq.lb.rancher.cloud => 192.0.2.1
+func (c *canary) write() (string, error) {
+	b := backend.GetBackend()
+	sub := fmt.Sprintf("%s-%s", canaryPrefix, util.RandStringWithSmall(5))
+	opts := &model.DomainOptions{
+		Fqdn:      c.fqdn,
+		Hosts:     []string{canaryHost},
+		SubDomain: map[string][]string{sub: {canaryHost}},
+	}
+
+	if c.fqdn != "" {
+		if _, err := b.Update(opts); err == nil {
+			_, _ = b.Renew(&model.DomainOptions{Fqdn: c.fqdn})
+			return fmt.Sprintf("%s.%s", sub, c.fqdn), nil
+		}
+		logrus.Warnf(errCanaryUpdate, c.fqdn)
+	}
+
+	opts.Fqdn = ""
+	d, err := b.Set(opts)
+	if err != nil {
+		return "", errors.Wrap(err, errCanaryCreate)
+	}
+	c.fqdn = d.Fqdn
+
+	return fmt.Sprintf("%s.%s", sub, c.fqdn), nil
+}
+
+func (c *canary) resolved(name string) bool {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), dns.TypeA)
+
+	r, _, err := c.client.Exchange(m, c.resolver)
+	if err != nil || r.Rcode != dns.RcodeSuccess {
+		return false
+	}
+
+	for _, a := range dnsutil.FromRRs(r.Answer).A {
+		for _, h := range a.Hosts {
+			if h == canaryHost {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+package slo
+
+const (
+	errCanaryCreate    = "failed to create canary domain"
+	errCanaryResolve   = "canary record %s is not resolved within %s"
+	errCanaryUpdate    = "failed to update canary domain %s"
+	errSendAlert       = "failed to send %s alert of %s"
+	errUnexpectedReply = "unexpected reply status from %s: %s"
+)
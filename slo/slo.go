@@ -0,0 +1,213 @@
+package slo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rancher/rdns-server/jobs"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+type SLI string
+
+const (
+	// APIAvailability is the ratio of API requests which are not answered with 5xx
+	APIAvailability SLI = "api_availability"
+	// RenewSuccess is the ratio of renew requests which succeed
+	RenewSuccess SLI = "renew_success"
+	// ResolveLatency is the ratio of canary records which resolve within SLO_RESOLVE_THRESHOLD
+	ResolveLatency SLI = "resolve_latency"
+
+	severityPage   = "page"
+	severityTicket = "ticket"
+
+	evaluateInterval = 30 * time.Second
+	alertTimeout     = 5 * time.Second
+
+	kindAlert        = "slo.alert"
+	maxAlertAttempts = 5
+)
+
+var (
+	objectives = map[SLI]float64{
+		APIAvailability: 0.999,
+		RenewSuccess:    0.995,
+		ResolveLatency:  0.99,
+	}
+
+	// multiwindow burn-rate alerts, a long window with a short window to reset quickly
+	alerts = []struct {
+		severity  string
+		long      time.Duration
+		short     time.Duration
+		threshold float64
+	}{
+		{severityPage, time.Hour, 5 * time.Minute, 14.4},
+		{severityTicket, 6 * time.Hour, 30 * time.Minute, 6},
+	}
+
+	windows = map[SLI]*window{
+		APIAvailability: {},
+		RenewSuccess:    {},
+		ResolveLatency:  {},
+	}
+
+	burnRateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rancher_dns_slo_burn_rate",
+		Help: "The error budget burn rate of the rancher dns slo within the window",
+	}, []string{"slo", "window"})
+
+	objectiveGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rancher_dns_slo_objective",
+		Help: "The objective of the rancher dns slo",
+	}, []string{"slo"})
+)
+
+type Alert struct {
+	SLO       SLI       `json:"slo"`
+	Severity  string    `json:"severity"`
+	Firing    bool      `json:"firing"`
+	BurnRate  float64   `json:"burnRate"`
+	Window    string    `json:"window"`
+	Objective float64   `json:"objective"`
+	Time      time.Time `json:"time"`
+}
+
+// Record counts one event of the SLI.
+func Record(s SLI, good bool) {
+	if w, ok := windows[s]; ok {
+		w.add(time.Now(), good)
+	}
+}
+
+type evaluator struct {
+	webhook string
+	client  *http.Client
+	firing  map[string]bool
+}
+
+// StartSLODaemon computes the burn-rate series of the built-in SLOs and sends
+// alerts to SLO_ALERT_WEBHOOK when it is set.
+func StartSLODaemon(done chan struct{}) {
+	for s, o := range objectives {
+		objectiveGauge.WithLabelValues(string(s)).Set(o)
+	}
+
+	if resolver := os.Getenv("SLO_CANARY_RESOLVER"); resolver != "" {
+		go startCanary(resolver, done)
+	}
+
+	e := &evaluator{
+		webhook: os.Getenv("SLO_ALERT_WEBHOOK"),
+		client:  &http.Client{Timeout: alertTimeout},
+		firing:  make(map[string]bool),
+	}
+	if e.webhook != "" {
+		w := &jobs.Worker{
+			Kind:        kindAlert,
+			Workers:     1,
+			MaxAttempts: maxAlertAttempts,
+			Handle:      e.sendJob,
+		}
+		go w.Run(done)
+	}
+	wait.Until(e.evaluate, evaluateInterval, done)
+}
+
+func (e *evaluator) evaluate() {
+	now := time.Now()
+
+	for s, w := range windows {
+		budget := 1 - objectives[s]
+		rates := make(map[time.Duration]float64)
+
+		for _, a := range alerts {
+			for _, d := range []time.Duration{a.long, a.short} {
+				if _, ok := rates[d]; ok {
+					continue
+				}
+				ratio, _ := w.errorRatio(now, d)
+				rates[d] = ratio / budget
+				burnRateGauge.WithLabelValues(string(s), d.String()).Set(rates[d])
+			}
+		}
+
+		for _, a := range alerts {
+			firing := rates[a.long] > a.threshold && rates[a.short] > a.threshold
+			key := string(s) + "/" + a.severity
+			if firing == e.firing[key] {
+				continue
+			}
+			e.firing[key] = firing
+
+			alert := &Alert{
+				SLO:       s,
+				Severity:  a.severity,
+				Firing:    firing,
+				BurnRate:  rates[a.long],
+				Window:    a.long.String(),
+				Objective: objectives[s],
+				Time:      now,
+			}
+			logrus.Warnf("slo %s %s alert firing: %t, burn rate %.2f within %s", s, a.severity, firing, alert.BurnRate, alert.Window)
+
+			if err := e.queue(alert); err != nil {
+				logrus.Error(err)
+			}
+		}
+	}
+}
+
+// Used to queue an alert in the backend so it is retried, an alert which the backend can not keep
+// is sent at once, e.g. the alerts of an outage of the backend.
+func (e *evaluator) queue(a *Alert) error {
+	if e.webhook == "" {
+		return nil
+	}
+
+	if err := jobs.Enqueue(kindAlert, a); err != nil {
+		logrus.Warn(err)
+		return e.send(a)
+	}
+	return nil
+}
+
+func (e *evaluator) sendJob(j model.Job) error {
+	a := &Alert{}
+	if err := json.Unmarshal(j.Payload, a); err != nil {
+		return jobs.Permanent(err)
+	}
+	return e.send(a)
+}
+
+func (e *evaluator) send(a *Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return errors.Wrapf(err, errSendAlert, a.Severity, a.SLO)
+	}
+
+	resp, err := e.client.Post(e.webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, errSendAlert, a.Severity, a.SLO)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		err := errors.Errorf(errUnexpectedReply, e.webhook, resp.Status)
+		if resp.StatusCode < http.StatusInternalServerError {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	return nil
+}
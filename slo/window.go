@@ -0,0 +1,61 @@
+package slo
+
+import (
+	"sync"
+	"time"
+)
+
+// keep one bucket per minute for the longest burn-rate window
+const windowBuckets = 360
+
+type bucket struct {
+	minute int64
+	good   int64
+	total  int64
+}
+
+// window counts good and total events per minute, so error ratios of
+// any window up to six hours can be computed without keeping every event.
+type window struct {
+	mu      sync.Mutex
+	buckets [windowBuckets]bucket
+}
+
+func (w *window) add(t time.Time, good bool) {
+	minute := t.Unix() / 60
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &w.buckets[minute%windowBuckets]
+	if b.minute != minute {
+		*b = bucket{minute: minute}
+	}
+	b.total++
+	if good {
+		b.good++
+	}
+}
+
+// errorRatio returns the ratio of bad events within the last d,
+// and whether there was any event at all.
+func (w *window) errorRatio(t time.Time, d time.Duration) (float64, bool) {
+	now := t.Unix() / 60
+	from := now - int64(d/time.Minute) + 1
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var good, total int64
+	for _, b := range w.buckets {
+		if b.minute >= from && b.minute <= now {
+			good += b.good
+			total += b.total
+		}
+	}
+
+	if total == 0 {
+		return 0, false
+	}
+	return float64(total-good) / float64(total), true
+}
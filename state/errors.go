@@ -0,0 +1,8 @@
+package state
+
+const (
+	errDecodeState  = "failed to decode operational state %s"
+	errEncodeState  = "failed to encode operational state %s"
+	errUpdateState  = "failed to update operational state %s after %d attempts"
+	errRestoreState = "failed to restore double-write state %s"
+)
@@ -0,0 +1,232 @@
+// Package state keeps the operational state of the server in the state keyspace of the backend,
+// so the state which is switched through the admin api survives restarts and every replica
+// reads the same one. The suspensions and the data migrations keep their own keyspaces.
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/dual"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// KeyMaintenance keeps the maintenance mode
+	KeyMaintenance = "maintenance"
+	// KeyFeatures keeps the feature flags by name
+	KeyFeatures = "features"
+	// KeyBackend keeps the double-write state
+	KeyBackend = "backend"
+
+	defaultSyncInterval = 10 * time.Second
+	// updates which conflict with the writes of other replicas are retried maxAttempts times
+	maxAttempts = 5
+)
+
+// the state of this replica, it is synced every STATE_SYNC_INTERVAL
+var current = struct {
+	sync.RWMutex
+	maintenance model.Maintenance
+	features    map[string]bool
+}{features: make(map[string]bool)}
+
+// Get decodes the state of the key into v and returns its version, v is left as is when the
+// key is never written.
+func Get(b backend.Backend, key string, v interface{}) (int64, error) {
+	s, err := b.GetOperationalState(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(s.Value) > 0 {
+		if err := json.Unmarshal(s.Value, v); err != nil {
+			return 0, errors.Wrapf(err, errDecodeState, key)
+		}
+	}
+	return s.Version, nil
+}
+
+// Update reads the state of the key into v, changes it with f and writes it with the version it
+// was read with, the update is read and changed again when another replica wrote it meanwhile.
+// v is a pointer, it is zeroed before every read.
+func Update(b backend.Backend, key string, v interface{}, f func() error) error {
+	for i := 0; i < maxAttempts; i++ {
+		e := reflect.ValueOf(v).Elem()
+		e.Set(reflect.Zero(e.Type()))
+
+		version, err := Get(b, key, v)
+		if err != nil {
+			return err
+		}
+		if err := f(); err != nil {
+			return err
+		}
+
+		value, err := json.Marshal(v)
+		if err != nil {
+			return errors.Wrapf(err, errEncodeState, key)
+		}
+
+		err = b.SetOperationalState(&model.OperationalState{Key: key, Value: value, Version: version})
+		if errors.Cause(err) == backend.ErrStateConflict {
+			continue
+		}
+		return err
+	}
+	return errors.Errorf(errUpdateState, key, maxAttempts)
+}
+
+// GetMaintenance returns the maintenance mode, it is disabled unless it is switched.
+func GetMaintenance(b backend.Backend) (model.Maintenance, error) {
+	var m model.Maintenance
+	_, err := Get(b, KeyMaintenance, &m)
+	return m, err
+}
+
+// SetMaintenance switches the maintenance mode, it is enabled since the first time it is switched on.
+func SetMaintenance(b backend.Backend, m model.Maintenance) (model.Maintenance, error) {
+	var result model.Maintenance
+	err := Update(b, KeyMaintenance, &result, func() error {
+		since := result.Since
+		if !result.Enabled || since == nil {
+			t := time.Now()
+			since = &t
+		}
+		result = m
+		result.Since = nil
+		if m.Enabled {
+			result.Since = since
+		}
+		return nil
+	})
+	if err == nil {
+		setMaintenance(result)
+	}
+	return result, err
+}
+
+// InMaintenance returns the maintenance mode of this replica, it is synced with the backend
+// every STATE_SYNC_INTERVAL.
+func InMaintenance() model.Maintenance {
+	current.RLock()
+	defer current.RUnlock()
+
+	return current.maintenance
+}
+
+// GetFeatures returns the feature flags by name, the flags which are never switched are missing.
+func GetFeatures(b backend.Backend) (map[string]bool, error) {
+	features := make(map[string]bool)
+	_, err := Get(b, KeyFeatures, &features)
+	return features, err
+}
+
+// SetFeature switches a feature flag and returns all of them.
+func SetFeature(b backend.Backend, name string, enabled bool) (map[string]bool, error) {
+	var features map[string]bool
+	err := Update(b, KeyFeatures, &features, func() error {
+		if features == nil {
+			features = make(map[string]bool)
+		}
+		features[name] = enabled
+		return nil
+	})
+	if err == nil {
+		setFeatures(features)
+	}
+	return features, err
+}
+
+// Enabled returns whether the feature flag is switched on for this replica, it is synced with
+// the backend every STATE_SYNC_INTERVAL.
+func Enabled(name string) bool {
+	current.RLock()
+	defer current.RUnlock()
+
+	return current.features[name]
+}
+
+// GetBackendState returns the persisted double-write state, empty when it is never switched.
+func GetBackendState(b backend.Backend) (string, error) {
+	var s model.BackendState
+	_, err := Get(b, KeyBackend, &s)
+	return s.State, err
+}
+
+// SetBackendState persists the double-write state, so restarted and other replicas switch to it.
+func SetBackendState(b backend.Backend, s string) error {
+	var st model.BackendState
+	return Update(b, KeyBackend, &st, func() error {
+		st.State = s
+		return nil
+	})
+}
+
+// Load reads the operational state of the backend, and restores the persisted double-write
+// state over DOUBLE_WRITE_STATE.
+func Load(b backend.Backend) error {
+	m, err := GetMaintenance(b)
+	if err != nil {
+		return err
+	}
+	features, err := GetFeatures(b)
+	if err != nil {
+		return err
+	}
+	setMaintenance(m)
+	setFeatures(features)
+
+	d, ok := b.(*dual.Backend)
+	if !ok {
+		return nil
+	}
+	s, err := GetBackendState(b)
+	if err != nil || s == "" {
+		return err
+	}
+	return errors.Wrapf(d.Restore(s), errRestoreState, s)
+}
+
+// StartStateDaemon loads the operational state every STATE_SYNC_INTERVAL, so the state which is
+// switched by another replica is used by this one within the interval.
+func StartStateDaemon(done chan struct{}) {
+	interval, err := time.ParseDuration(os.Getenv("STATE_SYNC_INTERVAL"))
+	if err != nil || interval <= 0 {
+		logrus.Errorf("invalid state sync interval %s, use %s", os.Getenv("STATE_SYNC_INTERVAL"), defaultSyncInterval)
+		interval = defaultSyncInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := Load(backend.GetBackend()); err != nil {
+				logrus.Warnf("failed to sync operational state: %v", err)
+			}
+		}
+	}
+}
+
+func setMaintenance(m model.Maintenance) {
+	current.Lock()
+	defer current.Unlock()
+
+	current.maintenance = m
+}
+
+func setFeatures(features map[string]bool) {
+	current.Lock()
+	defer current.Unlock()
+
+	current.features = features
+}
@@ -0,0 +1,7 @@
+package usage
+
+const (
+	errExtendDomain  = "failed to extend the lease of %s"
+	errInvalidTier   = "invalid usage tier: %s"
+	errNotExtendable = "leases of the %s backend can not be extended"
+)
@@ -0,0 +1,159 @@
+package usage
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWindow = 24 * time.Hour
+	// queries of more domains than maxDomains within a window are not counted
+	maxDomains = 100000
+)
+
+var (
+	extensions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_usage_extensions",
+		Help: "The number of domains whose lease is extended by usage tier",
+	}, []string{"tier"})
+
+	counts = struct {
+		sync.Mutex
+		queries map[string]uint64
+	}{queries: make(map[string]uint64)}
+)
+
+// Tier gives the domains which are asked for at least Queries times within a window a lease of Lease.
+type Tier struct {
+	Queries uint64
+	Lease   time.Duration
+}
+
+// Extender is implemented by the backends whose domains can be moved to a longer lease.
+type Extender interface {
+	Extend(fqdn string, lease time.Duration) (bool, error)
+}
+
+// Record counts an answered query of a name under the zone for the domain it belongs to
+// e.g. x1.qrn7oq.lb.rancher.cloud., lb.rancher.cloud. => qrn7oq.lb.rancher.cloud
+func Record(name, zone string) {
+	slug := util.SlugWithZone(name, zone)
+	if slug == "" || slug == "*" {
+		return
+	}
+	fqdn := slug + "." + strings.Trim(zone, ".")
+
+	counts.Lock()
+	defer counts.Unlock()
+
+	if _, ok := counts.queries[fqdn]; !ok && len(counts.queries) >= maxDomains {
+		return
+	}
+	counts.queries[fqdn]++
+}
+
+// ParseTiers parses tiers of queries and leases, the tiers are sorted from the busiest one
+// e.g. 100:720h,10000:2160h => [{10000 2160h} {100 720h}]
+func ParseTiers(s string) ([]Tier, error) {
+	tiers := make([]Tier, 0)
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		ss := strings.SplitN(t, ":", 2)
+		if len(ss) != 2 {
+			return nil, errors.Errorf(errInvalidTier, t)
+		}
+		queries, err := strconv.ParseUint(ss[0], 10, 64)
+		if err != nil || queries == 0 {
+			return nil, errors.Errorf(errInvalidTier, t)
+		}
+		lease, err := time.ParseDuration(ss[1])
+		if err != nil || lease <= 0 {
+			return nil, errors.Errorf(errInvalidTier, t)
+		}
+		tiers = append(tiers, Tier{Queries: queries, Lease: lease})
+	}
+
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Queries > tiers[j].Queries })
+	return tiers, nil
+}
+
+// StartUsageDaemon extends the leases of the domains which reach a tier of USAGE_TIERS at the end
+// of every USAGE_WINDOW, idle domains keep the default lease. Queries are counted by the
+// embedded CoreDNS of this instance only, it returns at once when no tiers are configured.
+func StartUsageDaemon(done chan struct{}) {
+	tiers, err := ParseTiers(os.Getenv("USAGE_TIERS"))
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	if len(tiers) == 0 {
+		return
+	}
+
+	b := backend.GetBackend()
+	e, ok := b.(Extender)
+	if !ok {
+		logrus.Errorf(errNotExtendable, b.GetName())
+		return
+	}
+
+	window, err := time.ParseDuration(os.Getenv("USAGE_WINDOW"))
+	if err != nil || window <= 0 {
+		logrus.Errorf("invalid usage window %s, use %s", os.Getenv("USAGE_WINDOW"), defaultWindow)
+		window = defaultWindow
+	}
+
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			extend(e, tiers, reset())
+		}
+	}
+}
+
+func reset() map[string]uint64 {
+	counts.Lock()
+	defer counts.Unlock()
+
+	queries := counts.queries
+	counts.queries = make(map[string]uint64)
+	return queries
+}
+
+func extend(e Extender, tiers []Tier, queries map[string]uint64) {
+	for fqdn, n := range queries {
+		for _, t := range tiers {
+			if n < t.Queries {
+				continue
+			}
+			ok, err := e.Extend(fqdn, t.Lease)
+			if err != nil {
+				logrus.Warn(errors.Wrapf(err, errExtendDomain, fqdn))
+			}
+			if ok {
+				extensions.WithLabelValues(strconv.FormatUint(t.Queries, 10)).Inc()
+				logrus.Infof("extended lease of %s to %s with %d queries", fqdn, t.Lease, n)
+			}
+			break
+		}
+	}
+}
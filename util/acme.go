@@ -0,0 +1,13 @@
+package util
+
+import "strings"
+
+// ACMEChallengeLabel is the label of the TXT records which answer ACME dns-01 challenges
+const ACMEChallengeLabel = "_acme-challenge"
+
+// Used to check whether a fqdn is an ACME challenge record
+// e.g. _acme-challenge.qrn7oq.lb.rancher.cloud => true
+// e.g. _acme-challenge.x1.qrn7oq.lb.rancher.cloud => true
+func IsACMEChallenge(fqdn string) bool {
+	return strings.HasPrefix(strings.ToLower(fqdn), ACMEChallengeLabel+".")
+}
@@ -0,0 +1,15 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CAAPath keeps the CAA records of the domains below the etcd prefix, the dns plugin reads them from there
+const CAAPath = "/caav3"
+
+// Used to get the key of the CAA records of a domain, it is shared by the backend and the dns plugin
+// e.g. /rdnsv3, sample.lb.rancher.cloud. => /rdnsv3/caav3/sample_lb_rancher_cloud
+func CAAKey(prefix, fqdn string) string {
+	return fmt.Sprintf("%s%s/%s", prefix, CAAPath, strings.Replace(strings.TrimSuffix(strings.ToLower(fqdn), "."), ".", "_", -1))
+}
@@ -0,0 +1,28 @@
+package util
+
+import "net"
+
+var privateNets = func() []*net.IPNet {
+	nets := make([]*net.IPNet, 0)
+	for _, c := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, n, _ := net.ParseCIDR(c)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// Used to check whether an address is reachable from the internet
+// e.g. 1.1.1.1 => true
+// e.g. 10.0.0.1, 127.0.0.1, fe80::1 => false
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,15 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QueryTypesPath keeps the query types of the restricted domains below the etcd prefix, the dns plugin reads them from there
+const QueryTypesPath = "/typesv3"
+
+// Used to get the key of the query types of a domain, it is shared by the backend and the dns plugin
+// e.g. /rdnsv3, sample.lb.rancher.cloud. => /rdnsv3/typesv3/sample_lb_rancher_cloud
+func QueryTypesKey(prefix, fqdn string) string {
+	return fmt.Sprintf("%s%s/%s", prefix, QueryTypesPath, strings.Replace(strings.TrimSuffix(strings.ToLower(fqdn), "."), ".", "_", -1))
+}
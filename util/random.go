@@ -61,8 +61,12 @@ func SecureRandomString(availableCharBytes string, length int) string {
 	return string(result)
 }
 
-// secureRandomBytes returns the requested number of bytes using crypto/rand
+// secureRandomBytes returns the requested number of bytes using crypto/rand,
+// or the seeded source in test mode
 func secureRandomBytes(length int) []byte {
+	if b, ok := testRandomBytes(length); ok {
+		return b
+	}
 	var randomBytes = make([]byte, length)
 	_, err := rand.Read(randomBytes)
 	if err != nil {
@@ -0,0 +1,28 @@
+package util
+
+import "strings"
+
+// Used to split the root domains of DOMAIN, the first root domain is the default one
+// e.g. "lb.example1.com, lb.example2.com." => [lb.example1.com lb.example2.com]
+func RootDomains(s string) []string {
+	roots := make([]string, 0)
+	for _, r := range strings.Split(s, ",") {
+		if r = strings.Trim(strings.TrimSpace(r), "."); r != "" {
+			roots = append(roots, strings.ToLower(r))
+		}
+	}
+	return roots
+}
+
+// Used to find the longest root domain which a fqdn is under, an empty root means it is under none
+// e.g. qrn7oq.lb.example2.com, [lb.example1.com lb.example2.com] => lb.example2.com
+func RootOf(fqdn string, roots []string) string {
+	name := strings.ToLower(strings.TrimSuffix(fqdn, "."))
+	root := ""
+	for _, r := range roots {
+		if (name == r || strings.HasSuffix(name, "."+r)) && len(r) > len(root) {
+			root = r
+		}
+	}
+	return root
+}
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+const shardLabelPrefix = "_"
+
+// Used to get the shard label of a slug, an empty label means sharding is disabled
+// e.g. qrn7oq with 64 shards => _21
+func ShardLabel(slug string, shards int) string {
+	if shards <= 0 {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(slug))
+	return fmt.Sprintf("%s%x", shardLabelPrefix, h.Sum32()%uint32(shards))
+}
+
+// Used to check whether a key segment is a shard label
+// e.g. _21 => true
+func IsShardLabel(label string) bool {
+	return strings.HasPrefix(label, shardLabelPrefix)
+}
+
+// Used to insert the shard label between the slug and the zone
+// e.g. x1.qrn7oq.lb.rancher.cloud => x1.qrn7oq._21.lb.rancher.cloud
+func ShardFqdn(fqdn, zone string, shards int) string {
+	slug := SlugWithZone(fqdn, zone)
+	if slug == "" || slug == "*" {
+		return fqdn
+	}
+	label := ShardLabel(slug, shards)
+	if label == "" {
+		return fqdn
+	}
+	suffix := "." + strings.Trim(zone, ".")
+	name := strings.TrimSuffix(strings.TrimSuffix(fqdn, "."), suffix)
+	return strings.Replace(fqdn, name+suffix, name+"."+label+suffix, 1)
+}
+
+// Used to find the slug of a fqdn, an empty slug means the fqdn is not under the zone
+// e.g. x1.qrn7oq.lb.rancher.cloud => qrn7oq
+func SlugWithZone(fqdn, zone string) string {
+	suffix := "." + strings.Trim(zone, ".")
+	name := strings.TrimSuffix(fqdn, ".")
+	if !strings.HasSuffix(name, suffix) {
+		return ""
+	}
+	ss := strings.Split(strings.TrimSuffix(name, suffix), ".")
+	return ss[len(ss)-1]
+}
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// TestLeaseTime is the lease of every domain in test mode, domains outlive any test run
+const TestLeaseTime = "87600h"
+
+var testMode struct {
+	sync.Mutex
+	r *rand.Rand
+}
+
+// SetTestMode makes the generated slugs and tokens deterministic from the seed,
+// it must never be enabled for a server which serves real domains.
+func SetTestMode(seed int64) {
+	testMode.Lock()
+	defer testMode.Unlock()
+
+	testMode.r = rand.New(rand.NewSource(seed))
+}
+
+func IsTestMode() bool {
+	testMode.Lock()
+	defer testMode.Unlock()
+
+	return testMode.r != nil
+}
+
+// Used to read random bytes from the seeded source, ok is false when test mode is disabled
+func testRandomBytes(length int) ([]byte, bool) {
+	testMode.Lock()
+	defer testMode.Unlock()
+
+	if testMode.r == nil {
+		return nil, false
+	}
+
+	b := make([]byte, length)
+	testMode.r.Read(b)
+	return b, true
+}
@@ -0,0 +1,12 @@
+package webhook
+
+const (
+	errDeliver         = "failed to deliver %s event of %s to %s"
+	errDrop            = "drop %s event of %s to %s"
+	errInvalidEvent    = "invalid webhook event: %s"
+	errInvalidURL      = "invalid webhook url: %s"
+	errListWebhooks    = "failed to list webhooks of %s"
+	errPrivateAddress  = "webhook address %s is not public"
+	errTooManyWebhooks = "domain %s already has %d webhooks"
+	errUnexpectedReply = "unexpected reply status from %s: %s"
+)
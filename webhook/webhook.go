@@ -0,0 +1,348 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/config"
+	"github.com/rancher/rdns-server/jobs"
+	"github.com/rancher/rdns-server/lifecycle"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	scopeGlobal = "global"
+	scopeDomain = "domain"
+	scopeExpiry = "expiry"
+
+	kindDelivery = "webhook"
+
+	deliverTimeout = 10 * time.Second
+	maxAttempts    = 3
+	defaultWorkers = 4
+	idLength       = 12
+	secretLength   = 32
+)
+
+var (
+	deliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_webhook_deliveries",
+		Help: "The number of webhook deliveries by scope and result",
+	}, []string{"scope", "result"})
+
+	// delivery ids start from the start time in nanoseconds, they keep increasing across restarts
+	lastDelivery = uint64(time.Now().UnixNano())
+
+	globalClient = &http.Client{Timeout: deliverTimeout}
+	// webhooks of domains are registered by anyone holding a domain token,
+	// they are never delivered to loopback, private or link-local addresses
+	domainClient = &http.Client{
+		Timeout: deliverTimeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: deliverTimeout,
+				Control: publicOnly,
+			}).DialContext,
+			MaxIdleConns:    100,
+			IdleConnTimeout: 90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+type target struct {
+	Scope  string `json:"scope"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// delivery is the job of posting an event to one target, its id is kept across the attempts.
+type delivery struct {
+	ID     uint64          `json:"id"`
+	Type   string          `json:"type"`
+	Fqdn   string          `json:"fqdn"`
+	Target target          `json:"target"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// StartWebhookDaemon delivers the published events to the global webhook WEBHOOK_URL
+// and to the webhooks of their domain, deliveries which are queued by any instance are claimed.
+func StartWebhookDaemon(done chan struct{}) {
+	if u := os.Getenv("WEBHOOK_URL"); u != "" {
+		logrus.Infof("deliver webhook events to %s", u)
+	}
+
+	workers, err := strconv.Atoi(os.Getenv("WEBHOOK_WORKERS"))
+	if err != nil || workers <= 0 {
+		logrus.Errorf("invalid webhook workers %s, use %d", os.Getenv("WEBHOOK_WORKERS"), defaultWorkers)
+		workers = defaultWorkers
+	}
+
+	w := &jobs.Worker{
+		Kind:        kindDelivery,
+		Workers:     workers,
+		MaxAttempts: maxAttempts,
+		Handle:      deliver,
+	}
+	w.Run(done)
+}
+
+// Publish queues a delivery of an event of the fqdn to every target in the backend, the webhooks of
+// its domain are looked up at once so an event of a deleted domain still reaches them. Deliveries
+// are dropped when the backend can not keep them.
+func Publish(eventType, fqdn string, data interface{}) {
+	PublishChange(eventType, fqdn, data, nil, nil)
+}
+
+// PublishChange publishes an event which changed the records of the fqdn, the records before and
+// after are posted with the diff of them so consumers do not have to look them up.
+func PublishChange(eventType, fqdn string, data interface{}, before, after *model.Domain) {
+	if lifecycle.Disabled("webhook") {
+		return
+	}
+
+	e := model.WebhookEvent{
+		Type:   eventType,
+		Fqdn:   fqdn,
+		Data:   data,
+		Before: before,
+		After:  after,
+	}
+	if before != nil || after != nil {
+		e.Diff = model.DiffRecords(before, after)
+	}
+
+	var ws []model.Webhook
+	if owner := Owner(fqdn); owner != "" {
+		var err error
+		if ws, err = backend.GetBackend().ListWebhooks(owner); err != nil {
+			logrus.Warn(errors.Wrapf(err, errListWebhooks, owner))
+		}
+	}
+
+	publish(e, globalTargets(), ws)
+}
+
+// Snapshot gets the records of a name before they are changed, nil is returned when it does not
+// exist or the events are not published.
+func Snapshot(get func(opts *model.DomainOptions) (model.Domain, error), opts *model.DomainOptions) *model.Domain {
+	if lifecycle.Disabled("webhook") {
+		return nil
+	}
+
+	d, err := get(opts)
+	if err != nil || d.Fqdn == "" {
+		return nil
+	}
+	return &d
+}
+
+// PublishExpiry queues an expiry event of a domain to the webhooks it had, they expire with the domain
+// so they are looked up before. The event is also posted to EXPIRY_WEBHOOK_URL when it is set.
+func PublishExpiry(eventType, fqdn string, data interface{}, ws []model.Webhook) {
+	if lifecycle.Disabled("webhook") {
+		return
+	}
+
+	targets := globalTargets()
+	if u := os.Getenv("EXPIRY_WEBHOOK_URL"); u != "" {
+		targets = append(targets, target{Scope: scopeExpiry, URL: u, Secret: os.Getenv("WEBHOOK_SECRET")})
+	}
+
+	publish(model.WebhookEvent{Type: eventType, Fqdn: fqdn, Data: data}, targets, ws)
+}
+
+func globalTargets() []target {
+	targets := make([]target, 0)
+	if u := os.Getenv("WEBHOOK_URL"); u != "" {
+		targets = append(targets, target{Scope: scopeGlobal, URL: u, Secret: os.Getenv("WEBHOOK_SECRET")})
+	}
+	return targets
+}
+
+func publish(e model.WebhookEvent, targets []target, ws []model.Webhook) {
+	for _, w := range ws {
+		if w.Subscribed(e.Type) {
+			targets = append(targets, target{Scope: scopeDomain, URL: w.URL, Secret: w.Secret})
+		}
+	}
+
+	if len(targets) == 0 {
+		return
+	}
+
+	e.Time = time.Now().UTC()
+	body, err := json.Marshal(e)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	for _, t := range targets {
+		d := &delivery{
+			ID:     atomic.AddUint64(&lastDelivery, 1),
+			Type:   e.Type,
+			Fqdn:   e.Fqdn,
+			Target: t,
+			Body:   body,
+		}
+		if err := jobs.Enqueue(kindDelivery, d); err != nil {
+			deliveries.WithLabelValues(t.Scope, "dropped").Inc()
+			logrus.Warn(errors.Wrapf(err, errDrop, e.Type, e.Fqdn, t.URL))
+		}
+	}
+}
+
+// Forget deletes the webhooks of a domain, it is called once the domain is deleted.
+func Forget(fqdn string) {
+	b := backend.GetBackend()
+	ws, err := b.ListWebhooks(fqdn)
+	if err != nil {
+		logrus.Warn(errors.Wrapf(err, errListWebhooks, fqdn))
+		return
+	}
+	for _, w := range ws {
+		if err := b.DeleteWebhook(fqdn, w.ID); err != nil {
+			logrus.Warn(err)
+		}
+	}
+}
+
+// New validates the options and returns a webhook of the domain with a new id.
+func New(fqdn string, opts *model.WebhookOptions) (*model.Webhook, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, errors.Errorf(errInvalidURL, opts.URL)
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !util.IsPublicIP(ip) {
+		return nil, errors.Errorf(errPrivateAddress, ip.String())
+	}
+
+	known := make(map[string]bool)
+	for _, e := range model.WebhookEvents {
+		known[e] = true
+	}
+	for _, e := range opts.Events {
+		if !known[e] {
+			return nil, errors.Errorf(errInvalidEvent, e)
+		}
+	}
+
+	ws, err := backend.GetBackend().ListWebhooks(fqdn)
+	if err != nil {
+		return nil, errors.Wrapf(err, errListWebhooks, fqdn)
+	}
+	if len(ws) >= config.Resolve(fqdn).MaxWebhooks {
+		return nil, errors.Errorf(errTooManyWebhooks, fqdn, len(ws))
+	}
+
+	// every delivery to a domain webhook is signed, a secret is generated when none is given
+	secret := opts.Secret
+	if secret == "" {
+		secret = util.RandStringWithAll(secretLength)
+	}
+
+	return &model.Webhook{
+		ID:     util.RandStringWithSmall(idLength),
+		Fqdn:   fqdn,
+		URL:    opts.URL,
+		Secret: secret,
+		Events: opts.Events,
+	}, nil
+}
+
+// Owner returns the domain which an fqdn belongs to, it is empty when the fqdn is not under the zone
+// e.g. _acme-challenge.x1.qrn7oq.lb.rancher.cloud => qrn7oq.lb.rancher.cloud
+func Owner(fqdn string) string {
+	zone := strings.Trim(backend.ZoneOf(backend.GetBackend(), fqdn), ".")
+	slug := util.SlugWithZone(fqdn, zone)
+	if slug == "" || slug == "*" {
+		return ""
+	}
+	return slug + "." + zone
+}
+
+// Used to deliver a queued delivery, errors and 5xx replies are retried with the same delivery id
+// and signed again with a new timestamp.
+func deliver(j model.Job) error {
+	d := &delivery{}
+	if err := json.Unmarshal(j.Payload, d); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	err := d.post()
+	switch {
+	case err == nil:
+		deliveries.WithLabelValues(d.Target.Scope, "success").Inc()
+		return nil
+	case j.Attempts < maxAttempts && !jobs.IsPermanent(err):
+		deliveries.WithLabelValues(d.Target.Scope, "retry").Inc()
+	default:
+		deliveries.WithLabelValues(d.Target.Scope, "failure").Inc()
+	}
+	return errors.Wrapf(err, errDeliver, d.Type, d.Fqdn, d.Target.URL)
+}
+
+func (d *delivery) post() error {
+	client := globalClient
+	if d.Target.Scope == scopeDomain {
+		client = domainClient
+	}
+
+	req, err := http.NewRequest(http.MethodPost, d.Target.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	timestamp := time.Now().Unix()
+	req.Header.Set(model.HeaderWebhookEvent, d.Type)
+	req.Header.Set(model.HeaderWebhookDelivery, strconv.FormatUint(d.ID, 10))
+	req.Header.Set(model.HeaderWebhookTimestamp, strconv.FormatInt(timestamp, 10))
+	if d.Target.Secret != "" {
+		req.Header.Set(model.HeaderWebhookSignature, model.WebhookSignature(d.Target.Secret, timestamp, d.ID, d.Body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err = errors.Errorf(errUnexpectedReply, d.Target.URL, resp.Status)
+	if resp.StatusCode < http.StatusInternalServerError {
+		return jobs.Permanent(err)
+	}
+	return err
+}
+
+func publicOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !util.IsPublicIP(ip) {
+		return errors.Errorf(errPrivateAddress, host)
+	}
+	return nil
+}